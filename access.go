@@ -0,0 +1,180 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 运行中变得不可读的目录
+//
+// 目录被 chmod 000 等改为不可读后，其下路径的 stat 与哈希都会因权限不足失败。Watcher 把这样的目录记录下来：
+// 目录条目标记 FileMetadata.Inaccessible(子树中已有的条目原样保留，不当作删除)，发送一次 *AccessDeniedError，
+// 之后其下路径的事件只检查一次 stat，仍无权限时直接跳过，不报告错误也不计算哈希。
+// 目录本身的事件(如再次 chmod)或其下某个路径的 stat 重新成功时检查目录能否读取，恢复后清除标记、
+// 重新注册其中子目录的监控并对账整个子树(OpReconcile* 事件)。
+
+// AccessDeniedError 表示目录在运行中变得不可读，其子树暂停更新直到恢复访问；每个目录每次变得不可读只发送一次(ErrorChan)
+//
+// errors.Is(err, fs.ErrPermission) 成立
+type AccessDeniedError struct {
+	Path string
+	Err  error
+}
+
+// Error 实现 error 接口
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("directory %s is not accessible: %v", e.Path, e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *AccessDeniedError) Unwrap() error {
+	return e.Err
+}
+
+// deniedDirs 记录当前不可读的目录
+type deniedDirs struct {
+	mu   sync.Mutex
+	dirs map[string]struct{}
+	n    atomic.Int64 // len(dirs)，没有不可读目录时免去加锁
+}
+
+// add 记录 dir，返回是否为新增
+func (d *deniedDirs) add(dir string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.dirs[dir]; ok {
+		return false
+	}
+	if d.dirs == nil {
+		d.dirs = make(map[string]struct{})
+	}
+	d.dirs[dir] = struct{}{}
+	d.n.Store(int64(len(d.dirs)))
+	return true
+}
+
+// remove 清除 dir，返回之前是否已记录
+func (d *deniedDirs) remove(dir string) bool {
+	if d.n.Load() == 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.dirs[dir]; !ok {
+		return false
+	}
+	delete(d.dirs, dir)
+	d.n.Store(int64(len(d.dirs)))
+	return true
+}
+
+// has 判断 dir 本身是否已记录
+func (d *deniedDirs) has(dir string) bool {
+	if d.n.Load() == 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.dirs[dir]
+	return ok
+}
+
+// covering 返回 path 的上级目录中(不含 path 本身)离它最近的不可读目录，没有时返回空串
+func (d *deniedDirs) covering(path string) string {
+	if d.n.Load() == 0 {
+		return ""
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for cur, dir := path, dirOf(path); dir != cur; cur, dir = dir, dirOf(dir) {
+		if _, ok := d.dirs[dir]; ok {
+			return dir
+		}
+	}
+	return ""
+}
+
+// list 返回按路径排序的全部不可读目录
+func (d *deniedDirs) list() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]string, 0, len(d.dirs))
+	for dir := range d.dirs {
+		out = append(out, dir)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// isDenied 判断错误是否为权限不足
+func isDenied(err error) bool {
+	return errors.Is(err, fs.ErrPermission)
+}
+
+// probeDir 检查目录的内容能否读取：列出目录并 stat 第一个条目(只有读权限、没有执行权限时列目录成功但 stat 子路径失败)
+func (w *Watcher) probeDir(dir string) error {
+	entries, err := w.fs.ReadDir(dir)
+	if err == nil && len(entries) > 0 {
+		_, err = w.fs.Stat(filepath.Join(dir, entries[0].Name()))
+	}
+	return err
+}
+
+// denyDir 记录不可读的目录，第一次记录时发送 *AccessDeniedError
+func (w *Watcher) denyDir(dir string, err error) {
+	if !w.denied.add(dir) {
+		return
+	}
+	if w.emitError(&AccessDeniedError{Path: dir, Err: err}) {
+		w.logWarn("Directory became inaccessible", err)
+	}
+}
+
+// deniedAncestor 为因权限不足而 stat 失败的 path 找出不可读的目录：自下而上第一个自身可以 stat 的上级目录(不超出监控根)
+func (w *Watcher) deniedAncestor(path string) string {
+	root := w.rootOf(path)
+	if root == "" || path == root {
+		return ""
+	}
+	for dir := dirOf(path); ; dir = dirOf(dir) {
+		_, err := w.fs.Stat(dir)
+		if err == nil {
+			return dir
+		}
+		if !isDenied(err) || dir == root {
+			return ""
+		}
+	}
+}
+
+// checkDenied 在处理 path 之前检查其上级的不可读目录，返回 true 表示 path 仍无法访问、应当跳过；
+// path 重新可以 stat 时先恢复该目录
+func (w *Watcher) checkDenied(path string) bool {
+	dir := w.denied.covering(path)
+	if dir == "" {
+		return false
+	}
+	if _, err := w.fs.Stat(path); isDenied(err) {
+		return true
+	}
+	w.refreshDir(dir)
+	return false
+}
+
+// refreshDir 重新处理目录条目，提交其 Inaccessible 标记的变化(不可读状态由 applyChange 检查)
+func (w *Watcher) refreshDir(dir string) {
+	w.applyChange(aggItem{dir, aggEntry{op: fsnotify.Chmod}}, true, nil)
+}
+
+// restoreDir 在目录恢复访问后重新注册其中子目录的监控，并对账整个子树
+func (w *Watcher) restoreDir(dir string) {
+	w.watchSubtree(dir)
+	w.reconcile(dir, fmt.Sprintf("Reconcile after %s became accessible", dir))
+}
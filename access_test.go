@@ -0,0 +1,187 @@
+package watcher
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// deniedFS 模拟目录被 chmod 000：dir 之下的路径 stat/打开失败，dir 本身可以 stat 但不能列出
+type deniedFS struct {
+	FS
+	dir atomic.Value // string
+}
+
+func (d *deniedFS) denied() string {
+	s, _ := d.dir.Load().(string)
+	return s
+}
+
+func (d *deniedFS) blocked(name string) bool {
+	dir := d.denied()
+	return dir != "" && name != dir && withinRoot(name, dir)
+}
+
+func (d *deniedFS) Stat(name string) (fs.FileInfo, error) {
+	if d.blocked(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrPermission}
+	}
+	return d.FS.Stat(name)
+}
+
+func (d *deniedFS) Open(name string) (io.ReadCloser, error) {
+	if d.blocked(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return d.FS.Open(name)
+}
+
+func (d *deniedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == d.denied() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return d.FS.ReadDir(name)
+}
+
+func (d *deniedFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return d.FS.WalkDir(root, func(p string, de fs.DirEntry, err error) error {
+		if err == nil && de.IsDir() && p == d.denied() {
+			if err := fn(p, de, nil); err != nil {
+				return err
+			}
+			_ = fn(p, de, &fs.PathError{Op: "open", Path: p, Err: fs.ErrPermission})
+			return filepath.SkipDir
+		}
+		return fn(p, de, err)
+	})
+}
+
+// accessErrors 取出 ErrorChan 中的全部错误，返回其中 *AccessDeniedError 的个数与其它错误
+func accessErrors(w *Watcher) (denied int, others []error) {
+	for len(w.ErrorChan) > 0 {
+		err := <-w.ErrorChan
+		var ae *AccessDeniedError
+		if errors.As(err, &ae) && errors.Is(err, fs.ErrPermission) {
+			denied++
+			continue
+		}
+		others = append(others, err)
+	}
+	return denied, others
+}
+
+// TestInaccessibleDir 测试目录变得不可读后子树保留、只报告一次错误、不再处理其下路径，恢复后对账子树
+func TestInaccessibleDir(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	_ = os.Mkdir(sub, 0755)
+	a, b, c := filepath.Join(sub, "a.txt"), filepath.Join(sub, "b.txt"), filepath.Join(sub, "c.txt")
+	_ = os.WriteFile(a, []byte("a"), 0644)
+	_ = os.WriteFile(b, []byte("b"), 0644)
+	fsys := &deniedFS{FS: osFS{}}
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(fsys, replaySource{}), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	for _, p := range []string{sub, a, b} {
+		w.handleFileChange(w.keyOf(p), fsnotify.Create)
+	}
+
+	// 其下路径的事件发现目录不可读
+	fsys.dir.Store(w.keyOf(sub))
+	w.handleFileChange(w.keyOf(b), fsnotify.Write)
+	cur := w.GetCurrentSnapshot()
+	if m := cur.Files[w.keyOf(sub)]; m == nil || !m.Inaccessible {
+		t.Fatalf("sub = %+v; want it marked inaccessible", m)
+	}
+	if cur.Files[w.keyOf(a)] == nil || cur.Files[w.keyOf(b)] == nil {
+		t.Error("entries under the inaccessible directory should be kept")
+	}
+	_ = os.WriteFile(b, []byte("changed"), 0644)
+	_ = os.WriteFile(c, []byte("c"), 0644)
+	for _, p := range []string{a, b, c, b} {
+		w.handleFileChange(w.keyOf(p), fsnotify.Write)
+	}
+	if w.GetCurrentSnapshot() != cur {
+		t.Error("events under the inaccessible directory should not commit snapshots")
+	}
+	// 目录本身仍可 stat：其事件只更新目录条目，重新对账也不删除子树
+	w.handleFileChange(w.keyOf(sub), fsnotify.Chmod)
+	w.reconcile(w.keyOf(root), "rescan")
+	cur = w.GetCurrentSnapshot()
+	if m := cur.Files[w.keyOf(sub)]; m == nil || !m.Inaccessible {
+		t.Errorf("sub = %+v; want it still marked inaccessible", m)
+	}
+	if cur.Files[w.keyOf(a)] == nil || cur.Files[w.keyOf(b)] == nil || cur.Files[w.keyOf(c)] != nil {
+		t.Error("reconcile should leave the inaccessible subtree untouched")
+	}
+	if n, others := accessErrors(w); n != 1 || len(others) != 0 {
+		t.Errorf("%d AccessDeniedErrors and %v; want exactly one notification", n, others)
+	}
+	if n := w.Stats().InaccessibleDirs; n != 1 {
+		t.Errorf("InaccessibleDirs = %d; want 1", n)
+	}
+
+	// 恢复访问：对账子树
+	sn := w.Subscribe(8)
+	fsys.dir.Store("")
+	w.handleFileChange(w.keyOf(sub), fsnotify.Chmod)
+	cur = w.GetCurrentSnapshot()
+	if m := cur.Files[w.keyOf(sub)]; m == nil || m.Inaccessible {
+		t.Errorf("sub = %+v; want the mark cleared", m)
+	}
+	if cur.Files[w.keyOf(c)] == nil || cur.Files[w.keyOf(b)].Size != 7 {
+		t.Error("the subtree should be reconciled after access is restored")
+	}
+	kinds := map[EventOp]int{}
+	for len(sn.C) > 0 {
+		kinds[(<-sn.C).Kind]++
+	}
+	if kinds[OpReconcileAdd] != 1 || kinds[OpReconcileWrite] != 1 {
+		t.Errorf("events %v; want one reconcile add and one reconcile write", kinds)
+	}
+	if n := w.Stats().InaccessibleDirs; n != 0 {
+		t.Errorf("InaccessibleDirs = %d after restore; want 0", n)
+	}
+}
+
+// TestInaccessibleDirChmod 用真实的 chmod 测试目录本身的权限变化事件
+func TestInaccessibleDirChmod(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("requires Unix permissions enforced for the current user")
+	}
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	_ = os.Mkdir(sub, 0755)
+	file := filepath.Join(sub, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+	w := newTestWatcher(t, root)
+	for _, p := range []string{sub, file} {
+		w.handleFileChange(p, fsnotify.Create)
+	}
+	if err := os.Chmod(sub, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(sub, 0755) })
+	w.handleFileChange(sub, fsnotify.Chmod)
+	w.handleFileChange(file, fsnotify.Write)
+	if m := w.GetCurrentSnapshot().Files[sub]; m == nil || !m.Inaccessible {
+		t.Fatalf("sub = %+v; want it marked inaccessible", m)
+	}
+	if n, others := accessErrors(w); n != 1 || len(others) != 0 {
+		t.Errorf("%d AccessDeniedErrors and %v; want exactly one notification", n, others)
+	}
+	_ = os.Chmod(sub, 0755)
+	w.handleFileChange(sub, fsnotify.Chmod)
+	if m := w.GetCurrentSnapshot().Files[sub]; m == nil || m.Inaccessible {
+		t.Errorf("sub = %+v; want the mark cleared", m)
+	}
+}
@@ -0,0 +1,87 @@
+package watcher
+
+import "sync"
+
+// DefaultMaxPendingPaths 是 ConfigWatcher.MaxPendingPaths 的默认值
+const DefaultMaxPendingPaths = 100000
+
+// inflightPaths 记录已分派给worker(快、慢车道)但尚未处理完的路径
+//
+// 周期性 flush 与合并表达到 MaxPendingPaths 时的立即 flush 可能让前后多个批次同时在处理中；
+// 同一路径在之前的批次中尚未处理完时，本批次把它放回合并表延后到下一次 flush，保证同一路径按顺序处理
+type inflightPaths struct {
+	mu    sync.Mutex
+	paths map[string]struct{}
+}
+
+// claim 登记 items 中可以立即分派的路径，返回这部分(free)与仍在处理中的部分(busy)
+func (f *inflightPaths) claim(items []aggItem) (free, busy []aggItem) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.paths == nil {
+		f.paths = make(map[string]struct{})
+	}
+	free = items[:0]
+	for _, it := range items {
+		if _, ok := f.paths[it.path]; ok {
+			busy = append(busy, it)
+			continue
+		}
+		f.paths[it.path] = struct{}{}
+		free = append(free, it)
+	}
+	return free, busy
+}
+
+// release 在路径处理完后解除登记
+func (f *inflightPaths) release(path string) {
+	f.mu.Lock()
+	delete(f.paths, path)
+	f.mu.Unlock()
+}
+
+// len 返回处理中的路径数
+func (f *inflightPaths) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.paths)
+}
+
+// requeueAgg 把延后的路径放回合并表，与期间新到达的事件合并
+func (w *Watcher) requeueAgg(items []aggItem) {
+	if len(items) == 0 {
+		return
+	}
+	w.aggMu.Lock()
+	for _, it := range items {
+		e := w.aggMap[it.path]
+		trace := joinTrace(it.trace, e.trace, w.cfg.MaxOpTrace)
+		e = e.join(it.aggEntry)
+		e.trace = trace
+		w.aggMap[it.path] = e
+	}
+	w.aggMu.Unlock()
+}
+
+// settled 返回 items 中最近一个事件之后已安静 SettleDelay 的部分，其余放回合并表
+func (w *Watcher) settled(items []aggItem) []aggItem {
+	now := w.now()
+	var waiting []aggItem
+	ready := items[:0]
+	for _, it := range items {
+		if now.Sub(it.last) < w.cfg.SettleDelay {
+			waiting = append(waiting, it)
+			continue
+		}
+		ready = append(ready, it)
+	}
+	w.requeueAgg(waiting)
+	return ready
+}
+
+// aggLen 返回合并表中的路径数
+func (w *Watcher) aggLen() int {
+	w.aggMu.Lock()
+	defer w.aggMu.Unlock()
+	return len(w.aggMap)
+}
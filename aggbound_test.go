@@ -0,0 +1,149 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// slowFS 让每次 Stat 变慢，并记录同一路径是否被并发处理
+type slowFS struct {
+	FS
+	delay time.Duration
+
+	mu      sync.Mutex
+	active  map[string]int
+	overlap atomic.Int32
+}
+
+func (s *slowFS) Stat(name string) (fs.FileInfo, error) {
+	s.mu.Lock()
+	s.active[name]++
+	if s.active[name] > 1 {
+		s.overlap.Add(1)
+	}
+	s.mu.Unlock()
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	s.active[name]--
+	s.mu.Unlock()
+	return s.FS.Stat(name)
+}
+
+// TestMaxPendingPaths 测试定时 flush 从不执行(时钟不前进)且处理变慢时，合并表达到上限即立即 flush，
+// 其大小始终有界，重叠的批次不会同时处理同一路径，Close 后全部事件都已处理
+func TestMaxPendingPaths(t *testing.T) {
+	root := t.TempDir()
+	slow := &slowFS{FS: osFS{}, delay: 100 * time.Microsecond, active: make(map[string]int)}
+	const limit = 100
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(slow, replaySource{}), WithClock(&manualClock{}),
+		WithWorkerCount(4), WithMaxPendingPaths(limit), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// 合并表的大小在合并goroutine处理事件的同时采样
+	var peak atomic.Int64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			if n := int64(w.aggLen()); n > peak.Load() {
+				peak.Store(n)
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(100 * time.Microsecond):
+			}
+		}
+	}()
+	const events = 4000
+	for i := 0; i < events; i++ {
+		// 路径反复出现，前后批次中会有同一路径
+		w.queueAgg(fsnotify.Event{Name: w.keyOf(filepath.Join(root, fmt.Sprintf("f%d", i%1000))), Op: fsnotify.Write})
+	}
+	if !waitFor(t, 10*time.Second, func() bool { return w.Stats().EventsAggregated == events }) {
+		t.Fatalf("aggregated %d of %d events", w.Stats().EventsAggregated, events)
+	}
+	close(done)
+	<-sampled
+	if n := peak.Load(); n > 2*limit {
+		t.Errorf("merge table peaked at %d paths; want at most %d", n, 2*limit)
+	}
+	if n := w.Stats().SizeFlushes; n == 0 {
+		t.Error("SizeFlushes = 0; the size trigger never fired")
+	}
+	if n := slow.overlap.Load(); n != 0 {
+		t.Errorf("the same path was processed concurrently %d times", n)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if n := w.aggLen(); n != 0 || w.inflight.len() != 0 {
+		t.Errorf("after Close: %d pending, %d in flight; want none", n, w.inflight.len())
+	}
+}
+
+// TestSettleDelay 测试 5ms 间隔的两次写入在 20ms 的 SettleDelay 下只产生一个带最终哈希的事件，
+// 以及 Close 时不等待尚未安静的路径
+func TestSettleDelay(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "config.json")
+	clock := &manualClock{}
+	clock.now.Store(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithClock(clock),
+		WithSettleDelay(20*time.Millisecond), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	sub := w.Subscribe(8)
+	write := func(content string) {
+		_ = os.WriteFile(file, []byte(content), 0644)
+		w.queueAgg(fsnotify.Event{Name: w.keyOf(file), Op: fsnotify.Write})
+		w.mergeAgg(<-w.aggChan)
+	}
+	flush := func(d time.Duration) {
+		clock.advance(d)
+		w.flushAgg(false)
+		w.workerWG.Wait()
+	}
+
+	write(`{"v":1}`)
+	flush(5 * time.Millisecond) // 不设置 SettleDelay 时这里会处理中间状态
+	write(`{"v":2}`)
+	flush(10 * time.Millisecond)
+	if n := len(sub.C); n != 0 || w.aggLen() != 1 {
+		t.Fatalf("%d events, %d pending before the path settled; want 0, 1", n, w.aggLen())
+	}
+	flush(10 * time.Millisecond)
+	if n := len(sub.C); n != 1 {
+		t.Fatalf("%d events after the path settled; want exactly 1", n)
+	}
+	sum := sha256.Sum256([]byte(`{"v":2}`))
+	want := hex.EncodeToString(sum[:])
+	if ev := <-sub.C; ev.NewMeta == nil || ev.NewMeta.Hash != want {
+		t.Errorf("event hash = %v; want the final content %q", ev.NewMeta, want)
+	}
+
+	// Close 时处理尚未安静的路径
+	write(`{"v":3}`)
+	_ = w.Close()
+	if ev, ok := <-sub.C; !ok || ev.NewMeta == nil || ev.NewMeta.Size != 7 {
+		t.Errorf("event on Close = %+v, %v; want the unsettled write", ev, ok)
+	}
+}
@@ -0,0 +1,96 @@
+package watcher
+
+import (
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 追加写检测
+//
+// 对大体积的日志类文件，新内容往往只是在旧内容末尾追加。
+// 命中 ConfigWatcher.AppendOnlyPatterns 且文件变大时，先只对前 旧大小 字节求哈希并与旧哈希比对：
+//   - 一致：判定为追加写，记录 AppendedBytes，并在同一个哈希状态上继续读完剩余部分得到完整哈希
+//   - 不一致：判定为重写，继续读完整个文件，退化为普通哈希
+//
+// 两种情况都只顺序读一遍文件，不会重复I/O。
+
+// appendCandidate 判断该文件是否需要走追加写检测
+func (w *Watcher) appendCandidate(path string, fileInfo os.FileInfo, prev *FileMetadata) bool {
+	if prev == nil || prev.IsDirectory || prev.Hash == "" {
+		return false
+	}
+	if fileInfo.Size() <= prev.Size {
+		return false
+	}
+	return matchPatterns(w.cfg.AppendOnlyPatterns, path)
+}
+
+// hashFileAppend 计算文件完整哈希，同时判断前 oldSize 字节的哈希是否等于 oldHash
+//
+// 依赖 hash.Hash 在 Sum 之后仍可继续写入的约定(标准库实现均满足)
+// buf 为读缓冲，语义同 hashFile
+func hashFileAppend(fsys FS, path string, newHash func() hash.Hash, buf []byte, oldSize int64, oldHash string) (string, bool, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	h := newHash()
+	n, err := io.CopyBuffer(h, io.LimitReader(f, oldSize), buf)
+	if err == nil && n < oldSize {
+		err = io.EOF // 与 io.CopyN 一致：文件比 oldSize 短
+	}
+	if err != nil {
+		return "", false, err
+	}
+	isAppend := hex.EncodeToString(h.Sum(nil)) == oldHash
+
+	if _, err := io.CopyBuffer(h, readerOnly{f}, buf); err != nil {
+		return "", false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), isAppend, nil
+}
+
+// matchPatterns 判断路径是否命中任一通配符
+//
+// 不含路径分隔符的模式只匹配文件名(如 "*.log")，含分隔符的模式匹配完整路径(统一为 "/" 分隔，支持 "**")，
+// 规则见 glob.go
+func matchPatterns(patterns []string, path string) bool {
+	base := filepath.Base(path)
+	slashPath := filepath.ToSlash(path)
+	for _, pat := range patterns {
+		if hasPathSeparator(pat) {
+			if matched, _ := matchGlob(filepath.ToSlash(pat), slashPath); matched {
+				return true
+			}
+			continue
+		}
+		if matched, _ := filepath.Match(pat, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sizeChange 计算事件的大小变化与截断标记
+//
+// 新增按旧大小0计算，删除按新大小0计算；目录的大小没有意义，总返回 0, false
+// 截断：新旧都存在且文件变小，或者追加写检测发现旧内容已不是前缀(如日志被轮转后重新写得更大)
+func sizeChange(old, cur *FileMetadata) (delta int64, truncated bool) {
+	if (old != nil && old.IsDirectory) || (cur != nil && cur.IsDirectory) {
+		return 0, false
+	}
+	var oldSize, newSize int64
+	if old != nil {
+		oldSize = old.Size
+	}
+	if cur != nil {
+		newSize = cur.Size
+	}
+	truncated = old != nil && cur != nil && (newSize < oldSize || cur.rewritten)
+	return newSize - oldSize, truncated
+}
@@ -0,0 +1,107 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestAppendOnlyDetection 测试追加写与重写的区分
+func TestAppendOnlyDetection(t *testing.T) {
+	root := t.TempDir()
+	logFile := filepath.Join(root, "app.log")
+	_ = os.WriteFile(logFile, []byte("line1\n"), 0644)
+
+	w := newTestWatcher(t, root)
+	w.cfg.AppendOnlyPatterns = []string{"*.log"}
+	if _, _, err := w.RehashFile(logFile); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+
+	f, _ := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	_, _ = f.WriteString("line2\n")
+	f.Close()
+
+	meta, _, err := w.RehashFile(logFile)
+	if err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	if meta.AppendedBytes != 6 {
+		t.Errorf("AppendedBytes = %d; want 6", meta.AppendedBytes)
+	}
+	full, _ := hashFile(osFS{}, logFile, sha256.New, nil)
+	if meta.Hash != full {
+		t.Errorf("hash after append should equal full file hash")
+	}
+
+	// 重写(前缀不一致)时退化为普通哈希
+	_ = os.WriteFile(logFile, []byte("rewritten content\n"), 0644)
+	meta, _, _ = w.RehashFile(logFile)
+	if meta.AppendedBytes != 0 {
+		t.Errorf("rewrite should not be reported as append, got %d", meta.AppendedBytes)
+	}
+	full, _ = hashFile(osFS{}, logFile, sha256.New, nil)
+	if meta.Hash != full {
+		t.Errorf("hash after rewrite should equal full file hash")
+	}
+}
+
+// TestEventSizeDelta 测试事件的大小变化与截断标记：原地截断、轮转后重建、追加与变大的重写
+func TestEventSizeDelta(t *testing.T) {
+	root := t.TempDir()
+	logFile := filepath.Join(root, "app.log")
+	w := newTestWatcher(t, root)
+	w.cfg.AppendOnlyPatterns = []string{"*.log"}
+	sub := w.Subscribe(16)
+	t.Cleanup(sub.Close)
+
+	step := func(name string, op fsnotify.Op, content string, wantDelta int64, wantTrunc bool) {
+		t.Helper()
+		if content != "" {
+			_ = os.WriteFile(logFile, []byte(content), 0644)
+		}
+		w.handleFileChange(logFile, op)
+		var ev FileEvent
+		for ev = range sub.C {
+			if ev.FilePath == logFile {
+				break
+			}
+		}
+		if ev.SizeDelta != wantDelta || ev.Truncated != wantTrunc {
+			t.Errorf("%s: SizeDelta=%d Truncated=%v; want %d %v", name, ev.SizeDelta, ev.Truncated, wantDelta, wantTrunc)
+		}
+	}
+
+	step("create", fsnotify.Create, "0123456789", 10, false)
+	step("append", fsnotify.Write, "0123456789abc", 3, false)
+	step("in-place truncation", fsnotify.Write, "01", -11, true)
+	// 轮转：旧文件被移走、同名新文件在同一批次中创建
+	_ = os.Remove(logFile)
+	step("rotate and recreate", fsnotify.Rename|fsnotify.Create, "x", -1, true)
+	// 变大但旧内容已不是前缀
+	step("rewrite larger", fsnotify.Write, "something else entirely", 22, true)
+	_ = os.Remove(logFile)
+	step("remove", fsnotify.Remove, "", -23, false)
+}
+
+// TestMatchPatterns 测试通配符匹配
+func TestMatchPatterns(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.log", "/var/log/app.log", true},
+		{"*.log", "/var/log/app.txt", false},
+		{"logs/*.log", "logs/app.log", true},
+		{"logs/*.log", "other/app.log", false},
+	}
+	for _, c := range cases {
+		if got := matchPatterns([]string{c.pattern}, c.path); got != c.match {
+			t.Errorf("matchPatterns(%q, %q) = %v; want %v", c.pattern, c.path, got, c.match)
+		}
+	}
+}
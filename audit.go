@@ -0,0 +1,340 @@
+package watcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 审计日志的默认参数
+const (
+	defaultAuditFlushInterval = time.Second
+	defaultAuditQueueSize     = 4096
+)
+
+// AuditRecord 是审计日志(NDJSON)中的一行
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	Seq        uint64    `json:"seq"`
+	Op         string    `json:"op"`
+	Path       string    `json:"path"`
+	Root       string    `json:"root,omitempty"`
+	OldHash    string    `json:"old_hash,omitempty"`
+	NewHash    string    `json:"new_hash,omitempty"`
+	SnapshotID string    `json:"snapshot_id"`
+	ParentID   string    `json:"parent_id,omitempty"` // 即 FileEvent.ParentSnapshotID
+	FirstSeen  time.Time `json:"first_seen"`          // 即 FileEvent.FirstSeen，旧版本写入的记录为零值
+	Processed  time.Time `json:"processed"`           // 即 FileEvent.Processed，旧版本写入的记录为零值
+}
+
+// auditSink 把事件异步写入审计日志
+//
+// 事件先进入有界队列，由单独的goroutine编码并经 bufio 写出，按 AuditFlushInterval 与 Stop 时 flush；
+// 队列已满时丢弃记录并报告错误，保证事件处理不会被慢速的磁盘阻塞
+type auditSink struct {
+	w     *Watcher
+	queue chan AuditRecord
+	done  chan struct{}
+
+	mu      sync.RWMutex // 保护 closed/started，使 enqueue 与 close 不会并发操作通道
+	closed  bool
+	started bool  // run goroutine 已启动
+	err     error // 最后一次 flush/关闭文件的错误，由 close 返回
+
+	out     io.Writer
+	file    *os.File // AuditPath 模式下打开的文件(Writer 模式为nil)
+	buf     *bufio.Writer
+	written int64 // 当前输出已写入的字节数(用于轮转判断)
+}
+
+// newAuditSink 根据配置创建审计日志，未配置时返回nil
+func newAuditSink(w *Watcher) (*auditSink, error) {
+	cfg := &w.cfg
+	if cfg.AuditWriter == nil && cfg.AuditPath == "" {
+		return nil, nil
+	}
+	if cfg.AuditWriter != nil && cfg.AuditPath != "" {
+		return nil, fmt.Errorf("%w: AuditWriter and AuditPath are mutually exclusive", ErrInvalidConfig)
+	}
+	if cfg.AuditFlushInterval <= 0 {
+		cfg.AuditFlushInterval = defaultAuditFlushInterval
+	}
+	if cfg.AuditQueueSize <= 0 {
+		cfg.AuditQueueSize = defaultAuditQueueSize
+	}
+
+	a := &auditSink{
+		w:     w,
+		queue: make(chan AuditRecord, cfg.AuditQueueSize),
+		done:  make(chan struct{}),
+		out:   cfg.AuditWriter,
+	}
+	if cfg.AuditPath != "" {
+		if err := a.openFile(); err != nil {
+			return nil, err
+		}
+	}
+	a.buf = bufio.NewWriter(a.out)
+	return a, nil
+}
+
+// openFile 以追加方式打开 AuditPath
+func (a *auditSink) openFile() error {
+	f, err := os.OpenFile(a.w.cfg.AuditPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	a.file, a.out, a.written = f, f, fi.Size()
+	return nil
+}
+
+// enqueue 非阻塞地把记录放入队列；sink 已关闭时忽略
+func (a *auditSink) enqueue(rec AuditRecord) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.queue <- rec:
+	default:
+		a.w.counters.auditDropped.Add(1)
+		a.w.emitError(fmt.Errorf("%w: queue full, seq %d for %s", ErrAuditDropped, rec.Seq, rec.Path))
+	}
+}
+
+// run 消费队列并写出，队列关闭后 flush 并退出
+func (a *auditSink) run() {
+	defer close(a.done)
+	ticker := a.w.clock.NewTicker(a.w.cfg.AuditFlushInterval)
+	defer ticker.Stop()
+	enc := json.NewEncoder(countingWriter{a})
+
+	for {
+		select {
+		case rec, ok := <-a.queue:
+			if !ok {
+				a.err = a.finish()
+				return
+			}
+			if err := enc.Encode(rec); err != nil {
+				a.w.emitError(fmt.Errorf("failed to write audit record seq %d: %w", rec.Seq, err))
+				continue
+			}
+			a.w.counters.auditWritten.Add(1)
+			if a.w.cfg.AuditRotateSize > 0 && a.written >= a.w.cfg.AuditRotateSize {
+				a.rotate()
+			}
+		case <-ticker.C():
+			_ = a.flush()
+		}
+	}
+}
+
+// flush 把缓冲写出到底层输出
+func (a *auditSink) flush() error {
+	if err := a.buf.Flush(); err != nil {
+		err = fmt.Errorf("failed to flush audit log: %w", err)
+		a.w.emitError(err)
+		a.buf.Reset(a.out) // 丢弃写失败的缓冲，避免后续写入一直失败
+		return err
+	}
+	return nil
+}
+
+// finish 最后一次 flush 并关闭 AuditPath 打开的文件
+func (a *auditSink) finish() error {
+	err := a.flush()
+	if a.file != nil {
+		if cerr := a.file.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close audit log: %w", cerr)
+		}
+		a.file = nil
+	}
+	return err
+}
+
+// start 启动写入goroutine
+func (a *auditSink) start() {
+	a.mu.Lock()
+	a.started = true
+	a.mu.Unlock()
+	go a.run()
+}
+
+// rotate 在大小超过阈值时调用轮转回调
+//
+// AuditPath 模式下先关闭当前文件再调用回调(回调通常会重命名/压缩它)，回调返回nil时重新打开 AuditPath；
+// Writer 模式下回调返回的非nil Writer 会替换当前输出
+func (a *auditSink) rotate() {
+	_ = a.flush()
+	size := a.written
+	if a.file != nil {
+		_ = a.file.Close()
+		a.file = nil
+	}
+
+	var next io.Writer
+	if a.w.cfg.AuditOnRotate != nil {
+		var err error
+		next, err = a.w.cfg.AuditOnRotate(size)
+		if err != nil {
+			a.w.emitError(fmt.Errorf("audit log rotation failed: %w", err))
+		}
+	}
+
+	switch {
+	case next != nil:
+		a.out, a.written = next, 0
+	case a.w.cfg.AuditPath != "":
+		if err := a.openFile(); err != nil {
+			a.w.emitError(err)
+			a.out = io.Discard
+		}
+	default:
+		a.written = 0
+	}
+	a.buf.Reset(a.out)
+}
+
+// close 停止接收记录，等待队列写完并 flush，返回最后一次写出或关闭文件的错误
+//
+// 写入goroutine未启动(如 Start 失败)时直接关闭文件，队列中的记录被丢弃
+func (a *auditSink) close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		<-a.done
+		return a.err
+	}
+	a.closed = true
+	close(a.queue)
+	started := a.started
+	a.mu.Unlock()
+	if !started {
+		a.err = a.finish()
+		close(a.done)
+	}
+	<-a.done
+	return a.err
+}
+
+// countingWriter 统计写入 auditSink 缓冲的字节数
+type countingWriter struct{ a *auditSink }
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.a.buf.Write(p)
+	c.a.written += int64(n)
+	return n, err
+}
+
+// auditRecord 由事件构造审计记录
+func (w *Watcher) auditRecord(ev *FileEvent) AuditRecord {
+	rec := AuditRecord{
+		Time:       w.now(),
+		Seq:        ev.Seq,
+		Op:         ev.Kind.String(),
+		Path:       SlashPath(ev.FilePath),
+		Root:       SlashPath(ev.Root),
+		SnapshotID: ev.SnapshotID(),
+		ParentID:   ev.ParentSnapshotID,
+		FirstSeen:  ev.FirstSeen,
+		Processed:  ev.Processed,
+	}
+	if ev.NewMeta != nil {
+		rec.NewHash = ev.NewMeta.Hash
+	}
+	if ev.OldMeta != nil {
+		rec.OldHash = ev.OldMeta.Hash
+	}
+	return rec
+}
+
+// AuditReader 逐行解析审计日志
+type AuditReader struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+// NewAuditReader 创建读取 r 的 AuditReader
+func NewAuditReader(r io.Reader) *AuditReader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &AuditReader{sc: sc}
+}
+
+// Next 返回下一条记录，读完时返回 io.EOF；空行会被跳过
+func (r *AuditReader) Next() (AuditRecord, error) {
+	for r.sc.Scan() {
+		r.line++
+		if len(strings.TrimSpace(r.sc.Text())) == 0 {
+			continue
+		}
+		var rec AuditRecord
+		if err := json.Unmarshal(r.sc.Bytes(), &rec); err != nil {
+			return AuditRecord{}, fmt.Errorf("audit log line %d: %w", r.line, err)
+		}
+		return rec, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return AuditRecord{}, err
+	}
+	return AuditRecord{}, io.EOF
+}
+
+// Event 把记录还原为 FileEvent，用于回放
+//
+// NewSnap 只包含快照ID、父快照ID以及该路径的哈希(删除时不含文件)，不是完整快照；OldMeta/NewMeta 只有 Path 与 Hash
+// 记录没有快照ID(DisableSnapshots 时写入)时 NewSnap 为nil
+func (rec AuditRecord) Event() FileEvent {
+	kind := ParseEventOp(rec.Op)
+	ev := FileEvent{FilePath: rec.Path, Root: rec.Root, Kind: kind, Op: kind.fsnotifyOp(), Seq: rec.Seq, ParentSnapshotID: rec.ParentID,
+		FirstSeen: rec.FirstSeen, Processed: rec.Processed}
+	if rec.NewHash != "" {
+		ev.NewMeta = &FileMetadata{Path: rec.Path, Hash: rec.NewHash}
+	}
+	if rec.SnapshotID != "" {
+		ev.NewSnap = &SnapshotNode{
+			ID:        rec.SnapshotID,
+			Instance:  SnapshotInstance(rec.SnapshotID),
+			CreatedAt: rec.Time,
+			Files:     make(map[string]*FileMetadata),
+		}
+		if rec.ParentID != "" {
+			ev.NewSnap.ParentIDs = []string{rec.ParentID}
+		}
+		if ev.NewMeta != nil {
+			ev.NewSnap.Files[rec.Path] = ev.NewMeta
+		}
+	}
+	if rec.OldHash != "" {
+		ev.OldMeta = &FileMetadata{Path: rec.Path, Hash: rec.OldHash}
+	}
+	return ev
+}
+
+// ReadAuditLog 读取整个审计日志并还原为事件列表
+func ReadAuditLog(r io.Reader) ([]FileEvent, error) {
+	ar := NewAuditReader(r)
+	var out []FileEvent
+	for {
+		rec, err := ar.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, rec.Event())
+	}
+}
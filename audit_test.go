@@ -0,0 +1,144 @@
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestAuditLogRoundTrip 测试事件写入审计日志、关闭时 flush 并可读回
+func TestAuditLogRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "audit.ndjson")
+	file := filepath.Join(root, "a.txt")
+
+	w, err := NewWatcher(ConfigWatcher{
+		WatchPaths:         []string{root},
+		AuditPath:          logPath,
+		AuditFlushInterval: time.Hour, // 只依赖关闭时的 flush
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+	// 不启动 fsnotify，只运行审计goroutine，避免真实事件混入
+	w.audit.start()
+	_ = os.WriteFile(file, []byte("one"), 0644)
+	w.handleFileChange(file, fsnotify.Create)
+	created := w.GetCurrentSnapshot().Files[file].Hash
+	_ = os.WriteFile(file, []byte("two"), 0644)
+	w.handleFileChange(file, fsnotify.Write)
+	modified := w.GetCurrentSnapshot()
+	_ = os.Remove(file)
+	w.handleFileChange(file, fsnotify.Remove)
+	w.audit.close()
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events, err := ReadAuditLog(f)
+	if err != nil {
+		t.Fatalf("ReadAuditLog failed: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d audit events; want 3", len(events))
+	}
+	if ev := events[0]; ev.Seq != 1 || ev.Kind != OpCreate || ev.NewSnap.Files[file].Hash != created {
+		t.Errorf("unexpected create event %+v", ev)
+	}
+	if ev := events[1]; ev.Kind != OpWrite || ev.NewSnap.ID != modified.ID || ev.NewSnap.ParentIDs[0] != modified.ParentIDs[0] {
+		t.Errorf("unexpected write event %+v", ev)
+	}
+	if ev := events[2]; ev.Kind != OpRemove || ev.NewSnap.Files[file] != nil {
+		t.Errorf("unexpected remove event %+v", ev)
+	}
+
+	f.Seek(0, io.SeekStart)
+	r := NewAuditReader(f)
+	_, _ = r.Next()
+	rec, _ := r.Next()
+	if rec.OldHash != created || rec.NewHash == "" || rec.NewHash == created {
+		t.Errorf("write record hashes: old=%s new=%s", rec.OldHash, rec.NewHash)
+	}
+	if st := w.Stats(); st.AuditWritten != 3 || st.AuditDropped != 0 {
+		t.Errorf("audit stats: written=%d dropped=%d", st.AuditWritten, st.AuditDropped)
+	}
+}
+
+// TestAuditLogRotate 测试超过大小阈值时调用轮转回调并切换输出
+func TestAuditLogRotate(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+
+	var first, second bytes.Buffer
+	var rotations []int64
+	w, err := NewWatcher(ConfigWatcher{
+		WatchPaths:      []string{root},
+		AuditWriter:     &first,
+		AuditRotateSize: 1,
+		AuditOnRotate: func(size int64) (io.Writer, error) {
+			rotations = append(rotations, size)
+			return &second, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.fsWatcher.Close()
+	w.audit.start()
+	w.handleFileChange(file, fsnotify.Create)
+	w.handleFileChange(file, fsnotify.Write)
+	w.audit.close()
+
+	if len(rotations) != 2 || rotations[0] == 0 {
+		t.Errorf("rotations = %v; want 2 with non-zero sizes", rotations)
+	}
+	if n := strings.Count(first.String(), "\n"); n != 1 {
+		t.Errorf("first writer got %d lines; want 1", n)
+	}
+	if n := strings.Count(second.String(), "\n"); n != 1 {
+		t.Errorf("second writer got %d lines; want 1", n)
+	}
+}
+
+// TestAuditQueueFull 测试队列已满时丢弃记录并报告错误而不是阻塞
+func TestAuditQueueFull(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root}, AuditWriter: io.Discard, AuditQueueSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.fsWatcher.Close()
+	// 未 Start，队列不会被消费
+	w.handleFileChange(file, fsnotify.Create)
+	w.handleFileChange(file, fsnotify.Write)
+
+	if st := w.Stats(); st.AuditDropped != 1 {
+		t.Errorf("AuditDropped = %d; want 1", st.AuditDropped)
+	}
+	select {
+	case err := <-w.ErrorChan:
+		if !errors.Is(err, ErrAuditDropped) {
+			t.Errorf("unexpected error %v", err)
+		}
+	default:
+		t.Error("dropping an audit record should report an error")
+	}
+
+	if _, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root}, AuditWriter: io.Discard, AuditPath: "x"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Error("AuditWriter and AuditPath together should be rejected")
+	}
+}
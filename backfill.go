@@ -0,0 +1,234 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backfillPollInterval 是降级期间 BackfillHashes 检查是否已恢复的间隔
+const backfillPollInterval = 100 * time.Millisecond
+
+// BackfillProgress 是 BackfillHashes 的进度，见 WithBackfillProgress
+type BackfillProgress struct {
+	Total  int    // 需要补齐哈希的条目数
+	Done   int    // 已处理的条目数(含失败与跳过的)
+	Filled int    // 已补齐哈希的条目数
+	Bytes  int64  // 已读取的字节数
+	Path   string // 最近处理的路径
+}
+
+type backfillProgressKey struct{}
+
+// WithBackfillProgress 返回带有进度回调的 ctx，传给 BackfillHashes 后最多每 500ms 回调一次 fn，结束时再回调一次
+//
+// fn 在 BackfillHashes 的goroutine中调用，不应阻塞
+func WithBackfillProgress(ctx context.Context, fn func(BackfillProgress)) context.Context {
+	return context.WithValue(ctx, backfillProgressKey{}, fn)
+}
+
+// BackfillHashes 为当前快照中没有内容哈希(HashState 不是 Hashed)的文件补齐哈希，返回补齐的文件数
+//
+// filter 为nil时处理全部这类文件，否则只处理 filter 返回 true 的条目。补齐时不受 MaxHashSize、NoHashPatterns 限制，
+// 但与事件处理共用 worker 池(WorkerCount)，配置了 StormMaxHashBytesPerSec 时读取速率不超过该值，降级期间暂停。
+// 文件的大小或修改时间已与记录不同时跳过(留给正常的事件处理)；读取失败的文件以 *HashError 发送到 ErrorChan 并保持原状。
+// 全部完成后提交一个快照(未补齐的条目与原快照共享)，不发送事件；提交时路径已被其它变更更新的结果会被丢弃。
+// ctx 取消时停止读取新文件，已补齐的结果照常提交，并返回 ctx.Err()；进度回调见 WithBackfillProgress
+// Close 时同样停止读取新文件并提交已补齐的结果，返回 ErrStopped；Close 之后调用直接返回 ErrStopped。DisableCurrentState 时返回错误
+// 并发安全
+func (w *Watcher) BackfillHashes(ctx context.Context, filter func(*FileMetadata) bool) (int, error) {
+	if w.cfg.DisableCurrentState {
+		return 0, fmt.Errorf("backfill hashes: %w", ErrInvalidConfig)
+	}
+	if err := w.beginMutation(); err != nil {
+		return 0, err
+	}
+	defer w.endMutation()
+	// Close 时取消，停止读取新文件(包括等待降级结束与限速)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	var todo []*FileMetadata
+	w.readCurrent(func(files map[string]*FileMetadata) {
+		for _, m := range files {
+			if !m.IsDirectory && !m.Deleted && m.HashState != HashStateHashed && (filter == nil || filter(m)) {
+				todo = append(todo, m)
+			}
+		}
+	})
+	sort.Slice(todo, func(i, j int) bool { return todo[i].Path < todo[j].Path })
+
+	b := &backfill{w: w, prog: BackfillProgress{Total: len(todo)}, filled: make(map[string]*FileMetadata)}
+	if fn, ok := ctx.Value(backfillProgressKey{}).(func(BackfillProgress)); ok {
+		b.report = fn
+	}
+	var wg sync.WaitGroup
+	err := func() error {
+		for _, m := range todo {
+			if err := b.wait(ctx, m.Size); err != nil {
+				return err
+			}
+			select {
+			case w.workerPool <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			wg.Add(1)
+			go func(m *FileMetadata) {
+				defer func() { <-w.workerPool; wg.Done() }()
+				b.fill(m)
+			}(m)
+		}
+		return nil
+	}()
+	wg.Wait()
+	b.progress(true)
+	if err != nil && w.stopped() {
+		err = ErrStopped
+	}
+	return b.commit(), err
+}
+
+// backfill 是一次 BackfillHashes 的状态
+type backfill struct {
+	w      *Watcher
+	report func(BackfillProgress)
+
+	mu         sync.Mutex
+	prog       BackfillProgress
+	lastReport time.Time
+	filled     map[string]*FileMetadata // 路径 -> 补齐哈希后的条目
+	orig       map[string]*FileMetadata // 路径 -> 补齐前的条目，提交时用于判断是否已被其它变更更新
+	nextRead   time.Time                // 限速：下一次读取最早的开始时间
+}
+
+// wait 在降级期间等待恢复，并按 StormMaxHashBytesPerSec 为即将读取的 size 字节限速
+func (b *backfill) wait(ctx context.Context, size int64) error {
+	w := b.w
+	for w.storm.degraded.Load() {
+		if err := sleepCtx(ctx, backfillPollInterval); err != nil {
+			return err
+		}
+	}
+	rate := w.cfg.StormMaxHashBytesPerSec
+	if rate <= 0 {
+		return ctx.Err()
+	}
+	now := time.Now()
+	b.mu.Lock()
+	start := b.nextRead
+	if start.Before(now) {
+		start = now
+	}
+	b.nextRead = start.Add(time.Duration(float64(size) / float64(rate) * float64(time.Second)))
+	b.mu.Unlock()
+	return sleepCtx(ctx, start.Sub(now))
+}
+
+// sleepCtx 等待 d 或 ctx 取消
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fill 计算单个条目的哈希，文件已变化或读取失败时不记录结果
+func (b *backfill) fill(m *FileMetadata) {
+	w := b.w
+	var filled *FileMetadata
+	if fi, err := w.fs.Stat(m.Path); err == nil && fi.Mode().IsRegular() && fi.Size() == m.Size && fi.ModTime().Equal(m.ModTime) {
+		buf := w.bufs.get()
+		start := time.Now()
+		sum, err := hashFile(w.fs, m.Path, w.newHash, *buf)
+		w.bufs.put(buf)
+		if err != nil {
+			w.counters.hashErrors.Add(1)
+			w.emitError(&HashError{Path: m.Path, Err: err})
+		} else {
+			w.counters.hashLatency.observe(time.Since(start))
+			w.counters.hashOps.Add(1)
+			w.counters.bytesHashed.Add(uint64(m.Size))
+			cp := *m
+			cp.Hash, cp.HashState = sum, HashStateHashed
+			filled = &cp
+		}
+	}
+
+	b.mu.Lock()
+	b.prog.Done++
+	b.prog.Path = m.Path
+	if filled != nil {
+		if b.orig == nil {
+			b.orig = make(map[string]*FileMetadata)
+		}
+		b.filled[m.Path], b.orig[m.Path] = filled, m
+		b.prog.Filled++
+		b.prog.Bytes += m.Size
+	}
+	b.mu.Unlock()
+	b.progress(false)
+}
+
+// progress 按节流间隔(final 时总是)调用进度回调
+func (b *backfill) progress(final bool) {
+	if b.report == nil {
+		return
+	}
+	b.mu.Lock()
+	now := time.Now()
+	if !final && now.Sub(b.lastReport) < scanProgressInterval {
+		b.mu.Unlock()
+		return
+	}
+	b.lastReport = now
+	p := b.prog
+	b.mu.Unlock()
+	b.report(p)
+}
+
+// commit 丢弃已被其它变更更新的结果，把其余结果提交为一个快照，返回提交的条目数
+func (b *backfill) commit() int {
+	w := b.w
+	n, c := b.commitLocked()
+	w.describeCommitted(&c)
+	// 启用 MinSnapshotInterval 时提交可能顺带发布了待发布快照，发送其延后的事件
+	w.emitEvents(c.ready)
+	return n
+}
+
+// commitLocked 是 commit 在 w.mu 内的部分
+func (b *backfill) commitLocked() (int, commitResult) {
+	w := b.w
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cur := w.head.Load().Files
+	if w.throttle.snap != nil {
+		cur = w.throttle.snap.Files
+	}
+	for p := range b.filled {
+		if cur[p] != b.orig[p] {
+			delete(b.filled, p)
+		}
+	}
+	if len(b.filled) == 0 {
+		return 0, commitResult{}
+	}
+	c := w.commitLocked(fmt.Sprintf("Backfill hashes (%d files)", len(b.filled)), b.filled, "")
+	return len(b.filled), c
+}
@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestBackfillHashes 测试补齐跳过哈希的条目：一次提交、未处理的条目共享、过滤、进度回调与取消
+func TestBackfillHashes(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithNoHashPatterns("*.mp4"), WithMaxHashSize(8))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	write := func(name, content string) string {
+		p := filepath.Join(root, name)
+		_ = os.WriteFile(p, []byte(content), 0644)
+		w.handleFileChange(p, fsnotify.Create)
+		return p
+	}
+	video := write("a.mp4", "frames")
+	big := write("big.dat", strings.Repeat("x", 100))
+	other := write("skip.mp4", "later")
+	small := write("small.txt", "ok")
+	before := w.GetCurrentSnapshot()
+
+	// 已取消的 ctx：不读取任何文件，也不提交
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if n, err := w.BackfillHashes(ctx, nil); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled BackfillHashes = %d, %v", n, err)
+	}
+	if w.GetCurrentSnapshot() != before {
+		t.Fatal("canceled backfill committed a snapshot")
+	}
+
+	var last BackfillProgress
+	ctx = WithBackfillProgress(context.Background(), func(p BackfillProgress) { last = p })
+	n, err := w.BackfillHashes(ctx, func(m *FileMetadata) bool { return m.Path != other })
+	if err != nil || n != 2 {
+		t.Fatalf("BackfillHashes = %d, %v; want 2", n, err)
+	}
+	if last.Total != 2 || last.Done != 2 || last.Filled != 2 || last.Bytes != 106 {
+		t.Errorf("final progress = %+v", last)
+	}
+
+	after := w.GetCurrentSnapshot()
+	if len(after.ParentIDs) != 1 || after.ParentIDs[0] != before.ID {
+		t.Fatalf("backfill snapshot parents = %v; want [%s]", after.ParentIDs, before.ID)
+	}
+	for _, p := range []string{video, big} {
+		m := after.Files[p]
+		want, _ := hashFile(osFS{}, p, w.newHash, make([]byte, 64))
+		if m.HashState != HashStateHashed || m.Hash != want {
+			t.Errorf("%s: state=%v hash=%q; want hashed %q", p, m.HashState, m.Hash, want)
+		}
+	}
+	if after.Files[other] != before.Files[other] || after.Files[small] != before.Files[small] {
+		t.Error("entries not backfilled should be shared with the parent snapshot")
+	}
+	if d, _ := w.DiffSnapshots(before.ID, after.ID); !d.Empty() {
+		t.Errorf("backfill changed content: %+v", d)
+	}
+}
@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ManifestEntry 是清单中的一个文件：部署流程已知的路径、大小与内容哈希
+type ManifestEntry struct {
+	Path    string    // 绝对路径
+	Size    int64     // 文件大小(字节)
+	Hash    string    // 内容哈希(hex)，须与 Watcher 使用的哈希算法(默认 SHA-256)一致，为空表示未知
+	ModTime time.Time // 修改时间(可为零值)
+}
+
+// BaselineFromManifest 把清单转换为可用作 ConfigWatcher.Baseline 的快照
+//
+// 只填充 Files；上级目录、目录哈希等在 NewWatcher 时补齐，条目的合法性也在那时校验
+func BaselineFromManifest(entries []ManifestEntry) *SnapshotNode {
+	sn := &SnapshotNode{Files: make(map[string]*FileMetadata, len(entries))}
+	for _, e := range entries {
+		m := &FileMetadata{Path: e.Path, Size: e.Size, Hash: e.Hash, ModTime: e.ModTime, LastModified: e.ModTime}
+		if e.Hash != "" {
+			m.HashState = HashStateHashed
+		}
+		sn.Files[e.Path] = m
+	}
+	return sn
+}
+
+// baselineChanges 校验 ConfigWatcher.Baseline 并返回要写入初始快照的条目
+//
+// 路径必须是规范的绝对路径(分隔符可以是 '/' 或本平台的形式，统一转换为快照中的形式)，元信息不能为nil，
+// 大小不能为负，墓碑(Deleted)被忽略；监控根之外的路径默认报错，BaselineKeepOutsideRoots 时保留。监控根之下缺失的上级目录以只有路径的目录条目补齐，
+// 条目均为副本，不修改调用方的快照
+func (w *Watcher) baselineChanges(base *SnapshotNode) (map[string]*FileMetadata, error) {
+	changes := make(map[string]*FileMetadata, len(base.Files))
+	for p, m := range base.Files {
+		switch {
+		case p == "":
+			return nil, fmt.Errorf("%w: baseline: empty path", ErrInvalidConfig)
+		case !filepath.IsAbs(p) || filepath.ToSlash(filepath.Clean(p)) != filepath.ToSlash(p):
+			return nil, fmt.Errorf("%w: baseline: path %q is not a clean absolute path", ErrInvalidConfig, p)
+		case m == nil:
+			return nil, fmt.Errorf("%w: baseline: nil metadata for %q", ErrInvalidConfig, p)
+		case m.Deleted:
+			continue
+		case m.Size < 0:
+			return nil, fmt.Errorf("%w: baseline: negative size %d for %q", ErrInvalidConfig, m.Size, p)
+		case w.rootOf(w.keyOf(p)) == "" && !w.cfg.BaselineKeepOutsideRoots:
+			return nil, fmt.Errorf("%w: baseline: path %q is outside the watch roots", ErrInvalidConfig, p)
+		}
+		c := *m
+		c.Path = w.keyOf(p)
+		c.rewritten = false
+		changes[c.Path] = &c
+	}
+	for p, m := range base.Files {
+		if m.Deleted {
+			continue
+		}
+		p = w.keyOf(p)
+		root := w.rootOf(p)
+		if root == "" || p == root {
+			continue
+		}
+		for dir := dirOf(p); ; dir = dirOf(dir) {
+			if _, ok := changes[dir]; ok {
+				break
+			}
+			changes[dir] = &FileMetadata{Path: dir, IsDirectory: true}
+			if dir == root || dir == dirOf(dir) {
+				break
+			}
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,87 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestBaselineFromManifest 测试以清单作为初始快照：上级目录被补齐，第一次变更的 OldMeta 即清单记录
+func TestBaselineFromManifest(t *testing.T) {
+	root, _ := filepath.EvalSymlinks(t.TempDir())
+	a := filepath.Join(root, "a.txt")
+	c := filepath.Join(root, "sub", "c.txt")
+	_ = os.MkdirAll(filepath.Dir(c), 0755)
+	_ = os.WriteFile(a, []byte("shipped"), 0644)
+	_ = os.WriteFile(c, []byte("changed"), 0644)
+	sum := sha256.Sum256([]byte("shipped"))
+	manifest := []ManifestEntry{
+		{Path: a, Size: 7, Hash: hex.EncodeToString(sum[:])},
+		{Path: c, Size: 7, Hash: "deadbeef"},
+	}
+
+	w, err := NewWatcherWithOptions([]string{root}, WithBaseline(BaselineFromManifest(manifest), false), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	base := w.GetCurrentSnapshot()
+	for _, p := range []string{root, filepath.Dir(c), a, c} {
+		if base.Files[p] == nil {
+			t.Fatalf("baseline missing %s", p)
+		}
+	}
+	if !base.Files[root].IsDirectory || base.Files[root].Hash == "" || base.RootHash == "" {
+		t.Errorf("directory hashes not computed: %+v root hash %q", base.Files[root], base.RootHash)
+	}
+	if len(base.Files) != 4 || base.Description != "Provided baseline (4 entries)" {
+		t.Errorf("baseline = %d files, %q", len(base.Files), base.Description)
+	}
+
+	sub := w.Subscribe(4)
+	defer sub.Close()
+	w.handleFileChange(c, fsnotify.Write)
+	ev := <-sub.C
+	if ev.Kind != OpWrite || ev.OldMeta == nil || ev.OldMeta.Hash != "deadbeef" {
+		t.Fatalf("first event = %+v (old %+v); want write with manifest OldMeta", ev, ev.OldMeta)
+	}
+	d, err := w.DiffSnapshots(base.ID, w.GetCurrentSnapshot().ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Modified) != 1 || d.Modified[0].Path != c || len(d.Added)+len(d.Removed) != 0 {
+		t.Errorf("diff against baseline = %+v", d)
+	}
+}
+
+// TestBaselineValidation 测试明显非法的条目与监控根之外的路径
+func TestBaselineValidation(t *testing.T) {
+	root, _ := filepath.EvalSymlinks(t.TempDir())
+	outside := filepath.Join(filepath.Dir(root), "elsewhere.txt")
+	for name, entries := range map[string][]ManifestEntry{
+		"empty path":    {{Path: ""}},
+		"relative path": {{Path: "a.txt"}},
+		"unclean path":  {{Path: root + "/x/../a.txt"}},
+		"negative size": {{Path: filepath.Join(root, "a.txt"), Size: -1}},
+		"outside roots": {{Path: outside}},
+	} {
+		_, err := NewWatcherWithOptions([]string{root}, WithBaseline(BaselineFromManifest(entries), false))
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: err = %v; want ErrInvalidConfig", name, err)
+		}
+	}
+
+	w, err := NewWatcherWithOptions([]string{root}, WithBaseline(BaselineFromManifest([]ManifestEntry{{Path: outside, Size: 1}}), true))
+	if err != nil {
+		t.Fatalf("keepOutsideRoots: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	if files := w.GetCurrentSnapshot().Files; len(files) != 1 || files[outside] == nil {
+		t.Errorf("outside path not kept: %v", files)
+	}
+}
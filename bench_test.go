@@ -0,0 +1,191 @@
+package watcher_test
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchertest"
+)
+
+// 端到端基准：在真实临时目录上运行 watchertest 的合成负载，比较 Debounce、WorkerCount 等配置
+//
+//	go test -run '^$' -bench Workload -benchtime 3x
+//
+// 通过 b.ReportMetric 报告的指标(每次迭代一份负载的平均值)：
+//   - p50-ms / p99-ms：从 write() 返回到收到该路径 FileEvent 的延迟(同一路径多次写入以最早未确认的一次计)
+//   - snaps/s：每秒创建的快照数
+//   - hashed-B/op：计算哈希读取的字节数
+//   - paths/batch：每个非空 flush 批次平均包含的路径数
+//   - peak-heap-MB：运行期间采样到的最大堆内存
+//   - missed/op：写入后始终没有收到事件的路径数(如新目录注册监控之前写入的文件)
+func BenchmarkWorkload(b *testing.B) {
+	workloads := []watchertest.Workload{
+		watchertest.SteadyWrites(200, 4<<10, 500, 500*time.Millisecond),
+		watchertest.CheckoutStorm(20, 50, 8<<10, 3, 50*time.Millisecond),
+		watchertest.DeepTree(3, 4, 5, 1<<10),
+	}
+	type knobs struct {
+		debounce time.Duration
+		workers  int
+	}
+	for _, wl := range workloads {
+		for _, k := range []knobs{{10 * time.Millisecond, 32}, {50 * time.Millisecond, 32}, {10 * time.Millisecond, 4}} {
+			name := fmt.Sprintf("%s/debounce=%v/workers=%d", wl.Name, k.debounce, k.workers)
+			b.Run(name, func(b *testing.B) {
+				runWorkload(b, wl, watcher.WithDebounce(k.debounce), watcher.WithWorkerCount(k.workers))
+			})
+		}
+	}
+}
+
+// workloadResult 是一次负载运行的测量结果
+type workloadResult struct {
+	latencies []time.Duration
+	missed    int // 写入后始终没有收到事件的路径数
+	elapsed   time.Duration
+	stats     watcher.WatcherStats
+	peakHeap  uint64
+}
+
+// runWorkload 运行 b.N 次负载，每次使用新的目录与 Watcher，并报告汇总指标
+func runWorkload(b *testing.B, wl watchertest.Workload, opts ...watcher.Option) {
+	b.Helper()
+	var all workloadResult
+	for i := 0; i < b.N; i++ {
+		r := runWorkloadOnce(b, wl, opts...)
+		all.latencies = append(all.latencies, r.latencies...)
+		all.missed += r.missed
+		all.elapsed += r.elapsed
+		all.stats.SnapshotsCreated += r.stats.SnapshotsCreated
+		all.stats.BytesHashed += r.stats.BytesHashed
+		all.stats.EventsAggregated += r.stats.EventsAggregated - r.stats.EventsCoalesced
+		all.stats.BatchesProcessed += r.stats.BatchesProcessed
+		all.peakHeap = max(all.peakHeap, r.peakHeap)
+	}
+
+	sort.Slice(all.latencies, func(i, j int) bool { return all.latencies[i] < all.latencies[j] })
+	b.ReportMetric(percentileMillis(all.latencies, 0.50), "p50-ms")
+	b.ReportMetric(percentileMillis(all.latencies, 0.99), "p99-ms")
+	b.ReportMetric(float64(all.stats.SnapshotsCreated)/all.elapsed.Seconds(), "snaps/s")
+	b.ReportMetric(float64(all.stats.BytesHashed)/float64(b.N), "hashed-B/op")
+	if all.stats.BatchesProcessed > 0 {
+		b.ReportMetric(float64(all.stats.EventsAggregated)/float64(all.stats.BatchesProcessed), "paths/batch")
+	}
+	b.ReportMetric(float64(all.peakHeap)/(1<<20), "peak-heap-MB")
+	b.ReportMetric(float64(all.missed)/float64(b.N), "missed/op")
+}
+
+// runWorkloadOnce 在新目录上启动 Watcher 并运行一次负载，等待全部写入都有对应事件后返回
+func runWorkloadOnce(b *testing.B, wl watchertest.Workload, opts ...watcher.Option) workloadResult {
+	b.Helper()
+	b.StopTimer()
+	dir, err := os.MkdirTemp(b.TempDir(), "wl")
+	if err != nil {
+		b.Fatal(err)
+	}
+	w, err := watcher.NewWatcherWithOptions([]string{dir}, append([]watcher.Option{watcher.WithDisableEventChan()}, opts...)...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer w.Stop()
+	sub := w.Subscribe(1 << 20)
+	if err := w.Start(); err != nil {
+		b.Fatal(err)
+	}
+
+	// pending：已写入但尚未收到事件的路径 -> 最早一次未确认写入的时间
+	var mu sync.Mutex
+	pending := make(map[string]time.Time)
+	var latencies []time.Duration
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for ev := range sub.C {
+			now := time.Now()
+			mu.Lock()
+			if at, ok := pending[ev.FilePath]; ok {
+				latencies = append(latencies, now.Sub(at))
+				delete(pending, ev.FilePath)
+			}
+			mu.Unlock()
+		}
+	}()
+
+	stopSampler := make(chan struct{})
+	peak := make(chan uint64, 1)
+	go func() {
+		var ms runtime.MemStats
+		var p uint64
+		t := time.NewTicker(10 * time.Millisecond)
+		defer t.Stop()
+		for {
+			runtime.ReadMemStats(&ms)
+			p = max(p, ms.HeapAlloc)
+			select {
+			case <-t.C:
+			case <-stopSampler:
+				peak <- p
+				return
+			}
+		}
+	}()
+
+	b.StartTimer()
+	start := time.Now()
+	err = wl.Run(watchertest.OSWriter(), dir, func(path string, _ int) {
+		now := time.Now()
+		mu.Lock()
+		if _, ok := pending[path]; !ok {
+			pending[path] = now
+		}
+		mu.Unlock()
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	// 等待全部写入都收到事件；连续 missedAfter 没有进展时，剩下的计为丢失
+	// (如新目录的监控注册之前就写入的文件)
+	const missedAfter = time.Second
+	var missed int
+	last, lastChange := -1, time.Now()
+	for {
+		mu.Lock()
+		n := len(pending)
+		mu.Unlock()
+		if n != last {
+			last, lastChange = n, time.Now()
+		}
+		if n == 0 || time.Since(lastChange) > missedAfter {
+			missed = n
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	end := time.Now()
+	if missed > 0 {
+		end = lastChange
+	}
+	elapsed := end.Sub(start)
+	b.StopTimer()
+
+	close(stopSampler)
+	st := w.Stats()
+	w.Stop()
+	<-drained
+	return workloadResult{latencies: latencies, missed: missed, elapsed: elapsed, stats: st, peakHeap: <-peak}
+}
+
+// percentileMillis 返回已排序延迟的 q 分位数(毫秒)
+func percentileMillis(sorted []time.Duration, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(q * float64(len(sorted)-1))
+	return float64(sorted[i]) / float64(time.Millisecond)
+}
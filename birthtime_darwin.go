@@ -0,0 +1,18 @@
+//go:build darwin
+
+package watcher
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// birthTime 从 stat 结果的 Birthtimespec 获取文件创建时间
+func birthTime(_ string, fi os.FileInfo) time.Time {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(st.Birthtimespec.Sec, st.Birthtimespec.Nsec)
+}
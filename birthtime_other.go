@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package watcher
+
+import (
+	"os"
+	"time"
+)
+
+// birthTime 在不支持创建时间的平台上始终返回零值
+func birthTime(_ string, _ os.FileInfo) time.Time {
+	return time.Time{}
+}
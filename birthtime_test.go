@@ -0,0 +1,29 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestBirthTimeBestEffort 测试创建时间：平台不支持时为零值，支持时应不晚于当前时间
+func TestBirthTimeBestEffort(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "born.txt")
+	_ = os.WriteFile(file, []byte("x"), 0644)
+
+	w := newTestWatcher(t, filepath.Dir(file))
+	meta, _, err := w.RehashFile(file)
+	if err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	if meta.BirthTime.IsZero() {
+		t.Skip("birth time not supported on this platform/filesystem")
+	}
+	if meta.BirthTime.After(time.Now().Add(time.Second)) {
+		t.Errorf("birth time %v is in the future", meta.BirthTime)
+	}
+	if got := w.GetCurrentSnapshot().Files[file].BirthTime; !got.Equal(meta.BirthTime) {
+		t.Errorf("snapshot should carry birth time, got %v", got)
+	}
+}
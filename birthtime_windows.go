@@ -0,0 +1,18 @@
+//go:build windows
+
+package watcher
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// birthTime 从 Win32 文件属性的 CreationTime 获取文件创建时间
+func birthTime(_ string, fi os.FileInfo) time.Time {
+	attr, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(0, attr.CreationTime.Nanoseconds())
+}
@@ -0,0 +1,97 @@
+package watcher
+
+import (
+	"sort"
+)
+
+// DefaultMaxChangedPaths 是 ConfigWatcher.MaxChangedPaths 的默认值
+const DefaultMaxChangedPaths = 1000
+
+// changedPathSet 收集一个快照的变更路径，超过上限后不再记录，只标记截断
+type changedPathSet struct {
+	paths     map[string]struct{}
+	truncated bool
+}
+
+// add 记录 changes 中的路径
+func (s *changedPathSet) add(changes map[string]*FileMetadata, limit int) {
+	if s.paths == nil {
+		s.paths = make(map[string]struct{}, min(len(changes), limit))
+	}
+	for p := range changes {
+		if _, ok := s.paths[p]; ok {
+			continue
+		}
+		if len(s.paths) >= limit {
+			s.truncated = true
+			return
+		}
+		s.paths[p] = struct{}{}
+	}
+}
+
+// apply 把收集到的路径(有序)写入 sn.ChangedPaths 与 sn.Truncated
+func (s *changedPathSet) apply(sn *SnapshotNode) {
+	sn.ChangedPaths = make([]string, 0, len(s.paths))
+	for p := range s.paths {
+		sn.ChangedPaths = append(sn.ChangedPaths, p)
+	}
+	sort.Strings(sn.ChangedPaths)
+	sn.Truncated = s.truncated
+}
+
+// setChangedPaths 把 changes 的路径记录到 sn 上，超过 limit 时只保留排序后的前 limit 个
+func setChangedPaths(sn *SnapshotNode, changes map[string]*FileMetadata, limit int) {
+	paths := make([]string, 0, len(changes))
+	for p := range changes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if len(paths) > limit {
+		paths = paths[:limit:limit]
+		sn.Truncated = true
+	}
+	sn.ChangedPaths = paths
+}
+
+// changedTops 返回比较的起点：只比较 to.ChangedPaths 中的路径及其子树，得到与完整比较相同的结果
+//
+// 仅适用于 to 是 from 的唯一父快照的直接子快照、且 ChangedPaths 完整(未截断)的情况，见 canDiffChanged
+func changedTops(from, to *SnapshotNode) (a, b []string) {
+	for _, p := range topmostPaths(to.ChangedPaths) {
+		if live(from.Files[p]) != nil {
+			a = append(a, p)
+		}
+		if live(to.Files[p]) != nil {
+			b = append(b, p)
+		}
+	}
+	return a, b
+}
+
+// canDiffChanged 报告 from→to 能否使用 changedTops
+func canDiffChanged(from, to *SnapshotNode) bool {
+	return to.ChangedPaths != nil && !to.Truncated && len(to.ParentIDs) == 1 && to.ParentIDs[0] == from.ID
+}
+
+// topmostPaths 去掉有序路径中祖先也在其中的路径，避免子树被重复比较
+func topmostPaths(paths []string) []string {
+	set := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		set[p] = struct{}{}
+	}
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		covered := false
+		for cur, dir := p, dirOf(p); dir != cur; cur, dir = dir, dirOf(dir) {
+			if _, ok := set[dir]; ok {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			out = append(out, p)
+		}
+	}
+	return out
+}
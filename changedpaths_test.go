@@ -0,0 +1,112 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestChangedPaths 测试快照记录触发它的路径，超过上限时截断
+func TestChangedPaths(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+
+	w, err := NewWatcherWithOptions([]string{root}, WithMaxChangedPaths(2))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	if got := w.GetCurrentSnapshot().ChangedPaths; got != nil {
+		t.Errorf("initial snapshot ChangedPaths = %v; want nil", got)
+	}
+
+	// 补齐的上级目录(此处为监控根)也是快照的新增条目
+	w.handleFileChange(file, fsnotify.Create)
+	if sn := w.GetCurrentSnapshot(); !slices.Equal(sn.ChangedPaths, []string{root, file}) || sn.Truncated {
+		t.Errorf("ChangedPaths = %v truncated=%v; want [%s %s]", sn.ChangedPaths, sn.Truncated, root, file)
+	}
+	_ = os.WriteFile(file, []byte("b"), 0644)
+	w.handleFileChange(file, fsnotify.Write)
+	if sn := w.GetCurrentSnapshot(); !slices.Equal(sn.ChangedPaths, []string{file}) {
+		t.Errorf("ChangedPaths = %v; want [%s]", sn.ChangedPaths, file)
+	}
+
+	changes := map[string]*FileMetadata{
+		filepath.Join(root, "c"): {Path: filepath.Join(root, "c")},
+		filepath.Join(root, "b"): {Path: filepath.Join(root, "b")},
+		filepath.Join(root, "d"): {Path: filepath.Join(root, "d")},
+	}
+	sn := w.commitSnapshot("bulk", changes, "").snap
+	if want := []string{filepath.Join(root, "b"), filepath.Join(root, "c")}; !slices.Equal(sn.ChangedPaths, want) || !sn.Truncated {
+		t.Errorf("ChangedPaths = %v truncated=%v; want %v truncated", sn.ChangedPaths, sn.Truncated, want)
+	}
+	if sum := sn.Summary(); !sum.Truncated || len(sum.ChangedPaths) != 2 {
+		t.Errorf("Summary = %+v", sum)
+	}
+}
+
+// TestDiffChangedPathsFastPath 测试父子快照按 ChangedPaths 比较的结果与完整比较一致
+func TestDiffChangedPathsFastPath(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dir")
+	_ = os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	for _, p := range []string{"dir/a.txt", "dir/sub/b.txt", "c.txt"} {
+		_ = os.WriteFile(filepath.Join(root, p), []byte(p), 0644)
+	}
+	w := newTestWatcher(t, root)
+
+	steps := []func(){
+		func() { w.handleFileChange(dir, fsnotify.Create) },
+		func() {
+			_ = os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed"), 0644)
+			w.handleFileChange(filepath.Join(dir, "a.txt"), fsnotify.Write)
+		},
+		func() { w.handleFileChange(filepath.Join(root, "c.txt"), fsnotify.Create) },
+		func() {
+			_ = os.RemoveAll(dir)
+			w.handleFileChange(dir, fsnotify.Remove)
+		},
+	}
+	for i, step := range steps {
+		from := w.GetCurrentSnapshot()
+		step()
+		to := w.GetCurrentSnapshot()
+		if !canDiffChanged(from, to) {
+			t.Fatalf("step %d: fast path not applicable (ChangedPaths %v)", i, to.ChangedPaths)
+		}
+		fast := DiffNodes(from, to)
+		full := DiffNodes(from, &SnapshotNode{ID: to.ID, Files: to.Files})
+		if fast.Empty() || !sameDiff(fast, full) {
+			t.Errorf("step %d: fast diff %+v; want %+v", i, fast, full)
+		}
+	}
+}
+
+// sameDiff 按路径比较两个差异的内容(不比较顺序)
+func sameDiff(a, b *SnapshotDiff) bool {
+	paths := func(es []DiffEntry) []string {
+		out := make([]string, len(es))
+		for i, e := range es {
+			out[i] = e.Path
+		}
+		slices.Sort(out)
+		return out
+	}
+	return reflect.DeepEqual(paths(a.Added), paths(b.Added)) &&
+		reflect.DeepEqual(paths(a.Removed), paths(b.Removed)) &&
+		reflect.DeepEqual(paths(a.Modified), paths(b.Modified))
+}
+
+// TestTopmostPaths 测试去掉祖先已在列表中的路径
+func TestTopmostPaths(t *testing.T) {
+	in := []string{"/r/a", "/r/a b", "/r/a/x", "/r/a/x/y", "/r/b/z"}
+	want := []string{"/r/a", "/r/a b", "/r/b/z"}
+	if got := topmostPaths(in); !slices.Equal(got, want) {
+		t.Errorf("topmostPaths = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,211 @@
+package watcher
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 变更集
+//
+// 变更集是一个快照相对其第一个父快照(ParentIDs[0])的索引操作列表：新增或修改的路径为 UPSERT(附新的元信息)，
+// 删除的路径为 DELETE，按路径排序，同一路径类型改变(文件↔目录)时先 DELETE 后 UPSERT。按顺序把全部操作应用到
+// 父快照对应的索引上即得到该快照对应的索引，适合搜索索引、数据库等在一个事务中整体应用。
+// 比较规则同 DiffSnapshots(CompareFull，墓碑视为不存在)，ChangedPaths 完整时只需检查其中的路径。
+//
+// 合并快照(有多个父快照)的变更集同样相对第一个父快照计算，其中来自其他父快照的变化(该路径在某个其他父快照中
+// 已与合并结果相同)以 MergedFrom 标出该父快照
+
+const (
+	ChangeUpsert = "UPSERT" // 新增或修改，ChangeOp.Meta 为新的元信息
+	ChangeDelete = "DELETE" // 删除
+)
+
+// ChangeOp 是变更集中的单个索引操作
+type ChangeOp struct {
+	Op   string        `json:"op"` // ChangeUpsert/ChangeDelete
+	Path string        `json:"path"`
+	Meta *FileMetadata `json:"meta,omitempty"` // UPSERT 时的元信息
+
+	// MergedFrom 非空表示该变化来自合并的其他父快照(其ID)，只出现在合并快照的变更集中
+	MergedFrom string `json:"merged_from,omitempty"`
+}
+
+// SnapshotChangeSet 是 SubscribeChangeSets 投递的一个快照的变更集，可直接序列化为 JSON
+type SnapshotChangeSet struct {
+	SnapshotID string     `json:"snapshot_id"`
+	ParentID   string     `json:"parent_id,omitempty"` // 变更集相对的父快照，初始快照为空
+	CreatedAt  time.Time  `json:"created_at"`
+	Ops        []ChangeOp `json:"ops"`
+}
+
+// ChangeSet 返回快照相对其第一个父快照的变更集，没有父快照的快照(初始快照)相对空树计算，即每个条目一个 UPSERT
+//
+// 快照之后因 MaxSnapshots 被合并掉父快照时，变更集相对合并后的父快照计算；已换出到 Store 的快照会被读回，
+// 快照或其父快照不存在时返回的错误同 DiffSnapshots
+// 并发安全
+func (w *Watcher) ChangeSet(snapshotID string) ([]ChangeOp, error) {
+	sn, err := w.loadSnapshot(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return w.changeSetOf(sn)
+}
+
+// changeSetOf 计算 sn 的变更集
+func (w *Watcher) changeSetOf(sn *SnapshotNode) ([]ChangeOp, error) {
+	parent := &SnapshotNode{Files: map[string]*FileMetadata{}}
+	var others []*SnapshotNode
+	for i, pid := range sn.ParentIDs {
+		p, err := w.loadSnapshot(pid)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			parent = p
+		} else {
+			others = append(others, p)
+		}
+	}
+	return changeOps(parent, sn, others), nil
+}
+
+// changeOps 返回 parent → sn 的索引操作，others 为合并的其他父快照
+func changeOps(parent, sn *SnapshotNode, others []*SnapshotNode) []ChangeOp {
+	var ops []ChangeOp
+	_ = walkDiff(parent, sn, func(e DiffEntry) error {
+		op := ChangeOp{Op: ChangeUpsert, Path: e.Path, Meta: e.New}
+		if e.Kind == DiffRemoved {
+			op = ChangeOp{Op: ChangeDelete, Path: e.Path}
+		}
+		for _, o := range others {
+			if sameState(live(o.Files[e.Path]), op.Meta) {
+				op.MergedFrom = o.ID
+				break
+			}
+		}
+		ops = append(ops, op)
+		return nil
+	})
+	// walkDiff 按目录层级给出，同一路径的 DELETE 总在 UPSERT 之前，稳定排序保留这一顺序
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Path < ops[j].Path })
+	return ops
+}
+
+// sameState 判断路径在两个快照中的状态是否相同：都不存在，或类型相同且文件内容相同(目录只比较类型)
+func sameState(a, b *FileMetadata) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.IsDirectory != b.IsDirectory {
+		return false
+	}
+	return a.IsDirectory || sameContent(a, b)
+}
+
+// ChangeSetSubscription 是按快照投递变更集的订阅，见 SubscribeChangeSets
+//
+// 消费过慢导致缓冲已满时，新的变更集对该订阅直接丢弃(计入 Dropped)，不会阻塞提交；
+// 订阅不再使用时必须调用 Close，Watcher Stop 时通道会被关闭
+type ChangeSetSubscription struct {
+	// C 按快照发布的顺序投递变更集
+	C <-chan SnapshotChangeSet
+
+	w       *Watcher
+	ch      chan SnapshotChangeSet
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// changeSetSubs 管理全部变更集订阅
+type changeSetSubs struct {
+	mu     sync.Mutex
+	subs   map[*ChangeSetSubscription]struct{}
+	closed bool
+	n      atomic.Int64 // len(subs)，没有订阅时发布快照免去计算变更集
+}
+
+// SubscribeChangeSets 创建一个变更集订阅：之后发布的每个快照投递一个 SnapshotChangeSet，buffer<=0 时使用 DefaultSubscriptionBuffer
+//
+// 变更集在快照发布时相对当时的父快照计算(同 ChangeSet)，按发布顺序投递；连续两个变更集的 ParentID 与上一个 SnapshotID
+// 不一致(缓冲已满被丢弃、重放还原的快照接在更早的快照上等)时，可用 DiffSnapshots 补上中间的差异。
+// DisableSnapshots 时没有快照，订阅不会收到变更集；Watcher 已 Stop 时返回的订阅通道已关闭
+// 并发安全
+func (w *Watcher) SubscribeChangeSets(buffer int) *ChangeSetSubscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+	ch := make(chan SnapshotChangeSet, buffer)
+	s := &ChangeSetSubscription{C: ch, w: w, ch: ch}
+
+	cs := &w.changeSets
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.closed {
+		s.once.Do(func() { close(ch) })
+		return s
+	}
+	if cs.subs == nil {
+		cs.subs = make(map[*ChangeSetSubscription]struct{})
+	}
+	cs.subs[s] = struct{}{}
+	cs.n.Store(int64(len(cs.subs)))
+	return s
+}
+
+// Close 取消订阅并关闭通道，可重复调用
+func (s *ChangeSetSubscription) Close() {
+	s.once.Do(func() {
+		cs := &s.w.changeSets
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		delete(cs.subs, s)
+		cs.n.Store(int64(len(cs.subs)))
+		close(s.ch)
+	})
+}
+
+// Dropped 返回因缓冲已满而未投递给该订阅的变更集数
+func (s *ChangeSetSubscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// publishChangeSetLocked 计算新发布快照的变更集并非阻塞地投递给全部订阅，调用方需持有 w.mu 写锁
+func (w *Watcher) publishChangeSetLocked(sn *SnapshotNode) {
+	cs := &w.changeSets
+	if cs.n.Load() == 0 {
+		return
+	}
+	ops, err := w.changeSetOf(sn)
+	if err != nil {
+		w.logWarn("Failed to compute change set", err)
+		return
+	}
+	set := SnapshotChangeSet{SnapshotID: sn.ID, CreatedAt: sn.CreatedAt, Ops: ops}
+	if len(sn.ParentIDs) > 0 {
+		set.ParentID = sn.ParentIDs[0]
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for s := range cs.subs {
+		select {
+		case s.ch <- set:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// closeChangeSets 在 Stop 时关闭全部变更集订阅
+func (w *Watcher) closeChangeSets() {
+	cs := &w.changeSets
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.closed = true
+	for s := range cs.subs {
+		s.once.Do(func() { close(s.ch) })
+	}
+	cs.subs = nil
+	cs.n.Store(0)
+}
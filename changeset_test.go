@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// opsString 把变更集写成 "OP path" 的列表，便于比较
+func opsString(ops []ChangeOp) []string {
+	out := make([]string, len(ops))
+	for i, op := range ops {
+		out[i] = op.Op + " " + op.Path
+		if op.MergedFrom != "" {
+			out[i] += " <" + op.MergedFrom
+		}
+	}
+	return out
+}
+
+// TestChangeSet 测试快照的变更集，订阅按快照投递同样的变更集
+func TestChangeSet(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, root)
+	sub := w.SubscribeChangeSets(0)
+	defer sub.Close()
+
+	if ops, err := w.ChangeSet(w.GetCurrentSnapshot().ID); err != nil || len(ops) != 0 {
+		t.Errorf("initial change set = %v, %v; want empty", ops, err)
+	}
+
+	dir := filepath.Join(root, "sub")
+	file := filepath.Join(dir, "b.txt")
+	_ = os.Mkdir(dir, 0755)
+	_ = os.WriteFile(file, []byte("b"), 0644)
+	w.handleFileChange(file, fsnotify.Create)
+	created := w.GetCurrentSnapshot()
+
+	_ = os.RemoveAll(dir)
+	w.handleFileChange(dir, fsnotify.Remove)
+	removed := w.GetCurrentSnapshot()
+
+	cases := []struct {
+		sn   *SnapshotNode
+		want []string
+	}{
+		{created, []string{"UPSERT " + root, "UPSERT " + dir, "UPSERT " + file}},
+		{removed, []string{"DELETE " + dir, "DELETE " + file}},
+	}
+	for _, c := range cases {
+		ops, err := w.ChangeSet(c.sn.ID)
+		if err != nil {
+			t.Fatalf("ChangeSet(%s) failed: %v", c.sn.ID, err)
+		}
+		if got := opsString(ops); !slices.Equal(got, c.want) {
+			t.Errorf("ChangeSet(%s) = %v; want %v", c.sn.ID, got, c.want)
+		}
+		for _, op := range ops {
+			if (op.Op == ChangeUpsert) != (op.Meta != nil) || (op.Meta != nil && op.Meta != c.sn.Files[op.Path]) {
+				t.Errorf("%s %s carries meta %+v", op.Op, op.Path, op.Meta)
+			}
+		}
+
+		set := <-sub.C
+		parent := c.sn.ParentIDs[0]
+		if set.SnapshotID != c.sn.ID || set.ParentID != parent || !slices.Equal(opsString(set.Ops), c.want) {
+			t.Errorf("delivered %+v; want %s → %s %v", set, parent, c.sn.ID, c.want)
+		}
+	}
+	if len(sub.C) != 0 || sub.Dropped() != 0 {
+		t.Errorf("%d extra change sets, %d dropped", len(sub.C), sub.Dropped())
+	}
+
+	if _, err := w.ChangeSet("snap-missing"); err == nil {
+		t.Error("ChangeSet of a missing snapshot should fail")
+	}
+}
+
+// TestChangeSetOrder 测试变更集按路径排序(而非按目录层级)，类型改变时同一路径先 DELETE 后 UPSERT
+func TestChangeSetOrder(t *testing.T) {
+	p := filepath.FromSlash
+	dir := func(path string) *FileMetadata { return &FileMetadata{Path: p(path), IsDirectory: true} }
+	file := func(path, hash string) *FileMetadata {
+		return &FileMetadata{Path: p(path), Hash: hash, HashState: HashStateHashed}
+	}
+	from := &SnapshotNode{ID: "from", Files: map[string]*FileMetadata{
+		p("/r"): dir("/r"), p("/r/sub"): dir("/r/sub"), p("/r/sub/b"): file("/r/sub/b", "b"), p("/r/x"): file("/r/x", "x1"),
+	}}
+	to := &SnapshotNode{ID: "to", ParentIDs: []string{"from"}, Files: map[string]*FileMetadata{
+		p("/r"): dir("/r"), p("/r/sub"): file("/r/sub", "s"), p("/r/sub.txt"): file("/r/sub.txt", "t"), p("/r/x"): file("/r/x", "x2"),
+	}}
+	want := []string{"DELETE " + p("/r/sub"), "UPSERT " + p("/r/sub"), "UPSERT " + p("/r/sub.txt"), "DELETE " + p("/r/sub/b"), "UPSERT " + p("/r/x")}
+	if got := opsString(changeOps(from, to, nil)); !slices.Equal(got, want) {
+		t.Errorf("changeOps = %v; want %v", got, want)
+	}
+}
+
+// TestChangeSetMerge 测试合并快照的变更集相对第一个父快照计算，来自其他父快照的变化以 MergedFrom 标出
+func TestChangeSetMerge(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, root)
+	x, y := filepath.Join(root, "x"), filepath.Join(root, "y")
+	_ = os.WriteFile(x, []byte("x"), 0644)
+	w.handleFileChange(x, fsnotify.Create)
+	base := w.GetCurrentSnapshot()
+	_ = os.WriteFile(y, []byte("y"), 0644)
+	w.handleFileChange(y, fsnotify.Create)
+	other := w.GetCurrentSnapshot()
+
+	// 合并结果：other 的 y，加上合并时新增的 z
+	z := filepath.Join(root, "z")
+	files := maps.Clone(other.Files)
+	zm := *files[y]
+	zm.Path = z
+	files[z] = &zm
+	merge := &SnapshotNode{ID: "snap-merge", ParentIDs: []string{base.ID, other.ID}, CreatedAt: other.CreatedAt, Files: files}
+	w.snapshots.put(merge)
+
+	ops, err := w.ChangeSet(merge.ID)
+	if err != nil {
+		t.Fatalf("ChangeSet failed: %v", err)
+	}
+	want := []string{"UPSERT " + y + " <" + other.ID, "UPSERT " + z}
+	if got := opsString(ops); !slices.Equal(got, want) {
+		t.Errorf("merge change set = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package watcher
+
+import "time"
+
+// Clock 是 Watcher 使用的时间来源，通过 ConfigWatcher.Clock 或 WithClock 注入
+//
+// 快照ID与创建时间、元信息与审计记录的时间戳、事件合并(Debounce)、监控根巡检与审计日志 flush 的定时器
+// 都取自 Clock；耗时统计(批次/哈希延迟直方图)仍使用真实时间
+// 默认使用系统时间(RealClock)，测试中可使用 watchertest.FakeClock 手动推进时间
+type Clock interface {
+	// Now 返回当前时间
+	Now() time.Time
+	// NewTicker 返回一个周期为 d 的定时器，语义同 time.NewTicker
+	NewTicker(d time.Duration) Ticker
+	// Sleep 阻塞 d
+	Sleep(d time.Duration)
+}
+
+// Ticker 是 Clock.NewTicker 返回的定时器，语义同 time.Ticker
+type Ticker interface {
+	// C 返回投递 tick 的通道
+	C() <-chan time.Time
+	// Stop 停止定时器，不关闭通道
+	Stop()
+}
+
+// RealClock 返回使用系统时间的 Clock
+func RealClock() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker 包装 time.Ticker
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
+
+// now 返回配置的 Clock 的当前时间
+func (w *Watcher) now() time.Time {
+	return w.clock.Now()
+}
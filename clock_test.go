@@ -0,0 +1,62 @@
+package watcher_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchertest"
+)
+
+// TestFakeClock 测试注入的时钟驱动防抖与快照时间戳
+func TestFakeClock(t *testing.T) {
+	root := t.TempDir()
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := watchertest.NewFakeClock(start)
+
+	w, err := watcher.NewWatcherWithOptions([]string{root}, watcher.WithClock(clock), watcher.WithDebounce(time.Second))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.Close()
+	initial := w.GetCurrentSnapshot()
+	if !initial.CreatedAt.Equal(start) {
+		t.Errorf("initial CreatedAt = %v, want %v", initial.CreatedAt, start)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	clock.BlockUntil(2) // 合并与监控根巡检的定时器
+
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("x"), 0644)
+
+	// 时间不推进就不会 flush
+	time.Sleep(50 * time.Millisecond)
+	if w.GetCurrentSnapshot() != initial {
+		t.Fatal("snapshot created before the debounce interval elapsed")
+	}
+
+	var ev watcher.FileEvent
+	deadline := time.After(2 * time.Second)
+	for ev.NewSnap == nil {
+		clock.Advance(time.Second)
+		select {
+		case ev = <-w.EventChan:
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no event after advancing the clock")
+		}
+	}
+	if ev.FilePath != file {
+		t.Errorf("unexpected event %v", ev)
+	}
+	if now := clock.Now(); !ev.NewSnap.CreatedAt.Equal(now) || !ev.NewMeta.CreatedAt.Equal(now) {
+		t.Errorf("CreatedAt = %v / %v, want %v", ev.NewSnap.CreatedAt, ev.NewMeta.CreatedAt, now)
+	}
+	if ev.NewSnap.ID == initial.ID {
+		t.Errorf("snapshot IDs must be unique: %s", ev.NewSnap.ID)
+	}
+}
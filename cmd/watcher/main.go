@@ -0,0 +1,67 @@
+// Command watcher 是 github.com/shuakami/watcher 的命令行封装
+//
+// 用法：
+//
+//	watcher watch <path>... [flags]    监控路径并把事件输出到 stdout(--json 时为 NDJSON)
+//	watcher snapshot list|show|diff    查看 watch --store 持久化的快照
+//	watcher verify <snapshot-id>       校验磁盘内容与快照是否一致
+//
+// 退出码：0 正常结束；1 运行期错误；2 参数或配置错误
+//
+// 命令行层保持尽量薄，flag 与 ConfigWatcher 字段一一对应，也可作为库用法的示例
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 退出码
+const (
+	exitOK      = 0
+	exitRuntime = 1
+	exitUsage   = 2
+)
+
+const usage = `usage: watcher <command> [arguments]
+
+commands:
+  watch <path>... [flags]          watch paths and print events
+  snapshot list|show <id>|diff <from> <to> --store DIR
+                                   inspect snapshots persisted by watch --store
+  verify <snapshot-id>             verify files on disk against a snapshot
+
+run "watcher <command> -h" for command flags
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run 分发子命令并返回退出码
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "watch":
+		return runWatch(ctx, args[1:], stdout, stderr)
+	case "snapshot":
+		return runSnapshot(args[1:], stdout, stderr)
+	case "verify":
+		return runVerify(args[1:], stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "watcher: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+}
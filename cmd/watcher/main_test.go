@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+)
+
+// syncBuffer 是并发安全的 bytes.Buffer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestExitCodes 测试参数错误与未知命令的退出码
+func TestExitCodes(t *testing.T) {
+	cases := []struct {
+		args []string
+		want int
+	}{
+		{nil, exitUsage},
+		{[]string{"nope"}, exitUsage},
+		{[]string{"help"}, exitOK},
+		{[]string{"watch"}, exitUsage},
+		{[]string{"watch", t.TempDir(), "--bogus"}, exitUsage},
+		{[]string{"watch", filepath.Join(t.TempDir(), "missing")}, exitUsage},
+		{[]string{"snapshot", "show"}, exitUsage},
+		{[]string{"snapshot", "list"}, exitUsage},
+		{[]string{"snapshot", "list", "--store", filepath.Join(t.TempDir(), "missing")}, exitRuntime},
+		{[]string{"verify", "snap-1"}, exitRuntime},
+	}
+	for _, c := range cases {
+		var out, errOut bytes.Buffer
+		if got := run(context.Background(), c.args, &out, &errOut); got != c.want {
+			t.Errorf("run(%q) = %d; want %d (stderr: %s)", c.args, got, c.want, errOut.String())
+		}
+	}
+}
+
+// TestWatchFlags 测试 flag 与 ConfigWatcher 字段的对应以及 flag 出现在路径之后
+func TestWatchFlags(t *testing.T) {
+	var cfg watcher.ConfigWatcher
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	watchFlags(fs, &cfg)
+	paths, err := parseInterleaved(fs, []string{"a", "--ignore", "*.tmp", "b", "--ignore=*.log", "--workers", "4", "--scan-on-start", "--debounce", "50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, ",") != "a,b" {
+		t.Errorf("paths = %v", paths)
+	}
+	if strings.Join(cfg.IgnorePatterns, ",") != "*.tmp,*.log" || cfg.WorkerCount != 4 || !cfg.ScanOnStart || cfg.Debounce != 50*time.Millisecond {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+// TestWatchJSON 测试 watch --json 输出 NDJSON，并在 ctx 取消后正常退出
+func TestWatchJSON(t *testing.T) {
+	root := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	var out, errOut syncBuffer
+	code := make(chan int, 1)
+	go func() { code <- run(ctx, []string{"watch", root, "--json", "--debounce", "5ms"}, &out, &errOut) }()
+
+	file := filepath.Join(root, "a.txt")
+	deadline := time.Now().Add(3 * time.Second)
+	for !strings.Contains(out.String(), "a.txt") && time.Now().Before(deadline) {
+		// watch 启动需要时间，重复写入直到事件出现
+		_ = os.WriteFile(file, []byte("hello"), 0644)
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if c := <-code; c != exitOK {
+		t.Fatalf("exit code %d; stderr: %s", c, errOut.String())
+	}
+
+	line := strings.SplitN(strings.TrimSpace(out.String()), "\n", 2)[0]
+	var ev eventLine
+	if err := json.Unmarshal([]byte(line), &ev); err != nil {
+		t.Fatalf("invalid NDJSON line %q: %v", line, err)
+	}
+	if ev.Path != file || ev.Seq != 1 || ev.SnapshotID == "" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+}
+
+// TestSnapshotCommands 测试 snapshot list/show/diff 读取 DirStore 中的快照
+func TestSnapshotCommands(t *testing.T) {
+	dir := t.TempDir()
+	store, err := watcher.NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := &watcher.FileMetadata{Path: "/r/a.txt", Hash: "aa", Size: 1}
+	_ = store.Put(&watcher.SnapshotNode{ID: "snap-1", CreatedAt: base, Files: map[string]*watcher.FileMetadata{"/r/a.txt": a}})
+	_ = store.Put(&watcher.SnapshotNode{ID: "snap-2", ParentIDs: []string{"snap-1"}, CreatedAt: base.Add(time.Second),
+		Files: map[string]*watcher.FileMetadata{"/r/b.txt": {Path: "/r/b.txt", Hash: "bb", Size: 2}}})
+
+	cases := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"snapshot", "list", "--store", dir}, []string{"snap-1 (", "snap-2 ("}},
+		{[]string{"snapshot", "show", "snap-1", "--store", dir}, []string{"/r/a.txt\taa\t1"}},
+		{[]string{"snapshot", "diff", "snap-1", "snap-2", "--store", dir}, []string{"ADDED\t/r/b.txt", "REMOVED\t/r/a.txt"}},
+	}
+	for _, c := range cases {
+		var out, errOut bytes.Buffer
+		if code := run(context.Background(), c.args, &out, &errOut); code != exitOK {
+			t.Fatalf("run(%q) = %d; stderr: %s", c.args, code, errOut.String())
+		}
+		for _, want := range c.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("run(%q) output %q does not contain %q", c.args, out.String(), want)
+			}
+		}
+	}
+
+	var out, errOut bytes.Buffer
+	if code := run(context.Background(), []string{"snapshot", "show", "snap-9", "--store", dir}, &out, &errOut); code != exitRuntime {
+		t.Errorf("show of a missing snapshot = %d; want %d", code, exitRuntime)
+	}
+}
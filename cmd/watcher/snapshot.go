@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/shuakami/watcher"
+)
+
+// errNoVerify 说明 verify 子命令尚未实现
+const errNoVerify = "watcher: %s is not implemented yet\n"
+
+const snapshotUsage = "usage: watcher snapshot list|show <id>|diff <from> <to> --store DIR [--json]"
+
+// runSnapshot 实现 snapshot list/show/diff，读取 watch --store 写入的快照目录
+func runSnapshot(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	storeDir := fs.String("store", "", "snapshot store directory written by watch --store")
+	jsonOut := fs.Bool("json", false, "print results as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, snapshotUsage)
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseInterleaved(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	want := map[string]int{"list": 0, "show": 1, "diff": 2}
+	if len(pos) == 0 {
+		fmt.Fprintln(stderr, snapshotUsage)
+		return exitUsage
+	}
+	n, ok := want[pos[0]]
+	if !ok || len(pos)-1 != n {
+		fmt.Fprintln(stderr, snapshotUsage)
+		return exitUsage
+	}
+	if *storeDir == "" {
+		fmt.Fprintln(stderr, "watcher: snapshot requires --store DIR")
+		return exitUsage
+	}
+	if _, err := os.Stat(*storeDir); err != nil {
+		fmt.Fprintf(stderr, "watcher: %v\n", err)
+		return exitRuntime
+	}
+	store, err := watcher.NewDirStore(*storeDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "watcher: %v\n", err)
+		return exitRuntime
+	}
+
+	switch pos[0] {
+	case "list":
+		err = listSnapshots(store, stdout, *jsonOut)
+	case "show":
+		err = showSnapshot(store, pos[1], stdout, *jsonOut)
+	case "diff":
+		err = diffSnapshots(store, pos[1], pos[2], stdout, *jsonOut)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "watcher: %v\n", err)
+		return exitRuntime
+	}
+	return exitOK
+}
+
+// listSnapshots 按创建时间输出全部快照的摘要
+func listSnapshots(store watcher.SnapshotStore, out io.Writer, jsonOut bool) error {
+	ids, err := store.List()
+	if err != nil {
+		return err
+	}
+	snaps := make([]*watcher.SnapshotNode, 0, len(ids))
+	for _, id := range ids {
+		sn, err := store.Get(id)
+		if err != nil {
+			return err
+		}
+		snaps = append(snaps, sn)
+	}
+	sort.SliceStable(snaps, func(i, j int) bool { return snaps[i].CreatedAt.Before(snaps[j].CreatedAt) })
+
+	enc := json.NewEncoder(out)
+	for _, sn := range snaps {
+		if jsonOut {
+			if err := enc.Encode(sn.Summary()); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(out, "%s\t%s\n", sn, sn.Description)
+	}
+	return nil
+}
+
+// showSnapshot 输出快照摘要与按路径排序的文件表
+func showSnapshot(store watcher.SnapshotStore, id string, out io.Writer, jsonOut bool) error {
+	sn, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(sn.Files))
+	for p := range sn.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	if jsonOut {
+		files := make([]*watcher.FileMetadata, len(paths))
+		for i, p := range paths {
+			files[i] = sn.Files[p]
+		}
+		return json.NewEncoder(out).Encode(struct {
+			watcher.SnapshotSummary
+			Files []*watcher.FileMetadata `json:"files"`
+		}{sn.Summary(), files})
+	}
+	fmt.Fprintln(out, sn)
+	for _, p := range paths {
+		m := sn.Files[p]
+		if m.IsDirectory {
+			fmt.Fprintf(out, "%s/\t%s\n", p, m.Hash)
+			continue
+		}
+		fmt.Fprintf(out, "%s\t%s\t%d\n", p, m.Hash, m.Size)
+	}
+	return nil
+}
+
+// diffSnapshots 输出两个快照之间的差异，每行一个路径
+func diffSnapshots(store watcher.SnapshotStore, fromID, toID string, out io.Writer, jsonOut bool) error {
+	from, err := store.Get(fromID)
+	if err != nil {
+		return err
+	}
+	to, err := store.Get(toID)
+	if err != nil {
+		return err
+	}
+	d := watcher.DiffNodes(from, to)
+
+	enc := json.NewEncoder(out)
+	for _, group := range [][]watcher.DiffEntry{d.Added, d.Removed, d.Modified} {
+		for _, e := range group {
+			if jsonOut {
+				if err := enc.Encode(struct {
+					Kind string `json:"kind"`
+					Path string `json:"path"`
+				}{e.Kind.String(), e.Path}); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Fprintf(out, "%s\t%s\n", e.Kind, e.Path)
+		}
+	}
+	return nil
+}
+
+// runVerify 实现 verify <snapshot-id>
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "usage: watcher verify <snapshot-id>")
+		return exitUsage
+	}
+	fmt.Fprintf(stderr, errNoVerify, "verify")
+	return exitRuntime
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shuakami/watcher"
+)
+
+// stringList 是可重复的字符串 flag(如 --ignore a --ignore b)
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+// historyLimits 是可重复的 --history-limit pattern=N flag
+type historyLimits []watcher.HistoryLimit
+
+func (h *historyLimits) String() string {
+	parts := make([]string, len(*h))
+	for i, l := range *h {
+		parts[i] = fmt.Sprintf("%s=%d", l.Pattern, l.Versions)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (h *historyLimits) Set(v string) error {
+	i := strings.LastIndexByte(v, '=')
+	if i <= 0 {
+		return fmt.Errorf("want pattern=N, got %q", v)
+	}
+	n, err := strconv.Atoi(v[i+1:])
+	if err != nil || n <= 0 {
+		return fmt.Errorf("want a positive version count in %q", v)
+	}
+	*h = append(*h, watcher.HistoryLimit{Pattern: v[:i], Versions: n})
+	return nil
+}
+
+// watchFlags 把 flag 绑定到 ConfigWatcher 的对应字段
+func watchFlags(fs *flag.FlagSet, cfg *watcher.ConfigWatcher) {
+	fs.Var((*stringList)(&cfg.IgnorePatterns), "ignore", "ignore pattern (repeatable), e.g. '*.tmp'")
+	fs.DurationVar(&cfg.Debounce, "debounce", 10*time.Millisecond, "event debounce interval")
+	fs.IntVar(&cfg.WorkerCount, "workers", 32, "maximum concurrent workers")
+	fs.BoolVar(&cfg.WorkerAutoTune, "workers-auto", false, "grow or shrink the worker limit between --workers-min and --workers-max under sustained load")
+	fs.IntVar(&cfg.WorkerMin, "workers-min", 0, "with --workers-auto, lowest worker limit (0 = a quarter of --workers)")
+	fs.IntVar(&cfg.WorkerMax, "workers-max", 0, "with --workers-auto, highest worker limit (0 = four times --workers)")
+	fs.BoolVar(&cfg.RecordOpTrace, "op-trace", false, "attach the sequence of raw operations merged into each event")
+	fs.IntVar(&cfg.MaxOpTrace, "op-trace-max", watcher.DefaultMaxOpTrace, "with --op-trace, keep at most this many operations per event")
+	fs.DurationVar(&cfg.SettleDelay, "settle", 0, "process a path only after it has been quiet this long (0 = immediately)")
+	fs.IntVar(&cfg.MaxPendingPaths, "max-pending", watcher.DefaultMaxPendingPaths, "flush immediately once this many paths are waiting to be processed")
+	fs.Var((*stringList)(&cfg.AppendOnlyPatterns), "append-only", "pattern for append-only detection (repeatable)")
+	fs.Var((*stringList)(&cfg.CompletionPatterns), "complete", "pattern for files reported only once their writes complete, e.g. in a drop folder (repeatable)")
+	fs.DurationVar(&cfg.CompletionQuiet, "complete-quiet", watcher.DefaultCompletionQuiet, "how long a --complete file must stay unchanged to count as complete")
+	fs.Var((*stringList)(&cfg.NoHashPatterns), "no-hash", "pattern for files tracked by size and mtime only, never hashed (repeatable), e.g. '*.mp4'")
+	fs.Int64Var(&cfg.MaxHashSize, "max-hash-size", 0, "skip hashing files larger than this many bytes (0 = no limit)")
+	fs.Func("hash-error-policy", "how to record a file that cannot be hashed: EmptyHash (default), KeepPrevious or DropEntry", func(v string) error {
+		for p := watcher.HashErrorEmptyHash; p <= watcher.HashErrorDropEntry; p++ {
+			if strings.EqualFold(v, p.String()) {
+				cfg.HashErrorPolicy = p
+				return nil
+			}
+		}
+		return fmt.Errorf("want EmptyHash, KeepPrevious or DropEntry, got %q", v)
+	})
+	fs.BoolVar(&cfg.FailOnPartialWatch, "fail-on-partial-watch", false, "fail when any directory cannot be watched")
+	fs.IntVar(&cfg.MaxWatchedDirs, "max-watched-dirs", 0, "watch at most this many directories (0 = no limit)")
+	fs.DurationVar(&cfg.WatchRetryInterval, "watch-retry-interval", watcher.DefaultWatchRetryInterval, "initial interval for retrying directories whose watch failed on resource exhaustion")
+	fs.DurationVar(&cfg.RootPollInterval, "root-poll-interval", time.Second, "interval for checking whether watch roots exist")
+	fs.BoolVar(&cfg.KeepEntriesOnRootLoss, "keep-entries-on-root-loss", false, "keep entries of a removed watch root")
+	fs.BoolVar(&cfg.RejectOverlappingRoots, "reject-overlapping-roots", false, "fail instead of merging overlapping paths")
+	fs.BoolVar(&cfg.ScanOnStart, "scan-on-start", false, "scan and hash all files at start to build a baseline snapshot")
+	fs.BoolVar(&cfg.ScanEvents, "scan-events", false, "with --scan-on-start, emit a SCAN event for every entry found before the BASELINE event")
+	fs.StringVar(&cfg.AuditPath, "audit-path", "", "append an NDJSON audit log of all events to this file")
+	fs.DurationVar(&cfg.MinSnapshotInterval, "min-snapshot-interval", 0, "create at most one snapshot per interval, coalescing changes in between (0 = no limit)")
+	fs.IntVar(&cfg.StormMaxEventsPerSec, "storm-max-events", 0, "degrade (stop hashing, flush less often) when events per second stay above this (0 = no limit)")
+	fs.Int64Var(&cfg.StormMaxHashBytesPerSec, "storm-max-hash-bytes", 0, "degrade when bytes to hash per second stay above this (0 = no limit)")
+	fs.DurationVar(&cfg.StormDwell, "storm-dwell", watcher.DefaultStormDwell, "how long a rate must stay above its limit before degrading")
+	fs.DurationVar(&cfg.StormRecovery, "storm-recovery", watcher.DefaultStormRecovery, "how long rates must stay below their limits before leaving degraded mode")
+	fs.DurationVar(&cfg.StormMaxDebounce, "storm-max-debounce", watcher.DefaultStormMaxDebounce, "flush interval while degraded")
+	fs.BoolVar(&cfg.StormNotifyOnly, "storm-notify-only", false, "only report event storms, never degrade")
+	fs.BoolVar(&cfg.NativePaths, "native-paths", false, "keep the platform's path separator in snapshots and events instead of '/'")
+	fs.Func("path-rewrite", "record paths under RAW as paths under LOGICAL, as RAW=LOGICAL, e.g. '/mnt/data=/srv' for a bind mount", func(v string) error {
+		raw, logical, ok := strings.Cut(v, "=")
+		if !ok {
+			return fmt.Errorf("want raw=logical, got %q", v)
+		}
+		return watcher.WithPathPrefixRewrite(raw, logical)(cfg)
+	})
+	fs.BoolVar(&cfg.IgnoreChmod, "ignore-chmod", false, "drop events that only change metadata (permissions, timestamps)")
+	fs.BoolVar(&cfg.DisablePlatformDefaults, "no-platform-defaults", false, "do not apply the platform's default ignore patterns (e.g. .DS_Store on macOS)")
+	fs.DurationVar(&cfg.ErrorDedupWindow, "error-dedup", 0, "report a repeated error once per window, followed by a summary with its count (0 = report every error)")
+	fs.BoolVar(&cfg.RescanOnOverflow, "rescan-on-overflow", false, "rescan all watch roots after the kernel event queue overflows")
+	fs.IntVar(&cfg.MaxChangedPaths, "max-changed-paths", watcher.DefaultMaxChangedPaths, "record at most this many changed paths per snapshot")
+	fs.IntVar(&cfg.TombstoneSnapshots, "tombstones", 0, "keep deleted entries as tombstones for this many later snapshots (0 = remove immediately)")
+	fs.Var((*historyLimits)(&cfg.HistoryLimits), "history-limit", "keep at most N versions of paths matching pattern, as pattern=N (repeatable), e.g. '*.log=10'")
+	fs.IntVar(&cfg.ReconcileSummaryThreshold, "reconcile-summary", 0, "emit one summary event instead of per-path events when reconciliation finds more changes than this (0 = never)")
+	fs.StringVar(&cfg.InstanceID, "instance", "", "instance id embedded in snapshot ids, so several watchers can share one --store (default random)")
+	fs.BoolVar(&cfg.ValidateStoreOnStart, "validate-store", false, "with --store, check the persisted history for consistency at start")
+	fs.IntVar(&cfg.MemorySnapshots, "keep-snapshots", 0, "with --store, keep only this many recent snapshots in memory (0 = keep all)")
+}
+
+// parseInterleaved 解析 flag，允许 flag 出现在位置参数之后(watch <path> --json)
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// eventLine 是 --json 输出中的一行
+type eventLine struct {
+	Time       time.Time `json:"time"`
+	Seq        uint64    `json:"seq"`
+	Op         string    `json:"op"`
+	Path       string    `json:"path"`
+	SnapshotID string    `json:"snapshot_id"`
+	Hash       string    `json:"hash,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+}
+
+// runWatch 实现 watch 子命令：ctx 取消(收到信号)时优雅退出，Stop 会在退出前 flush 积压事件
+func runWatch(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	var cfg watcher.ConfigWatcher
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	jsonOut := fs.Bool("json", false, "print events as NDJSON")
+	storeDir := fs.String("store", "", "persist snapshots to this directory (read with the snapshot command)")
+	keyframes := fs.Int("store-keyframe-interval", 0, "with --store, persist snapshots as deltas against their parent with a full keyframe every this many deltas (0 = always full)")
+	watchFlags(fs, &cfg)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: watcher watch <path>... [flags]")
+		fs.PrintDefaults()
+	}
+
+	paths, err := parseInterleaved(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			fmt.Fprintf(stderr, "watcher: %v\n", err)
+			return exitUsage
+		}
+	}
+	cfg.WatchPaths = paths
+	if *storeDir != "" {
+		if cfg.Store, err = watcher.NewDirStore(*storeDir, watcher.DirStoreDeltas(*keyframes)); err != nil {
+			fmt.Fprintf(stderr, "watcher: %v\n", err)
+			return exitUsage
+		}
+	}
+
+	w, err := watcher.NewWatcher(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "watcher: %v\n", err)
+		return exitUsage
+	}
+	if err := w.Start(); err != nil {
+		fmt.Fprintf(stderr, "watcher: %v\n", err)
+		return exitRuntime
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		printEvents(w, stdout, *jsonOut)
+	}()
+	go func() {
+		for err := range w.ErrorChan {
+			fmt.Fprintf(stderr, "watcher: %v\n", err)
+		}
+	}()
+
+	<-ctx.Done()
+	closeErr := w.Close()
+	<-done
+	if closeErr != nil {
+		fmt.Fprintf(stderr, "watcher: shutdown: %v\n", closeErr)
+		return exitRuntime
+	}
+	return exitOK
+}
+
+// printEvents 输出事件直到 EventChan 关闭
+func printEvents(w *watcher.Watcher, out io.Writer, jsonOut bool) {
+	enc := json.NewEncoder(out)
+	for ev := range w.EventChan {
+		if !jsonOut {
+			fmt.Fprintf(out, "%d\t%s\t%s\n", ev.Seq, ev.Kind, ev.FilePath)
+			continue
+		}
+		line := eventLine{Time: time.Now(), Seq: ev.Seq, Op: ev.Kind.String(), Path: ev.FilePath, SnapshotID: ev.SnapshotID()}
+		if m := ev.NewMeta; m != nil {
+			line.Hash, line.Size = m.Hash, m.Size
+		}
+		_ = enc.Encode(line)
+	}
+}
@@ -0,0 +1,98 @@
+package watcher
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CompareMode 决定比较快照时文件条目的哪些字段参与判断"修改"，见 CompareBy
+type CompareMode int
+
+const (
+	CompareFull        CompareMode = iota // 内容哈希、大小与修改时间(默认)
+	CompareHashAndSize                    // 内容哈希与大小，忽略修改时间
+	CompareHashOnly                       // 只比较内容哈希，忽略大小与修改时间
+)
+
+// String 返回比较方式的名称
+func (m CompareMode) String() string {
+	switch m {
+	case CompareFull:
+		return "Full"
+	case CompareHashAndSize:
+		return "HashAndSize"
+	case CompareHashOnly:
+		return "HashOnly"
+	}
+	return fmt.Sprintf("CompareMode(%d)", int(m))
+}
+
+// CompareBy 设置 DiffSnapshots、StreamDiff、DriftFromTag 与 DiffNodes 判断文件是否修改的方式，默认 CompareFull
+//
+// 比较不同机器上本应相同的树时，修改时间(以及随之变化的大小)往往不同而内容相同，CompareHashOnly/CompareHashAndSize
+// 可排除这些易变字段。两侧不都有内容哈希(跳过哈希、不可读等)的条目内容未知：CompareHashOnly 与 CompareHashAndSize 下只比较大小
+func CompareBy(m CompareMode) QueryOption {
+	return func(o *queryOptions) {
+		o.compare = m
+	}
+}
+
+// sameBy 按比较方式判断两个文件条目是否视为未修改
+//
+// 只有两侧都有可用的内容哈希时才比较哈希；不可读或跳过哈希的条目内容视为"未知"，
+// 按大小(CompareFull 时另加修改时间)判断，绝不会因为空哈希被判定为"变成了空文件"
+func sameBy(m CompareMode, a, b *FileMetadata) bool {
+	hashed := a.HashState.hasContentHash() && b.HashState.hasContentHash()
+	switch {
+	case m == CompareFull && !a.ModTime.Equal(b.ModTime):
+		return false
+	case a.Size != b.Size && (m != CompareHashOnly || !hashed):
+		return false
+	}
+	if hashed {
+		return a.Hash == b.Hash
+	}
+	return true
+}
+
+// SnapshotRootHashes 返回快照的两种根哈希：content 只由路径、类型与内容哈希决定(没有内容哈希的文件以大小代替)，
+// full 另外包含每个条目(含目录)的大小与修改时间
+//
+// content 相同即内容相同，适合比较不同机器上的树；full 相同即元信息也完全一致。两者都按文件表重新计算，
+// 代价与文件数成正比，不缓存；快照的 RootHash 用于增量维护，与两者都不同。墓碑不参与计算，快照不存在时返回的错误同 DiffSnapshots
+func (w *Watcher) SnapshotRootHashes(id string) (content, full string, err error) {
+	sn, err := w.loadSnapshot(id)
+	if err != nil {
+		return "", "", err
+	}
+	content = recomputeRootHashWith(sn.Files, w.roots, appendContentEntry)
+	full = recomputeRootHashWith(sn.Files, w.roots, appendFullEntry)
+	return content, full, nil
+}
+
+// appendContentEntry 同 appendHashEntry，没有内容哈希的文件只以大小代替哈希
+func appendContentEntry(buf []byte, name string, m *FileMetadata) []byte {
+	typ := byte('f')
+	if m.IsDirectory {
+		typ = 'd'
+	}
+	buf = append(buf, name...)
+	buf = append(buf, 0, typ, 0)
+	buf = append(buf, m.Hash...)
+	if !m.IsDirectory && !m.HashState.hasContentHash() {
+		buf = append(buf, 0)
+		buf = strconv.AppendInt(buf, m.Size, 10)
+	}
+	return append(buf, '\n')
+}
+
+// appendFullEntry 在 (名称, 类型, 哈希) 之后追加大小与修改时间
+func appendFullEntry(buf []byte, name string, m *FileMetadata) []byte {
+	buf = appendContentEntry(buf, name, m)
+	buf = buf[:len(buf)-1]
+	buf = append(buf, 0)
+	buf = strconv.AppendInt(buf, m.Size, 10)
+	buf = append(buf, 0)
+	buf = strconv.AppendInt(buf, m.ModTime.UnixNano(), 10)
+	return append(buf, '\n')
+}
@@ -0,0 +1,125 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCompareBy 测试只有修改时间变化的文件在各比较方式下是否报告为修改，快照经由真实的提交路径生成
+func TestCompareBy(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "d")
+	_ = os.Mkdir(sub, 0755)
+	touched := filepath.Join(sub, "touched.txt")
+	changed := filepath.Join(sub, "changed.txt")
+	_ = os.WriteFile(touched, []byte("t"), 0644)
+	_ = os.WriteFile(changed, []byte("c"), 0644)
+
+	w := newTestWatcher(t, root)
+	rehash := func(path string) string {
+		t.Helper()
+		if _, _, err := w.RehashFile(path); err != nil {
+			t.Fatalf("RehashFile failed: %v", err)
+		}
+		return w.GetCurrentSnapshot().ID
+	}
+	rehash(touched)
+	s0 := rehash(changed)
+	later := time.Now().Add(time.Hour)
+	_ = os.Chtimes(touched, later, later)
+	s1 := rehash(touched)
+	_ = os.WriteFile(changed, []byte("changed"), 0644)
+	s2 := rehash(changed)
+
+	for _, tc := range []struct {
+		from, to string
+		mode     CompareMode
+		want     []string
+	}{
+		{s0, s1, CompareFull, []string{touched}},
+		{s0, s1, CompareHashAndSize, nil},
+		{s0, s1, CompareHashOnly, nil},
+		{s0, s2, CompareFull, []string{changed, touched}},
+		{s0, s2, CompareHashAndSize, []string{changed}},
+		{s0, s2, CompareHashOnly, []string{changed}},
+	} {
+		d, err := w.DiffSnapshots(tc.from, tc.to, CompareBy(tc.mode))
+		if err != nil {
+			t.Fatalf("DiffSnapshots failed: %v", err)
+		}
+		var got []string
+		for _, e := range d.Modified {
+			got = append(got, e.Path)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s %s→%s: modified %v; want %v", tc.mode, tc.from, tc.to, got, tc.want)
+		}
+	}
+	if d, _ := w.DiffSnapshots(s0, s1); len(d.Modified) != 1 {
+		t.Errorf("default compares %d modified; want CompareFull", len(d.Modified))
+	}
+}
+
+// TestSameBy 测试真实文件难以构造的情况：哈希相同而大小不同、两侧没有内容哈希
+func TestSameBy(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	grownA := &FileMetadata{Size: 2, ModTime: t0, Hash: "h", HashState: HashStateHashed}
+	grownB := &FileMetadata{Size: 5, ModTime: t0, Hash: "h", HashState: HashStateHashed}
+	nohashA := &FileMetadata{Size: 3, ModTime: t0, HashState: HashStateSkippedType}
+	nohashB := &FileMetadata{Size: 3, ModTime: t0.Add(time.Hour), HashState: HashStateSkippedType}
+
+	for _, tc := range []struct {
+		mode         CompareMode
+		grown, touch bool
+	}{
+		{CompareFull, false, false},
+		{CompareHashAndSize, false, true},
+		{CompareHashOnly, true, true},
+	} {
+		if got := sameBy(tc.mode, grownA, grownB); got != tc.grown {
+			t.Errorf("%s: same hash, different size: same = %v; want %v", tc.mode, got, tc.grown)
+		}
+		if got := sameBy(tc.mode, nohashA, nohashB); got != tc.touch {
+			t.Errorf("%s: unhashed, different mtime: same = %v; want %v", tc.mode, got, tc.touch)
+		}
+	}
+}
+
+// TestSnapshotRootHashes 测试只有修改时间变化时内容根哈希不变、完整根哈希变化
+func TestSnapshotRootHashes(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, root)
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+	if _, _, err := w.RehashFile(file); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	before := w.GetCurrentSnapshot().ID
+	later := time.Now().Add(time.Hour)
+	_ = os.Chtimes(file, later, later)
+	if _, _, err := w.RehashFile(file); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	after := w.GetCurrentSnapshot().ID
+	if after == before {
+		t.Fatal("an mtime change should commit a snapshot")
+	}
+
+	c1, f1, err := w.SnapshotRootHashes(before)
+	if err != nil {
+		t.Fatalf("SnapshotRootHashes failed: %v", err)
+	}
+	c2, f2, _ := w.SnapshotRootHashes(after)
+	if c1 == "" || c1 != c2 {
+		t.Errorf("content root hashes %q, %q; want equal", c1, c2)
+	}
+	if f1 == f2 || f1 == c1 {
+		t.Errorf("full root hashes %q, %q; want them to differ from each other and from the content hash", f1, f2)
+	}
+	if _, _, err := w.SnapshotRootHashes("missing"); err == nil {
+		t.Error("unknown snapshot: want an error")
+	}
+}
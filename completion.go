@@ -0,0 +1,146 @@
+package watcher
+
+import (
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 投递目录的写入完成检测
+//
+// 命中 ConfigWatcher.CompletionPatterns 的文件在写入期间(如缓慢的分块复制)不计算哈希、不提交、不发送事件，
+// 只记入等待集合；大小与修改时间稳定 CompletionQuiet 且(Linux 上)没有进程以写方式打开它之后，
+// 路径被放回合并表，按正常流程处理一次，事件的 Completed 为 true。
+// 到期检查在合并goroutine的每个 tick(Debounce 粒度)中进行，不额外启动goroutine
+
+// DefaultCompletionQuiet 是 ConfigWatcher.CompletionQuiet 的默认值
+const DefaultCompletionQuiet = 2 * time.Second
+
+// completions 记录等待完成检测的文件
+type completions struct {
+	mu    sync.Mutex
+	files map[string]completionFile
+	n     atomic.Int64 // len(files)，避免没有等待文件时加锁
+}
+
+// completionFile 是单个文件的检测状态
+type completionFile struct {
+	size     int64
+	modTime  time.Time
+	since    time.Time // 大小与修改时间最近一次变化的时间
+	released bool      // 已判定完成并放回合并表，等待处理
+}
+
+// awaitsCompletion 报告 path 是否需要完成检测
+func (w *Watcher) awaitsCompletion(path string, info fs.FileInfo) bool {
+	return !info.IsDir() && matchPatterns(w.cfg.CompletionPatterns, path)
+}
+
+// holdForCompletion 在处理命中 CompletionPatterns 的文件时调用
+//
+// 文件已判定完成且此后没有变化时清除记录并返回 (false, true)，由调用方照常处理；
+// 否则把文件记入(或留在)等待集合并返回 (true, false)，大小或修改时间变化时重新计时
+func (w *Watcher) holdForCompletion(path string, info fs.FileInfo) (hold, completed bool) {
+	c := &w.completion
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.files[path]
+	same := ok && st.size == info.Size() && st.modTime.Equal(info.ModTime())
+	if same && st.released {
+		delete(c.files, path)
+		c.n.Store(int64(len(c.files)))
+		return false, true
+	}
+	if c.files == nil {
+		c.files = make(map[string]completionFile)
+	}
+	if !same {
+		st = completionFile{size: info.Size(), modTime: info.ModTime(), since: w.now()}
+	}
+	st.released = false
+	c.files[path] = st
+	c.n.Store(int64(len(c.files)))
+	return true, false
+}
+
+// clearCompletion 在路径被删除后清除其记录，返回它是否在等待完成(此前未发送过事件)
+func (w *Watcher) clearCompletion(path string) bool {
+	c := &w.completion
+	if c.n.Load() == 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.files[path]
+	if ok {
+		delete(c.files, path)
+		c.n.Store(int64(len(c.files)))
+	}
+	return ok && !st.released
+}
+
+// checkCompletions 检查已稳定 CompletionQuiet 的等待文件，写入完成的放回合并表，由下一次 flush 处理
+//
+// 已不存在的文件直接移除；大小或修改时间有变化、或仍被打开写入的文件再等待一个 CompletionQuiet
+func (w *Watcher) checkCompletions() {
+	c := &w.completion
+	if c.n.Load() == 0 {
+		return
+	}
+	now := w.now()
+	c.mu.Lock()
+	due := make(map[string]completionFile)
+	for p, st := range c.files {
+		if !st.released && now.Sub(st.since) >= w.cfg.CompletionQuiet {
+			due[p] = st
+		}
+	}
+	c.mu.Unlock()
+	if len(due) == 0 {
+		return
+	}
+
+	var ready []string
+	for p, st := range due {
+		info, err := w.fs.Stat(p)
+		switch {
+		case err != nil:
+			st = completionFile{}
+		case info.Size() != st.size || !info.ModTime().Equal(st.modTime):
+			st = completionFile{size: info.Size(), modTime: info.ModTime(), since: now}
+		case w.probeWriters() && openForWrite(w.diskPath(p)):
+			st.since = now
+		default:
+			st.released = true
+			ready = append(ready, p)
+		}
+		c.mu.Lock()
+		if cur, ok := c.files[p]; ok && cur.since.Equal(due[p].since) && !cur.released {
+			if st.since.IsZero() {
+				delete(c.files, p)
+			} else {
+				c.files[p] = st
+			}
+		} else if st.released {
+			ready = ready[:len(ready)-1] // 检查期间有新的变更，由其重新计时
+		}
+		c.n.Store(int64(len(c.files)))
+		c.mu.Unlock()
+	}
+	for _, p := range ready {
+		op := fsnotify.Write
+		if w.currentMeta(p) == nil {
+			op = fsnotify.Create
+		}
+		w.mergeAgg(aggEvent{Event: fsnotify.Event{Name: p, Op: op}})
+	}
+}
+
+// probeWriters 报告能否检查文件是否被打开写入：只有使用操作系统文件系统时才有意义
+func (w *Watcher) probeWriters() bool {
+	_, ok := w.baseFS().(osFS)
+	return ok
+}
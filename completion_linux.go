@@ -0,0 +1,65 @@
+//go:build linux
+
+package watcher
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// openForWrite 报告是否有进程以写方式打开着 path：遍历 /proc/<pid>/fd，
+// 指向 path 的描述符再读取 /proc/<pid>/fdinfo 中的打开标志
+//
+// 只能看到有权限读取的进程，看不到的进程按未打开处理
+func openForWrite(path string) bool {
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return false
+	}
+	for _, proc := range procs {
+		if _, err := strconv.Atoi(proc.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", proc.Name())
+		fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join(dir, "fd", fd.Name())); err != nil || target != path {
+				continue
+			}
+			if fdWritable(filepath.Join(dir, "fdinfo", fd.Name())) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fdWritable 解析 fdinfo 文件的 "flags:" 行(八进制)，报告描述符是否以写方式打开
+func fdWritable(fdinfo string) bool {
+	f, err := os.Open(fdinfo)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		v, ok := strings.CutPrefix(sc.Text(), "flags:")
+		if !ok {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimSpace(v), 8, 64)
+		return err == nil && flags&unix.O_ACCMODE != unix.O_RDONLY
+	}
+	return false
+}
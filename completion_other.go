@@ -0,0 +1,6 @@
+//go:build !linux
+
+package watcher
+
+// openForWrite 在没有 /proc 的平台上总是返回 false，完成检测只依据大小与修改时间是否稳定
+func openForWrite(string) bool { return false }
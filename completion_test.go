@@ -0,0 +1,117 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestCompletionSlowCopy 模拟分块缓慢复制到投递目录：写入期间不提交、不发送事件，
+// 大小稳定 CompletionQuiet 且文件已关闭后才发送一次 Completed 事件
+func TestCompletionSlowCopy(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "upload.iso")
+	const quiet = 2 * time.Second
+	clock := &manualClock{}
+	clock.now.Store(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithClock(clock),
+		WithDisableEventChan(), WithCompletionDetection(quiet, "*.iso"))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	sub := w.Subscribe(16)
+	defer sub.Close()
+
+	tick := func() {
+		w.checkCompletions()
+		w.flushAgg(true)
+		w.workerWG.Wait()
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	op := fsnotify.Create
+	for i := 0; i < 3; i++ {
+		if _, err := f.Write(make([]byte, 4096)); err != nil {
+			t.Fatal(err)
+		}
+		w.handleFileChange(file, op)
+		op = fsnotify.Write
+		clock.advance(quiet / 2)
+		tick()
+	}
+	if _, ok := w.GetCurrentSnapshot().Files[file]; ok {
+		t.Fatal("file committed while still being written")
+	}
+	if n := w.Stats().CompletionPending; n != 1 {
+		t.Fatalf("CompletionPending = %d; want 1", n)
+	}
+
+	clock.advance(quiet)
+	tick()
+	if runtime.GOOS == "linux" {
+		if _, ok := w.GetCurrentSnapshot().Files[file]; ok {
+			t.Fatal("file committed while still open for writing")
+		}
+		_ = f.Close()
+		clock.advance(quiet)
+		tick()
+	}
+
+	m := w.GetCurrentSnapshot().Files[file]
+	if m == nil || m.Size != 3*4096 || m.Hash == "" {
+		t.Fatalf("completed file = %+v; want hashed 12288 bytes", m)
+	}
+	select {
+	case ev := <-sub.C:
+		if !ev.Completed || ev.Kind != OpCreate || ev.FilePath != file {
+			t.Fatalf("event = %+v; want completed create", ev)
+		}
+	default:
+		t.Fatal("no event for the completed file")
+	}
+	select {
+	case ev := <-sub.C:
+		t.Fatalf("unexpected extra event %+v", ev)
+	default:
+	}
+	if n := w.Stats().CompletionPending; n != 0 {
+		t.Fatalf("CompletionPending = %d after completion; want 0", n)
+	}
+}
+
+// TestCompletionDeletedBeforeComplete 测试写入完成前就被删除的文件不产生事件
+func TestCompletionDeletedBeforeComplete(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "partial.iso")
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithDisableEventChan(),
+		WithCompletionDetection(time.Hour, "*.iso"))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	sub := w.Subscribe(16)
+	defer sub.Close()
+
+	_ = os.WriteFile(file, []byte("part"), 0644)
+	w.handleFileChange(file, fsnotify.Create)
+	_ = os.Remove(file)
+	w.handleFileChange(file, fsnotify.Remove)
+
+	select {
+	case ev := <-sub.C:
+		t.Fatalf("unexpected event %+v", ev)
+	default:
+	}
+	if n := w.Stats().CompletionPending; n != 0 {
+		t.Fatalf("CompletionPending = %d; want 0", n)
+	}
+}
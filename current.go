@@ -0,0 +1,35 @@
+package watcher
+
+// 当前状态的轻量查询
+//
+// 高频轮询"某个路径现在是否存在、哈希是多少"时，GetCurrentSnapshot().Files 会让调用方持有(DisableSnapshots 时还要复制)
+// 整个文件表。下面的查询只在调用期间读取当前状态(快照模式下不加锁，DisableSnapshots 或 MinSnapshotInterval 时
+// 持有读锁的时间仅为一次 map 查找)，返回值副本，不分配内存，是热路径上推荐的轮询接口。
+// 当前状态包括 MinSnapshotInterval 时尚未发布的变更，与 GetCurrentSnapshot 一致；DisableCurrentState 时总是为空
+
+// CurrentFile 返回当前状态中 path 的元信息副本，path 不存在或为墓碑时 ok 为 false
+//
+// path 接受 '/' 与本平台分隔符两种形式(同 SnapshotNode.Lookup)。返回的是值副本，可以保留或修改
+// 并发安全
+func (w *Watcher) CurrentFile(path string) (meta FileMetadata, ok bool) {
+	w.readCurrentNode(func(sn *SnapshotNode) {
+		if m := live(sn.lookup(path)); m != nil {
+			meta, ok = *m, true
+		}
+	})
+	return meta, ok
+}
+
+// CurrentLen 返回当前状态中的条目数(文件与目录，不含墓碑)
+// 并发安全
+func (w *Watcher) CurrentLen() (n int) {
+	w.readCurrentNode(func(sn *SnapshotNode) { n = sn.FileCount() })
+	return n
+}
+
+// CurrentRootHash 返回当前状态的根哈希(见 SnapshotNode.RootHash)，可用于廉价地判断监控树是否变化
+// 并发安全
+func (w *Watcher) CurrentRootHash() (hash string) {
+	w.readCurrentNode(func(sn *SnapshotNode) { hash = sn.RootHash })
+	return hash
+}
@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestCurrentQueries 测试 CurrentFile/CurrentLen/CurrentRootHash 与当前快照一致，不分配内存，
+// 墓碑不可见，DisableSnapshots 时同样可用
+func TestCurrentQueries(t *testing.T) {
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{{"snapshots", []Option{WithTombstones(2)}}, {"no snapshots", []Option{WithDisableSnapshots()}}} {
+		t.Run(mode.name, func(t *testing.T) {
+			root := t.TempDir()
+			opts := append([]Option{WithFS(osFS{}, replaySource{}), WithDisableEventChan()}, mode.opts...)
+			w, err := NewWatcherWithOptions([]string{root}, opts...)
+			if err != nil {
+				t.Fatalf("NewWatcherWithOptions failed: %v", err)
+			}
+			t.Cleanup(func() { _ = w.Close() })
+			a, b := filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt")
+			for _, p := range []string{a, b} {
+				_ = os.WriteFile(p, []byte(p), 0644)
+				w.handleFileChange(w.keyOf(p), fsnotify.Create)
+			}
+
+			cur := w.GetCurrentSnapshot()
+			m, ok := w.CurrentFile(a)
+			if !ok || m != *cur.Lookup(a) {
+				t.Fatalf("CurrentFile(a) = %+v, %v; want %+v", m, ok, cur.Lookup(a))
+			}
+			if n := w.CurrentLen(); n != cur.FileCount() || n != 3 {
+				t.Fatalf("CurrentLen() = %d; want 3 (root, a, b)", n)
+			}
+			if h := w.CurrentRootHash(); h == "" || h != cur.RootHash {
+				t.Fatalf("CurrentRootHash() = %q; want %q", h, cur.RootHash)
+			}
+			if allocs := testing.AllocsPerRun(100, func() {
+				_, _ = w.CurrentFile(a)
+				_ = w.CurrentLen()
+				_ = w.CurrentRootHash()
+			}); allocs != 0 {
+				t.Fatalf("queries allocate %v times per run; want 0", allocs)
+			}
+
+			_ = os.Remove(b)
+			w.handleFileChange(w.keyOf(b), fsnotify.Remove)
+			if _, ok := w.CurrentFile(b); ok || w.CurrentLen() != 2 {
+				t.Fatalf("removed file still visible (CurrentLen() = %d)", w.CurrentLen())
+			}
+			if _, ok := w.CurrentFile(filepath.Join(root, "missing")); ok {
+				t.Fatal("CurrentFile reported a missing path")
+			}
+		})
+	}
+}
+
+// BenchmarkCurrentFile 比较 CurrentFile 与经 GetCurrentSnapshot 查找的开销
+func BenchmarkCurrentFile(b *testing.B) {
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{{"snapshots", nil}, {"no snapshots", []Option{WithDisableSnapshots()}}} {
+		root := b.TempDir()
+		opts := append([]Option{WithFS(osFS{}, replaySource{}), WithDisableEventChan()}, mode.opts...)
+		w, err := NewWatcherWithOptions([]string{root}, opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			p := filepath.Join(root, fmt.Sprintf("f%d", i))
+			_ = os.WriteFile(p, nil, 0644)
+			w.handleFileChange(w.keyOf(p), fsnotify.Create)
+		}
+		target := w.keyOf(filepath.Join(root, "f500"))
+
+		b.Run(mode.name+"/CurrentFile", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, ok := w.CurrentFile(target); !ok {
+					b.Fatal("missing")
+				}
+			}
+		})
+		b.Run(mode.name+"/GetCurrentSnapshot", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if w.GetCurrentSnapshot().Lookup(target) == nil {
+					b.Fatal("missing")
+				}
+			}
+		})
+		_ = w.Close()
+	}
+}
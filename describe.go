@@ -0,0 +1,155 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultDescribeTimeout 是 ConfigWatcher.DescribeSnapshotTimeout 的默认值
+const DefaultDescribeTimeout = 100 * time.Millisecond
+
+// ChangedPath 是传给 DescribeSnapshot 的单个变更路径
+//
+// Op 由提交前后的条目推导：新增为 OpCreate，删除为 OpRemove，哈希或大小变化为 OpWrite，其余(只有修改时间、权限等变化)为 OpChmod；
+// OldHash/NewHash 为提交前后的哈希(不存在时为空)，目录为其 Merkle 哈希
+type ChangedPath struct {
+	Path        string
+	Op          EventOp
+	OldHash     string
+	NewHash     string
+	IsDirectory bool
+}
+
+// changedBatch 按 sn.ChangedPaths 的顺序列出 parent → sn 的变更
+func changedBatch(parent, sn *SnapshotNode) []ChangedPath {
+	batch := make([]ChangedPath, 0, len(sn.ChangedPaths))
+	for _, p := range sn.ChangedPaths {
+		old, cur := live(parent.Files[p]), live(sn.Files[p])
+		c := ChangedPath{Path: p}
+		switch {
+		case old == nil && cur == nil:
+			continue
+		case old == nil:
+			c.Op = OpCreate
+		case cur == nil:
+			c.Op = OpRemove
+		case old.Hash != cur.Hash || old.Size != cur.Size:
+			c.Op = OpWrite
+		default:
+			c.Op = OpChmod
+		}
+		if old != nil {
+			c.OldHash, c.IsDirectory = old.Hash, old.IsDirectory
+		}
+		if cur != nil {
+			c.NewHash, c.IsDirectory = cur.Hash, cur.IsDirectory
+		}
+		batch = append(batch, c)
+	}
+	return batch
+}
+
+// describeJob 是已发布、描述尚待 DescribeSnapshot 生成的快照，见 describeCommitted
+type describeJob struct {
+	sn, parent *SnapshotNode
+}
+
+// describeJobFor 返回为刚发布的 sn 生成描述的任务，未配置 DescribeSnapshot 时返回零值
+func (w *Watcher) describeJobFor(sn, parent *SnapshotNode) describeJob {
+	if w.cfg.DescribeSnapshot == nil {
+		return describeJob{}
+	}
+	return describeJob{sn: sn, parent: parent}
+}
+
+// describeCommitted 执行 c 中的描述任务，带描述的替换节点写回 c.snap 与 c.ready 中的事件；调用方不得持有 w.mu
+func (w *Watcher) describeCommitted(c *commitResult) {
+	j := c.describe
+	if j.sn == nil {
+		return
+	}
+	c.describe = describeJob{}
+	sn := w.describe(j.sn, j.parent)
+	if sn == j.sn {
+		return
+	}
+	if c.snap == j.sn {
+		c.snap = sn
+	}
+	for i := range c.ready {
+		if c.ready[i].NewSnap == j.sn {
+			c.ready[i].NewSnap = sn
+		}
+	}
+}
+
+// describe 按 DescribeSnapshot 为已发布的 sn 生成描述，返回带该描述的替换节点(见 withDescription)；
+// 回调 panic、超时或返回空串，或 sn 已被替换、合并时保留默认描述，返回 sn。调用方不得持有 w.mu
+//
+// 回调在单独的goroutine中执行，超过 DescribeSnapshotTimeout(真实时间)后不再等待，其结果被丢弃；
+// 仍在执行的回调计入 Stats().DescribeRunning，Close 时至多再等待一个 DescribeSnapshotTimeout(见 waitDescribes)
+func (w *Watcher) describe(sn, parent *SnapshotNode) *SnapshotNode {
+	batch := changedBatch(parent, sn)
+	done := make(chan string, 1)
+	w.describeWG.Add(1)
+	w.counters.describeRunning.Add(1)
+	go func() {
+		defer w.describeWG.Done()
+		defer w.counters.describeRunning.Add(-1)
+		defer func() {
+			if r := recover(); r != nil {
+				w.logWarn("DescribeSnapshot panicked", fmt.Errorf("%v", r))
+				close(done)
+			}
+		}()
+		done <- w.cfg.DescribeSnapshot(batch, parent)
+	}()
+	t := time.NewTimer(w.cfg.DescribeSnapshotTimeout)
+	defer t.Stop()
+	var desc string
+	select {
+	case d, ok := <-done:
+		if !ok {
+			w.counters.describeFallbacks.Add(1)
+		}
+		desc = d
+	case <-t.C:
+		w.counters.describeFallbacks.Add(1)
+		w.logWarn("DescribeSnapshot timed out", fmt.Errorf("no description after %v", w.cfg.DescribeSnapshotTimeout))
+	}
+	if desc == "" {
+		return sn
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.snapshots.get(sn.ID) != sn {
+		return sn
+	}
+	described := withDescription(sn, desc)
+	w.snapshots.put(described)
+	if w.head.Load() == sn {
+		w.head.Store(described)
+	}
+	return described
+}
+
+// waitDescribes 在 Close 时等待已不再等待其结果的 DescribeSnapshot 回调结束，至多等待 DescribeSnapshotTimeout；
+// 回调无法被中止，届时仍未结束的留在后台(仍计入 Stats().DescribeRunning)并记录日志
+func (w *Watcher) waitDescribes() {
+	if w.cfg.DescribeSnapshot == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		w.describeWG.Wait()
+		close(done)
+	}()
+	t := time.NewTimer(w.cfg.DescribeSnapshotTimeout)
+	defer t.Stop()
+	select {
+	case <-done:
+	case <-t.C:
+		w.logWarn("DescribeSnapshot still running at Close", fmt.Errorf("%d callbacks abandoned", w.counters.describeRunning.Load()))
+	}
+}
@@ -0,0 +1,168 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestDescribeSnapshot 测试回调收到的变更列表与父快照，返回值成为快照描述
+func TestDescribeSnapshot(t *testing.T) {
+	root := t.TempDir()
+	var mu sync.Mutex
+	var batches [][]ChangedPath
+	var parents []string
+	describe := func(batch []ChangedPath, parent *SnapshotNode) string {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, batch)
+		parents = append(parents, parent.ID)
+		if len(batch) == 1 && batch[0].IsDirectory {
+			return ""
+		}
+		return fmt.Sprintf("%d changed, first %s", len(batch), batch[0].Op)
+	}
+	w, err := NewWatcherWithOptions([]string{root}, WithDescribeSnapshot(describe, 0))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	if w.cfg.DescribeSnapshotTimeout != DefaultDescribeTimeout {
+		t.Errorf("DescribeSnapshotTimeout = %v; want the default", w.cfg.DescribeSnapshotTimeout)
+	}
+
+	sub := filepath.Join(root, "sub")
+	file := filepath.Join(sub, "a.yaml")
+	_ = os.Mkdir(sub, 0755)
+	_ = os.WriteFile(file, []byte("a: 1"), 0644)
+	initial := w.GetCurrentSnapshot().ID
+	w.handleFileChange(file, fsnotify.Create)
+	created := w.GetCurrentSnapshot()
+	if created.Description != "3 changed, first CREATE" {
+		t.Errorf("description %q", created.Description)
+	}
+	b := batches[0]
+	// 补齐的上级目录(监控根与 sub)同样在列表中
+	if len(b) != 3 || b[0].Path != root || b[1].Path != sub || !b[1].IsDirectory || b[2].Path != file || b[2].Op != OpCreate ||
+		b[2].OldHash != "" || b[2].NewHash != created.Files[file].Hash || parents[0] != initial {
+		t.Errorf("first batch %+v with parent %s", b, parents[0])
+	}
+
+	_ = os.WriteFile(file, []byte("a: 2"), 0644)
+	w.handleFileChange(file, fsnotify.Write)
+	if b := batches[1]; len(b) != 1 || b[0].Op != OpWrite || b[0].OldHash != created.Files[file].Hash || b[0].NewHash == b[0].OldHash ||
+		parents[1] != created.ID {
+		t.Errorf("second batch %+v with parent %s", b, parents[1])
+	}
+
+	// 返回空串时使用默认描述
+	later := time.Now().Add(time.Hour)
+	_ = os.Chtimes(sub, later, later)
+	w.handleFileChange(sub, fsnotify.Chmod)
+	if b := batches[2]; len(b) != 1 || b[0].Op != OpChmod {
+		t.Errorf("third batch %+v", b)
+	}
+	if d := w.GetCurrentSnapshot().Description; !strings.HasPrefix(d, "Snapshot after CHMOD") {
+		t.Errorf("empty description should fall back to the default, got %q", d)
+	}
+}
+
+// TestDescribeSnapshotFallback 测试回调 panic 或超时时使用默认描述，快照照常创建
+func TestDescribeSnapshotFallback(t *testing.T) {
+	root := t.TempDir()
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	var calls int
+	describe := func(batch []ChangedPath, parent *SnapshotNode) string {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		<-release
+		return "too late"
+	}
+	w, err := NewWatcherWithOptions([]string{root}, WithDescribeSnapshot(describe, 20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	file := filepath.Join(root, "a.txt")
+	for i, op := range []fsnotify.Op{fsnotify.Create, fsnotify.Write} {
+		_ = os.WriteFile(file, []byte(strings.Repeat("x", i+1)), 0644)
+		w.handleFileChange(file, op)
+		if d := w.GetCurrentSnapshot().Description; d != fmt.Sprintf("Snapshot after %s on %s", op, file) {
+			t.Errorf("commit %d: description %q; want the default", i, d)
+		}
+	}
+	if n := w.Stats().DescribeFallbacks; n != 2 {
+		t.Errorf("DescribeFallbacks = %d; want 2", n)
+	}
+	if _, err := NewWatcherWithOptions([]string{root}, WithDescribeSnapshot(nil, 0)); err == nil {
+		t.Error("WithDescribeSnapshot(nil) should fail")
+	}
+}
+
+// TestDescribeSnapshotBlockedClose 测试回调在提交锁之外执行；阻塞超过超时时间的回调不阻塞提交与 Close，结束后其goroutine退出
+func TestDescribeSnapshotBlockedClose(t *testing.T) {
+	root := t.TempDir()
+	before := runtime.NumGoroutine()
+	release := make(chan struct{})
+	var w *Watcher
+	var lockHeld bool
+	describe := func(batch []ChangedPath, parent *SnapshotNode) string {
+		if w.mu.TryLock() {
+			w.mu.Unlock()
+		} else {
+			lockHeld = true
+		}
+		<-release
+		return "too late"
+	}
+	w, err := NewWatcherWithOptions([]string{root}, WithDisableEventChan(), WithDescribeSnapshot(describe, 20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+	if _, _, err := w.RehashFile(file); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	if d := w.GetCurrentSnapshot().Description; d == "too late" {
+		t.Errorf("description %q; want the default", d)
+	}
+	if n := w.Stats().DescribeRunning; n != 1 {
+		t.Errorf("DescribeRunning = %d; want 1", n)
+	}
+
+	start := time.Now()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Close took %v with a blocked callback", d)
+	}
+	if n := w.Stats().DescribeRunning; n != 1 {
+		t.Errorf("DescribeRunning after Close = %d; want the abandoned callback counted", n)
+	}
+
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before || w.Stats().DescribeRunning != 0 {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("goroutines: %d before, %d after; DescribeRunning = %d\n%s",
+				before, runtime.NumGoroutine(), w.Stats().DescribeRunning, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if lockHeld {
+		t.Error("DescribeSnapshot ran while w.mu was held")
+	}
+}
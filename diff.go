@@ -0,0 +1,286 @@
+package watcher
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// DiffKind 表示两个快照间某个路径的变化类型
+type DiffKind int
+
+const (
+	DiffAdded    DiffKind = iota // 新增
+	DiffRemoved                  // 删除
+	DiffModified                 // 修改(内容哈希、大小或修改时间变化，见 CompareBy)
+	DiffRenamed                  // 移动/重命名(内容相同、路径不同)，只在 DetectRenames 时出现
+)
+
+// String 返回变化类型的可读名称
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "ADDED"
+	case DiffRemoved:
+		return "REMOVED"
+	case DiffModified:
+		return "MODIFIED"
+	case DiffRenamed:
+		return "RENAMED"
+	}
+	return fmt.Sprintf("DiffKind(%d)", int(k))
+}
+
+// DiffEntry 表示一个发生变化的路径
+//
+// Old：旧快照中的元信息(新增时为nil)
+// New：新快照中的元信息(删除时为nil，IncludeDeleted 时为其墓碑)
+// From：DiffRenamed 时的旧路径(Path 为新路径)
+type DiffEntry struct {
+	Path string
+	Kind DiffKind
+	Old  *FileMetadata
+	New  *FileMetadata
+	From string
+}
+
+// SnapshotDiff 表示两个快照之间的差异，各列表均按路径排序
+type SnapshotDiff struct {
+	FromID   string
+	ToID     string
+	Added    []DiffEntry
+	Removed  []DiffEntry
+	Modified []DiffEntry
+	Renamed  []DiffEntry // 只在 DetectRenames 时填充
+}
+
+// Empty 判断两个快照是否没有任何差异
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0 && len(d.Renamed) == 0
+}
+
+// DiffSnapshots 比较两个快照，返回从 fromID 到 toID 的差异
+//
+// 相邻快照只比较 ChangedPaths 中的路径；忽略修改时间的比较方式(见 CompareBy)下还借助目录哈希(Merkle)整体跳过哈希相同的子树，
+// 默认按全部元信息比较时逐项比较，只有修改时间变化的文件同样报告为修改
+// 目录本身只报告新增/删除，其"修改"通过子节点的变化体现
+// 已换出到 Store 的快照会被读回，读回失败时返回该错误；opts 可按监控根过滤(见 InRoots)，CompareBy 可忽略修改时间等易变字段，
+// 传入 DetectRenames 时内容相同的删除+新增文件合并为 Renamed 中的一项；墓碑视为不存在，
+// 传入 IncludeDeleted 时被删除条目的 New 为 to 中的墓碑
+// 并发安全
+func (w *Watcher) DiffSnapshots(fromID, toID string, opts ...QueryOption) (*SnapshotDiff, error) {
+	from, err := w.loadSnapshot(fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := w.loadSnapshot(toID)
+	if err != nil {
+		return nil, err
+	}
+	d := DiffNodes(from, to, opts...)
+	w.filterDiff(d, opts)
+	o := parseQuery(opts)
+	if o.renames {
+		detectRenames(d)
+	}
+	if o.deleted {
+		for i := range d.Removed {
+			if m := to.Files[d.Removed[i].Path]; m != nil && m.Deleted {
+				d.Removed[i].New = m
+			}
+		}
+	}
+	return d, nil
+}
+
+// StreamDiff 比较两个快照，对从 fromID 到 toID 的每个差异调用一次 fn，不在内存中汇总结果
+//
+// 差异与 DiffSnapshots 相同(支持 InRoots、CompareBy 与 IncludeDeleted)，按目录层级深度优先给出，同一目录的子路径按路径排序，
+// 目录的子树紧跟在目录之后；DetectRenames 需要全部差异才能配对，在这里被忽略。
+// fn 返回错误时立即停止并原样返回该错误；快照不存在或读回失败时返回的错误同 DiffSnapshots。
+// 额外占用的内存与差异数量无关，适合整棵树被替换等差异极多的情况
+// 并发安全
+func (w *Watcher) StreamDiff(fromID, toID string, fn func(DiffEntry) error, opts ...QueryOption) error {
+	from, err := w.loadSnapshot(fromID)
+	if err != nil {
+		return err
+	}
+	to, err := w.loadSnapshot(toID)
+	if err != nil {
+		return err
+	}
+	keep := w.queryFilter(opts)
+	o := parseQuery(opts)
+	return walkDiffBy(from, to, o.compare, func(e DiffEntry) error {
+		if keep != nil && !keep(e.Path) {
+			return nil
+		}
+		if o.deleted && e.Kind == DiffRemoved {
+			if m := to.Files[e.Path]; m != nil && m.Deleted {
+				e.New = m
+			}
+		}
+		return fn(e)
+	})
+}
+
+// filterDiff 按 opts(如 InRoots)过滤差异中的条目
+func (w *Watcher) filterDiff(d *SnapshotDiff, opts []QueryOption) {
+	keep := w.queryFilter(opts)
+	if keep == nil {
+		return
+	}
+	drop := func(e DiffEntry) bool { return !keep(e.Path) }
+	d.Added = slices.DeleteFunc(d.Added, drop)
+	d.Removed = slices.DeleteFunc(d.Removed, drop)
+	d.Modified = slices.DeleteFunc(d.Modified, drop)
+}
+
+// DiffNodes 比较两个完整快照(如从 SnapshotStore 读出的快照)，返回从 from 到 to 的差异，规则同 DiffSnapshots
+//
+// opts 中只有 CompareBy 生效，按监控根过滤等需要 Watcher 的条件被忽略
+func DiffNodes(from, to *SnapshotNode, opts ...QueryOption) *SnapshotDiff {
+	d := &SnapshotDiff{FromID: from.ID, ToID: to.ID}
+	_ = walkDiffBy(from, to, parseQuery(opts).compare, func(e DiffEntry) error {
+		switch e.Kind {
+		case DiffAdded:
+			d.Added = append(d.Added, e)
+		case DiffRemoved:
+			d.Removed = append(d.Removed, e)
+		default:
+			d.Modified = append(d.Modified, e)
+		}
+		return nil
+	})
+	return d
+}
+
+// walkDiff 按目录层级深度优先地比较 from 与 to，每个差异调用一次 emit，emit 返回错误时停止并返回该错误
+func walkDiff(from, to *SnapshotNode, emit func(DiffEntry) error) error {
+	return walkDiffBy(from, to, CompareFull, emit)
+}
+
+// walkDiffBy 同 walkDiff，文件条目按 mode 比较
+func walkDiffBy(from, to *SnapshotNode, mode CompareMode, emit func(DiffEntry) error) error {
+	if mode.skipsByHash() && from.RootHash != "" && from.RootHash == to.RootHash {
+		return nil
+	}
+	dw := &diffWalker{from: from, to: to, fi: from.index(), ti: to.index(), mode: mode, emit: emit}
+	if canDiffChanged(from, to) {
+		a, b := changedTops(from, to)
+		return dw.level(a, b)
+	}
+	return dw.level(dw.fi.tops, dw.ti.tops)
+}
+
+// diffWalker 是一次比较的状态
+type diffWalker struct {
+	from, to *SnapshotNode
+	fi, ti   *snapIndex
+	mode     CompareMode
+	emit     func(DiffEntry) error
+}
+
+// level 对同一层级的两组有序路径做归并比较，必要时递归进入子目录
+func (dw *diffWalker) level(a, b []string) error {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		var err error
+		switch {
+		case j >= len(b) || (i < len(a) && a[i] < b[j]):
+			err = dw.tree(dw.from, dw.fi, a[i], DiffRemoved)
+			i++
+		case i >= len(a) || b[j] < a[i]:
+			err = dw.tree(dw.to, dw.ti, b[j], DiffAdded)
+			j++
+		default:
+			p := a[i]
+			om, nm := dw.from.Files[p], dw.to.Files[p]
+			switch {
+			case om.IsDirectory != nm.IsDirectory:
+				if err = dw.tree(dw.from, dw.fi, p, DiffRemoved); err == nil {
+					err = dw.tree(dw.to, dw.ti, p, DiffAdded)
+				}
+			case om.IsDirectory:
+				if !dw.mode.skipsByHash() || om.Hash == "" || om.Hash != nm.Hash {
+					err = dw.level(dw.fi.children[p], dw.ti.children[p])
+				}
+			case !sameBy(dw.mode, om, nm):
+				err = dw.emit(DiffEntry{Path: p, Kind: DiffModified, Old: om, New: nm})
+			}
+			i++
+			j++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipsByHash 报告该比较方式下目录哈希相同的子树能否整体跳过
+//
+// 目录哈希只包含有内容哈希的文件的哈希(没有内容哈希的文件以大小与修改时间代替)，不包含其修改时间，
+// 只有忽略修改时间的比较方式才与之一致
+func (m CompareMode) skipsByHash() bool {
+	return m != CompareFull
+}
+
+// tree 把 path 及其子树全部记为同一种变化
+func (dw *diffWalker) tree(sn *SnapshotNode, idx *snapIndex, path string, kind DiffKind) error {
+	e := DiffEntry{Path: path, Kind: kind}
+	if kind == DiffRemoved {
+		e.Old = sn.Files[path]
+	} else {
+		e.New = sn.Files[path]
+	}
+	if err := dw.emit(e); err != nil {
+		return err
+	}
+	for _, c := range idx.children[path] {
+		if err := dw.tree(sn, idx, c, kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameContent 判断两个文件条目是否视为未修改(CompareFull)，见 sameBy
+func sameContent(a, b *FileMetadata) bool {
+	return sameBy(CompareFull, a, b)
+}
+
+// snapIndex 是单个快照的目录层级索引
+//
+// children：目录 -> 按路径排序的直接子路径
+// tops：父目录不在快照中的条目(通常即监控根)，按路径排序
+type snapIndex struct {
+	children map[string][]string
+	tops     []string
+}
+
+// index 按需构建并缓存快照的层级索引
+//
+// 快照发布后不再修改，因此索引只需构建一次；墓碑不在索引中，比较时视为不存在
+func (sn *SnapshotNode) index() *snapIndex {
+	sn.idxOnce.Do(func() {
+		idx := &snapIndex{children: make(map[string][]string)}
+		for p, m := range sn.Files {
+			if m.Deleted {
+				continue
+			}
+			parent := dirOf(p)
+			if live(sn.Files[parent]) != nil && parent != p {
+				idx.children[parent] = append(idx.children[parent], p)
+			} else {
+				idx.tops = append(idx.tops, p)
+			}
+		}
+		for _, c := range idx.children {
+			sort.Strings(c)
+		}
+		sort.Strings(idx.tops)
+		sn.idx = idx
+	})
+	return sn.idx
+}
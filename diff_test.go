@@ -0,0 +1,115 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// replacedTrees 返回两个没有共同文件的合成快照：/r 下 dirs 个目录、每个目录 n 个文件，整棵树被替换时差异数为 2*dirs*(n+1)
+func replacedTrees(dirs, n int) (from, to *SnapshotNode) {
+	build := func(id, prefix string) *SnapshotNode {
+		files := map[string]*FileMetadata{"/r": {Path: "/r", IsDirectory: true, Hash: id}}
+		for d := 0; d < dirs; d++ {
+			dir := fmt.Sprintf("/r/%s%04d", prefix, d)
+			files[dir] = &FileMetadata{Path: dir, IsDirectory: true, Hash: id}
+			for i := 0; i < n; i++ {
+				p := fmt.Sprintf("%s/f%05d", dir, i)
+				files[p] = &FileMetadata{Path: p, Size: int64(i), Hash: id, HashState: HashStateHashed}
+			}
+		}
+		return &SnapshotNode{ID: id, Files: files}
+	}
+	return build("from", "old"), build("to", "new")
+}
+
+// TestStreamDiff 测试 StreamDiff 给出与 DiffSnapshots 相同的差异，回调返回错误时立即停止并原样返回
+func TestStreamDiff(t *testing.T) {
+	root := t.TempDir()
+	w := manifestWatcher(t, root, map[string]string{"keep.txt": "k", "mod.txt": "1", "gone/a.txt": "a", "gone/b.txt": "b"})
+	from := w.GetCurrentSnapshot().ID
+	_ = os.WriteFile(filepath.Join(root, "mod.txt"), []byte("22"), 0644)
+	w.handleFileChange(w.keyOf(filepath.Join(root, "mod.txt")), fsnotify.Write)
+	_ = os.RemoveAll(filepath.Join(root, "gone"))
+	w.handleFileChange(w.keyOf(filepath.Join(root, "gone")), fsnotify.Remove)
+	_ = os.MkdirAll(filepath.Join(root, "new"), 0755)
+	_ = os.WriteFile(filepath.Join(root, "new", "c.txt"), []byte("c"), 0644)
+	w.handleFileChange(w.keyOf(filepath.Join(root, "new", "c.txt")), fsnotify.Create)
+	to := w.GetCurrentSnapshot().ID
+
+	d, err := w.DiffSnapshots(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got []string
+	for _, list := range [][]DiffEntry{d.Added, d.Removed, d.Modified} {
+		for _, e := range list {
+			want = append(want, e.Kind.String()+" "+e.Path)
+		}
+	}
+	if err := w.StreamDiff(from, to, func(e DiffEntry) error {
+		got = append(got, e.Kind.String()+" "+e.Path)
+		return nil
+	}); err != nil {
+		t.Fatalf("StreamDiff failed: %v", err)
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+	if fmt.Sprint(got) != fmt.Sprint(want) || len(got) != 6 {
+		t.Fatalf("StreamDiff = %v; want %v", got, want)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	if err := w.StreamDiff(from, to, func(DiffEntry) error { calls++; return stop }); err != stop || calls != 1 {
+		t.Errorf("StreamDiff = %v after %d calls; want the callback's error after 1", err, calls)
+	}
+	if err := w.StreamDiff("missing", to, func(DiffEntry) error { return nil }); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("StreamDiff(missing) = %v; want ErrSnapshotNotFound", err)
+	}
+}
+
+// TestStreamDiffMemory 测试流式比较的分配次数与差异数量无关
+func TestStreamDiffMemory(t *testing.T) {
+	allocs := func(dirs, n int) float64 {
+		from, to := replacedTrees(dirs, n)
+		count := 0
+		emit := func(DiffEntry) error { count++; return nil }
+		_ = walkDiff(from, to, emit) // 层级索引只构建一次
+		return testing.AllocsPerRun(5, func() { _ = walkDiff(from, to, emit) })
+	}
+	small, large := allocs(2, 10), allocs(20, 1000)
+	if large != small {
+		t.Errorf("allocations grow with the number of differences: %v for 44, %v for 40040", small, large)
+	}
+}
+
+// BenchmarkStreamDiff 比较整棵树被替换时 DiffNodes 与流式比较的内存占用(见 B/op)
+func BenchmarkStreamDiff(b *testing.B) {
+	from, to := replacedTrees(100, 2000)
+	from.index()
+	to.index()
+	b.Run("DiffNodes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if d := DiffNodes(from, to); len(d.Added) == 0 {
+				b.Fatal("no differences")
+			}
+		}
+	})
+	b.Run("Stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			n := 0
+			_ = walkDiff(from, to, func(DiffEntry) error { n++; return nil })
+			if n == 0 {
+				b.Fatal("no differences")
+			}
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 目录哈希(Merkle)
+//
+// 目录的 Hash 定义为其直接子节点按名称排序后 (名称, 类型, 哈希) 元组序列的 SHA-256，
+// 自底向上逐层汇总到监控根，快照的 RootHash 再由各监控根的哈希汇总得到。
+// 因此两个快照中同一目录的 Hash 相同，即可认为整棵子树未变化。
+//
+// 计算是增量的：提交快照时只重算"变更路径 -> 监控根"这条链上的目录，
+// 借助 Watcher.children 索引，单次事件的代价是 O(深度 × 兄弟节点数)，而不是 O(整棵树)。
+
+// rootOf 返回 path 所属的监控根(最长前缀匹配)，不属于任何监控根时返回空串
+func (w *Watcher) rootOf(path string) string {
+	best := ""
+	for _, r := range w.roots {
+		if withinRoot(path, r) {
+			if len(r) > len(best) {
+				best = r
+			}
+		}
+	}
+	return best
+}
+
+// withinRoot 判断 path 是否为 root 本身或位于 root 之下，'/' 与本平台的分隔符都视为分隔符
+func withinRoot(path, root string) bool {
+	return path == root || (len(path) > len(root) && strings.HasPrefix(path, root) && isSep(path[len(root)]))
+}
+
+// isRoot 判断 path 是否为某个监控根
+func (w *Watcher) isRoot(path string) bool {
+	path = w.keyOf(path)
+	for _, r := range w.roots {
+		if path == r {
+			return true
+		}
+	}
+	return false
+}
+
+// missingAncestors 返回 path 的上级目录(直到监控根，含监控根)中当前快照尚未记录的条目
+//
+// stat 在锁外进行；提交时若这些目录已被其它worker补上，则以后提交者为准
+func (w *Watcher) missingAncestors(path string) map[string]*FileMetadata {
+	root := w.rootOf(path)
+	if root == "" || path == root || w.cfg.DisableCurrentState {
+		return nil
+	}
+
+	var missing []string
+	w.readCurrent(func(files map[string]*FileMetadata) {
+		for dir := dirOf(path); ; dir = dirOf(dir) {
+			if live(files[dir]) == nil {
+				missing = append(missing, dir)
+			}
+			if dir == root || dir == dirOf(dir) {
+				break
+			}
+		}
+	})
+
+	out := make(map[string]*FileMetadata, len(missing))
+	for _, dir := range missing {
+		fi, err := w.fs.Stat(dir)
+		if err != nil || !fi.IsDir() {
+			continue
+		}
+		out[dir] = &FileMetadata{
+			Path:         dir,
+			Size:         fi.Size(),
+			ModTime:      fi.ModTime(),
+			IsDirectory:  true,
+			CreatedAt:    w.now(),
+			LastModified: fi.ModTime(),
+			BirthTime:    w.birthTime(dir, fi),
+		}
+	}
+	return out
+}
+
+// linkChildLocked 在目录层级索引中登记 path，调用方需持有 w.mu 写锁
+func (w *Watcher) linkChildLocked(path string) {
+	parent := dirOf(path)
+	if parent == path {
+		return
+	}
+	set, ok := w.children[parent]
+	if !ok {
+		set = make(map[string]struct{})
+		w.children[parent] = set
+	}
+	set[path] = struct{}{}
+}
+
+// removeTreeLocked 从 files 中删除 path 及其整个子树，并同步维护层级索引
+// 调用方需持有 w.mu 写锁
+func (w *Watcher) removeTreeLocked(files map[string]*FileMetadata, path string) {
+	for child := range w.children[path] {
+		w.removeTreeLocked(files, child)
+	}
+	delete(w.children, path)
+	delete(files, path)
+
+	parent := dirOf(path)
+	if set, ok := w.children[parent]; ok {
+		delete(set, path)
+		if len(set) == 0 {
+			delete(w.children, parent)
+		}
+	}
+}
+
+// rehashDirsLocked 重新计算 dirty 路径本身(若为目录)及其所有已记录上级目录的哈希
+//
+// 子路径总比父路径长，按路径长度降序计算即可保证自底向上
+// 调用方需持有 w.mu 写锁
+func (w *Watcher) rehashDirsLocked(files map[string]*FileMetadata, dirty map[string]struct{}) {
+	dirs := make(map[string]struct{})
+	for p := range dirty {
+		if m, ok := files[p]; ok && m.IsDirectory {
+			dirs[p] = struct{}{}
+		}
+		for cur, dir := p, dirOf(p); dir != cur; cur, dir = dir, dirOf(dir) {
+			m, ok := files[dir]
+			if !ok || !m.IsDirectory {
+				break
+			}
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	ordered := make([]string, 0, len(dirs))
+	for d := range dirs {
+		ordered = append(ordered, d)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return len(ordered[i]) > len(ordered[j]) })
+
+	// 以副本替换而不是原地修改：DisableSnapshots 时 files 是可变的当前状态，
+	// 已发出事件的 NewMeta 与 GetCurrentSnapshot 返回的副本仍引用旧条目
+	for _, d := range ordered {
+		m := *files[d]
+		m.Hash = dirHash(files, w.children[d])
+		m.HashState = HashStateHashed
+		files[d] = &m
+	}
+}
+
+// rootHashLocked 汇总各监控根的哈希，调用方需持有 w.mu 锁
+func (w *Watcher) rootHashLocked(files map[string]*FileMetadata) string {
+	return rootHashOf(files, w.roots)
+}
+
+// rootHashOf 按监控根路径排序后汇总各监控根的哈希，没有任何监控根条目时返回空串
+func rootHashOf(files map[string]*FileMetadata, roots []string) string {
+	return rootHashWith(files, roots, appendHashEntry)
+}
+
+// hashEntryFunc 把一个条目编码为参与目录哈希的元组，见 appendHashEntry
+type hashEntryFunc func(buf []byte, name string, m *FileMetadata) []byte
+
+// rootHashWith 同 rootHashOf，条目按 enc 编码
+func rootHashWith(files map[string]*FileMetadata, roots []string, enc hashEntryFunc) string {
+	roots = append([]string(nil), roots...)
+	sort.Strings(roots)
+
+	h := sha256.New()
+	var buf []byte
+	n := 0
+	for _, r := range roots {
+		m := live(files[r])
+		if m == nil {
+			continue
+		}
+		buf = enc(buf[:0], r, m)
+		_, _ = h.Write(buf)
+		n++
+	}
+	if n == 0 {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// dirHash 根据子节点集合计算目录哈希
+func dirHash(files map[string]*FileMetadata, children map[string]struct{}) string {
+	return dirHashWith(files, children, appendHashEntry)
+}
+
+// dirHashWith 同 dirHash，子节点按 enc 编码
+func dirHashWith(files map[string]*FileMetadata, children map[string]struct{}, enc hashEntryFunc) string {
+	names := make([]string, 0, len(children))
+	for c := range children {
+		if _, ok := files[c]; ok {
+			names = append(names, c)
+		}
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	var buf []byte // 各子节点复用同一缓冲
+	for _, c := range names {
+		buf = enc(buf[:0], filepath.Base(c), files[c])
+		_, _ = h.Write(buf)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// appendHashEntry 把一个 (名称, 类型, 哈希) 元组追加到 buf
+//
+// 没有内容哈希的文件(跳过哈希、不可读等)以大小与修改时间代替哈希，与 sameContent 的比较方式一致，
+// 否则这些文件的变化不会反映到上级目录的哈希上，比较快照时会被整棵子树跳过
+func appendHashEntry(buf []byte, name string, m *FileMetadata) []byte {
+	typ := byte('f')
+	if m.IsDirectory {
+		typ = 'd'
+	}
+	buf = append(buf, name...)
+	buf = append(buf, 0, typ, 0)
+	buf = append(buf, m.Hash...)
+	if !m.IsDirectory && !m.HashState.hasContentHash() {
+		buf = append(buf, 0)
+		buf = strconv.AppendInt(buf, m.Size, 10)
+		buf = append(buf, 0)
+		buf = strconv.AppendInt(buf, m.ModTime.UnixNano(), 10)
+	}
+	return append(buf, '\n')
+}
@@ -0,0 +1,156 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// newTestWatcher 创建一个不启动后台goroutine的Watcher，便于直接调用 handleFileChange
+func newTestWatcher(t *testing.T, root string) *Watcher {
+	t.Helper()
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root}})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	return w
+}
+
+// TestDirHashIncremental 测试目录哈希随子节点变化而传递
+func TestDirHashIncremental(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	inner := filepath.Join(sub, "b.txt")
+	outer := filepath.Join(root, "c.txt")
+	_ = os.WriteFile(inner, []byte("b"), 0644)
+	_ = os.WriteFile(outer, []byte("c"), 0644)
+
+	w := newTestWatcher(t, root)
+	w.handleFileChange(inner, fsnotify.Create)
+	w.handleFileChange(outer, fsnotify.Create)
+	first := w.GetCurrentSnapshot()
+
+	if _, ok := first.Files[sub]; !ok {
+		t.Fatalf("ancestor directory %s should be recorded", sub)
+	}
+	if first.Files[sub].Hash == "" || first.Files[root].Hash == "" || first.RootHash == "" {
+		t.Fatalf("directory hashes should be computed")
+	}
+
+	_ = os.WriteFile(outer, []byte("cc"), 0644)
+	w.handleFileChange(outer, fsnotify.Write)
+	second := w.GetCurrentSnapshot()
+
+	if first.Files[sub].Hash != second.Files[sub].Hash {
+		t.Errorf("unchanged subtree hash should be stable")
+	}
+	if first.Files[root].Hash == second.Files[root].Hash || first.RootHash == second.RootHash {
+		t.Errorf("root hash should change after file modification")
+	}
+
+	_ = os.RemoveAll(sub)
+	w.handleFileChange(sub, fsnotify.Remove)
+	third := w.GetCurrentSnapshot()
+	if _, ok := third.Files[inner]; ok {
+		t.Errorf("descendants of removed directory should be removed")
+	}
+}
+
+// TestDiffSnapshots 测试快照差异比较
+func TestDiffSnapshots(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a")
+	_ = os.Mkdir(sub, 0755)
+	inner := filepath.Join(sub, "b.txt")
+	outer := filepath.Join(root, "c.txt")
+	added := filepath.Join(root, "d.txt")
+	_ = os.WriteFile(inner, []byte("b"), 0644)
+	_ = os.WriteFile(outer, []byte("c"), 0644)
+
+	w := newTestWatcher(t, root)
+	w.handleFileChange(inner, fsnotify.Create)
+	w.handleFileChange(outer, fsnotify.Create)
+	from := w.GetCurrentSnapshot()
+
+	_ = os.WriteFile(outer, []byte("changed"), 0644)
+	_ = os.WriteFile(added, []byte("d"), 0644)
+	w.handleFileChange(outer, fsnotify.Write)
+	w.handleFileChange(added, fsnotify.Create)
+	to := w.GetCurrentSnapshot()
+
+	d, err := w.DiffSnapshots(from.ID, to.ID)
+	if err != nil {
+		t.Fatalf("DiffSnapshots failed: %v", err)
+	}
+	if len(d.Modified) != 1 || d.Modified[0].Path != outer {
+		t.Errorf("expected %s modified, got %+v", outer, d.Modified)
+	}
+	if len(d.Added) != 1 || d.Added[0].Path != added {
+		t.Errorf("expected %s added, got %+v", added, d.Added)
+	}
+	if len(d.Removed) != 0 {
+		t.Errorf("expected no removals, got %+v", d.Removed)
+	}
+
+	same, err := w.DiffSnapshots(to.ID, to.ID)
+	if err != nil || !same.Empty() {
+		t.Errorf("diff of a snapshot with itself should be empty")
+	}
+	if _, err := w.DiffSnapshots("missing", to.ID); err == nil {
+		t.Errorf("expected error for unknown snapshot")
+	}
+}
+
+// TestDiffSnapshotsTouchOnly 测试深层目录中只有修改时间变化的文件被报告为修改，忽略修改时间时不报告
+func TestDiffSnapshotsTouchOnly(t *testing.T) {
+	root := t.TempDir()
+	deep := filepath.Join(root, "a", "b")
+	_ = os.MkdirAll(deep, 0755)
+	touched := filepath.Join(deep, "c.txt")
+	added := filepath.Join(root, "d.txt")
+	_ = os.WriteFile(touched, []byte("c"), 0644)
+
+	w := newTestWatcher(t, root)
+	if _, _, err := w.RehashFile(touched); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	from := w.GetCurrentSnapshot()
+
+	later := time.Now().Add(time.Hour)
+	_ = os.Chtimes(touched, later, later)
+	if _, changed, err := w.RehashFile(touched); err != nil || !changed {
+		t.Fatalf("RehashFile after touch = %v, %v; want a new snapshot", changed, err)
+	}
+	touchedSnap := w.GetCurrentSnapshot()
+	if touchedSnap.RootHash != from.RootHash {
+		t.Fatal("an mtime change of a hashed file should not change the root hash")
+	}
+	_ = os.WriteFile(added, []byte("d"), 0644)
+	w.handleFileChange(added, fsnotify.Create)
+	to := w.GetCurrentSnapshot()
+
+	// 相邻快照走 ChangedPaths，跨多个快照时逐层比较
+	for _, toID := range []string{touchedSnap.ID, to.ID} {
+		d, err := w.DiffSnapshots(from.ID, toID)
+		if err != nil {
+			t.Fatalf("DiffSnapshots failed: %v", err)
+		}
+		if len(d.Modified) != 1 || d.Modified[0].Path != touched {
+			t.Errorf("diff to %s: expected %s modified, got %+v", toID, touched, d.Modified)
+		}
+		d, err = w.DiffSnapshots(from.ID, toID, CompareBy(CompareHashOnly))
+		if err != nil {
+			t.Fatalf("DiffSnapshots failed: %v", err)
+		}
+		if len(d.Modified) != 0 {
+			t.Errorf("diff to %s ignoring mtime: expected no modifications, got %+v", toID, d.Modified)
+		}
+	}
+}
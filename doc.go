@@ -1,26 +1,68 @@
 // Package watcher 提供文件系统的变更监控与版本化快照管理功能。
 //
 // 核心特点：
-//   - 递归监控指定路径，自动捕获文件/目录的增删改事件
+//   - 递归监控指定路径，自动捕获文件/目录的增删改事件；新建的目录(含 mkdir -p 等快速建立的嵌套目录)随即加入监控，其中已有的条目补发 Create
+//   - 因文件描述符等系统资源耗尽(EMFILE/ENOSPC)注册失败的目录按退避自动重试(WatchRetryInterval)，调大上限后可调用 RetryFailedWatches 立即恢复，无需重启；结果以 *WatchRetry 发送到 ErrorChan
 //   - 通过Debounce（事件合并）减少过多的事件风暴
-//   - 采用worker池并发处理文件变更
-//   - 每次检测到变更时自动生成新快照（SnapshotNode），并维护DAG
-//   - 为每个快照记录文件元信息（大小、修改时间、哈希等）
-//   - 允许外部通过EventChan接收变更事件
+//   - 采用worker池并发处理文件变更；合并表达到 MaxPendingPaths 时不等定时器立即 flush(Stats().SizeFlushes)，定时 flush 被延误时内存占用仍然有界
+//   - Stats() 给出 worker 利用率、等待令牌的 flush 次数与单个变更的最长耗时；WorkerAutoTune 按持续的饱和/空闲在 WorkerMin 与 WorkerMax 之间调整 worker 上限，SetWorkerLimit 手动设置优先
+//   - SettleDelay 让路径在最近一个事件之后安静一段时间才处理，连续写入只产生一个带最终哈希的事件
+//   - RecordOpTrace 在事件的 OpTrace 中按到达顺序保留合并前的底层操作与时间，可区分 CREATE|WRITE|REMOVE 的实际先后
+//   - 投递目录可用 CompletionPatterns(WithCompletionDetection)等文件写入完成(大小稳定且未被打开写入)后才处理并发送事件(FileEvent.Completed)
+//   - 可配置 Priority(如 SmallFilesFirst)让小的配置文件走快车道，不被同一批次中的大文件拖慢
+//   - 每次检测到变更时自动生成新快照（SnapshotNode），并维护DAG；只需要事件时可用 DisableSnapshots 关闭快照
+//   - ScanOnStart 的基线快照提交后发送一个 OpBaseline 事件(带条目数)，ScanEvents 时之前为每个条目发送 OpScan 事件，与之后的实际变更区分
+//   - 已知树的预期内容(如部署清单)时可用 Baseline(BaselineFromManifest)代替空的初始快照，第一批事件即带有 OldMeta
+//   - 为每个快照记录文件元信息（大小、修改时间、哈希等）；路径统一以 '/' 分隔，Windows 上生成的快照可在其它平台上直接比较(NativePaths 时保留本平台形式)
+//   - 快照描述可由 DescribeSnapshot 回调按本次的变更列表(路径、操作与新旧哈希)生成，回调 panic 或超时时使用默认描述
+//   - 每个快照记录创建时的有效配置(SnapshotNode.Config：忽略规则、大小上限、哈希算法、是否降级及配置指纹)，可用 ConfigAt 查询，随 Store 持久化
+//   - 长期运行时可配置 Store(如 NewDirStore)与 MemorySnapshots：只在内存中完整保留最近的快照，更早的写入 Store，按需透明读回；多个实例可用不同的 InstanceID 共用一个 Store(LoadStore 按实例读取)
+//   - DirStoreDeltas 时 DirStore 只保存相对父快照的增量并定期写入完整的关键帧，读取时透明还原；断裂的增量链由 ValidateStore 报告(IssueBrokenDeltaChain)
+//   - 日志、数据库等频繁变化的文件可用 HistoryLimits(WithHistoryLimit)限制保留的版本数，只变更了这些文件的旧快照会被合并
+//   - TombstoneSnapshots(WithTombstones)时删除的条目以墓碑(FileMetadata.Deleted/DeletedAt)在之后若干个快照中保留，查询默认忽略，IncludeDeleted 时返回
+//   - 文件系统监控看不到的变更(如从容器外直接修改卷)可用 ApplyExternalChanges 作为一个快照提交，事件带有 OpExternal，历史保持一致
+//   - 应用自己写入监控树时可先调用 MarkSelfWrite，SelfWriteWindow 内该路径的事件被丢弃而不会回流(Stats().SelfSuppressed)
+//   - Watcher 自己的输出(DirStore 目录、AuditPath 审计日志)位于监控根之下时自动忽略，避免反馈循环；其路径包含监控根时 NewWatcher 报错
+//   - 监控树经绑定挂载访问时可用 PathRewrite(WithPathPrefixRewrite)把原始路径改写为逻辑路径，快照、事件与持久化只看到逻辑路径
+//   - ExportManifest 把快照导出为可移植的清单(sha256sum 兼容格式或带大小、修改时间的 CSV/TSV，相对路径)，VerifyManifest 与当前快照或磁盘核对
+//   - 审计日志(AuditWriter/AuditPath)可用 ReplayEvents 在另一台机器上还原快照历史，不需要访问原文件系统；事件带有父快照ID(FileEvent.ParentSnapshotID)，按 Seq 顺序沿父快照应用即可重建DAG
+//   - 时间线等需要分页浏览历史时用 HistoryIterator 沿当前分支(第一个父快照，HistoryAllParents 时广度优先遍历全部父快照)逐个取出快照，不复制整个快照表
+//   - 通过DiffSnapshots比较任意两个快照，哈希相同的子树会被整体跳过(DetectRenames 时识别移动/重命名)，差异极多时可用 StreamDiff 逐条处理而不汇总；DuplicateGroups 查找内容重复的文件
+//   - SizeReport 给出快照中文件的大小分布、总量与最大的文件(汇总按快照缓存)，SizeGrowth 找出两个快照之间变大最多的文件
+//   - 比较不同机器上的树时可用 CompareBy(CompareHashOnly 等)忽略修改时间等易变字段，SnapshotRootHashes 给出只由内容决定与包含全部元信息的两种根哈希
+//   - 允许外部通过EventChan或Subscribe()(多订阅者，事件带递增序号Seq)接收变更事件(不读取EventChan时用DisableEventChan关闭它)；事件类型为与fsnotify无关的EventOp(FileEvent.Kind)
+//   - 配置 ReplayBuffer 后，断线重连的消费者可用 SubscribeFrom 从上次处理到的序号续接，已被挤出缓冲时返回 *SeqUnavailableError，改用快照差异重新同步
+//   - ChangeSet 返回快照相对父快照的变更集(按路径排序的 UPSERT/DELETE 操作，合并快照中来自其他父快照的变化以 MergedFrom 标出)，SubscribeChangeSets 按快照投递变更集，适合索引器整体应用
+//   - 事件带有路径第一个底层事件到达的时间与处理完成的时间(FileEvent.FirstSeen/Processed)，不受通道积压影响，两者之差即管道延迟(Stats().EventLatency)，审计日志同样记录
+//   - 事件默认携带完整快照(NewSnap)；转发、序列化事件时推荐 EventSnapshotMode 设为 IDOnly(只带快照ID，按需 GetSnapshotByID)或 Summary(另带快照摘要)
+//   - 计算哈希失败时按 HashErrorPolicy 记录：清空哈希(默认)、沿用之前的哈希并标记为 Stale，或不记录该文件；失败总会计数并发送 *HashError
+//   - 事件风暴保护(StormMaxEventsPerSec/StormMaxHashBytesPerSec)：速率持续超限时降级为只记录元信息并拉长 flush 间隔，通过 *DegradedMode 通知；之后可用 BackfillHashes 补齐跳过的哈希
+//   - 多个 Watcher(不同的监控根或配置)可交给 WatcherGroup 统一启动/关闭，合并订阅事件(GroupEvent.Member 标明来源)并汇总 Stats
+//   - 持续失败的路径(如不可读的目录)可用 ErrorDedupWindow(WithErrorDedup)去重：窗口内只发送一次，结束时汇总为带次数的 *RepeatedError
+//   - 运行中变得不可读(如 chmod 000)的目录标记为 FileMetadata.Inaccessible 并只报告一次 *AccessDeniedError，子树条目保留而不当作删除，恢复访问后自动对账(Stats().InaccessibleDirs)
+//   - 内核事件队列溢出时报告 *OverflowError，可用 RescanOnOverflow 自动对账重扫
 //   - 使用sync.RWMutex保证并发访问安全
-//   - 提供可定制的忽略规则（IgnorePatterns）
+//   - 提供可定制的忽略规则（IgnorePatterns，支持"**"）；开启 HotPathWindow 后可用 HotPaths 找出事件最多的路径来调整规则
+//   - 监控根本身匹配忽略规则时仍被监控(记录一条警告)，规则只作用于其下的条目
+//   - 只关心单个配置文件时用 WatchFile：去抖后按内容哈希调用回调，兼容原子保存与 Kubernetes ConfigMap 的 ..data 符号链接切换，失败时退避重试
+//   - 高频轮询单个路径或汇总信息时用 CurrentFile/CurrentLen/CurrentRootHash，不复制、不持有文件表，也不分配内存
+//   - 通过FilesMatching/FilesUnder按通配符或路径前缀查询快照中的文件(有序索引与路径前缀树按快照缓存，前缀树提交时从父快照派生，子树查询只与路径深度和命中数有关)，GetFiles 一次查找一批指定路径
+//   - 通过Stats()/PublishExpvar()暴露内部计数器(批次与哈希延迟含 P50/P95/P99 与最大值，可用 ResetLatencyStats 清零)，Prometheus指标见子包watcherprom，OpenTelemetry追踪见watcherotel，HTTP调试接口见watcherhttp，gRPC服务见watchergrpc
+//   - 时间来源(Clock)、文件系统读取(FS)与事件来源(EventSource)可注入，测试辅助(可手动推进的FakeClock、内存文件系统MemFS、同步驱动事件管线的Harness)见子包watchertest
 //
 // 注意：
 //   - Windows、Linux、macOS等不同平台对文件系统事件的支持存在差异
+//   - 各平台的默认配置见 PlatformDefaults(如 macOS 上忽略 .DS_Store、Spotlight 索引等并丢弃只有 Chmod 的事件)，可用 WithoutPlatformDefaults 关闭
 //   - 大量文件频繁变更时，可能需要调大通道buffer或优化Debounce
-//   - 目录的哈希暂未实现，仅对文件内容做哈希校验
-//   - Stop() 方法会关闭所有后台goroutine，并在退出前flush一次事件
+//   - 目录的哈希由其子节点(名称、类型、哈希)自底向上汇总(Merkle)，可用于 O(1) 比较整棵子树
+//   - Stop()/Close() 方法会关闭所有后台goroutine，并在退出前flush一次事件；Close 实现 io.Closer 并返回关闭过程中的错误
 //
 // 推荐使用方式：
-//  1. 配置ConfigWatcher
-//  2. 通过NewWatcher创建Watcher
+//  1. 配置ConfigWatcher(或使用 WithDebounce 等 Option)
+//  2. 通过NewWatcher(或NewWatcherWithOptions)创建Watcher
 //  3. 调用Start()开始监控
-//  4. 通过EventChan或ListAllSnapshots()等方法获取监控结果
+//  4. 通过EventChan或ListAllSnapshots()等方法获取监控结果；开启 ScanOnStart 时先等待 OpBaseline 事件，
+//     整体读取其基线快照(而不是逐个处理初始扫描的条目)，再处理 Seq 更大的事件
 //  5. 调用Stop()结束监控
 //
 // 并发安全：
@@ -30,5 +72,6 @@
 //
 // 使用限制：
 //   - 需要在环境中安装fsnotify依赖: go get github.com/fsnotify/fsnotify
+//   - 返回及发送到ErrorChan的错误可用errors.Is/As区分(ErrPathNotFound、ErrWatchLimit、*HashError等)，分类见errors.go
 //   - 如果要在容器中使用，需要保证宿主机和容器的文件系统事件转发正常
 package watcher
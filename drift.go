@@ -0,0 +1,138 @@
+package watcher
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DriftTopN 是 DriftReport.Largest 最多列出的变更数
+const DriftTopN = 10
+
+// TagNotFoundError 表示标签不存在，errors.Is(err, ErrTagNotFound) 成立
+type TagNotFoundError struct {
+	Tag string
+}
+
+// Error 实现 error 接口
+func (e *TagNotFoundError) Error() string {
+	return fmt.Sprintf("tag %q not found", e.Tag)
+}
+
+// Is 使 errors.Is(err, ErrTagNotFound) 成立
+func (e *TagNotFoundError) Is(target error) bool {
+	return target == ErrTagNotFound
+}
+
+// DriftReport 汇总当前快照相对某个标签(基线)的偏离程度，可直接序列化为 JSON
+//
+// 统计只包含文件，目录的新增/删除/修改时间变化不计入；字节数按大小差计算：
+// 新增为其大小，删除为负的原大小，修改为新旧大小之差
+type DriftReport struct {
+	Tag        string `json:"tag"`
+	BaselineID string `json:"baseline_id"`
+	CurrentID  string `json:"current_id"`
+	InSync     bool   `json:"in_sync"` // 没有任何差异(含目录)
+
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Modified int `json:"modified"`
+
+	BytesChanged int64 `json:"bytes_changed"` // 各文件大小差的绝对值之和
+	NetBytes     int64 `json:"net_bytes"`     // 各文件大小差之和(正数表示变大)
+
+	// Largest 是大小差绝对值最大的至多 DriftTopN 个文件(相同时按路径排序)
+	Largest []DriftChange `json:"largest,omitempty"`
+}
+
+// DriftChange 是 DriftReport 中的单个文件变更
+type DriftChange struct {
+	Path      string `json:"path"`
+	Kind      string `json:"kind"` // ADDED/REMOVED/MODIFIED
+	OldSize   int64  `json:"old_size"`
+	NewSize   int64  `json:"new_size"`
+	SizeDelta int64  `json:"size_delta"`
+}
+
+// DriftFromTag 比较标签 tag 指向的快照与当前快照，返回汇总报告
+//
+// 两个快照的 RootHash 相同时不逐个比较文件，直接返回 InSync 的报告；
+// opts 可按监控根过滤(InRoots)或设置比较方式(CompareBy)；标签不存在时返回 *TagNotFoundError
+// 并发安全
+func (w *Watcher) DriftFromTag(tag string, opts ...QueryOption) (*DriftReport, error) {
+	base := w.SnapshotByTag(tag)
+	if base == nil {
+		return nil, &TagNotFoundError{Tag: tag}
+	}
+	cur := w.GetCurrentSnapshot()
+	if cur == nil {
+		return nil, fmt.Errorf("drift from %s: no current snapshot", tag)
+	}
+	r := &DriftReport{Tag: tag, BaselineID: base.ID, CurrentID: cur.ID}
+	if base.ID == cur.ID || (base.RootHash != "" && base.RootHash == cur.RootHash) {
+		r.InSync = true
+		return r, nil
+	}
+	d := DiffNodes(base, cur, opts...)
+	w.filterDiff(d, opts)
+	r.InSync = d.Empty()
+
+	var changes []DriftChange
+	for _, list := range [][]DiffEntry{d.Added, d.Removed, d.Modified} {
+		for _, e := range list {
+			c, ok := driftChange(e)
+			if !ok {
+				continue
+			}
+			switch e.Kind {
+			case DiffAdded:
+				r.Added++
+			case DiffRemoved:
+				r.Removed++
+			case DiffModified:
+				r.Modified++
+			}
+			r.NetBytes += c.SizeDelta
+			r.BytesChanged += abs64(c.SizeDelta)
+			changes = append(changes, c)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		ai, aj := abs64(changes[i].SizeDelta), abs64(changes[j].SizeDelta)
+		if ai != aj {
+			return ai > aj
+		}
+		return changes[i].Path < changes[j].Path
+	})
+	if len(changes) > DriftTopN {
+		changes = changes[:DriftTopN]
+	}
+	r.Largest = changes
+	return r, nil
+}
+
+// driftChange 把文件的差异条目转换为 DriftChange，目录返回 false
+func driftChange(e DiffEntry) (DriftChange, bool) {
+	c := DriftChange{Path: e.Path, Kind: e.Kind.String()}
+	if e.Old != nil {
+		if e.Old.IsDirectory {
+			return c, false
+		}
+		c.OldSize = e.Old.Size
+	}
+	if e.New != nil {
+		if e.New.IsDirectory {
+			return c, false
+		}
+		c.NewSize = e.New.Size
+	}
+	c.SizeDelta = c.NewSize - c.OldSize
+	return c, true
+}
+
+// abs64 返回 n 的绝对值
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
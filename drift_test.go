@@ -0,0 +1,66 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestDriftFromTag 测试相对标签的偏离汇总：计数、大小差、最大变更排序与未知标签
+func TestDriftFromTag(t *testing.T) {
+	root := t.TempDir()
+	write := func(name string, size int) string {
+		p := filepath.Join(root, name)
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		_ = os.WriteFile(p, make([]byte, size), 0644)
+		return p
+	}
+	keep := write("keep.txt", 10)
+	grow := write("dir/grow.bin", 100)
+	gone := write("gone.txt", 30)
+	w := newTestWatcher(t, root)
+	for _, p := range []string{keep, grow, gone} {
+		w.handleFileChange(p, fsnotify.Create)
+	}
+	if err := w.TagSnapshot("golden", w.GetCurrentSnapshot().ID); err != nil {
+		t.Fatalf("TagSnapshot failed: %v", err)
+	}
+
+	r, err := w.DriftFromTag("golden")
+	if err != nil || !r.InSync || r.Added+r.Removed+r.Modified != 0 {
+		t.Fatalf("DriftFromTag at baseline = %+v, %v; want in sync", r, err)
+	}
+
+	_ = os.WriteFile(grow, make([]byte, 1100), 0644)
+	w.handleFileChange(grow, fsnotify.Write)
+	_ = os.Remove(gone)
+	w.handleFileChange(gone, fsnotify.Remove)
+	added := write("new/added.txt", 5)
+	w.handleFileChange(added, fsnotify.Create)
+
+	r, err = w.DriftFromTag("golden")
+	if err != nil {
+		t.Fatalf("DriftFromTag failed: %v", err)
+	}
+	if r.InSync || r.Added != 1 || r.Removed != 1 || r.Modified != 1 {
+		t.Errorf("counts = +%d -%d ~%d in_sync=%v; want +1 -1 ~1", r.Added, r.Removed, r.Modified, r.InSync)
+	}
+	if r.BytesChanged != 1000+30+5 || r.NetBytes != 1000-30+5 {
+		t.Errorf("bytes changed=%d net=%d; want 1035 975", r.BytesChanged, r.NetBytes)
+	}
+	if len(r.Largest) != 3 || r.Largest[0].Path != grow || r.Largest[1].Path != gone || r.Largest[1].SizeDelta != -30 {
+		t.Errorf("Largest = %+v", r.Largest)
+	}
+	if _, err := json.Marshal(r); err != nil {
+		t.Errorf("json.Marshal failed: %v", err)
+	}
+
+	var tnf *TagNotFoundError
+	if _, err := w.DriftFromTag("nope"); !errors.As(err, &tnf) || !errors.Is(err, ErrTagNotFound) || tnf.Tag != "nope" {
+		t.Errorf("unknown tag returned %v; want *TagNotFoundError", err)
+	}
+}
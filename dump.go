@@ -0,0 +1,321 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// DumpOption 配置 DumpState 的输出
+type DumpOption func(*dumpOptions)
+
+type dumpOptions struct {
+	hashPaths bool
+}
+
+// DumpHashPaths 把输出中的路径替换为其 SHA-256 前缀("sha256:<16位hex>")，
+// 错误只保留类型不保留消息(消息中通常包含路径)
+//
+// 同一路径在一次输出中始终映射为同一个值，仍可用于对照
+func DumpHashPaths() DumpOption {
+	return func(o *dumpOptions) { o.hashPaths = true }
+}
+
+// stateDump 是 DumpState 输出的 JSON 结构
+type stateDump struct {
+	Time          time.Time         `json:"time"`
+	Config        configDump        `json:"config"`
+	Roots         []string          `json:"roots"`
+	Excluded      []string          `json:"excluded_own_paths,omitempty"` // 自动忽略的自身输出路径(原始路径)，见 ownpaths.go
+	WatchedDirs   []string          `json:"watched_dirs"`
+	WatchErrors   []watchErrorDump  `json:"watch_errors"`
+	Pending       map[string]string `json:"pending"`
+	RecentErrors  []errorDump       `json:"recent_errors"`
+	CurrentID     string            `json:"current_snapshot_id"`
+	CurrentFiles  int               `json:"current_snapshot_files"`
+	CurrentRoot   string            `json:"current_root_hash"`
+	SnapshotCount int               `json:"snapshot_count"`
+	LastSeq       uint64            `json:"last_seq"`
+	Health        HealthReport      `json:"health"`
+	Stats         WatcherStats      `json:"stats"`
+	HotPaths      []PathCount       `json:"hot_paths,omitempty"`
+}
+
+// configDump 是 ConfigWatcher 中可序列化的部分，回调/接口只记录是否设置
+type configDump struct {
+	WatchPaths             []string         `json:"watch_paths"`
+	IgnorePatterns         []string         `json:"ignore_patterns"`
+	Debounce               time.Duration    `json:"debounce"`
+	WorkerCount            int              `json:"worker_count"`
+	WorkerAutoTune         bool             `json:"worker_auto_tune"`
+	WorkerMin              int              `json:"worker_min"`
+	WorkerMax              int              `json:"worker_max"`
+	WorkerTuneInterval     time.Duration    `json:"worker_tune_interval"`
+	MaxPendingPaths        int              `json:"max_pending_paths"`
+	SettleDelay            time.Duration    `json:"settle_delay"`
+	AppendOnlyPatterns     []string         `json:"append_only_patterns"`
+	MaxHashSize            int64            `json:"max_hash_size"`
+	NoHashPatterns         []string         `json:"no_hash_patterns"`
+	HashBufferSize         int              `json:"hash_buffer_size"`
+	HashErrorPolicy        string           `json:"hash_error_policy"`
+	CompletionPatterns     []string         `json:"completion_patterns"`
+	CompletionQuiet        time.Duration    `json:"completion_quiet"`
+	FailOnPartialWatch     bool             `json:"fail_on_partial_watch"`
+	RootPollInterval       time.Duration    `json:"root_poll_interval"`
+	KeepEntriesOnRootLoss  bool             `json:"keep_entries_on_root_loss"`
+	RejectOverlappingRoots bool             `json:"reject_overlapping_roots"`
+	ScanOnStart            bool             `json:"scan_on_start"`
+	ScanEvents             bool             `json:"scan_events"`
+	HasScanProgress        bool             `json:"has_scan_progress"`
+	HasTracer              bool             `json:"has_tracer"`
+	HasPriority            bool             `json:"has_priority"`
+	HealthThresholds       HealthThresholds `json:"health_thresholds"`
+	AuditPath              string           `json:"audit_path"`
+	HasAuditWriter         bool             `json:"has_audit_writer"`
+	AuditFlushInterval     time.Duration    `json:"audit_flush_interval"`
+	AuditQueueSize         int              `json:"audit_queue_size"`
+	AuditRotateSize        int64            `json:"audit_rotate_size"`
+	HasAuditOnRotate       bool             `json:"has_audit_on_rotate"`
+	HasLogger              bool             `json:"has_logger"`
+	HasHasher              bool             `json:"has_hasher"`
+	DisableSnapshots       bool             `json:"disable_snapshots"`
+	DisableCurrentState    bool             `json:"disable_current_state"`
+	DisableEventChan       bool             `json:"disable_event_chan"`
+	EventSnapshotMode      string           `json:"event_snapshot_mode"`
+	ReplayBuffer           int              `json:"replay_buffer"`
+	RecordOpTrace          bool             `json:"record_op_trace"`
+	MaxOpTrace             int              `json:"max_op_trace"`
+	HasDescribeSnapshot    bool             `json:"has_describe_snapshot"`
+	DescribeTimeout        time.Duration    `json:"describe_snapshot_timeout"`
+	SelfWriteWindow        time.Duration    `json:"self_write_window"`
+	ErrorDedupWindow       time.Duration    `json:"error_dedup_window"`
+	HasClock               bool             `json:"has_clock"`
+	HasFS                  bool             `json:"has_fs"`
+	HasPathRewrite         bool             `json:"has_path_rewrite"`
+	HasEventSource         bool             `json:"has_event_source"`
+	MinSnapshotInterval    time.Duration    `json:"min_snapshot_interval"`
+	ReconcileSummary       int              `json:"reconcile_summary_threshold"`
+	MaxWatchedDirs         int              `json:"max_watched_dirs"`
+	WatchRetryInterval     time.Duration    `json:"watch_retry_interval"`
+	MaxChangedPaths        int              `json:"max_changed_paths"`
+	HistoryLimits          []HistoryLimit   `json:"history_limits"`
+	TombstoneSnapshots     int              `json:"tombstone_snapshots"`
+	RescanOnOverflow       bool             `json:"rescan_on_overflow"`
+	HotPathWindow          time.Duration    `json:"hot_path_window"`
+	HotPathCapacity        int              `json:"hot_path_capacity"`
+	StormMaxEvents         int              `json:"storm_max_events_per_sec"`
+	StormMaxHashBytes      int64            `json:"storm_max_hash_bytes_per_sec"`
+	StormDwell             time.Duration    `json:"storm_dwell"`
+	StormRecovery          time.Duration    `json:"storm_recovery"`
+	StormMaxDebounce       time.Duration    `json:"storm_max_debounce"`
+	StormNotifyOnly        bool             `json:"storm_notify_only"`
+	IgnoreChmod            bool             `json:"ignore_chmod"`
+	NoPlatformDefaults     bool             `json:"disable_platform_defaults"`
+	InstanceID             string           `json:"instance_id"`
+	HasStore               bool             `json:"has_store"`
+	MemorySnapshots        int              `json:"memory_snapshots"`
+	ValidateStoreOnStart   bool             `json:"validate_store_on_start"`
+	NativePaths            bool             `json:"native_paths"`
+	BaselineEntries        int              `json:"baseline_entries"`
+	BaselineKeepOutside    bool             `json:"baseline_keep_outside_roots"`
+}
+
+// dumpConfig 返回配置中可序列化的部分，ps 用于转换路径列表(脱敏)
+func (w *Watcher) dumpConfig(ps func([]string) []string) configDump {
+	cfg := w.cfg
+	d := configDump{
+		WatchPaths:             ps(cfg.WatchPaths),
+		IgnorePatterns:         cfg.IgnorePatterns,
+		Debounce:               cfg.Debounce,
+		WorkerCount:            cfg.WorkerCount,
+		WorkerAutoTune:         cfg.WorkerAutoTune,
+		WorkerMin:              cfg.WorkerMin,
+		WorkerMax:              cfg.WorkerMax,
+		WorkerTuneInterval:     cfg.WorkerTuneInterval,
+		MaxPendingPaths:        cfg.MaxPendingPaths,
+		SettleDelay:            cfg.SettleDelay,
+		AppendOnlyPatterns:     cfg.AppendOnlyPatterns,
+		MaxHashSize:            cfg.MaxHashSize,
+		NoHashPatterns:         cfg.NoHashPatterns,
+		HashBufferSize:         cfg.HashBufferSize,
+		HashErrorPolicy:        cfg.HashErrorPolicy.String(),
+		CompletionPatterns:     cfg.CompletionPatterns,
+		CompletionQuiet:        cfg.CompletionQuiet,
+		FailOnPartialWatch:     cfg.FailOnPartialWatch,
+		RootPollInterval:       cfg.RootPollInterval,
+		KeepEntriesOnRootLoss:  cfg.KeepEntriesOnRootLoss,
+		RejectOverlappingRoots: cfg.RejectOverlappingRoots,
+		ScanOnStart:            cfg.ScanOnStart,
+		ScanEvents:             cfg.ScanEvents,
+		HasScanProgress:        cfg.ScanProgress != nil,
+		HasTracer:              cfg.Tracer != nil,
+		HasPriority:            cfg.Priority != nil,
+		HealthThresholds:       cfg.HealthThresholds.withDefaults(),
+		HasAuditWriter:         cfg.AuditWriter != nil,
+		AuditFlushInterval:     cfg.AuditFlushInterval,
+		AuditQueueSize:         cfg.AuditQueueSize,
+		AuditRotateSize:        cfg.AuditRotateSize,
+		HasAuditOnRotate:       cfg.AuditOnRotate != nil,
+		HasLogger:              cfg.Logger != nil,
+		HasHasher:              cfg.Hasher != nil,
+		DisableSnapshots:       cfg.DisableSnapshots,
+		DisableCurrentState:    cfg.DisableCurrentState,
+		DisableEventChan:       cfg.DisableEventChan,
+		EventSnapshotMode:      cfg.EventSnapshotMode.String(),
+		ReplayBuffer:           cfg.ReplayBuffer,
+		RecordOpTrace:          cfg.RecordOpTrace,
+		MaxOpTrace:             cfg.MaxOpTrace,
+		HasDescribeSnapshot:    cfg.DescribeSnapshot != nil,
+		DescribeTimeout:        cfg.DescribeSnapshotTimeout,
+		SelfWriteWindow:        cfg.SelfWriteWindow,
+		ErrorDedupWindow:       cfg.ErrorDedupWindow,
+		HasClock:               cfg.Clock != nil,
+		HasFS:                  cfg.FS != nil,
+		HasPathRewrite:         cfg.PathRewrite != nil,
+		HasEventSource:         cfg.EventSource != nil,
+		MinSnapshotInterval:    cfg.MinSnapshotInterval,
+		ReconcileSummary:       cfg.ReconcileSummaryThreshold,
+		MaxWatchedDirs:         cfg.MaxWatchedDirs,
+		WatchRetryInterval:     cfg.WatchRetryInterval,
+		MaxChangedPaths:        cfg.MaxChangedPaths,
+		HistoryLimits:          cfg.HistoryLimits,
+		TombstoneSnapshots:     cfg.TombstoneSnapshots,
+		RescanOnOverflow:       cfg.RescanOnOverflow,
+		HotPathWindow:          cfg.HotPathWindow,
+		HotPathCapacity:        cfg.HotPathCapacity,
+		StormMaxEvents:         cfg.StormMaxEventsPerSec,
+		StormMaxHashBytes:      cfg.StormMaxHashBytesPerSec,
+		StormDwell:             cfg.StormDwell,
+		StormRecovery:          cfg.StormRecovery,
+		StormMaxDebounce:       cfg.StormMaxDebounce,
+		StormNotifyOnly:        cfg.StormNotifyOnly,
+		IgnoreChmod:            cfg.IgnoreChmod,
+		NoPlatformDefaults:     cfg.DisablePlatformDefaults,
+		InstanceID:             cfg.InstanceID,
+		HasStore:               cfg.Store != nil,
+		MemorySnapshots:        cfg.MemorySnapshots,
+		ValidateStoreOnStart:   cfg.ValidateStoreOnStart,
+		NativePaths:            cfg.NativePaths,
+		BaselineEntries:        baselineEntries(cfg.Baseline),
+		BaselineKeepOutside:    cfg.BaselineKeepOutsideRoots,
+	}
+	if cfg.AuditPath != "" {
+		d.AuditPath = ps([]string{cfg.AuditPath})[0]
+	}
+	return d
+}
+
+type watchErrorDump struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type errorDump struct {
+	Time  time.Time `json:"time"`
+	Type  string    `json:"type"`
+	Error string    `json:"error,omitempty"`
+}
+
+// DumpState 以 JSON 写出内部状态，用于问题排查(support bundle)
+//
+// 包含：配置、监控根与已注册目录、合并表(aggMap)中待处理的路径、队列深度、最近的错误、
+// 当前快照ID与文件数、健康状态与统计计数器，以及启用 HotPathWindow 时事件最多的路径
+// 默认不做任何脱敏；传入 DumpHashPaths() 可把路径替换为哈希
+// 各部分分别短暂持锁采集，不会长时间暂停事件处理(因此各部分之间不保证是同一时刻的一致视图)
+func (w *Watcher) DumpState(wr io.Writer, opts ...DumpOption) error {
+	var o dumpOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	p := func(path string) string {
+		if !o.hashPaths {
+			return path
+		}
+		sum := sha256.Sum256([]byte(path))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	ps := func(paths []string) []string {
+		out := make([]string, len(paths))
+		for i, path := range paths {
+			out[i] = p(path)
+		}
+		return out
+	}
+
+	d := stateDump{
+		Time:    w.now(),
+		Config:  w.dumpConfig(ps),
+		Roots:   ps(w.roots),
+		Pending: make(map[string]string),
+		LastSeq: w.LastSeq(),
+		Health:  w.Health(),
+		Stats:   w.Stats(),
+	}
+
+	if len(w.excluded) > 0 {
+		d.Excluded = ps(w.excluded)
+	}
+
+	if d.Health.Running {
+		watched := w.fsWatcher.WatchList()
+		sort.Strings(watched)
+		d.WatchedDirs = ps(watched)
+	}
+
+	w.aggMu.Lock()
+	for path, e := range w.aggMap {
+		d.Pending[p(path)] = e.op.String()
+	}
+	w.aggMu.Unlock()
+
+	w.mu.RLock()
+	for _, we := range w.watchErrs {
+		d.WatchErrors = append(d.WatchErrors, watchErrorDump{Path: p(we.Path), Error: redactError(we.Err, o.hashPaths)})
+	}
+	cur := w.head.Load()
+	d.CurrentID = cur.ID
+	d.CurrentFiles = cur.FileCount()
+	d.CurrentRoot = cur.RootHash
+	d.SnapshotCount = w.snapshots.len()
+	w.mu.RUnlock()
+
+	for _, pc := range w.HotPaths(hotPathsDumpN) {
+		pc.Path = p(pc.Path)
+		d.HotPaths = append(d.HotPaths, pc)
+	}
+
+	for _, re := range w.recentErrs.list() {
+		d.RecentErrors = append(d.RecentErrors, errorDump{
+			Time:  re.Time,
+			Type:  fmt.Sprintf("%T", re.Err),
+			Error: redactError(re.Err, o.hashPaths),
+		})
+	}
+
+	enc := json.NewEncoder(wr)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		return fmt.Errorf("failed to write state dump: %w", err)
+	}
+	return nil
+}
+
+// redactError 在脱敏模式下丢弃错误消息
+func redactError(err error, redact bool) string {
+	if redact {
+		return ""
+	}
+	return err.Error()
+}
+
+// baselineEntries 返回 Baseline 的条目数，未配置时为0
+func baselineEntries(base *SnapshotNode) int {
+	if base == nil {
+		return 0
+	}
+	return len(base.Files)
+}
@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDumpState 测试状态转储的内容与路径哈希选项
+func TestDumpState(t *testing.T) {
+	root := t.TempDir()
+	_ = os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644)
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root}, Debounce: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer w.Stop()
+	go func() {
+		for range w.ErrorChan {
+		}
+	}()
+	w.emitError(fmt.Errorf("file %s is unreadable: %w", filepath.Join(root, "secret"), os.ErrPermission))
+
+	var buf bytes.Buffer
+	if err := w.DumpState(&buf); err != nil {
+		t.Fatalf("DumpState failed: %v", err)
+	}
+	var d map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &d); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if d["current_snapshot_id"] != w.GetCurrentSnapshot().ID {
+		t.Errorf("current_snapshot_id = %v", d["current_snapshot_id"])
+	}
+	if dirs, _ := d["watched_dirs"].([]any); len(dirs) != 1 || dirs[0] != root {
+		t.Errorf("watched_dirs = %v", d["watched_dirs"])
+	}
+	if !strings.Contains(buf.String(), "secret") {
+		t.Errorf("recent error missing from dump:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := w.DumpState(&buf, DumpHashPaths()); err != nil {
+		t.Fatalf("DumpState failed: %v", err)
+	}
+	if s := buf.String(); strings.Contains(s, root) || strings.Contains(s, "secret") || !strings.Contains(s, "sha256:") {
+		t.Errorf("paths not hashed:\n%s", s)
+	}
+}
+
+// TestRecentErrors 测试错误环形缓冲区只保留最近的错误
+func TestRecentErrors(t *testing.T) {
+	var r errorRing
+	for i := 0; i < recentErrorCap+5; i++ {
+		r.add(time.Now(), fmt.Errorf("err %d", i))
+	}
+	list := r.list()
+	if len(list) != recentErrorCap {
+		t.Fatalf("len = %d, want %d", len(list), recentErrorCap)
+	}
+	if list[0].Err.Error() != "err 5" || list[len(list)-1].Err.Error() != fmt.Sprintf("err %d", recentErrorCap+4) {
+		t.Errorf("unexpected order: first %v, last %v", list[0].Err, list[len(list)-1].Err)
+	}
+}
@@ -0,0 +1,149 @@
+package watcher
+
+import "sort"
+
+// DuplicateGroup 是同一快照中内容相同(哈希与大小都相同)的一组文件
+type DuplicateGroup struct {
+	Hash   string
+	Size   int64    // 单个文件的大小
+	Paths  []string // 有序，至少两个
+	Wasted int64    // 除保留一份外多占用的字节数：Size × (len(Paths)-1)
+}
+
+// FileMove 是两个快照之间内容未变、只是路径变化的文件，见 MovedFiles
+type FileMove struct {
+	From string
+	To   string
+	Hash string
+	Size int64
+}
+
+// contentKey 是按内容分组的键；哈希相同但大小不同(哈希碰撞)的文件不会被视为相同
+type contentKey struct {
+	hash string
+	size int64
+}
+
+// dedupable 报告条目能否参与按内容分组：有可用内容哈希的非空文件
+//
+// 目录、墓碑、跳过哈希(HashState 非 OK)的文件不参与；空文件的内容都相同，不计为重复或移动
+func dedupable(m *FileMetadata) bool {
+	return m != nil && !m.IsDirectory && !m.Deleted && m.Size > 0 && m.HashState.hasContentHash()
+}
+
+// DuplicateGroups 返回快照中内容相同的文件分组，按 Wasted 从大到小排序(相同时按哈希)
+//
+// 已换出到 Store 的快照会被读回；快照不存在时返回 ErrSnapshotNotFound
+// 并发安全
+func (w *Watcher) DuplicateGroups(snapshotID string) ([]DuplicateGroup, error) {
+	sn, err := w.loadSnapshot(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[contentKey][]string)
+	for p, m := range sn.Files {
+		if dedupable(m) {
+			k := contentKey{m.Hash, m.Size}
+			byKey[k] = append(byKey[k], p)
+		}
+	}
+	var out []DuplicateGroup
+	for k, paths := range byKey {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Strings(paths)
+		out = append(out, DuplicateGroup{Hash: k.hash, Size: k.size, Paths: paths, Wasted: k.size * int64(len(paths)-1)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Wasted != out[j].Wasted {
+			return out[i].Wasted > out[j].Wasted
+		}
+		return out[i].Hash < out[j].Hash
+	})
+	return out, nil
+}
+
+// DuplicateReport 返回快照中内容相同的文件：哈希 -> 有序路径(至少两个)
+//
+// 规则同 DuplicateGroups，需要大小或浪费的字节数时使用 DuplicateGroups
+// 并发安全
+func (w *Watcher) DuplicateReport(snapshotID string) (map[string][]string, error) {
+	groups, err := w.DuplicateGroups(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]string, len(groups))
+	for _, g := range groups {
+		out[g.Hash] = append(out[g.Hash], g.Paths...)
+	}
+	for _, paths := range out {
+		sort.Strings(paths)
+	}
+	return out, nil
+}
+
+// MovedFiles 返回从 fromID 到 toID 内容未变、只是路径变化的文件(按 To 排序)
+//
+// 即 DiffSnapshots 中哈希与大小相同的一对"删除+新增"；同一内容有多个候选时按路径顺序配对，
+// 多出的一方仍是删除或新增。参与比较的文件规则同 DuplicateGroups
+// 并发安全
+func (w *Watcher) MovedFiles(fromID, toID string) ([]FileMove, error) {
+	d, err := w.DiffSnapshots(fromID, toID, DetectRenames())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FileMove, 0, len(d.Renamed))
+	for _, e := range d.Renamed {
+		out = append(out, FileMove{From: e.From, To: e.Path, Hash: e.New.Hash, Size: e.New.Size})
+	}
+	return out, nil
+}
+
+// DetectRenames 让 DiffSnapshots 把内容相同(哈希与大小都相同)的删除+新增文件报告为 DiffRenamed，
+// 放在 SnapshotDiff.Renamed 中而不是 Removed/Added；配对规则见 MovedFiles
+func DetectRenames() QueryOption {
+	return func(o *queryOptions) {
+		o.renames = true
+	}
+}
+
+// detectRenames 把 d 中可配对的删除+新增文件移到 Renamed
+func detectRenames(d *SnapshotDiff) {
+	removed := make(map[contentKey][]int)
+	for i, e := range d.Removed {
+		if dedupable(e.Old) {
+			k := contentKey{e.Old.Hash, e.Old.Size}
+			removed[k] = append(removed[k], i)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	moved := make(map[int]bool)
+	var added []DiffEntry
+	for _, e := range d.Added {
+		if dedupable(e.New) {
+			k := contentKey{e.New.Hash, e.New.Size}
+			// Removed 与 Added 都按路径排序，按顺序取第一个候选即按路径配对
+			if c := removed[k]; len(c) > 0 {
+				old := d.Removed[c[0]]
+				removed[k] = c[1:]
+				moved[c[0]] = true
+				d.Renamed = append(d.Renamed, DiffEntry{Path: e.Path, Kind: DiffRenamed, Old: old.Old, New: e.New, From: old.Path})
+				continue
+			}
+		}
+		added = append(added, e)
+	}
+	if len(moved) == 0 {
+		return
+	}
+	var rest []DiffEntry
+	for i, e := range d.Removed {
+		if !moved[i] {
+			rest = append(rest, e)
+		}
+	}
+	d.Added, d.Removed = added, rest
+}
@@ -0,0 +1,103 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestDuplicateReport 测试按内容分组：目录、空文件与跳过哈希的文件不参与，大小一并返回
+func TestDuplicateReport(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithNoHashPatterns("*.bin"))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	files := map[string]string{
+		"a.txt": "same", "sub/b.txt": "same", "c.txt": "other",
+		"x.bin": "same", "y.bin": "same", "e1": "", "e2": "",
+	}
+	_ = os.Mkdir(filepath.Join(root, "sub"), 0755)
+	w.handleFileChange(filepath.Join(root, "sub"), fsnotify.Create)
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		_ = os.WriteFile(p, []byte(content), 0644)
+		w.handleFileChange(p, fsnotify.Create)
+	}
+
+	id := w.GetCurrentSnapshot().ID
+	groups, err := w.DuplicateGroups(id)
+	if err != nil {
+		t.Fatalf("DuplicateGroups failed: %v", err)
+	}
+	want := []string{filepath.Join(root, "a.txt"), filepath.Join(root, "sub", "b.txt")}
+	if len(groups) != 1 || groups[0].Size != 4 || groups[0].Wasted != 4 || len(groups[0].Paths) != 2 ||
+		groups[0].Paths[0] != want[0] || groups[0].Paths[1] != want[1] {
+		t.Fatalf("DuplicateGroups = %+v; want one group %v of size 4", groups, want)
+	}
+	rep, err := w.DuplicateReport(id)
+	if err != nil || len(rep) != 1 || len(rep[groups[0].Hash]) != 2 {
+		t.Errorf("DuplicateReport = %v, %v", rep, err)
+	}
+	if _, err := w.DuplicateReport("nope"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("unknown snapshot: %v; want ErrSnapshotNotFound", err)
+	}
+}
+
+// TestDetectRenames 测试内容相同的删除+新增在 DetectRenames 时报告为 Renamed，否则保持原样
+func TestDetectRenames(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, root)
+	write := func(name, content string) string {
+		p := filepath.Join(root, name)
+		_ = os.WriteFile(p, []byte(content), 0644)
+		w.handleFileChange(p, fsnotify.Create)
+		return p
+	}
+	remove := func(p string) {
+		_ = os.Remove(p)
+		w.handleFileChange(p, fsnotify.Remove)
+	}
+	old := write("old.txt", "payload")
+	gone := write("gone.txt", "bye")
+	dup1 := write("d1.txt", "dup")
+	from := w.GetCurrentSnapshot().ID
+
+	remove(old)
+	moved := write("new.txt", "payload")
+	remove(gone)
+	write("fresh.txt", "hello")
+	remove(dup1)
+	dupA, dupB := write("da.txt", "dup"), write("db.txt", "dup")
+	to := w.GetCurrentSnapshot().ID
+
+	plain, err := w.DiffSnapshots(from, to)
+	if err != nil {
+		t.Fatalf("DiffSnapshots failed: %v", err)
+	}
+	if len(plain.Renamed) != 0 || len(plain.Removed) != 3 || len(plain.Added) != 4 {
+		t.Fatalf("plain diff removed=%d added=%d renamed=%d", len(plain.Removed), len(plain.Added), len(plain.Renamed))
+	}
+
+	d, err := w.DiffSnapshots(from, to, DetectRenames())
+	if err != nil {
+		t.Fatalf("DiffSnapshots failed: %v", err)
+	}
+	// d1.txt 与 da.txt 按路径顺序配对，db.txt 仍是新增
+	if len(d.Renamed) != 2 || d.Renamed[0].Path != dupA || d.Renamed[0].From != dup1 ||
+		d.Renamed[1].Path != moved || d.Renamed[1].From != old || d.Renamed[1].Kind != DiffRenamed {
+		t.Fatalf("Renamed = %+v", d.Renamed)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Path != gone || len(d.Added) != 2 || d.Added[0].Path != dupB {
+		t.Errorf("remaining removed=%+v added=%+v", d.Removed, d.Added)
+	}
+
+	moves, err := w.MovedFiles(from, to)
+	if err != nil || len(moves) != 2 || moves[1].From != old || moves[1].To != moved || moves[1].Size != 7 {
+		t.Errorf("MovedFiles = %+v, %v", moves, err)
+	}
+}
@@ -0,0 +1,142 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 重复错误的去重
+//
+// ErrorDedupWindow 大于0时，同一个(路径, 类别)的错误在窗口内只发送第一次(原样发送，errors.Is/As 照常可用)，
+// 之后的重复只计数；窗口结束时(在合并goroutine的 tick 中按 Debounce 粒度检查，或同一错误再次出现时)
+// 若有被折叠的重复，发送一个 *RepeatedError 汇总。跟踪的错误数最多 errorDedupCapacity 个，
+// 已满时新的错误不去重、直接发送，保证状态有界
+
+// errorDedupCapacity 是同时跟踪的不同错误数上限
+const errorDedupCapacity = 1024
+
+// RepeatedError 汇总去重窗口(ConfigWatcher.ErrorDedupWindow)内被折叠的重复错误
+//
+// Err 是窗口内第一次出现的错误(当时已单独发送)，Count 为窗口内的总次数(含第一次)，
+// errors.Is/As 按 Err 匹配
+type RepeatedError struct {
+	Err         error
+	Path        string // 错误涉及的路径，无法确定时为空
+	Count       int
+	First, Last time.Time // 第一次与最后一次出现的时间
+}
+
+// Error 实现 error 接口
+func (e *RepeatedError) Error() string {
+	return fmt.Sprintf("%v (repeated %d times between %s and %s)",
+		e.Err, e.Count, e.First.Format(time.RFC3339), e.Last.Format(time.RFC3339))
+}
+
+// Unwrap 返回第一次出现的错误
+func (e *RepeatedError) Unwrap() error {
+	return e.Err
+}
+
+// errorKey 是去重的键：错误涉及的路径与类别
+type errorKey struct {
+	path, kind string
+}
+
+// dedupEntry 是一个错误在当前窗口内的状态
+type dedupEntry struct {
+	err         error
+	count       int
+	first, last time.Time
+}
+
+// errorDedup 记录窗口内出现过的错误
+type errorDedup struct {
+	mu      sync.Mutex
+	entries map[errorKey]*dedupEntry
+	n       atomic.Int64 // len(entries)，避免没有跟踪的错误时加锁
+
+	folded atomic.Uint64 // 被折叠(未单独发送)的重复错误数
+}
+
+// keyOfError 返回 err 的去重键：路径取自 *fs.PathError、*HashError 或 *WatchError，
+// 类别为最外层错误的类型加上底层原因；没有路径的错误以完整消息为类别
+func keyOfError(err error) errorKey {
+	var pe *fs.PathError
+	var he *HashError
+	var we *WatchError
+	switch {
+	case errors.As(err, &pe):
+		return errorKey{path: pe.Path, kind: fmt.Sprintf("%T %s: %v", err, pe.Op, pe.Err)}
+	case errors.As(err, &he):
+		return errorKey{path: he.Path, kind: fmt.Sprintf("%T %v", err, he.Err)}
+	case errors.As(err, &we):
+		return errorKey{path: we.Path, kind: fmt.Sprintf("%T %v", err, we.Err)}
+	}
+	return errorKey{kind: fmt.Sprintf("%T %v", err, err)}
+}
+
+// dedupError 决定 err 是否发送：窗口内第一次出现时返回 true，重复时计数并返回 false；
+// 同一错误的上一个窗口已结束时返回其汇总(没有折叠的重复时为nil)
+func (w *Watcher) dedupError(err error, now time.Time) (emit bool, summary *RepeatedError) {
+	d := &w.errDedup
+	key := keyOfError(err)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.entries[key]; ok {
+		if now.Sub(e.first) < w.cfg.ErrorDedupWindow {
+			e.count++
+			e.last = now
+			d.folded.Add(1)
+			return false, nil
+		}
+		summary = e.summary(key)
+		delete(d.entries, key)
+	}
+	if len(d.entries) >= errorDedupCapacity {
+		d.n.Store(int64(len(d.entries)))
+		return true, summary
+	}
+	if d.entries == nil {
+		d.entries = make(map[errorKey]*dedupEntry)
+	}
+	d.entries[key] = &dedupEntry{err: err, count: 1, first: now, last: now}
+	d.n.Store(int64(len(d.entries)))
+	return true, summary
+}
+
+// summary 返回窗口内有折叠的重复时的汇总，否则返回nil
+func (e *dedupEntry) summary(key errorKey) *RepeatedError {
+	if e.count <= 1 {
+		return nil
+	}
+	return &RepeatedError{Err: e.err, Path: key.path, Count: e.count, First: e.first, Last: e.last}
+}
+
+// flushErrorDedup 结束已到期的窗口，发送其中有折叠重复的汇总；由合并goroutine在每个 tick 调用
+func (w *Watcher) flushErrorDedup(now time.Time) {
+	d := &w.errDedup
+	if d.n.Load() == 0 {
+		return
+	}
+	var summaries []*RepeatedError
+	d.mu.Lock()
+	for key, e := range d.entries {
+		if now.Sub(e.first) >= w.cfg.ErrorDedupWindow {
+			if s := e.summary(key); s != nil {
+				summaries = append(summaries, s)
+			}
+			delete(d.entries, key)
+		}
+	}
+	d.n.Store(int64(len(d.entries)))
+	d.mu.Unlock()
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].First.Before(summaries[j].First) })
+	for _, s := range summaries {
+		w.sendError(s)
+	}
+}
@@ -0,0 +1,105 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// drainErrors 读出 ErrorChan 中已缓冲的全部错误
+func drainErrors(w *Watcher) []error {
+	var errs []error
+	for {
+		select {
+		case err := <-w.ErrorChan:
+			errs = append(errs, err)
+		default:
+			return errs
+		}
+	}
+}
+
+// TestErrorDedup 测试持续失败的路径在窗口内只发送一次，窗口结束后发送一个带次数的汇总，其它路径不受影响
+func TestErrorDedup(t *testing.T) {
+	clock := &manualClock{}
+	clock.now.Store(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	w, err := NewWatcherWithOptions([]string{t.TempDir()}, WithFS(osFS{}, replaySource{}), WithClock(clock),
+		WithDisableEventChan(), WithErrorDedup(time.Minute))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	statErr := func(path string) error {
+		return fmt.Errorf("failed to stat %s: %w", path, &fs.PathError{Op: "lstat", Path: path, Err: syscall.EACCES})
+	}
+	for i := 0; i < 500; i++ {
+		emitted := w.emitError(statErr("/locked"))
+		if emitted != (i == 0) {
+			t.Fatalf("emitError #%d = %v; want only the first emitted", i, emitted)
+		}
+		clock.advance(100 * time.Millisecond)
+	}
+	w.emitError(statErr("/other"))
+	errs := drainErrors(w)
+	if len(errs) != 2 || !errors.Is(errs[0], syscall.EACCES) {
+		t.Fatalf("ErrorChan = %v; want the first /locked error and /other", errs)
+	}
+	if n := w.Stats().ErrorsDeduplicated; n != 499 {
+		t.Fatalf("ErrorsDeduplicated = %d; want 499", n)
+	}
+
+	// 窗口(从第一次出现算起)已过 50s，未到期时不发送汇总
+	w.flushErrorDedup(w.now())
+	if errs := drainErrors(w); len(errs) != 0 {
+		t.Fatalf("ErrorChan = %v before the window closed; want nothing", errs)
+	}
+	clock.advance(10 * time.Second)
+	w.flushErrorDedup(w.now())
+	errs = drainErrors(w)
+	var re *RepeatedError
+	if len(errs) != 1 || !errors.As(errs[0], &re) {
+		t.Fatalf("ErrorChan = %v; want one *RepeatedError", errs)
+	}
+	if re.Path != "/locked" || re.Count != 500 || re.Last.Sub(re.First) != 49900*time.Millisecond || !errors.Is(re, syscall.EACCES) {
+		t.Fatalf("summary = %+v; want 500 occurrences of /locked", re)
+	}
+
+	// 只出现一次的错误窗口结束时没有汇总；新窗口的第一次再次发送
+	clock.advance(time.Minute)
+	w.flushErrorDedup(w.now())
+	if errs := drainErrors(w); len(errs) != 0 || w.errDedup.n.Load() != 0 {
+		t.Fatalf("ErrorChan = %v; want no summary for a single error", errs)
+	}
+	if !w.emitError(statErr("/locked")) {
+		t.Fatal("first error of a new window was not emitted")
+	}
+}
+
+// TestErrorDedupBounded 测试跟踪的错误数达到上限后新的错误直接发送、不再跟踪
+func TestErrorDedupBounded(t *testing.T) {
+	w, err := NewWatcherWithOptions([]string{t.TempDir()}, WithFS(osFS{}, replaySource{}),
+		WithDisableEventChan(), WithErrorDedup(time.Hour))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	now := w.now()
+	for i := 0; i < errorDedupCapacity+10; i++ {
+		path := fmt.Sprintf("/p%d", i)
+		if emit, _ := w.dedupError(&fs.PathError{Op: "open", Path: path, Err: syscall.EACCES}, now); !emit {
+			t.Fatalf("first error for %s was folded", path)
+		}
+	}
+	if n := w.errDedup.n.Load(); n != errorDedupCapacity {
+		t.Fatalf("tracking %d errors; want %d", n, errorDedupCapacity)
+	}
+	untracked := &fs.PathError{Op: "open", Path: fmt.Sprintf("/p%d", errorDedupCapacity), Err: syscall.EACCES}
+	if emit, _ := w.dedupError(untracked, now); !emit {
+		t.Fatal("untracked error was folded; want it sent")
+	}
+}
@@ -0,0 +1,106 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+// 错误分类
+//
+// 本包返回或发送到 ErrorChan 的错误都可以用 errors.Is/errors.As 区分，错误消息仅供阅读，不应依赖其格式。
+//
+// 哨兵错误(errors.Is)：
+//   - ErrPathNotFound：路径不存在(Start 时监控根缺失、RehashFile 的路径不存在)，同时包装了底层的 fs.ErrNotExist
+//   - ErrPathIgnored：路径命中忽略规则(RehashFile、ApplyExternalChanges)
+//   - ErrOutsideRoots：路径不在任何监控根之下(ApplyExternalChanges，AllowOutsideRoots 时允许)
+//   - ErrWatchLimit：系统监控资源耗尽(inotify 监控数上限 ENOSPC、文件描述符上限 EMFILE/ENFILE)
+//   - ErrSnapshotNotFound：快照ID不存在(DiffSnapshots、TagSnapshot、SetSnapshotDescription、SnapshotStore.Get)
+//   - ErrInvalidConfig：配置或选项非法(NewWatcher、NewWatcherWithOptions)
+//   - ErrStopped：Watcher 已停止(WaitReady，Close 之后调用 RehashFile、ApplyExternalChanges 等修改状态的方法)
+//   - ErrRootLost：监控根被删除或移走(ErrorChan)
+//   - ErrAuditDropped：审计队列已满，记录被丢弃(ErrorChan)
+//   - ErrWatchBudget：已注册监控的目录数达到 MaxWatchedDirs，目录未被监控(*WatchError，见 WatchErrors 与 ErrorChan)
+//   - ErrReplayGap：审计日志的序号不连续或快照的父快照缺失(ReplayEvents)
+//   - ErrFileLocked：文件暂时被其它进程锁定(Windows 共享冲突)，哈希稍后重试，重试用尽后作为 *HashError 的底层错误
+//   - ErrEventOverflow：内核事件队列溢出，变更事件已丢失(*OverflowError，ErrorChan)
+//   - ErrTagNotFound：标签不存在(*TagNotFoundError，DriftFromTag)
+//   - ErrDegraded：事件风暴保护进入或退出降级模式(*DegradedMode，ErrorChan)
+//   - ErrMemberNotFound：WatcherGroup 中没有该名称的成员(WatcherGroup.Remove)
+//   - ErrInvalidManifest：清单格式错误或路径不是规范的相对路径(VerifyManifest)
+//   - ErrPathRewriteConflict：路径改写(PathRewrite)不可逆，多个原始路径改写为同一逻辑路径(*PathRewriteError，NewWatcher 与 ErrorChan)
+//   - ErrBrokenDeltaChain：DirStore 的增量记录链断裂，快照无法还原(*DeltaChainError，SnapshotStore.Get 与 ValidateStore)
+//   - ErrSeqUnavailable：请求的事件序号已被挤出回放缓冲或超过最新序号(*SeqUnavailableError，SubscribeFrom)
+//
+// 结构体错误(errors.As)：
+//   - *HashError：读取文件内容计算哈希失败(ErrorChan)
+//   - *WatchAddError(即 *WatchError)：单个目录注册监控失败，底层错误属于资源耗尽时同时匹配 ErrWatchLimit
+//   - *PartialWatchError：Start 时注册失败的目录汇总(FailOnPartialWatch 时由 Start 返回，否则发送到 ErrorChan)
+//   - *OverflowError：内核事件队列溢出，包含受影响的监控根以及是否已安排重扫(RescanOnOverflow)
+//   - *TagNotFoundError：标签不存在，包含标签名
+//   - *DegradedMode：事件风暴保护的降级通知，包含触发时的速率与(恢复时)降级持续的时间
+//   - *PathRewriteError：往返校验失败的路径改写，包含原始路径、改写结果与逆变换的结果
+//   - *DeltaChainError：无法还原的增量记录，包含从要读取的快照回溯到出问题的记录经过的链
+//   - *AccessDeniedError：目录在运行中变得不可读，其子树暂停更新直到恢复访问，errors.Is 匹配 fs.ErrPermission(ErrorChan)
+//   - *SeqUnavailableError：SubscribeFrom 无法续接，包含请求的序号、回放缓冲中最早的序号与最新序号
+//   - *RepeatedError：去重窗口(ErrorDedupWindow)内被折叠的重复错误的汇总，包含次数，errors.Is/As 按第一次出现的错误匹配(ErrorChan)
+//   - *GroupError/*MemberError：WatcherGroup.Start/Close 中失败的成员及其底层错误
+//   - ValidationIssue：快照历史的一致性问题(ValidateStoreOnStart 时由 Start 发送到 ErrorChan)
+var (
+	ErrPathNotFound     = errors.New("path not found")
+	ErrPathIgnored      = errors.New("path is ignored")
+	ErrOutsideRoots     = errors.New("path is outside the watch roots")
+	ErrWatchLimit       = errors.New("watch resource limit reached")
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	ErrInvalidConfig    = errors.New("invalid watcher configuration")
+	ErrStopped          = errors.New("watcher stopped")
+	ErrRootLost         = errors.New("watch root disappeared")
+	ErrAuditDropped     = errors.New("audit record dropped")
+	ErrReplayGap        = errors.New("audit log has gaps")
+	ErrWatchBudget      = errors.New("watched directory limit reached")
+	ErrEventOverflow    = errors.New("event queue overflow")
+	ErrFileLocked       = errors.New("file is locked by another process")
+	ErrMemberNotFound   = errors.New("watcher group member not found")
+	ErrDegraded         = errors.New("degraded mode")
+	ErrTagNotFound      = errors.New("tag not found")
+	ErrInvalidManifest  = errors.New("invalid manifest")
+	ErrSeqUnavailable   = errors.New("event sequence no longer available")
+)
+
+// HashError 表示读取文件内容计算哈希失败，该文件在快照中的记录见 HashErrorPolicy
+type HashError struct {
+	Path string
+	Err  error
+}
+
+// Error 实现 error 接口
+func (e *HashError) Error() string {
+	return fmt.Sprintf("file %s is unreadable: %v", e.Path, e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *HashError) Unwrap() error {
+	return e.Err
+}
+
+// WatchAddError 是 WatchError 的别名，表示单个目录注册监控失败
+type WatchAddError = WatchError
+
+// isWatchLimit 判断错误是否为系统监控资源耗尽
+func isWatchLimit(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
+
+// errSnapshotNotFound 构造指定快照ID的 ErrSnapshotNotFound
+func errSnapshotNotFound(id string) error {
+	return fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+}
+
+// wrapPathErr 以 msg 为前缀包装 err，err 表示路径不存在时同时包装 ErrPathNotFound
+func wrapPathErr(msg string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w: %w", msg, ErrPathNotFound, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
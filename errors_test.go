@@ -0,0 +1,122 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestErrorsConstruction 测试 NewWatcher/NewWatcherWithOptions/Start 的错误分类
+func TestErrorsConstruction(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	_ = os.Mkdir(sub, 0755)
+
+	_, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root, sub}, RejectOverlappingRoots: true})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("overlapping roots: got %v", err)
+	}
+	_, err = NewWatcherWithOptions([]string{root}, WithWorkerCount(0))
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("invalid option: got %v", err)
+	}
+
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{filepath.Join(root, "missing")}})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+	err = w.Start()
+	if !errors.Is(err, ErrPathNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing root: got %v", err)
+	}
+}
+
+// TestErrorsWatchLimit 测试注册监控时资源耗尽的错误分类
+func TestErrorsWatchLimit(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root}, FailOnPartialWatch: true})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+	w.addWatchFn = func(string) error { return syscall.ENOSPC }
+
+	err = w.Start()
+	var perr *PartialWatchError
+	var werr *WatchAddError
+	if !errors.Is(err, ErrWatchLimit) || !errors.As(err, &perr) || !errors.As(err, &werr) || werr.Path != root {
+		t.Errorf("got %v", err)
+	}
+	if errors.Is(&WatchError{Path: root, Err: syscall.EACCES}, ErrWatchLimit) {
+		t.Error("EACCES should not match ErrWatchLimit")
+	}
+}
+
+// TestErrorsSnapshots 测试快照相关接口的错误分类
+func TestErrorsSnapshots(t *testing.T) {
+	w := newTestWatcher(t, t.TempDir())
+	id := w.GetCurrentSnapshot().ID
+	if _, err := w.DiffSnapshots(id, "nope"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("DiffSnapshots: got %v", err)
+	}
+	if err := w.TagSnapshot("t", "nope"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("TagSnapshot: got %v", err)
+	}
+	if err := w.SetSnapshotDescription("nope", "d"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("SetSnapshotDescription: got %v", err)
+	}
+}
+
+// TestErrorsRehash 测试 RehashFile 与哈希失败的错误分类
+func TestErrorsRehash(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root}, IgnorePatterns: []string{"*.tmp"}})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+
+	if _, _, err := w.RehashFile("x.tmp"); !errors.Is(err, ErrPathIgnored) {
+		t.Errorf("ignored: got %v", err)
+	}
+	if _, _, err := w.RehashFile(filepath.Join(root, "missing")); !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("missing: got %v", err)
+	}
+
+	// stat 之后文件被删除，读取内容失败
+	file := filepath.Join(root, "gone.txt")
+	_ = os.WriteFile(file, []byte("x"), 0644)
+	fi, _ := os.Stat(file)
+	_ = os.Remove(file)
+	if res := w.hashFor(file, fi, nil, nil); res.state != HashStateUnreadable {
+		t.Errorf("state = %v", res.state)
+	}
+	var herr *HashError
+	if e := <-w.ErrorChan; !errors.As(e, &herr) || herr.Path != file || !errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("expected *HashError, got %v", e)
+	}
+}
+
+// TestErrorsRuntime 测试运行期间发送到 ErrorChan 及停止后的错误分类
+func TestErrorsRuntime(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, root)
+	w.markRootLost(root, map[string]bool{})
+	if e := <-w.ErrorChan; !errors.Is(e, ErrRootLost) {
+		t.Errorf("root lost: got %v", e)
+	}
+
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root}, ScanOnStart: true})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	w.Stop()
+	if err := w.WaitReady(context.Background()); !errors.Is(err, ErrStopped) {
+		t.Errorf("WaitReady: got %v", err)
+	}
+}
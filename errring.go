@@ -0,0 +1,53 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+)
+
+// recentErrorCap 是最近错误环形缓冲的容量
+const recentErrorCap = 64
+
+// RecentError 是最近发生的一个错误
+type RecentError struct {
+	Time time.Time
+	Err  error
+}
+
+// errorRing 保存最近 recentErrorCap 个错误(无论 ErrorChan 是否接收)，用于诊断
+type errorRing struct {
+	mu   sync.Mutex
+	buf  [recentErrorCap]RecentError
+	next int // 下一个写入位置
+	n    int // 已保存的数量
+}
+
+// add 记录 t 时刻发生的错误，满时覆盖最旧的
+func (r *errorRing) add(t time.Time, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf[r.next] = RecentError{Time: t, Err: err}
+	r.next = (r.next + 1) % recentErrorCap
+	if r.n < recentErrorCap {
+		r.n++
+	}
+}
+
+// list 按时间从旧到新返回已保存的错误
+func (r *errorRing) list() []RecentError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]RecentError, 0, r.n)
+	start := (r.next - r.n + recentErrorCap) % recentErrorCap
+	for i := 0; i < r.n; i++ {
+		out = append(out, r.buf[(start+i)%recentErrorCap])
+	}
+	return out
+}
+
+// RecentErrors 返回最近发生的错误(最多64个，从旧到新)，包括因 ErrorChan 已满而被丢弃的
+//
+// 并发安全
+func (w *Watcher) RecentErrors() []RecentError {
+	return w.recentErrs.list()
+}
@@ -0,0 +1,182 @@
+package watcher
+
+import (
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventOp 表示文件事件的操作类型，与底层监控实现(fsnotify)无关
+//
+// 防抖合并后一个事件可能包含多种操作(如 OpCreate|OpWrite)，因此以位掩码表示；
+// 一般使用 Has 或 IsCreate/IsDelete/IsModify 等判断方法，而不是直接比较
+type EventOp uint32
+
+const (
+	// OpCreate 文件或目录被创建
+	OpCreate EventOp = 1 << iota
+	// OpWrite 文件内容被写入
+	OpWrite
+	// OpRemove 文件或目录被删除
+	OpRemove
+	// OpRename 文件或目录被重命名/移走(该路径下已不存在)
+	OpRename
+	// OpChmod 元信息(权限、时间戳等)变化
+	OpChmod
+	// OpMove 文件或目录被移动到该路径(预留给能关联重命名两端的监控实现)
+	OpMove
+	// OpReconcileAdd 对账时发现新增(期间没有收到对应的文件系统事件)
+	OpReconcileAdd
+	// OpReconcileRemove 对账时发现删除(期间没有收到对应的文件系统事件)
+	OpReconcileRemove
+	// OpReconcileWrite 对账时发现内容或元信息变化(期间没有收到对应的文件系统事件)
+	OpReconcileWrite
+	// OpReconcileSummary 一次对账的汇总事件(差异超过 ReconcileSummaryThreshold 时代替逐个路径的事件)，
+	// FilePath 为对账的监控根，OldMeta/NewMeta 为nil，差异需通过 DiffSnapshots(父快照, NewSnap) 获取
+	OpReconcileSummary
+	// OpScan 初始扫描(ScanOnStart)发现的条目，只在开启 ScanEvents 时发送，OldMeta 为nil；
+	// 这些事件都在同一个基线快照上，随后是该快照的 OpBaseline 事件
+	OpScan
+	// OpBaseline 初始扫描的基线快照已提交，每次 Start 最多一个：FilePath 为空，NewSnap(或 SnapID)为基线快照，
+	// Entries 为扫描得到的条目数。推荐的消费方式：等到该事件后整体读取基线快照(NewSnap 或 GetSnapshotByID)批量导入，
+	// 再处理 Seq 更大的事件(扫描期间的变更在基线之后回放，都在该事件之后)
+	OpBaseline
+	// OpExternal 变更由应用通过 ApplyExternalChanges 提交(文件系统监控看不到)，总是与 OpCreate/OpWrite/OpRemove 之一同时出现
+	OpExternal
+)
+
+// eventOpNames 按位顺序排列的名称，String 依此顺序输出
+var eventOpNames = []struct {
+	op   EventOp
+	name string
+}{
+	{OpCreate, "CREATE"},
+	{OpWrite, "WRITE"},
+	{OpRemove, "REMOVE"},
+	{OpRename, "RENAME"},
+	{OpChmod, "CHMOD"},
+	{OpMove, "MOVE"},
+	{OpReconcileAdd, "RECONCILE_ADD"},
+	{OpReconcileRemove, "RECONCILE_REMOVE"},
+	{OpReconcileWrite, "RECONCILE_WRITE"},
+	{OpReconcileSummary, "RECONCILE_SUMMARY"},
+	{OpScan, "SCAN"},
+	{OpBaseline, "BASELINE"},
+	{OpExternal, "EXTERNAL"},
+}
+
+// String 返回以 "|" 连接的操作名称(如 "CREATE|WRITE")，与 fsnotify.Op.String() 的格式一致
+func (op EventOp) String() string {
+	var b strings.Builder
+	for _, n := range eventOpNames {
+		if op&n.op == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('|')
+		}
+		b.WriteString(n.name)
+	}
+	if b.Len() == 0 {
+		return "[no events]"
+	}
+	return b.String()
+}
+
+// Has 判断是否包含 h 中的任一操作
+func (op EventOp) Has(h EventOp) bool {
+	return op&h != 0
+}
+
+// IsCreate 判断路径是否(重新)出现：创建、移入或对账新增
+func (op EventOp) IsCreate() bool {
+	return op.Has(OpCreate | OpMove | OpReconcileAdd | OpScan)
+}
+
+// IsDelete 判断路径是否消失：删除、重命名移走或对账删除
+func (op EventOp) IsDelete() bool {
+	return op.Has(OpRemove | OpRename | OpReconcileRemove)
+}
+
+// IsModify 判断是否为内容写入(含对账发现的变化)
+func (op EventOp) IsModify() bool {
+	return op.Has(OpWrite | OpReconcileWrite)
+}
+
+// IsMetadata 判断是否为元信息变化
+func (op EventOp) IsMetadata() bool {
+	return op.Has(OpChmod)
+}
+
+// IsReconcile 判断事件是否由对账产生而非直接来自文件系统通知
+func (op EventOp) IsReconcile() bool {
+	return op.Has(OpReconcileAdd | OpReconcileRemove | OpReconcileWrite | OpReconcileSummary)
+}
+
+// IsScan 判断事件是否来自初始扫描(OpScan 或 OpBaseline)而非扫描之后的变更
+func (op EventOp) IsScan() bool {
+	return op.Has(OpScan | OpBaseline)
+}
+
+// IsExternal 判断事件是否由 ApplyExternalChanges 提交
+func (op EventOp) IsExternal() bool {
+	return op.Has(OpExternal)
+}
+
+// ParseEventOp 解析 String() 的输出(如 "CREATE|WRITE")，未知的名称被忽略
+func ParseEventOp(s string) EventOp {
+	var op EventOp
+	for _, part := range strings.Split(s, "|") {
+		for _, n := range eventOpNames {
+			if part == n.name {
+				op |= n.op
+			}
+		}
+	}
+	return op
+}
+
+// eventOpFromFsnotify 把 fsnotify.Op 转换为 EventOp
+func eventOpFromFsnotify(op fsnotify.Op) EventOp {
+	var out EventOp
+	if op.Has(fsnotify.Create) {
+		out |= OpCreate
+	}
+	if op.Has(fsnotify.Write) {
+		out |= OpWrite
+	}
+	if op.Has(fsnotify.Remove) {
+		out |= OpRemove
+	}
+	if op.Has(fsnotify.Rename) {
+		out |= OpRename
+	}
+	if op.Has(fsnotify.Chmod) {
+		out |= OpChmod
+	}
+	return out
+}
+
+// fsnotifyOp 把 EventOp 尽量转换为 fsnotify.Op(用于填充已弃用的 FileEvent.Op)
+//
+// OpMove、OpReconcileAdd 与 OpScan 视为 Create，OpReconcileRemove 视为 Remove，OpReconcileWrite 视为 Write，
+// OpReconcileSummary、OpBaseline 与 OpExternal 没有对应的操作
+func (op EventOp) fsnotifyOp() fsnotify.Op {
+	var out fsnotify.Op
+	if op.IsCreate() {
+		out |= fsnotify.Create
+	}
+	if op.IsModify() {
+		out |= fsnotify.Write
+	}
+	if op.Has(OpRemove | OpReconcileRemove) {
+		out |= fsnotify.Remove
+	}
+	if op.Has(OpRename) {
+		out |= fsnotify.Rename
+	}
+	if op.Has(OpChmod) {
+		out |= fsnotify.Chmod
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package watcher
+
+import (
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestEventOpString 测试名称输出与解析往返
+func TestEventOpString(t *testing.T) {
+	cases := map[EventOp]string{
+		OpCreate:                    "CREATE",
+		OpCreate | OpWrite:          "CREATE|WRITE",
+		OpReconcileRemove | OpChmod: "CHMOD|RECONCILE_REMOVE",
+		0:                           "[no events]",
+	}
+	for op, want := range cases {
+		if got := op.String(); got != want {
+			t.Errorf("%d.String() = %q, want %q", uint32(op), got, want)
+		}
+		if op != 0 && ParseEventOp(want) != op {
+			t.Errorf("ParseEventOp(%q) = %v", want, ParseEventOp(want))
+		}
+	}
+	// 与 fsnotify 的格式一致，审计日志等既有输出保持不变
+	raw := fsnotify.Create | fsnotify.Write | fsnotify.Chmod
+	if got := eventOpFromFsnotify(raw).String(); got != raw.String() {
+		t.Errorf("String() = %q, fsnotify = %q", got, raw.String())
+	}
+}
+
+// TestEventOpPredicates 测试判断方法与 fsnotify 的双向转换
+func TestEventOpPredicates(t *testing.T) {
+	op := eventOpFromFsnotify(fsnotify.Create | fsnotify.Write)
+	if !op.IsCreate() || !op.IsModify() || op.IsDelete() || op.IsMetadata() || op.IsReconcile() {
+		t.Errorf("unexpected predicates for %v", op)
+	}
+	if op := eventOpFromFsnotify(fsnotify.Rename); !op.IsDelete() || op.IsCreate() {
+		t.Errorf("rename should be a delete: %v", op)
+	}
+	if op := OpReconcileAdd; !op.IsCreate() || !op.IsReconcile() || op.fsnotifyOp() != fsnotify.Create {
+		t.Errorf("unexpected reconcile add: %v", op)
+	}
+	if op := OpReconcileRemove; !op.IsDelete() || op.fsnotifyOp() != fsnotify.Remove {
+		t.Errorf("unexpected reconcile remove: %v", op)
+	}
+	if op := OpReconcileWrite; !op.IsModify() || !op.IsReconcile() || op.IsCreate() || op.fsnotifyOp() != fsnotify.Write {
+		t.Errorf("unexpected reconcile write: %v", op)
+	}
+	if op := OpReconcileSummary; !op.IsReconcile() || op.IsModify() || op.fsnotifyOp() != 0 {
+		t.Errorf("unexpected reconcile summary: %v", op)
+	}
+	if op := OpScan; !op.IsCreate() || !op.IsScan() || op.IsReconcile() || op.fsnotifyOp() != fsnotify.Create || ParseEventOp(op.String()) != op {
+		t.Errorf("unexpected scan: %v", op)
+	}
+	if op := OpBaseline; !op.IsScan() || op.IsCreate() || op.fsnotifyOp() != 0 || op.String() != "BASELINE" {
+		t.Errorf("unexpected baseline: %v", op)
+	}
+	if op := OpExternal | OpRemove; !op.IsExternal() || !op.IsDelete() || op.fsnotifyOp() != fsnotify.Remove || op.String() != "REMOVE|EXTERNAL" {
+		t.Errorf("unexpected external remove: %v", op)
+	}
+	all := fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename | fsnotify.Chmod
+	if got := eventOpFromFsnotify(all).fsnotifyOp(); got != all {
+		t.Errorf("round trip = %v, want %v", got, all)
+	}
+}
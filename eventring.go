@@ -0,0 +1,105 @@
+package watcher
+
+import "fmt"
+
+// SeqUnavailableError 表示 SubscribeFrom 请求的位置无法续接：之后的事件已被挤出回放缓冲(ReplayBuffer)，
+// 或位置超过了最新的事件序号(如 Watcher 重启后序号从头开始)
+//
+// 调用方应改为用 Subscribe 重新订阅，并以快照差异(DiffSnapshots)补上遗漏的变更
+type SeqUnavailableError struct {
+	Seq    uint64 // 请求的位置
+	Oldest uint64 // 回放缓冲中最早的事件序号，缓冲为空时为 Last+1
+	Last   uint64 // 最近一次分配的事件序号
+}
+
+// Error 实现 error 接口
+func (e *SeqUnavailableError) Error() string {
+	if e.Seq > e.Last {
+		return fmt.Sprintf("event seq %d is ahead of the last event %d", e.Seq, e.Last)
+	}
+	return fmt.Sprintf("events after seq %d were evicted from the replay buffer (oldest available %d)", e.Seq, e.Oldest)
+}
+
+// Is 使 errors.Is(err, ErrSeqUnavailable) 成立
+func (e *SeqUnavailableError) Is(target error) bool {
+	return target == ErrSeqUnavailable
+}
+
+// eventRing 保存最近发送的至多 cap(buf) 个事件，供 SubscribeFrom 回放，由 subscribers.mu 保护
+//
+// 保存的副本不引用快照(NewSnap 以 SnapID 代替，同 EventSnapshotIDOnly)，内存占用只与容量有关，
+// 不会让已删除或已换出的快照留在内存中
+type eventRing struct {
+	buf  []FileEvent
+	next int // 下一个写入位置
+	n    int // 已保存的数量
+}
+
+// add 记录事件，满时覆盖最旧的
+func (r *eventRing) add(ev FileEvent) {
+	if len(r.buf) == 0 {
+		return
+	}
+	if ev.NewSnap != nil {
+		ev.SnapID = ev.NewSnap.ID
+		ev.NewSnap = nil
+	}
+	r.buf[r.next] = ev
+	r.next = (r.next + 1) % len(r.buf)
+	if r.n < len(r.buf) {
+		r.n++
+	}
+}
+
+// oldest 返回最早的事件序号，为空时返回 last+1
+func (r *eventRing) oldest(last uint64) uint64 {
+	if r.n == 0 {
+		return last + 1
+	}
+	return r.buf[(r.next-r.n+len(r.buf))%len(r.buf)].Seq
+}
+
+// after 按序号从小到大返回 Seq > seq 的事件
+func (r *eventRing) after(seq uint64) []FileEvent {
+	if r.n == 0 {
+		return nil
+	}
+	var out []FileEvent
+	start := (r.next - r.n + len(r.buf)) % len(r.buf)
+	for i := 0; i < r.n; i++ {
+		if ev := r.buf[(start+i)%len(r.buf)]; ev.Seq > seq {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// SubscribeFrom 创建一个从序号 seq 之后续接的订阅：先投递回放缓冲中 Seq > seq 的事件，再继续投递新事件，不重复也不遗漏
+//
+// 用于消费者断线重连：seq 为已处理的最后一个事件的序号(StartSeq 为 seq)。通道容量为 buffer(<=0 时为 DefaultSubscriptionBuffer)
+// 加上回放的事件数，回放的事件不会因缓冲已满而丢弃。回放的事件不引用快照(NewSnap 为nil，快照ID在 SnapID 中)。
+// seq 之后的事件已被挤出回放缓冲(见 WithReplayBuffer，未配置时只能从 LastSeq 续接)或 seq 超过 LastSeq 时
+// 返回 *SeqUnavailableError(errors.Is 匹配 ErrSeqUnavailable，Oldest 为最早可续接的事件)，此时应改用快照差异重新同步。
+// Watcher 已 Stop 时仍会投递回放的事件，之后通道关闭
+// 并发安全
+func (w *Watcher) SubscribeFrom(seq uint64, buffer int) (*Subscription, error) {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+	w.subs.mu.Lock()
+	defer w.subs.mu.Unlock()
+	last := w.subs.seq
+	oldest := w.subs.ring.oldest(last)
+	if seq > last || seq+1 < oldest {
+		return nil, &SeqUnavailableError{Seq: seq, Oldest: oldest, Last: last}
+	}
+
+	replay := w.subs.ring.after(seq)
+	ch := make(chan FileEvent, buffer+len(replay))
+	for _, ev := range replay {
+		ch <- ev
+	}
+	s := &Subscription{C: ch, StartSeq: seq, w: w, ch: ch}
+	w.subs.addLocked(s)
+	return s, nil
+}
@@ -0,0 +1,94 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestSubscribeFrom 测试从回放缓冲续接、缓冲大小上限、已挤出或超前的位置返回 *SeqUnavailableError
+func TestSubscribeFrom(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithReplayBuffer(3), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	change := func(i int) {
+		p := filepath.Join(root, fmt.Sprintf("f%d.txt", i))
+		_ = os.WriteFile(p, []byte("x"), 0644)
+		w.handleFileChange(w.keyOf(p), fsnotify.Create)
+	}
+	for i := 1; i <= 5; i++ {
+		change(i)
+	}
+	if n := w.Stats().ReplayBuffered; n != 3 {
+		t.Errorf("ReplayBuffered = %d; want the configured 3", n)
+	}
+
+	sub, err := w.SubscribeFrom(3, 1)
+	if err != nil {
+		t.Fatalf("SubscribeFrom(3) failed: %v", err)
+	}
+	defer sub.Close()
+	change(6)
+	for want := uint64(4); want <= 6; want++ {
+		ev := <-sub.C
+		if ev.Seq != want {
+			t.Fatalf("Seq = %d; want %d", ev.Seq, want)
+		}
+		if want < 6 && (ev.NewSnap != nil || ev.SnapID == "" || ev.SnapshotID() == "") {
+			t.Errorf("replayed event %d should carry only the snapshot ID", ev.Seq)
+		}
+	}
+	if sub.StartSeq != 3 || sub.Dropped() != 0 {
+		t.Errorf("StartSeq = %d, Dropped = %d; want 3, 0", sub.StartSeq, sub.Dropped())
+	}
+
+	// 缓冲中是 4..6：从 3 可以续接，从 2 起的事件 3 已被挤出
+	for seq, oldest := range map[uint64]uint64{2: 4, 99: 4} {
+		_, err := w.SubscribeFrom(seq, 0)
+		var se *SeqUnavailableError
+		if !errors.Is(err, ErrSeqUnavailable) || !errors.As(err, &se) || se.Oldest != oldest || se.Last != 6 {
+			t.Errorf("SubscribeFrom(%d) = %v; want *SeqUnavailableError with oldest %d", seq, err, oldest)
+		}
+	}
+	if s, err := w.SubscribeFrom(w.LastSeq(), 0); err != nil || len(s.C) != 0 {
+		t.Errorf("SubscribeFrom(LastSeq) = %v; want a live subscription", err)
+	}
+
+	// Stop 之后仍可取回缓冲中的事件
+	_ = w.Close()
+	s, err := w.SubscribeFrom(5, 0)
+	if err != nil {
+		t.Fatalf("SubscribeFrom after Close failed: %v", err)
+	}
+	if ev := <-s.C; ev.Seq != 6 {
+		t.Errorf("Seq = %d; want 6", ev.Seq)
+	}
+	if _, ok := <-s.C; ok {
+		t.Error("channel should be closed after the replay")
+	}
+}
+
+// TestSubscribeFromWithoutBuffer 测试未配置 ReplayBuffer 时只能从最新序号续接
+func TestSubscribeFromWithoutBuffer(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, root)
+	p := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(p, []byte("a"), 0644)
+	w.handleFileChange(w.keyOf(p), fsnotify.Create)
+	if _, err := w.SubscribeFrom(0, 0); !errors.Is(err, ErrSeqUnavailable) {
+		t.Errorf("SubscribeFrom(0) = %v; want ErrSeqUnavailable", err)
+	}
+	if _, err := w.SubscribeFrom(1, 0); err != nil {
+		t.Errorf("SubscribeFrom(LastSeq) = %v", err)
+	}
+	if n := w.Stats().ReplayBuffered; n != 0 {
+		t.Errorf("ReplayBuffered = %d; want 0", n)
+	}
+}
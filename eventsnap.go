@@ -0,0 +1,48 @@
+package watcher
+
+import "fmt"
+
+// EventSnapshotMode 决定事件携带快照的方式
+//
+// 作用于 EventChan 与订阅，事件的 JSON 序列化随之只输出快照ID或摘要；审计日志不受影响
+type EventSnapshotMode int
+
+const (
+	// EventSnapshotFull 事件的 NewSnap 指向完整快照(默认，与早期版本一致)
+	EventSnapshotFull EventSnapshotMode = iota
+	// EventSnapshotIDOnly 事件的 NewSnap 为nil，只在 SnapID 中给出快照ID，需要时用 GetSnapshotByID 取回快照(推荐)
+	EventSnapshotIDOnly
+	// EventSnapshotSummary 同 EventSnapshotIDOnly，另在 SnapSummary 中给出快照摘要(父快照、文件数、RootHash 等)
+	EventSnapshotSummary
+)
+
+// String 返回模式的可读名称
+func (m EventSnapshotMode) String() string {
+	switch m {
+	case EventSnapshotFull:
+		return "Full"
+	case EventSnapshotIDOnly:
+		return "IDOnly"
+	case EventSnapshotSummary:
+		return "Summary"
+	}
+	return fmt.Sprintf("EventSnapshotMode(%d)", int(m))
+}
+
+// valid 报告 m 是否为已定义的模式
+func (m EventSnapshotMode) valid() bool {
+	return m >= EventSnapshotFull && m <= EventSnapshotSummary
+}
+
+// applySnapshotMode 按 EventSnapshotMode 把事件的 NewSnap 替换为快照ID或摘要
+func (w *Watcher) applySnapshotMode(ev *FileEvent) {
+	if w.cfg.EventSnapshotMode == EventSnapshotFull || ev.NewSnap == nil {
+		return
+	}
+	ev.SnapID = ev.NewSnap.ID
+	if w.cfg.EventSnapshotMode == EventSnapshotSummary {
+		s := ev.NewSnap.Summary()
+		ev.SnapSummary = &s
+	}
+	ev.NewSnap = nil
+}
@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestEventSnapshotMode 测试 IDOnly/Summary 模式下事件不携带完整快照，快照ID与摘要正确，JSON 不含文件表
+func TestEventSnapshotMode(t *testing.T) {
+	for _, mode := range []EventSnapshotMode{EventSnapshotIDOnly, EventSnapshotSummary} {
+		t.Run(mode.String(), func(t *testing.T) {
+			root := t.TempDir()
+			w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithEventSnapshotMode(mode))
+			if err != nil {
+				t.Fatalf("NewWatcherWithOptions failed: %v", err)
+			}
+			t.Cleanup(func() { _ = w.Close() })
+			sub := w.Subscribe(4)
+			defer sub.Close()
+
+			file := w.keyOf(filepath.Join(root, "a.txt"))
+			_ = os.WriteFile(file, []byte("hello"), 0644)
+			w.handleFileChange(file, fsnotify.Create)
+			cur := w.GetCurrentSnapshot()
+
+			for _, ev := range []FileEvent{<-w.EventChan, <-sub.C} {
+				if ev.NewSnap != nil || ev.SnapID != cur.ID || ev.SnapshotID() != cur.ID {
+					t.Fatalf("event NewSnap = %v, SnapID = %q; want only the id %q", ev.NewSnap, ev.SnapID, cur.ID)
+				}
+				if got := ev.SnapSummary != nil; got != (mode == EventSnapshotSummary) {
+					t.Fatalf("SnapSummary = %+v in mode %v", ev.SnapSummary, mode)
+				}
+				if mode == EventSnapshotSummary && (ev.SnapSummary.FileCount != cur.FileCount() || ev.SnapSummary.ParentIDs[0] != cur.ParentIDs[0]) {
+					t.Fatalf("SnapSummary = %+v; want the summary of %v", ev.SnapSummary, cur)
+				}
+				data, err := json.Marshal(ev.WithFiles())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Contains(string(data), `"files"`) || !strings.Contains(string(data), cur.ID) {
+					t.Fatalf("event JSON = %s; want the snapshot id without files", data)
+				}
+				if got := strings.Contains(string(data), `"file_count"`); got != (mode == EventSnapshotSummary) {
+					t.Fatalf("event JSON = %s; summary present = %v in mode %v", data, got, mode)
+				}
+			}
+		})
+	}
+}
+
+// TestEventSnapshotModeInvalid 测试未定义的模式使 NewWatcher 返回 ErrInvalidConfig
+func TestEventSnapshotModeInvalid(t *testing.T) {
+	_, err := NewWatcher(ConfigWatcher{WatchPaths: []string{t.TempDir()}, EventSnapshotMode: EventSnapshotMode(7)})
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "EventSnapshotMode(7)") {
+		t.Fatalf("NewWatcher error = %v; want invalid mode", err)
+	}
+}
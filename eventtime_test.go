@@ -0,0 +1,62 @@
+package watcher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestEventTimestamps 测试事件的 FirstSeen 取合并窗口内第一个底层事件到达的时间，Processed 为处理完成的时间，
+// 两者之差计入 Stats().EventLatency，并写入审计记录与 JSON
+func TestEventTimestamps(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+	clock := &manualClock{}
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock.now.Store(t0.UnixNano())
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithClock(clock), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	sub := w.Subscribe(4)
+
+	w.handleFsEvent(fsnotify.Event{Name: file, Op: fsnotify.Create})
+	clock.advance(30 * time.Millisecond)
+	w.handleFsEvent(fsnotify.Event{Name: file, Op: fsnotify.Write})
+	for len(w.aggChan) > 0 {
+		w.mergeAgg(<-w.aggChan)
+	}
+	clock.advance(20 * time.Millisecond)
+	w.flushAgg(true)
+	w.workerWG.Wait()
+
+	ev := <-sub.C
+	if !ev.FirstSeen.Equal(t0) || !ev.Processed.Equal(t0.Add(50*time.Millisecond)) {
+		t.Fatalf("FirstSeen = %v, Processed = %v; want %v and 50ms later", ev.FirstSeen, ev.Processed, t0)
+	}
+	if h := w.Stats().EventLatency; h.Count != 1 || h.Max != 50*time.Millisecond {
+		t.Errorf("EventLatency = %+v; want one 50ms observation", h)
+	}
+	rec := w.auditRecord(&ev)
+	if !rec.FirstSeen.Equal(ev.FirstSeen) || !rec.Processed.Equal(ev.Processed) {
+		t.Errorf("audit record = %+v; want both timestamps", rec)
+	}
+	if back := rec.Event(); !back.FirstSeen.Equal(t0) {
+		t.Errorf("replayed event FirstSeen = %v", back.FirstSeen)
+	}
+	if data, _ := ev.MarshalJSON(); !bytes.Contains(data, []byte(`"first_seen":"2024-01-01T00:00:00Z"`)) {
+		t.Errorf("JSON = %s; want first_seen", data)
+	}
+
+	// 不经合并表的事件 FirstSeen 与 Processed 相同
+	w.emitFileEvent(file, fsnotify.Write, w.commitSnapshot("rehash", nil, file))
+	if ev := <-sub.C; ev.FirstSeen.IsZero() || !ev.FirstSeen.Equal(ev.Processed) {
+		t.Errorf("direct event FirstSeen = %v, Processed = %v; want equal", ev.FirstSeen, ev.Processed)
+	}
+}
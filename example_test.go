@@ -6,8 +6,6 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/fsnotify/fsnotify"
 )
 
 // ExampleWatcher 展示最简使用场景
@@ -75,11 +73,11 @@ selectLoop:
 				relPath = "./" + relPath
 			}
 			// 简化事件类型，只显示主要操作
-			op := evt.Op
-			if op&fsnotify.Create == fsnotify.Create {
-				op = fsnotify.Create
-			} else if op&fsnotify.Write == fsnotify.Write {
-				op = fsnotify.Write
+			op := evt.Kind
+			if op.Has(OpCreate) {
+				op = OpCreate
+			} else if op.Has(OpWrite) {
+				op = OpWrite
 			}
 			fmt.Printf("Event: %s %s\n", op.String(), relPath)
 		default:
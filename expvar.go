@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// expvarMu 保证 "检查名称是否已注册 + 注册" 是原子的(expvar.Publish 遇到重名会 panic)
+var expvarMu sync.Mutex
+
+// PublishExpvar 把内部统计注册到 expvar(即 /debug/vars)，无需引入 Prometheus
+//
+// 注册的变量(均以 prefix 为前缀，便于多个 Watcher 共存)：
+//
+//	<prefix>.stats            Stats() 的完整结果
+//	<prefix>.queues           合并通道/EventChan 的长度与容量、在途worker数
+//	<prefix>.current_snapshot 当前快照ID
+//
+// 所有值都是 expvar.Func，在读取时实时计算
+// 若该前缀已被注册(无论是否由本方法注册)，返回错误且不做任何修改
+// expvar 不支持注销，变量在进程生命周期内一直存在
+func (w *Watcher) PublishExpvar(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("expvar prefix must not be empty")
+	}
+	vars := map[string]expvar.Func{
+		prefix + ".stats": func() any {
+			return w.Stats()
+		},
+		prefix + ".queues": func() any {
+			st := w.Stats()
+			return map[string]int{
+				"agg_len":           st.AggChanLen,
+				"agg_cap":           st.AggChanCap,
+				"event_len":         st.EventChanLen,
+				"event_cap":         st.EventChanCap,
+				"workers_in_flight": st.InFlightWorkers,
+			}
+		},
+		prefix + ".current_snapshot": func() any {
+			if cur := w.GetCurrentSnapshot(); cur != nil {
+				return cur.ID
+			}
+			return ""
+		},
+	}
+
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	for name := range vars {
+		if expvar.Get(name) != nil {
+			return fmt.Errorf("expvar %q already published", name)
+		}
+	}
+	for name, fn := range vars {
+		expvar.Publish(name, fn)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package watcher
+
+import (
+	"expvar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestPublishExpvar 测试 expvar 变量随事件处理而更新，以及重复注册返回错误
+func TestPublishExpvar(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("hello"), 0644)
+
+	// expvar 无法注销，前缀带上时间戳以便 -count=N 重复运行
+	prefix := fmt.Sprintf("test_watcher_%d", time.Now().UnixNano())
+	w := newTestWatcher(t, root)
+	if err := w.PublishExpvar(prefix); err != nil {
+		t.Fatalf("PublishExpvar failed: %v", err)
+	}
+	if err := w.PublishExpvar(prefix); err == nil {
+		t.Error("publishing the same prefix twice should fail")
+	}
+	if err := newTestWatcher(t, root).PublishExpvar(prefix + "_2"); err != nil {
+		t.Errorf("second watcher with a different prefix failed: %v", err)
+	}
+
+	statsVar := expvar.Get(prefix + ".stats").(expvar.Func)
+	snapVar := expvar.Get(prefix + ".current_snapshot").(expvar.Func)
+	queueVar := expvar.Get(prefix + ".queues").(expvar.Func)
+	firstID := snapVar.Value().(string)
+
+	w.handleFileChange(file, fsnotify.Create)
+
+	if st := statsVar.Value().(WatcherStats); st.SnapshotsCreated != 1 || st.BytesHashed != 5 {
+		t.Errorf("stats not updated: created=%d bytes=%d", st.SnapshotsCreated, st.BytesHashed)
+	}
+	if id := snapVar.Value().(string); id == firstID || id != w.GetCurrentSnapshot().ID {
+		t.Errorf("current_snapshot = %q; want %q", id, w.GetCurrentSnapshot().ID)
+	}
+	if q := queueVar.Value().(map[string]int); q["event_len"] != 1 {
+		t.Errorf("queues.event_len = %d; want 1", q["event_len"])
+	}
+	if s := statsVar.String(); len(s) == 0 || s[0] != '{' {
+		t.Errorf("stats should render as JSON object, got %q", s)
+	}
+}
@@ -0,0 +1,193 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ExternalChange 是 ApplyExternalChanges 的一个条目：文件系统监控看不到的变更(如从容器外直接修改卷中的文件)
+//
+// Delete 为 true 时删除 Path(目录连同其子树)；否则为新增或更新：Meta 非nil 时按原样记录(以副本保存，Path 以本条目为准)，
+// Meta 为nil 时立即 stat 并计算哈希，与处理文件系统事件相同(遵循 MaxHashSize、HashErrorPolicy 等配置，文件已不存在时按删除处理)
+type ExternalChange struct {
+	Path   string
+	Meta   *FileMetadata
+	Delete bool
+}
+
+// ExternalOption 配置 ApplyExternalChanges
+type ExternalOption func(*externalOptions)
+
+type externalOptions struct {
+	outside bool
+}
+
+// AllowOutsideRoots 允许监控根之外的路径(事件的 Root 为 RootUnknown)，默认返回 ErrOutsideRoots
+func AllowOutsideRoots() ExternalOption {
+	return func(o *externalOptions) { o.outside = true }
+}
+
+// ApplyExternalChanges 把一批外部变更作为一个快照提交，并为每个实际变化的路径按路径顺序发送一个事件
+//
+// 事件的 Kind 为 OpExternal 与 OpCreate/OpWrite/OpRemove 之一的组合(IsCreate 等判断照常可用，IsExternal 区分来源)，
+// FirstSeen 与 Processed 相同。与当前状态相同的条目(包括删除不存在的路径)被跳过，全部相同时不提交，返回当前快照。
+// 先校验全部条目再提交，任一条目非法时整批都不应用：路径须为绝对路径，不能重复或为空，命中忽略规则时返回 ErrPathIgnored，
+// 监控根之外时返回 ErrOutsideRoots(除非 AllowOutsideRoots)，Meta 的大小不能为负。
+// 监控根之下缺失的上级目录自动补齐(Meta 给出的条目以只有路径的目录条目补齐，不访问磁盘)。
+// description 为空时使用默认描述；返回提交的快照，DisableSnapshots 或变更并入 MinSnapshotInterval 的待发布快照时返回nil。
+// Close 之后返回 ErrStopped
+func (w *Watcher) ApplyExternalChanges(changes []ExternalChange, description string, opts ...ExternalOption) (*SnapshotNode, error) {
+	if err := w.beginMutation(); err != nil {
+		return nil, err
+	}
+	defer w.endMutation()
+	var o externalOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	seen := make(map[string]struct{}, len(changes))
+	for i := range changes {
+		ch := &changes[i]
+		p := w.keyOf(ch.Path)
+		switch {
+		case ch.Path == "":
+			return nil, fmt.Errorf("external change %d: empty path", i)
+		case !filepath.IsAbs(ch.Path):
+			return nil, fmt.Errorf("external change %d: path %q is not absolute", i, ch.Path)
+		case w.isIgnored(p):
+			return nil, fmt.Errorf("%w: %s", ErrPathIgnored, p)
+		case w.rootOf(p) == "" && !o.outside:
+			return nil, fmt.Errorf("%w: %s", ErrOutsideRoots, p)
+		case !ch.Delete && ch.Meta != nil && ch.Meta.Size < 0:
+			return nil, fmt.Errorf("external change %d: negative size %d for %s", i, ch.Meta.Size, p)
+		}
+		if _, dup := seen[p]; dup {
+			return nil, fmt.Errorf("external change %d: duplicate path %s", i, p)
+		}
+		seen[p] = struct{}{}
+	}
+
+	now := w.now()
+	next := make(map[string]*FileMetadata, len(changes))
+	given := make(map[string]bool, len(changes)) // Meta 由调用方给出的路径
+	for _, ch := range changes {
+		p := w.keyOf(ch.Path)
+		switch {
+		case ch.Delete:
+			next[p] = nil
+		case ch.Meta != nil:
+			m := *ch.Meta
+			m.Path = p
+			m.Deleted, m.DeletedAt, m.rewritten = false, time.Time{}, false
+			if m.CreatedAt.IsZero() {
+				m.CreatedAt = now
+			}
+			if m.HashState == HashStateUnknown && m.Hash != "" {
+				m.HashState = HashStateHashed
+			}
+			next[p] = &m
+			given[p] = true
+		default:
+			fi, err := w.fs.Stat(p)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, wrapPathErr("failed to stat "+p, err)
+			}
+			if err == nil {
+				next[p] = w.buildMeta(p, fi, w.currentMeta(p), nil) // DropEntry 时为nil，按删除处理
+			} else {
+				next[p] = nil
+			}
+		}
+	}
+
+	// 与当前状态比较，只保留实际变化的路径
+	applied := make(map[string]*FileMetadata, len(next))
+	old := make(map[string]*FileMetadata, len(next))
+	w.readCurrent(func(files map[string]*FileMetadata) {
+		for p, m := range next {
+			cur := live(files[p])
+			if (m == nil && cur == nil) || (m != nil && !metaChanged(cur, m)) {
+				continue
+			}
+			applied[p], old[p] = m, cur
+		}
+	})
+	if len(applied) == 0 {
+		return w.GetCurrentSnapshot(), nil
+	}
+	events := w.externalEvents(applied, old, now)
+	dirs := make(map[string]*FileMetadata)
+	for p, m := range applied {
+		if m == nil {
+			continue
+		}
+		for dir, dm := range w.externalAncestors(p, given[p]) {
+			if _, ok := applied[dir]; !ok {
+				dirs[dir] = dm
+			}
+		}
+	}
+	for dir, dm := range dirs {
+		applied[dir] = dm
+	}
+
+	if description == "" {
+		description = fmt.Sprintf("External changes (%d entries)", len(events))
+	}
+	c := w.commitSnapshot(description, applied, "")
+	w.emitCommitted(c, events...)
+	return c.snap, nil
+}
+
+// externalAncestors 返回 p 在当前状态中缺失的上级目录：stat 得到的条目从磁盘补齐，given(Meta 由调用方给出)时只补路径
+func (w *Watcher) externalAncestors(p string, given bool) map[string]*FileMetadata {
+	if !given {
+		return w.missingAncestors(p)
+	}
+	root := w.rootOf(p)
+	if root == "" || p == root || w.cfg.DisableCurrentState {
+		return nil
+	}
+	out := make(map[string]*FileMetadata)
+	w.readCurrent(func(files map[string]*FileMetadata) {
+		for dir := dirOf(p); ; dir = dirOf(dir) {
+			if live(files[dir]) == nil {
+				out[dir] = &FileMetadata{Path: dir, IsDirectory: true, CreatedAt: w.now()}
+			}
+			if dir == root || dir == dirOf(dir) {
+				break
+			}
+		}
+	})
+	return out
+}
+
+// externalEvents 按路径顺序为外部变更构造事件
+func (w *Watcher) externalEvents(applied, old map[string]*FileMetadata, now time.Time) []FileEvent {
+	paths := make([]string, 0, len(applied))
+	for p := range applied {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	evs := make([]FileEvent, 0, len(paths))
+	for _, p := range paths {
+		ev := FileEvent{FilePath: p, Root: w.rootOf(p), OldMeta: old[p], NewMeta: applied[p], FirstSeen: now, Processed: now}
+		if ev.Root == "" {
+			ev.Root = RootUnknown
+		}
+		switch {
+		case ev.OldMeta == nil:
+			ev.Kind = OpExternal | OpCreate
+		case ev.NewMeta == nil:
+			ev.Kind = OpExternal | OpRemove
+		default:
+			ev.Kind = OpExternal | OpWrite
+		}
+		ev.Op = ev.Kind.fsnotifyOp()
+		evs = append(evs, ev)
+	}
+	return evs
+}
@@ -0,0 +1,89 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestApplyExternalChanges 测试一批外部变更作为一个快照提交、每个变化的路径一个 OpExternal 事件，
+// 未变化的条目被跳过，非法的批次整体不应用
+func TestApplyExternalChanges(t *testing.T) {
+	root := t.TempDir()
+	w := manifestWatcher(t, root, map[string]string{"keep.txt": "k", "gone.txt": "g", "same.txt": "s"})
+	sub := w.Subscribe(16)
+	before := w.GetCurrentSnapshot()
+	disk := filepath.Join(root, "disk.txt")
+	_ = os.WriteFile(disk, []byte("on disk"), 0644)
+	vol := filepath.Join(root, "vol", "data.bin")
+
+	sn, err := w.ApplyExternalChanges([]ExternalChange{
+		{Path: vol, Meta: &FileMetadata{Size: 10, Hash: "abc"}},
+		{Path: disk},
+		{Path: filepath.Join(root, "gone.txt"), Delete: true},
+		{Path: filepath.Join(root, "never.txt"), Delete: true},
+		{Path: filepath.Join(root, "same.txt")},
+	}, "volume sync")
+	if err != nil {
+		t.Fatalf("ApplyExternalChanges failed: %v", err)
+	}
+	if sn == nil || sn != w.GetCurrentSnapshot() || sn.ParentIDs[0] != before.ID || sn.Description != "volume sync" {
+		t.Fatalf("snapshot = %v; want a single child of %s", sn, before.ID)
+	}
+	if m := sn.Files[w.keyOf(vol)]; m == nil || m.Hash != "abc" || m.HashState != HashStateHashed || m.Path != w.keyOf(vol) {
+		t.Errorf("vol entry = %+v", m)
+	}
+	if d := sn.Files[w.keyOf(filepath.Join(root, "vol"))]; d == nil || !d.IsDirectory || d.Hash == "" {
+		t.Errorf("missing parent directory = %+v; want a hashed directory entry", d)
+	}
+	if m := sn.Files[w.keyOf(disk)]; m == nil || m.Size != 7 || m.Hash == "" {
+		t.Errorf("stat entry = %+v; want it hashed from disk", m)
+	}
+	if sn.Files[w.keyOf(filepath.Join(root, "gone.txt"))] != nil {
+		t.Error("deleted entry still present")
+	}
+
+	want := []struct {
+		path string
+		kind EventOp
+	}{{disk, OpExternal | OpCreate}, {filepath.Join(root, "gone.txt"), OpExternal | OpRemove}, {vol, OpExternal | OpCreate}}
+	for _, wt := range want {
+		ev := <-sub.C
+		if ev.FilePath != w.keyOf(wt.path) || ev.Kind != wt.kind || !ev.Kind.IsExternal() || ev.NewSnap != sn {
+			t.Errorf("event %s %s; want %s %s on the new snapshot", ev.Kind, ev.FilePath, wt.kind, wt.path)
+		}
+	}
+	if len(sub.C) != 0 {
+		t.Errorf("%d extra events; unchanged entries should be skipped", len(sub.C))
+	}
+	if again, err := w.ApplyExternalChanges([]ExternalChange{{Path: disk}}, ""); err != nil || again != sn {
+		t.Errorf("unchanged batch = %v, %v; want the current snapshot without a commit", again, err)
+	}
+
+	// 非法的批次：不提交任何变更
+	outside := filepath.Join(t.TempDir(), "x.txt")
+	for name, tc := range map[string]struct {
+		changes []ExternalChange
+		want    error
+	}{
+		"outside":  {[]ExternalChange{{Path: filepath.Join(root, "ok.txt"), Meta: &FileMetadata{}}, {Path: outside, Meta: &FileMetadata{}}}, ErrOutsideRoots},
+		"relative": {[]ExternalChange{{Path: "rel.txt", Meta: &FileMetadata{}}}, nil},
+		"dup":      {[]ExternalChange{{Path: disk, Delete: true}, {Path: disk}}, nil},
+		"negative": {[]ExternalChange{{Path: vol, Meta: &FileMetadata{Size: -1}}}, nil},
+	} {
+		_, err := w.ApplyExternalChanges(tc.changes, "")
+		if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+			t.Errorf("%s: error = %v; want %v", name, err, tc.want)
+		}
+		if w.GetCurrentSnapshot() != sn {
+			t.Errorf("%s: an invalid batch was committed", name)
+		}
+	}
+	if _, err := w.ApplyExternalChanges([]ExternalChange{{Path: outside, Meta: &FileMetadata{Size: 1}}}, "", AllowOutsideRoots()); err != nil {
+		t.Fatalf("AllowOutsideRoots: %v", err)
+	}
+	if ev := <-sub.C; ev.Root != RootUnknown || ev.FilePath != w.keyOf(outside) {
+		t.Errorf("outside event root = %q; want %q", ev.Root, RootUnknown)
+	}
+}
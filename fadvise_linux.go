@@ -0,0 +1,16 @@
+//go:build linux
+
+package watcher
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// adviseSequential 提示内核该文件将被顺序读完，使其加大预读窗口
+//
+// 只是提示，失败时忽略
+func adviseSequential(f *os.File) {
+	_ = unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_SEQUENTIAL)
+}
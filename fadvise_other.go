@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watcher
+
+import "os"
+
+// adviseSequential 在没有 posix_fadvise 的平台上不做任何事
+func adviseSequential(*os.File) {}
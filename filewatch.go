@@ -0,0 +1,225 @@
+package watcher
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 单个配置文件的监控(WatchFile)
+//
+// 编辑器的原子保存(写临时文件再 rename 覆盖)会替换文件本身，直接监控文件会在第一次保存后失效；
+// Kubernetes 挂载的 ConfigMap 中 app.yaml → ..data/app.yaml，..data → ..2024_01_01_00_00_00.123/，
+// 更新时只原子替换 ..data 这个符号链接，app.yaml 本身没有任何事件。
+// 因此 WatchFile 监控文件所在的目录(递归，包括 ..data 指向的目录)，目录中任何变化都会在去抖之后重新解析
+// 文件的符号链接，按快照中真实路径的哈希判断内容是否变化，只在哈希变化时读取内容并调用回调
+
+// 回调失败后重试的退避时间范围
+const (
+	fileWatchRetryMin = 100 * time.Millisecond
+	fileWatchRetryMax = 30 * time.Second
+)
+
+// FileWatch 是 WatchFile 返回的单文件监控，Close 释放底层的 Watcher
+type FileWatch struct {
+	path     string
+	w        *Watcher
+	sub      *Subscription
+	onChange func(meta FileMetadata, content []byte) error
+
+	mu      sync.Mutex
+	hash    string // 最近一次成功交给回调的内容哈希
+	lastErr error  // 最近一次读取或回调的错误，成功后清空
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+	wg        sync.WaitGroup
+}
+
+// WatchFile 监控单个(配置)文件，内容变化时以 debounce(0 表示默认的 Debounce)去抖后调用 onChange
+//
+// 返回前以文件的当前内容同步调用一次 onChange：文件不存在时返回 ErrPathNotFound，onChange 返回错误时返回该错误，
+// 两种情况下都不会留下后台资源。之后只在内容哈希变化时调用 onChange(元信息的 Path 为 path，Hash 为内容哈希)；
+// onChange 返回错误时按 100ms 起翻倍、最长 30s 的间隔重试同一内容，直到成功或内容再次变化。回调在同一个goroutine中依次执行。
+//
+// 文件被原子保存替换、被删除后重建，以及作为 Kubernetes ConfigMap(..data 符号链接切换)挂载时都能继续生效；
+// 文件暂时不存在(如删除后尚未重建)时不调用回调。path 所在的目录被递归监控，应避免把文件放在很大的目录树下
+func WatchFile(path string, debounce time.Duration, onChange func(meta FileMetadata, content []byte) error) (*FileWatch, error) {
+	if onChange == nil {
+		return nil, fmt.Errorf("%w: WatchFile: onChange must not be nil", ErrInvalidConfig)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	// 监控根取解析过符号链接的目录，使 EvalSymlinks 得到的真实路径与快照中的路径一致
+	dir := filepath.Dir(path)
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	opts := []Option{WithDisableSnapshots(), WithDisableEventChan()}
+	if debounce > 0 {
+		opts = append(opts, WithDebounce(debounce))
+	}
+	w, err := NewWatcherWithOptions([]string{dir}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	fw := &FileWatch{path: path, w: w, onChange: onChange, done: make(chan struct{})}
+	fw.sub = w.Subscribe(64)
+	if err := w.Start(); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	if _, err := w.fs.Stat(path); err != nil {
+		_ = w.Close()
+		return nil, fmt.Errorf("WatchFile %s: %w: %w", path, ErrPathNotFound, err)
+	}
+	if err := fw.check(); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	fw.wg.Add(1)
+	go fw.run()
+	return fw, nil
+}
+
+// Path 返回监控的文件路径(绝对路径)
+func (fw *FileWatch) Path() string {
+	return fw.path
+}
+
+// Err 返回最近一次读取文件或调用 onChange 的错误，之后成功交付时清空
+// 并发安全
+func (fw *FileWatch) Err() error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.lastErr
+}
+
+// Close 停止监控并关闭底层的 Watcher，等待进行中的回调返回；可重复调用
+func (fw *FileWatch) Close() error {
+	fw.closeOnce.Do(func() {
+		close(fw.done)
+		fw.wg.Wait()
+		fw.closeErr = fw.w.Close()
+	})
+	return fw.closeErr
+}
+
+// run 在目录中有变化或到了重试时间时检查文件内容
+func (fw *FileWatch) run() {
+	defer fw.wg.Done()
+	var (
+		retry   *time.Timer
+		retryC  <-chan time.Time
+		backoff = fileWatchRetryMin
+	)
+	defer func() {
+		if retry != nil {
+			retry.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-fw.done:
+			return
+		case _, ok := <-fw.sub.C:
+			if !ok {
+				return
+			}
+			// 同一批次的事件一起处理
+			fw.drain()
+			backoff = fileWatchRetryMin
+		case <-retryC:
+			retryC = nil
+		}
+		if retry != nil {
+			retry.Stop()
+			retryC = nil
+		}
+		if err := fw.check(); err != nil {
+			retry = time.NewTimer(backoff)
+			retryC = retry.C
+			backoff = min(2*backoff, fileWatchRetryMax)
+		}
+	}
+}
+
+// drain 丢弃订阅中已缓冲的事件
+func (fw *FileWatch) drain() {
+	for {
+		select {
+		case _, ok := <-fw.sub.C:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// check 解析文件的符号链接，内容哈希与上次交付的不同时读取内容并调用回调；返回需要重试的错误
+func (fw *FileWatch) check() error {
+	real, err := filepath.EvalSymlinks(fw.path)
+	if err != nil {
+		// 文件暂时不存在(原子保存或 ..data 切换的中间状态)：等待下一个事件
+		return nil
+	}
+	fw.mu.Lock()
+	last := fw.hash
+	fw.mu.Unlock()
+	if m, ok := fw.w.CurrentFile(real); ok && m.Hash != "" && m.Hash == last {
+		return nil
+	}
+
+	meta, content, err := fw.read(real)
+	if err == nil && meta.Hash == last {
+		return nil
+	}
+	if err == nil {
+		if err = fw.onChange(meta, content); err != nil {
+			err = fmt.Errorf("WatchFile %s: onChange: %w", fw.path, err)
+		}
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.lastErr = err
+	if err == nil {
+		fw.hash = meta.Hash
+	}
+	return err
+}
+
+// read 读取文件内容并计算哈希
+func (fw *FileWatch) read(real string) (FileMetadata, []byte, error) {
+	info, err := fw.w.fs.Stat(real)
+	if err != nil {
+		return FileMetadata{}, nil, fmt.Errorf("WatchFile %s: %w", fw.path, err)
+	}
+	f, err := fw.w.fs.Open(real)
+	if err != nil {
+		return FileMetadata{}, nil, fmt.Errorf("WatchFile %s: %w", fw.path, err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return FileMetadata{}, nil, fmt.Errorf("WatchFile %s: %w", fw.path, err)
+	}
+	h := fw.w.newHash()
+	h.Write(content)
+	now := fw.w.now()
+	return FileMetadata{
+		Path:         fw.path,
+		Size:         int64(len(content)),
+		ModTime:      info.ModTime(),
+		Hash:         hex.EncodeToString(h.Sum(nil)),
+		HashState:    HashStateHashed,
+		CreatedAt:    now,
+		LastModified: info.ModTime(),
+	}, content, nil
+}
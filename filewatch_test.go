@@ -0,0 +1,130 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fileWatchCalls 启动 WatchFile，返回依次收到的内容；fail 为 true 时回调返回错误(不记录)
+func fileWatchCalls(t *testing.T, path string, fail func(content string) bool) (*FileWatch, <-chan string) {
+	t.Helper()
+	calls := make(chan string, 16)
+	fw, err := WatchFile(path, 10*time.Millisecond, func(meta FileMetadata, content []byte) error {
+		if meta.Path != path || meta.Size != int64(len(content)) || meta.Hash == "" {
+			t.Errorf("meta = %+v; want path %s with a hash", meta, path)
+		}
+		if fail != nil && fail(string(content)) {
+			return errors.New("reload failed")
+		}
+		calls <- string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WatchFile failed: %v", err)
+	}
+	t.Cleanup(func() { _ = fw.Close() })
+	return fw, calls
+}
+
+// expectCall 等待下一次回调并校验内容
+func expectCall(t *testing.T, calls <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-calls:
+		if got != want {
+			t.Fatalf("onChange(%q); want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for onChange(%q)", want)
+	}
+}
+
+// expectNoCall 校验一段时间内没有回调
+func expectNoCall(t *testing.T, calls <-chan string) {
+	t.Helper()
+	select {
+	case got := <-calls:
+		t.Fatalf("unexpected onChange(%q)", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// TestWatchFileAtomicSave 测试初次调用、原子保存替换文件、内容不变的写入不触发回调，以及 Close 之后不再回调
+func TestWatchFileAtomicSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.yaml")
+	_ = os.WriteFile(path, []byte("v1"), 0644)
+	fw, calls := fileWatchCalls(t, path, nil)
+	expectCall(t, calls, "v1")
+
+	tmp := filepath.Join(dir, ".app.yaml.swp")
+	_ = os.WriteFile(tmp, []byte("v2"), 0644)
+	_ = os.Rename(tmp, path)
+	expectCall(t, calls, "v2")
+
+	_ = os.WriteFile(path, []byte("v2"), 0644)
+	expectNoCall(t, calls)
+
+	if err := fw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	_ = os.WriteFile(path, []byte("v3"), 0644)
+	expectNoCall(t, calls)
+}
+
+// TestWatchFileConfigMap 测试 Kubernetes ConfigMap 的 ..data 符号链接切换
+func TestWatchFileConfigMap(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on windows")
+	}
+	dir := t.TempDir()
+	version := func(name, content string) {
+		_ = os.Mkdir(filepath.Join(dir, name), 0755)
+		_ = os.WriteFile(filepath.Join(dir, name, "app.yaml"), []byte(content), 0644)
+	}
+	version("..2024_01_01_00_00_00.1", "v1")
+	_ = os.Symlink("..2024_01_01_00_00_00.1", filepath.Join(dir, "..data"))
+	_ = os.Symlink(filepath.Join("..data", "app.yaml"), filepath.Join(dir, "app.yaml"))
+	_, calls := fileWatchCalls(t, filepath.Join(dir, "app.yaml"), nil)
+	expectCall(t, calls, "v1")
+
+	// kubelet 的更新顺序：写入新版本目录，创建临时链接，rename 覆盖 ..data，删除旧目录
+	version("..2024_01_01_00_01_00.2", "v2")
+	_ = os.Symlink("..2024_01_01_00_01_00.2", filepath.Join(dir, "..data_tmp"))
+	_ = os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data"))
+	_ = os.RemoveAll(filepath.Join(dir, "..2024_01_01_00_00_00.1"))
+	expectCall(t, calls, "v2")
+	expectNoCall(t, calls)
+}
+
+// TestWatchFileRetry 测试回调失败后退避重试同一内容，成功后 Err 清空；初次回调失败时 WatchFile 返回错误
+func TestWatchFileRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	_ = os.WriteFile(path, []byte("v1"), 0644)
+	failures := 2
+	fw, calls := fileWatchCalls(t, path, func(content string) bool {
+		if content == "bad" && failures > 0 {
+			failures--
+			return true
+		}
+		return false
+	})
+	expectCall(t, calls, "v1")
+	_ = os.WriteFile(path, []byte("bad"), 0644)
+	expectCall(t, calls, "bad")
+	if failures != 0 || fw.Err() != nil {
+		t.Fatalf("failures left = %d, Err() = %v; want two failed attempts then success", failures, fw.Err())
+	}
+
+	_ = os.WriteFile(path, []byte("broken"), 0644)
+	if _, err := WatchFile(path, 10*time.Millisecond, func(FileMetadata, []byte) error { return errors.New("parse error") }); err == nil {
+		t.Fatal("WatchFile succeeded although the initial onChange failed")
+	}
+	if _, err := WatchFile(filepath.Join(t.TempDir(), "missing.yaml"), 0, func(FileMetadata, []byte) error { return nil }); !errors.Is(err, ErrPathNotFound) {
+		t.Fatalf("WatchFile(missing) error = %v; want ErrPathNotFound", err)
+	}
+}
@@ -0,0 +1,194 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// hashPrefixLen 是 String() 中显示的哈希前缀长度
+const hashPrefixLen = 8
+
+// shortHash 返回元信息哈希的前缀，没有条目或哈希时返回 "-"
+func shortHash(m *FileMetadata) string {
+	if m == nil || m.Hash == "" {
+		return "-"
+	}
+	if len(m.Hash) > hashPrefixLen {
+		return m.Hash[:hashPrefixLen]
+	}
+	return m.Hash
+}
+
+// SnapshotID 返回事件产生的快照ID：NewSnap 按 EventSnapshotMode 省略时返回 SnapID，没有快照(DisableSnapshots)时返回空串
+func (e FileEvent) SnapshotID() string {
+	if e.NewSnap == nil {
+		return e.SnapID
+	}
+	return e.NewSnap.ID
+}
+
+// String 返回事件的单行摘要，不包含快照的文件表，适合直接写入日志
+//
+// 格式：<操作> <路径> (seq <序号>, snapshot <快照ID>, hash <旧哈希前缀>→<新哈希前缀>)，缺失的哈希与快照ID显示为 "-"
+func (e FileEvent) String() string {
+	snapID := e.SnapshotID()
+	if snapID == "" {
+		snapID = "-"
+	}
+	return fmt.Sprintf("%s %s (seq %d, snapshot %s, hash %s→%s)",
+		e.Kind, e.FilePath, e.Seq, snapID, shortHash(e.OldMeta), shortHash(e.NewMeta))
+}
+
+// SnapshotSummary 是快照的摘要(不含文件表)，适合写入日志或序列化
+type SnapshotSummary struct {
+	ID           string    `json:"id"`
+	Instance     string    `json:"instance,omitempty"`
+	ParentIDs    []string  `json:"parent_ids,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Description  string    `json:"description,omitempty"`
+	FileCount    int       `json:"file_count"`
+	RootHash     string    `json:"root_hash,omitempty"`
+	ChangedPaths []string  `json:"changed_paths,omitempty"`
+	Truncated    bool      `json:"changed_paths_truncated,omitempty"`
+
+	ConfigFingerprint string `json:"config_fingerprint,omitempty"` // 创建时有效配置的指纹(SnapshotConfig.Fingerprint)
+}
+
+// Summary 返回快照的摘要
+func (sn *SnapshotNode) Summary() SnapshotSummary {
+	return SnapshotSummary{
+		ID:           sn.ID,
+		Instance:     sn.Instance,
+		ParentIDs:    sn.ParentIDs,
+		CreatedAt:    sn.CreatedAt,
+		Description:  sn.Description,
+		FileCount:    sn.FileCount(),
+		RootHash:     sn.RootHash,
+		ChangedPaths: sn.ChangedPaths,
+		Truncated:    sn.Truncated,
+
+		ConfigFingerprint: sn.Config.fingerprint(),
+	}
+}
+
+// String 返回快照的单行摘要，不包含文件表
+//
+// 格式：<快照ID> (<创建时间 RFC3339>, <文件数> files, parents [<父快照ID>...])
+func (sn *SnapshotNode) String() string {
+	if sn == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%s (%s, %d files, parents [%s])",
+		sn.ID, sn.CreatedAt.Format(time.RFC3339), sn.FileCount(), strings.Join(sn.ParentIDs, " "))
+}
+
+// fileEventJSON 是 FileEvent 的 JSON 结构
+type fileEventJSON struct {
+	Seq      uint64        `json:"seq"`
+	Path     string        `json:"path"`
+	Root     string        `json:"root,omitempty"`
+	Kind     string        `json:"kind"`
+	Old      *FileMetadata `json:"old,omitempty"`
+	New      *FileMetadata `json:"new,omitempty"`
+	Snapshot *snapshotJSON `json:"snapshot,omitempty"`
+	SnapID   string        `json:"snapshot_id,omitempty"`
+	Parent   string        `json:"parent_snapshot_id,omitempty"`
+
+	SizeDelta int64 `json:"size_delta,omitempty"`
+	Truncated bool  `json:"truncated,omitempty"`
+	Completed bool  `json:"completed,omitempty"`
+	Entries   int   `json:"entries,omitempty"`
+
+	FirstSeen *time.Time `json:"first_seen,omitempty"`
+	Processed *time.Time `json:"processed,omitempty"`
+
+	OpTrace []opSampleJSON `json:"op_trace,omitempty"`
+}
+
+// opSampleJSON 是 OpSample 的 JSON 结构
+type opSampleJSON struct {
+	Op string    `json:"op"`
+	At time.Time `json:"at"`
+}
+
+// optionalTime 返回 t 的指针，零值时返回nil(JSON 中省略)
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// snapshotJSON 是事件中快照的 JSON 结构，Files 仅在 WithFiles 时输出
+type snapshotJSON struct {
+	SnapshotSummary
+	Files map[string]*FileMetadata `json:"files,omitempty"`
+}
+
+// MarshalJSON 实现 json.Marshaler
+//
+// 快照只输出摘要(SnapshotSummary)，不包含 NewSnap.Files；需要完整文件表时序列化 e.WithFiles()。
+// NewSnap 按 EventSnapshotMode 省略时输出 SnapSummary，IDOnly 时只输出 snapshot_id
+func (e FileEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(false)
+}
+
+// WithFiles 返回一个在序列化时包含 NewSnap.Files 的包装
+//
+//	data, err := json.Marshal(ev.WithFiles())
+func (e FileEvent) WithFiles() json.Marshaler {
+	return fileEventWithFiles{e}
+}
+
+// fileEventWithFiles 序列化时包含快照文件表
+type fileEventWithFiles struct{ e FileEvent }
+
+// MarshalJSON 实现 json.Marshaler
+func (f fileEventWithFiles) MarshalJSON() ([]byte, error) {
+	return f.e.marshalJSON(true)
+}
+
+// marshalJSON 是 MarshalJSON 的实现
+func (e FileEvent) marshalJSON(withFiles bool) ([]byte, error) {
+	out := fileEventJSON{
+		Seq:  e.Seq,
+		Path: SlashPath(e.FilePath),
+		Root: SlashPath(e.Root),
+		Kind: e.Kind.String(),
+		Old:  slashMeta(e.OldMeta),
+		New:  slashMeta(e.NewMeta),
+
+		Parent:    e.ParentSnapshotID,
+		SizeDelta: e.SizeDelta,
+		Truncated: e.Truncated,
+		Completed: e.Completed,
+		Entries:   e.Entries,
+		FirstSeen: optionalTime(e.FirstSeen),
+		Processed: optionalTime(e.Processed),
+	}
+	for _, s := range e.OpTrace {
+		out.OpTrace = append(out.OpTrace, opSampleJSON{Op: s.Op.String(), At: s.At})
+	}
+	if e.NewSnap != nil {
+		sn := e.NewSnap.SlashPaths()
+		out.Snapshot = &snapshotJSON{SnapshotSummary: sn.Summary()}
+		if withFiles {
+			out.Snapshot.Files = sn.Files
+		}
+	} else if e.SnapSummary != nil {
+		sum := *e.SnapSummary
+		if os.PathSeparator != '/' && sum.ChangedPaths != nil {
+			sum.ChangedPaths = make([]string, len(e.SnapSummary.ChangedPaths))
+			for i, p := range e.SnapSummary.ChangedPaths {
+				sum.ChangedPaths[i] = SlashPath(p)
+			}
+		}
+		out.Snapshot = &snapshotJSON{SnapshotSummary: sum}
+	} else {
+		out.SnapID = e.SnapID
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// checkGolden 比较输出与 testdata 中的 golden 文件，-update 时改写
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch (run with -update to accept)\n--- got\n%s\n--- want\n%s", name, got, want)
+	}
+}
+
+// formatFixture 构造固定内容的事件
+func formatFixture() FileEvent {
+	at := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	old := &FileMetadata{Path: "/srv/app/config.yaml", Size: 3, ModTime: at, Hash: "1a2b3c4d5e6f7a8b", HashState: HashStateHashed}
+	cur := &FileMetadata{Path: "/srv/app/config.yaml", Size: 5, ModTime: at.Add(time.Second), Hash: "9f8e7d6c5b4a3928", HashState: HashStateHashed}
+	snap := &SnapshotNode{
+		ID:          "snap-2",
+		ParentIDs:   []string{"snap-1"},
+		CreatedAt:   at,
+		Description: "File changed: /srv/app/config.yaml",
+		Files:       map[string]*FileMetadata{cur.Path: cur},
+		RootHash:    "abcdef",
+	}
+	return FileEvent{FilePath: cur.Path, Kind: OpWrite, NewSnap: snap, Seq: 7, OldMeta: old, NewMeta: cur}
+}
+
+// TestFormatGolden 测试 String/Summary/MarshalJSON 的输出格式保持稳定
+func TestFormatGolden(t *testing.T) {
+	ev := formatFixture()
+	created := ev
+	created.Kind, created.OldMeta, created.NewSnap = OpCreate, nil, nil
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%v\n", ev)
+	fmt.Fprintf(&buf, "%s\n", created)
+	fmt.Fprintf(&buf, "%v\n", ev.NewSnap)
+	fmt.Fprintf(&buf, "%+v\n", ev.NewSnap.Summary())
+	checkGolden(t, "format_string.golden", buf.Bytes())
+
+	buf.Reset()
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(ev)
+	_ = enc.Encode(ev.WithFiles())
+	checkGolden(t, "format_json.golden", buf.Bytes())
+}
@@ -0,0 +1,191 @@
+package watcher
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FS 抽象 Watcher 对文件系统的读取，通过 ConfigWatcher.FS 或 WithFS 注入
+//
+// 传入的路径都是快照中使用的普通路径(Windows 上不带 `\\?\` 前缀)；WalkDir 的语义同 filepath.WalkDir
+// Open 因文件暂时被其它进程锁定而失败时应返回包装 ErrFileLocked 的错误，Watcher 会稍后重试计算哈希
+// 默认实现 OSFS() 直接调用 os 与 filepath；内存实现见 watchertest.MemFS
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	Open(name string) (io.ReadCloser, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	WalkDir(root string, fn fs.WalkDirFunc) error
+}
+
+// EventSource 抽象底层的文件系统事件来源，通过 ConfigWatcher.EventSource 或 WithFS 注入
+//
+// 默认实现基于 fsnotify.Watcher；Add/Remove 以目录为单位注册/取消监控，
+// Events 与 Errors 在 Close 后关闭，事件中的路径与 WatchList 都是普通路径。
+// Close 之前已产生的事件应在 Events 关闭之前交出：Watcher 停止时关闭事件来源后读完 Events，这些事件进入最后一次 flush；
+// 从不产生事件的来源 Events 可以返回nil
+type EventSource interface {
+	Add(name string) error
+	Remove(name string) error
+	WatchList() []string
+	Events() <-chan RawEvent
+	Errors() <-chan error
+	Close() error
+}
+
+// RawEvent 是 EventSource 回报的一个文件系统事件
+//
+// Op 为 OpCreate、OpWrite、OpRemove、OpRename 与 OpChmod 的组合
+type RawEvent struct {
+	Name string
+	Op   EventOp
+}
+
+// fsnotifyEvent 转换为内部处理使用的 fsnotify.Event
+func (ev RawEvent) fsnotifyEvent() fsnotify.Event {
+	return fsnotify.Event{Name: ev.Name, Op: ev.Op.fsnotifyOp()}
+}
+
+// OSFS 返回直接访问操作系统文件系统的 FS(默认实现)
+func OSFS() FS {
+	return osFS{}
+}
+
+// osFS 是 FS 的操作系统实现，长路径在这里统一加上前缀
+type osFS struct{}
+
+func (osFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(osPath(name)) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(osPath(name)) }
+
+// Open 打开文件用于计算哈希；调用方总是从头到尾顺序读完，因此顺带提示内核按顺序预读
+// Windows 上以共享模式打开，仍被其它进程锁定时返回包装 ErrFileLocked 的错误(见 openShared)
+//
+// 注意 *os.File 的 Read 只能经 readerOnly 包装后配合 io.CopyBuffer 使用，见 hashFile
+func (osFS) Open(name string) (io.ReadCloser, error) {
+	f, err := openShared(osPath(name))
+	if err != nil {
+		return nil, err
+	}
+	adviseSequential(f)
+	return f, nil
+}
+
+func (osFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, fn)
+}
+
+// NewFsnotifySource 创建基于 fsnotify 的 EventSource(默认实现)
+func NewFsnotifySource() (EventSource, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &fsnotifySource{w: fsw, done: make(chan struct{}), drained: make(chan struct{})}, nil
+}
+
+// fsnotifySource 把 fsnotify.Watcher 适配为 EventSource
+//
+// 长路径：注册时加 `\\?\` 前缀，WatchList 中去掉前缀；事件路径的还原在 handleFsEvent 中进行。
+// 只有调用 Events 时才启动转换为 RawEvent 的转发goroutine
+type fsnotifySource struct {
+	w       *fsnotify.Watcher
+	once    sync.Once
+	events  chan RawEvent
+	done    chan struct{} // Close 时关闭，通知 forward 取走 fsnotify 已送达的事件
+	drained chan struct{} // forward 取走已送达的事件后关闭，之后才能关闭 fsnotify
+	closed  sync.Once
+}
+
+// Events 返回转换为 RawEvent 的事件通道，第一次调用时启动转发
+func (s *fsnotifySource) Events() <-chan RawEvent {
+	s.once.Do(func() {
+		s.events = make(chan RawEvent)
+		go s.forward()
+	})
+	return s.events
+}
+
+// forward 把 fsnotify 的事件转换为 RawEvent 转发；Close 时取走 fsnotify 已送达的事件，
+// 交给读取方之后才关闭 Events
+func (s *fsnotifySource) forward() {
+	defer close(s.events)
+	var held []RawEvent
+loop:
+	for {
+		select {
+		case ev, ok := <-s.w.Events:
+			if !ok {
+				break loop
+			}
+			e := RawEvent{Name: ev.Name, Op: eventOpFromFsnotify(ev.Op)}
+			select {
+			case s.events <- e:
+			case <-s.done:
+				held = append(held, e)
+				break loop
+			}
+		case <-s.done:
+			break loop
+		}
+	}
+	held = s.drain(held)
+	close(s.drained)
+	for _, e := range held {
+		s.events <- e
+	}
+}
+
+// drain 把 fsnotify 已送达(正在等待被读取)的事件追加到 held 后返回，内核尚未送达的事件不会被捕获
+func (s *fsnotifySource) drain(held []RawEvent) []RawEvent {
+	for {
+		select {
+		case ev, ok := <-s.w.Events:
+			if !ok {
+				return held
+			}
+			held = append(held, RawEvent{Name: ev.Name, Op: eventOpFromFsnotify(ev.Op)})
+		default:
+			return held
+		}
+	}
+}
+
+func (s *fsnotifySource) Add(name string) error    { return s.w.Add(osPath(name)) }
+func (s *fsnotifySource) Remove(name string) error { return s.w.Remove(osPath(name)) }
+func (s *fsnotifySource) Errors() <-chan error     { return s.w.Errors }
+
+// Close 等待转发取走已送达的事件后关闭 fsnotify.Watcher，Events 在这些事件被读走后关闭
+func (s *fsnotifySource) Close() error {
+	s.closed.Do(func() {
+		close(s.done)
+		// 从未调用 Events 时没有转发，之后的 Events 返回已关闭的通道
+		s.once.Do(func() {
+			s.events = make(chan RawEvent)
+			close(s.events)
+			close(s.drained)
+		})
+		<-s.drained
+	})
+	return s.w.Close()
+}
+
+func (s *fsnotifySource) WatchList() []string {
+	list := s.w.WatchList()
+	for i, p := range list {
+		list[i] = fromLongPath(p)
+	}
+	return list
+}
+
+// birthTime 返回文件创建时间，只对操作系统文件系统有效，注入的 FS 返回零值
+func (w *Watcher) birthTime(path string, fi fs.FileInfo) time.Time {
+	if _, ok := w.baseFS().(osFS); !ok {
+		return time.Time{}
+	}
+	return birthTime(w.diskPath(path), fi)
+}
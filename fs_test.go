@@ -0,0 +1,160 @@
+package watcher_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchertest"
+)
+
+// nextEvent 等待已产生的 n 个文件系统事件全部进入合并表，然后推进时钟直到收到下一个事件
+func nextEvent(t *testing.T, w *watcher.Watcher, clock *watchertest.FakeClock, n uint64, d time.Duration) watcher.FileEvent {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for w.Stats().EventsAggregated < n {
+		select {
+		case <-deadline:
+			t.Fatalf("only %d of %d events aggregated", w.Stats().EventsAggregated, n)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	for {
+		clock.Advance(d)
+		select {
+		case ev := <-w.EventChan:
+			return ev
+		case <-time.After(time.Millisecond):
+		case <-deadline:
+			t.Fatal("timeout waiting for file event")
+		}
+	}
+}
+
+// TestWatcherBasicMemFS 是 TestWatcherBasic 在内存文件系统与模拟时钟上的版本
+func TestWatcherBasicMemFS(t *testing.T) {
+	clock := watchertest.NewFakeClock(time.Time{})
+	mfs := watchertest.NewMemFS(clock.Now)
+	root := filepath.Join(string(filepath.Separator), "data")
+	if err := mfs.MkdirAll(root); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := watcher.NewWatcherWithOptions([]string{root},
+		watcher.WithClock(clock), watcher.WithFS(mfs, mfs.Source()), watcher.WithDebounce(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.Stop()
+	if err := w.Start(); err != nil {
+		t.Fatalf("Watcher Start failed: %v", err)
+	}
+
+	// 创建一个测试文件
+	filePath := filepath.Join(root, "test.txt")
+	writeAt := clock.Now()
+	if err := mfs.WriteFile(filePath, []byte("hello")); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	evt := nextEvent(t, w, clock, 2, 5*time.Millisecond) // Create + Write
+	if evt.FilePath != filePath || !evt.Kind.IsCreate() {
+		t.Errorf("expected create event for %s, got %v", filePath, evt)
+	}
+
+	metadata, ok := w.GetCurrentSnapshot().Files[filePath]
+	if !ok {
+		t.Fatalf("file metadata not found in current snapshot")
+	}
+	if metadata.Hash != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("unexpected hash %s", metadata.Hash)
+	}
+	if !metadata.ModTime.Equal(writeAt) {
+		t.Errorf("ModTime = %v, want the fake clock time at write", metadata.ModTime)
+	}
+
+	// 再删除该文件
+	_ = mfs.RemoveAll(filePath)
+	evt = nextEvent(t, w, clock, 3, 5*time.Millisecond)
+	if evt.FilePath != filePath || !evt.Kind.IsDelete() {
+		t.Errorf("expected remove event for %s, got %v", filePath, evt)
+	}
+	if _, ok := w.GetCurrentSnapshot().Files[filePath]; ok {
+		t.Errorf("file metadata should be removed after deletion")
+	}
+}
+
+// TestFsnotifySourceEvents 测试默认事件来源把 fsnotify 的事件转换为 RawEvent，Close 后通道关闭
+func TestFsnotifySourceEvents(t *testing.T) {
+	dir := t.TempDir()
+	src, err := watcher.NewFsnotifySource()
+	if err != nil {
+		t.Fatalf("NewFsnotifySource failed: %v", err)
+	}
+	if err := src.Add(dir); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	file := filepath.Join(dir, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+
+	select {
+	case ev := <-src.Events():
+		if ev.Name != file || !ev.Op.Has(watcher.OpCreate) {
+			t.Errorf("event = %+v; want CREATE %s", ev, file)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-src.Events():
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Events not closed after Close")
+		}
+	}
+}
+
+// TestFsnotifySourceDrainsOnClose 测试 Close 前已送达但尚未读取的事件在 Events 关闭之前交出
+func TestFsnotifySourceDrainsOnClose(t *testing.T) {
+	dir := t.TempDir()
+	src, err := watcher.NewFsnotifySource()
+	if err != nil {
+		t.Fatalf("NewFsnotifySource failed: %v", err)
+	}
+	if err := src.Add(dir); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	events := src.Events()
+	file := filepath.Join(dir, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+	time.Sleep(100 * time.Millisecond) // 让事件送达而不读取
+
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	var created bool
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				if !created {
+					t.Errorf("CREATE %s delivered before Close was lost", file)
+				}
+				return
+			}
+			created = created || ev.Name == file && ev.Op.Has(watcher.OpCreate)
+		case <-deadline:
+			t.Fatal("Events not closed after Close")
+		}
+	}
+}
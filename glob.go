@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 通配符语言
+//
+// IgnorePatterns、AppendOnlyPatterns 与 FilesMatching 使用同一套规则：
+//   - 不含路径分隔符的模式只匹配文件名(如 "*.log")
+//   - 含分隔符的模式匹配完整路径，分隔符统一为 "/"(如 "/srv/app/config/*.yaml")
+//   - 语法同 path.Match(*、?、[...])；此外独占一段的 "**" 匹配零个或多个路径段
+//     (如 "**/config/**/*.yaml")，与其它字符相连的 "**" 等同于 "*"
+
+// matchGlob 按上述规则匹配以 "/" 分隔的完整路径，模式语法错误时返回 path.ErrBadPattern
+func matchGlob(pattern, name string) (bool, error) {
+	if !strings.Contains(pattern, "**") {
+		return path.Match(pattern, name)
+	}
+	return matchSegments(strings.Split(pattern, "/"), name, false)
+}
+
+// matchSegments 逐段匹配，"**" 段可吞掉任意多段
+//
+// name 按需用 strings.Cut 切分而不预先 Split，匹配过程不分配内存；
+// end 表示 name 已没有剩余的段(与剩下一个空段区分)
+func matchSegments(pats []string, name string, end bool) (bool, error) {
+	for len(pats) > 0 {
+		if pats[0] != "**" {
+			if end {
+				return false, nil
+			}
+			part, rest, more := strings.Cut(name, "/")
+			if ok, err := path.Match(pats[0], part); !ok || err != nil {
+				return false, err
+			}
+			pats, name, end = pats[1:], rest, !more
+			continue
+		}
+		// 连续的 "**" 等价于一个
+		for len(pats) > 0 && pats[0] == "**" {
+			pats = pats[1:]
+		}
+		if len(pats) == 0 {
+			return true, nil
+		}
+		for {
+			if ok, err := matchSegments(pats, name, end); ok || err != nil {
+				return ok, err
+			}
+			if end {
+				return false, nil
+			}
+			_, rest, more := strings.Cut(name, "/")
+			name, end = rest, !more
+		}
+	}
+	return end, nil
+}
+
+// globPattern 是预处理过的通配符，供每个事件都要执行的忽略检查使用
+type globPattern struct {
+	raw   string   // 原始模式
+	path  bool     // 含路径分隔符，匹配完整路径
+	slash string   // 分隔符统一为 "/" 的模式
+	segs  []string // 含 "**" 时按 "/" 拆分的段，否则为nil
+}
+
+// compileGlobs 预处理一组通配符
+func compileGlobs(patterns []string) []globPattern {
+	out := make([]globPattern, len(patterns))
+	for i, pat := range patterns {
+		g := globPattern{raw: pat, path: hasPathSeparator(pat), slash: filepath.ToSlash(pat)}
+		if strings.Contains(g.slash, "**") {
+			g.segs = strings.Split(g.slash, "/")
+		}
+		out[i] = g
+	}
+	return out
+}
+
+// matchPath 用含分隔符的模式匹配以 "/" 分隔的完整路径，语义同 matchGlob
+func (g *globPattern) matchPath(name string) bool {
+	if g.segs == nil {
+		ok, _ := path.Match(g.slash, name)
+		return ok
+	}
+	ok, _ := matchSegments(g.segs, name, false)
+	return ok
+}
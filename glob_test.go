@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestMatchGlob 测试 "**" 与普通通配符的匹配
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"config/*.yaml", "config/a.yaml", true},
+		{"config/*.yaml", "config/sub/a.yaml", false},
+		{"config/**/*.yaml", "config/a.yaml", true},
+		{"config/**/*.yaml", "config/x/y/a.yaml", true},
+		{"config/**/*.yaml", "other/a.yaml", false},
+		{"**/config/*.yaml", "/srv/app/config/a.yaml", true},
+		{"/srv/**", "/srv", true},
+		{"/srv/**", "/srv/a/b", true},
+		{"/srv/**/**/b", "/srv/b", true},
+		{"a**b/c", "axxb/c", true},
+		{"a**b/c", "ax/xb/c", false},
+		{"a/**/", "a/", true},
+		{"**/x", "x/", false},
+	}
+	for _, c := range cases {
+		got, err := matchGlob(c.pattern, c.name)
+		if err != nil || got != c.want {
+			t.Errorf("matchGlob(%q, %q) = %v, %v; want %v", c.pattern, c.name, got, err, c.want)
+		}
+	}
+	if _, err := matchGlob("**/[a-", "x/y"); err == nil {
+		t.Error("expected ErrBadPattern")
+	}
+}
+
+// TestIgnoreDoubleStar 测试忽略规则支持 "**"
+func TestIgnoreDoubleStar(t *testing.T) {
+	pats := []string{"**/node_modules/**"}
+	w := &Watcher{cfg: ConfigWatcher{IgnorePatterns: pats}, ignore: compileGlobs(pats)}
+	if !w.isIgnored("/proj/web/node_modules/x/index.js") || w.isIgnored("/proj/web/src/index.js") {
+		t.Error("unexpected ignore result for ** pattern")
+	}
+	if !matchPatterns([]string{"logs/**/*.log"}, "logs/2024/01/app.log") {
+		t.Error("append-only patterns should support **")
+	}
+}
+
+// TestIsIgnoredNoAlloc 测试忽略检查(含 "**" 模式)不分配内存
+func TestIsIgnoredNoAlloc(t *testing.T) {
+	pats := []string{"**/node_modules/**", "/data/build/*.o", "*.tmp"}
+	w := &Watcher{cfg: ConfigWatcher{IgnorePatterns: pats}, ignore: compileGlobs(pats)}
+	path := filepath.FromSlash("/proj/web/node_modules/x/index.js")
+	if n := testing.AllocsPerRun(100, func() { w.isIgnored(path) }); n != 0 && filepath.Separator == '/' {
+		t.Errorf("isIgnored allocated %v times per call", n)
+	}
+}
@@ -2,6 +2,7 @@ module github.com/shuakami/watcher
 
 go 1.21
 
-require github.com/fsnotify/fsnotify v1.7.0
-
-require golang.org/x/sys v0.4.0 // indirect 
\ No newline at end of file
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	golang.org/x/sys v0.21.0
+)
@@ -0,0 +1,469 @@
+package watcher
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WatcherGroup 管理多个 Watcher(如不同的监控根、每个租户各自的配置)，
+// 提供合并后的事件订阅、汇总的统计，以及整组的 Start/Close
+//
+// 每个成员以唯一的名称登记，事件通过 GroupEvent.Member 标明来源；
+// 快照相关的接口仍按成员调用，见 Member
+// 成员由组持有：Remove 与 Close 会关闭成员 Watcher
+// 并发安全
+type WatcherGroup struct {
+	failFast bool
+
+	mu      sync.Mutex
+	members map[string]*groupMember
+	started bool
+	closed  bool
+
+	subMu      sync.Mutex
+	subs       map[*GroupSubscription]struct{}
+	subsClosed bool
+	dropped    atomic.Uint64
+}
+
+// groupMember 是一个成员及其事件转发goroutine
+type groupMember struct {
+	w       *Watcher
+	sub     *Subscription
+	started bool
+	done    chan struct{} // 转发goroutine退出后关闭
+}
+
+// GroupOption 配置 WatcherGroup
+type GroupOption func(*WatcherGroup)
+
+// WithGroupFailFast 使 Start 在任一成员启动失败时关闭整个组并返回错误，
+// 默认只报告失败的成员，其余成员照常运行
+func WithGroupFailFast() GroupOption {
+	return func(g *WatcherGroup) {
+		g.failFast = true
+	}
+}
+
+// NewWatcherGroup 创建一个空的 WatcherGroup
+func NewWatcherGroup(opts ...GroupOption) *WatcherGroup {
+	g := &WatcherGroup{members: make(map[string]*groupMember)}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// GroupEvent 是合并订阅中的事件，Member 为产生该事件的成员名称
+//
+// Seq 是成员自己的事件序号，不同成员之间不可比较
+type GroupEvent struct {
+	Member string
+	FileEvent
+}
+
+// MemberError 表示单个成员的启动或关闭错误
+type MemberError struct {
+	Member string
+	Err    error
+}
+
+// Error 实现 error 接口
+func (e *MemberError) Error() string {
+	return fmt.Sprintf("watcher %s: %v", e.Member, e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *MemberError) Unwrap() error {
+	return e.Err
+}
+
+// GroupError 汇总 WatcherGroup.Start/Close 中失败的成员，按名称排序
+//
+// 实现了 Unwrap() []error，errors.Is/As 可直接作用于其中任意一个 *MemberError 及其底层错误
+type GroupError struct {
+	Failures []*MemberError
+}
+
+// Error 实现 error 接口
+func (e *GroupError) Error() string {
+	if len(e.Failures) == 1 {
+		return e.Failures[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d watchers failed:", len(e.Failures))
+	for _, f := range e.Failures {
+		fmt.Fprintf(&b, "\n  %s: %v", f.Member, f.Err)
+	}
+	return b.String()
+}
+
+// Unwrap 返回所有失败项
+func (e *GroupError) Unwrap() []error {
+	out := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		out[i] = f
+	}
+	return out
+}
+
+// groupError 按名称排序失败项，没有失败时返回nil
+func groupError(failures []*MemberError) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Member < failures[j].Member })
+	return &GroupError{Failures: failures}
+}
+
+// Add 以 name 登记一个成员
+//
+// 组已启动时立即启动该成员，启动失败时成员不会加入组，且 w 会被关闭；
+// name 为空或已存在、组已关闭时返回错误(w 不会被关闭)
+func (g *WatcherGroup) Add(name string, w *Watcher) error {
+	if name == "" || w == nil {
+		return fmt.Errorf("%w: group member needs a name and a watcher", ErrInvalidConfig)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return fmt.Errorf("cannot add %s: %w", name, ErrStopped)
+	}
+	if _, ok := g.members[name]; ok {
+		return fmt.Errorf("%w: duplicate group member %q", ErrInvalidConfig, name)
+	}
+
+	// 先订阅再启动，成员的第一个事件也不会错过
+	m := &groupMember{w: w, sub: w.Subscribe(0), done: make(chan struct{})}
+	go g.forward(name, m)
+	if g.started {
+		if err := w.Start(); err != nil {
+			_ = w.Close()
+			<-m.done
+			return &MemberError{Member: name, Err: err}
+		}
+		m.started = true
+	}
+	g.members[name] = m
+	return nil
+}
+
+// Remove 关闭并移除成员
+//
+// 成员关闭前最后一次 flush 产生的事件会先转发到合并订阅，Remove 返回后不会再有该成员的事件
+// 返回成员 Close 的错误；没有该成员时返回 ErrMemberNotFound
+func (g *WatcherGroup) Remove(name string) error {
+	g.mu.Lock()
+	m, ok := g.members[name]
+	delete(g.members, name)
+	g.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMemberNotFound, name)
+	}
+	err := m.w.Close()
+	<-m.done
+	return err
+}
+
+// Member 返回名为 name 的成员，用于查询快照等单个 Watcher 的接口
+func (g *WatcherGroup) Member(name string) (*Watcher, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	m, ok := g.members[name]
+	if !ok {
+		return nil, false
+	}
+	return m.w, true
+}
+
+// Names 返回全部成员名称(按名称排序)
+func (g *WatcherGroup) Names() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make([]string, 0, len(g.members))
+	for name := range g.members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start 启动全部尚未启动的成员，之后 Add 的成员会立即启动
+//
+// 默认某个成员启动失败不影响其它成员，返回的 *GroupError 列出失败的成员，失败的成员保留在组中(可 Remove)；
+// WithGroupFailFast 时遇到第一个失败即停止启动，关闭整个组，返回的 *GroupError 只含该成员
+// 组已关闭时返回 ErrStopped
+func (g *WatcherGroup) Start() error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return ErrStopped
+	}
+	g.started = true
+	names := make([]string, 0, len(g.members))
+	for name, m := range g.members {
+		if !m.started {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var failures []*MemberError
+	for _, name := range names {
+		m := g.members[name]
+		if err := m.w.Start(); err != nil {
+			failures = append(failures, &MemberError{Member: name, Err: err})
+			if g.failFast {
+				break
+			}
+			continue
+		}
+		m.started = true
+	}
+	g.mu.Unlock()
+
+	if g.failFast && len(failures) > 0 {
+		_ = g.Close()
+	}
+	return groupError(failures)
+}
+
+// Close 关闭全部成员与合并订阅，返回各成员 Close 的错误(*GroupError)，可重复调用
+//
+// 各成员最后一次 flush 产生的事件会先转发到合并订阅，然后订阅通道被关闭
+func (g *WatcherGroup) Close() error {
+	g.mu.Lock()
+	members := g.members
+	g.members = make(map[string]*groupMember)
+	g.closed = true
+	g.mu.Unlock()
+
+	var (
+		wg       sync.WaitGroup
+		failMu   sync.Mutex
+		failures []*MemberError
+	)
+	for name, m := range members {
+		wg.Add(1)
+		go func(name string, m *groupMember) {
+			defer wg.Done()
+			if err := m.w.Close(); err != nil {
+				failMu.Lock()
+				failures = append(failures, &MemberError{Member: name, Err: err})
+				failMu.Unlock()
+			}
+			<-m.done
+		}(name, m)
+	}
+	wg.Wait()
+
+	g.subMu.Lock()
+	g.subsClosed = true
+	for s := range g.subs {
+		s.once.Do(func() { close(s.ch) })
+	}
+	g.subs = nil
+	g.subMu.Unlock()
+	return groupError(failures)
+}
+
+// GroupSubscription 是 WatcherGroup 的合并事件订阅，语义同 Subscription：
+// 缓冲已满时新事件直接丢弃(计入 Dropped)；组关闭时通道被关闭
+type GroupSubscription struct {
+	C <-chan GroupEvent
+
+	g       *WatcherGroup
+	ch      chan GroupEvent
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// Subscribe 创建一个合并全部成员事件的订阅，buffer<=0 时使用 DefaultSubscriptionBuffer
+//
+// 只会收到订阅之后转发的事件；同一成员的事件保持其 Seq 顺序，不同成员之间不保证顺序
+// 组已关闭时返回的订阅通道已关闭
+func (g *WatcherGroup) Subscribe(buffer int) *GroupSubscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+	ch := make(chan GroupEvent, buffer)
+	s := &GroupSubscription{C: ch, g: g, ch: ch}
+
+	g.subMu.Lock()
+	defer g.subMu.Unlock()
+	if g.subsClosed {
+		close(ch)
+		s.once.Do(func() {})
+		return s
+	}
+	if g.subs == nil {
+		g.subs = make(map[*GroupSubscription]struct{})
+	}
+	g.subs[s] = struct{}{}
+	return s
+}
+
+// Close 取消订阅并关闭通道，可重复调用
+func (s *GroupSubscription) Close() {
+	s.once.Do(func() {
+		s.g.subMu.Lock()
+		defer s.g.subMu.Unlock()
+		delete(s.g.subs, s)
+		close(s.ch)
+	})
+}
+
+// Dropped 返回因缓冲已满而未投递给该订阅的事件数
+func (s *GroupSubscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// forward 把成员订阅中的事件转发给组的全部订阅，成员关闭(订阅通道关闭)后退出
+func (g *WatcherGroup) forward(name string, m *groupMember) {
+	defer close(m.done)
+	for ev := range m.sub.C {
+		gev := GroupEvent{Member: name, FileEvent: ev}
+		g.subMu.Lock()
+		for s := range g.subs {
+			select {
+			case s.ch <- gev:
+			default:
+				s.dropped.Add(1)
+				g.dropped.Add(1)
+			}
+		}
+		g.subMu.Unlock()
+	}
+}
+
+// GroupStats 是 WatcherGroup 的统计：Total 为全部成员的汇总，Members 为各成员自己的统计
+//
+// 汇总时计数与瞬时值按成员相加，AggChanHighWater 与 MaxChangeDuration 取最大值，WorkerUtilization 按各成员的 WorkerCount 加权平均，Degraded 表示任一成员处于降级，延迟直方图按桶合并；
+// Total.Subscribers/SubscriberDropped 是成员级别的(含组自己的转发订阅)，组订阅的丢弃数见 SubscriberDropped
+type GroupStats struct {
+	Total             WatcherStats
+	Members           map[string]WatcherStats
+	SubscriberDropped uint64 // 计数：因组订阅缓冲已满而未投递的事件数(所有组订阅合计)
+}
+
+// Stats 返回各成员的统计及其汇总
+func (g *WatcherGroup) Stats() GroupStats {
+	g.mu.Lock()
+	members := make(map[string]*Watcher, len(g.members))
+	for name, m := range g.members {
+		members[name] = m.w
+	}
+	g.mu.Unlock()
+
+	gs := GroupStats{Members: make(map[string]WatcherStats, len(members)), SubscriberDropped: g.dropped.Load()}
+	for name, w := range members {
+		st := w.Stats()
+		gs.Members[name] = st
+		gs.Total.add(&st)
+	}
+	return gs
+}
+
+// add 把 o 累加到 s 上，规则见 GroupStats
+func (s *WatcherStats) add(o *WatcherStats) {
+	s.EventsReceived += o.EventsReceived
+	s.EventsIgnored += o.EventsIgnored
+	s.SelfSuppressed += o.SelfSuppressed
+	s.PathRewriteConflicts += o.PathRewriteConflicts
+	s.EventsAggregated += o.EventsAggregated
+	s.EventsCoalesced += o.EventsCoalesced
+	s.FlushCycles += o.FlushCycles
+	s.SizeFlushes += o.SizeFlushes
+	s.BatchesProcessed += o.BatchesProcessed
+	s.BatchesCompleted += o.BatchesCompleted
+
+	s.SnapshotsCreated += o.SnapshotsCreated
+	s.SnapshotCount += o.SnapshotCount
+	s.ChangesCoalesced += o.ChangesCoalesced
+	s.DescribeFallbacks += o.DescribeFallbacks
+	s.DescribeRunning += o.DescribeRunning
+	s.SnapshotsSquashed += o.SnapshotsSquashed
+	s.Tombstones += o.Tombstones
+
+	s.SnapshotsInMemory += o.SnapshotsInMemory
+	s.SnapshotsSpilled += o.SnapshotsSpilled
+	s.SnapshotsHydrated += o.SnapshotsHydrated
+	s.HydrationCacheHits += o.HydrationCacheHits
+
+	s.EventOverflows += o.EventOverflows
+	s.OverflowRescans += o.OverflowRescans
+
+	s.Degraded = s.Degraded || o.Degraded
+	s.DegradedEntries += o.DegradedEntries
+	s.DegradedTime += o.DegradedTime
+
+	s.WatchedDirs += o.WatchedDirs
+	s.WatchedDirsLimit += o.WatchedDirsLimit
+	s.WatchesSkipped += o.WatchesSkipped
+	s.WatchesFailed += o.WatchesFailed
+	s.WatchesRecovered += o.WatchesRecovered
+	s.InaccessibleDirs += o.InaccessibleDirs
+
+	s.FastLaneQueued += o.FastLaneQueued
+	s.BulkLaneQueued += o.BulkLaneQueued
+
+	s.HashOps += o.HashOps
+	s.BytesHashed += o.BytesHashed
+	s.HashErrors += o.HashErrors
+	s.HashRetries += o.HashRetries
+	s.HashPending += o.HashPending
+
+	s.CompletionPending += o.CompletionPending
+
+	s.EventsEmitted += o.EventsEmitted
+	s.EventsDropped += o.EventsDropped
+	s.ErrorsDropped += o.ErrorsDropped
+	s.ErrorsDeduplicated += o.ErrorsDeduplicated
+
+	s.Subscribers += o.Subscribers
+	s.SubscriberDropped += o.SubscriberDropped
+	s.ReplayBuffered += o.ReplayBuffered
+
+	s.AuditWritten += o.AuditWritten
+	s.AuditDropped += o.AuditDropped
+
+	s.AggChanLen += o.AggChanLen
+	s.AggChanCap += o.AggChanCap
+	s.AggChanHighWater = max(s.AggChanHighWater, o.AggChanHighWater)
+	s.EventChanLen += o.EventChanLen
+	s.EventChanCap += o.EventChanCap
+	s.InFlightWorkers += o.InFlightWorkers
+	if n := s.WorkerCount + o.WorkerCount; n > 0 {
+		s.WorkerUtilization = (s.WorkerUtilization*float64(s.WorkerCount) + o.WorkerUtilization*float64(o.WorkerCount)) / float64(n)
+	}
+	s.WorkerCount += o.WorkerCount
+	s.WorkerBusyTime += o.WorkerBusyTime
+	s.WorkerWaits += o.WorkerWaits
+	s.MaxChangeDuration = max(s.MaxChangeDuration, o.MaxChangeDuration)
+
+	s.BatchLatency.add(o.BatchLatency)
+	s.HashLatency.add(o.HashLatency)
+	s.EventLatency.add(o.EventLatency)
+}
+
+// add 按桶合并 o 并重新计算分位数，分桶不同时只合并 Count、Sum 与 Max
+func (h *HistogramSnapshot) add(o HistogramSnapshot) {
+	if h.Bounds == nil {
+		h.Bounds = append([]time.Duration(nil), o.Bounds...)
+		h.Counts = make([]uint64, len(o.Counts))
+	}
+	if slices.Equal(h.Bounds, o.Bounds) {
+		for i, c := range o.Counts {
+			h.Counts[i] += c
+		}
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+	h.Max = max(h.Max, o.Max)
+	h.fillQuantiles()
+}
@@ -0,0 +1,172 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newGroupMember 创建一个监控新临时目录的成员，返回 Watcher 与其监控根
+func newGroupMember(t *testing.T, opts ...Option) (*Watcher, string) {
+	t.Helper()
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, append([]Option{WithDisableEventChan()}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	return w, root
+}
+
+// waitGroupEvent 等待合并订阅中出现 member 对 path 的事件
+func waitGroupEvent(t *testing.T, sub *GroupSubscription, member, path string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				t.Fatalf("subscription closed before event for %s/%s", member, path)
+			}
+			if ev.Member == member && ev.FilePath == path {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no event for %s/%s", member, path)
+		}
+	}
+}
+
+// TestWatcherGroupMergedEvents 测试合并订阅带有成员名称、运行中添加成员以及汇总统计
+func TestWatcherGroupMergedEvents(t *testing.T) {
+	g := NewWatcherGroup()
+	t.Cleanup(func() { _ = g.Close() })
+	wa, rootA := newGroupMember(t)
+	if err := g.Add("a", wa); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := g.Add("a", wa); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("duplicate Add returned %v; want ErrInvalidConfig", err)
+	}
+	sub := g.Subscribe(0)
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	fileA := filepath.Join(rootA, "a.txt")
+	_ = os.WriteFile(fileA, []byte("a"), 0644)
+	waitGroupEvent(t, sub, "a", fileA)
+
+	// 组已启动：新成员立即启动
+	wb, rootB := newGroupMember(t)
+	if err := g.Add("b", wb); err != nil {
+		t.Fatalf("Add to running group failed: %v", err)
+	}
+	fileB := filepath.Join(rootB, "b.txt")
+	_ = os.WriteFile(fileB, []byte("b"), 0644)
+	waitGroupEvent(t, sub, "b", fileB)
+
+	if names := g.Names(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("Names = %v; want [a b]", names)
+	}
+	if w, ok := g.Member("b"); !ok || w.GetCurrentSnapshot().Files[fileB] == nil {
+		t.Error("member b snapshot missing b.txt")
+	}
+	st := g.Stats()
+	sa, sb := st.Members["a"], st.Members["b"]
+	if st.Total.SnapshotsCreated != sa.SnapshotsCreated+sb.SnapshotsCreated || st.Total.WorkerCount != sa.WorkerCount+sb.WorkerCount {
+		t.Errorf("Total = %+v; want sum of members", st.Total)
+	}
+	if st.Total.HashLatency.Count != sa.HashLatency.Count+sb.HashLatency.Count {
+		t.Errorf("HashLatency.Count = %d; want %d", st.Total.HashLatency.Count, sa.HashLatency.Count+sb.HashLatency.Count)
+	}
+
+	if err := g.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for range sub.C {
+	}
+	if err := g.Add("c", wb); !errors.Is(err, ErrStopped) {
+		t.Errorf("Add after Close returned %v; want ErrStopped", err)
+	}
+}
+
+// TestWatcherGroupRemoveDrains 测试移除成员时其尚未 flush 的事件先转发，之后不再有该成员的事件
+func TestWatcherGroupRemoveDrains(t *testing.T) {
+	g := NewWatcherGroup()
+	t.Cleanup(func() { _ = g.Close() })
+	w, root := newGroupMember(t, WithDebounce(time.Hour))
+	keep, _ := newGroupMember(t)
+	_ = g.Add("gone", w)
+	_ = g.Add("keep", keep)
+	sub := g.Subscribe(0)
+	if err := g.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	file := filepath.Join(root, "pending.txt")
+	_ = os.WriteFile(file, []byte("x"), 0644)
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Stats().EventsAggregated == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("event never reached the aggregator")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := g.Remove("gone"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	select {
+	case ev := <-sub.C:
+		if ev.Member != "gone" || ev.FilePath != file {
+			t.Errorf("event = %s from %s; want %s from gone", ev.FilePath, ev.Member, file)
+		}
+	default:
+		t.Fatal("pending event of removed member not forwarded before Remove returned")
+	}
+	if _, ok := g.Member("gone"); ok {
+		t.Error("removed member still present")
+	}
+	if err := g.Remove("gone"); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("second Remove returned %v; want ErrMemberNotFound", err)
+	}
+}
+
+// TestWatcherGroupPartialStart 测试一个成员启动失败时默认不影响其它成员，FailFast 时关闭整个组
+func TestWatcherGroupPartialStart(t *testing.T) {
+	for _, failFast := range []bool{false, true} {
+		var opts []GroupOption
+		if failFast {
+			opts = append(opts, WithGroupFailFast())
+		}
+		g := NewWatcherGroup(opts...)
+		good, _ := newGroupMember(t)
+		bad, badRoot := newGroupMember(t)
+		_ = g.Add("good", good)
+		_ = g.Add("bad", bad)
+		_ = os.RemoveAll(badRoot)
+
+		err := g.Start()
+		var gerr *GroupError
+		if !errors.As(err, &gerr) || len(gerr.Failures) != 1 || gerr.Failures[0].Member != "bad" || !errors.Is(err, ErrPathNotFound) {
+			t.Fatalf("failFast=%v: Start returned %v; want GroupError for bad", failFast, err)
+		}
+		if failFast {
+			if names := g.Names(); len(names) != 0 {
+				t.Errorf("fail-fast group still has members %v", names)
+			}
+			if err := g.Start(); !errors.Is(err, ErrStopped) {
+				t.Errorf("Start after fail-fast returned %v; want ErrStopped", err)
+			}
+			continue
+		}
+		if !good.started.Load() {
+			t.Error("good member not started")
+		}
+		if err := g.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	}
+}
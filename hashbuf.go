@@ -0,0 +1,45 @@
+package watcher
+
+import (
+	"io"
+	"sync"
+)
+
+// DefaultHashBufferSize 是计算哈希时每次读取的缓冲大小(ConfigWatcher.HashBufferSize 的默认值)
+//
+// 比 io.Copy 默认的 32KB 大得多，读取大文件时系统调用次数随之减少
+const DefaultHashBufferSize = 1 << 20
+
+// bufferPool 是固定大小读缓冲的池
+//
+// 同时在用的缓冲不超过并发读取文件的 worker 数，内存占用约为 WorkerCount × 缓冲大小；
+// 其它需要整块复制文件内容的路径也应从这里取缓冲，而不是各自分配
+type bufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// newBufferPool 创建缓冲大小为 size 的池
+func newBufferPool(size int) *bufferPool {
+	p := &bufferPool{size: size}
+	p.pool.New = func() any {
+		b := make([]byte, size)
+		return &b
+	}
+	return p
+}
+
+// get 取出一个缓冲，用完后必须 put 回去
+func (p *bufferPool) get() *[]byte {
+	return p.pool.Get().(*[]byte)
+}
+
+// put 归还缓冲
+func (p *bufferPool) put(b *[]byte) {
+	p.pool.Put(b)
+}
+
+// readerOnly 隐藏底层 Reader 的 WriterTo 实现
+//
+// *os.File 实现了 WriterTo，io.CopyBuffer 会绕过传入的缓冲、改用内部分配的 32KB 缓冲
+type readerOnly struct{ io.Reader }
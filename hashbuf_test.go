@@ -0,0 +1,45 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHashBufferSizes 测试读缓冲远小于文件时，普通哈希与追加写检测的结果不受影响
+func TestHashBufferSizes(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "app.log")
+	content := strings.Repeat("0123456789", 100)
+	_ = os.WriteFile(file, []byte(content), 0644)
+
+	w, err := NewWatcherWithOptions([]string{root}, WithHashBufferSize(7), WithAppendOnlyPatterns("*.log"))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+
+	meta, _, err := w.RehashFile(file)
+	if err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	if meta.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("hash with 7-byte buffer = %s", meta.Hash)
+	}
+
+	f, _ := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	_, _ = f.WriteString("tail")
+	f.Close()
+	meta, _, err = w.RehashFile(file)
+	if err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	sum = sha256.Sum256([]byte(content + "tail"))
+	if meta.AppendedBytes != 4 || meta.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("append detection with small buffer: appended=%d hash=%s", meta.AppendedBytes, meta.Hash)
+	}
+}
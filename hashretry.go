@@ -0,0 +1,87 @@
+package watcher
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 被其它进程锁定的文件的哈希重试
+//
+// Windows 上被其它进程独占打开的文件(如正在编辑的 .docx)打开时报告共享冲突。这类失败是暂时的：
+// 条目先以 HashStatePending 提交，随后按指数退避把路径重新放入合并表，由正常的处理流程重新计算哈希；
+// 重试 hashRetryLimit 次仍失败时按不可读处理(HashStateUnreadable，并发送 *HashError)。
+// 到期检查在合并goroutine的每个 tick(Debounce 粒度)中进行，不额外启动goroutine
+
+const (
+	hashRetryLimit = 5                      // 最多重试次数
+	hashRetryBase  = 200 * time.Millisecond // 第一次重试的等待时间，之后每次翻倍
+)
+
+// hashRetries 记录等待重试哈希的路径
+type hashRetries struct {
+	mu      sync.Mutex
+	pending map[string]hashRetry
+	n       atomic.Int64 // len(pending)，避免没有待重试路径时加锁
+}
+
+// hashRetry 是单个路径的重试状态；due 为零表示已放回合并表、等待处理结果
+type hashRetry struct {
+	attempt int
+	due     time.Time
+}
+
+// scheduleHashRetry 为因文件被锁定而哈希失败的 path 安排下一次重试，重试次数用尽时清除记录并返回 false
+func (w *Watcher) scheduleHashRetry(path string) bool {
+	r := &w.hashRetry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.pending[path]
+	if st.attempt >= hashRetryLimit {
+		delete(r.pending, path)
+		r.n.Store(int64(len(r.pending)))
+		return false
+	}
+	if r.pending == nil {
+		r.pending = make(map[string]hashRetry)
+	}
+	r.pending[path] = hashRetry{attempt: st.attempt + 1, due: w.now().Add(hashRetryBase << st.attempt)}
+	r.n.Store(int64(len(r.pending)))
+	w.counters.hashRetries.Add(1)
+	return true
+}
+
+// clearHashRetry 在 path 的哈希成功或路径被删除后清除其重试记录
+func (w *Watcher) clearHashRetry(path string) {
+	r := &w.hashRetry
+	if r.n.Load() == 0 {
+		return
+	}
+	r.mu.Lock()
+	delete(r.pending, path)
+	r.n.Store(int64(len(r.pending)))
+	r.mu.Unlock()
+}
+
+// requeueHashRetries 把已到期的重试路径放回合并表，由下一次 flush 处理
+func (w *Watcher) requeueHashRetries() {
+	r := &w.hashRetry
+	if r.n.Load() == 0 {
+		return
+	}
+	now := w.now()
+	r.mu.Lock()
+	var due []string
+	for p, st := range r.pending {
+		if !st.due.IsZero() && !now.Before(st.due) {
+			due = append(due, p)
+			r.pending[p] = hashRetry{attempt: st.attempt}
+		}
+	}
+	r.mu.Unlock()
+	for _, p := range due {
+		w.mergeAgg(aggEvent{Event: fsnotify.Event{Name: p, Op: fsnotify.Write}})
+	}
+}
@@ -0,0 +1,126 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// lockedFS 模拟被其它进程锁定的文件：前 locked 次 Open 返回 ErrFileLocked
+type lockedFS struct {
+	FS
+	locked atomic.Int32
+}
+
+func (l *lockedFS) Open(name string) (io.ReadCloser, error) {
+	if l.locked.Add(-1) >= 0 {
+		return nil, fmt.Errorf("%w: open %s: sharing violation", ErrFileLocked, name)
+	}
+	return l.FS.Open(name)
+}
+
+// manualClock 是只能手动推进的 Clock，定时器从不触发
+type manualClock struct{ now atomic.Int64 }
+
+func (c *manualClock) Now() time.Time                 { return time.Unix(0, c.now.Load()) }
+func (c *manualClock) NewTicker(time.Duration) Ticker { return manualTicker{} }
+func (c *manualClock) Sleep(d time.Duration)          { c.now.Add(int64(d)) }
+func (c *manualClock) advance(d time.Duration)        { c.now.Add(int64(d)) }
+func (manualTicker) C() <-chan time.Time              { return nil }
+func (manualTicker) Stop()                            {}
+
+type manualTicker struct{}
+
+// TestHashRetryLockedFile 测试被锁定的文件先以 Pending 提交，退避到期后重新计算哈希
+func TestHashRetryLockedFile(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "doc.docx")
+	_ = os.WriteFile(file, []byte("draft"), 0644)
+	fsys := &lockedFS{FS: osFS{}}
+	fsys.locked.Store(2)
+	clock := &manualClock{}
+	clock.now.Store(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(fsys, replaySource{}), WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	w.handleFileChange(file, fsnotify.Create)
+	if m := w.GetCurrentSnapshot().Files[file]; m.HashState != HashStatePending || m.Hash != "" {
+		t.Fatalf("locked file state = %v hash %q; want Pending", m.HashState, m.Hash)
+	}
+	if st := w.Stats(); st.HashPending != 1 || st.HashRetries != 1 || st.HashErrors != 0 {
+		t.Fatalf("stats pending=%d retries=%d errors=%d; want 1/1/0", st.HashPending, st.HashRetries, st.HashErrors)
+	}
+
+	retry := func() {
+		w.requeueHashRetries()
+		w.flushAgg(true)
+		w.workerWG.Wait()
+	}
+	retry() // 尚未到期
+	if w.GetCurrentSnapshot().Files[file].HashState != HashStatePending {
+		t.Fatal("retried before the backoff elapsed")
+	}
+	clock.advance(hashRetryBase)
+	retry() // 第二次仍被锁定，退避翻倍
+	if w.GetCurrentSnapshot().Files[file].HashState != HashStatePending || w.Stats().HashRetries != 2 {
+		t.Fatalf("second attempt: %+v", w.GetCurrentSnapshot().Files[file])
+	}
+	clock.advance(hashRetryBase)
+	retry()
+	if w.GetCurrentSnapshot().Files[file].HashState != HashStatePending {
+		t.Fatal("retried before the doubled backoff elapsed")
+	}
+	clock.advance(hashRetryBase)
+	retry()
+	if m := w.GetCurrentSnapshot().Files[file]; m.HashState != HashStateHashed || m.Hash == "" {
+		t.Fatalf("after unlock state = %v; want Hashed", m.HashState)
+	}
+	if st := w.Stats(); st.HashPending != 0 {
+		t.Errorf("HashPending = %d; want 0", st.HashPending)
+	}
+}
+
+// TestHashRetryExhausted 测试重试用尽后按不可读处理并报告 *HashError
+func TestHashRetryExhausted(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "locked.bin")
+	_ = os.WriteFile(file, []byte("x"), 0644)
+	fsys := &lockedFS{FS: osFS{}}
+	fsys.locked.Store(1 << 20)
+	clock := &manualClock{}
+
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(fsys, replaySource{}), WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	w.handleFileChange(file, fsnotify.Create)
+	for i := 0; i < hashRetryLimit; i++ {
+		clock.advance(hashRetryBase << i)
+		w.requeueHashRetries()
+		w.flushAgg(true)
+		w.workerWG.Wait()
+	}
+	if m := w.GetCurrentSnapshot().Files[file]; m.HashState != HashStateUnreadable {
+		t.Fatalf("state = %v; want Unreadable", m.HashState)
+	}
+	if st := w.Stats(); st.HashRetries != hashRetryLimit || st.HashPending != 0 || st.HashErrors != 1 {
+		t.Errorf("stats retries=%d pending=%d errors=%d", st.HashRetries, st.HashPending, st.HashErrors)
+	}
+	var herr *HashError
+	if err := <-w.ErrorChan; !errors.As(err, &herr) || !errors.Is(err, ErrFileLocked) {
+		t.Errorf("error = %v; want *HashError wrapping ErrFileLocked", err)
+	}
+}
@@ -0,0 +1,170 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// HashState 表示 FileMetadata.Hash 的来源/可信程度
+type HashState int
+
+const (
+	// HashStateUnknown 未记录哈希状态(如外部构造的元信息)，按 Hash 字段原样比较
+	HashStateUnknown HashState = iota
+	// HashStateHashed 已计算内容哈希(目录为子树哈希)
+	HashStateHashed
+	// HashStateSkippedSize 文件超过 MaxHashSize，未计算哈希
+	HashStateSkippedSize
+	// HashStateSkippedType 非普通文件(设备、管道、套接字等)或按规则不计算哈希
+	HashStateSkippedType
+	// HashStateUnreadable 文件不可读(如无权限)，内容未知
+	HashStateUnreadable
+	// HashStatePending 哈希尚未完成：文件暂时被其它进程锁定，稍后自动重试(见 ErrFileLocked)
+	HashStatePending
+	// HashStateSkippedDegraded 事件风暴保护降级期间未计算哈希(见 WithStormProtection)
+	HashStateSkippedDegraded
+	// HashStateStale 本次计算哈希失败，Hash 沿用上一次成功计算的值，可能已与内容不符(见 HashErrorKeepPrevious)
+	HashStateStale
+)
+
+// String 返回哈希状态的可读名称
+func (s HashState) String() string {
+	switch s {
+	case HashStateUnknown:
+		return "Unknown"
+	case HashStateHashed:
+		return "Hashed"
+	case HashStateSkippedSize:
+		return "SkippedSize"
+	case HashStateSkippedType:
+		return "SkippedType"
+	case HashStateUnreadable:
+		return "Unreadable"
+	case HashStatePending:
+		return "Pending"
+	case HashStateSkippedDegraded:
+		return "SkippedDegraded"
+	case HashStateStale:
+		return "Stale"
+	}
+	return fmt.Sprintf("HashState(%d)", int(s))
+}
+
+// HashErrorPolicy 决定计算哈希失败(如无权限、文件暂时无法读取)时如何记录该条目
+//
+// 无论哪种策略，失败都计入 Stats().HashErrors 并把 *HashError 发送到 ErrorChan
+type HashErrorPolicy int
+
+const (
+	// HashErrorEmptyHash 记录元信息，Hash 为空、HashState 为 Unreadable(默认，与早期版本一致)；
+	// 之前记录的有效哈希被覆盖
+	HashErrorEmptyHash HashErrorPolicy = iota
+	// HashErrorKeepPrevious 快照中已有该文件的有效哈希时沿用它，HashState 为 Stale，比较时按大小与修改时间判断是否修改；
+	// 没有可沿用的哈希时同 HashErrorEmptyHash
+	HashErrorKeepPrevious
+	// HashErrorDropEntry 不记录该文件：新文件不加入快照，已有的条目从快照中移除(事件为 Remove)
+	HashErrorDropEntry
+)
+
+// String 返回策略的可读名称
+func (p HashErrorPolicy) String() string {
+	switch p {
+	case HashErrorEmptyHash:
+		return "EmptyHash"
+	case HashErrorKeepPrevious:
+		return "KeepPrevious"
+	case HashErrorDropEntry:
+		return "DropEntry"
+	}
+	return fmt.Sprintf("HashErrorPolicy(%d)", int(p))
+}
+
+// valid 报告 p 是否为已定义的策略
+func (p HashErrorPolicy) valid() bool {
+	return p >= HashErrorEmptyHash && p <= HashErrorDropEntry
+}
+
+// hasContentHash 判断该条目的 Hash 是否可用于内容比较
+func (s HashState) hasContentHash() bool {
+	return s == HashStateHashed || s == HashStateUnknown
+}
+
+// hashResult 表示单个文件的哈希结果
+type hashResult struct {
+	hash      string
+	state     HashState
+	appended  int64
+	rewritten bool // 经过追加写检测但旧内容不是前缀
+	drop      bool // 哈希失败且 HashErrorPolicy 为 DropEntry，不记录该文件
+}
+
+// hashFor 按文件类型、大小与配置决定如何计算哈希
+//
+// 不可读的文件把 *HashError(包装底层错误，含errno)发送到 ErrorChan，并按 HashErrorPolicy 记录(见 hashFailed)
+// span 不为nil时，实际读取了文件内容的哈希会上报给 span.FileHashed
+func (w *Watcher) hashFor(path string, fileInfo os.FileInfo, prev *FileMetadata, span BatchSpan) hashResult {
+	switch {
+	case !fileInfo.Mode().IsRegular():
+		return hashResult{state: HashStateSkippedType}
+	case len(w.cfg.NoHashPatterns) > 0 && matchPatterns(w.cfg.NoHashPatterns, path):
+		return hashResult{state: HashStateSkippedType}
+	case fileInfo.Size() == 0:
+		return hashResult{hash: w.emptyHash, state: HashStateHashed}
+	case w.cfg.MaxHashSize > 0 && fileInfo.Size() > w.cfg.MaxHashSize:
+		return hashResult{state: HashStateSkippedSize}
+	case w.storm.degraded.Load():
+		w.storm.skippedBytes.Add(uint64(fileInfo.Size()))
+		return hashResult{state: HashStateSkippedDegraded}
+	}
+
+	var res hashResult
+	var err error
+	buf := w.bufs.get()
+	start := time.Now()
+	if w.appendCandidate(path, fileInfo, prev) {
+		var isAppend bool
+		res.hash, isAppend, err = hashFileAppend(w.fs, path, w.newHash, *buf, prev.Size, prev.Hash)
+		if err == nil {
+			if isAppend {
+				res.appended = fileInfo.Size() - prev.Size
+			} else {
+				res.rewritten = true
+			}
+		}
+	} else {
+		res.hash, err = hashFile(w.fs, path, w.newHash, *buf)
+	}
+	w.bufs.put(buf)
+	if span != nil {
+		span.FileHashed(path, start, time.Since(start), err)
+	}
+	if err != nil {
+		if errors.Is(err, ErrFileLocked) && w.scheduleHashRetry(path) {
+			return hashResult{state: HashStatePending}
+		}
+		w.counters.hashErrors.Add(1)
+		w.emitError(&HashError{Path: path, Err: err})
+		return w.hashFailed(prev)
+	}
+	w.clearHashRetry(path)
+	w.counters.hashLatency.observe(time.Since(start))
+	w.counters.hashOps.Add(1)
+	w.counters.bytesHashed.Add(uint64(fileInfo.Size()))
+	res.state = HashStateHashed
+	return res
+}
+
+// hashFailed 按 HashErrorPolicy 返回计算哈希失败时的结果，prev 为快照中该路径的记录(可为nil)
+func (w *Watcher) hashFailed(prev *FileMetadata) hashResult {
+	switch w.cfg.HashErrorPolicy {
+	case HashErrorKeepPrevious:
+		if prev != nil && !prev.IsDirectory && prev.Hash != "" && (prev.HashState == HashStateHashed || prev.HashState == HashStateStale) {
+			return hashResult{hash: prev.Hash, state: HashStateStale}
+		}
+	case HashErrorDropEntry:
+		return hashResult{state: HashStateUnreadable, drop: true}
+	}
+	return hashResult{state: HashStateUnreadable}
+}
@@ -0,0 +1,207 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestHashStateFastPaths 测试零字节文件与超大文件的哈希状态
+func TestHashStateFastPaths(t *testing.T) {
+	root := t.TempDir()
+	empty := filepath.Join(root, "empty")
+	big := filepath.Join(root, "big")
+	_ = os.WriteFile(empty, nil, 0644)
+	_ = os.WriteFile(big, make([]byte, 1024), 0644)
+
+	w := newTestWatcher(t, root)
+	w.cfg.MaxHashSize = 512
+
+	meta, _, err := w.RehashFile(empty)
+	if err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	want, _ := hashFile(osFS{}, empty, sha256.New, nil)
+	if meta.HashState != HashStateHashed || meta.Hash != want {
+		t.Errorf("zero-byte file: state=%v hash=%s; want Hashed %s", meta.HashState, meta.Hash, want)
+	}
+
+	meta, _, _ = w.RehashFile(big)
+	if meta.HashState != HashStateSkippedSize || meta.Hash != "" {
+		t.Errorf("oversized file: state=%v hash=%q; want SkippedSize with empty hash", meta.HashState, meta.Hash)
+	}
+}
+
+// TestNoHashPatterns 测试命中 NoHashPatterns 的文件只记录元信息，修改仍按大小与修改时间识别
+func TestNoHashPatterns(t *testing.T) {
+	root := t.TempDir()
+	movie := filepath.Join(root, "media", "movie.mp4")
+	note := filepath.Join(root, "media", "note.txt")
+	_ = os.MkdirAll(filepath.Dir(movie), 0755)
+	_ = os.WriteFile(movie, []byte("frames"), 0644)
+	_ = os.WriteFile(note, []byte("text"), 0644)
+
+	w := newTestWatcher(t, root)
+	w.cfg.NoHashPatterns = []string{"*.mp4", "*.iso"}
+
+	meta, _, err := w.RehashFile(movie)
+	if err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	if meta.HashState != HashStateSkippedType || meta.Hash != "" || meta.Size != 6 {
+		t.Errorf("movie: state=%v hash=%q size=%d; want SkippedType, empty hash, size 6", meta.HashState, meta.Hash, meta.Size)
+	}
+	if meta, _, _ := w.RehashFile(note); meta.HashState != HashStateHashed || meta.Hash == "" {
+		t.Errorf("note: state=%v hash=%q; want Hashed", meta.HashState, meta.Hash)
+	}
+
+	before := w.GetCurrentSnapshot()
+	_ = os.WriteFile(movie, []byte("more frames"), 0644)
+	if _, changed, _ := w.RehashFile(movie); !changed {
+		t.Fatal("size change of unhashed file not detected")
+	}
+	d := DiffNodes(before, w.GetCurrentSnapshot())
+	if len(d.Modified) != 1 || d.Modified[0].Path != movie {
+		t.Errorf("diff Modified = %+v; want [%s]", d.Modified, movie)
+	}
+}
+
+// TestHashStateUnreadable 测试不可读文件的状态与错误通道
+func TestHashStateUnreadable(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("permission bits are not enforced here")
+	}
+	root := t.TempDir()
+	file := filepath.Join(root, "secret")
+	_ = os.WriteFile(file, []byte("x"), 0000)
+
+	w := newTestWatcher(t, root)
+	meta, _, err := w.RehashFile(file)
+	if err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	if meta.HashState != HashStateUnreadable {
+		t.Errorf("state = %v; want Unreadable", meta.HashState)
+	}
+	select {
+	case e := <-w.ErrorChan:
+		var herr *HashError
+		if !errors.Is(e, os.ErrPermission) || !errors.As(e, &herr) || herr.Path != file {
+			t.Errorf("expected permission *HashError, got %v", e)
+		}
+	default:
+		t.Errorf("expected an error on ErrorChan")
+	}
+}
+
+// TestSameContentUnknownHash 测试不可读条目不会被视为内容变化
+func TestSameContentUnknownHash(t *testing.T) {
+	now := time.Now()
+	hashed := &FileMetadata{Size: 3, ModTime: now, Hash: "abc", HashState: HashStateHashed}
+	unreadable := &FileMetadata{Size: 3, ModTime: now, HashState: HashStateUnreadable}
+	if !sameContent(hashed, unreadable) {
+		t.Errorf("unreadable entry with same size/mtime should not be reported as modified")
+	}
+	grown := &FileMetadata{Size: 4, ModTime: now, HashState: HashStateUnreadable}
+	if sameContent(hashed, grown) {
+		t.Errorf("size change should still be reported")
+	}
+}
+
+// failingFS 在 fail 为 true 时让 Open 返回 EBUSY，模拟暂时无法读取的文件
+type failingFS struct {
+	FS
+	fail atomic.Bool
+}
+
+func (f *failingFS) Open(name string) (io.ReadCloser, error) {
+	if f.fail.Load() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EBUSY}
+	}
+	return f.FS.Open(name)
+}
+
+// TestHashErrorPolicy 测试已计算过哈希的文件再次计算失败时，各策略下快照中的记录，以及失败总会计数并发送 *HashError
+func TestHashErrorPolicy(t *testing.T) {
+	for _, policy := range []HashErrorPolicy{HashErrorEmptyHash, HashErrorKeepPrevious, HashErrorDropEntry} {
+		t.Run(policy.String(), func(t *testing.T) {
+			root := t.TempDir()
+			file := filepath.Join(root, "data.bin")
+			_ = os.WriteFile(file, []byte("v1"), 0644)
+			fsys := &failingFS{FS: osFS{}}
+			w, err := NewWatcherWithOptions([]string{root}, WithFS(fsys, replaySource{}), WithDisableEventChan(), WithHashErrorPolicy(policy))
+			if err != nil {
+				t.Fatalf("NewWatcherWithOptions failed: %v", err)
+			}
+			t.Cleanup(func() { _ = w.Close() })
+			p := w.keyOf(file)
+			w.handleFileChange(p, fsnotify.Create)
+			before := w.GetCurrentSnapshot()
+			prev := before.Files[p]
+			if prev == nil || prev.HashState != HashStateHashed {
+				t.Fatalf("initial entry = %+v; want Hashed", prev)
+			}
+
+			_ = os.WriteFile(file, []byte("version 2"), 0644)
+			fsys.fail.Store(true)
+			w.handleFileChange(p, fsnotify.Write)
+			after := w.GetCurrentSnapshot()
+
+			if got := w.Stats().HashErrors; got != 1 {
+				t.Errorf("HashErrors = %d; want 1", got)
+			}
+			select {
+			case e := <-w.ErrorChan:
+				var herr *HashError
+				if !errors.As(e, &herr) || herr.Path != p || !errors.Is(e, syscall.EBUSY) {
+					t.Errorf("error = %v; want *HashError wrapping EBUSY", e)
+				}
+			default:
+				t.Error("expected a *HashError on ErrorChan")
+			}
+
+			m := after.Files[p]
+			d := DiffNodes(before, after)
+			switch policy {
+			case HashErrorEmptyHash:
+				if m == nil || m.Hash != "" || m.HashState != HashStateUnreadable || m.Size != 9 {
+					t.Errorf("entry = %+v; want Unreadable with an empty hash and the new size", m)
+				}
+			case HashErrorKeepPrevious:
+				if m == nil || m.Hash != prev.Hash || m.HashState != HashStateStale || m.Size != 9 {
+					t.Errorf("entry = %+v; want Stale keeping hash %s", m, prev.Hash)
+				}
+				if len(d.Modified) != 1 {
+					t.Errorf("diff = %+v; the size change should still show as modified", d)
+				}
+			case HashErrorDropEntry:
+				if m != nil {
+					t.Errorf("entry = %+v; want it dropped", m)
+				}
+				if len(d.Removed) != 1 || d.Removed[0].Path != p {
+					t.Errorf("diff Removed = %+v; want [%s]", d.Removed, p)
+				}
+			}
+
+			// 恢复可读后正常计算哈希
+			fsys.fail.Store(false)
+			w.handleFileChange(p, fsnotify.Write)
+			if m := w.GetCurrentSnapshot().Files[p]; m == nil || m.HashState != HashStateHashed || m.Hash == prev.Hash {
+				t.Errorf("after recovery entry = %+v; want a fresh hash", m)
+			}
+		})
+	}
+	if _, err := NewWatcherWithOptions([]string{t.TempDir()}, WithHashErrorPolicy(HashErrorPolicy(9))); err == nil {
+		t.Error("an unknown policy should be rejected")
+	}
+}
@@ -0,0 +1,179 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthStatus 是 Health() 推导出的整体状态
+type HealthStatus int
+
+const (
+	HealthHealthy  HealthStatus = iota // 正常
+	HealthDegraded                     // 可用但存在问题(部分目录未监控、积压、flush 延迟)
+	HealthFailed                       // 不可用(未运行、fsnotify 已退出、全部目录注册失败、队列已满或 flush 停滞)
+)
+
+// String 返回状态的可读名称
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthHealthy:
+		return "Healthy"
+	case HealthDegraded:
+		return "Degraded"
+	case HealthFailed:
+		return "Failed"
+	}
+	return fmt.Sprintf("HealthStatus(%d)", int(s))
+}
+
+// HealthThresholds 是推导 HealthStatus 的阈值，零值字段使用默认值
+//
+// BacklogDegraded/BacklogFailed：合并通道或 EventChan 的填充率(0~1)达到该值时判为降级/失败，默认 0.8 / 1.0
+// FlushDelayDegraded/FlushDelayFailed：距离上次 flush 超过该时长时判为降级/失败，默认 5s / 30s
+// (初始扫描期间 flush 暂停，不参与判断)
+type HealthThresholds struct {
+	BacklogDegraded    float64
+	BacklogFailed      float64
+	FlushDelayDegraded time.Duration
+	FlushDelayFailed   time.Duration
+}
+
+// withDefaults 填充默认阈值
+func (t HealthThresholds) withDefaults() HealthThresholds {
+	if t.BacklogDegraded <= 0 {
+		t.BacklogDegraded = 0.8
+	}
+	if t.BacklogFailed <= 0 {
+		t.BacklogFailed = 1.0
+	}
+	if t.FlushDelayDegraded <= 0 {
+		t.FlushDelayDegraded = 5 * time.Second
+	}
+	if t.FlushDelayFailed <= 0 {
+		t.FlushDelayFailed = 30 * time.Second
+	}
+	return t
+}
+
+// HealthReport 是 Health() 的结果
+//
+// Reasons 说明 Status 不是 Healthy 的原因；时间字段在对应动作从未发生时为零值
+type HealthReport struct {
+	Status  HealthStatus
+	Reasons []string
+
+	Running       bool // 已 Start 且未 Stop
+	FsnotifyAlive bool // fsnotify 事件读取goroutine仍在运行
+	Scanning      bool // 初始扫描进行中
+
+	WatchedDirs   int // 当前注册到 fsnotify 的目录数
+	WatchFailures int // Start 时注册失败的目录数
+	WatchSkipped  int // 因 MaxWatchedDirs 未注册监控的目录数(含运行中新建的目录)
+
+	LastEventAt    time.Time     // 最近一次处理完变更(发出事件)的时间
+	SinceLastEvent time.Duration // 距今时长(从未处理过时为0)
+	LastFlushAt    time.Time     // 最近一次 flush 的时间
+	SinceLastFlush time.Duration // 距今时长(从未 flush 时为0)
+
+	AggBacklog    int
+	AggCapacity   int
+	EventBacklog  int
+	EventCapacity int
+}
+
+// Health 汇总 Watcher 是否真正可用，可直接用于就绪探针
+//
+// 阈值见 HealthThresholds，可通过 ConfigWatcher.HealthThresholds 覆盖
+// 并发安全
+func (w *Watcher) Health() HealthReport {
+	now := w.now()
+	r := HealthReport{
+		Running:       w.started.Load() && !w.stopped(),
+		FsnotifyAlive: w.fsAlive.Load(),
+		Scanning:      w.scanning.Load(),
+		AggBacklog:    len(w.aggChan),
+		AggCapacity:   cap(w.aggChan),
+		EventBacklog:  len(w.EventChan),
+		EventCapacity: cap(w.EventChan),
+	}
+	if ns := w.counters.lastEventAt.Load(); ns != 0 {
+		r.LastEventAt = time.Unix(0, ns)
+		r.SinceLastEvent = now.Sub(r.LastEventAt)
+	}
+	if ns := w.counters.lastFlushAt.Load(); ns != 0 {
+		r.LastFlushAt = time.Unix(0, ns)
+		r.SinceLastFlush = now.Sub(r.LastFlushAt)
+	}
+	w.mu.RLock()
+	r.WatchFailures = len(w.watchErrs)
+	w.mu.RUnlock()
+	if r.Running {
+		r.WatchedDirs = len(w.fsWatcher.WatchList())
+	}
+	_, r.WatchSkipped = w.watches.counts()
+
+	th := w.cfg.HealthThresholds.withDefaults()
+	fail := func(format string, args ...any) {
+		r.Status = HealthFailed
+		r.Reasons = append(r.Reasons, fmt.Sprintf(format, args...))
+	}
+	degrade := func(format string, args ...any) {
+		if r.Status < HealthDegraded {
+			r.Status = HealthDegraded
+		}
+		r.Reasons = append(r.Reasons, fmt.Sprintf(format, args...))
+	}
+
+	if !r.Running {
+		fail("watcher is not running")
+		return r
+	}
+	if !r.FsnotifyAlive {
+		fail("fsnotify event loop has exited")
+	}
+	if r.WatchFailures > 0 {
+		if r.WatchedDirs == 0 {
+			fail("all %d directory watches failed", r.WatchFailures)
+		} else {
+			degrade("%d of %d directory watches failed", r.WatchFailures, r.WatchFailures+r.WatchedDirs)
+		}
+	}
+	if r.WatchSkipped > 0 {
+		degrade("%d directories unwatched: MaxWatchedDirs (%d) reached", r.WatchSkipped, w.cfg.MaxWatchedDirs)
+	}
+	for _, q := range []struct {
+		name     string
+		len, cap int
+	}{{"aggregation queue", r.AggBacklog, r.AggCapacity}, {"event channel", r.EventBacklog, r.EventCapacity}} {
+		if q.cap == 0 {
+			continue // DisableEventChan
+		}
+		fill := float64(q.len) / float64(q.cap)
+		switch {
+		case fill >= th.BacklogFailed:
+			fail("%s is full (%d/%d)", q.name, q.len, q.cap)
+		case fill >= th.BacklogDegraded:
+			degrade("%s is %.0f%% full (%d/%d)", q.name, fill*100, q.len, q.cap)
+		}
+	}
+	if !r.Scanning && !r.LastFlushAt.IsZero() {
+		switch {
+		case r.SinceLastFlush >= th.FlushDelayFailed:
+			fail("no flush for %s", r.SinceLastFlush.Round(time.Millisecond))
+		case r.SinceLastFlush >= th.FlushDelayDegraded:
+			degrade("no flush for %s", r.SinceLastFlush.Round(time.Millisecond))
+		}
+	}
+	return r
+}
+
+// stopped 判断 Stop 是否已被调用
+func (w *Watcher) stopped() bool {
+	select {
+	case <-w.stopChan:
+		return true
+	default:
+		return false
+	}
+}
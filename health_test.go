@@ -0,0 +1,58 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHealth 测试运行前后与积压时的健康状态
+func TestHealth(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcher(ConfigWatcher{
+		WatchPaths:       []string{root},
+		Debounce:         5 * time.Millisecond,
+		HealthThresholds: HealthThresholds{BacklogDegraded: 1e-9},
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if h := w.Health(); h.Status != HealthFailed || h.Running {
+		t.Errorf("before Start: %+v", h)
+	}
+
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waitFor(t, time.Second, func() bool { return !w.Health().LastFlushAt.IsZero() })
+	h := w.Health()
+	if h.Status != HealthHealthy || !h.FsnotifyAlive || h.WatchedDirs != 1 || len(h.Reasons) != 0 {
+		t.Errorf("after Start: %+v", h)
+	}
+
+	// 事件留在 EventChan 中未消费，按极低的阈值应判为降级
+	_ = os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644)
+	waitFor(t, 2*time.Second, func() bool { return w.Health().EventBacklog > 0 })
+	h = w.Health()
+	if h.Status != HealthDegraded || h.LastEventAt.IsZero() || !strings.Contains(strings.Join(h.Reasons, ";"), "event channel") {
+		t.Errorf("with backlog: %+v", h)
+	}
+
+	w.Stop()
+	if h := w.Health(); h.Status != HealthFailed || h.Running {
+		t.Errorf("after Stop: %+v", h)
+	}
+}
+
+// TestHealthThresholdDefaults 测试默认阈值
+func TestHealthThresholdDefaults(t *testing.T) {
+	th := HealthThresholds{FlushDelayFailed: time.Minute}.withDefaults()
+	if th.BacklogDegraded != 0.8 || th.BacklogFailed != 1 || th.FlushDelayDegraded != 5*time.Second || th.FlushDelayFailed != time.Minute {
+		t.Errorf("unexpected thresholds %+v", th)
+	}
+	if HealthDegraded.String() != "Degraded" {
+		t.Errorf("String() = %q", HealthDegraded.String())
+	}
+}
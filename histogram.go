@@ -0,0 +1,154 @@
+package watcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// defaultLatencyBounds 是延迟直方图的桶上界，覆盖从百微秒到分钟级的处理耗时，
+// 按 1-2-5 划分，使桶内插值得到的分位数误差不超过相邻上界之比
+var defaultLatencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	200 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// HistogramSnapshot 是一个延迟直方图的快照
+//
+// Counts[i] 为落入 (Bounds[i-1], Bounds[i]] 区间的观测数(非累计)，
+// Counts 比 Bounds 多一个元素，最后一个为超过最大上界(+Inf)的观测数
+//
+// P50/P95/P99 是按桶线性插值估计的分位数(见 Quantile)，Max 是观测到的最大值，没有观测时均为0
+type HistogramSnapshot struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// Quantile 估计 q(0~1)分位数：找到所在的桶，在桶的上下界之间按排名线性插值，结果不超过 Max
+//
+// 落入最后一个桶(超过最大上界)时以 Max 为上界；没有观测时返回0
+func (s HistogramSnapshot) Quantile(q float64) time.Duration {
+	if s.Count == 0 || len(s.Counts) == 0 {
+		return 0
+	}
+	q = min(max(q, 0), 1)
+	rank := q * float64(s.Count)
+	var cum uint64
+	for i, c := range s.Counts {
+		if c == 0 || float64(cum+c) < rank {
+			cum += c
+			continue
+		}
+		var lo, hi time.Duration
+		if i > 0 {
+			lo = s.Bounds[i-1]
+		}
+		if i < len(s.Bounds) {
+			hi = s.Bounds[i]
+		} else {
+			hi = max(s.Max, lo)
+		}
+		d := lo + time.Duration(float64(hi-lo)*(rank-float64(cum))/float64(c))
+		if s.Max > 0 && d > s.Max {
+			d = s.Max
+		}
+		return d
+	}
+	return s.Max
+}
+
+// fillQuantiles 按当前分桶计算 P50/P95/P99
+func (s *HistogramSnapshot) fillQuantiles() {
+	s.P50, s.P95, s.P99 = s.Quantile(0.50), s.Quantile(0.95), s.Quantile(0.99)
+}
+
+// latencyHistogram 是固定分桶的并发安全直方图，观测只做原子加法
+type latencyHistogram struct {
+	bounds []time.Duration
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Int64
+	max    atomic.Int64
+}
+
+// newLatencyHistogram 使用默认分桶创建直方图
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{
+		bounds: defaultLatencyBounds,
+		counts: make([]atomic.Uint64, len(defaultLatencyBounds)+1),
+	}
+}
+
+// observe 记录一次观测，h 为 nil 时忽略
+func (h *latencyHistogram) observe(d time.Duration) {
+	if h == nil {
+		return
+	}
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+	for {
+		m := h.max.Load()
+		if int64(d) <= m || h.max.CompareAndSwap(m, int64(d)) {
+			return
+		}
+	}
+}
+
+// reset 清空全部观测，与并发的 observe 之间不保证原子性(重置瞬间的少量观测可能只计入部分字段)
+func (h *latencyHistogram) reset() {
+	if h == nil {
+		return
+	}
+	for i := range h.counts {
+		h.counts[i].Store(0)
+	}
+	h.count.Store(0)
+	h.sum.Store(0)
+	h.max.Store(0)
+}
+
+// snapshot 读取直方图当前状态并计算分位数，h 为 nil 时返回空快照；分位数只在读取时计算，观测路径上没有额外开销
+func (h *latencyHistogram) snapshot() HistogramSnapshot {
+	if h == nil {
+		return HistogramSnapshot{}
+	}
+	s := HistogramSnapshot{
+		Bounds: append([]time.Duration(nil), h.bounds...),
+		Counts: make([]uint64, len(h.counts)),
+		Count:  h.count.Load(),
+		Sum:    time.Duration(h.sum.Load()),
+		Max:    time.Duration(h.max.Load()),
+	}
+	for i := range h.counts {
+		s.Counts[i] = h.counts[i].Load()
+	}
+	s.fillQuantiles()
+	return s
+}
@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestHistogramQuantiles 测试分位数按桶插值、不超过 Max，reset 后清零
+func TestHistogramQuantiles(t *testing.T) {
+	h := newLatencyHistogram()
+	for i := 0; i < 90; i++ {
+		h.observe(3 * time.Millisecond) // (2ms, 5ms]
+	}
+	for i := 0; i < 10; i++ {
+		h.observe(40 * time.Millisecond) // (20ms, 50ms]
+	}
+	s := h.snapshot()
+	if s.Count != 100 || s.Max != 40*time.Millisecond {
+		t.Fatalf("count=%d max=%v; want 100/40ms", s.Count, s.Max)
+	}
+	if s.P50 <= 2*time.Millisecond || s.P50 > 5*time.Millisecond {
+		t.Errorf("P50 = %v; want within (2ms, 5ms]", s.P50)
+	}
+	if s.P95 <= 20*time.Millisecond || s.P95 > 40*time.Millisecond {
+		t.Errorf("P95 = %v; want within (20ms, 40ms]", s.P95)
+	}
+	if s.P99 < s.P95 || s.P99 > s.Max {
+		t.Errorf("P99 = %v; want between P95 %v and Max %v", s.P99, s.P95, s.Max)
+	}
+
+	h.observe(2 * time.Minute) // 超过最大上界时以 Max 为上界
+	if q := h.snapshot().Quantile(1); q != 2*time.Minute {
+		t.Errorf("Quantile(1) = %v; want 2m", q)
+	}
+
+	h.reset()
+	if s := h.snapshot(); s.Count != 0 || s.Max != 0 || s.P99 != 0 || s.Sum != 0 {
+		t.Errorf("after reset: %+v", s)
+	}
+}
+
+// TestBatchLatencyIncludesQueueing 测试批次延迟从事件进入合并通道算起，ResetLatencyStats 不影响 BatchesCompleted
+func TestBatchLatencyIncludesQueueing(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("hello"), 0644)
+	w := newTestWatcher(t, root)
+
+	w.queueAgg(fsnotify.Event{Name: file, Op: fsnotify.Create})
+	time.Sleep(20 * time.Millisecond)
+	w.drainAggChan()
+	w.flushAgg(true)
+	w.workerWG.Wait()
+
+	st := w.Stats()
+	if st.BatchLatency.Count != 1 || st.BatchLatency.Max < 20*time.Millisecond {
+		t.Fatalf("batch latency count=%d max=%v; want 1 batch >= 20ms", st.BatchLatency.Count, st.BatchLatency.Max)
+	}
+	if st.HashLatency.Count != 1 || st.HashLatency.P50 == 0 {
+		t.Errorf("hash latency count=%d p50=%v", st.HashLatency.Count, st.HashLatency.P50)
+	}
+
+	w.ResetLatencyStats()
+	st = w.Stats()
+	if st.BatchLatency.Count != 0 || st.HashLatency.Count != 0 || st.BatchLatency.Max != 0 {
+		t.Errorf("latency not reset: %+v / %+v", st.BatchLatency, st.HashLatency)
+	}
+	if st.BatchesCompleted != 1 || st.BatchesProcessed != 1 {
+		t.Errorf("batches processed=%d completed=%d; want 1/1", st.BatchesProcessed, st.BatchesCompleted)
+	}
+}
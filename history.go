@@ -0,0 +1,55 @@
+package watcher
+
+import (
+	"time"
+)
+
+// FileVersion 表示某个路径在历史中的一个版本
+//
+// SnapshotID/CreatedAt：引入该版本的快照
+// Meta：该版本的元信息；为nil表示该快照中路径被删除
+type FileVersion struct {
+	SnapshotID string
+	CreatedAt  time.Time
+	Meta       *FileMetadata
+}
+
+// FileHistory 返回路径在当前分支上的变更历史(从新到旧)
+//
+// 从当前快照沿第一个父节点回溯，路径的元信息与父快照相比发生变化(新增、修改、删除)时记录一个版本
+// 目录的子树发生变化(目录哈希变化)也视为一个新版本；墓碑视为不存在，删除的版本 Meta 为nil
+// 路径从未出现过或 DisableSnapshots 时返回nil；回溯到已换出的快照时从 Store 逐个读回
+// 并发安全
+func (w *Watcher) FileHistory(path string) []FileVersion {
+	if w.cfg.DisableSnapshots {
+		return nil
+	}
+	path = w.keyOf(path)
+
+	// 快照不可变，沿父链回溯不需要加锁
+	var out []FileVersion
+	for sn := w.head.Load(); sn != nil; {
+		var parent *SnapshotNode
+		if len(sn.ParentIDs) > 0 {
+			parent = w.snapshotByID(sn.ParentIDs[0])
+		}
+		cur := live(sn.Files[path])
+		var prev *FileMetadata
+		if parent != nil {
+			prev = live(parent.Files[path])
+		}
+		switch {
+		case cur == nil && prev != nil:
+			out = append(out, FileVersion{SnapshotID: sn.ID, CreatedAt: sn.CreatedAt})
+		case cur != nil && (prev == nil || versionChanged(prev, cur)):
+			out = append(out, FileVersion{SnapshotID: sn.ID, CreatedAt: sn.CreatedAt, Meta: cur})
+		}
+		sn = parent
+	}
+	return out
+}
+
+// versionChanged 判断两个版本是否不同；与 metaChanged 不同，目录还会比较子树哈希
+func versionChanged(prev, cur *FileMetadata) bool {
+	return metaChanged(prev, cur) || (cur.IsDirectory && prev.Hash != cur.Hash)
+}
@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestFileHistory 测试沿第一父节点收集路径的新增、修改与删除
+func TestFileHistory(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	other := filepath.Join(root, "b.txt")
+	_ = os.WriteFile(file, []byte("one"), 0644)
+	_ = os.WriteFile(other, []byte("b"), 0644)
+
+	w := newTestWatcher(t, root)
+	w.handleFileChange(file, fsnotify.Create)
+	created := w.GetCurrentSnapshot().ID
+	w.handleFileChange(other, fsnotify.Create) // 不涉及 file，不应出现在历史中
+	_ = os.WriteFile(file, []byte("two!"), 0644)
+	w.handleFileChange(file, fsnotify.Write)
+	modified := w.GetCurrentSnapshot().ID
+	_ = os.Remove(file)
+	w.handleFileChange(file, fsnotify.Remove)
+	removed := w.GetCurrentSnapshot().ID
+
+	h := w.FileHistory(file)
+	if len(h) != 3 {
+		t.Fatalf("got %d versions; want 3: %+v", len(h), h)
+	}
+	if h[0].SnapshotID != removed || h[0].Meta != nil {
+		t.Errorf("newest version should be the removal, got %+v", h[0])
+	}
+	if h[1].SnapshotID != modified || h[1].Meta.Size != 4 {
+		t.Errorf("second version should be the modification, got %+v", h[1])
+	}
+	if h[2].SnapshotID != created || h[2].Meta.Size != 3 {
+		t.Errorf("oldest version should be the creation, got %+v", h[2])
+	}
+
+	if got := w.FileHistory(root); len(got) != 4 {
+		t.Errorf("root directory history: got %d versions; want 4 (one per subtree change)", len(got))
+	}
+	if w.FileHistory(filepath.Join(root, "never")) != nil {
+		t.Error("unknown path should have no history")
+	}
+}
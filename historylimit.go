@@ -0,0 +1,187 @@
+package watcher
+
+import (
+	"slices"
+	"sort"
+)
+
+// HistoryLimit 限制命中 Pattern 的路径保留的版本数，第一个命中的规则生效
+//
+// 每次发布快照后，版本数超过上限时从最旧的版本开始合并只变更了命中规则的路径的快照：删除该快照，
+// 其子快照改接到它的父快照上。HEAD、有标签的、合并产生的、ChangedPaths 被截断的以及已换出到 Store 的快照不会被合并，
+// 合并的快照数见 Stats().SnapshotsSquashed
+type HistoryLimit struct {
+	Pattern  string // 通配符，规则同 IgnorePatterns(如 "*.log"、"/data/**/*.db")
+	Versions int    // 最多保留的版本(变更了该路径的快照)数，大于0
+}
+
+// historyIndex 是 HistoryLimits 使用的索引，只在配置了 HistoryLimits 时维护，受 w.mu 保护
+type historyIndex struct {
+	versions map[string][]string // 路径 -> 变更了该路径的快照ID(从旧到新)，只记录命中 HistoryLimits 的路径
+	children map[string][]string // 快照ID -> 子快照ID
+}
+
+// historyLimit 返回路径的版本数上限，第一个命中的规则生效，没有命中时返回0
+func (w *Watcher) historyLimit(path string) int {
+	for _, l := range w.cfg.HistoryLimits {
+		if matchPatterns([]string{l.Pattern}, path) {
+			return l.Versions
+		}
+	}
+	return 0
+}
+
+// limitHistoryLocked 把新发布的快照登记到索引中，并合并超出版本数上限的旧快照，调用方需持有 w.mu 写锁
+//
+// 对每个超限路径，从最旧的版本开始找可合并的快照(见 squashableLocked)合并到其子快照中，
+// 直到版本数不超过上限或没有可合并的快照
+func (w *Watcher) limitHistoryLocked(sn *SnapshotNode) {
+	if len(w.cfg.HistoryLimits) == 0 {
+		return
+	}
+	h := &w.history
+	if h.versions == nil {
+		h.versions = make(map[string][]string)
+		h.children = make(map[string][]string)
+	}
+	for _, pid := range sn.ParentIDs {
+		h.children[pid] = append(h.children[pid], sn.ID)
+	}
+	var hot []string
+	for _, p := range sn.ChangedPaths {
+		if w.historyLimit(p) > 0 {
+			h.versions[p] = append(h.versions[p], sn.ID)
+			hot = append(hot, p)
+		}
+	}
+	for _, p := range hot {
+		limit := w.historyLimit(p)
+	squash:
+		for len(h.versions[p]) > limit {
+			for _, id := range h.versions[p] {
+				if s := w.snapshots.get(id); s != nil && w.squashableLocked(s) {
+					w.squashLocked(s)
+					continue squash
+				}
+			}
+			break
+		}
+	}
+}
+
+// squashableLocked 报告快照能否被合并：只有一个父快照、变更路径完整且全部命中 HistoryLimits，
+// 不是 HEAD、没有标签，自身、父快照与子快照都在内存中(未换出)，且自身没有正在写入 Store
+func (w *Watcher) squashableLocked(s *SnapshotNode) bool {
+	if s == w.head.Load() || s.spilled || s.ID == w.spill.writing || len(s.ParentIDs) != 1 || s.ChangedPaths == nil || s.Truncated {
+		return false
+	}
+	for _, p := range s.ChangedPaths {
+		if w.historyLimit(p) <= 0 {
+			return false
+		}
+	}
+	for _, id := range w.tags {
+		if id == s.ID {
+			return false
+		}
+	}
+	if w.snapshots.get(s.ParentIDs[0]) == nil {
+		return false
+	}
+	for _, cid := range w.history.children[s.ID] {
+		if c := w.snapshots.get(cid); c == nil || c.spilled {
+			return false
+		}
+	}
+	return true
+}
+
+// squashLocked 删除快照 s，把它的子快照改接到 s 的父快照上，调用方需持有 w.mu 写锁
+//
+// 快照不可变：子快照替换为父节点不同的新节点(Files 共享)；以 s 为第一个父快照的子快照，
+// 其 ChangedPaths 并入 s 的变更路径，使其仍是相对新父快照的完整变更，超过 MaxChangedPaths 时截断
+func (w *Watcher) squashLocked(s *SnapshotNode) {
+	h := &w.history
+	parentID := s.ParentIDs[0]
+	kids := h.children[s.ID]
+	for _, cid := range kids {
+		c := w.snapshots.get(cid)
+		nc := &SnapshotNode{
+			ID:          c.ID,
+			Instance:    c.Instance,
+			CreatedAt:   c.CreatedAt,
+			Description: c.Description,
+			Files:       c.Files,
+			RootHash:    c.RootHash,
+			Config:      c.Config,
+
+			ChangedPaths: c.ChangedPaths,
+			Truncated:    c.Truncated,
+		}
+		for _, pid := range c.ParentIDs {
+			if pid == s.ID {
+				pid = parentID
+			}
+			if !slices.Contains(nc.ParentIDs, pid) {
+				nc.ParentIDs = append(nc.ParentIDs, pid)
+			}
+		}
+		if c.ParentIDs[0] == s.ID && c.ChangedPaths != nil {
+			nc.ChangedPaths = mergeChangedPaths(c.ChangedPaths, s.ChangedPaths)
+			if len(nc.ChangedPaths) > w.cfg.MaxChangedPaths {
+				nc.ChangedPaths = nc.ChangedPaths[:w.cfg.MaxChangedPaths:w.cfg.MaxChangedPaths]
+				nc.Truncated = true
+			}
+			for _, p := range s.ChangedPaths {
+				h.versions[p] = replaceID(h.versions[p], s.ID, c.ID)
+			}
+		}
+		w.snapshots.put(nc)
+		if w.head.Load() == c {
+			w.head.Store(nc)
+		}
+	}
+
+	for _, p := range s.ChangedPaths {
+		h.versions[p] = slices.DeleteFunc(h.versions[p], func(id string) bool { return id == s.ID })
+	}
+	siblings := slices.DeleteFunc(h.children[parentID], func(id string) bool { return id == s.ID })
+	for _, cid := range kids {
+		if !slices.Contains(siblings, cid) {
+			siblings = append(siblings, cid)
+		}
+	}
+	h.children[parentID] = siblings
+	delete(h.children, s.ID)
+
+	w.snapshots.del(s.ID)
+	if i := slices.Index(w.spill.resident, s.ID); i >= 0 {
+		w.spill.resident = slices.Delete(w.spill.resident, i, i+1)
+		w.counters.snapshotsInMemory.Store(int64(len(w.spill.resident)))
+	}
+	w.counters.snapshotsSquashed.Add(1)
+}
+
+// mergeChangedPaths 返回两个有序路径列表的有序并集
+func mergeChangedPaths(a, b []string) []string {
+	out := make([]string, 0, len(a)+len(b))
+	out = append(out, a...)
+	for _, p := range b {
+		if _, found := slices.BinarySearch(a, p); !found {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// replaceID 把 ids 中的 old 替换为 cur；cur 已存在时只删除 old
+func replaceID(ids []string, old, cur string) []string {
+	if slices.Contains(ids, cur) {
+		return slices.DeleteFunc(ids, func(id string) bool { return id == old })
+	}
+	if i := slices.Index(ids, old); i >= 0 {
+		ids[i] = cur
+	}
+	return ids
+}
@@ -0,0 +1,206 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestHistoryLimitSquash 测试只变更了超限路径的旧快照被合并，DAG 保持有效，其它变更、标签与 HEAD 保留
+func TestHistoryLimitSquash(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithHistoryLimit("*.log", 2))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+
+	hot := filepath.Join(root, "app.log")
+	cold := filepath.Join(root, "conf.txt")
+	write := func(path, content string) *SnapshotNode {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		w.handleFileChange(path, fsnotify.Write)
+		return w.GetCurrentSnapshot()
+	}
+
+	write(cold, "v1")
+	tagged := write(hot, "0")
+	if err := w.TagSnapshot("keep", tagged.ID); err != nil {
+		t.Fatalf("TagSnapshot failed: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		write(hot, fmt.Sprint(i))
+	}
+	write(cold, "v2")
+	write(hot, "6")
+
+	// 初始 + conf(v1) + 7 个日志版本 + conf(v2)，日志最多保留2个可合并的版本(外加有标签的一个)
+	st := w.Stats()
+	if st.SnapshotsCreated != 9 || st.SnapshotsSquashed == 0 || st.SnapshotCount != 1+int(st.SnapshotsCreated-st.SnapshotsSquashed) {
+		t.Fatalf("created=%d squashed=%d count=%d", st.SnapshotsCreated, st.SnapshotsSquashed, st.SnapshotCount)
+	}
+	if w.GetSnapshotByID(tagged.ID) == nil {
+		t.Error("tagged snapshot squashed")
+	}
+
+	// 每个父快照都存在；沿父链能到达初始快照；conf.txt 的两个版本都还在
+	var confVersions int
+	for _, sn := range w.ListAllSnapshots() {
+		for _, pid := range sn.ParentIDs {
+			if w.GetSnapshotByID(pid) == nil {
+				t.Errorf("snapshot %s has missing parent %s", sn.ID, pid)
+			}
+		}
+	}
+	for _, v := range w.FileHistory(cold) {
+		if v.Meta != nil {
+			confVersions++
+		}
+	}
+	if confVersions != 2 {
+		t.Errorf("conf.txt has %d versions; want 2", confVersions)
+	}
+	if n := len(w.FileHistory(hot)); n > 4 {
+		t.Errorf("app.log has %d versions; want at most 4", n)
+	}
+
+	// 合并后父子快照的差异仍与完整比较一致
+	head := w.GetCurrentSnapshot()
+	parent := w.GetSnapshotByID(head.ParentIDs[0])
+	d, err := w.DiffSnapshots(parent.ID, head.ID)
+	if err != nil {
+		t.Fatalf("DiffSnapshots failed: %v", err)
+	}
+	if len(d.Modified) != 1 || d.Modified[0].Path != hot {
+		t.Errorf("head diff = %+v; want app.log modified", d)
+	}
+	if got := head.Files[hot]; got == nil || got.Size != 1 {
+		t.Errorf("head app.log = %+v", got)
+	}
+}
+
+// gatedStore 在写入 gate 指定的快照时通知 entered 并等待 release，用于构造写入期间的并发
+type gatedStore struct {
+	SnapshotStore
+	gate    string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *gatedStore) Put(sn *SnapshotNode) error {
+	if sn.ID == s.gate {
+		close(s.entered)
+		<-s.release
+	}
+	return s.SnapshotStore.Put(sn)
+}
+
+// assertNoOrphanRecords 检查 Store 中的每个记录都仍在历史中，且 ValidateStore 没有发现问题
+func assertNoOrphanRecords(t *testing.T, w *Watcher, store SnapshotStore) {
+	t.Helper()
+	ids, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if w.snapshots.get(id) == nil {
+			t.Errorf("store record %s is not in the history", id)
+		}
+	}
+	if issues := w.ValidateStore(); len(issues) != 0 {
+		t.Errorf("ValidateStore = %v", issues)
+	}
+}
+
+// TestHistoryLimitSpillRace 测试 HistoryLimits 与 MemorySnapshots 同时配置时，正在写入 Store 的快照不会被合并
+func TestHistoryLimitSpillRace(t *testing.T) {
+	root := t.TempDir()
+	dir, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &gatedStore{SnapshotStore: dir, entered: make(chan struct{}), release: make(chan struct{})}
+	w, err := NewWatcherWithOptions([]string{root}, WithStore(store, 1), WithHistoryLimit("*.log", 2))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	path := filepath.Join(root, "app.log")
+
+	ids := commitVersions(w, path, 2)
+	store.gate = ids[0]
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.spillOld()
+	}()
+	<-store.entered
+
+	// 第三个版本超出上限：ids[0] 正在写入，只能合并 ids[1]
+	commitVersions(w, path, 1)
+	close(store.release)
+	<-done
+
+	if w.GetSnapshotByID(ids[0]) == nil || w.GetSnapshotByID(ids[1]) != nil {
+		t.Errorf("want %s kept and %s squashed", ids[0], ids[1])
+	}
+	if st := w.Stats(); st.SnapshotsSquashed != 1 || st.SnapshotsInMemory != 1 {
+		t.Errorf("squashed=%d inMemory=%d; want 1/1", st.SnapshotsSquashed, st.SnapshotsInMemory)
+	}
+	assertNoOrphanRecords(t, w, dir)
+}
+
+// TestHistoryLimitSpillConcurrent 测试后台换出与合并并发进行(配合 -race)，历史与 Store 保持一致
+func TestHistoryLimitSpillConcurrent(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcherWithOptions([]string{root}, WithStore(store, 2), WithHistoryLimit("*.log", 3))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	path := filepath.Join(root, "app.log")
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				w.spillOld()
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		commitVersions(w, path, 1)
+	}
+	close(stop)
+	wg.Wait()
+	w.spillOld()
+
+	for _, sn := range w.ListAllSnapshots() {
+		for _, pid := range sn.ParentIDs {
+			if w.GetSnapshotByID(pid) == nil {
+				t.Errorf("snapshot %s has missing parent %s", sn.ID, pid)
+			}
+		}
+	}
+	if st := w.Stats(); st.SnapshotsInMemory != 2 {
+		t.Errorf("inMemory=%d; want 2", st.SnapshotsInMemory)
+	}
+	assertNoOrphanRecords(t, w, store)
+}
@@ -0,0 +1,138 @@
+package watcher
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHotPathCapacity 是 ConfigWatcher.HotPathCapacity 的默认值
+const DefaultHotPathCapacity = 10000
+
+// hotBuckets 是滑动窗口划分的桶数，计数的时间精度为 HotPathWindow/hotBuckets
+const hotBuckets = 10
+
+// hotPathsDumpN 是 DumpState 输出的热点路径数
+const hotPathsDumpN = 50
+
+// PathCount 是 HotPaths 返回的单个路径的事件计数
+type PathCount struct {
+	Path     string    `json:"path"`
+	Count    uint64    `json:"count"`     // 滑动窗口(HotPathWindow)内的事件数
+	LastSeen time.Time `json:"last_seen"` // 最近一次事件的时间
+}
+
+// hotTracker 按路径统计滑动窗口内的事件数，最多跟踪 capacity 个路径，超出时淘汰最久没有事件的路径
+//
+// 频繁产生事件的路径总是最近出现过，不会被淘汰，因此排在前面的计数是准确的；
+// 被淘汰后再次出现的路径从0开始计数
+type hotTracker struct {
+	mu      sync.Mutex
+	bucket  time.Duration
+	cap     int
+	entries map[string]*list.Element // 路径 -> lru 中的 *hotEntry
+	lru     list.List                // 最近有事件的在前
+}
+
+// hotEntry 是单个路径的分桶计数，counts[b%hotBuckets] 是第 b 个桶(按 bucket 划分的时间段)的事件数
+type hotEntry struct {
+	path     string
+	counts   [hotBuckets]uint32
+	last     int64 // 最近一次事件所在的桶
+	lastSeen time.Time
+}
+
+// init 按配置初始化，HotPathWindow 为0时不启用
+func (t *hotTracker) init(cfg *ConfigWatcher) {
+	if cfg.HotPathWindow <= 0 {
+		return
+	}
+	t.bucket = max(cfg.HotPathWindow/hotBuckets, 1)
+	t.cap = cfg.HotPathCapacity
+	if t.cap <= 0 {
+		t.cap = DefaultHotPathCapacity
+	}
+	t.entries = make(map[string]*list.Element)
+}
+
+// enabled 报告是否启用了热点路径统计
+func (t *hotTracker) enabled() bool {
+	return t.entries != nil
+}
+
+// record 记录 path 在 now 发生了一个事件
+func (t *hotTracker) record(path string, now time.Time) {
+	b := now.UnixNano() / int64(t.bucket)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var e *hotEntry
+	if el, ok := t.entries[path]; ok {
+		t.lru.MoveToFront(el)
+		e = el.Value.(*hotEntry)
+		// 清空上次事件之后已经过去的桶
+		for i := e.last + 1; i <= b && i <= e.last+hotBuckets; i++ {
+			e.counts[i%hotBuckets] = 0
+		}
+	} else {
+		if len(t.entries) >= t.cap {
+			oldest := t.lru.Back()
+			delete(t.entries, oldest.Value.(*hotEntry).path)
+			t.lru.Remove(oldest)
+		}
+		e = &hotEntry{path: path}
+		t.entries[path] = t.lru.PushFront(e)
+	}
+	if b > e.last {
+		e.last = b
+	}
+	e.counts[b%hotBuckets]++
+	e.lastSeen = now
+}
+
+// top 返回 now 时窗口内事件数最多的 n 个路径(n<=0 表示全部)，按事件数从多到少、最近事件从新到旧、路径排序
+func (t *hotTracker) top(n int, now time.Time) []PathCount {
+	b := now.UnixNano() / int64(t.bucket)
+	t.mu.Lock()
+	var out []PathCount
+	for el := t.lru.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*hotEntry)
+		if e.last <= b-hotBuckets {
+			break // 之后的路径更久没有事件，已全部滑出窗口
+		}
+		var sum uint64
+		for i := max(b-hotBuckets+1, e.last-hotBuckets+1); i <= e.last; i++ {
+			sum += uint64(e.counts[i%hotBuckets])
+		}
+		if sum > 0 {
+			out = append(out, PathCount{Path: e.path, Count: sum, LastSeen: e.lastSeen})
+		}
+	}
+	t.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		if !out[i].LastSeen.Equal(out[j].LastSeen) {
+			return out[i].LastSeen.After(out[j].LastSeen)
+		}
+		return out[i].Path < out[j].Path
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// HotPaths 返回最近 HotPathWindow 内事件最多的 n 个路径(n<=0 表示全部)，用于调整忽略规则
+//
+// 统计的是通过忽略规则、进入合并前的原始事件(同一路径的多个事件在合并后可能只产生一次变更)；
+// 最多跟踪 HotPathCapacity 个路径，见 WithHotPaths。未启用时返回nil
+// 并发安全
+func (w *Watcher) HotPaths(n int) []PathCount {
+	if !w.hot.enabled() {
+		return nil
+	}
+	return w.hot.top(n, w.now())
+}
@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestHotPaths 测试滑动窗口计数、超出容量时淘汰最久没有事件的路径以及 DumpState 中的排行
+func TestHotPaths(t *testing.T) {
+	root := t.TempDir()
+	clk := &manualClock{}
+	clk.now.Store(time.Unix(1000, 0).UnixNano())
+	w, err := NewWatcherWithOptions([]string{root}, WithClock(clk), WithHotPaths(10*time.Second, 3))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	path := func(name string) string { return filepath.Join(root, name) }
+	emit := func(name string, n int) {
+		for i := 0; i < n; i++ {
+			w.handleFsEvent(fsnotify.Event{Name: path(name), Op: fsnotify.Write})
+		}
+	}
+
+	emit("old.log", 4)
+	clk.advance(5 * time.Second)
+	emit("a.log", 3)
+	emit("b.log", 1)
+	got := w.HotPaths(2)
+	if len(got) != 2 || got[0].Path != path("old.log") || got[0].Count != 4 || got[1].Path != path("a.log") || got[1].Count != 3 {
+		t.Fatalf("HotPaths(2) = %+v", got)
+	}
+
+	// old.log 的事件滑出窗口
+	clk.advance(6 * time.Second)
+	emit("a.log", 2)
+	got = w.HotPaths(0)
+	if len(got) != 2 || got[0].Path != path("a.log") || got[0].Count != 5 || !got[0].LastSeen.Equal(clk.Now()) {
+		t.Fatalf("HotPaths after window = %+v", got)
+	}
+
+	// 容量为3：c.log 淘汰最久没有事件的 old.log，之后 old.log 从0开始计数
+	emit("c.log", 1)
+	if n := len(w.hot.entries); n != 3 {
+		t.Errorf("tracked %d paths; want 3", n)
+	}
+	if _, ok := w.hot.entries[path("old.log")]; ok {
+		t.Error("least recently seen path not evicted")
+	}
+
+	var buf bytes.Buffer
+	if err := w.DumpState(&buf); err != nil {
+		t.Fatalf("DumpState failed: %v", err)
+	}
+	var d struct {
+		HotPaths []PathCount `json:"hot_paths"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &d); err != nil || len(d.HotPaths) != 3 || d.HotPaths[0].Path != path("a.log") {
+		t.Errorf("dump hot_paths = %+v, %v", d.HotPaths, err)
+	}
+	if newTestWatcher(t, root).HotPaths(1) != nil {
+		t.Error("HotPaths without HotPathWindow not empty")
+	}
+}
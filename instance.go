@@ -0,0 +1,116 @@
+package watcher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// snapIDPrefix 是生成的快照ID的前缀，完整格式为 "snap-<InstanceID>-<纳秒时间戳>"
+const snapIDPrefix = "snap-"
+
+// newInstanceID 生成随机的实例ID(8位hex)
+func newInstanceID() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validInstanceID 报告实例ID能否嵌入快照ID与 Store 的文件名：非空，只含字母、数字、'.'、'_'、'-'
+func validInstanceID(id string) bool {
+	if id == "" || id == "." || id == ".." {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// SnapshotInstance 返回快照ID中的实例ID(见 WithInstanceID)，
+// 旧版本生成的ID("snap-<纳秒时间戳>")与不是由 Watcher 生成的ID返回空串
+func SnapshotInstance(id string) string {
+	s, ok := strings.CutPrefix(id, snapIDPrefix)
+	if !ok {
+		return ""
+	}
+	i := strings.LastIndexByte(s, '-')
+	if i <= 0 || i == len(s)-1 {
+		return ""
+	}
+	for _, r := range s[i+1:] {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	return s[:i]
+}
+
+// InstanceID 返回本 Watcher 的实例ID(ConfigWatcher.InstanceID，未设置时为随机生成的值)
+func (w *Watcher) InstanceID() string {
+	return w.cfg.InstanceID
+}
+
+// InstanceStore 是可选接口：可以按实例划分的 SnapshotStore(如 DirStore)
+//
+// 配置了实现该接口的 Store 时，Watcher 使用 ForInstance(InstanceID) 返回的视图，
+// 多个实例共用同一个存储时各自的 List、HEAD(HeadStore)与校验(ValidateStore)互不干扰
+type InstanceStore interface {
+	SnapshotStore
+	ForInstance(instance string) SnapshotStore
+}
+
+// LoadStore 读取 store 中的全部快照，按创建时间排序(相同时按ID)
+//
+// instances 非空时只返回这些实例的快照；快照的 Instance 字段标明其所属实例(旧版本写入的快照为空串)，
+// 可用于合并展示多个实例的历史
+func LoadStore(store SnapshotStore, instances ...string) ([]*SnapshotNode, error) {
+	ids, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []*SnapshotNode
+	for _, id := range ids {
+		if len(instances) > 0 && !slices.Contains(instances, SnapshotInstance(id)) {
+			continue
+		}
+		sn, err := store.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("load store: %w", err)
+		}
+		out = append(out, sn)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+// StoreInstances 返回 store 中出现过的实例ID(有序，旧版本写入的快照计为空串)
+func StoreInstances(store SnapshotStore) ([]string, error) {
+	ids, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{})
+	var out []string
+	for _, id := range ids {
+		inst := SnapshotInstance(id)
+		if _, ok := seen[inst]; !ok {
+			seen[inst] = struct{}{}
+			out = append(out, inst)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestInstanceSharedStore 测试两个实例共用一个 DirStore：快照ID带实例ID，各自的 HEAD 与校验互不干扰，LoadStore 可按实例过滤
+func TestInstanceSharedStore(t *testing.T) {
+	store, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	newInstance := func(id string) (*Watcher, string) {
+		root := t.TempDir()
+		w, err := NewWatcherWithOptions([]string{root}, WithStore(store, 0), WithInstanceID(id))
+		if err != nil {
+			t.Fatalf("NewWatcherWithOptions failed: %v", err)
+		}
+		file := filepath.Join(root, "a.txt")
+		_ = os.WriteFile(file, []byte(id), 0644)
+		w.handleFileChange(file, fsnotify.Create)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		return w, w.GetCurrentSnapshot().ID
+	}
+	wa, headA := newInstance("web-1")
+	_, headB := newInstance("web-2")
+
+	if !strings.HasPrefix(headA, "snap-web-1-") || SnapshotInstance(headA) != "web-1" || wa.GetCurrentSnapshot().Instance != "web-1" {
+		t.Errorf("head %s (instance %q)", headA, wa.GetCurrentSnapshot().Instance)
+	}
+	if SnapshotInstance("snap-1700000000") != "" || SnapshotInstance("other") != "" {
+		t.Error("legacy id attributed to an instance")
+	}
+	for inst, want := range map[string]string{"web-1": headA, "web-2": headB} {
+		if got, err := store.ForInstance(inst).(HeadStore).Head(); err != nil || got != want {
+			t.Errorf("HEAD of %s = %q, %v; want %s", inst, got, err, want)
+		}
+	}
+	if issues := wa.ValidateStore(); len(issues) != 0 {
+		t.Errorf("ValidateStore of web-1 = %v", issues)
+	}
+
+	if insts, err := StoreInstances(store); err != nil || strings.Join(insts, ",") != "web-1,web-2" {
+		t.Errorf("StoreInstances = %v, %v", insts, err)
+	}
+	all, err := LoadStore(store)
+	if err != nil || len(all) != 4 {
+		t.Fatalf("LoadStore = %d snapshots, %v; want 4", len(all), err)
+	}
+	only, err := LoadStore(store, "web-2")
+	if err != nil || len(only) != 2 || only[1].ID != headB || only[1].Instance != "web-2" {
+		t.Errorf("LoadStore(web-2) = %v, %v", only, err)
+	}
+
+	if _, err := NewWatcher(ConfigWatcher{WatchPaths: []string{t.TempDir()}, InstanceID: "a b"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("invalid InstanceID: %v; want ErrInvalidConfig", err)
+	}
+	w := newTestWatcher(t, t.TempDir())
+	if id := w.InstanceID(); !validInstanceID(id) || !strings.Contains(w.GetCurrentSnapshot().ID, id) {
+		t.Errorf("default instance %q, head %s", id, w.GetCurrentSnapshot().ID)
+	}
+}
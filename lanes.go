@@ -0,0 +1,98 @@
+package watcher
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// laneState 是优先级车道(ConfigWatcher.Priority)的状态
+//
+// 优先级大于0的路径走快车道，与未配置 Priority 时相同：在 flush 中同步领取 workerPool 令牌；
+// 其余路径走慢车道，由后台goroutine在 bulkPool 与 workerPool 都有令牌时处理，
+// 慢车道最多占用 bulkPool 容量(WorkerCount 的一半)个worker，其余worker始终留给快车道。
+// 已分派到慢车道但尚未处理完的路径与快车道一样登记在 Watcher.inflight 中，在后续批次中延后到下一次 flush
+type laneState struct {
+	bulkPool chan struct{}
+
+	fastQueued atomic.Int64 // 已分派、尚未开始处理的快车道路径数
+	bulkQueued atomic.Int64 // 已分派、尚未开始处理的慢车道路径数
+}
+
+// SmallFilesFirst 返回一个 Priority 函数：不超过 maxSize 字节的路径(含已删除的路径)走快车道，更大的文件走慢车道
+func SmallFilesFirst(maxSize int64) func(path string, size int64) int {
+	return func(_ string, size int64) int {
+		if size <= maxSize {
+			return 1
+		}
+		return 0
+	}
+}
+
+// prioritized 是带优先级的待处理路径
+type prioritized struct {
+	aggItem
+	prio int
+}
+
+// splitLanes 按 cfg.Priority 把批次拆分为快、慢两条车道，车道内按优先级从高到低排列
+func (w *Watcher) splitLanes(items []aggItem) (fast, bulk []aggItem) {
+	var fp, bp []prioritized
+	for _, it := range items {
+		var size int64
+		if fi, err := w.fs.Stat(it.path); err == nil {
+			size = fi.Size()
+		}
+		p := prioritized{it, w.cfg.Priority(it.path, size)}
+		if p.prio > 0 {
+			fp = append(fp, p)
+			continue
+		}
+		bp = append(bp, p)
+	}
+	return byPriority(fp), byPriority(bp)
+}
+
+// byPriority 按优先级从高到低(相同时按路径)排序并去掉优先级
+func byPriority(ps []prioritized) []aggItem {
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].prio != ps[j].prio {
+			return ps[i].prio > ps[j].prio
+		}
+		return ps[i].path < ps[j].path
+	})
+	out := make([]aggItem, len(ps))
+	for i, p := range ps {
+		out[i] = p.aggItem
+	}
+	return out
+}
+
+// dispatchBulk 在后台处理慢车道的路径，不阻塞 flush；done 在每个路径处理完后调用
+func (w *Watcher) dispatchBulk(items []aggItem, replay bool, span BatchSpan, done func()) {
+	w.lanes.bulkQueued.Add(int64(len(items)))
+	var next atomic.Int64
+	n := min(len(items), cap(w.lanes.bulkPool))
+	for i := 0; i < n; i++ {
+		w.workerWG.Add(1)
+		go func() {
+			defer w.workerWG.Done()
+			// 先取慢车道令牌再取worker令牌：快车道从不持有 bulkPool，不会互相等待
+			w.lanes.bulkPool <- struct{}{}
+			w.workerPool <- struct{}{}
+			defer func() {
+				<-w.workerPool
+				<-w.lanes.bulkPool
+			}()
+			for {
+				j := int(next.Add(1)) - 1
+				if j >= len(items) {
+					return
+				}
+				w.lanes.bulkQueued.Add(-1)
+				w.processItem(items[j], replay, span)
+				w.inflight.release(items[j].path)
+				done()
+			}
+		}()
+	}
+}
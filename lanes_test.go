@@ -0,0 +1,62 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestPriorityLanes 测试按大小拆分车道、车道内排序、慢车道中路径的延后以及完整处理
+func TestPriorityLanes(t *testing.T) {
+	root := t.TempDir()
+	small := filepath.Join(root, "b.conf")
+	small2 := filepath.Join(root, "a.conf")
+	big := filepath.Join(root, "blob.bin")
+	_ = os.WriteFile(small, []byte("x"), 0644)
+	_ = os.WriteFile(small2, []byte("y"), 0644)
+	_ = os.WriteFile(big, make([]byte, 4096), 0644)
+
+	w, err := NewWatcherWithOptions([]string{root}, WithWorkerCount(2), WithPriority(SmallFilesFirst(1024)))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+
+	fast, bulk := w.splitLanes([]aggItem{{big, aggEntry{op: fsnotify.Write}}, {small, aggEntry{op: fsnotify.Write}}, {small2, aggEntry{op: fsnotify.Create}}})
+	if len(fast) != 2 || fast[0].path != small2 || fast[1].path != small {
+		t.Errorf("fast lane = %v; want [%s %s]", fast, small2, small)
+	}
+	if len(bulk) != 1 || bulk[0].path != big {
+		t.Errorf("bulk lane = %v; want [%s]", bulk, big)
+	}
+
+	// big 仍在慢车道中：新的变更放回合并表
+	w.inflight.claim(bulk)
+	w.aggMap[big] = aggEntry{op: fsnotify.Write}
+	w.flushAgg(true)
+	w.workerWG.Wait()
+	if e, ok := w.aggMap[big]; !ok || e.op != fsnotify.Write {
+		t.Errorf("aggMap[%s] = %v, %v; want deferred Write", big, e.op, ok)
+	}
+	w.inflight.release(big)
+
+	w.flushAgg(true)
+	w.workerWG.Wait()
+	w.aggMap[small] = aggEntry{op: fsnotify.Write}
+	w.aggMap[big] = aggEntry{op: fsnotify.Write}
+	w.flushAgg(true)
+	w.workerWG.Wait()
+
+	files := w.GetCurrentSnapshot().Files
+	for _, p := range []string{small, big} {
+		if files[p] == nil {
+			t.Errorf("%s missing from snapshot", p)
+		}
+	}
+	st := w.Stats()
+	if st.FastLaneQueued != 0 || st.BulkLaneQueued != 0 || w.inflight.len() != 0 {
+		t.Errorf("lanes not drained: fast=%d bulk=%d busy=%v", st.FastLaneQueued, st.BulkLaneQueued, w.inflight.paths)
+	}
+}
@@ -0,0 +1,45 @@
+package watcher
+
+import "strings"
+
+// Windows 长路径与 UNC 路径
+//
+// Windows 上超过 MAX_PATH(260) 的路径需要 `\\?\` 前缀才能被 Win32 API 接受，
+// UNC 共享 `\\server\share\...` 对应的长路径形式为 `\\?\UNC\server\share\...`。
+// 快照与事件中始终保存不带前缀的原始路径，只有在真正调用操作系统(stat/open/注册监控)时
+// 才通过 osPath 转换；fsnotify 回报的带前缀路径会经 fromLongPath 还原。
+// 这里的字符串处理与平台无关，便于在任意平台上测试。
+
+const (
+	longPathPrefix    = `\\?\`
+	longUNCPathPrefix = `\\?\UNC\`
+
+	// longPathThreshold 以下的路径无需前缀(目录路径需为 8.3 文件名预留 12 个字符)
+	longPathThreshold = 248
+)
+
+// toLongPath 把 Windows 绝对路径转换为 `\\?\` 长路径形式
+//
+// 已带前缀或不是绝对路径(盘符或 UNC)的路径原样返回
+func toLongPath(path string) string {
+	switch {
+	case strings.HasPrefix(path, longPathPrefix):
+		return path
+	case strings.HasPrefix(path, `\\`):
+		return longUNCPathPrefix + path[2:]
+	case len(path) >= 3 && path[1] == ':' && (path[2] == '\\' || path[2] == '/'):
+		return longPathPrefix + strings.ReplaceAll(path, "/", `\`)
+	}
+	return path
+}
+
+// fromLongPath 去掉 `\\?\` 或 `\\?\UNC\` 前缀，还原为普通路径
+func fromLongPath(path string) string {
+	switch {
+	case strings.HasPrefix(path, longUNCPathPrefix):
+		return `\\` + path[len(longUNCPathPrefix):]
+	case strings.HasPrefix(path, longPathPrefix):
+		return path[len(longPathPrefix):]
+	}
+	return path
+}
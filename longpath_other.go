@@ -0,0 +1,8 @@
+//go:build !windows
+
+package watcher
+
+// osPath 在非 Windows 平台上原样返回路径
+func osPath(path string) string {
+	return path
+}
@@ -0,0 +1,37 @@
+package watcher
+
+import "testing"
+
+// TestLongPathConversion 测试长路径前缀的添加与还原
+func TestLongPathConversion(t *testing.T) {
+	cases := []struct {
+		path string
+		long string
+	}{
+		{`C:\very\deep\path`, `\\?\C:\very\deep\path`},
+		{`C:/mixed/separators`, `\\?\C:\mixed\separators`},
+		{`\\server\share\dir\file`, `\\?\UNC\server\share\dir\file`},
+		{`\\?\C:\already`, `\\?\C:\already`},
+		{`relative\path`, `relative\path`},
+		{`/unix/path`, `/unix/path`},
+	}
+	for _, c := range cases {
+		if got := toLongPath(c.path); got != c.long {
+			t.Errorf("toLongPath(%q) = %q; want %q", c.path, got, c.long)
+		}
+	}
+
+	back := []struct {
+		long string
+		path string
+	}{
+		{`\\?\C:\very\deep\path`, `C:\very\deep\path`},
+		{`\\?\UNC\server\share\dir`, `\\server\share\dir`},
+		{`C:\plain`, `C:\plain`},
+	}
+	for _, c := range back {
+		if got := fromLongPath(c.long); got != c.path {
+			t.Errorf("fromLongPath(%q) = %q; want %q", c.long, got, c.path)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package watcher
+
+import "path/filepath"
+
+// osPath 返回调用操作系统时使用的路径：统一为 '\' 分隔(快照中的路径默认以 '/' 分隔)，
+// 超过长度阈值的路径加上 `\\?\` 前缀(该前缀下系统不再转换 '/')
+func osPath(path string) string {
+	path = filepath.FromSlash(path)
+	if len(path) < longPathThreshold {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return toLongPath(path)
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package watcher
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestOSPathWindows 测试超过阈值的路径在调用系统API前加上前缀
+func TestOSPathWindows(t *testing.T) {
+	short := `C:\short`
+	if got := osPath(short); got != short {
+		t.Errorf("short path should be unchanged, got %q", got)
+	}
+	long := `C:\` + strings.Repeat(`a\`, 150) + "file.txt"
+	if got := osPath(long); !strings.HasPrefix(got, longPathPrefix) {
+		t.Errorf("long path should get the %q prefix, got %q", longPathPrefix, got)
+	}
+	unc := `\\server\share\` + strings.Repeat(`b\`, 150) + "file.txt"
+	if got := osPath(unc); !strings.HasPrefix(got, longUNCPathPrefix) {
+		t.Errorf("long UNC path should get the %q prefix, got %q", longUNCPathPrefix, got)
+	}
+}
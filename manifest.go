@@ -0,0 +1,385 @@
+package watcher
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 可移植的清单
+//
+// 清单只列出普通文件(不含目录与墓碑)，按路径排序。路径相对于所属的监控根、以 '/' 分隔，
+// 有多个监控根时以监控根的最后一级目录名开头(如 "app/config.yaml")，监控根之外的条目不列出。
+// 读取时按第一行自动识别格式：表头为 "path,size,mtime,hash" 或其 TSV 形式时为 CSV/TSV，否则为 sha256sum 格式
+
+// ManifestFormat 是 ExportManifest 输出的清单格式
+type ManifestFormat int
+
+const (
+	// ManifestSHA256Sum 与 sha256sum 输出兼容的文本格式("<hash>  <path>")，可直接用 sha256sum -c 校验；
+	// 只列出有内容哈希的文件，要求 Hasher 为默认的 SHA-256
+	ManifestSHA256Sum ManifestFormat = iota
+	// ManifestCSV 带表头的 CSV：path,size,mtime(RFC3339Nano，UTC),hash(未知时为空)
+	ManifestCSV
+	// ManifestTSV 同 ManifestCSV，以制表符分隔
+	ManifestTSV
+)
+
+// String 返回格式的可读名称
+func (f ManifestFormat) String() string {
+	switch f {
+	case ManifestSHA256Sum:
+		return "sha256sum"
+	case ManifestCSV:
+		return "csv"
+	case ManifestTSV:
+		return "tsv"
+	}
+	return fmt.Sprintf("ManifestFormat(%d)", int(f))
+}
+
+// manifestHeader 是 CSV/TSV 清单的表头
+var manifestHeader = []string{"path", "size", "mtime", "hash"}
+
+// VerifyReport 是 VerifyManifest 的结果，可直接序列化为 JSON；路径均为清单中的相对路径
+type VerifyReport struct {
+	SnapshotID string `json:"snapshot_id,omitempty"` // 比较的快照，VerifyOnDisk 时为空
+	Entries    int    `json:"entries"`               // 清单中的文件数
+	Matched    int    `json:"matched"`               // 大小与哈希(两边都已知时)一致的文件数
+	Unhashed   int    `json:"unhashed"`              // Matched 中因一边没有哈希而只比较了大小的文件数
+
+	Missing    []string           `json:"missing,omitempty"`    // 清单中有、快照或磁盘上不存在的文件
+	Mismatched []ManifestMismatch `json:"mismatched,omitempty"` // 大小或哈希不一致的文件
+	Extra      []string           `json:"extra,omitempty"`      // 快照中有、清单中没有的文件，VerifyOnDisk 时不统计
+}
+
+// OK 报告清单与快照(或磁盘)是否完全一致
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Mismatched) == 0 && len(r.Extra) == 0
+}
+
+// ManifestMismatch 是单个文件的不一致项
+type ManifestMismatch struct {
+	Path  string `json:"path"`
+	Field string `json:"field"` // "size" 或 "hash"
+	Want  string `json:"want"`  // 清单中的值
+	Got   string `json:"got"`   // 快照或磁盘上的值
+}
+
+// VerifyOnDisk 让 VerifyManifest 读取磁盘上的文件(stat 并重新计算哈希)，而不是与当前快照比较
+func VerifyOnDisk() QueryOption {
+	return func(o *queryOptions) {
+		o.disk = true
+	}
+}
+
+// ExportManifest 把快照 id 中的文件按 format 写入 wr，格式与路径形式见 ManifestFormat
+//
+// 快照不存在时返回 ErrSnapshotNotFound，已换出的快照从 Store 读回；
+// ManifestSHA256Sum 在 Hasher 不是 SHA-256 时返回 ErrInvalidConfig
+// 并发安全
+func (w *Watcher) ExportManifest(id string, wr io.Writer, format ManifestFormat) error {
+	if format == ManifestSHA256Sum && !w.sha256Hasher() {
+		return fmt.Errorf("export manifest: %w: sha256sum format requires the SHA-256 hasher", ErrInvalidConfig)
+	}
+	sn, err := w.loadSnapshot(id)
+	if err != nil {
+		return err
+	}
+	entries := w.manifestEntries(sn)
+
+	bw := bufio.NewWriter(wr)
+	switch format {
+	case ManifestSHA256Sum:
+		for _, e := range entries {
+			if e.Hash != "" {
+				writeSumLine(bw, e.Hash, e.Path)
+			}
+		}
+	case ManifestCSV, ManifestTSV:
+		cw := csv.NewWriter(bw)
+		if format == ManifestTSV {
+			cw.Comma = '\t'
+		}
+		_ = cw.Write(manifestHeader)
+		for _, e := range entries {
+			mtime := ""
+			if !e.ModTime.IsZero() {
+				mtime = e.ModTime.UTC().Format(time.RFC3339Nano)
+			}
+			_ = cw.Write([]string{e.Path, strconv.FormatInt(e.Size, 10), mtime, e.Hash})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("export manifest: %w", err)
+		}
+	default:
+		return fmt.Errorf("export manifest: unknown format %v", format)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("export manifest: %w", err)
+	}
+	return nil
+}
+
+// VerifyManifest 读取清单(格式自动识别，见 ManifestFormat)并与当前快照比较，传入 VerifyOnDisk 时改为与磁盘上的文件比较
+//
+// 大小在清单提供时比较(sha256sum 格式没有大小)，哈希在两边都已知时比较，修改时间不比较；
+// 清单格式错误、路径不是规范的相对路径或无法对应到监控根时返回包装 ErrInvalidManifest 的错误(含行号)。
+// sha256sum 格式的清单在 Hasher 不是 SHA-256 时返回 ErrInvalidConfig
+// 并发安全
+func (w *Watcher) VerifyManifest(r io.Reader, opts ...QueryOption) (*VerifyReport, error) {
+	entries, format, err := parseManifest(r)
+	if err != nil {
+		return nil, err
+	}
+	if format == ManifestSHA256Sum && !w.sha256Hasher() {
+		return nil, fmt.Errorf("verify manifest: %w: sha256sum format requires the SHA-256 hasher", ErrInvalidConfig)
+	}
+	abs := make([]string, len(entries))
+	for i, e := range entries {
+		if abs[i] = w.manifestTarget(e.Path); abs[i] == "" {
+			return nil, fmt.Errorf("verify manifest: %w: path %q is not under any watch root", ErrInvalidManifest, e.Path)
+		}
+	}
+
+	report := &VerifyReport{Entries: len(entries)}
+	disk := parseQuery(opts).disk
+	var sn *SnapshotNode
+	if !disk {
+		if sn = w.GetCurrentSnapshot(); sn == nil {
+			return nil, fmt.Errorf("verify manifest: no current snapshot")
+		}
+		report.SnapshotID = sn.ID
+	}
+	listed := make(map[string]struct{}, len(entries))
+	for i, e := range entries {
+		listed[e.Path] = struct{}{}
+		var cur *FileMetadata
+		if disk {
+			cur = w.diskManifestEntry(abs[i], e.Hash != "")
+		} else {
+			cur = sn.Lookup(abs[i])
+		}
+		switch {
+		case cur == nil || cur.IsDirectory:
+			report.Missing = append(report.Missing, e.Path)
+		case e.Size >= 0 && e.Size != cur.Size:
+			report.Mismatched = append(report.Mismatched, ManifestMismatch{Path: e.Path, Field: "size",
+				Want: strconv.FormatInt(e.Size, 10), Got: strconv.FormatInt(cur.Size, 10)})
+		case e.Hash == "" || cur.Hash == "" || !cur.HashState.hasContentHash():
+			report.Matched++
+			report.Unhashed++
+		case !strings.EqualFold(e.Hash, cur.Hash):
+			report.Mismatched = append(report.Mismatched, ManifestMismatch{Path: e.Path, Field: "hash", Want: e.Hash, Got: cur.Hash})
+		default:
+			report.Matched++
+		}
+	}
+	if !disk {
+		for _, e := range w.manifestEntries(sn) {
+			if _, ok := listed[e.Path]; !ok {
+				report.Extra = append(report.Extra, e.Path)
+			}
+		}
+	}
+	return report, nil
+}
+
+// sha256Hasher 报告文件内容哈希是否为 SHA-256
+func (w *Watcher) sha256Hasher() bool {
+	empty := sha256.Sum256(nil)
+	return w.emptyHash == hex.EncodeToString(empty[:])
+}
+
+// manifestEntries 返回快照中可写入清单的文件(路径为清单中的相对路径)，按路径排序
+func (w *Watcher) manifestEntries(sn *SnapshotNode) []ManifestEntry {
+	var out []ManifestEntry
+	for p, m := range sn.Files {
+		if m.IsDirectory || m.Deleted {
+			continue
+		}
+		rel := w.manifestPath(p)
+		if rel == "" {
+			continue
+		}
+		e := ManifestEntry{Path: rel, Size: m.Size, ModTime: m.ModTime}
+		if m.HashState.hasContentHash() {
+			e.Hash = m.Hash
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// manifestPath 返回 p 在清单中的相对路径，不属于任何监控根或就是监控根时返回空串
+func (w *Watcher) manifestPath(p string) string {
+	root := w.rootOf(p)
+	if root == "" || p == root {
+		return ""
+	}
+	rel := strings.TrimLeft(SlashPath(p)[len(root):], "/")
+	if len(w.roots) > 1 {
+		rel = path.Base(SlashPath(root)) + "/" + rel
+	}
+	return rel
+}
+
+// manifestTarget 是 manifestPath 的逆变换，返回相对路径 rel 对应的快照路径，无法对应时返回空串
+func (w *Watcher) manifestTarget(rel string) string {
+	if len(w.roots) == 1 {
+		return w.keyOf(filepath.Join(w.roots[0], filepath.FromSlash(rel)))
+	}
+	first, rest, ok := strings.Cut(rel, "/")
+	if !ok {
+		return ""
+	}
+	for _, r := range w.roots {
+		if path.Base(SlashPath(r)) == first {
+			return w.keyOf(filepath.Join(r, filepath.FromSlash(rest)))
+		}
+	}
+	return ""
+}
+
+// diskManifestEntry 读取磁盘上 p 的大小，withHash 时计算内容哈希；不存在或不是普通文件时返回nil
+func (w *Watcher) diskManifestEntry(p string, withHash bool) *FileMetadata {
+	info, err := w.fs.Stat(p)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	m := &FileMetadata{Path: p, Size: info.Size(), ModTime: info.ModTime()}
+	if !withHash {
+		return m
+	}
+	buf := w.bufs.get()
+	defer w.bufs.put(buf)
+	if h, err := hashFile(w.fs, p, w.newHash, *buf); err == nil {
+		m.Hash, m.HashState = h, HashStateHashed
+	} else {
+		m.HashState = HashStateUnreadable
+	}
+	return m
+}
+
+// writeSumLine 按 sha256sum 的规则写入一行：路径含 '\' 或换行时行首加 '\' 并转义
+func writeSumLine(bw *bufio.Writer, hash, p string) {
+	if strings.ContainsAny(p, "\\\n") {
+		p = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(p)
+		bw.WriteByte('\\')
+	}
+	fmt.Fprintf(bw, "%s  %s\n", hash, p)
+}
+
+// parseManifest 读取清单，返回条目(Path 为相对路径，Size 未知时为 -1)与识别出的格式
+func parseManifest(r io.Reader) ([]ManifestEntry, ManifestFormat, error) {
+	br := bufio.NewReader(r)
+	first, err := br.Peek(len("path,size,mtime,hash"))
+	if err != nil && err != io.EOF {
+		return nil, 0, fmt.Errorf("read manifest: %w", err)
+	}
+	switch string(first) {
+	case strings.Join(manifestHeader, ","):
+		entries, err := parseManifestCSV(br, ',')
+		return entries, ManifestCSV, err
+	case strings.Join(manifestHeader, "\t"):
+		entries, err := parseManifestCSV(br, '\t')
+		return entries, ManifestTSV, err
+	}
+	entries, err := parseManifestSum(br)
+	return entries, ManifestSHA256Sum, err
+}
+
+// parseManifestCSV 读取 CSV/TSV 清单
+func parseManifestCSV(r io.Reader, comma rune) ([]ManifestEntry, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.FieldsPerRecord = len(manifestHeader)
+	var entries []ManifestEntry
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+		}
+		if line == 1 {
+			if !slices.Equal(rec, manifestHeader) {
+				return nil, fmt.Errorf("%w: line 1: header %q; want %q", ErrInvalidManifest, rec, manifestHeader)
+			}
+			continue
+		}
+		e := ManifestEntry{Path: rec[0], Hash: rec[3]}
+		if e.Size, err = strconv.ParseInt(rec[1], 10, 64); err != nil || e.Size < 0 {
+			return nil, fmt.Errorf("%w: line %d: invalid size %q", ErrInvalidManifest, line, rec[1])
+		}
+		if rec[2] != "" {
+			if e.ModTime, err = time.Parse(time.RFC3339Nano, rec[2]); err != nil {
+				return nil, fmt.Errorf("%w: line %d: invalid mtime %q", ErrInvalidManifest, line, rec[2])
+			}
+		}
+		if err := checkManifestEntry(e); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidManifest, line, err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+// parseManifestSum 读取 sha256sum 格式的清单，空行被忽略
+func parseManifestSum(r io.Reader) ([]ManifestEntry, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	var entries []ManifestEntry
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSuffix(sc.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(text, `\`)
+		if escaped {
+			text = text[1:]
+		}
+		// "<hash>  <path>"(文本模式)或 "<hash> *<path>"(二进制模式)
+		hash, p, ok := strings.Cut(text, " ")
+		if !ok || len(hash) != sha256.Size*2 || (!strings.HasPrefix(p, " ") && !strings.HasPrefix(p, "*")) {
+			return nil, fmt.Errorf("%w: line %d: not a sha256sum line", ErrInvalidManifest, line)
+		}
+		p = p[1:]
+		if escaped {
+			p = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(p)
+		}
+		e := ManifestEntry{Path: p, Size: -1, Hash: strings.ToLower(hash)}
+		if err := checkManifestEntry(e); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidManifest, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	return entries, nil
+}
+
+// checkManifestEntry 检查路径是规范的、以 '/' 分隔的相对路径，哈希为空或为十六进制
+func checkManifestEntry(e ManifestEntry) error {
+	if !fs.ValidPath(e.Path) || e.Path == "." {
+		return fmt.Errorf("path %q is not a clean relative slash path", e.Path)
+	}
+	if _, err := hex.DecodeString(e.Hash); err != nil {
+		return fmt.Errorf("invalid hash %q for %q", e.Hash, e.Path)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// manifestWatcher 创建监控 root 的 Watcher，并提交 root 下的 files(相对路径 → 内容)
+func manifestWatcher(t *testing.T, root string, files map[string]string) *Watcher {
+	t.Helper()
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		_ = os.WriteFile(p, []byte(content), 0644)
+		w.handleFileChange(w.keyOf(p), fsnotify.Create)
+	}
+	return w
+}
+
+// TestManifestRoundTrip 测试三种格式导出后再校验均一致；路径为相对路径、按路径排序；磁盘变化后 VerifyOnDisk 报告不一致
+func TestManifestRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	w := manifestWatcher(t, root, map[string]string{"b.txt": "bee", "sub/a.txt": "ay", `odd\name.txt`: "odd"})
+	cur := w.GetCurrentSnapshot()
+
+	for _, format := range []ManifestFormat{ManifestSHA256Sum, ManifestCSV, ManifestTSV} {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := w.ExportManifest(cur.ID, &buf, format); err != nil {
+				t.Fatalf("ExportManifest failed: %v", err)
+			}
+			out := buf.String()
+			if strings.Contains(out, filepath.ToSlash(root)) || strings.Index(out, "b.txt") > strings.Index(out, "sub/a.txt") {
+				t.Fatalf("manifest = %q; want sorted relative paths", out)
+			}
+			r, err := w.VerifyManifest(strings.NewReader(out))
+			if err != nil {
+				t.Fatalf("VerifyManifest failed: %v", err)
+			}
+			if !r.OK() || r.Entries != 3 || r.Matched != 3 || r.Unhashed != 0 || r.SnapshotID != cur.ID {
+				t.Fatalf("report = %+v; want 3 matched entries", r)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	_ = w.ExportManifest(cur.ID, &buf, ManifestCSV)
+	_ = os.WriteFile(filepath.Join(root, "b.txt"), []byte("wasp"), 0644)
+	_ = os.Remove(filepath.Join(root, "sub", "a.txt"))
+	r, err := w.VerifyManifest(bytes.NewReader(buf.Bytes()), VerifyOnDisk())
+	if err != nil {
+		t.Fatalf("VerifyManifest(VerifyOnDisk) failed: %v", err)
+	}
+	if r.SnapshotID != "" || len(r.Missing) != 1 || r.Missing[0] != "sub/a.txt" ||
+		len(r.Mismatched) != 1 || r.Mismatched[0].Path != "b.txt" || r.Mismatched[0].Field != "size" {
+		t.Fatalf("report = %+v; want sub/a.txt missing and b.txt size mismatch", r)
+	}
+
+	// 清单中少列的文件计入 Extra
+	r, err = w.VerifyManifest(strings.NewReader("path,size,mtime,hash\nb.txt,3,,\n"))
+	if err != nil || len(r.Extra) != 2 || r.Unhashed != 1 {
+		t.Fatalf("report = %+v, %v; want 2 extra files", r, err)
+	}
+}
+
+// TestManifestMultiRoot 测试多个监控根时清单路径以监控根的目录名开头
+func TestManifestMultiRoot(t *testing.T) {
+	base := t.TempDir()
+	app, data := filepath.Join(base, "app"), filepath.Join(base, "data")
+	_ = os.Mkdir(app, 0755)
+	_ = os.Mkdir(data, 0755)
+	w, err := NewWatcherWithOptions([]string{app, data}, WithFS(osFS{}, replaySource{}), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	for _, p := range []string{filepath.Join(app, "x.txt"), filepath.Join(data, "x.txt")} {
+		_ = os.WriteFile(p, []byte(p), 0644)
+		w.handleFileChange(w.keyOf(p), fsnotify.Create)
+	}
+
+	var buf bytes.Buffer
+	if err := w.ExportManifest(w.GetCurrentSnapshot().ID, &buf, ManifestSHA256Sum); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "  app/x.txt") || !strings.HasSuffix(lines[1], "  data/x.txt") {
+		t.Fatalf("manifest = %q; want app/x.txt and data/x.txt", lines)
+	}
+	if r, err := w.VerifyManifest(&buf); err != nil || !r.OK() {
+		t.Fatalf("report = %+v, %v", r, err)
+	}
+}
+
+// TestManifestMalformed 测试格式错误、路径不合法的清单返回 ErrInvalidManifest
+func TestManifestMalformed(t *testing.T) {
+	w := manifestWatcher(t, t.TempDir(), nil)
+	hash := strings.Repeat("ab", 32)
+	for name, in := range map[string]string{
+		"short hash":    "abc  a.txt\n",
+		"one space":     hash + " a.txt\n",
+		"absolute path": hash + "  /etc/passwd\n",
+		"dot dot":       hash + "  ../a.txt\n",
+		"unclean":       hash + "  sub//a.txt\n",
+		"csv fields":    "path,size,mtime,hash\na.txt,1\n",
+		"csv size":      "path,size,mtime,hash\na.txt,-1,,\n",
+		"csv mtime":     "path,size,mtime,hash\na.txt,1,yesterday,\n",
+		"csv hash":      "path,size,mtime,hash\na.txt,1,,xyz\n",
+		"tsv backslash": "path\tsize\tmtime\thash\n" + `sub\..\a.txt` + "\t1\t\t\n",
+	} {
+		if name == "tsv backslash" && os.PathSeparator != '/' {
+			continue
+		}
+		_, err := w.VerifyManifest(strings.NewReader(in))
+		if name == "tsv backslash" {
+			// 非 Windows 平台上 '\' 是普通字符，路径本身合法
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidManifest) {
+			t.Errorf("%s: error = %v; want ErrInvalidManifest", name, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package watcher
+
+import "maps"
+
+// liveDescription 是 DisableSnapshots 时当前状态节点的描述
+const liveDescription = "Live state"
+
+// commitLiveLocked 是 DisableSnapshots 时 commitSnapshot 的实现，调用方需持有 w.mu 写锁
+//
+// changes 直接应用到 w.head 的文件表上，不创建新节点、不登记到快照列表，
+// 因此已删除的条目无法再通过快照查询；DisableCurrentState 时不维护任何状态，
+// focus 的旧元信息为nil，新元信息取自 changes
+func (w *Watcher) commitLiveLocked(changes map[string]*FileMetadata, focus string) commitResult {
+	if w.cfg.DisableCurrentState {
+		return commitResult{cur: changes[focus]}
+	}
+
+	live := w.head.Load()
+	old := live.Files[focus]
+	w.applyChangesLocked(live.Files, changes)
+	live.RootHash = w.rootHashLocked(live.Files)
+	live.CreatedAt = w.now()
+	live.Config = w.snapshotConfig()
+	return commitResult{old: old, cur: live.Files[focus]}
+}
+
+// liveSnapshotLocked 返回当前状态的副本，DisableCurrentState 时返回nil，调用方需持有 w.mu 读锁
+//
+// 条目在提交后不会被原地修改(见 rehashDirsLocked)，浅复制文件表即可与后续提交隔离
+func (w *Watcher) liveSnapshotLocked() *SnapshotNode {
+	if w.cfg.DisableCurrentState {
+		return nil
+	}
+	live := w.head.Load()
+	return &SnapshotNode{
+		ID:          live.ID,
+		Instance:    live.Instance,
+		CreatedAt:   live.CreatedAt,
+		Description: live.Description,
+		Files:       maps.Clone(live.Files),
+		RootHash:    live.RootHash,
+		Config:      live.Config,
+	}
+}
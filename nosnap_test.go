@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDisableSnapshots 测试只发送事件的模式：不创建快照，事件仍带新旧元信息，当前状态以副本返回
+func TestDisableSnapshots(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("one"), 0644)
+
+	w, err := NewWatcherWithOptions([]string{root}, WithDisableSnapshots())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+
+	if _, _, err := w.RehashFile(file); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	ev := <-w.EventChan
+	if ev.NewSnap != nil || ev.OldMeta != nil || ev.NewMeta == nil || ev.SnapshotID() != "" {
+		t.Fatalf("unexpected create event %+v", ev)
+	}
+	before := w.GetCurrentSnapshot()
+	if before == nil || before.Files[file] == nil || before.RootHash == "" {
+		t.Fatalf("live state should contain %s: %v", file, before)
+	}
+
+	_ = os.WriteFile(file, []byte("two"), 0644)
+	if _, _, err := w.RehashFile(file); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	ev = <-w.EventChan
+	if ev.NewSnap != nil || ev.OldMeta == nil || ev.NewMeta == nil || ev.OldMeta.Hash == ev.NewMeta.Hash {
+		t.Fatalf("unexpected write event %+v", ev)
+	}
+
+	after := w.GetCurrentSnapshot()
+	if before.Files[file].Hash != ev.OldMeta.Hash || after.Files[file].Hash != ev.NewMeta.Hash {
+		t.Error("earlier copy of the live state should not change")
+	}
+	if before.Files[root].Hash == after.Files[root].Hash || before.RootHash == after.RootHash {
+		t.Error("directory hashes should follow the change")
+	}
+
+	if n := len(w.ListAllSnapshots()); n != 0 {
+		t.Errorf("expected no snapshots, got %d", n)
+	}
+	if w.GetSnapshotByID(after.ID) != nil || w.FileHistory(file) != nil {
+		t.Error("live state should not be reachable as a snapshot")
+	}
+	if _, err := w.DiffSnapshots(after.ID, after.ID); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+	if n := w.Stats().SnapshotsCreated; n != 0 {
+		t.Errorf("SnapshotsCreated = %d", n)
+	}
+}
+
+// TestDisableCurrentState 测试不维护当前状态的模式
+func TestDisableCurrentState(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("one"), 0644)
+
+	w, err := NewWatcherWithOptions([]string{root}, WithDisableCurrentState())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+	if !w.cfg.DisableSnapshots {
+		t.Error("DisableCurrentState should imply DisableSnapshots")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, changed, err := w.RehashFile(file); err != nil || !changed {
+			t.Fatalf("RehashFile: changed=%v err=%v", changed, err)
+		}
+		ev := <-w.EventChan
+		if ev.NewSnap != nil || ev.OldMeta != nil || ev.NewMeta == nil || ev.NewMeta.Hash == "" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	}
+	if sn := w.GetCurrentSnapshot(); sn != nil {
+		t.Errorf("expected nil current snapshot, got %v", sn)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package watcher
+
+import "os"
+
+// openShared 打开文件用于读取；非 Windows 平台的打开不受其它进程的共享模式限制
+func openShared(name string) (*os.File, error) {
+	return os.Open(name)
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// openShared 以允许其它进程同时读、写、删除的共享模式打开文件用于读取
+//
+// os.Open 不带 FILE_SHARE_DELETE，且会与以独占方式打开文件的进程冲突；
+// 仍然失败时，共享冲突与锁冲突包装为 ErrFileLocked，由调用方稍后重试
+func openShared(name string) (*os.File, error) {
+	p, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	h, err := windows.CreateFile(p, windows.GENERIC_READ,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL|windows.FILE_FLAG_SEQUENTIAL_SCAN, 0)
+	if err != nil {
+		perr := &os.PathError{Op: "open", Path: name, Err: err}
+		if errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+			return nil, fmt.Errorf("%w: %w", ErrFileLocked, perr)
+		}
+		return nil, perr
+	}
+	return os.NewFile(uintptr(h), name), nil
+}
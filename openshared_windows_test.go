@@ -0,0 +1,41 @@
+//go:build windows
+
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+// TestOpenSharedLocked 测试其它句柄以独占方式打开时返回 ErrFileLocked，只共享读写时仍能打开
+func TestOpenSharedLocked(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "doc.docx")
+	_ = os.WriteFile(file, []byte("x"), 0644)
+	p, _ := windows.UTF16PtrFromString(file)
+
+	open := func(share uint32) windows.Handle {
+		h, err := windows.CreateFile(p, windows.GENERIC_READ|windows.GENERIC_WRITE, share, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+		if err != nil {
+			t.Fatalf("CreateFile failed: %v", err)
+		}
+		return h
+	}
+
+	h := open(windows.FILE_SHARE_READ | windows.FILE_SHARE_WRITE)
+	f, err := openShared(file)
+	if err != nil {
+		t.Fatalf("openShared with a shared writer failed: %v", err)
+	}
+	_ = f.Close()
+	_ = windows.CloseHandle(h)
+
+	h = open(0)
+	defer windows.CloseHandle(h)
+	if _, err := openShared(file); !errors.Is(err, ErrFileLocked) {
+		t.Fatalf("openShared of an exclusively opened file = %v; want ErrFileLocked", err)
+	}
+}
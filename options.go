@@ -0,0 +1,849 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// Option 是 NewWatcherWithOptions 的配置项
+//
+// 每个 Option 在应用时校验自己的参数，非法参数返回错误；
+// NewWatcherWithOptions 会收集所有 Option 的错误后一并返回，而不是在第一个错误处停止
+type Option func(*ConfigWatcher) error
+
+// 默认配置
+const (
+	defaultDebounce         = 10 * time.Millisecond
+	defaultWorkerCount      = 32
+	defaultRootPollInterval = time.Second
+)
+
+// defaultConfig 返回填充了默认值的配置
+func defaultConfig() ConfigWatcher {
+	return ConfigWatcher{
+		Debounce:                defaultDebounce,
+		WorkerCount:             defaultWorkerCount,
+		RootPollInterval:        defaultRootPollInterval,
+		HashBufferSize:          DefaultHashBufferSize,
+		CompletionQuiet:         DefaultCompletionQuiet,
+		SelfWriteWindow:         DefaultSelfWriteWindow,
+		MaxChangedPaths:         DefaultMaxChangedPaths,
+		MaxPendingPaths:         DefaultMaxPendingPaths,
+		MaxOpTrace:              DefaultMaxOpTrace,
+		DescribeSnapshotTimeout: DefaultDescribeTimeout,
+		WorkerTuneInterval:      DefaultWorkerTuneInterval,
+		WatchRetryInterval:      DefaultWatchRetryInterval,
+		StormDwell:              DefaultStormDwell,
+		StormRecovery:           DefaultStormRecovery,
+		StormMaxDebounce:        DefaultStormMaxDebounce,
+	}
+}
+
+// NewWatcherWithOptions 监控 paths 并按 opts 配置创建 Watcher
+//
+// 未设置的选项使用默认值(Debounce 10ms、WorkerCount 32、RootPollInterval 1s、HashBufferSize 1MB、MaxChangedPaths 1000)，
+// 与 ConfigWatcher 不同，显式传入的 0 等非法值会报错而不会被当作"使用默认值"
+// 同一选项多次出现时：列表类选项(如 WithIgnorePatterns)追加，其余以最后一次为准
+func NewWatcherWithOptions(paths []string, opts ...Option) (*Watcher, error) {
+	cfg := defaultConfig()
+	cfg.WatchPaths = append([]string(nil), paths...)
+
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	return newWatcher(cfg)
+}
+
+// withConfig 用整个 ConfigWatcher 覆盖配置，供 NewWatcher 使用
+//
+// 保持 ConfigWatcher 的约定：数值字段为零值(或负数)时沿用默认值
+func withConfig(src ConfigWatcher) Option {
+	return func(cfg *ConfigWatcher) error {
+		def := *cfg
+		*cfg = src
+		if cfg.Debounce <= 0 {
+			cfg.Debounce = def.Debounce
+		}
+		if cfg.WorkerCount <= 0 {
+			cfg.WorkerCount = def.WorkerCount
+		}
+		if cfg.RootPollInterval <= 0 {
+			cfg.RootPollInterval = def.RootPollInterval
+		}
+		if cfg.HashBufferSize <= 0 {
+			cfg.HashBufferSize = def.HashBufferSize
+		}
+		if cfg.CompletionQuiet <= 0 {
+			cfg.CompletionQuiet = def.CompletionQuiet
+		}
+		if cfg.MaxChangedPaths <= 0 {
+			cfg.MaxChangedPaths = def.MaxChangedPaths
+		}
+		if cfg.MaxPendingPaths <= 0 {
+			cfg.MaxPendingPaths = def.MaxPendingPaths
+		}
+		if cfg.MaxOpTrace <= 0 {
+			cfg.MaxOpTrace = def.MaxOpTrace
+		}
+		if cfg.WorkerTuneInterval <= 0 {
+			cfg.WorkerTuneInterval = def.WorkerTuneInterval
+		}
+		if cfg.WatchRetryInterval <= 0 {
+			cfg.WatchRetryInterval = def.WatchRetryInterval
+		}
+		if cfg.DescribeSnapshotTimeout <= 0 {
+			cfg.DescribeSnapshotTimeout = def.DescribeSnapshotTimeout
+		}
+		if cfg.SelfWriteWindow <= 0 {
+			cfg.SelfWriteWindow = def.SelfWriteWindow
+		}
+		if cfg.StormDwell <= 0 {
+			cfg.StormDwell = def.StormDwell
+		}
+		if cfg.StormRecovery <= 0 {
+			cfg.StormRecovery = def.StormRecovery
+		}
+		if cfg.StormMaxDebounce <= 0 {
+			cfg.StormMaxDebounce = def.StormMaxDebounce
+		}
+		return nil
+	}
+}
+
+// validatePatterns 检查通配符语法
+func validatePatterns(patterns []string) error {
+	for _, pat := range patterns {
+		if _, err := filepath.Match(pat, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pat, err)
+		}
+	}
+	return nil
+}
+
+// WithIgnorePatterns 追加要忽略的文件通配符，语法错误的模式会报错
+func WithIgnorePatterns(patterns ...string) Option {
+	return func(cfg *ConfigWatcher) error {
+		if err := validatePatterns(patterns); err != nil {
+			return fmt.Errorf("WithIgnorePatterns: %w", err)
+		}
+		cfg.IgnorePatterns = append(cfg.IgnorePatterns, patterns...)
+		return nil
+	}
+}
+
+// WithDebounce 设置事件合并间隔，必须大于0
+func WithDebounce(d time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if d <= 0 {
+			return fmt.Errorf("WithDebounce: debounce must be positive, got %v", d)
+		}
+		cfg.Debounce = d
+		return nil
+	}
+}
+
+// WithSettleDelay 设置路径的最近一个事件之后安静多久才处理，必须大于0
+//
+// 连续写入期间的 flush 跳过该路径，最后一次写入之后才处理，只产生一个带最终哈希的事件；
+// 只在 flush 时检查，实际等待时间按 Debounce 向上取整，Stop 时不等待
+func WithSettleDelay(d time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if d <= 0 {
+			return fmt.Errorf("WithSettleDelay: delay must be positive, got %v", d)
+		}
+		cfg.SettleDelay = d
+		return nil
+	}
+}
+
+// WithMaxPendingPaths 设置合并表的路径数上限，达到时不等定时器立即 flush，必须大于0
+//
+// 默认 DefaultMaxPendingPaths；初始扫描期间留待回放的事件不受此限制，立即 flush 的次数见 Stats().SizeFlushes
+func WithMaxPendingPaths(n int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if n <= 0 {
+			return fmt.Errorf("WithMaxPendingPaths: limit must be positive, got %d", n)
+		}
+		cfg.MaxPendingPaths = n
+		return nil
+	}
+}
+
+// WithWorkerCount 设置并发处理 Worker 数，必须大于0
+func WithWorkerCount(n int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if n <= 0 {
+			return fmt.Errorf("WithWorkerCount: worker count must be positive, got %d", n)
+		}
+		cfg.WorkerCount = n
+		return nil
+	}
+}
+
+// WithLogger 设置警告日志输出
+func WithLogger(l *slog.Logger) Option {
+	return func(cfg *ConfigWatcher) error {
+		if l == nil {
+			return errors.New("WithLogger: logger is nil")
+		}
+		cfg.Logger = l
+		return nil
+	}
+}
+
+// WithHasher 设置文件内容哈希算法(如 sha512.New)
+//
+// 追加写检测要求 Sum 之后仍可继续写入，标准库的哈希实现均满足
+func WithHasher(newHash func() hash.Hash) Option {
+	return func(cfg *ConfigWatcher) error {
+		if newHash == nil || newHash() == nil {
+			return errors.New("WithHasher: hash constructor is nil or returns nil")
+		}
+		cfg.Hasher = newHash
+		return nil
+	}
+}
+
+// WithNoHashPatterns 追加只记录元信息、不计算哈希的文件通配符，命中的文件 HashState 为 SkippedType，
+// 比较快照时按大小与修改时间判断是否修改
+func WithNoHashPatterns(patterns ...string) Option {
+	return func(cfg *ConfigWatcher) error {
+		if err := validatePatterns(patterns); err != nil {
+			return fmt.Errorf("WithNoHashPatterns: %w", err)
+		}
+		cfg.NoHashPatterns = append(cfg.NoHashPatterns, patterns...)
+		return nil
+	}
+}
+
+// WithAppendOnlyPatterns 追加启用追加写检测的文件通配符
+func WithAppendOnlyPatterns(patterns ...string) Option {
+	return func(cfg *ConfigWatcher) error {
+		if err := validatePatterns(patterns); err != nil {
+			return fmt.Errorf("WithAppendOnlyPatterns: %w", err)
+		}
+		cfg.AppendOnlyPatterns = append(cfg.AppendOnlyPatterns, patterns...)
+		return nil
+	}
+}
+
+// WithCompletionDetection 追加需要写入完成检测的文件通配符，quiet 为判定完成所需的稳定时长(必须大于0)，
+// 命中的文件在写入完成前不提交、不发送事件，完成后按正常流程处理一次，事件的 Completed 为 true
+func WithCompletionDetection(quiet time.Duration, patterns ...string) Option {
+	return func(cfg *ConfigWatcher) error {
+		if quiet <= 0 {
+			return fmt.Errorf("WithCompletionDetection: quiet period must be positive, got %v", quiet)
+		}
+		if len(patterns) == 0 {
+			return fmt.Errorf("WithCompletionDetection: no patterns")
+		}
+		if err := validatePatterns(patterns); err != nil {
+			return fmt.Errorf("WithCompletionDetection: %w", err)
+		}
+		cfg.CompletionPatterns = append(cfg.CompletionPatterns, patterns...)
+		cfg.CompletionQuiet = quiet
+		return nil
+	}
+}
+
+// WithNativePaths 在快照与事件中保留本平台的路径分隔符，而不是统一为 '/'
+//
+// 接受路径参数的查询总是接受两种形式，导出格式(JSON、DirStore、审计日志)总是使用 '/' 形式
+func WithNativePaths() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.NativePaths = true
+		return nil
+	}
+}
+
+// WithPathRewrite 把磁盘上的原始路径改写为快照、事件与持久化中使用的逻辑路径，inverse 为其逆变换，
+// 两者必须互逆。WatchPaths、IgnorePatterns 与注入的 FS/EventSource 使用原始路径，其余 API 使用逻辑路径；
+// 不可逆的监控根使 NewWatcher 失败，运行中遇到的这类路径被跳过并发送 *PathRewriteError
+func WithPathRewrite(rewrite, inverse func(string) string) Option {
+	return func(cfg *ConfigWatcher) error {
+		if rewrite == nil || inverse == nil {
+			return errors.New("WithPathRewrite: rewrite and inverse must not be nil")
+		}
+		cfg.PathRewrite = rewrite
+		cfg.PathRewriteInverse = inverse
+		return nil
+	}
+}
+
+// WithPathPrefixRewrite 把 rawPrefix 下的路径改写为 logicalPrefix 下的对应路径(如绑定挂载 /mnt/data → /srv)，
+// 其它路径不变，见 WithPathRewrite
+//
+// 监控树中存在 logicalPrefix 下的原始路径时，它与改写结果冲突，按 ErrPathRewriteConflict 处理
+func WithPathPrefixRewrite(rawPrefix, logicalPrefix string) Option {
+	return func(cfg *ConfigWatcher) error {
+		if rawPrefix == "" || logicalPrefix == "" {
+			return errors.New("WithPathPrefixRewrite: prefixes must not be empty")
+		}
+		return WithPathRewrite(replacePrefix(rawPrefix, logicalPrefix), replacePrefix(logicalPrefix, rawPrefix))(cfg)
+	}
+}
+
+// WithBaseline 以 base(如 BaselineFromManifest 的结果)的文件表作为初始快照，keepOutsideRoots 时保留监控根之外的路径
+//
+// 第一批变更事件的 OldMeta 即为 base 中的记录；监控根之外的路径默认使 NewWatcher 返回 ErrInvalidConfig。
+// 条目的 Hash 须与 Hasher 的算法一致；同时开启 ScanOnStart 时，扫描得到的基线快照是它的子快照
+func WithBaseline(base *SnapshotNode, keepOutsideRoots bool) Option {
+	return func(cfg *ConfigWatcher) error {
+		if base == nil {
+			return fmt.Errorf("WithBaseline: nil snapshot")
+		}
+		cfg.Baseline = base
+		cfg.BaselineKeepOutsideRoots = keepOutsideRoots
+		return nil
+	}
+}
+
+// WithMaxHashSize 设置计算哈希的文件大小上限(字节)，必须大于0
+func WithMaxHashSize(n int64) Option {
+	return func(cfg *ConfigWatcher) error {
+		if n <= 0 {
+			return fmt.Errorf("WithMaxHashSize: size must be positive, got %d", n)
+		}
+		cfg.MaxHashSize = n
+		return nil
+	}
+}
+
+// WithHashErrorPolicy 设置计算哈希失败时如何记录该文件，见 HashErrorPolicy
+func WithHashErrorPolicy(p HashErrorPolicy) Option {
+	return func(cfg *ConfigWatcher) error {
+		if !p.valid() {
+			return fmt.Errorf("WithHashErrorPolicy: unknown policy %v", p)
+		}
+		cfg.HashErrorPolicy = p
+		return nil
+	}
+}
+
+// WithHashBufferSize 设置计算哈希的读缓冲大小(字节)，必须大于0，内存占用约为 WorkerCount × n
+func WithHashBufferSize(n int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if n <= 0 {
+			return fmt.Errorf("WithHashBufferSize: size must be positive, got %d", n)
+		}
+		cfg.HashBufferSize = n
+		return nil
+	}
+}
+
+// WithFailOnPartialWatch 使任一目录注册监控失败时 Start 返回 *PartialWatchError，
+// 默认以降级状态继续运行，失败项见 WatchErrors()
+func WithFailOnPartialWatch() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.FailOnPartialWatch = true
+		return nil
+	}
+}
+
+// WithMaxWatchedDirs 限制注册监控的目录数，n 必须大于0
+//
+// 超出的目录不注册监控，其下的变更不会被感知，相应的错误包装 ErrWatchBudget；用量见 Stats().WatchedDirs 与 WatchCoverage
+func WithMaxWatchedDirs(n int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if n <= 0 {
+			return fmt.Errorf("WithMaxWatchedDirs: limit must be positive, got %d", n)
+		}
+		cfg.MaxWatchedDirs = n
+		return nil
+	}
+}
+
+// WithWatchRetryInterval 设置因系统资源耗尽注册失败的目录自动重试的初始间隔，必须大于0，
+// 仍失败时间隔翻倍，见 RetryFailedWatches
+func WithWatchRetryInterval(d time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if d <= 0 {
+			return fmt.Errorf("WithWatchRetryInterval: interval must be positive, got %v", d)
+		}
+		cfg.WatchRetryInterval = d
+		return nil
+	}
+}
+
+// WithRootPollInterval 设置监控根存在性巡检间隔，必须大于0
+func WithRootPollInterval(d time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if d <= 0 {
+			return fmt.Errorf("WithRootPollInterval: interval must be positive, got %v", d)
+		}
+		cfg.RootPollInterval = d
+		return nil
+	}
+}
+
+// WithKeepEntriesOnRootLoss 使监控根消失时保留快照中其下的条目
+func WithKeepEntriesOnRootLoss() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.KeepEntriesOnRootLoss = true
+		return nil
+	}
+}
+
+// WithRejectOverlappingRoots 使监控路径重叠时返回错误而不是合并
+func WithRejectOverlappingRoots() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.RejectOverlappingRoots = true
+		return nil
+	}
+}
+
+// WithScanOnStart 使 Start 时全量扫描并提交基线快照，提交后发送 OpBaseline 事件
+//
+// progress 为进度回调(可为nil)，最多每 500ms 调用一次，total 在遍历完成前为 -1
+func WithScanOnStart(progress func(scanned, total int64, currentPath string)) Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.ScanOnStart = true
+		cfg.ScanProgress = progress
+		return nil
+	}
+}
+
+// WithTracer 设置批次处理追踪钩子
+func WithTracer(t BatchTracer) Option {
+	return func(cfg *ConfigWatcher) error {
+		if t == nil {
+			return errors.New("WithTracer: tracer is nil")
+		}
+		cfg.Tracer = t
+		return nil
+	}
+}
+
+// WithHotPaths 开启按路径的事件计数，window 必须大于0，capacity 为0时使用默认值，见 HotPaths
+func WithHotPaths(window time.Duration, capacity int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if window <= 0 {
+			return fmt.Errorf("WithHotPaths: window must be positive, got %v", window)
+		}
+		if capacity < 0 {
+			return fmt.Errorf("WithHotPaths: capacity must not be negative, got %d", capacity)
+		}
+		cfg.HotPathWindow = window
+		cfg.HotPathCapacity = capacity
+		return nil
+	}
+}
+
+// WithInstanceID 设置嵌入快照ID的实例ID，只能包含字母、数字、'.'、'_'、'-'
+//
+// 默认随机生成；多个 Watcher 共用一个 Store 时各自只使用属于本实例的快照，重启后需要沿用同一段历史时应设置固定的ID
+func WithInstanceID(id string) Option {
+	return func(cfg *ConfigWatcher) error {
+		if !validInstanceID(id) {
+			return fmt.Errorf("WithInstanceID: invalid instance id %q", id)
+		}
+		cfg.InstanceID = id
+		return nil
+	}
+}
+
+// WithHistoryLimit 追加一条按路径限制版本数的规则，versions 必须大于0，见 HistoryLimit
+func WithHistoryLimit(pattern string, versions int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if err := validatePatterns([]string{pattern}); err != nil {
+			return fmt.Errorf("WithHistoryLimit: %w", err)
+		}
+		if versions <= 0 {
+			return fmt.Errorf("WithHistoryLimit: versions must be positive, got %d", versions)
+		}
+		cfg.HistoryLimits = append(cfg.HistoryLimits, HistoryLimit{Pattern: pattern, Versions: versions})
+		return nil
+	}
+}
+
+// WithTombstones 让被删除的条目以墓碑形式在之后的 snapshots 个快照中保留，snapshots 必须大于0
+//
+// 墓碑的 Deleted 为 true，不参与目录哈希与 RootHash，查询传入 IncludeDeleted 时才返回；数量见 Stats().Tombstones
+func WithTombstones(snapshots int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if snapshots <= 0 {
+			return fmt.Errorf("WithTombstones: snapshots must be positive, got %d", snapshots)
+		}
+		cfg.TombstoneSnapshots = snapshots
+		return nil
+	}
+}
+
+// WithMaxChangedPaths 设置快照记录的变更路径数上限，n 必须大于0，见 SnapshotNode.ChangedPaths
+func WithMaxChangedPaths(n int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if n <= 0 {
+			return fmt.Errorf("WithMaxChangedPaths: limit must be positive, got %d", n)
+		}
+		cfg.MaxChangedPaths = n
+		return nil
+	}
+}
+
+// WithRescanOnOverflow 在内核事件队列溢出后自动对账重扫全部监控根，这是溢出后唯一可靠的恢复方式；
+// 无论是否开启，溢出都以 *OverflowError 发送到 ErrorChan
+func WithRescanOnOverflow() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.RescanOnOverflow = true
+		return nil
+	}
+}
+
+// WithStormProtection 开启事件风暴保护：每秒事件数超过 maxEvents 或每秒计算哈希的字节数超过 maxHashBytes
+// 并持续 dwell 后降级，0 表示不限制该项，但不能都为0
+//
+// 降级期间不计算哈希(HashState 为 SkippedDegraded)、拉长 flush 间隔，进入与退出时各发送一个 *DegradedMode
+func WithStormProtection(maxEvents int, maxHashBytes int64, dwell time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if maxEvents < 0 || maxHashBytes < 0 || maxEvents == 0 && maxHashBytes == 0 {
+			return fmt.Errorf("WithStormProtection: need a positive limit, got %d events/s and %d bytes/s", maxEvents, maxHashBytes)
+		}
+		if dwell <= 0 {
+			return fmt.Errorf("WithStormProtection: dwell must be positive, got %v", dwell)
+		}
+		cfg.StormMaxEventsPerSec = maxEvents
+		cfg.StormMaxHashBytesPerSec = maxHashBytes
+		cfg.StormDwell = dwell
+		return nil
+	}
+}
+
+// WithStormRecovery 设置速率回落后恢复正常前需要持续的时间与降级期间的 flush 间隔，必须大于0
+func WithStormRecovery(recovery, maxDebounce time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if recovery <= 0 || maxDebounce <= 0 {
+			return fmt.Errorf("WithStormRecovery: durations must be positive, got %v and %v", recovery, maxDebounce)
+		}
+		cfg.StormRecovery = recovery
+		cfg.StormMaxDebounce = maxDebounce
+		return nil
+	}
+}
+
+// WithStormNotifyOnly 超过事件风暴上限时只发送 *DegradedMode 通知，不降级
+func WithStormNotifyOnly() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.StormNotifyOnly = true
+		return nil
+	}
+}
+
+// WithIgnoreChmod 丢弃只有 Chmod 的事件(只改变权限、时间戳、扩展属性等元信息)，macOS 上默认开启，见 PlatformDefaults
+func WithIgnoreChmod() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.IgnoreChmod = true
+		return nil
+	}
+}
+
+// WithoutPlatformDefaults 不应用当前平台的默认配置，见 PlatformDefaults
+func WithoutPlatformDefaults() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.DisablePlatformDefaults = true
+		return nil
+	}
+}
+
+// WithPriority 设置批次内路径的优先级函数，按大小区分可使用 SmallFilesFirst
+//
+// 返回值大于0的路径优先处理，其余路径最多占用一半的 worker、在后台处理；排队数见 Stats().FastLaneQueued/BulkLaneQueued
+func WithPriority(fn func(path string, size int64) int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if fn == nil {
+			return errors.New("WithPriority: priority func is nil")
+		}
+		cfg.Priority = fn
+		return nil
+	}
+}
+
+// WithHealthThresholds 设置 Health() 的判定阈值，零值字段使用默认值，负数报错
+func WithHealthThresholds(th HealthThresholds) Option {
+	return func(cfg *ConfigWatcher) error {
+		if th.BacklogDegraded < 0 || th.BacklogFailed < 0 || th.FlushDelayDegraded < 0 || th.FlushDelayFailed < 0 {
+			return fmt.Errorf("WithHealthThresholds: thresholds must not be negative, got %+v", th)
+		}
+		cfg.HealthThresholds = th
+		return nil
+	}
+}
+
+// WithAuditWriter 把审计日志写到 wr，与 WithAuditPath 互斥
+func WithAuditWriter(wr io.Writer) Option {
+	return func(cfg *ConfigWatcher) error {
+		if wr == nil {
+			return errors.New("WithAuditWriter: writer is nil")
+		}
+		if cfg.AuditPath != "" {
+			return errors.New("WithAuditWriter: mutually exclusive with WithAuditPath")
+		}
+		cfg.AuditWriter = wr
+		return nil
+	}
+}
+
+// WithAuditPath 把审计日志追加写入 path，与 WithAuditWriter 互斥
+func WithAuditPath(path string) Option {
+	return func(cfg *ConfigWatcher) error {
+		if path == "" {
+			return errors.New("WithAuditPath: path is empty")
+		}
+		if cfg.AuditWriter != nil {
+			return errors.New("WithAuditPath: mutually exclusive with WithAuditWriter")
+		}
+		cfg.AuditPath = path
+		return nil
+	}
+}
+
+// WithAuditFlushInterval 设置审计日志 flush 间隔，必须大于0
+func WithAuditFlushInterval(d time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if d <= 0 {
+			return fmt.Errorf("WithAuditFlushInterval: interval must be positive, got %v", d)
+		}
+		cfg.AuditFlushInterval = d
+		return nil
+	}
+}
+
+// WithAuditQueueSize 设置审计日志队列长度，必须大于0
+func WithAuditQueueSize(n int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if n <= 0 {
+			return fmt.Errorf("WithAuditQueueSize: queue size must be positive, got %d", n)
+		}
+		cfg.AuditQueueSize = n
+		return nil
+	}
+}
+
+// WithAuditRotation 在审计日志超过 size 字节时调用 onRotate 获取新的输出(onRotate 可为nil)
+func WithAuditRotation(size int64, onRotate func(size int64) (io.Writer, error)) Option {
+	return func(cfg *ConfigWatcher) error {
+		if size <= 0 {
+			return fmt.Errorf("WithAuditRotation: size must be positive, got %d", size)
+		}
+		cfg.AuditRotateSize = size
+		cfg.AuditOnRotate = onRotate
+		return nil
+	}
+}
+
+// WithDisableSnapshots 只发送事件而不创建快照，事件的 NewSnap 为nil，只维护可变的当前状态，见 GetCurrentSnapshot
+func WithDisableSnapshots() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.DisableSnapshots = true
+		return nil
+	}
+}
+
+// WithDisableCurrentState 既不创建快照也不维护当前状态(隐含 WithDisableSnapshots)，
+// 事件的 OldMeta 始终为nil，GetCurrentSnapshot 返回nil
+func WithDisableCurrentState() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.DisableSnapshots = true
+		cfg.DisableCurrentState = true
+		return nil
+	}
+}
+
+// WithScanEvents 使初始扫描在 OpBaseline 之前为每个条目发送 OpScan 事件
+//
+// 条目很多时逐个处理很慢，推荐在收到 OpBaseline 后整体读取基线快照
+func WithScanEvents() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.ScanEvents = true
+		return nil
+	}
+}
+
+// WithDisableEventChan 不创建 EventChan，适合只轮询快照或只使用 Subscribe 的场景，避免通道写满后阻塞处理流程
+func WithDisableEventChan() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.DisableEventChan = true
+		return nil
+	}
+}
+
+// WithEventSnapshotMode 设置事件携带快照的方式，见 EventSnapshotMode
+func WithEventSnapshotMode(mode EventSnapshotMode) Option {
+	return func(cfg *ConfigWatcher) error {
+		if !mode.valid() {
+			return fmt.Errorf("WithEventSnapshotMode: unknown mode %v", mode)
+		}
+		cfg.EventSnapshotMode = mode
+		return nil
+	}
+}
+
+// WithOpTrace 在事件的 OpTrace 中记录合并的底层操作序列，每个事件至多 max 个(超出时丢弃最早的)，必须大于0
+func WithOpTrace(max int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if max <= 0 {
+			return fmt.Errorf("WithOpTrace: limit must be positive, got %d", max)
+		}
+		cfg.RecordOpTrace = true
+		cfg.MaxOpTrace = max
+		return nil
+	}
+}
+
+// WithWorkerAutoTune 开启 worker 上限的自动调整，范围 [min, max] 须包含 WorkerCount(为0时使用默认值)
+//
+// 按持续的饱和或空闲逐步调整，每次调整以 Info 级别记录日志；SetWorkerLimit 手动设置上限后不再自动调整
+func WithWorkerAutoTune(min, max int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if min < 0 || max < 0 || (min > 0 && max > 0 && min > max) {
+			return fmt.Errorf("WithWorkerAutoTune: invalid range [%d, %d]", min, max)
+		}
+		cfg.WorkerAutoTune = true
+		cfg.WorkerMin, cfg.WorkerMax = min, max
+		return nil
+	}
+}
+
+// WithDescribeSnapshot 设置生成快照描述的回调与等待它的时长(timeout 为0时使用 DefaultDescribeTimeout)
+//
+// fn 以本次的变更列表(同 SnapshotNode.ChangedPaths)与父快照调用，返回空串时使用默认描述。
+// 快照先以默认描述发布，fn 在提交锁之外执行，返回后替换描述；提交它的goroutine同步等待 fn，
+// 因此 fn 必须很快返回，也不能调用 Watcher 的方法。panic 或超时时保留默认描述并计入 Stats().DescribeFallbacks
+func WithDescribeSnapshot(fn func(batch []ChangedPath, parent *SnapshotNode) string, timeout time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if fn == nil {
+			return errors.New("WithDescribeSnapshot: describe func is nil")
+		}
+		if timeout < 0 {
+			return fmt.Errorf("WithDescribeSnapshot: timeout must not be negative, got %v", timeout)
+		}
+		cfg.DescribeSnapshot = fn
+		if timeout > 0 {
+			cfg.DescribeSnapshotTimeout = timeout
+		}
+		return nil
+	}
+}
+
+// WithReplayBuffer 设置供 SubscribeFrom 回放的最近事件数，必须大于0
+//
+// 保存的事件只引用快照ID，内存占用只与数量有关，当前数量见 Stats().ReplayBuffered
+func WithReplayBuffer(n int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if n <= 0 {
+			return fmt.Errorf("WithReplayBuffer: size must be positive, got %d", n)
+		}
+		cfg.ReplayBuffer = n
+		return nil
+	}
+}
+
+// WithSelfWriteWindow 设置 MarkSelfWrite 标记的有效时长，必须大于0，见 MarkSelfWrite
+func WithSelfWriteWindow(d time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if d <= 0 {
+			return fmt.Errorf("WithSelfWriteWindow: window must be positive, got %v", d)
+		}
+		cfg.SelfWriteWindow = d
+		return nil
+	}
+}
+
+// WithErrorDedup 让同一路径、同一类别的错误在 window 内只发送一次，结束时汇总为 *RepeatedError，
+// window 必须大于0；窗口内的重复只计数(Stats().ErrorsDeduplicated)
+func WithErrorDedup(window time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if window <= 0 {
+			return fmt.Errorf("WithErrorDedup: window must be positive, got %v", window)
+		}
+		cfg.ErrorDedupWindow = window
+		return nil
+	}
+}
+
+// WithClock 设置时间来源(测试中可使用 watchertest.FakeClock)，见 Clock
+func WithClock(c Clock) Option {
+	return func(cfg *ConfigWatcher) error {
+		if c == nil {
+			return errors.New("WithClock: clock is nil")
+		}
+		cfg.Clock = c
+		return nil
+	}
+}
+
+// WithMinSnapshotInterval 限制两次创建快照的最小间隔，必须大于0
+//
+// 窗口内完成的批次并入同一个待发布的快照，窗口打开或 Stop 时发布；这些变更的事件延后到发布时发送，
+// NewSnap 都指向最终的快照，OldMeta/NewMeta 仍是逐个变更的前后状态
+func WithMinSnapshotInterval(d time.Duration) Option {
+	return func(cfg *ConfigWatcher) error {
+		if d <= 0 {
+			return fmt.Errorf("WithMinSnapshotInterval: interval must be positive, got %v", d)
+		}
+		cfg.MinSnapshotInterval = d
+		return nil
+	}
+}
+
+// WithReconcileSummary 使对账差异超过 threshold 个路径时只发送一个 OpReconcileSummary 事件，
+// threshold 必须大于0
+func WithReconcileSummary(threshold int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if threshold <= 0 {
+			return fmt.Errorf("WithReconcileSummary: threshold must be positive, got %d", threshold)
+		}
+		cfg.ReconcileSummaryThreshold = threshold
+		return nil
+	}
+}
+
+// WithStore 把快照持久化到 store，内存中只完整保留最近 keep 个快照，
+// 更早的替换为占位节点，GetSnapshotByID、DiffSnapshots 等按需从 store 读回
+//
+// keep 为0时快照全部留在内存，只在 Close 时写入 store；keep 不能为负数
+func WithStore(store SnapshotStore, keep int) Option {
+	return func(cfg *ConfigWatcher) error {
+		if store == nil {
+			return errors.New("WithStore: store is nil")
+		}
+		if keep < 0 {
+			return fmt.Errorf("WithStore: keep must not be negative, got %d", keep)
+		}
+		cfg.Store = store
+		cfg.MemorySnapshots = keep
+		return nil
+	}
+}
+
+// WithValidateStoreOnStart 使 Start 时执行 ValidateStore，问题发送到 ErrorChan
+func WithValidateStoreOnStart() Option {
+	return func(cfg *ConfigWatcher) error {
+		cfg.ValidateStoreOnStart = true
+		return nil
+	}
+}
+
+// WithFS 设置文件系统读取与事件来源(如 watchertest.MemFS 与其 Source())，见 FS 与 EventSource
+//
+// 两者需配套使用：事件中的路径必须能通过 fsys 读取
+func WithFS(fsys FS, src EventSource) Option {
+	return func(cfg *ConfigWatcher) error {
+		if fsys == nil || src == nil {
+			return errors.New("WithFS: filesystem and event source must not be nil")
+		}
+		cfg.FS = fsys
+		cfg.EventSource = src
+		return nil
+	}
+}
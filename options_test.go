@@ -0,0 +1,163 @@
+package watcher
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestOptionDefaults 测试未传选项时的默认值
+func TestOptionDefaults(t *testing.T) {
+	w, err := NewWatcherWithOptions([]string{t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+	if w.cfg.Debounce != defaultDebounce || w.cfg.WorkerCount != defaultWorkerCount || w.cfg.RootPollInterval != defaultRootPollInterval ||
+		w.cfg.HashBufferSize != DefaultHashBufferSize {
+		t.Errorf("unexpected defaults: %+v", w.cfg)
+	}
+	if cap(w.workerPool) != defaultWorkerCount {
+		t.Errorf("worker pool cap = %d", cap(w.workerPool))
+	}
+}
+
+// TestOptionComposition 测试列表选项追加、标量选项以最后一次为准
+func TestOptionComposition(t *testing.T) {
+	cfg := defaultConfig()
+	for _, opt := range []Option{
+		WithIgnorePatterns("*.tmp"),
+		WithDebounce(time.Second),
+		WithIgnorePatterns("*.swp", "build/*"),
+		WithDebounce(50 * time.Millisecond),
+		WithWorkerCount(4),
+	} {
+		if err := opt(&cfg); err != nil {
+			t.Fatalf("option failed: %v", err)
+		}
+	}
+	if got := strings.Join(cfg.IgnorePatterns, ","); got != "*.tmp,*.swp,build/*" {
+		t.Errorf("IgnorePatterns = %s", got)
+	}
+	if cfg.Debounce != 50*time.Millisecond || cfg.WorkerCount != 4 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+// TestOptionValidation 测试非法参数报错且所有错误被一并返回
+func TestOptionValidation(t *testing.T) {
+	_, err := NewWatcherWithOptions([]string{t.TempDir()},
+		WithWorkerCount(0),
+		WithDebounce(-time.Second),
+		WithIgnorePatterns("[a-"),
+		WithLogger(nil),
+		WithHashBufferSize(0),
+		WithStore(nil, 1),
+		WithMinSnapshotInterval(0),
+		WithReconcileSummary(0),
+		WithMaxWatchedDirs(-1),
+		WithPriority(nil),
+		WithMaxChangedPaths(0),
+		WithNoHashPatterns("[bad"),
+		WithStormProtection(0, 0, time.Second),
+		WithStormRecovery(0, time.Second),
+		WithHistoryLimit("*.log", 0),
+		WithHotPaths(0, 10),
+		WithInstanceID("a/b"),
+		WithCompletionDetection(0, "*.iso"),
+		WithBaseline(nil, false),
+		WithTombstones(0),
+		WithEventSnapshotMode(EventSnapshotMode(9)),
+		WithSelfWriteWindow(0),
+		WithErrorDedup(-time.Second),
+		WithPathRewrite(nil, nil),
+		WithPathPrefixRewrite("", "/srv"),
+		WithDebounce(time.Millisecond),
+	)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"WithWorkerCount", "WithDebounce", "WithIgnorePatterns", "WithLogger", "WithHashBufferSize", "WithStore", "WithMinSnapshotInterval", "WithReconcileSummary", "WithMaxWatchedDirs", "WithPriority", "WithMaxChangedPaths", "WithNoHashPatterns", "WithStormProtection", "WithStormRecovery", "WithHistoryLimit", "WithHotPaths", "WithInstanceID", "WithCompletionDetection", "WithBaseline", "WithTombstones", "WithEventSnapshotMode", "WithSelfWriteWindow", "WithErrorDedup", "WithPathRewrite", "WithPathPrefixRewrite"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+	if !errors.Is(err, filepath.ErrBadPattern) {
+		t.Errorf("expected wrapped ErrBadPattern, got %v", err)
+	}
+
+	_, err = NewWatcherWithOptions(nil, WithAuditPath("a.log"), WithAuditWriter(&bytes.Buffer{}))
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("expected mutually exclusive error, got %v", err)
+	}
+}
+
+// TestNewWatcherZeroValues 测试 NewWatcher 仍把零值当作默认值
+func TestNewWatcherZeroValues(t *testing.T) {
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{t.TempDir()}, WorkerCount: -1, IgnorePatterns: []string{"*.tmp"}})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+	if w.cfg.Debounce != defaultDebounce || w.cfg.WorkerCount != defaultWorkerCount || len(w.cfg.IgnorePatterns) != 1 ||
+		w.cfg.HashBufferSize != DefaultHashBufferSize {
+		t.Errorf("unexpected config %+v", w.cfg)
+	}
+}
+
+// TestWithHasher 测试自定义内容哈希算法(含零字节文件)
+func TestWithHasher(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	empty := filepath.Join(root, "empty.txt")
+	_ = os.WriteFile(file, []byte("hello"), 0644)
+	_ = os.WriteFile(empty, nil, 0644)
+
+	w, err := NewWatcherWithOptions([]string{root}, WithHasher(sha512.New))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+
+	sum := sha512.Sum512([]byte("hello"))
+	if meta, _, err := w.RehashFile(file); err != nil || meta.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("hash = %v, err = %v", meta, err)
+	}
+	sum = sha512.Sum512(nil)
+	if meta, _, err := w.RehashFile(empty); err != nil || meta.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("empty hash = %v, err = %v", meta, err)
+	}
+}
+
+// TestWithLogger 测试警告输出到配置的 Logger
+func TestWithLogger(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, 2, 1)
+	var buf bytes.Buffer
+	w, err := NewWatcherWithOptions([]string{root}, WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.fsWatcher.Close()
+	bad := filepath.Join(root, "d1")
+	w.addWatchFn = func(p string) error {
+		if p == bad {
+			return syscall.ENOSPC
+		}
+		return w.fsWatcher.Add(p)
+	}
+	if err := w.registerWatches(); err != nil {
+		t.Fatalf("registerWatches failed: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, bad) {
+		t.Errorf("unexpected log output %q", out)
+	}
+}
@@ -0,0 +1,39 @@
+package watcher
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultMaxOpTrace 是 ConfigWatcher.MaxOpTrace 的默认值
+const DefaultMaxOpTrace = 32
+
+// OpSample 是合并进一个事件的单个底层操作及其到达 Watcher 的时间，见 FileEvent.OpTrace
+type OpSample struct {
+	Op EventOp
+	At time.Time
+}
+
+// appendTrace 追加一个操作，超过 max 个时丢弃最早的
+func appendTrace(trace []OpSample, s OpSample, max int) []OpSample {
+	if len(trace) < max {
+		return append(trace, s)
+	}
+	copy(trace, trace[1:])
+	trace[len(trace)-1] = s
+	return trace
+}
+
+// joinTrace 按到达时间合并两段操作序列(如放回合并表的路径与期间新到达的事件)，只保留最近的 max 个
+func joinTrace(a, b []OpSample, max int) []OpSample {
+	if len(b) == 0 {
+		return a
+	}
+	out := make([]OpSample, 0, len(a)+len(b))
+	out = append(append(out, a...), b...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	if len(out) > max {
+		out = out[len(out)-max:]
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestOpTrace 测试合并进事件的底层操作按到达顺序记录、超出上限时保留最近的，以及默认不记录
+func TestOpTrace(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		root := t.TempDir()
+		file := filepath.Join(root, "a.txt")
+		_ = os.WriteFile(file, []byte("a"), 0644)
+		clock := &manualClock{}
+		clock.now.Store(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+		opts := []Option{WithFS(osFS{}, replaySource{}), WithClock(clock), WithDisableEventChan()}
+		if enabled {
+			opts = append(opts, WithOpTrace(3))
+		}
+		w, err := NewWatcherWithOptions([]string{root}, opts...)
+		if err != nil {
+			t.Fatalf("NewWatcherWithOptions failed: %v", err)
+		}
+		sub := w.Subscribe(4)
+		for _, op := range []fsnotify.Op{fsnotify.Create, fsnotify.Write, fsnotify.Chmod, fsnotify.Write} {
+			w.queueAgg(fsnotify.Event{Name: w.keyOf(file), Op: op})
+			w.mergeAgg(<-w.aggChan)
+			clock.advance(time.Millisecond)
+		}
+		w.flushAgg(true)
+		w.workerWG.Wait()
+		ev := <-sub.C
+		_ = w.Close()
+
+		if ev.Kind != OpCreate|OpWrite|OpChmod {
+			t.Errorf("Kind = %s", ev.Kind)
+		}
+		if !enabled {
+			if ev.OpTrace != nil {
+				t.Errorf("OpTrace = %v; want nil by default", ev.OpTrace)
+			}
+			continue
+		}
+		if got := fmt.Sprint(ev.OpTrace[0].Op, ev.OpTrace[1].Op, ev.OpTrace[2].Op); len(ev.OpTrace) != 3 || got != "WRITE CHMOD WRITE" {
+			t.Fatalf("OpTrace = %v; want the last 3 operations in order", ev.OpTrace)
+		}
+		if first := ev.FirstSeen.Add(time.Millisecond); !ev.OpTrace[0].At.Equal(first) || !ev.OpTrace[2].At.Equal(first.Add(2*time.Millisecond)) {
+			t.Errorf("OpTrace times %v; want 1ms apart after FirstSeen %v", ev.OpTrace, ev.FirstSeen)
+		}
+	}
+}
+
+// TestJoinTrace 测试放回合并表的操作序列与新到达的按时间合并
+func TestJoinTrace(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+	old := []OpSample{{OpCreate, at(1)}, {OpWrite, at(3)}}
+	fresh := []OpSample{{OpRemove, at(2)}, {OpCreate, at(4)}}
+	got := joinTrace(old, fresh, 3)
+	if fmt.Sprint(got[0].Op, got[1].Op, got[2].Op) != "REMOVE WRITE CREATE" || len(got) != 3 {
+		t.Errorf("joinTrace = %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// OverflowError 表示内核事件队列溢出(inotify IN_Q_OVERFLOW)，溢出期间的变更事件已经丢失
+//
+// 同时匹配 ErrEventOverflow 与底层错误(fsnotify.ErrEventOverflow)
+type OverflowError struct {
+	Roots  []string // 受影响的监控根：队列属于整个监控实例，因此是全部监控根
+	Rescan bool     // 是否已安排对账重扫(RescanOnOverflow)
+	Err    error    // fsnotify 报告的底层错误
+}
+
+func (e *OverflowError) Error() string {
+	msg := fmt.Sprintf("event queue overflow, changes under %s may be missed", strings.Join(e.Roots, ", "))
+	if e.Rescan {
+		msg += "; rescan scheduled"
+	}
+	return msg
+}
+
+// Unwrap 返回 ErrEventOverflow 与底层错误
+func (e *OverflowError) Unwrap() []error {
+	return []error{ErrEventOverflow, e.Err}
+}
+
+// isOverflow 判断 fsnotify 错误是否为事件队列溢出
+func isOverflow(err error) bool {
+	return errors.Is(err, fsnotify.ErrEventOverflow)
+}
+
+// handleOverflow 记录一次事件队列溢出，按配置安排对账重扫，并把 *OverflowError 发送到 ErrorChan
+//
+// 多次溢出在重扫开始前只安排一次重扫
+func (w *Watcher) handleOverflow(err error) {
+	w.counters.eventOverflows.Add(1)
+	oe := &OverflowError{Roots: w.Roots(), Rescan: w.cfg.RescanOnOverflow, Err: err}
+	if oe.Rescan {
+		select {
+		case w.rescanChan <- struct{}{}:
+		default:
+		}
+	}
+	if w.emitError(oe) {
+		w.logWarn("fsnotify event queue overflow", err)
+	}
+}
+
+// rescanRoots 在事件队列溢出后对账全部(仍存在的)监控根，由 runRootMonitor 调用
+func (w *Watcher) rescanRoots(lost map[string]bool) {
+	for _, root := range w.roots {
+		if lost[root] {
+			continue // 重新出现时会整体对账
+		}
+		w.reconcile(root, fmt.Sprintf("Rescan of %s after event queue overflow", root))
+	}
+	w.counters.overflowRescans.Add(1)
+}
@@ -0,0 +1,70 @@
+package watcher_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchertest"
+)
+
+// expectOverflow 从 ErrorChan 读取 *OverflowError
+func expectOverflow(t *testing.T, w *watcher.Watcher) *watcher.OverflowError {
+	t.Helper()
+	select {
+	case err := <-w.ErrorChan:
+		var oe *watcher.OverflowError
+		if !errors.As(err, &oe) || !errors.Is(err, watcher.ErrEventOverflow) || !errors.Is(err, fsnotify.ErrEventOverflow) {
+			t.Fatalf("error = %v; want *OverflowError", err)
+		}
+		return oe
+	case <-time.After(5 * time.Second):
+		t.Fatal("no overflow error reported")
+		return nil
+	}
+}
+
+// TestOverflowRescan 测试事件队列溢出后计数、报告错误并对账重扫，补上丢失的变更
+func TestOverflowRescan(t *testing.T) {
+	h := watchertest.NewHarness(t, watcher.WithRescanOnOverflow())
+	h.Touch("kept.txt", "1")
+	h.AdvanceDebounce()
+
+	// 变更事件尚未经过 flush，模拟其在溢出中丢失：只有重扫能把它提交到快照
+	if err := h.FS.WriteFile(h.Path("missed.txt"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	h.FS.InjectError(fsnotify.ErrEventOverflow)
+
+	oe := expectOverflow(t, h.W)
+	if !oe.Rescan || len(oe.Roots) != 1 || oe.Roots[0] != h.Root {
+		t.Errorf("OverflowError = %+v", oe)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for h.W.Stats().OverflowRescans != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("rescan not completed: %+v", h.W.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	h.ExpectEvent(t, watchertest.Matcher{Path: "missed.txt", Op: watcher.OpReconcileAdd})
+	if st := h.W.Stats(); st.EventOverflows != 1 {
+		t.Errorf("EventOverflows = %d; want 1", st.EventOverflows)
+	}
+	h.ExpectFile(t, "missed.txt", "")
+}
+
+// TestOverflowWithoutRescan 测试未开启 RescanOnOverflow 时只计数并报告错误
+func TestOverflowWithoutRescan(t *testing.T) {
+	h := watchertest.NewHarness(t)
+	h.FS.InjectError(fsnotify.ErrEventOverflow)
+	if oe := expectOverflow(t, h.W); oe.Rescan {
+		t.Errorf("Rescan = true without RescanOnOverflow")
+	}
+	if st := h.W.Stats(); st.EventOverflows != 1 || st.OverflowRescans != 0 {
+		t.Errorf("EventOverflows=%d OverflowRescans=%d; want 1/0", st.EventOverflows, st.OverflowRescans)
+	}
+}
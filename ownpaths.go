@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// 自身输出路径的自动排除
+//
+// Watcher 自己写入磁盘的路径(DirStore 的目录、AuditPath 的审计日志)位于监控根之下时，写入会产生事件、
+// 进入快照并再次写入 Store 或审计日志，形成反馈循环。NewWatcher 时把这些路径(目录连同其下全部内容)
+// 登记为忽略，效果与对应的 IgnorePatterns 相同：扫描时跳过，事件在进入合并表之前丢弃(计入 EventsIgnored)，
+// 并通过 Logger 记录一行说明。登记的路径见 DumpState 的 excluded_own_paths。
+//
+// 排除会连带排除监控根(路径就是某个监控根，或某个监控根位于其下)时无法区分监控内容与自身输出，
+// NewWatcher 返回 ErrInvalidConfig。审计日志轮转(AuditOnRotate)产生的文件名由回调决定，不会自动排除，
+// 应放在监控树之外或加入 IgnorePatterns
+
+// ownPath 是一个需要排除的自身输出路径
+type ownPath struct {
+	kind string // 用于日志与错误消息，如 "store directory"
+	path string
+}
+
+// ownPaths 返回配置中 Watcher 自己会写入的路径
+func ownPaths(cfg *ConfigWatcher) []ownPath {
+	var out []ownPath
+	if ds, ok := cfg.Store.(*DirStore); ok {
+		out = append(out, ownPath{"store directory", ds.dir})
+	}
+	if cfg.AuditPath != "" {
+		out = append(out, ownPath{"audit log", cfg.AuditPath})
+	}
+	return out
+}
+
+// excludeOwnPaths 把位于监控根之下的自身输出路径登记为忽略，需在扫描与注册监控之前调用
+//
+// w.excluded 保存原始路径(keyOf 形式，与 ignoredRaw 的参数一致)；与监控根的比较使用改写后的逻辑路径
+func (w *Watcher) excludeOwnPaths() error {
+	for _, own := range ownPaths(&w.cfg) {
+		abs, err := filepath.Abs(own.path)
+		if err != nil {
+			continue
+		}
+		logical, err := w.rewritePath(abs)
+		if err != nil {
+			continue
+		}
+		for _, r := range w.roots {
+			if withinRoot(r, logical) {
+				return fmt.Errorf("%w: %s %s would exclude watch root %s; move it out of the watched tree", ErrInvalidConfig, own.kind, abs, r)
+			}
+		}
+		if w.rootOf(logical) == "" {
+			continue
+		}
+		w.excluded = append(w.excluded, w.keyOf(abs))
+		w.logInfo("Excluding own output from watching", "kind", own.kind, "path", abs)
+	}
+	return nil
+}
+
+// ownExcluded 报告原始路径 path 是否为登记的自身输出路径或位于其下
+func (w *Watcher) ownExcluded(path string) bool {
+	for _, dir := range w.excluded {
+		if withinRoot(path, dir) {
+			return true
+		}
+	}
+	return false
+}
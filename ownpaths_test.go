@@ -0,0 +1,98 @@
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestExcludeOwnPaths 测试监控树中的 Store 目录与审计日志被自动忽略：写入它们不产生快照，并记录日志
+func TestExcludeOwnPaths(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewDirStore(filepath.Join(root, ".snapshots"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit := filepath.Join(root, "audit.ndjson")
+	var logs bytes.Buffer
+	w, err := NewWatcherWithOptions([]string{root}, WithStore(store, 1), WithAuditPath(audit),
+		WithDebounce(5*time.Millisecond), WithDisableEventChan(), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	if !strings.Contains(logs.String(), "store directory") || !strings.Contains(logs.String(), "audit log") {
+		t.Errorf("log = %q; want a line for each excluded path", logs.String())
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// 每次变更都会写审计日志并把旧快照换出到 Store，两者都在监控树中
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		_ = os.WriteFile(filepath.Join(root, name), []byte(name), 0644)
+		p := w.keyOf(filepath.Join(root, name))
+		if !waitFor(t, 2*time.Second, func() bool { _, ok := w.CurrentFile(p); return ok }) {
+			t.Fatalf("%s never reached the snapshot", name)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	created := w.Stats().SnapshotsCreated
+	for _, sn := range w.ListAllSnapshots() {
+		if strings.Contains(sn.Description, ".snapshots") || strings.Contains(sn.Description, "audit.ndjson") {
+			t.Errorf("self-generated snapshot %s: %s", sn.ID, sn.Description)
+		}
+	}
+	for p := range w.GetCurrentSnapshot().Files {
+		if withinRoot(p, w.keyOf(store.dir)) || p == w.keyOf(audit) {
+			t.Errorf("own output %s in the snapshot", p)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := w.Stats().SnapshotsCreated; got != created {
+		t.Errorf("snapshots kept being created by own writes: %d → %d", created, got)
+	}
+
+	ignored := w.Stats().EventsIgnored
+	w.handleFsEvent(fsnotify.Event{Name: filepath.Join(store.dir, "snap-x.json.gz"), Op: fsnotify.Create})
+	if w.Stats().EventsIgnored != ignored+1 {
+		t.Error("events under the store directory should be ignored")
+	}
+}
+
+// TestExcludeOwnPathsOverlap 测试自身输出路径就是监控根或包含监控根时 NewWatcher 报错，监控树之外的路径不登记
+func TestExcludeOwnPathsOverlap(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "tree")
+	_ = os.Mkdir(root, 0o755)
+	for name, dir := range map[string]string{"equal": root, "parent": base} {
+		store, err := NewDirStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewWatcherWithOptions([]string{root}, WithStore(store, 0)); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: error = %v; want ErrInvalidConfig", name, err)
+		}
+	}
+	if _, err := NewWatcherWithOptions([]string{root}, WithAuditPath(root)); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("audit log at the root: error = %v; want ErrInvalidConfig", err)
+	}
+
+	store, _ := NewDirStore(filepath.Join(base, "store"))
+	w, err := NewWatcherWithOptions([]string{root}, WithStore(store, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	if len(w.excluded) != 0 {
+		t.Errorf("excluded = %v; want none outside the watched tree", w.excluded)
+	}
+}
@@ -0,0 +1,164 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// 快照中的路径形式
+//
+// SnapshotNode.Files 的键、FileMetadata.Path、FileEvent.FilePath 等默认统一为以 '/' 分隔的形式
+// (filepath.ToSlash)，Windows 上生成的快照可以直接在其它平台上比较；NativePaths 时保留本平台的分隔符。
+// 路径在进入 Watcher 时(事件、监控根、扫描、查询参数)转换一次，内部按原形式拆分与拼接(dirOf)，
+// 只在访问文件系统时由操作系统接受 '/'。非 Windows 平台上分隔符本来就是 '/'，两种形式相同。
+// 导出格式(JSON、DirStore、HTTP)总是使用 '/' 形式，见 SlashPath
+
+// keyOf 把路径转换为快照中使用的形式：清理后以 '/' 分隔，NativePaths 时使用本平台的分隔符
+func (w *Watcher) keyOf(p string) string {
+	if p == "" {
+		return p
+	}
+	p = filepath.Clean(p)
+	if w.cfg.NativePaths {
+		return p
+	}
+	return filepath.ToSlash(p)
+}
+
+// dirOf 同 filepath.Dir，但保持 p 的分隔符形式(Windows 上 filepath.Dir 总是返回 '\' 形式)
+func dirOf(p string) string {
+	return sameStyle(p, filepath.Dir(p))
+}
+
+// sameStyle 在 like 使用 '/' 分隔时把 p 也转换为 '/' 形式
+func sameStyle(like, p string) string {
+	if os.PathSeparator != '/' && strings.Contains(like, "/") {
+		return filepath.ToSlash(p)
+	}
+	return p
+}
+
+// isSep 报告 c 是否为路径分隔符：'/' 与本平台的分隔符
+func isSep(c byte) bool {
+	return c == '/' || c == os.PathSeparator
+}
+
+// SlashPath 返回路径的 '/' 分隔形式(filepath.ToSlash)，导出格式使用这种形式
+func SlashPath(p string) string {
+	return filepath.ToSlash(p)
+}
+
+// pathForms 返回查找 p 时依次尝试的形式：原样、'/' 形式、本平台形式，以及把 '\' 视为分隔符的形式
+// (在其它平台上查找 Windows 上生成的快照)，去重
+func pathForms(p string) []string {
+	forms := []string{p}
+	for _, f := range []string{filepath.ToSlash(p), filepath.FromSlash(p), strings.ReplaceAll(p, `\`, "/")} {
+		if !slices.Contains(forms, f) {
+			forms = append(forms, f)
+		}
+	}
+	return forms
+}
+
+// Lookup 返回路径对应的条目，'/' 与 '\'(Windows)两种分隔形式都可以，不存在或为墓碑(Deleted)时返回nil
+func (sn *SnapshotNode) Lookup(path string) *FileMetadata {
+	return live(sn.lookup(path))
+}
+
+// lookup 同 Lookup，但也返回墓碑
+func (sn *SnapshotNode) lookup(path string) *FileMetadata {
+	if sn == nil {
+		return nil
+	}
+	if m, ok := sn.Files[path]; ok {
+		return m
+	}
+	for _, p := range pathForms(path)[1:] {
+		if m, ok := sn.Files[p]; ok {
+			return m
+		}
+	}
+	return nil
+}
+
+// SlashPaths 返回路径全部为 '/' 形式的快照：已经是这种形式时返回 sn 本身，否则返回副本(条目也复制)
+func (sn *SnapshotNode) SlashPaths() *SnapshotNode {
+	if sn == nil || os.PathSeparator == '/' || !sn.hasNativePaths() {
+		return sn
+	}
+	return sn.mapPaths(SlashPath)
+}
+
+// mapPaths 返回按 conv 转换了全部路径(键、Path、ChangedPaths)的快照副本
+func (sn *SnapshotNode) mapPaths(conv func(string) string) *SnapshotNode {
+	c := &SnapshotNode{
+		ID:          sn.ID,
+		Instance:    sn.Instance,
+		ParentIDs:   sn.ParentIDs,
+		CreatedAt:   sn.CreatedAt,
+		Description: sn.Description,
+		Files:       make(map[string]*FileMetadata, len(sn.Files)),
+		RootHash:    sn.RootHash,
+		Config:      sn.Config,
+
+		Truncated: sn.Truncated,
+		spilled:   sn.spilled,
+	}
+	for p, m := range sn.Files {
+		n := *m
+		n.Path = conv(m.Path)
+		c.Files[conv(p)] = &n
+	}
+	if sn.ChangedPaths != nil {
+		c.ChangedPaths = make([]string, len(sn.ChangedPaths))
+		for i, p := range sn.ChangedPaths {
+			c.ChangedPaths[i] = conv(p)
+		}
+	}
+	return c
+}
+
+// hasNativePaths 报告快照中是否有包含本平台分隔符('/' 以外)的路径
+func (sn *SnapshotNode) hasNativePaths() bool {
+	for p := range sn.Files {
+		if strings.ContainsRune(p, os.PathSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+// SlashPaths 返回路径全部为 '/' 形式的差异(原地转换并返回 d)
+func (d *SnapshotDiff) SlashPaths() *SnapshotDiff {
+	if d == nil || os.PathSeparator == '/' {
+		return d
+	}
+	for _, list := range [][]DiffEntry{d.Added, d.Removed, d.Modified, d.Renamed} {
+		for i := range list {
+			e := &list[i]
+			e.Path, e.From = SlashPath(e.Path), SlashPath(e.From)
+			e.Old, e.New = slashMeta(e.Old), slashMeta(e.New)
+		}
+	}
+	return d
+}
+
+// slashMeta 返回 Path 为 '/' 形式的条目，已是这种形式时返回 m 本身
+func slashMeta(m *FileMetadata) *FileMetadata {
+	if m == nil || SlashPath(m.Path) == m.Path {
+		return m
+	}
+	c := *m
+	c.Path = SlashPath(m.Path)
+	return &c
+}
+
+// importSnapshot 把从 Store 读回的快照(导出格式，'/' 形式)转换为本 Watcher 的路径形式
+func (w *Watcher) importSnapshot(sn *SnapshotNode) *SnapshotNode {
+	if !w.cfg.NativePaths || os.PathSeparator == '/' {
+		return sn
+	}
+	return sn.mapPaths(filepath.FromSlash)
+}
@@ -0,0 +1,112 @@
+package watcher
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestSnapshotLookupEitherSeparator 测试 Lookup/FilesUnder 在 Windows 上生成的('/' 形式)快照中接受两种分隔形式
+func TestSnapshotLookupEitherSeparator(t *testing.T) {
+	sn := &SnapshotNode{Files: map[string]*FileMetadata{
+		"C:/data":       {Path: "C:/data", IsDirectory: true},
+		"C:/data/a.txt": {Path: "C:/data/a.txt", Size: 1},
+		"C:/other.txt":  {Path: "C:/other.txt", Size: 2},
+	}}
+	for _, p := range []string{"C:/data/a.txt", `C:\data\a.txt`, `C:\data/a.txt`} {
+		if m := sn.Lookup(p); m == nil || m.Size != 1 {
+			t.Errorf("Lookup(%q) = %+v; want a.txt", p, m)
+		}
+	}
+	if m := sn.Lookup(`C:\data\missing.txt`); m != nil {
+		t.Errorf("Lookup(missing) = %+v; want nil", m)
+	}
+	if got := sn.FilesUnder(`C:\data`); len(got) != 2 || got[0].Path != "C:/data" || got[1].Path != "C:/data/a.txt" {
+		t.Errorf("FilesUnder(`C:\\data`) = %d entries; want C:/data and C:/data/a.txt", len(got))
+	}
+}
+
+// TestEventPathsNormalized 测试混合分隔符、未清理的事件路径在快照与事件中统一为 '/' 形式
+func TestEventPathsNormalized(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	sub := w.Subscribe(16)
+	defer sub.Close()
+
+	want := filepath.ToSlash(filepath.Join(root, "sub", "a.txt"))
+	if r := w.Roots()[0]; r != filepath.ToSlash(root) {
+		t.Fatalf("root = %q; want %q", r, filepath.ToSlash(root))
+	}
+	w.handleFsEvent(fsnotify.Event{Name: root + string(os.PathSeparator) + "sub/./" + "a.txt", Op: fsnotify.Create})
+	w.mergeAgg(<-w.aggChan)
+	w.flushAgg(true)
+	w.workerWG.Wait()
+
+	sn := w.GetCurrentSnapshot()
+	for p, m := range sn.Files {
+		if os.PathSeparator != '/' && strings.ContainsRune(p, os.PathSeparator) {
+			t.Errorf("key %q contains the native separator", p)
+		}
+		if m.Path != p {
+			t.Errorf("Files[%q].Path = %q", p, m.Path)
+		}
+	}
+	if sn.Files[want] == nil || sn.Lookup(filepath.FromSlash(want)) == nil {
+		t.Fatalf("snapshot has no entry for %q: %v", want, sn.Files)
+	}
+	select {
+	case ev := <-sub.C:
+		if ev.FilePath != want || ev.Root != filepath.ToSlash(root) {
+			t.Fatalf("event path = %q root = %q; want %q under %q", ev.FilePath, ev.Root, want, filepath.ToSlash(root))
+		}
+	default:
+		t.Fatal("no event")
+	}
+	if h := w.FileHistory(filepath.FromSlash(want)); len(h) != 1 {
+		t.Fatalf("FileHistory(native form) = %v; want one version", h)
+	}
+}
+
+// TestSnapshotMapPaths 测试路径转换返回副本：键、Path 与 ChangedPaths 都被转换，原快照不变
+func TestSnapshotMapPaths(t *testing.T) {
+	sn := &SnapshotNode{
+		ID:           "s1",
+		Files:        map[string]*FileMetadata{`C:\data\a.txt`: {Path: `C:\data\a.txt`, Size: 3}},
+		ChangedPaths: []string{`C:\data\a.txt`},
+	}
+	c := sn.mapPaths(func(p string) string { return strings.ReplaceAll(p, `\`, "/") })
+	if c == sn || c.ID != "s1" {
+		t.Fatalf("mapPaths returned %p (id %q); want a copy of s1", c, c.ID)
+	}
+	m := c.Files["C:/data/a.txt"]
+	if m == nil || m.Path != "C:/data/a.txt" || m.Size != 3 || c.ChangedPaths[0] != "C:/data/a.txt" {
+		t.Fatalf("converted = %+v, changed %v", c.Files, c.ChangedPaths)
+	}
+	if sn.Files[`C:\data\a.txt`].Path != `C:\data\a.txt` || sn.ChangedPaths[0] != `C:\data\a.txt` {
+		t.Fatal("mapPaths modified the original snapshot")
+	}
+
+	// 导出格式使用 '/' 形式
+	ev := FileEvent{FilePath: filepath.FromSlash("/data/a.txt"), Kind: OpCreate}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"path":"/data/a.txt"`) {
+		t.Fatalf("event JSON = %s; want slash path", data)
+	}
+}
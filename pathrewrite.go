@@ -0,0 +1,173 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 路径改写(ConfigWatcher.PathRewrite)
+//
+// 监控树经由绑定挂载等方式访问、而快照中需要记录另一种(逻辑)路径时，PathRewrite 把磁盘上的原始路径改写为逻辑路径，
+// PathRewriteInverse 把逻辑路径还原为原始路径。Watcher 内部(合并表、快照、事件、持久化、审计)只使用逻辑路径，
+// 改写发生在原始路径进入 Watcher 的唯一边界：
+//   - 监控根(WatchPaths)在 NewWatcher 时改写一次
+//   - EventSource 回报的事件路径先按原始路径匹配忽略规则，再改写(handleFsEvent)
+//   - 扫描(FS.WalkDir)回调中的路径由 FS 包装改写，EventSource.WatchList 同样
+//
+// Watcher 自己访问磁盘时(扫描、哈希、RehashFile、VerifyOnDisk、写入完成检测、监控注册)经过 FS/EventSource 的包装，
+// 参数先用 PathRewriteInverse 还原。忽略规则(IgnorePatterns)总是按原始路径匹配。
+//
+// 看到原始路径的：ConfigWatcher.WatchPaths、IgnorePatterns、注入的 FS/EventSource、文件系统返回的错误(如 *fs.PathError.Path)；
+// 其余 API 的路径参数与返回值(GetCurrentSnapshot、FileEvent、History、RehashFile、MarkSelfWrite、QueryOption、
+// Store/审计日志、清单)都是逻辑路径。
+//
+// 不同原始路径改写为同一逻辑路径(改写不是单射)时无法区分，以往返校验检测：PathRewriteInverse(PathRewrite(p)) 必须还原为 p。
+// 校验失败的监控根使 NewWatcher 返回错误；运行中遇到的路径被跳过(不进入快照)，计入 Stats().PathRewriteConflicts，
+// 并向 ErrorChan 发送 *PathRewriteError
+
+// ErrPathRewriteConflict 表示路径改写不可逆：改写结果经 PathRewriteInverse 不能还原为原路径，通常是多个原始路径改写为同一逻辑路径
+var ErrPathRewriteConflict = errors.New("path rewrite is not reversible")
+
+// PathRewriteError 描述一次往返校验失败的路径改写
+type PathRewriteError struct {
+	Raw     string // 磁盘上的原始路径
+	Logical string // PathRewrite 的结果
+	Inverse string // PathRewriteInverse(Logical) 的结果，与 Raw 不同
+}
+
+// Error 实现 error 接口
+func (e *PathRewriteError) Error() string {
+	return fmt.Sprintf("path rewrite of %s to %s maps back to %s", e.Raw, e.Logical, e.Inverse)
+}
+
+// Unwrap 返回 ErrPathRewriteConflict
+func (e *PathRewriteError) Unwrap() error {
+	return ErrPathRewriteConflict
+}
+
+// rewriting 报告是否配置了路径改写
+func (w *Watcher) rewriting() bool {
+	return w.cfg.PathRewrite != nil
+}
+
+// rewritePath 把原始路径改写为快照中使用的逻辑路径(keyOf 形式)，往返校验失败时返回 *PathRewriteError
+func (w *Watcher) rewritePath(raw string) (string, error) {
+	raw = w.keyOf(raw)
+	if !w.rewriting() || raw == "" {
+		return raw, nil
+	}
+	logical := w.keyOf(w.cfg.PathRewrite(raw))
+	if back := w.keyOf(w.cfg.PathRewriteInverse(logical)); back != raw {
+		return "", &PathRewriteError{Raw: raw, Logical: logical, Inverse: back}
+	}
+	return logical, nil
+}
+
+// ingestPath 同 rewritePath，用于运行中遇到的路径：校验失败时计数并发送错误，返回 false 表示应跳过
+func (w *Watcher) ingestPath(raw string) (string, bool) {
+	p, err := w.rewritePath(raw)
+	if err != nil {
+		w.counters.rewriteConflicts.Add(1)
+		if w.emitError(err) {
+			w.logWarn("Path rewrite conflict", err)
+		}
+		return "", false
+	}
+	return p, true
+}
+
+// diskPath 把逻辑路径还原为磁盘上的原始路径，未配置改写时原样返回
+func (w *Watcher) diskPath(p string) string {
+	if !w.rewriting() || p == "" {
+		return p
+	}
+	return w.cfg.PathRewriteInverse(p)
+}
+
+// baseFS 返回未经改写包装的 FS
+func (w *Watcher) baseFS() FS {
+	if r, ok := w.fs.(rewriteFS); ok {
+		return r.fs
+	}
+	return w.fs
+}
+
+// rewriteFS 包装 FS：参数为逻辑路径，访问前还原为原始路径；WalkDir 回调中的路径改写为逻辑路径，
+// 往返校验失败的路径被跳过(目录连同其下内容)
+type rewriteFS struct {
+	fs FS
+	w  *Watcher
+}
+
+func (r rewriteFS) Stat(name string) (fs.FileInfo, error)   { return r.fs.Stat(r.w.diskPath(name)) }
+func (r rewriteFS) Open(name string) (io.ReadCloser, error) { return r.fs.Open(r.w.diskPath(name)) }
+func (r rewriteFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return r.fs.ReadDir(r.w.diskPath(name))
+}
+
+func (r rewriteFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return r.fs.WalkDir(r.w.diskPath(root), func(path string, d fs.DirEntry, err error) error {
+		p, ok := r.w.ingestPath(path)
+		if !ok {
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		return fn(p, d, err)
+	})
+}
+
+// rewriteSource 包装 EventSource：注册与取消监控时还原为原始路径，WatchList 改写为逻辑路径；
+// 事件路径在 handleFsEvent 中匹配忽略规则之后改写
+type rewriteSource struct {
+	EventSource
+	w *Watcher
+}
+
+func (r rewriteSource) Add(name string) error    { return r.EventSource.Add(r.w.diskPath(name)) }
+func (r rewriteSource) Remove(name string) error { return r.EventSource.Remove(r.w.diskPath(name)) }
+
+func (r rewriteSource) WatchList() []string {
+	raw := r.EventSource.WatchList()
+	list := make([]string, 0, len(raw))
+	for _, p := range raw {
+		if l, err := r.w.rewritePath(p); err == nil {
+			list = append(list, l)
+		}
+	}
+	return list
+}
+
+// rewriteEvent 把事件路径改写为逻辑路径，ok 为 false 表示应丢弃
+func (w *Watcher) rewriteEvent(ev *fsnotify.Event) (ok bool) {
+	if !w.rewriting() {
+		return true
+	}
+	ev.Name, ok = w.ingestPath(ev.Name)
+	return ok
+}
+
+// replacePrefix 返回把 from 下的路径替换为 to 下对应路径的函数，结果保持参数的分隔符形式
+func replacePrefix(from, to string) func(string) string {
+	from, to = filepath.ToSlash(filepath.Clean(from)), filepath.ToSlash(filepath.Clean(to))
+	return func(p string) string {
+		s := filepath.ToSlash(p)
+		if !withinRoot(s, from) {
+			return p
+		}
+		r := path.Join(to, s[len(from):])
+		if strings.ContainsRune(p, os.PathSeparator) && os.PathSeparator != '/' {
+			return filepath.FromSlash(r)
+		}
+		return r
+	}
+}
@@ -0,0 +1,87 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestPathRewrite 测试原始路径在进入快照前改写为逻辑路径，忽略规则按原始路径匹配，访问磁盘时还原为原始路径
+func TestPathRewrite(t *testing.T) {
+	raw := filepath.Join(t.TempDir(), "mnt", "data")
+	_ = os.MkdirAll(filepath.Join(raw, "sub"), 0755)
+	_ = os.WriteFile(filepath.Join(raw, "sub", "a.txt"), []byte("hello"), 0644)
+	_ = os.WriteFile(filepath.Join(raw, "skip.txt"), []byte("skip"), 0644)
+	w, err := NewWatcherWithOptions([]string{raw}, WithFS(osFS{}, replaySource{}), WithDisableEventChan(),
+		WithPathPrefixRewrite(raw, "/srv/data"), WithIgnorePatterns("**/mnt/data/skip.txt"))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	if roots := w.Roots(); len(roots) != 1 || roots[0] != "/srv/data" {
+		t.Fatalf("Roots() = %v; want [/srv/data]", roots)
+	}
+
+	// 事件：原始路径匹配忽略规则，之后改写
+	w.handleFsEvent(fsnotify.Event{Name: filepath.Join(raw, "skip.txt"), Op: fsnotify.Create})
+	w.handleFsEvent(fsnotify.Event{Name: filepath.Join(raw, "sub", "a.txt"), Op: fsnotify.Create})
+	if n := len(w.aggChan); n != 1 {
+		t.Fatalf("%d events queued; want 1 (skip.txt is ignored by its raw path)", n)
+	}
+	ev := <-w.aggChan
+	if ev.Name != "/srv/data/sub/a.txt" {
+		t.Fatalf("queued %q; want /srv/data/sub/a.txt", ev.Name)
+	}
+	w.handleFileChange(ev.Name, ev.Op)
+	m := w.GetCurrentSnapshot().Lookup("/srv/data/sub/a.txt")
+	if m == nil || m.Size != 5 || m.Hash == "" {
+		t.Fatalf("entry = %+v; want a hashed 5-byte file at the logical path", m)
+	}
+	for p := range w.GetCurrentSnapshot().Files {
+		if strings.Contains(p, "mnt") {
+			t.Fatalf("snapshot contains raw path %q", p)
+		}
+	}
+	if _, _, err := w.RehashFile("/srv/data/sub/a.txt"); err != nil {
+		t.Fatalf("RehashFile(logical path) failed: %v", err)
+	}
+
+	// 扫描：WalkDir 回调中的路径同样改写
+	entries, _ := w.collectEntries(w.roots, nil)
+	if _, ok := entries["/srv/data/sub/a.txt"]; !ok || len(entries) != 3 {
+		t.Fatalf("scan found %d entries %v; want root, sub and sub/a.txt at logical paths", len(entries), entries)
+	}
+}
+
+// TestPathRewriteConflict 测试不可逆的改写：监控根冲突时 NewWatcher 失败，运行中的路径被跳过并报告
+func TestPathRewriteConflict(t *testing.T) {
+	raw := t.TempDir()
+	// 去掉 .orig 后缀后与同名文件冲突
+	rewrite := func(p string) string { return strings.TrimSuffix(replacePrefix(raw, "/srv")(p), ".orig") }
+	inverse := replacePrefix("/srv", raw)
+
+	_, err := NewWatcherWithOptions([]string{filepath.Join(raw, "a"), filepath.Join(raw, "a.orig")},
+		WithFS(osFS{}, replaySource{}), WithPathRewrite(rewrite, inverse))
+	var pe *PathRewriteError
+	if !errors.Is(err, ErrInvalidConfig) || !errors.As(err, &pe) || !errors.Is(err, ErrPathRewriteConflict) {
+		t.Fatalf("NewWatcher error = %v; want a path rewrite conflict", err)
+	}
+
+	w, err := NewWatcherWithOptions([]string{raw}, WithFS(osFS{}, replaySource{}), WithDisableEventChan(),
+		WithPathRewrite(rewrite, inverse))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	w.handleFsEvent(fsnotify.Event{Name: filepath.Join(raw, "x.orig"), Op: fsnotify.Create})
+	if n := len(w.aggChan); n != 0 || w.Stats().PathRewriteConflicts != 1 {
+		t.Fatalf("%d events queued, %d conflicts; want the conflicting path skipped and counted", n, w.Stats().PathRewriteConflicts)
+	}
+	if err := <-w.ErrorChan; !errors.As(err, &pe) || pe.Logical != "/srv/x" || pe.Inverse != filepath.ToSlash(filepath.Join(raw, "x")) {
+		t.Fatalf("ErrorChan = %v; want *PathRewriteError for x.orig", err)
+	}
+}
@@ -0,0 +1,384 @@
+package watcher
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"slices"
+	"sync/atomic"
+)
+
+// 路径前缀树
+//
+// 快照的文件表是以完整路径为键的扁平 map，"某个目录下的全部条目"只能逐个比较全部键。
+// 前缀树按路径的各级名称('/' 与本平台的分隔符都视为分隔符)组织快照中未删除的条目(墓碑不在其中)，
+// 每个节点记录子树中的条目数，使子树的查找、计数与摘除只与路径深度(以及命中的条目数)有关。
+//
+// 前缀树发布后不可变：修改通过 trieEdit 进行，只复制从根到被修改节点的路径，其余节点与原树共享；
+// 同一次编辑中已复制过的节点(gen 相同)原地修改，一批变更只复制每个受影响的节点一次。
+// 子节点表(kidTable)同样是持久化的，修改只复制几层很小的表，与目录中的子项数无关。
+// 快照的前缀树按需构建(见 SnapshotNode.pathTrie)；父快照已有前缀树时，提交直接在其上应用变更(见 deriveTrie)，
+// 未变化的子树与父快照共享。扁平的 Files 保持不变
+
+// trieGen 为每次编辑分配不同的批次号
+var trieGen atomic.Uint64
+
+// trieNode 是前缀树的一个节点，对应路径的一级
+type trieNode struct {
+	path string    // 该节点对应的条目路径，只是中间一级(不是条目)时为空
+	n    int       // 子树(含自身)中的条目数
+	kids *kidTable // 下一级名称 -> 子节点
+	gen  uint64    // 创建该节点的编辑批次
+}
+
+// nextName 返回 path 中从 i 开始的一级名称，以及下一级的起始位置；末尾的分隔符被忽略
+func nextName(path string, i int) (string, int) {
+	j := i
+	for j < len(path) && !isSep(path[j]) {
+		j++
+	}
+	return path[i:j], j + 1
+}
+
+// find 返回 path 对应的节点，不存在时返回nil；t 可以为nil
+func (t *trieNode) find(path string) *trieNode {
+	cur := t
+	for i := 0; cur != nil && i < len(path); {
+		var name string
+		name, i = nextName(path, i)
+		cur = cur.kids.get(name)
+	}
+	return cur
+}
+
+// count 返回子树中的条目数，t 可以为nil
+func (t *trieNode) count() int {
+	if t == nil {
+		return 0
+	}
+	return t.n
+}
+
+// each 以子树中每个条目的路径调用 fn(顺序不定)，t 可以为nil
+func (t *trieNode) each(fn func(path string)) {
+	if t == nil {
+		return
+	}
+	if t.path != "" {
+		fn(t.path)
+	}
+	t.kids.each(func(c *trieNode) { c.each(fn) })
+}
+
+// trieEdit 是对一棵前缀树的一批修改，done 之后不得再使用
+type trieEdit struct {
+	root *trieNode
+	gen  uint64
+}
+
+// edit 开始一批修改，t 本身不受影响；t 为nil时从空树开始
+func (t *trieNode) edit() *trieEdit {
+	if t == nil {
+		t = &trieNode{}
+	}
+	return &trieEdit{root: t, gen: trieGen.Add(1)}
+}
+
+// own 返回可以原地修改的 n：本批次创建的节点直接返回，否则复制一份
+func (e *trieEdit) own(n *trieNode) *trieNode {
+	if n.gen == e.gen {
+		return n
+	}
+	c := *n
+	c.gen = e.gen
+	return &c
+}
+
+// insert 加入条目 path，已存在时不变
+func (e *trieEdit) insert(path string) {
+	if n := e.root.find(path); n != nil && n.path != "" {
+		return
+	}
+	e.root = e.own(e.root)
+	cur := e.root
+	cur.n++
+	for i := 0; i < len(path); {
+		var name string
+		name, i = nextName(path, i)
+		child := cur.kids.get(name)
+		if child == nil {
+			child = &trieNode{gen: e.gen}
+		} else {
+			child = e.own(child)
+		}
+		cur.kids = cur.kids.set(name, hashName(name), 0, child, e.gen)
+		child.n++
+		cur = child
+	}
+	cur.path = path
+}
+
+// remove 移除条目 path 本身(其下的条目保留)，不存在时不变
+func (e *trieEdit) remove(path string) {
+	if n := e.root.find(path); n != nil && n.path != "" {
+		e.drop(path, 1)
+	}
+}
+
+// removeTree 移除 path 及其下的全部条目，只与路径深度有关
+func (e *trieEdit) removeTree(path string) {
+	if n := e.root.find(path); n.count() > 0 {
+		e.drop(path, n.n)
+	}
+}
+
+// drop 沿 path 把各级的条目数减去 k：某一级的子树因此变空时整个摘下，否则清除 path 本身的条目
+//
+// k 为1时只移除 path 本身，为 path 子树的条目数时移除整个子树；调用方保证 path 存在
+func (e *trieEdit) drop(path string, k int) {
+	e.root = e.own(e.root)
+	cur := e.root
+	cur.n -= k
+	for i := 0; i < len(path); {
+		var name string
+		name, i = nextName(path, i)
+		child := cur.kids.get(name)
+		if child.n == k {
+			cur.kids = cur.kids.del(name, hashName(name), 0, e.gen)
+			return
+		}
+		child = e.own(child)
+		cur.kids = cur.kids.set(name, hashName(name), 0, child, e.gen)
+		child.n -= k
+		cur = child
+	}
+	cur.path = ""
+}
+
+// done 结束修改并返回新的前缀树
+func (e *trieEdit) done() *trieNode {
+	t := e.root
+	e.root = nil
+	return t
+}
+
+// kidSeed 是子节点表的哈希种子
+var kidSeed = maphash.MakeSeed()
+
+// kidBits 是子节点表每一层使用的哈希位数，每层最多 1<<kidBits 个槽位
+const kidBits = 5
+
+// hashName 返回一级名称的哈希
+func hashName(name string) uint64 {
+	return maphash.String(kidSeed, name)
+}
+
+// kidTable 是持久化的子节点表(按名称哈希逐层分支的 HAMT)
+//
+// 每一层用哈希的 kidBits 位选择槽位，槽位中是一个子节点或下一层的表；64 位哈希用尽(完全相同)时
+// 最后一层按顺序保存全部冲突的名称。修改只复制从顶层到所在槽位的各层，同一次编辑(gen 相同)创建的表原地修改。
+// nil 表示空表
+type kidTable struct {
+	bitmap uint32 // 已占用的槽位
+	slots  []kidSlot
+	gen    uint64
+}
+
+// kidSlot 是子节点表的一个槽位：sub 不为nil时是下一层的表，否则是名称为 name 的子节点
+type kidSlot struct {
+	name string
+	node *trieNode
+	sub  *kidTable
+}
+
+// slot 返回哈希 h 在 shift 所在层对应的槽位下标，以及该槽位是否已占用
+func (t *kidTable) slot(h uint64, shift uint) (int, uint32, bool) {
+	bit := uint32(1) << (h >> shift & (1<<kidBits - 1))
+	return bits.OnesCount32(t.bitmap & (bit - 1)), bit, t.bitmap&bit != 0
+}
+
+// get 返回名称为 name 的子节点，不存在时返回nil
+func (t *kidTable) get(name string) *trieNode {
+	h := hashName(name)
+	for shift := uint(0); t != nil; shift += kidBits {
+		if shift >= 64 {
+			for _, s := range t.slots {
+				if s.name == name {
+					return s.node
+				}
+			}
+			return nil
+		}
+		i, _, ok := t.slot(h, shift)
+		if !ok {
+			return nil
+		}
+		s := t.slots[i]
+		if s.sub == nil {
+			if s.name == name {
+				return s.node
+			}
+			return nil
+		}
+		t = s.sub
+	}
+	return nil
+}
+
+// each 以每个子节点调用 fn(顺序不定)
+func (t *kidTable) each(fn func(*trieNode)) {
+	if t == nil {
+		return
+	}
+	for _, s := range t.slots {
+		if s.sub != nil {
+			s.sub.each(fn)
+		} else {
+			fn(s.node)
+		}
+	}
+}
+
+// own 返回可以原地修改的 t：本批次创建的表直接返回，否则复制一份；t 为nil时返回新的空表
+func (t *kidTable) own(gen uint64) *kidTable {
+	if t == nil {
+		return &kidTable{gen: gen}
+	}
+	if t.gen == gen {
+		return t
+	}
+	return &kidTable{bitmap: t.bitmap, slots: slices.Clone(t.slots), gen: gen}
+}
+
+// set 返回把 name(哈希为 h，位于 shift 所在层)设为 node 之后的表
+func (t *kidTable) set(name string, h uint64, shift uint, node *trieNode, gen uint64) *kidTable {
+	t = t.own(gen)
+	if shift >= 64 {
+		for i := range t.slots {
+			if t.slots[i].name == name {
+				t.slots[i].node = node
+				return t
+			}
+		}
+		t.slots = append(t.slots, kidSlot{name: name, node: node})
+		return t
+	}
+	i, bit, ok := t.slot(h, shift)
+	if !ok {
+		t.slots = slices.Insert(t.slots, i, kidSlot{name: name, node: node})
+		t.bitmap |= bit
+		return t
+	}
+	s := &t.slots[i]
+	switch {
+	case s.sub != nil:
+		s.sub = s.sub.set(name, h, shift+kidBits, node, gen)
+	case s.name == name:
+		s.node = node
+	default:
+		// 两个名称在这一层落在同一个槽位，一起下沉到下一层
+		sub := (*kidTable)(nil).set(s.name, hashName(s.name), shift+kidBits, s.node, gen)
+		*s = kidSlot{sub: sub.set(name, h, shift+kidBits, node, gen)}
+	}
+	return t
+}
+
+// del 返回删除 name(哈希为 h，位于 shift 所在层)之后的表，表变空时返回nil；调用方保证 name 存在
+func (t *kidTable) del(name string, h uint64, shift uint, gen uint64) *kidTable {
+	if shift >= 64 {
+		i := slices.IndexFunc(t.slots, func(s kidSlot) bool { return s.name == name })
+		t = t.own(gen)
+		t.slots = slices.Delete(t.slots, i, i+1)
+		return t.orNil()
+	}
+	i, bit, _ := t.slot(h, shift)
+	sub := t.slots[i].sub
+	t = t.own(gen)
+	if sub == nil {
+		t.slots = slices.Delete(t.slots, i, i+1)
+		t.bitmap &^= bit
+		return t.orNil()
+	}
+	switch sub = sub.del(name, h, shift+kidBits, gen); {
+	case sub == nil:
+		t.slots = slices.Delete(t.slots, i, i+1)
+		t.bitmap &^= bit
+	case len(sub.slots) == 1 && sub.slots[0].sub == nil:
+		// 下一层只剩一个子节点，提升到这一层
+		t.slots[i] = sub.slots[0]
+	default:
+		t.slots[i].sub = sub
+	}
+	return t.orNil()
+}
+
+// orNil 把空表换成nil
+func (t *kidTable) orNil() *kidTable {
+	if len(t.slots) == 0 {
+		return nil
+	}
+	return t
+}
+
+// buildTrie 为文件表中未删除的条目构建前缀树
+func buildTrie(files map[string]*FileMetadata) *trieNode {
+	e := (*trieNode)(nil).edit()
+	for p, m := range files {
+		if !m.Deleted {
+			e.insert(p)
+		}
+	}
+	return e.done()
+}
+
+// deriveTrie 在父快照的前缀树 parent 上应用一次提交的 changes，得到其结果 files 的前缀树
+//
+// 被删除路径只检查其在 parent 中的子树，新增或更新的路径逐个加入；其余部分与 parent 共享
+func deriveTrie(parent *trieNode, changes, files map[string]*FileMetadata) *trieNode {
+	e := parent.edit()
+	for p, meta := range changes {
+		if meta != nil {
+			continue
+		}
+		// 删除可能因为路径已是墓碑而被跳过，子树中也可能有本批次重新创建的条目，以结果为准
+		parent.find(p).each(func(k string) {
+			if live(files[k]) == nil {
+				e.remove(k)
+			}
+		})
+	}
+	for p, meta := range changes {
+		if meta != nil && live(files[p]) != nil {
+			e.insert(p)
+		}
+	}
+	return e.done()
+}
+
+// pathTrie 按需构建并缓存快照的前缀树，提交时已从父快照派生的直接返回
+//
+// 快照发布后不再修改，因此前缀树只需构建一次
+func (sn *SnapshotNode) pathTrie() *trieNode {
+	if t := sn.trie.Load(); t != nil {
+		return t
+	}
+	sn.trieOnce.Do(func() {
+		sn.trie.Store(buildTrie(sn.Files))
+	})
+	return sn.trie.Load()
+}
+
+// eachLiveUnder 以 sn 中 root 及其下每个未删除的条目调用 fn，sn 是 readCurrentNode 给出的当前状态
+//
+// 已发布的快照按前缀树只访问 root 的子树；DisableSnapshots 的当前状态与尚未发布(没有ID)的快照会被原地修改，
+// 没有可缓存的前缀树，逐个比较
+func (w *Watcher) eachLiveUnder(sn *SnapshotNode, root string, fn func(p string, m *FileMetadata)) {
+	if w.cfg.DisableSnapshots || sn.ID == "" {
+		for p, m := range sn.Files {
+			if !m.Deleted && withinRoot(p, root) {
+				fn(p, m)
+			}
+		}
+		return
+	}
+	sn.pathTrie().find(root).each(func(p string) { fn(p, sn.Files[p]) })
+}
@@ -0,0 +1,217 @@
+package watcher
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// triePaths 返回前缀树子树中的条目路径(有序)
+func triePaths(t *trieNode) []string {
+	var out []string
+	t.each(func(p string) { out = append(out, p) })
+	sort.Strings(out)
+	return out
+}
+
+// livePaths 返回文件表中未删除条目的路径(有序)
+func livePaths(files map[string]*FileMetadata) []string {
+	var out []string
+	for p, m := range files {
+		if !m.Deleted {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// TestPathTrie 测试随机增删后派生的前缀树与重新构建的一致，原树不受影响，未变化的子树被共享
+func TestPathTrie(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	files := make(map[string]*FileMetadata)
+	randPath := func() string {
+		parts := []string{""}
+		for d := rng.Intn(4); d >= 0; d-- {
+			parts = append(parts, fmt.Sprintf("d%d", rng.Intn(3)))
+		}
+		return strings.Join(parts, "/")
+	}
+	for i := 0; i < 200; i++ {
+		files[randPath()] = &FileMetadata{}
+	}
+	files["/d0/gone"] = &FileMetadata{Deleted: true}
+	cur := buildTrie(files)
+	if got, want := fmt.Sprint(triePaths(cur)), fmt.Sprint(livePaths(files)); got != want {
+		t.Fatalf("buildTrie = %s; want %s", got, want)
+	}
+
+	for round := 0; round < 50; round++ {
+		before := fmt.Sprint(triePaths(cur))
+		next := make(map[string]*FileMetadata, len(files))
+		for p, m := range files {
+			next[p] = m
+		}
+		// 与 applyChangesLocked 相同：先删除子树，再写入新增与更新
+		changes := make(map[string]*FileMetadata)
+		for i := rng.Intn(5); i >= 0; i-- {
+			if rng.Intn(2) == 0 {
+				changes[randPath()] = nil
+			} else {
+				changes[randPath()] = &FileMetadata{}
+			}
+		}
+		for p, m := range changes {
+			if m == nil {
+				for k := range next {
+					if withinRoot(k, p) {
+						delete(next, k)
+					}
+				}
+			}
+		}
+		for p, m := range changes {
+			if m != nil {
+				next[p] = m
+			}
+		}
+
+		derived := deriveTrie(cur, changes, next)
+		if got, want := fmt.Sprint(triePaths(derived)), fmt.Sprint(livePaths(next)); got != want {
+			t.Fatalf("round %d: derived = %s; want %s", round, got, want)
+		}
+		if derived.count() != len(livePaths(next)) {
+			t.Fatalf("round %d: count = %d; want %d", round, derived.count(), len(livePaths(next)))
+		}
+		if after := fmt.Sprint(triePaths(cur)); after != before {
+			t.Fatalf("round %d: deriving modified the parent trie", round)
+		}
+		files, cur = next, derived
+	}
+
+	// 子项很多的目录：子节点表分层后增删仍然正确，原表不受影响
+	wide := make(map[string]*FileMetadata)
+	for i := 0; i < 5000; i++ {
+		wide[fmt.Sprintf("/w/f%d", i)] = &FileMetadata{}
+	}
+	full := buildTrie(wide)
+	e := full.edit()
+	for i := 0; i < 5000; i += 2 {
+		e.remove(fmt.Sprintf("/w/f%d", i))
+	}
+	half := e.done()
+	if full.find("/w").count() != 5000 || half.find("/w").count() != 2500 {
+		t.Fatalf("counts = %d, %d; want 5000, 2500", full.find("/w").count(), half.find("/w").count())
+	}
+	for i := 0; i < 5000; i++ {
+		p := fmt.Sprintf("/w/f%d", i)
+		if full.find(p) == nil || (half.find(p) != nil) != (i%2 == 1) {
+			t.Fatalf("%s: in full = %v, in half = %v", p, full.find(p) != nil, half.find(p) != nil)
+		}
+	}
+	if n := len(triePaths(half)); n != 2500 {
+		t.Errorf("half has %d entries; want 2500", n)
+	}
+
+	// 只修改 /a 下的条目时 /b 的子树原样共享
+	base := buildTrie(map[string]*FileMetadata{"/a/x": {}, "/b/y": {}, "/b/z": {}})
+	e = base.edit()
+	e.insert("/a/w")
+	e.removeTree("/a/x")
+	next := e.done()
+	if next.find("/b") != base.find("/b") {
+		t.Error("the untouched /b subtree should be shared with the parent")
+	}
+	if got := triePaths(next); fmt.Sprint(got) != "[/a/w /b/y /b/z]" || base.find("/a/w") != nil {
+		t.Errorf("next = %v; the parent must not see /a/w", got)
+	}
+}
+
+// TestPathTrieCommit 测试父快照已有前缀树时提交直接派生新快照的前缀树，查询结果与文件表一致
+func TestPathTrieCommit(t *testing.T) {
+	root := t.TempDir()
+	w := manifestWatcher(t, root, map[string]string{"keep/a.txt": "a", "keep/b.txt": "b", "gone/c.txt": "c", "gone/sub/d.txt": "d"})
+	parent := w.GetCurrentSnapshot()
+	if got, err := w.FilesUnder(parent.ID, filepath.Join(root, "gone")); err != nil || len(got) != 4 {
+		t.Fatalf("FilesUnder(gone) = %d entries, %v; want 4", len(got), err)
+	}
+
+	_ = os.RemoveAll(filepath.Join(root, "gone"))
+	w.handleFileChange(w.keyOf(filepath.Join(root, "gone")), fsnotify.Remove)
+	_ = os.WriteFile(filepath.Join(root, "new.txt"), []byte("n"), 0644)
+	w.handleFileChange(w.keyOf(filepath.Join(root, "new.txt")), fsnotify.Create)
+
+	cur := w.GetCurrentSnapshot()
+	derived := cur.trie.Load()
+	if derived == nil {
+		t.Fatal("the new snapshot's trie should be derived at commit time")
+	}
+	if got, want := fmt.Sprint(triePaths(derived)), fmt.Sprint(livePaths(cur.Files)); got != want {
+		t.Errorf("derived trie = %s; want %s", got, want)
+	}
+	keep := w.keyOf(filepath.Join(root, "keep"))
+	if derived.find(keep) != parent.pathTrie().find(keep) {
+		t.Error("the unchanged keep/ subtree should be shared with the parent snapshot")
+	}
+	if got, _ := w.FilesUnder(cur.ID, filepath.Join(root, "gone")); len(got) != 0 {
+		t.Errorf("FilesUnder(gone) = %d entries after removal; want 0", len(got))
+	}
+	counts, err := w.SnapshotRoots(cur.ID)
+	if err != nil || counts[w.Roots()[0]] != len(cur.Files) {
+		t.Errorf("SnapshotRoots = %v, %v; want %d under the root", counts, err, len(cur.Files))
+	}
+}
+
+// BenchmarkSubtreeDelete 比较在 1M 条目的快照中删除一个 100 条目的子树：逐个比较全部路径与按前缀树只访问子树
+func BenchmarkSubtreeDelete(b *testing.B) {
+	files := make(map[string]*FileMetadata, 1_000_000)
+	for d := 0; d < 10_000; d++ {
+		for i := 0; i < 100; i++ {
+			p := fmt.Sprintf("/r/d%05d/f%03d", d, i)
+			files[p] = &FileMetadata{Path: p}
+		}
+	}
+	const target = "/r/d04242"
+	sub := make(map[string]*FileMetadata)
+	for p, m := range files {
+		if withinRoot(p, target) {
+			sub[p] = m
+		}
+	}
+	// 每轮删除后把子树放回，文件表保持 1M 条目
+	restore := func() {
+		for p, m := range sub {
+			files[p] = m
+		}
+	}
+
+	b.Run("Scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for p := range files {
+				if withinRoot(p, target) {
+					delete(files, p)
+				}
+			}
+			restore()
+		}
+	})
+	b.Run("Trie", func(b *testing.B) {
+		t := buildTrie(files)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			e := t.edit()
+			e.root.find(target).each(func(p string) { delete(files, p) })
+			e.removeTree(target)
+			if e.done().count() != len(files) {
+				b.Fatal("trie and files disagree")
+			}
+			restore()
+		}
+	})
+}
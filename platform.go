@@ -0,0 +1,57 @@
+package watcher
+
+import "slices"
+
+// PlatformPreset 是按运行平台自动应用的默认配置，可通过 PlatformDefaults 查看，
+// 通过 ConfigWatcher.DisablePlatformDefaults(WithoutPlatformDefaults)整体关闭
+//
+// IgnorePatterns 排在用户的 IgnorePatterns 之前；IgnoreChmod 为 true 时开启 ConfigWatcher.IgnoreChmod
+type PlatformPreset struct {
+	Name           string   // 平台名(runtime.GOOS)，没有预设的平台为空
+	IgnorePatterns []string // 平台自身产生、通常不需要关心的文件与目录
+	IgnoreChmod    bool     // 丢弃只有 Chmod 的事件(平台频繁改写元信息时)
+}
+
+// darwinPreset 是 macOS 的默认配置(放在不带构建标签的文件中，便于在其他平台上测试)
+//
+// Finder、Spotlight、Time Machine 与 FSEvents 会在卷与目录中持续写入自己的文件，
+// 并频繁改写扩展属性与时间戳(只产生 Chmod 事件)；这些都不是用户内容的变化
+var darwinPreset = PlatformPreset{
+	Name: "darwin",
+	IgnorePatterns: []string{
+		"**/.DS_Store",
+		"**/._*", // AppleDouble 资源分叉
+		"**/.Spotlight-V100/**",
+		"**/.fseventsd/**",
+		"**/.TemporaryItems/**",
+		"**/.Trashes/**",
+		"**/.DocumentRevisions-V100/**",
+		"**/.MobileBackups/**",
+		"**/.com.apple.timemachine.donotpresent",
+		"**/.VolumeIcon.icns",
+		"**/.AppleDouble/**",
+		"**/.AppleDB/**",
+		"**/.AppleDesktop/**",
+	},
+	IgnoreChmod: true,
+}
+
+// PlatformDefaults 返回当前平台的默认配置(副本)，没有预设的平台返回零值
+func PlatformDefaults() PlatformPreset {
+	p := platformPreset
+	p.IgnorePatterns = slices.Clone(p.IgnorePatterns)
+	return p
+}
+
+// applyPlatformPreset 把预设合并到 cfg 中，cfg.DisablePlatformDefaults 时不做任何事
+func applyPlatformPreset(cfg *ConfigWatcher, p PlatformPreset) {
+	if cfg.DisablePlatformDefaults {
+		return
+	}
+	if len(p.IgnorePatterns) > 0 {
+		cfg.IgnorePatterns = append(slices.Clone(p.IgnorePatterns), cfg.IgnorePatterns...)
+	}
+	if p.IgnoreChmod {
+		cfg.IgnoreChmod = true
+	}
+}
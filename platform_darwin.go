@@ -0,0 +1,5 @@
+//go:build darwin
+
+package watcher
+
+var platformPreset = darwinPreset
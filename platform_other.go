@@ -0,0 +1,6 @@
+//go:build !darwin
+
+package watcher
+
+// platformPreset 在没有预设的平台上为空
+var platformPreset = PlatformPreset{}
@@ -0,0 +1,77 @@
+package watcher
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestApplyPlatformPreset 测试平台预设排在用户忽略规则之前、开启 IgnoreChmod，以及关闭预设
+func TestApplyPlatformPreset(t *testing.T) {
+	p := PlatformPreset{Name: "test", IgnorePatterns: []string{"**/.noise"}, IgnoreChmod: true}
+
+	cfg := ConfigWatcher{IgnorePatterns: []string{"*.tmp"}}
+	applyPlatformPreset(&cfg, p)
+	if want := []string{"**/.noise", "*.tmp"}; !slices.Equal(cfg.IgnorePatterns, want) || !cfg.IgnoreChmod {
+		t.Errorf("applied cfg: patterns=%v chmod=%v; want %v true", cfg.IgnorePatterns, cfg.IgnoreChmod, want)
+	}
+	cfg.IgnorePatterns[0] = "changed"
+	if p.IgnorePatterns[0] != "**/.noise" {
+		t.Error("applyPlatformPreset shares the preset's slice")
+	}
+
+	cfg = ConfigWatcher{IgnorePatterns: []string{"*.tmp"}, DisablePlatformDefaults: true}
+	applyPlatformPreset(&cfg, p)
+	if !slices.Equal(cfg.IgnorePatterns, []string{"*.tmp"}) || cfg.IgnoreChmod {
+		t.Errorf("opted-out cfg: patterns=%v chmod=%v", cfg.IgnorePatterns, cfg.IgnoreChmod)
+	}
+
+	d := PlatformDefaults()
+	d.IgnorePatterns = append(d.IgnorePatterns, "x")
+	if len(PlatformDefaults().IgnorePatterns) == len(d.IgnorePatterns) {
+		t.Error("PlatformDefaults returns a shared slice")
+	}
+}
+
+// TestDarwinPresetPatterns 测试 macOS 预设的忽略规则匹配系统文件而不误伤普通文件
+func TestDarwinPresetPatterns(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithoutPlatformDefaults(), WithIgnorePatterns(darwinPreset.IgnorePatterns...))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+
+	for _, rel := range []string{".DS_Store", "a/b/.DS_Store", "._photo.jpg", ".Spotlight-V100", ".Spotlight-V100/Store-V2/x", ".fseventsd/0001", ".TemporaryItems/t"} {
+		if !w.isIgnored(filepath.Join(root, rel)) {
+			t.Errorf("%s not ignored", rel)
+		}
+	}
+	for _, rel := range []string{"DS_Store.txt", "a/photo.jpg", "notes/.Spotlight-V100.md"} {
+		if w.isIgnored(filepath.Join(root, rel)) {
+			t.Errorf("%s ignored", rel)
+		}
+	}
+}
+
+// TestIgnoreChmod 测试 IgnoreChmod 只丢弃只有 Chmod 的事件
+func TestIgnoreChmod(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithIgnoreChmod())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+
+	file := filepath.Join(root, "a.txt")
+	w.handleFsEvent(fsnotify.Event{Name: file, Op: fsnotify.Chmod})
+	if n := len(w.aggChan); n != 0 || w.counters.eventsIgnored.Load() != 1 {
+		t.Errorf("Chmod event queued=%d ignored=%d; want dropped", n, w.counters.eventsIgnored.Load())
+	}
+	w.handleFsEvent(fsnotify.Event{Name: file, Op: fsnotify.Write | fsnotify.Chmod})
+	if n := len(w.aggChan); n != 1 {
+		t.Errorf("Write|Chmod event queued=%d; want 1", n)
+	}
+}
@@ -0,0 +1,181 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// sortedPaths 按需构建并缓存快照中全部条目的有序路径
+//
+// 快照发布后不再修改，因此只需构建一次；之后的前缀查询通过二分查找定位
+func (sn *SnapshotNode) sortedPaths() []string {
+	sn.pathsOnce.Do(func() {
+		paths := make([]string, 0, len(sn.Files))
+		for p := range sn.Files {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		sn.paths = paths
+	})
+	return sn.paths
+}
+
+// withPrefix 返回有序路径中以 prefix 开头(按字符串)的区间
+func withPrefix(paths []string, prefix string) []string {
+	i := sort.SearchStrings(paths, prefix)
+	j := i
+	for j < len(paths) && strings.HasPrefix(paths[j], prefix) {
+		j++
+	}
+	return paths[i:j]
+}
+
+// FilesUnder 返回 prefix 本身及其下所有条目，按路径排序
+//
+// prefix 按 filepath.Clean 规范化后与快照中的路径比较(形式需与 WatchPaths 一致，分隔符可以是 '/' 或 '\'，
+// 见 SnapshotNode.Lookup)；为空时返回全部条目。墓碑(Deleted)不包含在内
+func (sn *SnapshotNode) FilesUnder(prefix string) []*FileMetadata {
+	return sn.filesUnder(prefix, false)
+}
+
+// filesUnder 是 FilesUnder 的实现，deleted 时包含墓碑
+//
+// 不含墓碑时按前缀树只访问 prefix 的子树；前缀树不含墓碑，deleted 时在有序路径上二分查找
+func (sn *SnapshotNode) filesUnder(prefix string, deleted bool) []*FileMetadata {
+	if prefix != "" && !deleted {
+		var node *trieNode
+		for _, f := range pathForms(filepath.Clean(prefix)) {
+			if node = sn.pathTrie().find(f); node != nil {
+				break
+			}
+		}
+		paths := make([]string, 0, node.count())
+		node.each(func(p string) { paths = append(paths, p) })
+		sort.Strings(paths)
+		out := make([]*FileMetadata, len(paths))
+		for i, p := range paths {
+			out[i] = sn.Files[p]
+		}
+		return out
+	}
+	all := sn.sortedPaths()
+	paths := all
+	if prefix != "" {
+		for _, f := range pathForms(filepath.Clean(prefix)) {
+			if paths = withPrefix(all, f); len(paths) > 0 {
+				prefix = f
+				break
+			}
+		}
+	}
+	out := make([]*FileMetadata, 0, len(paths))
+	for _, p := range paths {
+		// "a/b-c" 按字符串也以 "a/b" 开头，但不在其子树中
+		if m := sn.Files[p]; (prefix == "" || withinRoot(p, prefix)) && (deleted || !m.Deleted) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// FilesMatching 返回路径匹配 pattern 的条目，按路径排序
+//
+// pattern 与 IgnorePatterns 使用同一套规则(见 glob.go)：不含分隔符时只匹配文件名，
+// 含分隔符时匹配完整路径并支持 "**"，如 "**/config/**/*.yaml"
+// 模式语法错误时返回包装了 filepath.ErrBadPattern 的错误；墓碑(Deleted)不包含在内
+func (sn *SnapshotNode) FilesMatching(pattern string) ([]*FileMetadata, error) {
+	return sn.filesMatching(pattern, false)
+}
+
+// filesMatching 是 FilesMatching 的实现，deleted 时包含墓碑
+func (sn *SnapshotNode) filesMatching(pattern string, deleted bool) ([]*FileMetadata, error) {
+	if err := validatePatterns([]string{pattern}); err != nil {
+		return nil, err
+	}
+	paths := sn.sortedPaths()
+	// 完整路径模式的字面前缀可直接缩小候选范围(路径分隔符不是 "/" 的平台上需先转换，跳过此优化)
+	if hasPathSeparator(pattern) && os.PathSeparator == '/' {
+		if i := strings.IndexAny(pattern, `*?[\`); i > 0 {
+			paths = withPrefix(paths, pattern[:i])
+		}
+	}
+	patterns := []string{pattern}
+	var out []*FileMetadata
+	for _, p := range paths {
+		if m := sn.Files[p]; (deleted || !m.Deleted) && matchPatterns(patterns, p) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// FilesUnder 返回快照 id 中 prefix 子树下的条目，按路径排序，见 SnapshotNode.FilesUnder
+//
+// 快照不存在时返回 ErrSnapshotNotFound，已换出的快照从 Store 读回；opts 可按监控根过滤(见 InRoots)，
+// 传入 IncludeDeleted 时包含墓碑
+// 并发安全
+func (w *Watcher) FilesUnder(id, prefix string, opts ...QueryOption) ([]*FileMetadata, error) {
+	sn, err := w.loadSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	return filterFiles(sn.filesUnder(prefix, parseQuery(opts).deleted), w.queryFilter(opts)), nil
+}
+
+// FilesMatching 返回快照 id 中路径匹配 pattern 的条目，按路径排序，见 SnapshotNode.FilesMatching
+//
+// 快照不存在时返回 ErrSnapshotNotFound，已换出的快照从 Store 读回；opts 可按监控根过滤(见 InRoots)，
+// 传入 IncludeDeleted 时包含墓碑
+// 并发安全
+func (w *Watcher) FilesMatching(id, pattern string, opts ...QueryOption) ([]*FileMetadata, error) {
+	sn, err := w.loadSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	files, err := sn.filesMatching(pattern, parseQuery(opts).deleted)
+	if err != nil {
+		return nil, err
+	}
+	return filterFiles(files, w.queryFilter(opts)), nil
+}
+
+// GetFiles 在快照 snapshotID 中批量查找 paths
+//
+// found 以调用方给出的路径为键(分隔符两种形式都可以，见 SnapshotNode.Lookup)，值为条目的副本，修改它们不影响快照；
+// missing 是快照中不存在的路径，按在 paths 中首次出现的顺序排列。paths 中重复的路径只查找一次。
+// 墓碑视为不存在，传入 IncludeDeleted 时作为找到的条目返回；
+// 全部查找在同一个快照上完成，不逐个加锁；快照不存在时返回 ErrSnapshotNotFound，已换出的快照从 Store 读回一次
+// 并发安全
+func (w *Watcher) GetFiles(snapshotID string, paths []string, opts ...QueryOption) (found map[string]*FileMetadata, missing []string, err error) {
+	sn, err := w.loadSnapshot(snapshotID)
+	if err != nil {
+		return nil, nil, err
+	}
+	deleted := parseQuery(opts).deleted
+	found = make(map[string]*FileMetadata, len(paths))
+	seen := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		if _, dup := seen[p]; dup {
+			continue
+		}
+		seen[p] = struct{}{}
+		if m := sn.lookup(p); m != nil && (deleted || !m.Deleted) {
+			c := *m
+			found[p] = &c
+		} else {
+			missing = append(missing, p)
+		}
+	}
+	return found, missing, nil
+}
+
+// filterFiles 原地保留 keep 返回 true 的条目，keep 为nil时原样返回
+func filterFiles(files []*FileMetadata, keep func(path string) bool) []*FileMetadata {
+	if keep == nil {
+		return files
+	}
+	return slices.DeleteFunc(files, func(m *FileMetadata) bool { return !keep(m.Path) })
+}
@@ -0,0 +1,136 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newQuerySnapshot 构造一个只含路径的快照
+func newQuerySnapshot(paths ...string) *SnapshotNode {
+	sn := &SnapshotNode{ID: "q", Files: make(map[string]*FileMetadata)}
+	for _, p := range paths {
+		p = filepath.FromSlash(p)
+		sn.Files[p] = &FileMetadata{Path: p}
+	}
+	return sn
+}
+
+// metaPaths 提取路径(统一为 "/")
+func metaPaths(ms []*FileMetadata) []string {
+	out := make([]string, len(ms))
+	for i, m := range ms {
+		out[i] = filepath.ToSlash(m.Path)
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestFilesUnder 测试前缀子树查询(不包含仅字符串前缀相同的兄弟)
+func TestFilesUnder(t *testing.T) {
+	sn := newQuerySnapshot("/p", "/p/config", "/p/config/b.yaml", "/p/config/a.yaml", "/p/config-old/c.yaml", "/p/src/main.go")
+	got := metaPaths(sn.FilesUnder(filepath.FromSlash("/p/config/")))
+	want := []string{"/p/config", "/p/config/a.yaml", "/p/config/b.yaml"}
+	if !equalStrings(got, want) {
+		t.Errorf("FilesUnder = %v; want %v", got, want)
+	}
+	if n := len(sn.FilesUnder("")); n != len(sn.Files) {
+		t.Errorf("empty prefix returned %d entries", n)
+	}
+	if n := len(sn.FilesUnder(filepath.FromSlash("/nope"))); n != 0 {
+		t.Errorf("unknown prefix returned %d entries", n)
+	}
+}
+
+// TestFilesMatching 测试与忽略规则一致的匹配语义
+func TestFilesMatching(t *testing.T) {
+	sn := newQuerySnapshot("/p/config/a.yaml", "/p/config/deep/b.yaml", "/p/config/c.json", "/p/x.yaml", "/q/config/d.yaml")
+
+	got, err := sn.FilesMatching("/p/config/**/*.yaml")
+	want := []string{"/p/config/a.yaml", "/p/config/deep/b.yaml"}
+	if err != nil || !equalStrings(metaPaths(got), want) {
+		t.Errorf("FilesMatching = %v, %v; want %v", metaPaths(got), err, want)
+	}
+	got, _ = sn.FilesMatching("*.yaml")
+	if len(got) != 4 {
+		t.Errorf("basename pattern matched %v", metaPaths(got))
+	}
+	got, _ = sn.FilesMatching("**/config/*.yaml")
+	want = []string{"/p/config/a.yaml", "/q/config/d.yaml"}
+	if !equalStrings(metaPaths(got), want) {
+		t.Errorf("FilesMatching = %v; want %v", metaPaths(got), want)
+	}
+	if _, err := sn.FilesMatching("[a-"); !errors.Is(err, filepath.ErrBadPattern) {
+		t.Errorf("expected ErrBadPattern, got %v", err)
+	}
+}
+
+// TestWatcherFilesQuery 测试按快照ID查询
+func TestWatcherFilesQuery(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.yaml")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+	w := newTestWatcher(t, root)
+	if _, _, err := w.RehashFile(file); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	id := w.GetCurrentSnapshot().ID
+
+	got, err := w.FilesMatching(id, "*.yaml")
+	if err != nil || len(got) != 1 || got[0].Path != file {
+		t.Errorf("FilesMatching = %v, %v", got, err)
+	}
+	if got, err := w.FilesUnder(id, root); err != nil || len(got) != 2 {
+		t.Errorf("FilesUnder = %v, %v", got, err)
+	}
+	if _, err := w.FilesUnder("nope", root); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+}
+
+// TestGetFiles 测试批量查找：输入去重、missing 保持首次出现的顺序、返回的条目是副本
+func TestGetFiles(t *testing.T) {
+	root := t.TempDir()
+	a, b := filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt")
+	_ = os.WriteFile(a, []byte("a"), 0644)
+	_ = os.WriteFile(b, []byte("bb"), 0644)
+	w := newTestWatcher(t, root)
+	for _, p := range []string{a, b} {
+		if _, _, err := w.RehashFile(p); err != nil {
+			t.Fatalf("RehashFile failed: %v", err)
+		}
+	}
+	id := w.GetCurrentSnapshot().ID
+
+	z, y := filepath.Join(root, "z.txt"), filepath.Join(root, "y.txt")
+	found, missing, err := w.GetFiles(id, []string{z, b, a, z, y, b})
+	if err != nil {
+		t.Fatalf("GetFiles failed: %v", err)
+	}
+	if len(found) != 2 || found[a] == nil || found[a].Size != 1 || found[b] == nil || found[b].Size != 2 {
+		t.Errorf("found = %v; want a.txt and b.txt", found)
+	}
+	if !equalStrings(missing, []string{z, y}) {
+		t.Errorf("missing = %v; want [%s %s]", missing, z, y)
+	}
+
+	found[a].Size = 100
+	if m := w.GetCurrentSnapshot().Files[a]; m.Size != 1 {
+		t.Errorf("modifying a result changed the snapshot: size %d", m.Size)
+	}
+	if _, _, err := w.GetFiles("nope", []string{a}); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package watcher
+
+import "sort"
+
+// reconcile 重新扫描 root 子树并与当前快照对账，把差异作为一个快照提交
+//
+// 新出现或变化的条目会被更新，快照中存在但磁盘上已不存在的条目会被删除(不可读目录之下的条目保留，见 access.go)；
+// 没有差异或 Watcher 被停止时不提交。每个差异路径发送一个 OpReconcile* 事件，
+// 差异超过 ReconcileSummaryThreshold 时只发送一个 OpReconcileSummary 事件
+func (w *Watcher) reconcile(root, desc string) {
+	entries, ok := w.collectEntries([]string{root}, nil)
+	if !ok {
+		return
+	}
+
+	changes := make(map[string]*FileMetadata)
+	old := make(map[string]*FileMetadata)
+	w.readCurrentNode(func(sn *SnapshotNode) {
+		for p, meta := range entries {
+			if cur := live(sn.Files[p]); metaChanged(cur, meta) {
+				changes[p] = meta
+				old[p] = cur
+			}
+		}
+		w.eachLiveUnder(sn, root, func(p string, meta *FileMetadata) {
+			if _, ok := entries[p]; !ok && w.denied.covering(p) == "" {
+				changes[p] = nil
+				old[p] = meta
+			}
+		})
+	})
+
+	if len(changes) == 0 {
+		return
+	}
+	c := w.commitSnapshot(desc, changes, "")
+	w.emitCommitted(c, w.reconcileEvents(root, changes, old)...)
+}
+
+// reconcileEvents 按路径顺序为对账差异构造事件，超过 ReconcileSummaryThreshold 时只构造一个汇总事件
+func (w *Watcher) reconcileEvents(root string, changes, old map[string]*FileMetadata) []FileEvent {
+	if n := w.cfg.ReconcileSummaryThreshold; n > 0 && len(changes) > n {
+		return []FileEvent{{FilePath: root, Root: w.attributeRoot(root), Kind: OpReconcileSummary}}
+	}
+	paths := make([]string, 0, len(changes))
+	for p := range changes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	evs := make([]FileEvent, 0, len(paths))
+	for _, p := range paths {
+		ev := FileEvent{FilePath: p, Root: w.attributeRoot(p), OldMeta: old[p], NewMeta: changes[p]}
+		switch {
+		case ev.OldMeta == nil:
+			ev.Kind = OpReconcileAdd
+		case ev.NewMeta == nil:
+			ev.Kind = OpReconcileRemove
+		default:
+			ev.Kind = OpReconcileWrite
+		}
+		ev.Op = ev.Kind.fsnotifyOp()
+		evs = append(evs, ev)
+	}
+	return evs
+}
@@ -0,0 +1,61 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// drainEvents 取出 EventChan 中已缓冲的全部事件，按路径索引
+func drainEvents(w *Watcher) map[string]FileEvent {
+	out := make(map[string]FileEvent)
+	for {
+		select {
+		case ev := <-w.EventChan:
+			out[ev.FilePath] = ev
+		default:
+			return out
+		}
+	}
+}
+
+// TestReconcileEvents 测试对账发现的差异逐个路径发送事件，超过阈值时只发送汇总事件
+func TestReconcileEvents(t *testing.T) {
+	root := t.TempDir()
+	a, b, c := filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt"), filepath.Join(root, "c.txt")
+	_ = os.WriteFile(a, []byte("1"), 0644)
+	_ = os.WriteFile(b, []byte("1"), 0644)
+	w := newTestWatcher(t, root)
+
+	w.reconcile(root, "first")
+	evs := drainEvents(w)
+	for _, p := range []string{root, a, b} {
+		if ev, ok := evs[p]; !ok || ev.Kind != OpReconcileAdd || ev.NewMeta == nil || ev.NewSnap != w.GetCurrentSnapshot() {
+			t.Errorf("%s: got %v; want RECONCILE_ADD on the new snapshot", p, ev)
+		}
+	}
+
+	_ = os.WriteFile(a, []byte("22"), 0644)
+	_ = os.Remove(b)
+	_ = os.WriteFile(c, []byte("1"), 0644)
+	w.reconcile(root, "second")
+	evs = drainEvents(w)
+	want := map[string]EventOp{a: OpReconcileWrite, b: OpReconcileRemove, c: OpReconcileAdd}
+	for p, op := range want {
+		if ev := evs[p]; ev.Kind != op || ev.Root != root {
+			t.Errorf("%s: got %v (root %q); want %v", p, ev, ev.Root, op)
+		}
+	}
+	if ev := evs[b]; ev.OldMeta == nil || ev.NewMeta != nil {
+		t.Errorf("removal should carry only OldMeta: %v", ev)
+	}
+
+	w.cfg.ReconcileSummaryThreshold = 1
+	_ = os.Remove(a)
+	_ = os.Remove(c)
+	w.reconcile(root, "third")
+	evs = drainEvents(w)
+	if ev, ok := evs[root]; len(evs) != 1 || !ok || ev.Kind != OpReconcileSummary || ev.NewSnap == nil {
+		t.Errorf("got %v; want a single RECONCILE_SUMMARY for the root", evs)
+	}
+}
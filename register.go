@@ -0,0 +1,228 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchError 记录一个注册监控失败的目录
+//
+// Err 为 fsnotify 返回的底层错误(Start 时无法读取目录则为遍历的错误，其子树未被注册)，可通过 errors.Is 判断(如 syscall.ENOSPC、syscall.EMFILE)；
+// 底层错误属于系统资源耗尽时 errors.Is(err, ErrWatchLimit) 也成立
+type WatchError struct {
+	Path string
+	Err  error
+}
+
+// Error 实现 error 接口
+func (e *WatchError) Error() string {
+	return fmt.Sprintf("cannot watch dir %s: %v", e.Path, e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *WatchError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使 errors.Is(err, ErrWatchLimit) 在底层错误为资源耗尽时成立
+func (e *WatchError) Is(target error) bool {
+	return target == ErrWatchLimit && isWatchLimit(e.Err)
+}
+
+// PartialWatchError 汇总 Start 时注册失败的所有目录
+//
+// 实现了 Unwrap() []error，errors.Is/As 可直接作用于其中任意一个 *WatchError 及其底层错误
+type PartialWatchError struct {
+	Failures []*WatchError
+}
+
+// Error 实现 error 接口
+func (e *PartialWatchError) Error() string {
+	if len(e.Failures) == 1 {
+		return e.Failures[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "cannot watch %d dirs:", len(e.Failures))
+	for _, f := range e.Failures {
+		fmt.Fprintf(&b, "\n  %s: %v", f.Path, f.Err)
+	}
+	return b.String()
+}
+
+// Unwrap 返回所有失败项
+func (e *PartialWatchError) Unwrap() []error {
+	out := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		out[i] = f
+	}
+	return out
+}
+
+// WatchErrors 返回 Start 时注册失败或无法读取的目录列表(副本)
+//
+// 未开启 FailOnPartialWatch 时 Start 在部分目录注册失败后仍会以降级状态运行，
+// 调用方可通过此方法查询并单独告警；因资源耗尽失败的目录重试成功或被删除后从中移除(见 RetryFailedWatches)
+// 并发安全
+func (w *Watcher) WatchErrors() []WatchError {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	out := make([]WatchError, len(w.watchErrs))
+	for i, f := range w.watchErrs {
+		out[i] = *f
+	}
+	return out
+}
+
+// addWatch 为单个目录注册监控；addWatchFn 非空时用它替代 fsnotify(供测试注入失败)
+//
+// 已注册的目录数达到 MaxWatchedDirs 时不再注册，返回包装 ErrWatchBudget 的错误；
+// 因系统资源耗尽失败的目录记入重试集合，见 watchretry.go
+func (w *Watcher) addWatch(p string) error {
+	if !w.watches.reserve(p, w.cfg.MaxWatchedDirs) {
+		return w.errWatchBudget()
+	}
+	var err error
+	if w.addWatchFn != nil {
+		err = w.addWatchFn(p)
+	} else {
+		err = w.fsWatcher.Add(p)
+	}
+	if err != nil {
+		w.watches.unreserve(p)
+	}
+	w.noteWatchResult(p, err)
+	return err
+}
+
+// watchNewDir 为新建的目录 dir 注册监控，随即遍历其中已有的条目：子目录同样注册监控，
+// 每个条目(不含 dir 自身，遵循忽略规则)以合成的 Create 事件放入合并队列；超出 MaxWatchedDirs 时报告未被监控的目录
+//
+// mkdir -p、解压等快速建树时，子目录可能在其父目录的监控注册之前就已创建，它们及其内容的事件不会送达。
+// WalkDir 先回调目录再读取其内容，监控注册之后创建的条目有事件、之前创建的条目被遍历到，两者重复时在合并表中合并
+func (w *Watcher) watchNewDir(dir string) {
+	addDir := func(p string) {
+		if err := w.addWatch(p); errors.Is(err, ErrWatchBudget) {
+			w.emitError(&WatchError{Path: p, Err: err})
+		}
+	}
+	addDir(dir)
+	_ = w.fs.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		p = w.keyOf(p)
+		if err != nil || p == dir {
+			return nil // 目录在遍历期间被删除等：之后的事件会处理
+		}
+		if w.isIgnored(p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			addDir(p)
+		}
+		if !w.suppressSelf(p) {
+			w.queueAgg(fsnotify.Event{Name: p, Op: fsnotify.Create})
+		}
+		return nil
+	})
+}
+
+// registerWatches 递归地把所有监控根下的目录注册到 fsnotify
+//
+// 每个监控根先注册自身，再把其一级子目录作为独立任务并行遍历(并发数受 WorkerCount 限制)；
+// 单个任务内部使用 filepath.WalkDir，保证父目录先于子目录注册，被忽略的目录连同子树一起跳过。
+// 单个目录注册失败或无法读取(其子树未被注册)会被收集起来：记录到 WatchErrors()，并以 *PartialWatchError 的形式
+// 发送到 ErrorChan；开启 FailOnPartialWatch 时则作为错误返回。监控根无法访问时直接返回错误
+func (w *Watcher) registerWatches() error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures []*WatchError
+		sem      = make(chan struct{}, w.cfg.WorkerCount)
+	)
+
+	fail := func(p string, err error) {
+		mu.Lock()
+		failures = append(failures, &WatchError{Path: p, Err: err})
+		mu.Unlock()
+	}
+	addDir := func(p string) {
+		if err := w.addWatch(p); err != nil {
+			fail(p, err)
+		}
+	}
+
+	for _, root := range w.roots {
+		fi, err := w.fs.Stat(root)
+		if err != nil {
+			return wrapPathErr("failed to walk watch path "+root, err)
+		}
+		if !fi.IsDir() || w.isIgnored(root) {
+			continue
+		}
+		addDir(root)
+
+		entries, err := w.fs.ReadDir(root)
+		if err != nil {
+			fail(root, fmt.Errorf("failed to walk watch path: %w", err))
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			sub := filepath.Join(root, e.Name())
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(sub string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				_ = w.fs.WalkDir(sub, func(p string, d fs.DirEntry, err error) error {
+					p = w.keyOf(p)
+					if err != nil {
+						// 遍历期间被删除的目录之后由事件处理；其它错误(如无权限)记为该目录的失败，继续遍历其余目录
+						if !errors.Is(err, fs.ErrNotExist) {
+							fail(p, fmt.Errorf("failed to walk watch path: %w", err))
+						}
+						return nil
+					}
+					if !d.IsDir() {
+						return nil
+					}
+					if w.isIgnored(p) {
+						return filepath.SkipDir
+					}
+					addDir(p)
+					return nil
+				})
+			}(sub)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Path < failures[j].Path })
+	w.mu.Lock()
+	w.watchErrs = failures
+	w.mu.Unlock()
+	if len(failures) == 0 {
+		return nil
+	}
+
+	perr := &PartialWatchError{Failures: failures}
+	if w.cfg.FailOnPartialWatch {
+		return fmt.Errorf("failed to register watches: %w", perr)
+	}
+	if w.emitError(perr) {
+		w.logWarn("Warning", perr)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// makeTree 生成宽度为 width、深度为 depth 的目录树，返回目录总数
+func makeTree(tb testing.TB, root string, width, depth int) int {
+	tb.Helper()
+	if depth == 0 {
+		return 0
+	}
+	n := 0
+	for i := 0; i < width; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%d", i))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			tb.Fatal(err)
+		}
+		n += 1 + makeTree(tb, dir, width, depth-1)
+	}
+	return n
+}
+
+// TestRegisterWatches 测试所有目录都被注册
+func TestRegisterWatches(t *testing.T) {
+	root := t.TempDir()
+	dirs := makeTree(t, root, 3, 3)
+
+	w := newTestWatcher(t, root)
+	if err := w.registerWatches(); err != nil {
+		t.Fatalf("registerWatches failed: %v", err)
+	}
+	if got := len(w.fsWatcher.WatchList()); got != dirs+1 {
+		t.Errorf("watched %d dirs; want %d", got, dirs+1)
+	}
+}
+
+// TestRegisterWatchesMissingRoot 测试监控根不存在时返回错误
+func TestRegisterWatchesMissingRoot(t *testing.T) {
+	w := newTestWatcher(t, filepath.Join(t.TempDir(), "missing"))
+	if err := w.registerWatches(); err == nil {
+		t.Error("expected error for missing watch root")
+	}
+}
+
+// BenchmarkRegisterWatches 对比串行注册与并行注册
+func BenchmarkRegisterWatches(b *testing.B) {
+	root := b.TempDir()
+	makeTree(b, root, 8, 3)
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fsw, _ := fsnotify.NewWatcher()
+			_ = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+				if err == nil && info.IsDir() {
+					_ = fsw.Add(p)
+				}
+				return err
+			})
+			fsw.Close()
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root}})
+		if err != nil {
+			b.Fatal(err)
+		}
+		w.fsWatcher.Close()
+		for i := 0; i < b.N; i++ {
+			w.fsWatcher, _ = NewFsnotifySource()
+			_ = w.registerWatches()
+			w.fsWatcher.Close()
+		}
+	})
+}
+
+// TestPartialWatchErrors 测试注册失败的收集与 FailOnPartialWatch
+func TestPartialWatchErrors(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, 2, 1)
+	bad := filepath.Join(root, "d1")
+
+	inject := func(w *Watcher) {
+		w.addWatchFn = func(p string) error {
+			if p == bad {
+				return syscall.ENOSPC
+			}
+			return w.fsWatcher.Add(p)
+		}
+	}
+
+	w := newTestWatcher(t, root)
+	inject(w)
+	if err := w.registerWatches(); err != nil {
+		t.Fatalf("degraded start should not fail: %v", err)
+	}
+	errs := w.WatchErrors()
+	if len(errs) != 1 || errs[0].Path != bad || !errors.Is(errs[0].Err, syscall.ENOSPC) {
+		t.Errorf("unexpected WatchErrors: %+v", errs)
+	}
+	var perr *PartialWatchError
+	if e := <-w.ErrorChan; !errors.As(e, &perr) {
+		t.Errorf("expected *PartialWatchError on ErrorChan, got %v", e)
+	}
+
+	w = newTestWatcher(t, root)
+	w.cfg.FailOnPartialWatch = true
+	inject(w)
+	err := w.registerWatches()
+	var we *WatchError
+	if !errors.As(err, &we) || we.Path != bad || !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("expected wrapped WatchError for %s, got %v", bad, err)
+	}
+}
+
+// TestRegisterWatchesSkipsAndCollects 测试被忽略的目录连同子树不被注册，无法读取的目录记为 WatchError 而不中止注册
+func TestRegisterWatchesSkipsAndCollects(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"a/b", "c", "skip/x"} {
+		_ = os.MkdirAll(filepath.Join(root, d), 0755)
+	}
+	denied := filepath.Join(root, "a")
+
+	newWatcher := func(opts ...Option) *Watcher {
+		fsys := &deniedFS{FS: osFS{}}
+		fsys.dir.Store(denied)
+		w, err := NewWatcherWithOptions([]string{root}, append([]Option{WithFS(fsys, replaySource{}), WithIgnorePatterns("**/skip")}, opts...)...)
+		if err != nil {
+			t.Fatalf("NewWatcherWithOptions failed: %v", err)
+		}
+		w.addWatchFn = func(string) error { return nil }
+		return w
+	}
+
+	w := newWatcher()
+	if err := w.registerWatches(); err != nil {
+		t.Fatalf("an unreadable dir should not fail registration: %v", err)
+	}
+	for _, d := range []string{root, denied, filepath.Join(root, "c")} {
+		if _, ok := w.watches.dirs[w.keyOf(d)]; !ok {
+			t.Errorf("%s is not watched", d)
+		}
+	}
+	for _, d := range []string{filepath.Join(root, "skip"), filepath.Join(root, "skip", "x"), filepath.Join(denied, "b")} {
+		if _, ok := w.watches.dirs[w.keyOf(d)]; ok {
+			t.Errorf("%s should not be watched", d)
+		}
+	}
+	errs := w.WatchErrors()
+	if len(errs) != 1 || errs[0].Path != w.keyOf(denied) || !errors.Is(errs[0].Err, fs.ErrPermission) {
+		t.Errorf("WatchErrors = %+v; want %s with a permission error", errs, denied)
+	}
+
+	w = newWatcher(WithFailOnPartialWatch())
+	if err := w.registerWatches(); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("FailOnPartialWatch: registerWatches = %v; want the permission error", err)
+	}
+}
+
+// TestWatchNewDirNested 测试新建目录的监控注册：其中已有的子目录同样注册，已有的条目以合成事件补上；
+// 真实的快速嵌套 mkdir 与写入最终全部出现在快照中
+func TestWatchNewDirNested(t *testing.T) {
+	// 只送达最外层目录的事件，子目录与文件的事件全部丢失
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithDisableEventChan(), WithIgnorePatterns("**/*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	top := filepath.Join(root, "a")
+	_ = os.MkdirAll(filepath.Join(top, "b", "c"), 0755)
+	files := []string{filepath.Join(top, "x.txt"), filepath.Join(top, "b", "y.txt"), filepath.Join(top, "b", "c", "z.txt")}
+	for _, f := range files {
+		_ = os.WriteFile(f, []byte(f), 0644)
+	}
+	_ = os.WriteFile(filepath.Join(top, "b", "skip.tmp"), nil, 0644)
+	w.handleFsEvent(fsnotify.Event{Name: top, Op: fsnotify.Create})
+	for len(w.aggChan) > 0 {
+		w.mergeAgg(<-w.aggChan)
+	}
+	w.flushAgg(true)
+	w.workerWG.Wait()
+
+	for _, d := range []string{top, filepath.Join(top, "b"), filepath.Join(top, "b", "c")} {
+		if _, ok := w.watches.dirs[w.keyOf(d)]; !ok {
+			t.Errorf("%s is not watched", d)
+		}
+	}
+	for _, f := range files {
+		if _, ok := w.CurrentFile(w.keyOf(f)); !ok {
+			t.Errorf("%s missing from the snapshot", f)
+		}
+	}
+	if _, ok := w.CurrentFile(w.keyOf(filepath.Join(top, "b", "skip.tmp"))); ok {
+		t.Error("ignored file reached the snapshot")
+	}
+
+	// 真实的事件来源
+	root = t.TempDir()
+	w, err = NewWatcherWithOptions([]string{root}, WithDebounce(5*time.Millisecond), WithDisableEventChan())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for i := 0; i < 20; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("t%d", i), "b", "c", "d")
+		_ = os.MkdirAll(dir, 0755)
+		for _, d := range []string{dir, filepath.Dir(dir), filepath.Dir(filepath.Dir(dir))} {
+			f := filepath.Join(d, "f.txt")
+			_ = os.WriteFile(f, []byte(f), 0644)
+			want = append(want, w.keyOf(f))
+		}
+	}
+	missing := func() (n int) {
+		for _, p := range want {
+			if _, ok := w.CurrentFile(p); !ok {
+				n++
+			}
+		}
+		return n
+	}
+	if !waitFor(t, 5*time.Second, func() bool { return missing() == 0 }) {
+		t.Errorf("%d of %d files never reached the snapshot", missing(), len(want))
+	}
+}
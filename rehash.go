@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// RehashFile 立即对单个路径重新 stat 并计算哈希，与当前快照比对
+//
+// 若与当前快照记录不一致(包括新出现或已消失)，则提交一个新快照并向 EventChan 发送事件
+// 返回值：最新的文件元信息(文件已不存在，或哈希失败且 HashErrorPolicy 为 DropEntry 时为nil)、是否发现变化、错误
+// 路径命中忽略规则时返回错误，Close 之后返回 ErrStopped；适合在怀疑哈希过期时手动校验，也可在测试中替代等待Debounce
+func (w *Watcher) RehashFile(path string) (*FileMetadata, bool, error) {
+	if err := w.beginMutation(); err != nil {
+		return nil, false, err
+	}
+	defer w.endMutation()
+	path = w.keyOf(path)
+	if w.isIgnored(path) {
+		return nil, false, fmt.Errorf("%w: %s", ErrPathIgnored, path)
+	}
+
+	old := w.currentMeta(path)
+
+	fileInfo, err := w.fs.Stat(path)
+	if err != nil {
+		if !os.IsNotExist(err) || old == nil {
+			return nil, false, wrapPathErr("failed to stat "+path, err)
+		}
+		c := w.commitSnapshot(fmt.Sprintf("Rehash: %s no longer exists", path), map[string]*FileMetadata{path: nil}, path)
+		w.emitFileEvent(path, fsnotify.Remove, c)
+		return nil, true, nil
+	}
+
+	meta := w.buildMeta(path, fileInfo, old, nil)
+	if meta == nil {
+		// 哈希失败且策略为 DropEntry
+		if old == nil {
+			return nil, false, nil
+		}
+		c := w.commitSnapshot(fmt.Sprintf("Rehash: %s could not be hashed", path), map[string]*FileMetadata{path: nil}, path)
+		w.emitFileEvent(path, fsnotify.Remove, c)
+		return nil, true, nil
+	}
+	if !metaChanged(old, meta) {
+		return meta, false, nil
+	}
+
+	op := fsnotify.Write
+	if old == nil {
+		op = fsnotify.Create
+	}
+	changes := map[string]*FileMetadata{path: meta}
+	for p, m := range w.missingAncestors(path) {
+		changes[p] = m
+	}
+	c := w.commitSnapshot(fmt.Sprintf("Rehash: %s changed", path), changes, path)
+	w.emitFileEvent(path, op, c)
+	return meta, true, nil
+}
+
+// metaChanged 判断新采集的元信息相对快照中记录是否发生变化
+//
+// 目录的哈希由子节点推导，因此目录只比较类型、修改时间与是否可读
+func metaChanged(old, cur *FileMetadata) bool {
+	if old == nil || old.IsDirectory != cur.IsDirectory {
+		return true
+	}
+	if cur.IsDirectory {
+		return !old.ModTime.Equal(cur.ModTime) || old.Inaccessible != cur.Inaccessible
+	}
+	return !sameContent(old, cur)
+}
@@ -0,0 +1,51 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRehashFile 测试按需重新校验单个文件
+func TestRehashFile(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("one"), 0644)
+
+	w := newTestWatcher(t, root)
+
+	meta, changed, err := w.RehashFile(file)
+	if err != nil || !changed || meta == nil || meta.Hash == "" {
+		t.Fatalf("first rehash should record the file: meta=%v changed=%v err=%v", meta, changed, err)
+	}
+	if evt := <-w.EventChan; evt.FilePath != file {
+		t.Errorf("expected event for %s, got %s", file, evt.FilePath)
+	}
+
+	if _, changed, _ := w.RehashFile(file); changed {
+		t.Errorf("rehash of an unchanged file should report no change")
+	}
+
+	_ = os.WriteFile(file, []byte("two"), 0644)
+	meta2, changed, err := w.RehashFile(file)
+	if err != nil || !changed || meta2.Hash == meta.Hash {
+		t.Errorf("rehash should detect modified content")
+	}
+
+	_ = os.Remove(file)
+	if m, changed, err := w.RehashFile(file); err != nil || !changed || m != nil {
+		t.Errorf("rehash should detect removal: meta=%v changed=%v err=%v", m, changed, err)
+	}
+	if _, ok := w.GetCurrentSnapshot().Files[file]; ok {
+		t.Errorf("removed file should not remain in snapshot")
+	}
+}
+
+// TestRehashFileIgnored 测试被忽略路径返回错误
+func TestRehashFileIgnored(t *testing.T) {
+	pats := []string{"*.tmp"}
+	w := &Watcher{cfg: ConfigWatcher{IgnorePatterns: pats}, ignore: compileGlobs(pats)}
+	if _, _, err := w.RehashFile("x.tmp"); err == nil {
+		t.Errorf("expected error for ignored path")
+	}
+}
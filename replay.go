@@ -0,0 +1,269 @@
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"time"
+)
+
+// ReplayOptions 是 ReplayEvents 的选项
+//
+// Baseline：从已有的快照(如从 Store 读回的)继续回放，早于第一条以它为父快照的记录的记录被跳过；nil 时从空的初始快照开始
+// StopAtSeq/StopAt：只回放序号不大于 StopAtSeq、时间不晚于 StopAt 的记录，遇到第一条超出的记录即停止；零值表示不限
+// AllowGaps：允许序号不连续与父快照缺失(如审计队列满时丢弃过记录)，否则返回包装 ErrReplayGap 的错误
+// Options：创建 Watcher 时附加的选项，如 WithStore(回放结果在 Close 时写入 Store)
+type ReplayOptions struct {
+	Baseline  *SnapshotNode
+	StopAtSeq uint64
+	StopAt    time.Time
+	AllowGaps bool
+	Options   []Option
+}
+
+// ReplayEvents 读取审计日志(NDJSON，见 AuditRecord)，按原 Watcher 的提交顺序重建快照DAG，不需要访问原文件系统
+//
+// 记录按序号排序后按快照ID分组，每组还原为一个快照：ID、父快照与原快照相同，CreatedAt 为该组最早记录的时间
+// (审计记录的时间是事件发送时间，可能略晚于原快照)，Description 按单个变更的格式重新生成
+// (MinSnapshotInterval 合并的快照带 "(coalesced N changes)" 后缀)，ChangedPaths 为该组记录的路径。
+// 审计记录只有哈希，因此文件条目只有 Path、Hash、HashState 与 CreatedAt(没有大小、修改时间与目录标记)，
+// 删除的路径连同其下的条目一起删除，RootHash 为空；没有初始快照的记录时，初始快照以第一条记录的父快照ID创建
+//
+// 返回的 Watcher 未启动，只用于查询、差异与历史(GetSnapshotByID、DiffSnapshots、FileHistory 等)，不应调用 Start；
+// 使用完毕后调用 Close(配置了 Store 时回放的快照在此时写入)
+func ReplayEvents(r io.Reader, opts ReplayOptions) (*Watcher, error) {
+	recs, err := readReplayRecords(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	all := append(append([]Option(nil), opts.Options...), WithFS(OSFS(), replaySource{}), WithDisableEventChan())
+	w, err := NewWatcherWithOptions(nil, all...)
+	if err != nil {
+		return nil, err
+	}
+	if w.cfg.DisableSnapshots {
+		_ = w.Close()
+		return nil, fmt.Errorf("%w: ReplayEvents requires snapshots", ErrInvalidConfig)
+	}
+	if err := w.replay(recs, opts); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// readReplayRecords 读取全部记录，按序号排序并截断到停止位置，检查序号是否连续
+//
+// 并发的 worker 发送事件的顺序可能与序号不一致，审计日志中的记录因此不一定按序号排列
+func readReplayRecords(r io.Reader, opts ReplayOptions) ([]AuditRecord, error) {
+	ar := NewAuditReader(r)
+	var recs []AuditRecord
+	for {
+		rec, err := ar.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		if rec.SnapshotID == "" {
+			return nil, fmt.Errorf("audit record seq %d has no snapshot ID (written with DisableSnapshots?)", rec.Seq)
+		}
+		recs = append(recs, rec)
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Seq < recs[j].Seq })
+
+	for i, rec := range recs {
+		if (opts.StopAtSeq > 0 && rec.Seq > opts.StopAtSeq) || (!opts.StopAt.IsZero() && rec.Time.After(opts.StopAt)) {
+			recs = recs[:i]
+			break
+		}
+	}
+	if base := opts.Baseline; base != nil {
+		start := -1
+		for i, rec := range recs {
+			if rec.ParentID == base.ID && rec.SnapshotID != base.ID {
+				start = i
+				break
+			}
+		}
+		if start < 0 && len(recs) > 0 {
+			return nil, fmt.Errorf("baseline %s is not a parent of any replayed record: %w", base.ID, ErrSnapshotNotFound)
+		}
+		recs = recs[max(start, 0):]
+	}
+	if !opts.AllowGaps {
+		for i := 1; i < len(recs); i++ {
+			if prev := recs[i-1].Seq; recs[i].Seq != prev+1 {
+				return nil, fmt.Errorf("%w: seq %d follows %d", ErrReplayGap, recs[i].Seq, prev)
+			}
+		}
+	}
+	return recs, nil
+}
+
+// replayGroup 是同一个快照的全部记录(按序号排列)
+type replayGroup struct {
+	id, parent string
+	recs       []AuditRecord
+}
+
+// replay 把记录还原为快照并发布，Watcher 未启动，不会与其它提交并发
+func (w *Watcher) replay(recs []AuditRecord, opts ReplayOptions) error {
+	var groups []*replayGroup
+	byID := make(map[string]*replayGroup)
+	for _, rec := range recs {
+		g := byID[rec.SnapshotID]
+		if g == nil {
+			g = &replayGroup{id: rec.SnapshotID, parent: rec.ParentID}
+			byID[rec.SnapshotID] = g
+			groups = append(groups, g)
+		}
+		if rec.ParentID != g.parent {
+			return fmt.Errorf("audit record seq %d: snapshot %s has parents %s and %s", rec.Seq, rec.SnapshotID, g.parent, rec.ParentID)
+		}
+		g.recs = append(g.recs, rec)
+	}
+
+	root := opts.Baseline
+	if root == nil {
+		if len(groups) == 0 {
+			return nil // 空日志：保留新建的空初始快照
+		}
+		if groups[0].parent == "" {
+			return fmt.Errorf("audit record seq %d: snapshot %s has no parent", groups[0].recs[0].Seq, groups[0].id)
+		}
+		root = &SnapshotNode{
+			ID:          groups[0].parent,
+			Instance:    SnapshotInstance(groups[0].parent),
+			CreatedAt:   groups[0].recs[0].Time,
+			Description: "Initial snapshot",
+			Files:       make(map[string]*FileMetadata),
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.snapshots.del(w.head.Load().ID)
+	w.spill.resident = nil
+	w.history = historyIndex{}
+	w.snapshots.put(root)
+	w.head.Store(root)
+	w.trackResidentLocked(root.ID)
+
+	// 按父子关系依次还原；父快照缺失的组在 AllowGaps 时按日志顺序接在当前 HEAD 上
+	children := make(map[string][]*replayGroup)
+	for _, g := range groups {
+		children[g.parent] = append(children[g.parent], g)
+	}
+	done := make(map[string]bool, len(groups))
+	queue := append([]*replayGroup(nil), children[root.ID]...)
+	for len(done) < len(groups) {
+		if len(queue) == 0 {
+			var orphan *replayGroup
+			for _, g := range groups {
+				if !done[g.id] {
+					orphan = g
+					break
+				}
+			}
+			if !opts.AllowGaps {
+				return fmt.Errorf("%w: snapshot %s has missing parent %s", ErrReplayGap, orphan.id, orphan.parent)
+			}
+			queue = append(queue, orphan)
+		}
+		g := queue[0]
+		queue = queue[1:]
+		if done[g.id] {
+			continue
+		}
+		parent := w.snapshots.get(g.parent)
+		if parent == nil {
+			parent = w.head.Load()
+		}
+		w.publishLocked(replayNode(g, parent, w.cfg.MaxChangedPaths))
+		done[g.id] = true
+		queue = append(queue, children[g.id]...)
+	}
+	return nil
+}
+
+// replayNode 把一组记录应用到父快照的文件表副本上，得到还原的快照
+func replayNode(g *replayGroup, parent *SnapshotNode, maxChanged int) *SnapshotNode {
+	first := g.recs[0]
+	sn := &SnapshotNode{
+		ID:          g.id,
+		Instance:    SnapshotInstance(g.id),
+		ParentIDs:   []string{g.parent},
+		CreatedAt:   first.Time,
+		Description: fmt.Sprintf("Snapshot after %s on %s", first.Op, first.Path),
+		Files:       maps.Clone(parent.Files),
+	}
+	if sn.Files == nil {
+		sn.Files = make(map[string]*FileMetadata)
+	}
+	if len(g.recs) > 1 {
+		sn.Description = fmt.Sprintf("%s (coalesced %d changes)", sn.Description, len(g.recs))
+	}
+	// 前缀树不含墓碑，父快照有墓碑时删除子树还需逐个比较
+	t := parent.pathTrie()
+	tombs := t.count() != len(parent.Files)
+	e := t.edit()
+	changed := make(map[string]*FileMetadata, len(g.recs))
+	for _, rec := range g.recs {
+		if rec.Time.Before(sn.CreatedAt) {
+			sn.CreatedAt = rec.Time
+		}
+		// 基线事件不对应任何路径，基线快照的条目只有开启 ScanEvents 时才记录在审计日志中
+		if ParseEventOp(rec.Op).Has(OpBaseline) {
+			sn.Description = "Baseline snapshot"
+			continue
+		}
+		changed[rec.Path] = nil
+		if rec.NewHash == "" && ParseEventOp(rec.Op).IsDelete() {
+			removeSubtree(sn.Files, e, rec.Path, tombs)
+			continue
+		}
+		meta := &FileMetadata{Path: rec.Path, Hash: rec.NewHash, CreatedAt: rec.Time}
+		if rec.NewHash != "" {
+			meta.HashState = HashStateHashed
+		}
+		sn.Files[rec.Path] = meta
+		e.insert(rec.Path)
+	}
+	sn.trie.Store(e.done())
+	if sn.CreatedAt.Before(parent.CreatedAt) {
+		sn.CreatedAt = parent.CreatedAt
+	}
+	setChangedPaths(sn, changed, maxChanged)
+	return sn
+}
+
+// removeSubtree 从 files 中删除 path 及其下的全部条目，并从前缀树 e 中摘下该子树
+//
+// 按前缀树只访问 path 的子树；scan 时(files 中有墓碑)再逐个比较全部路径，删除子树中的墓碑
+func removeSubtree(files map[string]*FileMetadata, e *trieEdit, path string, scan bool) {
+	e.root.find(path).each(func(p string) { delete(files, p) })
+	e.removeTree(path)
+	delete(files, path)
+	if !scan {
+		return
+	}
+	for p := range files {
+		if withinRoot(p, path) {
+			delete(files, p)
+		}
+	}
+}
+
+// replaySource 是回放 Watcher 使用的空事件来源，从不产生事件
+type replaySource struct{}
+
+func (replaySource) Add(string) error        { return nil }
+func (replaySource) Remove(string) error     { return nil }
+func (replaySource) WatchList() []string     { return nil }
+func (replaySource) Events() <-chan RawEvent { return nil }
+func (replaySource) Errors() <-chan error    { return nil }
+func (replaySource) Close() error            { return nil }
@@ -0,0 +1,240 @@
+package watcher_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchertest"
+)
+
+// recordAuditLog 用 Harness 产生一段变更历史，返回审计日志与关闭前的全部快照(按创建顺序)
+func recordAuditLog(t *testing.T) (string, []*watcher.SnapshotNode) {
+	t.Helper()
+	var buf bytes.Buffer
+	h := watchertest.NewHarness(t, watcher.WithAuditWriter(&buf))
+	var snaps []*watcher.SnapshotNode
+	step := func() {
+		h.AdvanceDebounce()
+		snaps = append(snaps, h.W.GetCurrentSnapshot())
+	}
+	h.Mkdir("dir")
+	step()
+	h.Touch("dir/a.txt", "1")
+	step()
+	h.Touch("b.txt", "1")
+	step()
+	h.Touch("dir/a.txt", "2")
+	step()
+	h.Remove("dir")
+	step()
+	h.Close()
+	return buf.String(), snaps
+}
+
+// TestReplayEvents 测试按审计日志还原的快照与原快照的ID、父快照与文件哈希一致
+func TestReplayEvents(t *testing.T) {
+	log, snaps := recordAuditLog(t)
+	w, err := watcher.ReplayEvents(strings.NewReader(log), watcher.ReplayOptions{})
+	if err != nil {
+		t.Fatalf("ReplayEvents failed: %v", err)
+	}
+	defer w.Close()
+
+	if got := w.GetCurrentSnapshot().ID; got != snaps[len(snaps)-1].ID {
+		t.Fatalf("HEAD = %s; want %s", got, snaps[len(snaps)-1].ID)
+	}
+	for _, orig := range snaps {
+		sn := w.GetSnapshotByID(orig.ID)
+		if sn == nil {
+			t.Fatalf("snapshot %s not replayed", orig.ID)
+		}
+		if len(sn.ParentIDs) != 1 || sn.ParentIDs[0] != orig.ParentIDs[0] {
+			t.Errorf("%s parents = %v; want %v", orig.ID, sn.ParentIDs, orig.ParentIDs)
+		}
+		for p, m := range orig.Files {
+			if m.IsDirectory {
+				continue
+			}
+			if got := sn.Files[p]; got == nil || got.Hash != m.Hash {
+				t.Errorf("%s: %s = %v; want hash %s", orig.ID, p, got, m.Hash)
+			}
+		}
+	}
+	if got := w.GetCurrentSnapshot().Files; len(got) != 1 {
+		t.Errorf("removing dir should drop its subtree: %v", got)
+	}
+	if issues := w.ValidateStore(); len(issues) != 0 {
+		t.Errorf("replayed history has issues: %v", issues)
+	}
+}
+
+// TestReplayStopAndBaseline 测试回放到指定序号停止，以及从已有快照继续回放
+func TestReplayStopAndBaseline(t *testing.T) {
+	log, snaps := recordAuditLog(t)
+
+	w, err := watcher.ReplayEvents(strings.NewReader(log), watcher.ReplayOptions{StopAtSeq: 2})
+	if err != nil {
+		t.Fatalf("ReplayEvents failed: %v", err)
+	}
+	defer w.Close()
+	head := w.GetCurrentSnapshot()
+	if head.ID != snaps[1].ID {
+		t.Fatalf("HEAD = %s; want %s", head.ID, snaps[1].ID)
+	}
+
+	rest, err := watcher.ReplayEvents(strings.NewReader(log), watcher.ReplayOptions{Baseline: head})
+	if err != nil {
+		t.Fatalf("ReplayEvents from baseline failed: %v", err)
+	}
+	defer rest.Close()
+	if got := rest.GetCurrentSnapshot().ID; got != snaps[len(snaps)-1].ID {
+		t.Errorf("HEAD = %s; want %s", got, snaps[len(snaps)-1].ID)
+	}
+	if rest.GetSnapshotByID(snaps[0].ID) != nil {
+		t.Errorf("snapshot %s before the baseline was replayed", snaps[0].ID)
+	}
+	for _, sn := range snaps[1:] {
+		if rest.GetSnapshotByID(sn.ID) == nil {
+			t.Errorf("snapshot %s missing", sn.ID)
+		}
+	}
+}
+
+// TestReplayGap 测试缺失的记录导致 ErrReplayGap，AllowGaps 时照常回放
+func TestReplayGap(t *testing.T) {
+	log, snaps := recordAuditLog(t)
+	lines := strings.SplitAfter(log, "\n")
+	gapped := lines[0] + strings.Join(lines[2:], "")
+
+	if _, err := watcher.ReplayEvents(strings.NewReader(gapped), watcher.ReplayOptions{}); !errors.Is(err, watcher.ErrReplayGap) {
+		t.Fatalf("err = %v; want ErrReplayGap", err)
+	}
+	w, err := watcher.ReplayEvents(strings.NewReader(gapped), watcher.ReplayOptions{AllowGaps: true})
+	if err != nil {
+		t.Fatalf("ReplayEvents with AllowGaps failed: %v", err)
+	}
+	defer w.Close()
+	if got := w.GetCurrentSnapshot().ID; got != snaps[len(snaps)-1].ID {
+		t.Errorf("HEAD = %s; want %s", got, snaps[len(snaps)-1].ID)
+	}
+}
+
+// rootHashOf 以 files 为 Baseline 创建 Watcher，返回重新计算的 RootHash
+func rootHashOf(t *testing.T, root string, files map[string]*watcher.FileMetadata) string {
+	t.Helper()
+	mfs := watchertest.NewMemFS(nil)
+	if err := mfs.MkdirAll(root); err != nil {
+		t.Fatal(err)
+	}
+	w, err := watcher.NewWatcherWithOptions([]string{root}, watcher.WithFS(mfs, mfs.Source()), watcher.WithDisableEventChan(),
+		watcher.WithBaseline(&watcher.SnapshotNode{Files: files}, false))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	defer w.Close()
+	return w.GetCurrentSnapshot().RootHash
+}
+
+// TestEventParentLinksReproduceDAG 随机生成变更序列(部分批次在 MinSnapshotInterval 下合并为一个快照)，
+// 断言按 Seq 顺序沿 ParentSnapshotID 应用事件得到的每个快照与原快照的 RootHash 相同，审计日志回放得到相同的父子关系
+func TestEventParentLinksReproduceDAG(t *testing.T) {
+	paths := []string{"a.txt", "b.txt", "d1/c.txt", "d1/d2/e.txt", "d3/f.txt"}
+	for seed := int64(1); seed <= 8; seed++ {
+		t.Run(fmt.Sprint("seed", seed), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(seed))
+			var audit bytes.Buffer
+			opts := []watcher.Option{watcher.WithAuditWriter(&audit)}
+			if seed%2 == 0 {
+				opts = append(opts, watcher.WithMinSnapshotInterval(25*time.Millisecond))
+			}
+			h := watchertest.NewHarness(t, opts...)
+			initial := h.W.GetCurrentSnapshot()
+
+			exists := make(map[string]bool)
+			for step := 0; step < 30; step++ {
+				for n := 1 + rng.Intn(3); n > 0; n-- {
+					p := paths[rng.Intn(len(paths))]
+					switch {
+					case exists[p] && rng.Intn(4) == 0:
+						h.Remove(p)
+						delete(exists, p)
+					case rng.Intn(8) == 0 && exists["d1/c.txt"]:
+						h.Remove("d1")
+						delete(exists, "d1/c.txt")
+						delete(exists, "d1/d2/e.txt")
+					default:
+						h.Touch(p, fmt.Sprint(rng.Intn(5)))
+						exists[p] = true
+					}
+				}
+				h.AdvanceDebounce()
+			}
+			for i := 0; i < 4; i++ {
+				h.AdvanceDebounce() // 发布 MinSnapshotInterval 下仍待发布的快照
+			}
+			events := h.Events()
+			snaps := h.W.ListAllSnapshots()
+			h.Close()
+
+			// 按 Seq 顺序沿父快照重建
+			sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+			states := map[string]map[string]*watcher.FileMetadata{initial.ID: {}}
+			for _, ev := range events {
+				st, ok := states[ev.SnapshotID()]
+				if !ok {
+					parent, ok := states[ev.ParentSnapshotID]
+					if !ok {
+						t.Fatalf("event %v extends unknown snapshot %q", ev, ev.ParentSnapshotID)
+					}
+					st = maps.Clone(parent)
+					states[ev.SnapshotID()] = st
+				}
+				if ev.NewMeta == nil {
+					for p := range st {
+						if p == ev.FilePath || strings.HasPrefix(p, ev.FilePath+"/") {
+							delete(st, p)
+						}
+					}
+					continue
+				}
+				st[ev.FilePath] = ev.NewMeta
+			}
+			if len(states) != len(snaps) {
+				t.Fatalf("rebuilt %d snapshots; want %d", len(states), len(snaps))
+			}
+			for _, sn := range snaps {
+				st, ok := states[sn.ID]
+				if !ok {
+					t.Fatalf("snapshot %s not rebuilt", sn.ID)
+				}
+				if sn.ID != initial.ID {
+					if got := rootHashOf(t, h.Root, st); got != sn.RootHash {
+						t.Fatalf("%s: rebuilt RootHash = %s; want %s", sn.ID, got, sn.RootHash)
+					}
+				}
+			}
+
+			// 审计日志带有同样的父子关系
+			w, err := watcher.ReplayEvents(&audit, watcher.ReplayOptions{})
+			if err != nil {
+				t.Fatalf("ReplayEvents failed: %v", err)
+			}
+			defer w.Close()
+			for _, sn := range snaps {
+				got := w.GetSnapshotByID(sn.ID)
+				if got == nil || !slices.Equal(got.ParentIDs, sn.ParentIDs) {
+					t.Fatalf("replayed %s = %v; want parents %v", sn.ID, got, sn.ParentIDs)
+				}
+			}
+		})
+	}
+}
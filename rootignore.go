@@ -0,0 +1,52 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// 监控根与忽略规则
+//
+// 显式配置的监控根从不因忽略规则被排除：即使监控根本身匹配 IgnorePatterns(如监控一个名为 ".git" 的裸仓库，
+// 同时配置了 "**/.git")，它仍然被注册、扫描与记录，规则只作用于其下的条目。NewWatcher 时为每个这样的监控根
+// 记录一条警告，说明匹配的规则，提醒其下的条目可能同样被排除(如 "**/.git/**" 会排除监控根下的全部内容)
+
+// ignoreMatch 返回 path(原始路径)匹配的第一条忽略规则，规则见 isIgnored
+func (w *Watcher) ignoreMatch(path string) (string, bool) {
+	base := filepath.Base(path)
+	for i := range w.ignore {
+		g := &w.ignore[i]
+		if g.path {
+			if g.matchPath(filepath.ToSlash(path)) {
+				return g.raw, true
+			}
+			continue
+		}
+		matched, _ := filepath.Match(g.raw, base)
+		if matched {
+			// 如果是在子目录中，且模式不包含路径分隔符，则不忽略
+			if filepath.Dir(path) != "." {
+				return "", false
+			}
+			return g.raw, true
+		}
+	}
+	return "", false
+}
+
+// exemptRoots 登记匹配忽略规则的监控根(原始路径，与 ignoredRaw 的参数一致)，使其不被忽略，并记录警告
+func (w *Watcher) exemptRoots() {
+	for _, root := range w.roots {
+		raw := w.keyOf(w.diskPath(root))
+		pat, ok := w.ignoreMatch(raw)
+		if !ok {
+			continue
+		}
+		if w.rootExempt == nil {
+			w.rootExempt = make(map[string]struct{})
+		}
+		w.rootExempt[raw] = struct{}{}
+		w.logWarn("Watch root matches an ignore pattern",
+			fmt.Errorf("%s matches %q: the root is still watched, but entries under it that match are ignored", root, pat))
+	}
+}
@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestRootMatchesIgnore 测试匹配忽略规则的监控根仍被监控并记录警告，规则只作用于其下的条目
+func TestRootMatchesIgnore(t *testing.T) {
+	root := filepath.Join(t.TempDir(), ".git")
+	for _, d := range []string{"hooks", "objects", filepath.Join("sub", ".git")} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = os.WriteFile(filepath.Join(root, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(root, "hooks", "pre-commit"), []byte("#!/bin/sh\n"), 0o755)
+
+	var logs bytes.Buffer
+	w, err := NewWatcherWithOptions([]string{root}, WithIgnorePatterns("**/.git", "**/.git/hooks"),
+		WithScanOnStart(nil), WithDisableEventChan(), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	if out := logs.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, "**/.git") {
+		t.Errorf("log = %q; want a warning naming the matching pattern", out)
+	}
+
+	key := w.keyOf(root)
+	cases := []struct {
+		path   string
+		ignore bool
+	}{
+		{key, false},
+		{w.keyOf(filepath.Join(root, "hooks")), true},
+		{w.keyOf(filepath.Join(root, "objects")), false},
+		{w.keyOf(filepath.Join(root, "sub", ".git")), true},
+	}
+	for _, c := range cases {
+		if got := w.isIgnored(c.path); got != c.ignore {
+			t.Errorf("isIgnored(%s) = %v; want %v", c.path, got, c.ignore)
+		}
+	}
+
+	var mu sync.Mutex
+	watched := make(map[string]bool)
+	w.addWatchFn = func(p string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		watched[p] = true
+		return nil
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := w.WaitReady(context.Background()); err != nil {
+		t.Fatalf("WaitReady failed: %v", err)
+	}
+	mu.Lock()
+	if !watched[root] || !watched[filepath.Join(root, "objects")] || watched[filepath.Join(root, "hooks")] {
+		t.Errorf("watched = %v; want the root and objects but not hooks", watched)
+	}
+	mu.Unlock()
+
+	files := w.GetCurrentSnapshot().Files
+	for _, p := range []string{key, w.keyOf(filepath.Join(root, "HEAD")), w.keyOf(filepath.Join(root, "objects"))} {
+		if _, ok := files[p]; !ok {
+			t.Errorf("%s missing from the snapshot", p)
+		}
+	}
+	for _, p := range []string{w.keyOf(filepath.Join(root, "hooks", "pre-commit")), w.keyOf(filepath.Join(root, "sub", ".git"))} {
+		if _, ok := files[p]; ok {
+			t.Errorf("ignored entry %s in the snapshot", p)
+		}
+	}
+}
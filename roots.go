@@ -0,0 +1,163 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// resolveRoots 对监控路径去重：按真实路径(Abs + EvalSymlinks)检测嵌套与重复
+//
+// 重叠时保留最外层的路径(保持调用方给出的写法，不改写成绝对/真实路径，
+// 以免改变事件与快照中的路径形式)；reject 为 true 时返回描述性错误。
+// 路径尚不存在时无法解析符号链接，按 Abs 结果比较
+func resolveRoots(paths []string, reject bool) ([]string, error) {
+	type root struct {
+		path  string // 清理后的原始写法
+		canon string // 真实路径
+		order int
+	}
+
+	cands := make([]root, 0, len(paths))
+	for i, p := range paths {
+		clean := filepath.Clean(p)
+		canon := clean
+		if abs, err := filepath.Abs(clean); err == nil {
+			canon = abs
+		}
+		if real, err := filepath.EvalSymlinks(canon); err == nil {
+			canon = real
+		}
+		cands = append(cands, root{path: clean, canon: canon, order: i})
+	}
+
+	// 外层路径更短，按真实路径长度升序处理即可保证先保留外层
+	sort.SliceStable(cands, func(i, j int) bool { return len(cands[i].canon) < len(cands[j].canon) })
+
+	var kept []root
+	for _, c := range cands {
+		overlap := -1
+		for i, k := range kept {
+			if withinRoot(c.canon, k.canon) {
+				overlap = i
+				break
+			}
+		}
+		if overlap < 0 {
+			kept = append(kept, c)
+			continue
+		}
+		if reject {
+			k := kept[overlap]
+			if c.canon == k.canon {
+				return nil, fmt.Errorf("%w: watch paths %s and %s resolve to the same directory %s", ErrInvalidConfig, k.path, c.path, c.canon)
+			}
+			return nil, fmt.Errorf("%w: watch path %s (%s) is nested inside watch path %s (%s)", ErrInvalidConfig, c.path, c.canon, k.path, k.canon)
+		}
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].order < kept[j].order })
+	out := make([]string, len(kept))
+	for i, k := range kept {
+		out[i] = k.path
+	}
+	return out, nil
+}
+
+// Roots 返回去重后实际生效的监控根(保持 WatchPaths 中的写法与顺序)
+func (w *Watcher) Roots() []string {
+	return append([]string(nil), w.roots...)
+}
+
+// RootUnknown 是不属于任何监控根的路径(如重命名竞争中移出监控根的路径)在 FileEvent.Root 与 SnapshotRoots 中的归属
+const RootUnknown = "(outside watch roots)"
+
+// attributeRoot 返回 path 所属的监控根，不属于任何监控根时记录警告并返回 RootUnknown
+func (w *Watcher) attributeRoot(path string) string {
+	if root := w.rootOf(path); root != "" {
+		return root
+	}
+	w.logWarn("Path is outside every watch root", fmt.Errorf("%s: attributed to %s", path, RootUnknown))
+	return RootUnknown
+}
+
+// SnapshotRoots 返回快照 id 中各监控根(含 RootUnknown)下的条目数，没有条目的监控根不出现
+//
+// 从快照的前缀树直接读取各监控根子树中的条目数，不遍历文件表
+// 快照不存在时返回 ErrSnapshotNotFound，已换出的快照从 Store 读回
+// 并发安全
+func (w *Watcher) SnapshotRoots(id string) (map[string]int, error) {
+	sn, err := w.loadSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	t := sn.pathTrie()
+	out := make(map[string]int)
+	total := 0
+	for _, r := range w.roots {
+		if n := t.find(r).count(); n > 0 {
+			out[r] = n
+			total += n
+		}
+	}
+	// 监控根互不重叠，未被任何监控根覆盖的条目即为 RootUnknown
+	if n := t.count() - total; n > 0 {
+		out[RootUnknown] = n
+	}
+	return out, nil
+}
+
+// QueryOption 是 DiffSnapshots、FilesUnder、FilesMatching 等查询的可选条件
+type QueryOption func(*queryOptions)
+
+type queryOptions struct {
+	roots   map[string]struct{} // 只保留属于这些监控根的路径，nil 表示不过滤
+	renames bool                // DiffSnapshots 识别移动/重命名，见 DetectRenames
+	deleted bool                // 包含墓碑，见 IncludeDeleted
+	disk    bool                // VerifyManifest 与磁盘比较，见 VerifyOnDisk
+	compare CompareMode         // 比较文件条目的方式，见 CompareBy
+}
+
+// parseQuery 汇总 opts
+func parseQuery(opts []QueryOption) queryOptions {
+	var o queryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// InRoots 只返回属于给定监控根(写法同 Roots()，分隔符可以是 '/' 或本平台的形式，可包含 RootUnknown)的条目
+func InRoots(roots ...string) QueryOption {
+	return func(o *queryOptions) {
+		if o.roots == nil {
+			o.roots = make(map[string]struct{}, len(roots))
+		}
+		for _, r := range roots {
+			o.roots[r] = struct{}{}
+		}
+	}
+}
+
+// queryFilter 返回按 opts 过滤路径的函数，没有过滤条件时返回nil
+func (w *Watcher) queryFilter(opts []QueryOption) func(path string) bool {
+	o := parseQuery(opts)
+	if o.roots == nil {
+		return nil
+	}
+	roots := make(map[string]struct{}, len(o.roots))
+	for r := range o.roots {
+		if r != RootUnknown {
+			r = w.keyOf(r)
+		}
+		roots[r] = struct{}{}
+	}
+	return func(path string) bool {
+		root := w.rootOf(path)
+		if root == "" {
+			root = RootUnknown
+		}
+		_, ok := roots[root]
+		return ok
+	}
+}
@@ -0,0 +1,118 @@
+package watcher
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestResolveRoots 测试嵌套、重复与符号链接监控路径的合并
+func TestResolveRoots(t *testing.T) {
+	base := t.TempDir()
+	data := filepath.Join(base, "data")
+	sub := filepath.Join(data, "sub")
+	other := filepath.Join(base, "other")
+	_ = os.MkdirAll(sub, 0755)
+	_ = os.Mkdir(other, 0755)
+
+	link := filepath.Join(base, "link")
+	hasLink := os.Symlink(data, link) == nil
+
+	cases := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{"nested", []string{sub, data}, []string{data}},
+		{"identical", []string{data, data + string(os.PathSeparator)}, []string{data}},
+		{"disjoint", []string{data, other}, []string{data, other}},
+	}
+	if hasLink {
+		cases = append(cases,
+			struct {
+				name  string
+				paths []string
+				want  []string
+			}{"symlinked", []string{data, link}, []string{data}},
+		)
+	}
+
+	for _, c := range cases {
+		got, err := resolveRoots(c.paths, false)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: resolveRoots(%v) = %v; want %v", c.name, c.paths, got, c.want)
+		}
+		if c.name != "disjoint" {
+			if _, err := resolveRoots(c.paths, true); err == nil {
+				t.Errorf("%s: expected error when rejecting overlapping roots", c.name)
+			}
+		}
+	}
+}
+
+// TestNewWatcherRejectOverlappingRoots 测试 NewWatcher 返回重叠错误
+func TestNewWatcherRejectOverlappingRoots(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewWatcher(ConfigWatcher{
+		WatchPaths:             []string{dir, filepath.Join(dir, ".")},
+		RejectOverlappingRoots: true,
+	})
+	if err == nil {
+		t.Error("expected NewWatcher to reject duplicate watch paths")
+	}
+}
+
+// TestRootAttribution 测试事件与快照条目按监控根归属，以及差异与查询的监控根过滤
+func TestRootAttribution(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	fa, fb := filepath.Join(a, "x.txt"), filepath.Join(b, "y.txt")
+	_ = os.WriteFile(fa, []byte("a"), 0644)
+	_ = os.WriteFile(fb, []byte("b"), 0644)
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{a, b}, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	sub := w.Subscribe(16)
+	first := w.GetCurrentSnapshot().ID
+
+	w.handleFileChange(fa, fsnotify.Create)
+	w.handleFileChange(fb, fsnotify.Create)
+	for _, want := range []string{a, b} {
+		if ev := <-sub.C; ev.Root != want {
+			t.Errorf("%s: Root = %q; want %q", ev.FilePath, ev.Root, want)
+		}
+	}
+	outside := filepath.Join(t.TempDir(), "z.txt")
+	w.commitSnapshot("outside", map[string]*FileMetadata{outside: {Path: outside, Hash: "z"}}, outside)
+	if got := w.attributeRoot(outside); got != RootUnknown {
+		t.Errorf("attributeRoot(outside) = %q", got)
+	}
+
+	cur := w.GetCurrentSnapshot().ID
+	counts, err := w.SnapshotRoots(cur)
+	want := map[string]int{a: 2, b: 2, RootUnknown: 1}
+	if err != nil || !reflect.DeepEqual(counts, want) {
+		t.Errorf("SnapshotRoots = %v, %v; want %v", counts, err, want)
+	}
+
+	d, err := w.DiffSnapshots(first, cur, InRoots(b))
+	if err != nil || len(d.Added) != 2 || d.Added[0].Path != b || d.Added[1].Path != fb {
+		t.Errorf("DiffSnapshots(InRoots(b)) = %+v, %v", d, err)
+	}
+	if files, err := w.FilesMatching(cur, "*.txt", InRoots(a, RootUnknown)); err != nil || len(files) != 2 || files[0].Path == fb || files[1].Path == fb {
+		t.Errorf("FilesMatching(InRoots(a, unknown)) = %v, %v", files, err)
+	}
+	if files, err := w.FilesUnder(cur, "", InRoots(b)); err != nil || len(files) != 2 {
+		t.Errorf("FilesUnder(InRoots(b)) = %v, %v", files, err)
+	}
+}
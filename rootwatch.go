@@ -0,0 +1,109 @@
+package watcher
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 监控根的删除与替换
+//
+// 部署流水线经常整体删除并重建监控根目录，此时 inotify 的监控随旧 inode 一起失效。
+// runRootMonitor 通过两种途径发现监控根消失：fsnotify 上监控根自身的 Remove/Rename 事件，
+// 以及按 RootPollInterval 进行的周期性存在性巡检；随后持续巡检直到其重新出现，
+// 再为整棵子树重新注册监控，并提交一个对账(reconcile)快照。
+
+// runRootMonitor 巡检监控根的存在性，处理监控根的消失与恢复
+func (w *Watcher) runRootMonitor() {
+	defer w.bgWG.Done()
+
+	lost := make(map[string]bool)
+	ticker := w.clock.NewTicker(w.cfg.RootPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case root := <-w.rootLostChan:
+			w.markRootLost(w.keyOf(root), lost)
+
+		case <-w.rescanChan:
+			w.rescanRoots(lost)
+
+		case <-ticker.C():
+			for _, root := range w.roots {
+				exists := w.dirExists(root)
+				switch {
+				case lost[root] && exists:
+					delete(lost, root)
+					w.recoverRoot(root)
+				case !lost[root] && !exists:
+					w.markRootLost(root, lost)
+				}
+			}
+
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+// markRootLost 记录监控根已消失：移除残留的监控，并按配置从快照中移除其下的条目
+func (w *Watcher) markRootLost(root string, lost map[string]bool) {
+	if lost[root] {
+		return
+	}
+	lost[root] = true
+	w.unwatchTree(root)
+	w.emitError(fmt.Errorf("%w: %s, waiting for it to reappear", ErrRootLost, root))
+
+	if w.cfg.KeepEntriesOnRootLoss {
+		return
+	}
+	changes := make(map[string]*FileMetadata)
+	w.readCurrentNode(func(sn *SnapshotNode) {
+		w.eachLiveUnder(sn, root, func(p string, _ *FileMetadata) { changes[p] = nil })
+	})
+	if len(changes) == 0 {
+		return
+	}
+	c := w.commitSnapshot(fmt.Sprintf("Watch root %s disappeared", root), changes, root)
+	w.emitFileEvent(root, fsnotify.Remove, c)
+}
+
+// recoverRoot 在监控根重新出现后重新注册监控，并对账其子树
+func (w *Watcher) recoverRoot(root string) {
+	w.unwatchTree(root)
+	var failures []*WatchError
+	_ = w.fs.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		p = w.keyOf(p)
+		if err != nil || !d.IsDir() || (p != root && w.isIgnored(p)) {
+			return nil
+		}
+		if err := w.addWatch(p); err != nil {
+			failures = append(failures, &WatchError{Path: p, Err: err})
+		}
+		return nil
+	})
+	if len(failures) > 0 {
+		w.emitError(&PartialWatchError{Failures: failures})
+	}
+	w.reconcile(root, fmt.Sprintf("Reconcile after watch root %s reappeared", root))
+}
+
+// unwatchTree 移除 root 及其子目录上残留的监控(监控根被移走时旧 inode 上的监控仍然有效)
+func (w *Watcher) unwatchTree(root string) {
+	w.watches.release(root)
+	w.forgetWatch(root)
+	for _, p := range w.fsWatcher.WatchList() {
+		if withinRoot(w.keyOf(p), root) {
+			_ = w.fsWatcher.Remove(p)
+		}
+	}
+}
+
+// dirExists 判断路径是否存在且为目录
+func (w *Watcher) dirExists(path string) bool {
+	fi, err := w.fs.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor 在超时前反复检查条件
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+// TestWatchRootRecreated 测试监控根被删除后重建，事件能够恢复
+func TestWatchRootRecreated(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	_ = os.Mkdir(root, 0755)
+
+	w, err := NewWatcher(ConfigWatcher{
+		WatchPaths:       []string{root},
+		Debounce:         5 * time.Millisecond,
+		RootPollInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer w.Stop()
+
+	before := filepath.Join(root, "before.txt")
+	_ = os.WriteFile(before, []byte("x"), 0644)
+	if !waitFor(t, 2*time.Second, func() bool { _, ok := w.GetCurrentSnapshot().Files[before]; return ok }) {
+		t.Fatal("file written before removal was not recorded")
+	}
+
+	_ = os.RemoveAll(root)
+	if !waitFor(t, 2*time.Second, func() bool { _, ok := w.GetCurrentSnapshot().Files[root]; return !ok }) {
+		t.Fatal("entries under a removed root should be dropped")
+	}
+
+	_ = os.Mkdir(root, 0755)
+	if !waitFor(t, 2*time.Second, func() bool { _, ok := w.GetCurrentSnapshot().Files[root]; return ok }) {
+		t.Fatal("recreated root should be reconciled into the snapshot")
+	}
+
+	after := filepath.Join(root, "after.txt")
+	_ = os.WriteFile(after, []byte("y"), 0644)
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case evt := <-w.EventChan:
+			if evt.FilePath == after {
+				return
+			}
+		case <-deadline:
+			t.Fatal("events did not resume after the root was recreated")
+		}
+	}
+}
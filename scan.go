@@ -0,0 +1,221 @@
+package watcher
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scanProgressInterval 是 ScanProgress 回调的最小调用间隔
+const scanProgressInterval = 500 * time.Millisecond
+
+// ScanStatus 表示初始扫描的进度
+//
+// Total 在目录遍历完成前未知，此时为 -1；遍历完成后变为确切的条目数
+type ScanStatus struct {
+	Running     bool          // 扫描是否进行中
+	Scanned     int64         // 已采集完成的条目数
+	Total       int64         // 条目总数(未知时为 -1)
+	BytesHashed int64         // 已哈希的字节数
+	CurrentPath string        // 最近一个完成采集的路径
+	StartedAt   time.Time     // 扫描开始时间(未扫描时为零值)
+	Elapsed     time.Duration // 已耗时(扫描结束后为总耗时)
+}
+
+// scanProgress 保存扫描进度，全部用原子变量，更新时不会让并发的哈希worker互相等待
+type scanProgress struct {
+	running     atomic.Bool
+	scanned     atomic.Int64
+	total       atomic.Int64
+	bytesHashed atomic.Int64
+	current     atomic.Pointer[string]
+	startedAt   atomic.Int64 // UnixNano
+	finishedAt  atomic.Int64 // UnixNano
+}
+
+// ScanStatus 返回初始扫描(ScanOnStart)的进度，可在扫描进行中随时调用
+//
+// 并发安全
+func (w *Watcher) ScanStatus() ScanStatus {
+	sp := &w.scan
+	st := ScanStatus{
+		Running:     sp.running.Load(),
+		Scanned:     sp.scanned.Load(),
+		Total:       sp.total.Load(),
+		BytesHashed: sp.bytesHashed.Load(),
+	}
+	if cur := sp.current.Load(); cur != nil {
+		st.CurrentPath = *cur
+	}
+	if started := sp.startedAt.Load(); started != 0 {
+		st.StartedAt = time.Unix(0, started)
+		end := w.now()
+		if !st.Running {
+			end = time.Unix(0, sp.finishedAt.Load())
+		}
+		st.Elapsed = end.Sub(st.StartedAt)
+	}
+	return st
+}
+
+// scanBaseline 遍历所有监控根，借助 workerPool 并发采集文件元信息，
+// 并以一个快照的形式提交(即基线快照)
+//
+// 进度可通过 ScanStatus() 查询，配置了 cfg.ScanProgress 时按节流间隔回调
+// 扫描过程中 Watcher 被停止时放弃提交并返回 false
+func (w *Watcher) scanBaseline() bool {
+	sp := &w.scan
+	sp.total.Store(-1)
+	sp.startedAt.Store(w.now().UnixNano())
+	sp.running.Store(true)
+
+	stopReport := make(chan struct{})
+	reportDone := make(chan struct{})
+	go w.reportScanProgress(stopReport, reportDone)
+
+	changes, ok := w.collectEntries(w.roots, sp)
+
+	sp.finishedAt.Store(w.now().UnixNano())
+	sp.running.Store(false)
+	close(stopReport)
+	<-reportDone
+
+	if !ok {
+		return false
+	}
+	c := w.commitSnapshot(fmt.Sprintf("Baseline snapshot (%d entries)", len(changes)), changes, "")
+	w.emitCommitted(c, w.baselineEvents(changes)...)
+	return true
+}
+
+// baselineEvents 构造基线快照的事件：ScanEvents 时按路径顺序为每个条目一个 OpScan 事件，最后是一个 OpBaseline 事件
+func (w *Watcher) baselineEvents(entries map[string]*FileMetadata) []FileEvent {
+	now := w.now()
+	var evs []FileEvent
+	if w.cfg.ScanEvents {
+		paths := make([]string, 0, len(entries))
+		for p := range entries {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		evs = make([]FileEvent, 0, len(paths)+1)
+		for _, p := range paths {
+			evs = append(evs, FileEvent{FilePath: p, Root: w.attributeRoot(p), Kind: OpScan, Op: OpScan.fsnotifyOp(), NewMeta: entries[p], Processed: now})
+		}
+	}
+	return append(evs, FileEvent{Kind: OpBaseline, Entries: len(entries), Processed: now})
+}
+
+// collectEntries 遍历给定的根路径，借助 workerPool 并发采集其下所有条目(含根本身)的元信息
+//
+// 遵循忽略规则与 MaxHashSize；目录遍历不跟随符号链接，符号链接本身按其指向的目标 stat
+// sp 非空时更新扫描进度；Watcher 被停止时中断遍历并返回 ok=false
+func (w *Watcher) collectEntries(roots []string, sp *scanProgress) (entries map[string]*FileMetadata, ok bool) {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		found int64
+	)
+	entries = make(map[string]*FileMetadata)
+
+	for _, root := range roots {
+		_ = w.fs.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			p = w.keyOf(p)
+			select {
+			case <-w.stopChan:
+				return filepath.SkipAll
+			default:
+			}
+			if err != nil {
+				if d != nil && d.IsDir() && isDenied(err) {
+					w.denyDir(p, err) // 子树保留快照中已有的条目，见 access.go
+					return nil
+				}
+				w.emitError(fmt.Errorf("scan of %s failed: %w", p, err))
+				return nil
+			}
+			if p != root && w.isIgnored(p) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			found++
+
+			w.acquireWorker()
+			wg.Add(1)
+			go func(path string) {
+				defer func() {
+					<-w.workerPool
+					wg.Done()
+				}()
+				if sp != nil {
+					defer func() {
+						sp.scanned.Add(1)
+						sp.current.Store(&path)
+					}()
+				}
+				fi, err := w.fs.Stat(path)
+				if err != nil {
+					return
+				}
+				meta := w.buildMeta(path, fi, nil, nil)
+				if meta == nil {
+					return
+				}
+				if sp != nil && meta.HashState == HashStateHashed && !meta.IsDirectory {
+					sp.bytesHashed.Add(meta.Size)
+				}
+				mu.Lock()
+				entries[path] = meta
+				mu.Unlock()
+			}(p)
+			return nil
+		})
+	}
+	if sp != nil {
+		sp.total.Store(found)
+	}
+	wg.Wait()
+	// 目录条目可能在发现其不可读之前就已构造
+	for _, dir := range w.denied.list() {
+		if m := entries[dir]; m != nil {
+			m.Inaccessible = true
+		}
+	}
+
+	select {
+	case <-w.stopChan:
+		return nil, false
+	default:
+	}
+	return entries, true
+}
+
+// reportScanProgress 按 scanProgressInterval 节流调用 cfg.ScanProgress，扫描结束时再调用一次
+func (w *Watcher) reportScanProgress(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	if w.cfg.ScanProgress == nil {
+		return
+	}
+	report := func() {
+		st := w.ScanStatus()
+		w.cfg.ScanProgress(st.Scanned, st.Total, st.CurrentPath)
+	}
+
+	ticker := w.clock.NewTicker(scanProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			report()
+		case <-stop:
+			report()
+			return
+		}
+	}
+}
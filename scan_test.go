@@ -0,0 +1,214 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestScanOnStart 测试启动时生成基线快照
+func TestScanOnStart(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	_ = os.Mkdir(sub, 0755)
+	a := filepath.Join(root, "a.txt")
+	b := filepath.Join(sub, "b.txt")
+	_ = os.WriteFile(a, []byte("a"), 0644)
+	_ = os.WriteFile(b, []byte("b"), 0644)
+
+	w, err := NewWatcher(ConfigWatcher{
+		WatchPaths:  []string{root},
+		Debounce:    5 * time.Millisecond,
+		ScanOnStart: true,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	initial := w.GetCurrentSnapshot()
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer w.Stop()
+	<-w.Ready()
+
+	baseline := w.GetCurrentSnapshot()
+	if len(baseline.ParentIDs) != 1 || baseline.ParentIDs[0] != initial.ID {
+		t.Errorf("baseline should be a child of the initial snapshot")
+	}
+	for _, p := range []string{root, sub, a, b} {
+		if _, ok := baseline.Files[p]; !ok {
+			t.Errorf("baseline missing %s", p)
+		}
+	}
+	if baseline.Files[b].Hash == "" || baseline.RootHash == "" {
+		t.Errorf("baseline should contain hashes")
+	}
+}
+
+// TestBaselineEvent 测试基线快照提交后发送一个 OpBaseline 事件，ScanEvents 时之前按路径顺序为每个条目发送 OpScan 事件，
+// 之后的变更序号都大于基线事件
+func TestBaselineEvent(t *testing.T) {
+	for _, scanEvents := range []bool{false, true} {
+		root := t.TempDir()
+		_ = os.Mkdir(filepath.Join(root, "sub"), 0755)
+		_ = os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644)
+		_ = os.WriteFile(filepath.Join(root, "sub", "b.txt"), []byte("b"), 0644)
+		opts := []Option{WithScanOnStart(nil), WithDebounce(5 * time.Millisecond), WithDisableEventChan()}
+		if scanEvents {
+			opts = append(opts, WithScanEvents())
+		}
+		w, err := NewWatcherWithOptions([]string{root}, opts...)
+		if err != nil {
+			t.Fatalf("NewWatcherWithOptions failed: %v", err)
+		}
+		sub := w.Subscribe(16)
+		if err := w.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		<-w.Ready()
+
+		var scanned []string
+		var base FileEvent
+		for base.Kind == 0 {
+			select {
+			case ev := <-sub.C:
+				switch {
+				case ev.Kind == OpScan && ev.NewMeta != nil && ev.Kind.IsCreate():
+					scanned = append(scanned, ev.FilePath)
+				case ev.Kind == OpBaseline:
+					base = ev
+				default:
+					t.Fatalf("scanEvents=%v: unexpected event %s %s before the baseline", scanEvents, ev.Kind, ev.FilePath)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("scanEvents=%v: no baseline event", scanEvents)
+			}
+		}
+		if base.NewSnap != w.GetCurrentSnapshot() || base.Entries != 4 || base.FilePath != "" || !base.Kind.IsScan() {
+			t.Errorf("baseline event = %+v; want the current snapshot with 4 entries", base)
+		}
+		want := "[]"
+		if scanEvents {
+			want = fmt.Sprint([]string{w.keyOf(root), w.keyOf(filepath.Join(root, "a.txt")), w.keyOf(filepath.Join(root, "sub")), w.keyOf(filepath.Join(root, "sub", "b.txt"))})
+		}
+		if got := fmt.Sprint(scanned); got != want || base.Seq != uint64(len(scanned)+1) {
+			t.Errorf("scanEvents=%v: scan events %s before baseline seq %d; want %s", scanEvents, got, base.Seq, want)
+		}
+
+		_ = os.WriteFile(filepath.Join(root, "c.txt"), []byte("c"), 0644)
+		select {
+		case ev := <-sub.C:
+			if ev.Seq <= base.Seq || ev.Kind.IsScan() {
+				t.Errorf("live event %s seq %d; want a non-scan event after %d", ev.Kind, ev.Seq, base.Seq)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no live event after the baseline")
+		}
+		_ = w.Close()
+	}
+}
+
+// TestScanProgress 测试扫描进度回调与 ScanStatus
+func TestScanProgress(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		_ = os.WriteFile(filepath.Join(root, name), []byte(name), 0644)
+	}
+
+	var lastScanned, lastTotal int64
+	w, err := NewWatcher(ConfigWatcher{
+		WatchPaths:  []string{root},
+		ScanOnStart: true,
+		ScanProgress: func(scanned, total int64, _ string) {
+			lastScanned, lastTotal = scanned, total
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if st := w.ScanStatus(); st.Running || !st.StartedAt.IsZero() {
+		t.Errorf("status before scan should be empty, got %+v", st)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer w.Stop()
+	if err := w.WaitReady(context.Background()); err != nil {
+		t.Fatalf("WaitReady failed: %v", err)
+	}
+
+	// 根目录 + 3 个文件
+	if lastScanned != 4 || lastTotal != 4 {
+		t.Errorf("final progress = %d/%d; want 4/4", lastScanned, lastTotal)
+	}
+	st := w.ScanStatus()
+	if st.Running || st.Scanned != 4 || st.Total != 4 || st.BytesHashed != 3 || st.Elapsed <= 0 {
+		t.Errorf("unexpected final status %+v", st)
+	}
+}
+
+// TestReadyWithoutScan 测试未开启扫描时 Ready 在 Start 后立即关闭
+func TestReadyWithoutScan(t *testing.T) {
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{t.TempDir()}})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	select {
+	case <-w.Ready():
+		t.Fatal("Ready should not be closed before Start")
+	default:
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer w.Stop()
+	select {
+	case <-w.Ready():
+	default:
+		t.Error("Ready should be closed right after Start when ScanOnStart is false")
+	}
+}
+
+// concurrencyFS 记录同时进行的 Stat 调用数的峰值
+type concurrencyFS struct {
+	FS
+	active atomic.Int64
+	peak   atomic.Uint64
+}
+
+func (c *concurrencyFS) Stat(name string) (fs.FileInfo, error) {
+	observeHighWater(&c.peak, uint64(c.active.Add(1)))
+	defer c.active.Add(-1)
+	time.Sleep(time.Millisecond)
+	return c.FS.Stat(name)
+}
+
+// TestScanRespectsWorkerLimit 测试 workerPool 的容量大于有效上限(WorkerAutoTune)时，Start 后立即开始的扫描也不超出上限
+func TestScanRespectsWorkerLimit(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 100; i++ {
+		_ = os.WriteFile(filepath.Join(root, fmt.Sprintf("f%d.txt", i)), []byte("x"), 0644)
+	}
+	fsys := &concurrencyFS{FS: osFS{}}
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(fsys, replaySource{}), WithDisableEventChan(),
+		WithWorkerCount(2), WithWorkerAutoTune(1, 32), WithScanOnStart(nil))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	fsys.peak.Store(0) // 只统计扫描
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := w.WaitReady(context.Background()); err != nil {
+		t.Fatalf("WaitReady failed: %v", err)
+	}
+	if n := fsys.peak.Load(); n > 2 {
+		t.Errorf("scan ran %d Stat calls at once; want at most the worker limit 2", n)
+	}
+}
@@ -0,0 +1,91 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 本进程写入的抑制
+//
+// 使用方在写入监控树之前调用 MarkSelfWrite 标记路径，之后 SelfWriteWindow 内该路径的事件在进入合并表之前丢弃，
+// 不产生事件也不进入快照。一次写入通常产生多个事件(Create、Write、Chmod)，所以标记在窗口内持续有效而不是只抵消一个事件；
+// 窗口到期后标记自动失效，标记之后没有写入(如进程崩溃)也不会永久屏蔽该路径。
+// Watcher 自己写入的 Store 目录与审计日志不需要标记，位于监控树中时被自动忽略(见 ownpaths.go)
+
+// DefaultSelfWriteWindow 是 ConfigWatcher.SelfWriteWindow 的默认值
+const DefaultSelfWriteWindow = 2 * time.Second
+
+// selfWritePruneSize 是触发清理过期标记的标记数
+const selfWritePruneSize = 1024
+
+// selfWrites 记录本进程写入的路径及其标记的过期时间
+type selfWrites struct {
+	mu    sync.Mutex
+	paths map[string]time.Time // 路径 → 过期时间
+	n     atomic.Int64         // len(paths)，避免没有标记时加锁
+
+	suppressed atomic.Uint64 // 丢弃的事件数
+}
+
+// MarkSelfWrite 标记本进程即将写入(创建、修改、删除或重命名) path，
+// 之后 SelfWriteWindow 内该路径的事件被丢弃，计入 Stats().SelfEventsSuppressed
+//
+// 被丢弃的变更不进入快照，快照中该路径保持写入前的状态，直到窗口之后的事件或对账再次发现它；
+// 写入持续时间超过窗口时应在写入完成后再标记一次。新建目录仍会注册监控，其中之后的(未标记的)变更照常处理
+// 并发安全
+func (w *Watcher) MarkSelfWrite(path string) {
+	if path == "" {
+		return
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	w.self.mark(w.keyOf(path), w.now().Add(w.cfg.SelfWriteWindow))
+}
+
+// mark 记录 key 的标记，过期时间为 until
+func (s *selfWrites) mark(key string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paths == nil {
+		s.paths = make(map[string]time.Time)
+	}
+	s.paths[key] = until
+	if len(s.paths) >= selfWritePruneSize {
+		s.pruneLocked(until.Add(-time.Nanosecond))
+	}
+	s.n.Store(int64(len(s.paths)))
+}
+
+// pruneLocked 删除在 now 之前过期的标记
+func (s *selfWrites) pruneLocked(now time.Time) {
+	for p, until := range s.paths {
+		if !now.Before(until) {
+			delete(s.paths, p)
+		}
+	}
+}
+
+// suppressSelf 报告 path 的事件是否因本进程写入的标记而应丢弃，丢弃时计数
+func (w *Watcher) suppressSelf(path string) bool {
+	s := &w.self
+	if s.n.Load() == 0 {
+		return false
+	}
+	now := w.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.paths[path]
+	if !ok {
+		return false
+	}
+	if !now.Before(until) {
+		delete(s.paths, path)
+		s.n.Store(int64(len(s.paths)))
+		return false
+	}
+	s.suppressed.Add(1)
+	return true
+}
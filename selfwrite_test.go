@@ -0,0 +1,48 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestMarkSelfWrite 测试标记的路径在窗口内的事件全部被丢弃并计数，窗口过期后恢复，其它路径不受影响
+func TestMarkSelfWrite(t *testing.T) {
+	root := t.TempDir()
+	clock := &manualClock{}
+	clock.now.Store(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithClock(clock),
+		WithDisableEventChan(), WithSelfWriteWindow(time.Second))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	cache := filepath.Join(root, "cache.bin")
+	other := filepath.Join(root, "other.txt")
+	w.MarkSelfWrite(cache)
+	for _, op := range []fsnotify.Op{fsnotify.Create, fsnotify.Write, fsnotify.Chmod} {
+		w.handleFsEvent(fsnotify.Event{Name: cache, Op: op})
+	}
+	if n := len(w.aggChan); n != 0 {
+		t.Fatalf("%d events queued for a marked path; want none", n)
+	}
+	if n := w.Stats().SelfSuppressed; n != 3 {
+		t.Fatalf("SelfSuppressed = %d; want 3", n)
+	}
+	w.handleFsEvent(fsnotify.Event{Name: other, Op: fsnotify.Create})
+	if ev := <-w.aggChan; ev.Name != w.keyOf(other) {
+		t.Fatalf("queued %q; want %q", ev.Name, other)
+	}
+
+	clock.advance(time.Second)
+	w.handleFsEvent(fsnotify.Event{Name: cache, Op: fsnotify.Write})
+	if n := len(w.aggChan); n != 1 {
+		t.Fatalf("%d events queued after the mark expired; want 1", n)
+	}
+	if n := w.self.n.Load(); n != 0 {
+		t.Fatalf("%d marks left after expiry; want 0", n)
+	}
+}
@@ -0,0 +1,188 @@
+package watcher_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchertest"
+)
+
+// TestStopDrainsDeliveredEvents 测试 Stop 前已送达但尚未读取的事件进入最终快照
+func TestStopDrainsDeliveredEvents(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		h := watchertest.NewHarness(t)
+		// 直接写 MemFS：事件已在 Events 通道中，但不等待 Watcher 读取
+		for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+			if err := h.FS.WriteFile(h.Path(name), []byte(name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := h.W.Close(); err != nil {
+			t.Fatalf("Close returned %v", err)
+		}
+		files := h.W.GetCurrentSnapshot().Files
+		for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+			if files[h.Path(name)] == nil {
+				t.Fatalf("run %d: %s written before Stop missing from final snapshot", i, name)
+			}
+		}
+	}
+}
+
+// TestStopRightAfterWrite 测试写入后立即 Stop 不会 panic 或泄漏goroutine
+func TestStopRightAfterWrite(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		root := t.TempDir()
+		w, err := watcher.NewWatcherWithOptions([]string{root}, watcher.WithDisableEventChan())
+		if err != nil {
+			t.Fatalf("NewWatcherWithOptions failed: %v", err)
+		}
+		if err := w.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		_ = os.WriteFile(filepath.Join(root, "late.txt"), []byte("x"), 0644)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close returned %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines: %d before, %d after Stop", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestNoGoroutineLeak 测试反复创建、启动(处理中的变更、初始扫描、优先级车道)并关闭 Watcher 后goroutine数不增长
+func TestNoGoroutineLeak(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 20; i++ {
+		_ = os.WriteFile(filepath.Join(root, fmt.Sprintf("f%d.txt", i)), make([]byte, 512*i), 0644)
+	}
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		w, err := watcher.NewWatcherWithOptions([]string{root},
+			watcher.WithDisableEventChan(),
+			watcher.WithDebounce(time.Millisecond),
+			watcher.WithScanOnStart(nil),
+			watcher.WithPriority(watcher.SmallFilesFirst(4096)),
+		)
+		if err != nil {
+			t.Fatalf("NewWatcherWithOptions failed: %v", err)
+		}
+		if err := w.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		for j := 0; j < 5; j++ {
+			_ = os.WriteFile(filepath.Join(root, fmt.Sprintf("f%d.txt", i%20)), make([]byte, 512*j), 0644)
+		}
+		if i%2 == 0 {
+			time.Sleep(2 * time.Millisecond) // 让一部分 Watcher 在 worker 处理中被关闭
+		}
+		_ = w.Close()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("goroutines: %d before, %d after\n%s", before, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestMutationsAfterClose 测试 Close 之后修改状态的方法返回 ErrStopped，不提交快照，EventChan 已关闭也不会 panic
+func TestMutationsAfterClose(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+	w, err := watcher.NewWatcherWithOptions([]string{root}, watcher.WithScanOnStart(nil))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	go func() {
+		for range w.EventChan {
+		}
+	}()
+	if err := w.WaitReady(context.Background()); err != nil {
+		t.Fatalf("WaitReady failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	_ = os.WriteFile(file, []byte("changed"), 0644)
+	head := w.GetCurrentSnapshot()
+
+	calls := map[string]func() error{
+		"RehashFile": func() error { _, _, err := w.RehashFile(file); return err },
+		"ApplyExternalChanges": func() error {
+			_, err := w.ApplyExternalChanges([]watcher.ExternalChange{{Path: filepath.Join(root, "b.txt"), Meta: &watcher.FileMetadata{Size: 1}}}, "")
+			return err
+		},
+		"BackfillHashes":         func() error { _, err := w.BackfillHashes(context.Background(), nil); return err },
+		"SetSnapshotDescription": func() error { return w.SetSnapshotDescription(head.ID, "late") },
+		"RepairStore":            func() error { _, err := w.RepairStore(); return err },
+		"RetryFailedWatches":     func() error { _, err := w.RetryFailedWatches(); return err },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, watcher.ErrStopped) {
+			t.Errorf("%s after Close = %v; want ErrStopped", name, err)
+		}
+	}
+	if cur := w.GetCurrentSnapshot(); cur != head || cur.Description == "late" {
+		t.Errorf("snapshot changed after Close: %s %q", cur.ID, cur.Description)
+	}
+}
+
+// TestMutationsDuringClose 测试与 Close 并发的修改调用要么在关闭通道前完成，要么返回 ErrStopped(配合 -race)
+func TestMutationsDuringClose(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		root := t.TempDir()
+		file := filepath.Join(root, "a.txt")
+		w, err := watcher.NewWatcherWithOptions([]string{root})
+		if err != nil {
+			t.Fatalf("NewWatcherWithOptions failed: %v", err)
+		}
+		if err := w.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		go func() {
+			for range w.EventChan {
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for j := 0; ; j++ {
+					_ = os.WriteFile(file, []byte(fmt.Sprint(g, j)), 0644)
+					if _, _, err := w.RehashFile(file); errors.Is(err, watcher.ErrStopped) {
+						return
+					}
+				}
+			}(g)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close returned %v", err)
+		}
+		wg.Wait()
+	}
+}
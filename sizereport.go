@@ -0,0 +1,222 @@
+package watcher
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// SizeBucketBounds 是文件大小直方图的桶上界(字节，含上界)：1KiB 起每档乘以 4，直到 4GiB
+var SizeBucketBounds = []int64{
+	1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10,
+	1 << 20, 4 << 20, 16 << 20, 64 << 20, 256 << 20,
+	1 << 30, 4 << 30,
+}
+
+// SizeHistogram 是快照中文件大小的分布
+//
+// Counts[i]/Bytes[i] 为大小落入 (Bounds[i-1], Bounds[i]] 区间的文件数与字节数(非累计)，
+// 比 Bounds 多一个元素，最后一个为超过最大上界的文件；比较两个快照时为两者之差(可为负)
+type SizeHistogram struct {
+	Bounds []int64 `json:"bounds"`
+	Counts []int   `json:"counts"`
+	Bytes  []int64 `json:"bytes"`
+}
+
+// SizeEntry 是 SizeReport 中的单个文件
+type SizeEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// SizeReport 汇总快照中文件的大小，可直接序列化为 JSON
+//
+// 只统计文件，目录与墓碑不计入
+type SizeReport struct {
+	SnapshotID string        `json:"snapshot_id"`
+	Files      int           `json:"files"`
+	TotalBytes int64         `json:"total_bytes"`
+	Histogram  SizeHistogram `json:"histogram"`
+
+	// Largest 是最大的至多 topN 个文件，按大小从大到小排序(相同时按路径)
+	Largest []SizeEntry `json:"largest,omitempty"`
+}
+
+// SizeGrowthReport 比较两个快照的文件大小，可直接序列化为 JSON
+//
+// Histogram 为各桶文件数与字节数之差(to 减 from)；Growth 是变大最多的至多 topN 个文件(新增的文件按其大小计)，
+// 按 SizeDelta 从大到小排序(相同时按路径)，变小或删除的文件只体现在 NetBytes 与 Histogram 中
+type SizeGrowthReport struct {
+	FromID    string        `json:"from_id"`
+	ToID      string        `json:"to_id"`
+	FromFiles int           `json:"from_files"`
+	ToFiles   int           `json:"to_files"`
+	FromBytes int64         `json:"from_bytes"`
+	ToBytes   int64         `json:"to_bytes"`
+	NetBytes  int64         `json:"net_bytes"`
+	Histogram SizeHistogram `json:"histogram"`
+
+	Growth []DriftChange `json:"growth,omitempty"`
+}
+
+// sizeStats 是快照中文件大小的汇总，快照发布后不可变，按快照缓存(见 SnapshotNode.sizeStats)
+type sizeStats struct {
+	files  int
+	total  int64
+	counts []int
+	bytes  []int64
+}
+
+// sizeBucket 返回大小所在的桶
+func sizeBucket(size int64) int {
+	return sort.Search(len(SizeBucketBounds), func(i int) bool { return size <= SizeBucketBounds[i] })
+}
+
+// sizeFile 报告条目是否计入大小统计：未删除的文件
+func sizeFile(m *FileMetadata) bool {
+	return m != nil && !m.IsDirectory && !m.Deleted
+}
+
+// sizeStats 返回快照的大小汇总，第一次调用时遍历文件表并缓存
+func (sn *SnapshotNode) sizeStats() *sizeStats {
+	sn.sizeOnce.Do(func() {
+		s := &sizeStats{
+			counts: make([]int, len(SizeBucketBounds)+1),
+			bytes:  make([]int64, len(SizeBucketBounds)+1),
+		}
+		for _, m := range sn.Files {
+			if !sizeFile(m) {
+				continue
+			}
+			b := sizeBucket(m.Size)
+			s.files++
+			s.total += m.Size
+			s.counts[b]++
+			s.bytes[b] += m.Size
+		}
+		sn.sizes = s
+	})
+	return sn.sizes
+}
+
+// histogram 返回汇总的直方图(复制，调用方可修改)
+func (s *sizeStats) histogram() SizeHistogram {
+	return SizeHistogram{
+		Bounds: append([]int64(nil), SizeBucketBounds...),
+		Counts: append([]int(nil), s.counts...),
+		Bytes:  append([]int64(nil), s.bytes...),
+	}
+}
+
+// SizeReport 返回快照中文件的总大小、大小分布与最大的 topN 个文件(topN 不大于0时不列出)
+//
+// 总量与分布按快照缓存，重复调用只需选出最大的文件：遍历文件表时只保留 topN 个候选，不复制文件表。
+// 已换出到 Store 的快照会被读回；快照不存在时返回 ErrSnapshotNotFound
+// 并发安全
+func (w *Watcher) SizeReport(snapshotID string, topN int) (*SizeReport, error) {
+	sn, err := w.loadSnapshot(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	s := sn.sizeStats()
+	return &SizeReport{
+		SnapshotID: sn.ID,
+		Files:      s.files,
+		TotalBytes: s.total,
+		Histogram:  s.histogram(),
+		Largest:    largestFiles(sn.Files, topN),
+	}, nil
+}
+
+// SizeGrowth 比较 fromID 与 toID 两个快照的文件大小，列出变大最多的 topN 个文件(topN 不大于0时不列出)
+//
+// 总量与分布之差来自两个快照缓存的汇总；逐个文件的变化按目录哈希逐层比较得到，哈希相同的子树被整体跳过。
+// 快照不存在时返回的错误同 DiffSnapshots
+// 并发安全
+func (w *Watcher) SizeGrowth(fromID, toID string, topN int) (*SizeGrowthReport, error) {
+	from, err := w.loadSnapshot(fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := w.loadSnapshot(toID)
+	if err != nil {
+		return nil, err
+	}
+	fs, ts := from.sizeStats(), to.sizeStats()
+	r := &SizeGrowthReport{
+		FromID: from.ID, ToID: to.ID,
+		FromFiles: fs.files, ToFiles: ts.files,
+		FromBytes: fs.total, ToBytes: ts.total,
+		NetBytes:  ts.total - fs.total,
+		Histogram: ts.histogram(),
+	}
+	for i := range r.Histogram.Counts {
+		r.Histogram.Counts[i] -= fs.counts[i]
+		r.Histogram.Bytes[i] -= fs.bytes[i]
+	}
+	if topN <= 0 {
+		return r, nil
+	}
+	var growth []DriftChange
+	_ = walkDiffBy(from, to, CompareHashAndSize, func(e DiffEntry) error {
+		if c, ok := driftChange(e); ok && c.SizeDelta > 0 {
+			growth = append(growth, c)
+		}
+		return nil
+	})
+	sort.Slice(growth, func(i, j int) bool {
+		if growth[i].SizeDelta != growth[j].SizeDelta {
+			return growth[i].SizeDelta > growth[j].SizeDelta
+		}
+		return growth[i].Path < growth[j].Path
+	})
+	if len(growth) > topN {
+		growth = growth[:topN]
+	}
+	r.Growth = growth
+	return r, nil
+}
+
+// largestFiles 返回 files 中最大的至多 n 个文件，按大小从大到小排序(相同时按路径)
+func largestFiles(files map[string]*FileMetadata, n int) []SizeEntry {
+	if n <= 0 {
+		return nil
+	}
+	h := &sizeHeap{}
+	for p, m := range files {
+		if !sizeFile(m) {
+			continue
+		}
+		e := SizeEntry{Path: p, Size: m.Size}
+		if h.Len() < n {
+			heap.Push(h, e)
+		} else if bigger(e, (*h)[0]) {
+			(*h)[0] = e
+			heap.Fix(h, 0)
+		}
+	}
+	out := []SizeEntry(*h)
+	sort.Slice(out, func(i, j int) bool { return bigger(out[i], out[j]) })
+	return out
+}
+
+// bigger 是大文件列表的排序：大小从大到小，相同时按路径
+func bigger(a, b SizeEntry) bool {
+	if a.Size != b.Size {
+		return a.Size > b.Size
+	}
+	return a.Path < b.Path
+}
+
+// sizeHeap 是按 bigger 排序的最小堆，堆顶是候选中最"小"的文件
+type sizeHeap []SizeEntry
+
+func (h sizeHeap) Len() int           { return len(h) }
+func (h sizeHeap) Less(i, j int) bool { return bigger(h[j], h[i]) }
+func (h sizeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *sizeHeap) Push(x any)        { *h = append(*h, x.(SizeEntry)) }
+func (h *sizeHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestSizeReport 测试大小分布、总量与最大文件：目录不计入，同样大小按路径排序，汇总按快照缓存
+func TestSizeReport(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, root)
+	write := func(name string, size int) string {
+		p := filepath.Join(root, name)
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		_ = os.WriteFile(p, []byte(strings.Repeat("x", size)), 0644)
+		w.handleFileChange(filepath.Dir(p), fsnotify.Create)
+		w.handleFileChange(p, fsnotify.Create)
+		return p
+	}
+	small := write("small.txt", 10)
+	mid := write("sub/mid.bin", 5000)
+	write("sub/a.bin", 2000)
+	b := write("sub/b.bin", 2000)
+	from := w.GetCurrentSnapshot()
+
+	rep, err := w.SizeReport(from.ID, 3)
+	if err != nil {
+		t.Fatalf("SizeReport failed: %v", err)
+	}
+	if rep.SnapshotID != from.ID || rep.Files != 4 || rep.TotalBytes != 9010 {
+		t.Errorf("report %+v; want 4 files, 9010 bytes", rep)
+	}
+	h := rep.Histogram
+	if len(h.Counts) != len(SizeBucketBounds)+1 || h.Counts[0] != 1 || h.Counts[1] != 2 || h.Counts[2] != 1 || h.Bytes[1] != 4000 {
+		t.Errorf("histogram %+v; want 1, 2 and 1 files in the first three buckets", h)
+	}
+	if len(rep.Largest) != 3 || rep.Largest[0].Path != mid || rep.Largest[1].Path != filepath.Join(root, "sub", "a.bin") || rep.Largest[2].Path != b {
+		t.Errorf("largest %+v; want mid, a, b", rep.Largest)
+	}
+	if again, _ := w.SizeReport(from.ID, 0); again.Largest != nil || from.sizes == nil || again.TotalBytes != rep.TotalBytes {
+		t.Errorf("topN 0 should list nothing and reuse the cached totals: %+v", again)
+	}
+
+	_ = os.WriteFile(small, []byte(strings.Repeat("x", 100000)), 0644)
+	w.handleFileChange(small, fsnotify.Write)
+	big := write("sub/big.bin", 3000)
+	_ = os.WriteFile(mid, []byte("x"), 0644)
+	w.handleFileChange(mid, fsnotify.Write)
+	g, err := w.SizeGrowth(from.ID, w.GetCurrentSnapshot().ID, 5)
+	if err != nil {
+		t.Fatalf("SizeGrowth failed: %v", err)
+	}
+	if g.FromBytes != 9010 || g.ToBytes != 107001 || g.NetBytes != 97991 || g.FromFiles != 4 || g.ToFiles != 5 {
+		t.Errorf("growth totals %+v", g)
+	}
+	if len(g.Growth) != 2 || g.Growth[0].Path != small || g.Growth[0].SizeDelta != 99990 || g.Growth[1].Path != big || g.Growth[1].Kind != "ADDED" {
+		t.Errorf("growth %+v; want small then big", g.Growth)
+	}
+	if g.Histogram.Counts[0] != 0 || g.Histogram.Counts[2] != -1 || g.Histogram.Bytes[0] != 1-10 {
+		t.Errorf("histogram delta %+v", g.Histogram)
+	}
+	if _, err := w.SizeReport("nope", 1); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("unknown snapshot: %v; want ErrSnapshotNotFound", err)
+	}
+}
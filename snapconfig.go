@@ -0,0 +1,104 @@
+package watcher
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"reflect"
+	"slices"
+)
+
+// 快照上记录的配置
+//
+// 快照中缺少某个文件时，可能是文件不存在，也可能是当时的忽略规则排除了它；哈希为空可能是文件超过了大小上限，
+// 也可能是事件风暴降级期间没有计算。因此每个快照记录创建时生效的配置(SnapshotConfig)：影响快照内容的关键字段，
+// 以及完整有效配置的指纹。配置不变的快照共享同一个 *SnapshotConfig；运行中有效配置变化(如进入或退出事件风暴降级)后
+// 创建的快照带有新的指纹。SnapshotConfig 随快照写入 Store(DirStore)，并出现在快照的 JSON(watcherhttp)与
+// SnapshotSummary 中；从审计日志重建(ReplayEvents)的快照与旧版本写入的快照没有记录，为nil
+
+// SnapshotConfig 是快照创建时 Watcher 的有效配置中影响快照内容的部分，见 ConfigAt
+type SnapshotConfig struct {
+	// Fingerprint 是规范化后的完整有效配置(DumpState 中的 config 部分，不含实例ID，加上是否处于降级模式)
+	// 的 SHA-256 前 16 字节(十六进制)，两个快照的指纹相同即创建时的配置相同
+	Fingerprint string `json:"fingerprint"`
+
+	Roots           []string       `json:"roots"`                     // 监控根(即包含的范围)
+	IgnorePatterns  []string       `json:"ignore_patterns,omitempty"` // 忽略规则，含平台默认规则
+	NoHashPatterns  []string       `json:"no_hash_patterns,omitempty"`
+	MaxHashSize     int64          `json:"max_hash_size,omitempty"`     // 0 表示不限制
+	HashErrorPolicy string         `json:"hash_error_policy,omitempty"` // 计算哈希失败时的策略，默认 EmptyHash 时为空
+	HistoryLimits   []HistoryLimit `json:"history_limits,omitempty"`
+	Hasher          string         `json:"hasher"` // 内容哈希算法，如 SHA-256
+	IgnoreChmod     bool           `json:"ignore_chmod,omitempty"`
+	Degraded        bool           `json:"degraded,omitempty"` // 创建于事件风暴降级期间(不计算哈希)
+}
+
+// ConfigAt 返回快照创建时记录的配置，快照不存在或没有记录(旧版本写入、从审计日志重建)时返回nil
+//
+// 返回的 *SnapshotConfig 在快照之间共享，调用方不应修改
+// 并发安全
+func (w *Watcher) ConfigAt(snapshotID string) *SnapshotConfig {
+	if sn := w.snapshotByID(snapshotID); sn != nil {
+		return sn.Config
+	}
+	if w.cfg.DisableSnapshots {
+		if cur := w.GetCurrentSnapshot(); cur != nil && cur.ID == snapshotID {
+			return cur.Config
+		}
+	}
+	return nil
+}
+
+// fingerprint 返回 c.Fingerprint，c 为nil时返回空串
+func (c *SnapshotConfig) fingerprint() string {
+	if c == nil {
+		return ""
+	}
+	return c.Fingerprint
+}
+
+// snapshotConfig 返回新快照应记录的配置
+func (w *Watcher) snapshotConfig() *SnapshotConfig {
+	return w.snapCfg.Load()
+}
+
+// refreshSnapshotConfig 按当前的有效配置重新计算 SnapshotConfig，之后创建的快照记录新值
+func (w *Watcher) refreshSnapshotConfig() {
+	cfg := w.cfg
+	c := &SnapshotConfig{
+		Roots:          slices.Clone(w.roots),
+		IgnorePatterns: slices.Clone(cfg.IgnorePatterns),
+		NoHashPatterns: slices.Clone(cfg.NoHashPatterns),
+		MaxHashSize:    cfg.MaxHashSize,
+		HistoryLimits:  slices.Clone(cfg.HistoryLimits),
+		Hasher:         hasherName(w.newHash()),
+		IgnoreChmod:    cfg.IgnoreChmod,
+		Degraded:       w.storm.degraded.Load(),
+	}
+	if cfg.HashErrorPolicy != HashErrorEmptyHash {
+		c.HashErrorPolicy = cfg.HashErrorPolicy.String()
+	}
+	d := w.dumpConfig(func(paths []string) []string { return paths })
+	d.InstanceID = ""
+	data, _ := json.Marshal(struct {
+		Config   configDump `json:"config"`
+		Hasher   string     `json:"hasher"`
+		Degraded bool       `json:"degraded"`
+	}{d, c.Hasher, c.Degraded})
+	sum := sha256.Sum256(data)
+	c.Fingerprint = hex.EncodeToString(sum[:16])
+	w.snapCfg.Store(c)
+}
+
+// hasherName 返回哈希算法的名称：已链接的标准库算法(crypto.Hash)使用其名称，如 SHA-256，其它实现使用类型名
+func hasherName(h hash.Hash) string {
+	t := reflect.TypeOf(h)
+	for c := crypto.MD5; c <= crypto.BLAKE2b_512; c++ {
+		if c.Available() && c.Size() == h.Size() && reflect.TypeOf(c.New()) == t {
+			return c.String()
+		}
+	}
+	return t.String()
+}
@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestSnapshotConfig 测试快照记录创建时的配置：配置相同的快照共享记录、指纹与实例无关，
+// 有效配置变化(降级)后的快照带有新指纹，记录随 DirStore 持久化
+func TestSnapshotConfig(t *testing.T) {
+	root := t.TempDir()
+	newW := func(opts ...Option) *Watcher {
+		t.Helper()
+		opts = append([]Option{WithFS(osFS{}, replaySource{}), WithDisableEventChan(), WithIgnorePatterns("*.tmp")}, opts...)
+		w, err := NewWatcherWithOptions([]string{root}, opts...)
+		if err != nil {
+			t.Fatalf("NewWatcherWithOptions failed: %v", err)
+		}
+		t.Cleanup(func() { _ = w.Close() })
+		return w
+	}
+	touch := func(w *Watcher, name string) *SnapshotNode {
+		p := filepath.Join(root, name)
+		_ = os.WriteFile(p, []byte(name), 0644)
+		w.handleFileChange(w.keyOf(p), fsnotify.Create)
+		return w.GetCurrentSnapshot()
+	}
+
+	w := newW(WithInstanceID("a"))
+	initial := w.GetCurrentSnapshot()
+	sn := touch(w, "a.txt")
+	c := w.ConfigAt(sn.ID)
+	if c == nil || c != w.ConfigAt(initial.ID) {
+		t.Fatalf("ConfigAt = %+v; want one record shared by both snapshots", c)
+	}
+	if !slices.Contains(c.IgnorePatterns, "*.tmp") || c.Hasher != "SHA-256" || len(c.Fingerprint) != 32 ||
+		!slices.Equal(c.Roots, w.Roots()) || c.Degraded {
+		t.Fatalf("ConfigAt = %+v; want the effective ignore patterns, roots and hasher", c)
+	}
+	if sum := sn.Summary(); sum.ConfigFingerprint != c.Fingerprint {
+		t.Fatalf("Summary().ConfigFingerprint = %q; want %q", sum.ConfigFingerprint, c.Fingerprint)
+	}
+	if got := newW(WithInstanceID("b")).snapshotConfig().Fingerprint; got != c.Fingerprint {
+		t.Fatalf("fingerprint differs between instances: %s vs %s", got, c.Fingerprint)
+	}
+	if got := newW(WithMaxHashSize(1 << 20)).snapshotConfig(); got.Fingerprint == c.Fingerprint || got.MaxHashSize != 1<<20 {
+		t.Fatalf("MaxHashSize change not reflected: %+v", got)
+	}
+	if w.ConfigAt("snap-missing") != nil {
+		t.Fatal("ConfigAt(missing) != nil")
+	}
+
+	// 进入降级模式后创建的快照带有新的指纹
+	w.storm.degraded.Store(true)
+	w.refreshSnapshotConfig()
+	deg := w.ConfigAt(touch(w, "b.txt").ID)
+	if !deg.Degraded || deg.Fingerprint == c.Fingerprint || w.ConfigAt(sn.ID) != c {
+		t.Fatalf("ConfigAt after degrading = %+v; want a new fingerprint for new snapshots only", deg)
+	}
+
+	store, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(sn); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(sn.ID)
+	if err != nil || got.Config == nil || got.Config.Fingerprint != c.Fingerprint || !slices.Equal(got.Config.IgnorePatterns, c.IgnorePatterns) {
+		t.Fatalf("stored Config = %+v, %v; want %+v", got.Config, err, c)
+	}
+}
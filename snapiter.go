@@ -0,0 +1,76 @@
+package watcher
+
+// SnapIter 从一个快照出发沿父快照方向遍历历史，由 Watcher.HistoryIterator 创建
+//
+// 每次 Next 只按ID查找一个快照，不复制快照表，也不在两次 Next 之间持有任何锁；
+// 遍历期间快照可能被并发合并(HistoryLimits)或删除：父快照在下一次 Next 时才读取，
+// 已被合并的快照由其子快照的新父快照接上，找不到的快照视为历史在此中断。
+// 换出到 Store 的快照以占位节点返回(Spilled() 为 true，Files 为nil)，与 ListAllSnapshots 相同。
+// 不是并发安全的，同一个 SnapIter 只应在一个goroutine中使用
+type SnapIter struct {
+	w     *Watcher
+	all   bool
+	queue []string
+	seen  map[string]struct{}
+	last  *SnapshotNode // 上一次返回、尚未展开父快照的快照
+}
+
+// HistoryOption 配置 HistoryIterator
+type HistoryOption func(*SnapIter)
+
+// HistoryAllParents 按广度优先遍历全部父快照(合并产生的快照的每个父快照都会访问)，每个快照只返回一次；
+// 默认只沿第一个父快照(ParentIDs[0])，即当前分支
+func HistoryAllParents() HistoryOption {
+	return func(it *SnapIter) { it.all = true }
+}
+
+// HistoryIterator 返回从 fromID 开始向更早的快照遍历的迭代器，fromID 为空时从当前快照开始
+//
+// 默认沿第一个父快照返回当前分支上的快照(从新到旧)，适合时间线分页；需要包含合并分支时使用 HistoryAllParents。
+// fromID 不存在或 DisableSnapshots 时迭代器为空
+func (w *Watcher) HistoryIterator(fromID string, opts ...HistoryOption) *SnapIter {
+	it := &SnapIter{w: w}
+	for _, opt := range opts {
+		opt(it)
+	}
+	if w.cfg.DisableSnapshots {
+		return it
+	}
+	if fromID == "" {
+		fromID = w.head.Load().ID
+	}
+	it.queue = []string{fromID}
+	it.seen = map[string]struct{}{fromID: {}}
+	return it
+}
+
+// Next 返回下一个快照，遍历结束时返回 false
+func (it *SnapIter) Next() (*SnapshotNode, bool) {
+	if last := it.last; last != nil {
+		it.last = nil
+		parents := last.ParentIDs
+		// 上一个快照在此期间可能因合并其父快照而被替换：按最新的父快照继续
+		if cur := it.w.snapshots.get(last.ID); cur != nil {
+			parents = cur.ParentIDs
+		}
+		if !it.all && len(parents) > 1 {
+			parents = parents[:1]
+		}
+		for _, pid := range parents {
+			if _, ok := it.seen[pid]; !ok {
+				it.seen[pid] = struct{}{}
+				it.queue = append(it.queue, pid)
+			}
+		}
+	}
+	for len(it.queue) > 0 {
+		id := it.queue[0]
+		it.queue = it.queue[1:]
+		if sn := it.w.snapshots.get(id); sn != nil {
+			it.last = sn
+			return sn, true
+		}
+		// 快照已被删除：当前分支到此为止，广度优先时跳过这一支
+	}
+	return nil, false
+}
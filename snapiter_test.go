@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// iterIDs 取出迭代器的全部快照ID
+func iterIDs(it *SnapIter) []string {
+	var ids []string
+	for sn, ok := it.Next(); ok; sn, ok = it.Next() {
+		ids = append(ids, sn.ID)
+	}
+	return ids
+}
+
+// TestHistoryIterator 测试沿第一个父快照与广度优先的遍历，以及遍历期间快照被删除或合并
+func TestHistoryIterator(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, root)
+	initial := w.GetCurrentSnapshot().ID
+	want := []string{initial}
+	for i := 0; i < 3; i++ {
+		p := filepath.Join(root, fmt.Sprintf("f%d.txt", i))
+		_ = os.WriteFile(p, []byte("x"), 0644)
+		w.handleFileChange(w.keyOf(p), fsnotify.Create)
+		want = append([]string{w.GetCurrentSnapshot().ID}, want...)
+	}
+	if got := iterIDs(w.HistoryIterator("")); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("current branch = %v; want %v", got, want)
+	}
+	if got := iterIDs(w.HistoryIterator(want[2])); fmt.Sprint(got) != fmt.Sprint(want[2:]) {
+		t.Errorf("from %s = %v; want %v", want[2], got, want[2:])
+	}
+	if _, ok := w.HistoryIterator("missing").Next(); ok {
+		t.Error("an unknown start should yield nothing")
+	}
+
+	// 合并产生的快照：m 的父快照为 a、b，两者的父快照都是 initial
+	node := func(id string, parents ...string) *SnapshotNode {
+		sn := &SnapshotNode{ID: id, ParentIDs: parents}
+		w.snapshots.put(sn)
+		return sn
+	}
+	node("a", initial)
+	node("b", initial)
+	node("m", "a", "b")
+	if got := fmt.Sprint(iterIDs(w.HistoryIterator("m"))); got != fmt.Sprint([]string{"m", "a", initial}) {
+		t.Errorf("first parents = %s", got)
+	}
+	if got := fmt.Sprint(iterIDs(w.HistoryIterator("m", HistoryAllParents()))); got != fmt.Sprint([]string{"m", "a", "b", initial}) {
+		t.Errorf("all parents = %s", got)
+	}
+
+	// 返回 m 之后 a 被删除：当前分支到此为止
+	it := w.HistoryIterator("m")
+	it.Next()
+	w.snapshots.del("a")
+	if sn, ok := it.Next(); ok {
+		t.Errorf("Next = %s after the parent was removed; want the end", sn.ID)
+	}
+	// 返回 m 之后 a 被合并进 m(m 被替换为以 initial 为父快照的新节点)：沿新的父快照继续
+	node("a", initial)
+	it = w.HistoryIterator("m")
+	it.Next()
+	w.snapshots.del("a")
+	node("m", initial, "b")
+	if sn, ok := it.Next(); !ok || sn.ID != initial {
+		t.Errorf("Next = %v, %v after a squash; want %s", sn, ok, initial)
+	}
+}
@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// snapshotTable 是快照ID -> *SnapshotNode 的索引
+//
+// 读取(get/all/len)不加锁；写入只发生在持有 w.mu 写锁的提交路径上。
+// 与原子指针 w.head 一起，使 GetCurrentSnapshot、GetSnapshotByID 等读接口不必与提交争用 w.mu
+type snapshotTable struct {
+	m sync.Map // string -> *SnapshotNode
+	n atomic.Int64
+}
+
+// get 返回 id 对应的快照，不存在时返回nil
+func (t *snapshotTable) get(id string) *SnapshotNode {
+	v, ok := t.m.Load(id)
+	if !ok {
+		return nil
+	}
+	return v.(*SnapshotNode)
+}
+
+// put 登记快照，同 ID 已存在时替换
+func (t *snapshotTable) put(sn *SnapshotNode) {
+	if _, loaded := t.m.Swap(sn.ID, sn); !loaded {
+		t.n.Add(1)
+	}
+}
+
+// del 删除快照，不存在时忽略
+func (t *snapshotTable) del(id string) {
+	if _, loaded := t.m.LoadAndDelete(id); loaded {
+		t.n.Add(-1)
+	}
+}
+
+// len 返回快照数量
+func (t *snapshotTable) len() int {
+	return int(t.n.Load())
+}
+
+// all 返回全部快照(顺序不定)
+//
+// 与并发提交同时调用时，结果可能包含也可能不包含调用期间新增的快照
+func (t *snapshotTable) all() []*SnapshotNode {
+	out := make([]*SnapshotNode, 0, t.len())
+	t.m.Range(func(_, v any) bool {
+		out = append(out, v.(*SnapshotNode))
+		return true
+	})
+	return out
+}
+
+// readCurrent 以当前状态的文件表调用 fn，fn 不得修改或保留 files
+//
+// 快照模式下 head 指向不可变的快照，直接读取而不加锁；DisableSnapshots 时当前状态被原地修改，需持有读锁
+// 启用 MinSnapshotInterval 时，当前状态是尚未发布的快照(存在时)，它同样被原地修改
+func (w *Watcher) readCurrent(fn func(files map[string]*FileMetadata)) {
+	w.readCurrentNode(func(sn *SnapshotNode) { fn(sn.Files) })
+}
+
+// readCurrentNode 同 readCurrent，但以当前状态的快照节点调用 fn，fn 不得修改或保留 sn
+func (w *Watcher) readCurrentNode(fn func(sn *SnapshotNode)) {
+	if w.cfg.DisableSnapshots || w.throttled() {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		if p := w.throttle.snap; p != nil {
+			fn(p)
+			return
+		}
+	}
+	fn(w.head.Load())
+}
+
+// currentMeta 返回当前状态中 path 的元信息，不存在或为墓碑时返回nil
+func (w *Watcher) currentMeta(path string) (meta *FileMetadata) {
+	w.readCurrent(func(files map[string]*FileMetadata) { meta = live(files[path]) })
+	return meta
+}
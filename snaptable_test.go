@@ -0,0 +1,85 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestSnapshotReadsDuringCommits 并发提交快照的同时大量调用读接口，检查读到的状态始终自洽且没有丢失提交
+//
+// 配合 -race 运行
+func TestSnapshotReadsDuringCommits(t *testing.T) {
+	root := t.TempDir()
+	w := newTestWatcher(t, root)
+
+	const writers, commits, readers = 8, 200, 8
+	var stop atomic.Bool
+	var rg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		rg.Add(1)
+		go func() {
+			defer rg.Done()
+			lastCount := 0
+			for !stop.Load() {
+				cur := w.GetCurrentSnapshot()
+				if w.GetSnapshotByID(cur.ID) != cur {
+					t.Errorf("current snapshot %s not indexed", cur.ID)
+					return
+				}
+				for _, pid := range cur.ParentIDs {
+					if w.GetSnapshotByID(pid) == nil {
+						t.Errorf("parent %s of %s not indexed", pid, cur.ID)
+						return
+					}
+				}
+				if len(cur.ParentIDs) > 0 {
+					if _, err := w.DiffSnapshots(cur.ParentIDs[0], cur.ID); err != nil {
+						t.Errorf("DiffSnapshots failed: %v", err)
+						return
+					}
+				}
+				n := w.Stats().SnapshotCount
+				if n < lastCount {
+					t.Errorf("snapshot count went backwards: %d -> %d", lastCount, n)
+					return
+				}
+				lastCount = n
+				_ = w.ListAllSnapshots()
+				_ = w.FileHistory(filepath.Join(root, "w0-0"))
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < commits; j++ {
+				p := filepath.Join(root, fmt.Sprintf("w%d-%d", i, j))
+				w.commitSnapshot("stress", map[string]*FileMetadata{p: {Path: p, Hash: p}}, p)
+			}
+		}(i)
+	}
+	wg.Wait()
+	stop.Store(true)
+	rg.Wait()
+
+	cur := w.GetCurrentSnapshot()
+	if got, want := len(cur.Files), writers*commits; got != want {
+		t.Errorf("current snapshot has %d files, want %d (lost commits)", got, want)
+	}
+	if got, want := len(w.ListAllSnapshots()), writers*commits+1; got != want || w.Stats().SnapshotCount != want {
+		t.Errorf("ListAllSnapshots = %d, SnapshotCount = %d, want %d", got, w.Stats().SnapshotCount, want)
+	}
+	depth := 0
+	for sn := cur; len(sn.ParentIDs) > 0; sn = w.GetSnapshotByID(sn.ParentIDs[0]) {
+		depth++
+	}
+	if depth != writers*commits {
+		t.Errorf("parent chain length = %d, want %d", depth, writers*commits)
+	}
+}
@@ -0,0 +1,213 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// hydrateCacheSize 是从 Store 读回的快照在内存中保留的最大个数
+const hydrateCacheSize = 16
+
+// spillState 是两级快照存储(内存 + ConfigWatcher.Store)的状态
+//
+// resident 按提交顺序记录仍完整保存在内存中的快照ID，writing 为正在写入 Store 的快照ID，均受 w.mu 保护；
+// mu 串行化换出过程；kick 通知后台goroutine有新的快照提交
+type spillState struct {
+	mu       sync.Mutex
+	resident []string
+	writing  string
+	kick     chan struct{}
+	cache    hydrateCache
+}
+
+// hydrateCache 是从 Store 读回的完整快照的 LRU 缓存
+type hydrateCache struct {
+	mu    sync.Mutex
+	nodes []*SnapshotNode // 最近使用的在前
+}
+
+// get 返回缓存中的快照并把它移到最前
+func (c *hydrateCache) get(id string) *SnapshotNode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, sn := range c.nodes {
+		if sn.ID == id {
+			copy(c.nodes[1:i+1], c.nodes[:i])
+			c.nodes[0] = sn
+			return sn
+		}
+	}
+	return nil
+}
+
+// add 把快照放到最前，替换同ID的旧条目，超出容量时淘汰最久未使用的
+func (c *hydrateCache) add(sn *SnapshotNode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, old := range c.nodes {
+		if old.ID == sn.ID {
+			c.nodes = append(c.nodes[:i], c.nodes[i+1:]...)
+			break
+		}
+	}
+	if len(c.nodes) == hydrateCacheSize {
+		c.nodes = c.nodes[:hydrateCacheSize-1]
+	}
+	c.nodes = append(c.nodes, nil)
+	copy(c.nodes[1:], c.nodes)
+	c.nodes[0] = sn
+}
+
+// Spilled 报告快照是否是已换出到 Store 的占位节点
+//
+// 占位节点只有 ID、Instance、ParentIDs 与 CreatedAt，Files 为nil；完整内容通过 Watcher.GetSnapshotByID 读回
+func (sn *SnapshotNode) Spilled() bool {
+	return sn.spilled
+}
+
+// spillEnabled 报告是否启用了两级快照存储
+func (w *Watcher) spillEnabled() bool {
+	return w.cfg.Store != nil && w.cfg.MemorySnapshots > 0 && !w.cfg.DisableSnapshots
+}
+
+// trackResidentLocked 登记一个新提交的快照，超出内存配额时通知后台换出，调用方需持有 w.mu 写锁
+func (w *Watcher) trackResidentLocked(id string) {
+	if w.cfg.Store == nil {
+		return
+	}
+	w.spill.resident = append(w.spill.resident, id)
+	w.counters.snapshotsInMemory.Store(int64(len(w.spill.resident)))
+	if w.spillEnabled() && len(w.spill.resident) > w.cfg.MemorySnapshots {
+		select {
+		case w.spill.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// runSpiller 在有新快照提交时把超出内存配额的旧快照换出到 Store，直到 Stop
+func (w *Watcher) runSpiller() {
+	defer w.bgWG.Done()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-w.spill.kick:
+			w.spillOld()
+		}
+	}
+}
+
+// spillOld 把最旧的快照写入 Store 并在内存中替换为占位节点，直到内存中只剩 MemorySnapshots 个
+//
+// 写入 Store 不持有 w.mu，提交不会被 I/O 阻塞；写入失败时发送到 ErrorChan，快照留在内存中等下次重试
+// HEAD 总是最新登记的快照，配额至少为1，因此不会被换出
+func (w *Watcher) spillOld() {
+	if !w.spillEnabled() {
+		return
+	}
+	w.spill.mu.Lock()
+	defer w.spill.mu.Unlock()
+	for {
+		w.mu.Lock()
+		if len(w.spill.resident) <= w.cfg.MemorySnapshots {
+			w.mu.Unlock()
+			return
+		}
+		id := w.spill.resident[0]
+		sn := w.snapshots.get(id)
+		if sn == nil {
+			// 已被删除(HistoryLimits 合并)而 resident 未同步：跳过，重新读取
+			w.spill.resident = w.spill.resident[1:]
+			w.counters.snapshotsInMemory.Store(int64(len(w.spill.resident)))
+			w.mu.Unlock()
+			continue
+		}
+		// 写入期间不允许合并该快照，否则 Store 中会留下一个不在历史中的记录
+		w.spill.writing = id
+		w.mu.Unlock()
+
+		err := w.cfg.Store.Put(sn)
+		w.mu.Lock()
+		w.spill.writing = ""
+		if err != nil {
+			w.mu.Unlock()
+			w.emitError(err)
+			return
+		}
+		// 写入期间快照可能被 SetSnapshotDescription 替换，此时重新写入新节点
+		if w.snapshots.get(id) == sn {
+			w.snapshots.put(&SnapshotNode{ID: sn.ID, Instance: sn.Instance, ParentIDs: sn.ParentIDs, CreatedAt: sn.CreatedAt, spilled: true})
+			w.spill.resident = w.spill.resident[1:]
+			w.counters.snapshotsInMemory.Store(int64(len(w.spill.resident)))
+			w.counters.snapshotsSpilled.Add(1)
+		}
+		w.mu.Unlock()
+	}
+}
+
+// persistResident 把仍在内存中的快照全部写入 Store(不替换为占位节点)并记录 HEAD(Store 实现 HeadStore 时)，由 Close 调用
+func (w *Watcher) persistResident() error {
+	if w.cfg.Store == nil || w.cfg.DisableSnapshots {
+		return nil
+	}
+	w.spill.mu.Lock()
+	defer w.spill.mu.Unlock()
+	w.mu.RLock()
+	ids := append([]string(nil), w.spill.resident...)
+	w.mu.RUnlock()
+
+	var errs []error
+	for _, id := range ids {
+		if sn := w.snapshots.get(id); sn != nil && !sn.spilled {
+			if err := w.cfg.Store.Put(sn); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if hs, ok := w.cfg.Store.(HeadStore); ok && len(errs) == 0 {
+		if err := hs.SetHead(w.head.Load().ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadSnapshot 返回完整快照，已换出的快照从 Store 读回(经过 LRU 缓存)
+func (w *Watcher) loadSnapshot(id string) (*SnapshotNode, error) {
+	sn := w.snapshots.get(id)
+	if sn == nil {
+		return nil, errSnapshotNotFound(id)
+	}
+	if !sn.spilled {
+		return sn, nil
+	}
+	if full := w.spill.cache.get(id); full != nil {
+		w.counters.hydrationCacheHits.Add(1)
+		return full, nil
+	}
+	full, err := w.cfg.Store.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rehydrate snapshot %s: %w", id, err)
+	}
+	full = w.importSnapshot(full)
+	w.counters.snapshotsHydrated.Add(1)
+	w.spill.cache.add(full)
+	return full, nil
+}
+
+// snapshotByID 与 loadSnapshot 相同，但只返回快照：不存在时返回nil，读回失败时记录警告并返回nil
+//
+// 读接口在 Close 之后仍可调用，此时 ErrorChan 已关闭，因此这里不发送到 ErrorChan
+func (w *Watcher) snapshotByID(id string) *SnapshotNode {
+	sn, err := w.loadSnapshot(id)
+	if err != nil {
+		if w.snapshots.get(id) != nil {
+			w.recentErrs.add(w.now(), err)
+			w.logWarn("Failed to rehydrate snapshot", err)
+		}
+		return nil
+	}
+	return sn
+}
@@ -0,0 +1,160 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// newSpillWatcher 创建一个快照保存到 store、内存中只保留 keep 个快照的 Watcher(不启动)
+func newSpillWatcher(t *testing.T, root string, store SnapshotStore, keep int) *Watcher {
+	t.Helper()
+	w, err := NewWatcherWithOptions([]string{root}, WithStore(store, keep))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	return w
+}
+
+// commitVersions 对 path 提交 n 个内容不同的版本，返回各快照ID
+func commitVersions(w *Watcher, path string, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		meta := &FileMetadata{Path: path, Size: int64(i), Hash: fmt.Sprintf("h%d", i), HashState: HashStateHashed}
+		ids[i] = w.commitSnapshot(fmt.Sprintf("v%d", i), map[string]*FileMetadata{path: meta}, path).snap.ID
+	}
+	return ids
+}
+
+// TestSpillAndRehydrate 测试旧快照换出为占位节点，按ID、差异、历史与标签读取时透明地从 Store 读回
+func TestSpillAndRehydrate(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newSpillWatcher(t, root, store, 3)
+	path := filepath.Join(root, "a.txt")
+	ids := commitVersions(w, path, 10)
+	w.spillOld()
+
+	st := w.Stats()
+	if st.SnapshotCount != 11 || st.SnapshotsInMemory != 3 || st.SnapshotsSpilled != 8 {
+		t.Fatalf("after spill: count=%d inMemory=%d spilled=%d", st.SnapshotCount, st.SnapshotsInMemory, st.SnapshotsSpilled)
+	}
+	stubs := 0
+	for _, sn := range w.ListAllSnapshots() {
+		if sn.Spilled() {
+			stubs++
+			if sn.Files != nil || sn.CreatedAt.IsZero() {
+				t.Errorf("stub %s should keep only ID/CreatedAt/ParentIDs: %+v", sn.ID, sn)
+			}
+		}
+	}
+	if stubs != 8 {
+		t.Errorf("stubs = %d; want 8", stubs)
+	}
+	if w.GetCurrentSnapshot().Spilled() {
+		t.Error("HEAD must never be spilled")
+	}
+
+	old := w.GetSnapshotByID(ids[0])
+	if old == nil || old.Spilled() || old.Files[path].Hash != "h0" || old.Description != "v0" {
+		t.Fatalf("rehydrated snapshot mismatch: %+v", old)
+	}
+	_ = w.GetSnapshotByID(ids[0])
+	if st := w.Stats(); st.SnapshotsHydrated != 1 || st.HydrationCacheHits != 1 {
+		t.Errorf("hydrated=%d hits=%d; want 1/1", st.SnapshotsHydrated, st.HydrationCacheHits)
+	}
+
+	// 跨越内存/Store 边界的差异与历史
+	d, err := w.DiffSnapshots(ids[1], ids[9])
+	if err != nil || len(d.Modified) != 1 || d.Modified[0].Old.Hash != "h1" || d.Modified[0].New.Hash != "h9" {
+		t.Fatalf("cross-tier diff = %+v, %v", d, err)
+	}
+	if h := w.FileHistory(path); len(h) != 10 || h[9].SnapshotID != ids[0] || h[9].Meta.Hash != "h0" {
+		t.Errorf("history across tiers has %d versions: %+v", len(h), h)
+	}
+	if fs, err := w.FilesUnder(ids[2], root); err != nil || len(fs) != 1 {
+		t.Errorf("FilesUnder on spilled snapshot = %v, %v", fs, err)
+	}
+
+	// 标签与描述
+	if err := w.TagSnapshot("first", ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if sn := w.SnapshotByTag("first"); sn == nil || sn.Files[path] == nil {
+		t.Errorf("SnapshotByTag should rehydrate: %+v", sn)
+	}
+	if err := w.SetSnapshotDescription(ids[0], "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if sn := w.GetSnapshotByID(ids[0]); sn.Description != "renamed" {
+		t.Errorf("description = %q", sn.Description)
+	}
+	if sn, _ := store.Get(ids[0]); sn.Description != "renamed" {
+		t.Errorf("stored description = %q", sn.Description)
+	}
+
+	// Close 把仍在内存中的快照写入 Store
+	_ = w.Close()
+	if got, _ := store.List(); len(got) != 11 {
+		t.Errorf("store holds %d snapshots after Close; want 11", len(got))
+	}
+}
+
+// failingStore 是写入总是失败的 SnapshotStore
+type failingStore struct{}
+
+func (failingStore) Put(*SnapshotNode) error { return errors.New("disk full") }
+func (failingStore) Get(id string) (*SnapshotNode, error) {
+	return nil, errSnapshotNotFound(id)
+}
+func (failingStore) List() ([]string, error) { return nil, nil }
+
+// TestSpillStoreError 测试写入 Store 失败时快照留在内存中并报告错误
+func TestSpillStoreError(t *testing.T) {
+	root := t.TempDir()
+	w := newSpillWatcher(t, root, failingStore{}, 1)
+	ids := commitVersions(w, filepath.Join(root, "a.txt"), 3)
+	w.spillOld()
+
+	if st := w.Stats(); st.SnapshotsSpilled != 0 || st.SnapshotsInMemory != 4 {
+		t.Errorf("spilled=%d inMemory=%d; want 0/4", st.SnapshotsSpilled, st.SnapshotsInMemory)
+	}
+	if sn := w.GetSnapshotByID(ids[0]); sn == nil || sn.Spilled() {
+		t.Errorf("snapshot should stay in memory: %+v", sn)
+	}
+	select {
+	case err := <-w.ErrorChan:
+		if err == nil {
+			t.Error("nil error")
+		}
+	default:
+		t.Error("expected store error on ErrorChan")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Close should report the failed final persist")
+	}
+}
+
+// TestHydrateCacheLRU 测试缓存淘汰最久未使用的快照
+func TestHydrateCacheLRU(t *testing.T) {
+	var c hydrateCache
+	for i := 0; i < hydrateCacheSize; i++ {
+		c.add(&SnapshotNode{ID: fmt.Sprint(i)})
+	}
+	_ = c.get("0") // 0 成为最近使用
+	c.add(&SnapshotNode{ID: "new"})
+	if c.get("0") == nil || c.get("new") == nil {
+		t.Error("recently used entries should stay cached")
+	}
+	if c.get("1") != nil {
+		t.Error("least recently used entry should be evicted")
+	}
+	if len(c.nodes) != hydrateCacheSize {
+		t.Errorf("cache size = %d", len(c.nodes))
+	}
+}
@@ -0,0 +1,250 @@
+package watcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WatcherStats 是 Watcher 内部计数器与队列状态的一份快照
+//
+// 计数器(单调递增)与瞬时值(Gauge)混合在一起，字段注释中标明了类别
+type WatcherStats struct {
+	// 事件流转
+	EventsReceived       uint64 // 计数：从 fsnotify 收到的事件数
+	EventsIgnored        uint64 // 计数：命中忽略规则被丢弃的事件数
+	SelfSuppressed       uint64 // 计数：因本进程写入的标记(MarkSelfWrite)被丢弃的事件数
+	PathRewriteConflicts uint64 // 计数：路径改写(PathRewrite)不可逆而被跳过的路径数
+	EventsAggregated     uint64 // 计数：进入合并表(aggMap)的事件数
+	EventsCoalesced      uint64 // 计数：与合并表中已有条目合并的事件数
+	FlushCycles          uint64 // 计数：执行的 flush 次数
+	SizeFlushes          uint64 // 计数：合并表达到 MaxPendingPaths 而不等定时器立即执行的 flush 次数
+	BatchesProcessed     uint64 // 计数：至少包含一个路径的 flush 批次数
+	BatchesCompleted     uint64 // 计数：已全部处理完成(事件已发送)的批次数，不受 ResetLatencyStats 影响
+
+	// 快照
+	SnapshotsCreated uint64 // 计数：创建的快照数
+	SnapshotCount    int    // 瞬时：当前保存的快照数(含已换出到 Store 的占位节点)
+	ChangesCoalesced uint64 // 计数：因 MinSnapshotInterval 并入同一快照(而没有单独创建快照)的提交数
+
+	DescribeFallbacks uint64 // 计数：DescribeSnapshot 回调 panic 或超时而使用默认描述的次数
+	DescribeRunning   int    // 瞬时：仍在执行的 DescribeSnapshot 回调数(含超时后不再等待、Close 后仍未结束的)
+
+	// 按路径限制版本数(ConfigWatcher.HistoryLimits)
+	SnapshotsSquashed uint64 // 计数：因版本数超限被合并(删除)的快照数
+
+	// 删除标记(ConfigWatcher.TombstoneSnapshots)
+	Tombstones int // 瞬时：当前状态中的墓碑数
+
+	// 两级快照存储(ConfigWatcher.Store)
+	SnapshotsInMemory  int    // 瞬时：完整保存在内存中的快照数(未配置 Store 时等于 SnapshotCount)
+	SnapshotsSpilled   uint64 // 计数：换出到 Store 的快照数
+	SnapshotsHydrated  uint64 // 计数：从 Store 读回快照的次数(未命中缓存)
+	HydrationCacheHits uint64 // 计数：读取已换出快照时命中 LRU 缓存的次数
+
+	// 内核事件队列溢出
+	EventOverflows  uint64 // 计数：fsnotify 报告事件队列溢出(事件丢失)的次数
+	OverflowRescans uint64 // 计数：因溢出完成的对账重扫次数(RescanOnOverflow)
+
+	// 事件风暴保护(ConfigWatcher.StormMaxEventsPerSec/StormMaxHashBytesPerSec)
+	Degraded        bool          // 瞬时：当前处于降级模式
+	DegradedEntries uint64        // 计数：进入降级模式的次数
+	DegradedTime    time.Duration // 计数：处于降级模式的累计时间(含进行中的一次)
+
+	// 监控目录(ConfigWatcher.MaxWatchedDirs)
+	WatchedDirs      int    // 瞬时：已注册监控(占用内核 watch)的目录数
+	WatchedDirsLimit int    // 瞬时：MaxWatchedDirs，0 表示不限
+	WatchesSkipped   int    // 瞬时：因达到上限而未注册监控的目录数
+	WatchesFailed    int    // 瞬时：因系统资源耗尽注册失败、等待重试的目录数(见 RetryFailedWatches)
+	WatchesRecovered uint64 // 计数：重试后注册成功的目录数
+	InaccessibleDirs int    // 瞬时：运行中变得不可读、子树暂停更新的目录数(AccessDeniedError)
+
+	// 优先级车道(ConfigWatcher.Priority)
+	FastLaneQueued int64 // 瞬时：已分派到快车道、尚未开始处理的路径数
+	BulkLaneQueued int64 // 瞬时：已分派到慢车道、尚未开始处理的路径数
+
+	// 哈希
+	HashOps     uint64 // 计数：实际读取文件内容计算哈希的次数
+	BytesHashed uint64 // 计数：计算哈希读取的字节数
+	HashErrors  uint64 // 计数：哈希失败次数
+	HashRetries uint64 // 计数：因文件被锁定(ErrFileLocked)安排的哈希重试次数
+	HashPending int    // 瞬时：等待重试哈希的路径数(条目的 HashState 为 Pending)
+
+	// 写入完成检测(ConfigWatcher.CompletionPatterns)
+	CompletionPending int // 瞬时：等待写入完成的文件数
+
+	// 对外通道
+	EventsEmitted uint64 // 计数：发送到 EventChan 的事件数
+	EventsDropped uint64 // 计数：因 EventChan 无法接收而丢弃的事件数
+	ErrorsDropped uint64 // 计数：因 ErrorChan 已满而丢弃的错误数
+
+	ErrorsDeduplicated uint64 // 计数：去重窗口内被折叠、没有单独发送的重复错误数(ErrorDedupWindow)
+
+	// 订阅
+	Subscribers       int    // 瞬时：当前订阅数
+	SubscriberDropped uint64 // 计数：因订阅缓冲已满而未投递的事件数(所有订阅合计)
+	ReplayBuffered    int    // 瞬时：回放缓冲(ReplayBuffer)中保存的事件数
+
+	// 审计日志
+	AuditWritten uint64 // 计数：写入审计日志的记录数
+	AuditDropped uint64 // 计数：因审计队列已满而丢弃的记录数
+
+	// 队列与worker
+	AggChanLen       int    // 瞬时：合并通道中等待的事件数
+	AggChanCap       int    // 瞬时：合并通道容量
+	AggChanHighWater uint64 // 瞬时：合并通道的历史最高水位
+	EventChanLen     int    // 瞬时：EventChan 中等待消费的事件数
+	EventChanCap     int    // 瞬时：EventChan 容量
+	InFlightWorkers  int    // 瞬时：正在处理变更的worker数
+	WorkerCount      int    // 瞬时：worker上限(WorkerAutoTune 或 SetWorkerLimit 调整后的有效值)
+
+	// worker 利用率，见 WithWorkerAutoTune
+	WorkerUtilization float64       // 瞬时：最近一个统计区间(WorkerTuneInterval)内 worker 忙碌时间占 上限×区间长度 的比例
+	WorkerBusyTime    time.Duration // 计数：worker 处理变更的累计时间
+	WorkerWaits       uint64        // 计数：flush 提交路径时因 worker 全忙而等待令牌的次数
+	MaxChangeDuration time.Duration // 瞬时：单个变更(一次 handleFileChange)的最长处理耗时，可用 ResetLatencyStats 清零
+
+	// 延迟分布(含 P50/P95/P99 与 Max)，可用 ResetLatencyStats 清零
+	BatchLatency HistogramSnapshot // 单个 flush 批次从第一个事件进入合并通道到全部处理完成(事件已发送)的耗时
+	HashLatency  HistogramSnapshot // 单个文件计算哈希的耗时
+	EventLatency HistogramSnapshot // 单个路径从第一个底层事件到达到变更处理完成的耗时(FileEvent.Processed - FirstSeen)
+}
+
+// watcherCounters 保存所有计数器，全部使用原子操作，读取时不会与事件处理路径竞争锁
+type watcherCounters struct {
+	eventsReceived   atomic.Uint64
+	eventsIgnored    atomic.Uint64
+	rewriteConflicts atomic.Uint64
+	eventsAggregated atomic.Uint64
+	eventsCoalesced  atomic.Uint64
+	flushCycles      atomic.Uint64
+	sizeFlushes      atomic.Uint64
+	batchesProcessed atomic.Uint64
+	batchesCompleted atomic.Uint64
+	snapshotsCreated atomic.Uint64
+	hashOps          atomic.Uint64
+	bytesHashed      atomic.Uint64
+	hashErrors       atomic.Uint64
+	hashRetries      atomic.Uint64
+	eventsEmitted    atomic.Uint64
+	eventsDropped    atomic.Uint64
+	errorsDropped    atomic.Uint64
+	aggHighWater     atomic.Uint64
+	auditWritten     atomic.Uint64
+	auditDropped     atomic.Uint64
+	lastEventAt      atomic.Int64 // UnixNano，见 Health()
+	lastFlushAt      atomic.Int64 // UnixNano，见 Health()
+
+	changesCoalesced  atomic.Uint64
+	describeFallbacks atomic.Uint64 // 见 describe.go
+	describeRunning   atomic.Int64
+	snapshotsSquashed atomic.Uint64 // 见 historylimit.go
+
+	// 两级快照存储，见 spill.go
+	snapshotsSpilled   atomic.Uint64
+	snapshotsHydrated  atomic.Uint64
+	hydrationCacheHits atomic.Uint64
+	snapshotsInMemory  atomic.Int64
+
+	// 内核事件队列溢出，见 overflow.go
+	eventOverflows  atomic.Uint64
+	overflowRescans atomic.Uint64
+
+	batchLatency *latencyHistogram
+	hashLatency  *latencyHistogram
+	eventLatency *latencyHistogram
+}
+
+// observeHighWater 用 CAS 更新历史最高水位
+func observeHighWater(hw *atomic.Uint64, v uint64) {
+	for {
+		cur := hw.Load()
+		if v <= cur || hw.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+// ResetLatencyStats 清空 Stats() 中的延迟分布(BatchLatency、HashLatency、EventLatency)与 MaxChangeDuration，用于只观察某段时间内的延迟
+//
+// 其它计数器不受影响；Prometheus 等按累计值导出直方图的采集方会看到一次计数器重置
+// 并发安全
+func (w *Watcher) ResetLatencyStats() {
+	w.counters.batchLatency.reset()
+	w.counters.hashLatency.reset()
+	w.counters.eventLatency.reset()
+	w.workers.maxChange.Store(0)
+}
+
+// Stats 返回内部计数器与队列状态
+//
+// 计数器使用原子变量读取，只有 SnapshotCount 需要短暂持有读锁
+// 并发安全
+func (w *Watcher) Stats() WatcherStats {
+	c := &w.counters
+	st := WatcherStats{
+		EventsReceived:       c.eventsReceived.Load(),
+		EventsIgnored:        c.eventsIgnored.Load(),
+		SelfSuppressed:       w.self.suppressed.Load(),
+		PathRewriteConflicts: c.rewriteConflicts.Load(),
+		EventsAggregated:     c.eventsAggregated.Load(),
+		EventsCoalesced:      c.eventsCoalesced.Load(),
+		FlushCycles:          c.flushCycles.Load(),
+		SizeFlushes:          c.sizeFlushes.Load(),
+		BatchesProcessed:     c.batchesProcessed.Load(),
+		BatchesCompleted:     c.batchesCompleted.Load(),
+		SnapshotsCreated:     c.snapshotsCreated.Load(),
+		HashOps:              c.hashOps.Load(),
+		BytesHashed:          c.bytesHashed.Load(),
+		HashErrors:           c.hashErrors.Load(),
+		HashRetries:          c.hashRetries.Load(),
+		HashPending:          int(w.hashRetry.n.Load()),
+		CompletionPending:    int(w.completion.n.Load()),
+		EventsEmitted:        c.eventsEmitted.Load(),
+		EventsDropped:        c.eventsDropped.Load(),
+		ErrorsDropped:        c.errorsDropped.Load(),
+		AggChanLen:           len(w.aggChan),
+		AggChanCap:           cap(w.aggChan),
+		AggChanHighWater:     c.aggHighWater.Load(),
+		EventChanLen:         len(w.EventChan),
+		EventChanCap:         cap(w.EventChan),
+		AuditWritten:         c.auditWritten.Load(),
+		AuditDropped:         c.auditDropped.Load(),
+		Subscribers:          w.subscriberCount(),
+		ReplayBuffered:       w.replayLen(),
+		SubscriberDropped:    w.subs.dropped.Load(),
+		BatchLatency:         c.batchLatency.snapshot(),
+		HashLatency:          c.hashLatency.snapshot(),
+		EventLatency:         c.eventLatency.snapshot(),
+
+		ChangesCoalesced:   c.changesCoalesced.Load(),
+		DescribeFallbacks:  c.describeFallbacks.Load(),
+		DescribeRunning:    int(c.describeRunning.Load()),
+		SnapshotsSpilled:   c.snapshotsSpilled.Load(),
+		SnapshotsHydrated:  c.snapshotsHydrated.Load(),
+		HydrationCacheHits: c.hydrationCacheHits.Load(),
+
+		EventOverflows:  c.eventOverflows.Load(),
+		OverflowRescans: c.overflowRescans.Load(),
+
+		SnapshotsSquashed: c.snapshotsSquashed.Load(),
+
+		Tombstones: int(w.tombs.n.Load()),
+
+		ErrorsDeduplicated: w.errDedup.folded.Load(),
+	}
+
+	st.WorkerCount, st.InFlightWorkers, st.WorkerUtilization, st.WorkerBusyTime, st.WorkerWaits, st.MaxChangeDuration = w.workerStats()
+	st.WatchedDirs, st.WatchesSkipped = w.watches.counts()
+	st.WatchedDirsLimit = w.cfg.MaxWatchedDirs
+	st.WatchesFailed, st.WatchesRecovered = int(w.watchRetry.n.Load()), w.watchRetry.recovered.Load()
+	st.InaccessibleDirs = int(w.denied.n.Load())
+	st.Degraded, st.DegradedEntries, st.DegradedTime = w.stormStats()
+	st.FastLaneQueued = w.lanes.fastQueued.Load()
+	st.BulkLaneQueued = w.lanes.bulkQueued.Load()
+	st.SnapshotCount = w.snapshots.len()
+	st.SnapshotsInMemory = st.SnapshotCount
+	if w.cfg.Store != nil {
+		st.SnapshotsInMemory = int(c.snapshotsInMemory.Load())
+	}
+	return st
+}
@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestStatsCounters 测试直接处理变更时计数器的变化
+func TestStatsCounters(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("hello"), 0644)
+
+	w := newTestWatcher(t, root)
+	before := w.Stats()
+	if before.SnapshotCount != 1 || before.SnapshotsCreated != 0 {
+		t.Fatalf("unexpected initial stats %+v", before)
+	}
+
+	w.handleFileChange(file, fsnotify.Create)
+	st := w.Stats()
+	if st.SnapshotsCreated != 1 || st.SnapshotCount != 2 {
+		t.Errorf("snapshots: created=%d count=%d; want 1/2", st.SnapshotsCreated, st.SnapshotCount)
+	}
+	if st.HashOps != 1 || st.BytesHashed != 5 {
+		t.Errorf("hashing: ops=%d bytes=%d; want 1/5", st.HashOps, st.BytesHashed)
+	}
+	if st.EventsEmitted != 1 || st.EventChanLen != 1 {
+		t.Errorf("emission: emitted=%d queued=%d; want 1/1", st.EventsEmitted, st.EventChanLen)
+	}
+	if st.WorkerCount != 32 || st.AggChanCap == 0 {
+		t.Errorf("capacity gauges not populated: %+v", st)
+	}
+}
+
+// TestStatsEventFlow 测试真实事件流经时事件计数器的变化
+func TestStatsEventFlow(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcher(ConfigWatcher{
+		WatchPaths:     []string{root},
+		IgnorePatterns: []string{filepath.Join(root, "*.tmp")},
+		Debounce:       5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer w.Stop()
+
+	_ = os.WriteFile(filepath.Join(root, "skip.tmp"), []byte("x"), 0644)
+	_ = os.WriteFile(filepath.Join(root, "keep.txt"), []byte("x"), 0644)
+
+	ok := waitFor(t, 2*time.Second, func() bool {
+		st := w.Stats()
+		return st.EventsIgnored > 0 && st.BatchesProcessed > 0 && st.SnapshotsCreated > 0
+	})
+	st := w.Stats()
+	if !ok {
+		t.Fatalf("counters did not move: %+v", st)
+	}
+	if st.EventsReceived < st.EventsIgnored+st.EventsAggregated {
+		t.Errorf("received (%d) should cover ignored (%d) + aggregated (%d)", st.EventsReceived, st.EventsIgnored, st.EventsAggregated)
+	}
+	if st.FlushCycles < st.BatchesProcessed {
+		t.Errorf("unexpected flush/queue stats %+v", st)
+	}
+}
@@ -0,0 +1,286 @@
+package watcher
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotStore 持久化快照，配合 ConfigWatcher.Store 把较旧的快照移出内存
+//
+// Put 写入完整快照，同一ID再次 Put 时覆盖(如 SetSnapshotDescription 修改已换出快照的描述)；
+// Get 读回完整快照，ID 不存在时返回的错误包装 ErrSnapshotNotFound；List 返回全部已保存的快照ID(顺序不定)
+// 实现需要支持并发调用
+type SnapshotStore interface {
+	Put(sn *SnapshotNode) error
+	Get(id string) (*SnapshotNode, error)
+	List() ([]string, error)
+}
+
+// storedSnapshotVersion 是 DirStore 完整记录的格式版本号
+const storedSnapshotVersion = 1
+
+// storedDeltaVersion 是增量记录的格式版本号：不认识增量的旧版本读取时报告不支持的版本，而不是把增量当作完整快照
+const storedDeltaVersion = 2
+
+// storedSnapshot 是快照的持久化格式，Files 按路径排序以便输出稳定
+type storedSnapshot struct {
+	Version     int             `json:"version"`
+	ID          string          `json:"id"`
+	Instance    string          `json:"instance,omitempty"`
+	ParentIDs   []string        `json:"parent_ids,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Description string          `json:"description,omitempty"`
+	RootHash    string          `json:"root_hash,omitempty"`
+	Files       []*FileMetadata `json:"files"`
+	Config      *SnapshotConfig `json:"config,omitempty"`
+
+	ChangedPaths []string `json:"changed_paths,omitempty"`
+	Truncated    bool     `json:"changed_paths_truncated,omitempty"`
+
+	// 增量记录(Version 为 storedDeltaVersion)：Files 只含相对 Base 新增或变化的条目，Removed 为 Base 中有而本快照没有的路径
+	Base    string   `json:"delta_base,omitempty"`
+	Depth   int      `json:"delta_depth,omitempty"` // 距最近的完整记录(关键帧)的增量数
+	Removed []string `json:"removed,omitempty"`
+}
+
+// DirStore 是把每个快照保存为目录下一个 gzip 压缩的 JSON 文件(<id>.json.gz)的 SnapshotStore，
+// 同时实现 HeadStore(HEAD 保存在同一目录的 HEAD 文件中)与 InstanceStore
+//
+// 快照ID包含实例ID，多个实例的快照文件可以共存于同一目录；ForInstance 返回的视图只列出该实例
+// (以及旧版本写入的、ID 中没有实例的)快照，HEAD 保存在 HEAD-<实例ID> 文件中
+//
+// 默认每个快照保存完整的文件表；DirStoreDeltas 时只保存相对父快照的增量，见 DirStoreDeltas
+type DirStore struct {
+	dir      string
+	instance string // ForInstance 的视图所属实例，空串表示整个目录
+	keyframe int    // 连续增量数上限(DirStoreDeltas)，0 表示总是写完整记录
+	delta    deltaState
+}
+
+var (
+	_ SnapshotStore = (*DirStore)(nil)
+	_ HeadStore     = (*DirStore)(nil)
+	_ InstanceStore = (*DirStore)(nil)
+)
+
+// dirStoreExt 是 DirStore 快照文件的扩展名
+const dirStoreExt = ".json.gz"
+
+// DirStoreOption 配置 NewDirStore
+type DirStoreOption func(*DirStore)
+
+// NewDirStore 返回保存在 dir 下的 DirStore，dir 不存在时创建
+func NewDirStore(dir string, opts ...DirStoreOption) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot store %s: %w", dir, err)
+	}
+	s := &DirStore{dir: dir}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// ForInstance 实现 InstanceStore，返回同一目录上只属于 instance 的视图
+func (s *DirStore) ForInstance(instance string) SnapshotStore {
+	return &DirStore{dir: s.dir, instance: instance, keyframe: s.keyframe}
+}
+
+// file 返回快照文件路径，拒绝会逃出存储目录的ID
+func (s *DirStore) file(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid snapshot id %q", id)
+	}
+	return filepath.Join(s.dir, id+dirStoreExt), nil
+}
+
+// Put 实现 SnapshotStore，先写临时文件再重命名，读取方不会看到写了一半的快照
+//
+// 路径总是以 '/' 形式写入(见 SlashPaths)；启用 DirStoreDeltas 时相对第一个父快照写入增量(见 deltaBase)
+func (s *DirStore) Put(sn *SnapshotNode) error {
+	sn = sn.SlashPaths()
+	name, err := s.file(sn.ID)
+	if err != nil {
+		return err
+	}
+	rec := storedSnapshot{
+		Version:     storedSnapshotVersion,
+		ID:          sn.ID,
+		Instance:    sn.Instance,
+		ParentIDs:   sn.ParentIDs,
+		CreatedAt:   sn.CreatedAt,
+		Description: sn.Description,
+		RootHash:    sn.RootHash,
+		Files:       make([]*FileMetadata, 0, len(sn.Files)),
+		Config:      sn.Config,
+
+		ChangedPaths: sn.ChangedPaths,
+		Truncated:    sn.Truncated,
+	}
+	chain := []string{sn.ID}
+	if base, baseChain := s.deltaBase(sn); base != nil {
+		rec.Version, rec.Base, rec.Depth = storedDeltaVersion, base.ID, len(baseChain)
+		rec.Files, rec.Removed = deltaEntries(base, sn)
+		chain = append(chain, baseChain...)
+	} else {
+		for _, p := range sn.sortedPaths() {
+			rec.Files = append(rec.Files, sn.Files[p])
+		}
+	}
+
+	tmp, err := os.CreateTemp(s.dir, sn.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to store snapshot %s: %w", sn.ID, err)
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后删除失败，可忽略
+
+	zw := gzip.NewWriter(tmp)
+	err = json.NewEncoder(zw).Encode(&rec)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store snapshot %s: %w", sn.ID, err)
+	}
+	s.remember(sn, chain)
+	return nil
+}
+
+// Get 实现 SnapshotStore；增量记录(DirStoreDeltas)沿 delta_base 回溯到最近的完整记录后还原为完整快照，
+// 链上的记录缺失或损坏时返回 *DeltaChainError
+func (s *DirStore) Get(id string) (*SnapshotNode, error) {
+	sn, _, err := s.load(id)
+	return sn, err
+}
+
+// read 读取并解码一条记录，不还原增量
+func (s *DirStore) read(id string) (*storedSnapshot, error) {
+	name, err := s.file(id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errSnapshotNotFound(id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s: %w", id, err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s: %w", id, err)
+	}
+	var rec storedSnapshot
+	if err := json.NewDecoder(zr).Decode(&rec); err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s: %w", id, err)
+	}
+	switch {
+	case rec.Version == storedSnapshotVersion && rec.Base == "":
+	case rec.Version == storedDeltaVersion && rec.Base != "":
+	default:
+		return nil, fmt.Errorf("failed to load snapshot %s: unsupported format version %d", id, rec.Version)
+	}
+	return &rec, nil
+}
+
+// node 返回记录头部对应的快照(不含文件表)
+func (rec *storedSnapshot) node() *SnapshotNode {
+	sn := &SnapshotNode{
+		ID:          rec.ID,
+		Instance:    rec.Instance,
+		ParentIDs:   rec.ParentIDs,
+		CreatedAt:   rec.CreatedAt,
+		Description: rec.Description,
+		RootHash:    rec.RootHash,
+		Config:      rec.Config,
+
+		ChangedPaths: rec.ChangedPaths,
+		Truncated:    rec.Truncated,
+	}
+	if sn.Instance == "" {
+		sn.Instance = SnapshotInstance(sn.ID)
+	}
+	return sn
+}
+
+// List 实现 SnapshotStore，返回的ID按名称排序；ForInstance 的视图只返回该实例与旧版本写入的快照
+func (s *DirStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot store %s: %w", s.dir, err)
+	}
+	var ids []string
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), dirStoreExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if inst := SnapshotInstance(id); s.instance == "" || inst == "" || inst == s.instance {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// dirStoreHead 是 DirStore 中记录 HEAD 的文件名，ForInstance 的视图使用 HEAD-<实例ID>
+const dirStoreHead = "HEAD"
+
+// headFile 返回记录 HEAD 的文件名
+func (s *DirStore) headFile() string {
+	if s.instance == "" {
+		return dirStoreHead
+	}
+	return dirStoreHead + "-" + s.instance
+}
+
+// SetHead 实现 HeadStore
+func (s *DirStore) SetHead(id string) error {
+	if _, err := s.file(id); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, s.headFile()+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to store HEAD: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(id + "\n")
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(s.dir, s.headFile()))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store HEAD: %w", err)
+	}
+	return nil
+}
+
+// Head 实现 HeadStore
+func (s *DirStore) Head() (string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, s.headFile()))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
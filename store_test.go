@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDirStoreRoundTrip 测试快照写入、读回与列出，以及不存在/非法ID的错误
+func TestDirStoreRoundTrip(t *testing.T) {
+	s, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sn := &SnapshotNode{
+		ID:          "snap-2",
+		ParentIDs:   []string{"snap-1"},
+		CreatedAt:   created,
+		Description: "two files",
+		RootHash:    "root",
+		Files: map[string]*FileMetadata{
+			"/r/a.txt": {Path: "/r/a.txt", Size: 3, Hash: "aa", HashState: HashStateHashed, ModTime: created},
+			"/r/d":     {Path: "/r/d", IsDirectory: true, Hash: "dd"},
+		},
+	}
+	if err := s.Put(sn); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Put(&SnapshotNode{ID: "snap-1", CreatedAt: created}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	got, err := s.Get("snap-2")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.ID != sn.ID || got.ParentIDs[0] != "snap-1" || !got.CreatedAt.Equal(created) ||
+		got.Description != sn.Description || got.RootHash != sn.RootHash || len(got.Files) != 2 {
+		t.Errorf("round trip mismatch: %+v", got)
+	}
+	if m := got.Files["/r/a.txt"]; m == nil || *m != *sn.Files["/r/a.txt"] {
+		t.Errorf("file metadata mismatch: %+v", m)
+	}
+	if empty, _ := s.Get("snap-1"); empty == nil || empty.Files == nil {
+		t.Errorf("empty snapshot should round trip with a non-nil file table: %+v", empty)
+	}
+
+	if ids, err := s.List(); err != nil || strings.Join(ids, ",") != "snap-1,snap-2" {
+		t.Errorf("List = %v, %v", ids, err)
+	}
+	if _, err := s.Get("snap-9"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+	if err := s.Put(&SnapshotNode{ID: "../escape"}); err == nil {
+		t.Error("expected error for an id containing a path separator")
+	}
+}
@@ -0,0 +1,186 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DirStore 的增量记录(DirStoreDeltas)
+//
+// 相邻快照之间通常只有少数文件变化，每个快照都保存完整的文件表会让 Store 随历史长度成倍增长。
+// 启用增量后，Put 相对快照的第一个父快照(ParentIDs[0])只写入新增或变化的条目(Files)与被移除的路径(Removed)，
+// 记录中的 delta_base 指向父快照；每连续 keyframeEvery 个增量之后写一个完整记录(关键帧)，读取时最多回溯这么多条记录。
+// 父快照不在 Store 中(尚未写入、已被删除或读不出)时同样写完整记录。
+//
+// Get 沿 delta_base 回溯到关键帧，再依次应用增量还原出完整快照，调用方看到的与完整记录没有区别；
+// 链上的记录缺失、损坏、成环或与基准不符时返回 *DeltaChainError(匹配 ErrBrokenDeltaChain)，
+// ValidateStore 报告为 IssueBrokenDeltaChain。增量记录使用新的格式版本，不认识增量的旧版本读取时报告版本不支持
+
+// ErrBrokenDeltaChain 表示增量记录回溯到关键帧的链条断裂，快照无法还原(*DeltaChainError，SnapshotStore.Get 与 ValidateStore)
+var ErrBrokenDeltaChain = errors.New("delta chain is broken")
+
+// DeltaChainError 描述无法还原的增量记录链
+type DeltaChainError struct {
+	ID    string   // 要读取的快照
+	Chain []string // 从 ID 沿 delta_base 回溯经过的记录，最后一个是出问题的记录
+	Err   error    // 出问题的原因
+}
+
+// Error 实现 error 接口
+func (e *DeltaChainError) Error() string {
+	return fmt.Sprintf("snapshot %s: delta chain %s broken at %s: %v", e.ID, strings.Join(e.Chain, " → "), e.Broken(), e.Err)
+}
+
+// Broken 返回出问题的记录ID
+func (e *DeltaChainError) Broken() string {
+	if len(e.Chain) == 0 {
+		return e.ID
+	}
+	return e.Chain[len(e.Chain)-1]
+}
+
+// Unwrap 返回出问题的原因
+func (e *DeltaChainError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使 errors.Is(err, ErrBrokenDeltaChain) 成立
+func (e *DeltaChainError) Is(target error) bool {
+	return target == ErrBrokenDeltaChain
+}
+
+// DirStoreDeltas 让 DirStore 相对父快照写入增量记录，每 keyframeEvery 个连续增量之后写一个完整记录；
+// keyframeEvery 小于等于0时总是写完整记录(默认)。已有的完整记录照常读取，可以随时开启或关闭
+func DirStoreDeltas(keyframeEvery int) DirStoreOption {
+	return func(s *DirStore) {
+		s.keyframe = max(keyframeEvery, 0)
+	}
+}
+
+// deltaState 缓存最近一次 Put 的快照：换出按创建顺序进行，下一个快照的父快照通常就是它，不必从磁盘还原
+type deltaState struct {
+	mu    sync.Mutex
+	last  *SnapshotNode // '/' 形式
+	chain []string      // last 的记录回溯到关键帧经过的ID(含 last 本身)
+}
+
+// deltaBase 返回 sn 应作为增量写入时的基准快照及基准的记录链，应写完整记录时返回nil
+func (s *DirStore) deltaBase(sn *SnapshotNode) (*SnapshotNode, []string) {
+	if s.keyframe <= 0 || len(sn.ParentIDs) == 0 {
+		return nil, nil
+	}
+	parent := sn.ParentIDs[0]
+	s.delta.mu.Lock()
+	base, chain := s.delta.last, s.delta.chain
+	s.delta.mu.Unlock()
+	if base == nil || base.ID != parent {
+		var err error
+		if base, chain, err = s.load(parent); err != nil {
+			return nil, nil
+		}
+	}
+	// 链长达到上限时写关键帧；基准的链经过 sn 自己(覆盖写入已有记录)时也写完整记录，避免成环
+	if len(chain) > s.keyframe || slices.Contains(chain, sn.ID) {
+		return nil, nil
+	}
+	return base, chain
+}
+
+// remember 记录刚写入的快照及其记录链，供下一次 Put 使用
+func (s *DirStore) remember(sn *SnapshotNode, chain []string) {
+	s.delta.mu.Lock()
+	defer s.delta.mu.Unlock()
+	if s.keyframe > 0 {
+		s.delta.last, s.delta.chain = sn, chain
+	}
+}
+
+// deltaEntries 返回 sn 相对 base 新增或变化的条目与被移除的路径，均按路径排序
+func deltaEntries(base, sn *SnapshotNode) (files []*FileMetadata, removed []string) {
+	files = make([]*FileMetadata, 0)
+	for _, p := range sn.sortedPaths() {
+		if m := sn.Files[p]; !sameEntry(base.Files[p], m) {
+			files = append(files, m)
+		}
+	}
+	for p := range base.Files {
+		if _, ok := sn.Files[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+	slices.Sort(removed)
+	return files, removed
+}
+
+// sameEntry 报告两个条目持久化后是否完全相同
+func sameEntry(a, b *FileMetadata) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	return a.Path == b.Path && a.Size == b.Size && a.ModTime.Equal(b.ModTime) &&
+		a.Hash == b.Hash && a.HashState == b.HashState && a.IsDirectory == b.IsDirectory &&
+		a.CreatedAt.Equal(b.CreatedAt) && a.LastModified.Equal(b.LastModified) && a.BirthTime.Equal(b.BirthTime) &&
+		a.AppendedBytes == b.AppendedBytes && a.Deleted == b.Deleted && a.DeletedAt.Equal(b.DeletedAt) &&
+		a.Inaccessible == b.Inaccessible
+}
+
+// load 读取 id 的记录并还原为完整快照，同时返回回溯到关键帧经过的记录ID(含 id 本身)
+//
+// id 本身的记录读不出时返回 read 的错误(不存在时包装 ErrSnapshotNotFound)，链上更早的记录出问题时返回 *DeltaChainError
+func (s *DirStore) load(id string) (*SnapshotNode, []string, error) {
+	var (
+		recs  []*storedSnapshot
+		chain []string
+	)
+	for cur := id; ; {
+		if slices.Contains(chain, cur) {
+			return nil, nil, &DeltaChainError{ID: id, Chain: append(chain, cur), Err: errors.New("chain loops back on itself")}
+		}
+		chain = append(chain, cur)
+		rec, err := s.read(cur)
+		if err != nil {
+			if cur == id {
+				return nil, nil, err
+			}
+			if errors.Is(err, ErrSnapshotNotFound) {
+				err = errors.New("record is missing")
+			}
+			return nil, nil, &DeltaChainError{ID: id, Chain: chain, Err: err}
+		}
+		if rec.ID != cur && cur != id {
+			return nil, nil, &DeltaChainError{ID: id, Chain: chain, Err: fmt.Errorf("record holds snapshot %s", rec.ID)}
+		}
+		recs = append(recs, rec)
+		if rec.Base == "" {
+			break
+		}
+		cur = rec.Base
+	}
+
+	// 从关键帧开始依次应用增量
+	key := recs[len(recs)-1]
+	files := make(map[string]*FileMetadata, len(key.Files))
+	for _, m := range key.Files {
+		files[m.Path] = m
+	}
+	for i := len(recs) - 2; i >= 0; i-- {
+		for _, p := range recs[i].Removed {
+			if _, ok := files[p]; !ok {
+				return nil, nil, &DeltaChainError{ID: id, Chain: chain[:i+1], Err: fmt.Errorf("removes %s, which is not in base %s", p, recs[i].Base)}
+			}
+			delete(files, p)
+		}
+		for _, m := range recs[i].Files {
+			files[m.Path] = m
+		}
+	}
+	sn := recs[0].node()
+	sn.Files = files
+	return sn, chain, nil
+}
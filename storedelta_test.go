@@ -0,0 +1,167 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// putDeltaChain 向 s 写入 n 个依次为父子的快照(snap-0 ... snap-<n-1>)，每个快照修改 a.txt、新增一个文件并移除上一个新增的文件，
+// 返回各快照的文件表
+func putDeltaChain(t *testing.T, s *DirStore, n int) []map[string]*FileMetadata {
+	t.Helper()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	keep := &FileMetadata{Path: "/r/keep.txt", Size: 1, Hash: "kk", HashState: HashStateHashed, ModTime: created}
+	var tables []map[string]*FileMetadata
+	for i := 0; i < n; i++ {
+		extra := fmt.Sprintf("/r/extra-%d.txt", i)
+		files := map[string]*FileMetadata{
+			"/r/keep.txt": keep,
+			"/r/a.txt":    {Path: "/r/a.txt", Size: int64(i), Hash: fmt.Sprintf("a%d", i), HashState: HashStateHashed, ModTime: created.Add(time.Duration(i) * time.Second)},
+			extra:         {Path: extra, Size: 2, Hash: "ee", HashState: HashStateHashed},
+		}
+		sn := &SnapshotNode{ID: fmt.Sprintf("snap-%d", i), CreatedAt: created.Add(time.Duration(i) * time.Minute), Files: files}
+		if i > 0 {
+			sn.ParentIDs = []string{fmt.Sprintf("snap-%d", i-1)}
+		}
+		if err := s.Put(sn); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		tables = append(tables, files)
+	}
+	return tables
+}
+
+// TestDirStoreDeltas 测试增量记录只包含变化的条目、按间隔写入关键帧，读取时还原出完整的文件表
+func TestDirStoreDeltas(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDirStore(dir, DirStoreDeltas(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := putDeltaChain(t, s, 8)
+
+	wantDepth := []int{0, 1, 2, 3, 0, 1, 2, 3}
+	for i, depth := range wantDepth {
+		id := fmt.Sprintf("snap-%d", i)
+		rec, err := s.read(id)
+		if err != nil {
+			t.Fatalf("read %s: %v", id, err)
+		}
+		if rec.Depth != depth {
+			t.Errorf("%s: depth = %d; want %d", id, rec.Depth, depth)
+		}
+		if depth == 0 {
+			if rec.Version != storedSnapshotVersion || rec.Base != "" || len(rec.Files) != 3 {
+				t.Errorf("%s: want a full keyframe, got version %d base %q with %d files", id, rec.Version, rec.Base, len(rec.Files))
+			}
+		} else if rec.Version != storedDeltaVersion || rec.Base != fmt.Sprintf("snap-%d", i-1) ||
+			len(rec.Files) != 2 || len(rec.Removed) != 1 || rec.Removed[0] != fmt.Sprintf("/r/extra-%d.txt", i-1) {
+			t.Errorf("%s: want a delta against snap-%d, got %+v", id, i-1, rec)
+		}
+
+		got, err := s.Get(id)
+		if err != nil {
+			t.Fatalf("Get %s: %v", id, err)
+		}
+		if len(got.Files) != len(tables[i]) {
+			t.Fatalf("%s: %d files; want %d", id, len(got.Files), len(tables[i]))
+		}
+		for p, m := range tables[i] {
+			if g := got.Files[p]; g == nil || !sameEntry(g, m) {
+				t.Errorf("%s: %s = %+v; want %+v", id, p, g, m)
+			}
+		}
+	}
+
+	// 新打开的 Store 没有缓存，从磁盘还原父快照作为基准
+	s2, _ := NewDirStore(dir, DirStoreDeltas(3))
+	sn, _ := s.Get("snap-6")
+	sn.ID, sn.ParentIDs = "snap-x", []string{"snap-6"}
+	if err := s2.Put(sn); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := s2.read("snap-x"); rec.Base != "snap-6" || rec.Depth != 3 || len(rec.Files) != 0 || len(rec.Removed) != 0 {
+		t.Errorf("snap-x = %+v; want an empty delta against snap-6", rec)
+	}
+
+	// 未启用增量时读取照常，写入总是完整记录
+	plain, _ := NewDirStore(dir)
+	if got, err := plain.Get("snap-7"); err != nil || !reflect.DeepEqual(keysOf(got.Files), keysOf(tables[7])) {
+		t.Errorf("plain Get = %v, %v", got, err)
+	}
+	sn.ID = "snap-y"
+	_ = plain.Put(sn)
+	if rec, _ := plain.read("snap-y"); rec.Base != "" || rec.Version != storedSnapshotVersion {
+		t.Errorf("plain Put wrote %+v; want a full record", rec)
+	}
+}
+
+// keysOf 返回文件表中的路径集合
+func keysOf(files map[string]*FileMetadata) map[string]bool {
+	out := make(map[string]bool, len(files))
+	for p := range files {
+		out[p] = true
+	}
+	return out
+}
+
+// TestDirStoreBrokenDeltaChain 测试链上的记录缺失或损坏时返回指明断裂位置的 *DeltaChainError，ValidateStore 报告 IssueBrokenDeltaChain
+func TestDirStoreBrokenDeltaChain(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDirStore(dir, DirStoreDeltas(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	putDeltaChain(t, s, 8)
+	_ = os.Remove(filepath.Join(dir, "snap-2"+dirStoreExt))
+	_ = os.WriteFile(filepath.Join(dir, "snap-5"+dirStoreExt), []byte("not gzip"), 0o644)
+
+	_, err = s.Get("snap-3")
+	var chainErr *DeltaChainError
+	if !errors.As(err, &chainErr) || !errors.Is(err, ErrBrokenDeltaChain) {
+		t.Fatalf("Get(snap-3) error = %v; want *DeltaChainError", err)
+	}
+	if chainErr.ID != "snap-3" || chainErr.Broken() != "snap-2" || strings.Join(chainErr.Chain, ",") != "snap-3,snap-2" ||
+		!strings.Contains(err.Error(), "snap-3 → snap-2 broken at snap-2: record is missing") {
+		t.Errorf("error = %+v (%v)", chainErr, err)
+	}
+	if errors.Is(err, ErrSnapshotNotFound) {
+		t.Error("a missing base should not look like a missing snapshot")
+	}
+	if _, err := s.Get("snap-7"); !errors.As(err, &chainErr) || chainErr.Broken() != "snap-5" || len(chainErr.Chain) != 3 {
+		t.Errorf("Get(snap-7) error = %v; want the chain broken at snap-5", err)
+	}
+	if _, err := s.Get("snap-4"); err != nil {
+		t.Errorf("keyframe snap-4 should stay readable: %v", err)
+	}
+
+	// 基准被删除后写入的快照改写为完整记录
+	if err := s.Put(&SnapshotNode{ID: "snap-z", ParentIDs: []string{"snap-2"}, Files: map[string]*FileMetadata{}}); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := s.read("snap-z"); rec.Base != "" {
+		t.Errorf("snap-z = %+v; want a full record", rec)
+	}
+
+	w, err := NewWatcherWithOptions([]string{t.TempDir()}, WithStore(s, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	broken := map[string]string{"snap-3": "snap-2", "snap-6": "snap-5", "snap-7": "snap-5"}
+	found := make(map[string]string)
+	for _, is := range w.ValidateStore() {
+		if is.Kind == IssueBrokenDeltaChain {
+			found[is.SnapshotID] = is.Ref
+		}
+	}
+	if !reflect.DeepEqual(found, broken) {
+		t.Errorf("IssueBrokenDeltaChain = %v; want %v", found, broken)
+	}
+}
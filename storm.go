@@ -0,0 +1,165 @@
+package watcher
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 事件风暴保护的默认参数
+const (
+	DefaultStormDwell       = 5 * time.Second
+	DefaultStormRecovery    = 30 * time.Second
+	DefaultStormMaxDebounce = time.Second
+)
+
+// stormWindow 是速率采样的最短间隔
+const stormWindow = time.Second
+
+// DegradedMode 是进入或退出降级模式时发送到 ErrorChan 的通知，见 WithStormProtection
+//
+// errors.Is(err, ErrDegraded) 对进入与退出的通知都成立，用 Active 区分
+type DegradedMode struct {
+	Active          bool          // true 表示进入降级，false 表示恢复正常
+	NotifyOnly      bool          // StormNotifyOnly：只报告超限，没有实际降级
+	EventsPerSec    float64       // 最近一个采样窗口的事件速率(不含被忽略的事件)
+	HashBytesPerSec float64       // 最近一个采样窗口需要计算哈希的字节速率(含降级期间跳过的文件)
+	Duration        time.Duration // 恢复时：本次降级持续的时间
+}
+
+// Error 实现 error 接口
+func (e *DegradedMode) Error() string {
+	state := "degraded mode"
+	if e.NotifyOnly {
+		state = "limits exceeded"
+	}
+	if e.Active {
+		return fmt.Sprintf("event storm: %s (%.0f events/s, %.0f hashed bytes/s)", state, e.EventsPerSec, e.HashBytesPerSec)
+	}
+	return fmt.Sprintf("event storm subsided after %v", e.Duration)
+}
+
+// Is 使 errors.Is(err, ErrDegraded) 成立
+func (e *DegradedMode) Is(target error) bool {
+	return target == ErrDegraded
+}
+
+// stormState 是事件风暴保护的状态
+//
+// 采样与状态切换只在合并goroutine中进行；degraded 在哈希路径上读取，
+// 累计时间等由 Stats 读取，受 mu 保护
+type stormState struct {
+	degraded     atomic.Bool   // 已降级：停止计算哈希、拉长 flush 间隔
+	skippedBytes atomic.Uint64 // 降级期间因此跳过哈希的字节数
+
+	// 仅合并goroutine访问
+	lastAt     time.Time
+	lastEvents uint64
+	lastBytes  uint64
+	overSince  time.Time
+	underSince time.Time
+
+	mu      sync.Mutex
+	active  bool      // 处于超限状态(含 StormNotifyOnly)
+	since   time.Time // 本次超限开始的时间
+	total   time.Duration
+	entries uint64
+}
+
+// stormEnabled 报告是否配置了任一速率上限
+func (w *Watcher) stormEnabled() bool {
+	return w.cfg.StormMaxEventsPerSec > 0 || w.cfg.StormMaxHashBytesPerSec > 0
+}
+
+// checkStorm 在每次合并 tick 时调用：每隔 stormWindow 计算一次事件与哈希字节速率，
+// 超限持续 StormDwell 后进入降级，低于上限持续 StormRecovery 后恢复
+func (w *Watcher) checkStorm(now time.Time) {
+	if !w.stormEnabled() {
+		return
+	}
+	s := &w.storm
+	events := w.counters.eventsReceived.Load() - w.counters.eventsIgnored.Load()
+	bytes := w.counters.bytesHashed.Load() + s.skippedBytes.Load()
+	if s.lastAt.IsZero() {
+		s.lastAt, s.lastEvents, s.lastBytes = now, events, bytes
+		return
+	}
+	elapsed := now.Sub(s.lastAt)
+	if elapsed < stormWindow {
+		return
+	}
+	start := s.lastAt
+	evRate := float64(events-s.lastEvents) / elapsed.Seconds()
+	byRate := float64(bytes-s.lastBytes) / elapsed.Seconds()
+	s.lastAt, s.lastEvents, s.lastBytes = now, events, bytes
+
+	over := (w.cfg.StormMaxEventsPerSec > 0 && evRate > float64(w.cfg.StormMaxEventsPerSec)) ||
+		(w.cfg.StormMaxHashBytesPerSec > 0 && byRate > float64(w.cfg.StormMaxHashBytesPerSec))
+
+	s.mu.Lock()
+	var note *DegradedMode
+	switch {
+	case over:
+		s.underSince = time.Time{}
+		if s.overSince.IsZero() {
+			s.overSince = start
+		}
+		if !s.active && now.Sub(s.overSince) >= w.cfg.StormDwell {
+			s.active, s.since = true, now
+			s.entries++
+			s.degraded.Store(!w.cfg.StormNotifyOnly)
+			note = &DegradedMode{Active: true, NotifyOnly: w.cfg.StormNotifyOnly, EventsPerSec: evRate, HashBytesPerSec: byRate}
+		}
+	case s.active:
+		s.overSince = time.Time{}
+		if s.underSince.IsZero() {
+			s.underSince = start
+		}
+		if now.Sub(s.underSince) >= w.cfg.StormRecovery {
+			d := now.Sub(s.since)
+			s.active = false
+			s.total += d
+			s.degraded.Store(false)
+			note = &DegradedMode{NotifyOnly: w.cfg.StormNotifyOnly, EventsPerSec: evRate, HashBytesPerSec: byRate, Duration: d}
+		}
+	default:
+		s.overSince = time.Time{}
+	}
+	s.mu.Unlock()
+
+	if note != nil {
+		if !w.cfg.StormNotifyOnly {
+			// 降级期间创建的快照不计算哈希，记录在其配置中
+			w.refreshSnapshotConfig()
+		}
+		if w.emitError(note) {
+			w.logWarn("event storm protection", note)
+		}
+	}
+}
+
+// stormDelaysFlush 报告降级期间本次周期性 flush 是否应跳过：距上次 flush 不足 StormMaxDebounce
+func (w *Watcher) stormDelaysFlush(now time.Time) bool {
+	if !w.storm.degraded.Load() {
+		return false
+	}
+	return now.Sub(time.Unix(0, w.counters.lastFlushAt.Load())) < w.cfg.StormMaxDebounce
+}
+
+// stormStats 返回是否处于降级、进入降级的次数与累计降级时间(含进行中的一次)
+//
+// StormNotifyOnly 时不会实际降级，三者均为零值
+func (w *Watcher) stormStats() (degraded bool, entries uint64, total time.Duration) {
+	s := &w.storm
+	if w.cfg.StormNotifyOnly {
+		return false, 0, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total = s.total
+	if s.active {
+		total += w.now().Sub(s.since)
+	}
+	return s.active, s.entries, total
+}
@@ -0,0 +1,98 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestStormDegradeAndRecover 测试超限持续 StormDwell 后降级(跳过哈希、拉长 flush 间隔)，回落持续 StormRecovery 后恢复
+func TestStormDegradeAndRecover(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.bin")
+	_ = os.WriteFile(file, make([]byte, 100), 0644)
+	w, err := NewWatcherWithOptions([]string{root},
+		WithStormProtection(100, 0, 2*time.Second),
+		WithStormRecovery(3*time.Second, time.Second),
+	)
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+
+	t0 := time.Unix(1000, 0)
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+	w.checkStorm(t0)
+	// 第1秒超限但未满 StormDwell，第2秒满足
+	w.counters.eventsReceived.Add(500)
+	w.checkStorm(at(time.Second))
+	if w.storm.degraded.Load() {
+		t.Fatal("degraded before StormDwell elapsed")
+	}
+	w.counters.eventsReceived.Add(500)
+	w.checkStorm(at(2 * time.Second))
+	if !w.storm.degraded.Load() {
+		t.Fatal("not degraded after sustained storm")
+	}
+	var dm *DegradedMode
+	if err := <-w.ErrorChan; !errors.As(err, &dm) || !dm.Active || !errors.Is(err, ErrDegraded) || dm.EventsPerSec != 500 {
+		t.Fatalf("ErrorChan = %v; want active DegradedMode at 500 events/s", err)
+	}
+
+	meta, _, err := w.RehashFile(file)
+	if err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	if meta.HashState != HashStateSkippedDegraded || meta.Hash != "" || w.storm.skippedBytes.Load() != 100 {
+		t.Errorf("degraded hash: state=%v hash=%q skipped=%d", meta.HashState, meta.Hash, w.storm.skippedBytes.Load())
+	}
+	w.counters.lastFlushAt.Store(at(2 * time.Second).UnixNano())
+	if !w.stormDelaysFlush(at(2500 * time.Millisecond)) {
+		t.Error("flush within StormMaxDebounce not delayed")
+	}
+	if w.stormDelaysFlush(at(3 * time.Second)) {
+		t.Error("flush after StormMaxDebounce delayed")
+	}
+
+	// 风暴平息：回落持续 StormRecovery(3s，从第2秒的窗口起算)后恢复
+	w.checkStorm(at(3 * time.Second))
+	w.checkStorm(at(4 * time.Second))
+	if !w.storm.degraded.Load() {
+		t.Fatal("recovered before StormRecovery elapsed")
+	}
+	w.checkStorm(at(5 * time.Second))
+	if w.storm.degraded.Load() {
+		t.Fatal("still degraded after StormRecovery")
+	}
+	if err := <-w.ErrorChan; !errors.As(err, &dm) || dm.Active || dm.Duration != 3*time.Second {
+		t.Fatalf("ErrorChan = %v; want inactive DegradedMode after 3s", err)
+	}
+	st := w.Stats()
+	if st.Degraded || st.DegradedEntries != 1 || st.DegradedTime != 3*time.Second {
+		t.Errorf("Stats degraded=%v entries=%d time=%v; want false 1 3s", st.Degraded, st.DegradedEntries, st.DegradedTime)
+	}
+}
+
+// TestStormNotifyOnly 测试 StormNotifyOnly 时只发送通知，不降级
+func TestStormNotifyOnly(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithStormProtection(0, 1000, time.Second), WithStormNotifyOnly())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+
+	t0 := time.Unix(1000, 0)
+	w.checkStorm(t0)
+	w.counters.bytesHashed.Add(1 << 20)
+	w.checkStorm(t0.Add(time.Second))
+	var dm *DegradedMode
+	if err := <-w.ErrorChan; !errors.As(err, &dm) || !dm.Active || !dm.NotifyOnly {
+		t.Fatalf("ErrorChan = %v; want notify-only DegradedMode", err)
+	}
+	if w.storm.degraded.Load() || w.Stats().Degraded {
+		t.Error("notify-only storm degraded the watcher")
+	}
+}
@@ -0,0 +1,137 @@
+package watcher
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultSubscriptionBuffer 是 Subscribe 未指定缓冲大小时使用的默认值
+const DefaultSubscriptionBuffer = 1024
+
+// Subscription 是一个独立的事件订阅
+//
+// 与 EventChan 不同，每个订阅有自己的缓冲通道，互不影响；
+// 订阅与 EventChan 相互独立，开启 DisableEventChan 后订阅照常收到全部事件
+// 订阅者消费过慢导致缓冲已满时，新事件对该订阅直接丢弃(计入 Dropped)，不会阻塞事件处理
+// 订阅不再使用时必须调用 Close；Watcher Stop 时所有订阅的通道都会被关闭
+type Subscription struct {
+	// C 按 Seq 递增的顺序投递事件
+	C <-chan FileEvent
+	// StartSeq 是订阅建立时的最新序号，Seq > StartSeq 的事件都会尝试投递到 C
+	StartSeq uint64
+
+	w       *Watcher
+	ch      chan FileEvent
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// subscribers 管理全部订阅，seq 的分配与向订阅者投递在同一把锁内完成，保证每个订阅看到的 Seq 单调递增
+type subscribers struct {
+	mu      sync.Mutex
+	seq     uint64
+	subs    map[*Subscription]struct{}
+	closed  bool
+	dropped atomic.Uint64
+	ring    eventRing // 最近的事件，见 SubscribeFrom
+}
+
+// Subscribe 创建一个新的事件订阅，buffer<=0 时使用 DefaultSubscriptionBuffer
+//
+// 只会收到订阅之后产生的事件(断线重连时用 SubscribeFrom 补上期间的事件)；Watcher 已 Stop 时返回的订阅通道已关闭
+// 并发安全
+func (w *Watcher) Subscribe(buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+	ch := make(chan FileEvent, buffer)
+	s := &Subscription{C: ch, w: w, ch: ch}
+
+	w.subs.mu.Lock()
+	defer w.subs.mu.Unlock()
+	s.StartSeq = w.subs.seq
+	w.subs.addLocked(s)
+	return s
+}
+
+// addLocked 登记订阅，已关闭时直接关闭其通道，调用方需持有 mu
+func (s *subscribers) addLocked(sub *Subscription) {
+	if s.closed {
+		close(sub.ch)
+		sub.once.Do(func() {})
+		return
+	}
+	if s.subs == nil {
+		s.subs = make(map[*Subscription]struct{})
+	}
+	s.subs[sub] = struct{}{}
+}
+
+// Close 取消订阅并关闭通道，可重复调用
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.w.subs.mu.Lock()
+		defer s.w.subs.mu.Unlock()
+		delete(s.w.subs.subs, s)
+		close(s.ch)
+	})
+}
+
+// Dropped 返回因缓冲已满而未投递给该订阅的事件数
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// LastSeq 返回最近一次分配的事件序号(尚未产生事件时为0)
+//
+// 并发安全
+func (w *Watcher) LastSeq() uint64 {
+	w.subs.mu.Lock()
+	defer w.subs.mu.Unlock()
+	return w.subs.seq
+}
+
+// publish 为事件分配序号并非阻塞地投递给全部订阅者
+func (w *Watcher) publish(ev *FileEvent) {
+	w.subs.mu.Lock()
+	defer w.subs.mu.Unlock()
+	w.subs.seq++
+	ev.Seq = w.subs.seq
+	w.subs.ring.add(*ev)
+	if w.subs.closed {
+		return
+	}
+	for s := range w.subs.subs {
+		select {
+		case s.ch <- *ev:
+		default:
+			s.dropped.Add(1)
+			w.subs.dropped.Add(1)
+		}
+	}
+}
+
+// closeSubscribers 在 Stop 时关闭全部订阅，之后的 publish 只分配序号
+func (w *Watcher) closeSubscribers() {
+	w.subs.mu.Lock()
+	defer w.subs.mu.Unlock()
+	w.subs.closed = true
+	for s := range w.subs.subs {
+		s.once.Do(func() { close(s.ch) })
+	}
+	w.subs.subs = nil
+}
+
+// replayLen 返回回放缓冲中的事件数
+func (w *Watcher) replayLen() int {
+	w.subs.mu.Lock()
+	defer w.subs.mu.Unlock()
+	return w.subs.ring.n
+}
+
+// subscriberCount 返回当前订阅数
+func (w *Watcher) subscriberCount() int {
+	w.subs.mu.Lock()
+	defer w.subs.mu.Unlock()
+	return len(w.subs.subs)
+}
@@ -0,0 +1,67 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestSubscribe 测试多个订阅各自收到事件、序号递增以及关闭后的清理
+func TestSubscribe(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+
+	w := newTestWatcher(t, root)
+	s1 := w.Subscribe(0)
+	s2 := w.Subscribe(1)
+	if s1.StartSeq != 0 || w.Stats().Subscribers != 2 {
+		t.Fatalf("unexpected initial state: start=%d subscribers=%d", s1.StartSeq, w.Stats().Subscribers)
+	}
+
+	w.handleFileChange(file, fsnotify.Create)
+	w.handleFileChange(file, fsnotify.Write)
+
+	for i, want := range []uint64{1, 2} {
+		ev := <-s1.C
+		if ev.Seq != want || ev.FilePath != file {
+			t.Errorf("s1 event %d: seq=%d path=%s", i, ev.Seq, ev.FilePath)
+		}
+	}
+	if ev := <-s2.C; ev.Seq != 1 {
+		t.Errorf("s2 first event seq=%d; want 1", ev.Seq)
+	}
+	if s2.Dropped() != 1 || w.Stats().SubscriberDropped != 1 {
+		t.Errorf("s2 should have dropped one event, got %d", s2.Dropped())
+	}
+	if ev := <-w.EventChan; ev.Seq != 1 {
+		t.Errorf("EventChan should share the sequence, got %d", ev.Seq)
+	}
+
+	s3 := w.Subscribe(0)
+	if s3.StartSeq != 2 || w.LastSeq() != 2 {
+		t.Errorf("StartSeq=%d LastSeq=%d; want 2/2", s3.StartSeq, w.LastSeq())
+	}
+
+	s1.Close()
+	s1.Close()
+	if _, ok := <-s1.C; ok {
+		t.Error("closed subscription channel should be closed")
+	}
+	if w.Stats().Subscribers != 2 {
+		t.Errorf("Subscribers = %d; want 2", w.Stats().Subscribers)
+	}
+
+	w.closeSubscribers()
+	if _, ok := <-s3.C; ok {
+		t.Error("subscriptions should be closed when the watcher stops")
+	}
+	s2.Close()
+	if s := w.Subscribe(0); s.C == nil {
+		t.Error("Subscribe after stop should return a closed subscription")
+	} else if _, ok := <-s.C; ok {
+		t.Error("Subscribe after stop should return a closed channel")
+	}
+}
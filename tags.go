@@ -0,0 +1,150 @@
+package watcher
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TagSnapshot 给快照打上标签(如 "golden"、"release-1.2")
+//
+// 同名标签已存在时会被移动到新的快照上
+// 快照不存在或标签为空时返回错误
+// 并发安全
+func (w *Watcher) TagSnapshot(tag, id string) error {
+	if tag == "" {
+		return fmt.Errorf("tag must not be empty")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.snapshots.get(id) == nil {
+		return errSnapshotNotFound(id)
+	}
+	w.tags[tag] = id
+	return nil
+}
+
+// UntagSnapshot 删除标签，返回标签此前是否存在
+//
+// 并发安全
+func (w *Watcher) UntagSnapshot(tag string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.tags[tag]
+	delete(w.tags, tag)
+	return ok
+}
+
+// SnapshotByTag 返回标签指向的快照
+//
+// 若标签不存在则返回nil；指向已换出快照时从 Store 读回
+// 并发安全
+func (w *Watcher) SnapshotByTag(tag string) *SnapshotNode {
+	w.mu.RLock()
+	id, ok := w.tags[tag]
+	w.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return w.snapshotByID(id)
+}
+
+// Tags 返回全部标签(标签 -> 快照ID)的副本
+//
+// 并发安全
+func (w *Watcher) Tags() map[string]string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	out := make(map[string]string, len(w.tags))
+	for tag, id := range w.tags {
+		out[tag] = id
+	}
+	return out
+}
+
+// TagsOf 返回指向某个快照的全部标签(按名称排序)
+//
+// 并发安全
+func (w *Watcher) TagsOf(id string) []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var out []string
+	for tag, sid := range w.tags {
+		if sid == id {
+			out = append(out, tag)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SetSnapshotDescription 修改快照的描述
+//
+// 已发布的快照不可变：这里会用一个仅描述不同的新节点替换原节点(Files 共享)，
+// 已持有旧节点指针的调用方看到的仍是旧描述；已换出到 Store 的快照直接改写 Store 中的副本。Close 之后返回 ErrStopped
+// 并发安全
+func (w *Watcher) SetSnapshotDescription(id, desc string) error {
+	if err := w.beginMutation(); err != nil {
+		return err
+	}
+	defer w.endMutation()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	old := w.snapshots.get(id)
+	if old == nil {
+		return errSnapshotNotFound(id)
+	}
+	if old.spilled {
+		return w.describeSpilledLocked(id, desc)
+	}
+	sn := withDescription(old, desc)
+	w.snapshots.put(sn)
+	if w.head.Load() == old {
+		w.head.Store(sn)
+	}
+	return nil
+}
+
+// withDescription 返回只有描述与 old 不同的新节点，共享 Files 与已构建的前缀树
+func withDescription(old *SnapshotNode, desc string) *SnapshotNode {
+	sn := &SnapshotNode{
+		ID:          old.ID,
+		Instance:    old.Instance,
+		ParentIDs:   old.ParentIDs,
+		CreatedAt:   old.CreatedAt,
+		Description: desc,
+		Files:       old.Files,
+		RootHash:    old.RootHash,
+		Config:      old.Config,
+
+		ChangedPaths: old.ChangedPaths,
+		Truncated:    old.Truncated,
+	}
+	sn.trie.Store(old.trie.Load())
+	return sn
+}
+
+// describeSpilledLocked 修改已换出快照的描述：读回、改写并重新写入 Store，调用方需持有 w.mu 写锁
+func (w *Watcher) describeSpilledLocked(id, desc string) error {
+	full, err := w.loadSnapshot(id)
+	if err != nil {
+		return err
+	}
+	sn := &SnapshotNode{
+		ID:          full.ID,
+		Instance:    full.Instance,
+		ParentIDs:   full.ParentIDs,
+		CreatedAt:   full.CreatedAt,
+		Description: desc,
+		Files:       full.Files,
+		RootHash:    full.RootHash,
+		Config:      full.Config,
+
+		ChangedPaths: full.ChangedPaths,
+		Truncated:    full.Truncated,
+	}
+	if err := w.cfg.Store.Put(sn); err != nil {
+		return err
+	}
+	w.spill.cache.add(sn)
+	return nil
+}
@@ -0,0 +1,65 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestTagSnapshot 测试打标签、移动标签与删除标签
+func TestTagSnapshot(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+
+	w := newTestWatcher(t, root)
+	first := w.GetCurrentSnapshot()
+	if err := w.TagSnapshot("golden", first.ID); err != nil {
+		t.Fatalf("TagSnapshot failed: %v", err)
+	}
+	if err := w.TagSnapshot("bad", "no-such-id"); err == nil {
+		t.Error("tagging an unknown snapshot should fail")
+	}
+	if sn := w.SnapshotByTag("golden"); sn != first {
+		t.Errorf("SnapshotByTag returned %v; want %s", sn, first.ID)
+	}
+
+	w.handleFileChange(file, fsnotify.Create)
+	second := w.GetCurrentSnapshot()
+	_ = w.TagSnapshot("golden", second.ID)
+	_ = w.TagSnapshot("latest", second.ID)
+	if tags := w.TagsOf(second.ID); len(tags) != 2 || tags[0] != "golden" || tags[1] != "latest" {
+		t.Errorf("TagsOf = %v; want [golden latest]", tags)
+	}
+	if len(w.TagsOf(first.ID)) != 0 {
+		t.Error("moved tag should no longer point at the first snapshot")
+	}
+
+	if !w.UntagSnapshot("golden") || w.UntagSnapshot("golden") {
+		t.Error("UntagSnapshot should report whether the tag existed")
+	}
+	if w.SnapshotByTag("golden") != nil {
+		t.Error("removed tag should not resolve")
+	}
+}
+
+// TestSetSnapshotDescription 测试修改描述时不改动已发布的节点
+func TestSetSnapshotDescription(t *testing.T) {
+	w := newTestWatcher(t, t.TempDir())
+	old := w.GetCurrentSnapshot()
+	if err := w.SetSnapshotDescription(old.ID, "baseline"); err != nil {
+		t.Fatalf("SetSnapshotDescription failed: %v", err)
+	}
+	cur := w.GetCurrentSnapshot()
+	if cur.Description != "baseline" || cur.ID != old.ID {
+		t.Errorf("current = %s %q; want %s \"baseline\"", cur.ID, cur.Description, old.ID)
+	}
+	if old.Description != "Initial snapshot" {
+		t.Errorf("published node was mutated: %q", old.Description)
+	}
+	if err := w.SetSnapshotDescription("no-such-id", "x"); err == nil {
+		t.Error("describing an unknown snapshot should fail")
+	}
+}
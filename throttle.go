@@ -0,0 +1,116 @@
+package watcher
+
+import (
+	"fmt"
+	"maps"
+	"time"
+)
+
+// throttleState 是 MinSnapshotInterval 的状态，受 w.mu 保护
+//
+// snap 是尚未发布的快照：窗口关闭期间的提交都原地应用到它的文件表上(它对外不可见，可以安全修改)；
+// events 是等待该快照发布后才发送的事件；lastAt 是上一次发布快照的时间
+type throttleState struct {
+	snap   *SnapshotNode
+	n      int
+	paths  changedPathSet
+	events []FileEvent
+	lastAt time.Time
+}
+
+// throttled 报告是否启用了快照限速
+func (w *Watcher) throttled() bool {
+	return w.cfg.MinSnapshotInterval > 0 && !w.cfg.DisableSnapshots
+}
+
+// commitThrottledLocked 是启用 MinSnapshotInterval 时 commitSnapshot 的实现，调用方需持有 w.mu 写锁
+//
+// changes 总是先并入待发布快照；距上一次发布已满 MinSnapshotInterval 时立即发布，
+// 否则留待窗口打开(runAggregator 的下一个 tick)或 Stop 时发布
+func (w *Watcher) commitThrottledLocked(desc string, changes map[string]*FileMetadata, focus string) commitResult {
+	t := &w.throttle
+	if t.snap == nil {
+		parent := w.head.Load()
+		t.snap = &SnapshotNode{
+			Instance:    w.cfg.InstanceID,
+			ParentIDs:   []string{parent.ID},
+			Description: desc,
+			Files:       maps.Clone(parent.Files),
+		}
+		if t.snap.Files == nil {
+			t.snap.Files = make(map[string]*FileMetadata)
+		}
+	}
+	pending := t.snap
+	old := live(pending.Files[focus])
+	w.applyChangesLocked(pending.Files, changes)
+	t.paths.add(changes, w.cfg.MaxChangedPaths)
+	t.n++
+	if t.n > 1 {
+		w.counters.changesCoalesced.Add(1)
+	}
+
+	c := commitResult{pending: pending, old: old, cur: live(pending.Files[focus])}
+	if w.now().Sub(t.lastAt) >= w.cfg.MinSnapshotInterval {
+		c.ready, c.describe = w.publishPendingLocked()
+	}
+	return c
+}
+
+// publishPendingLocked 发布待发布快照，返回此前延后的事件(NewSnap 已指向该快照)与其描述任务，调用方需持有 w.mu 写锁，
+// 并在释放后执行描述任务(见 describeCommitted)
+func (w *Watcher) publishPendingLocked() ([]FileEvent, describeJob) {
+	t := &w.throttle
+	sn := t.snap
+	if sn == nil {
+		return nil, describeJob{}
+	}
+	if t.n > 1 {
+		sn.Description = fmt.Sprintf("%s (coalesced %d changes)", sn.Description, t.n)
+	}
+	t.paths.apply(sn)
+	var job describeJob
+	if parent := w.head.Load(); parent != nil && parent.ID == sn.ParentIDs[0] {
+		job = w.describeJobFor(sn, parent)
+	}
+	sn.ID = w.newSnapID()
+	sn.CreatedAt = w.now()
+	sn.Config = w.snapshotConfig()
+	sn.RootHash = w.rootHashLocked(sn.Files)
+	w.publishLocked(sn)
+
+	evs := t.events
+	for i := range evs {
+		evs[i].NewSnap = sn
+	}
+	*t = throttleState{lastAt: sn.CreatedAt}
+	return evs, job
+}
+
+// flushThrottled 在窗口已打开(或 force)时发布待发布快照并发送延后的事件
+//
+// 由 runAggregator 在每个 tick 调用，Stop 时以 force=true 调用
+func (w *Watcher) flushThrottled(force bool) {
+	if !w.throttled() {
+		return
+	}
+	w.mu.Lock()
+	var c commitResult
+	if w.throttle.snap != nil && (force || w.now().Sub(w.throttle.lastAt) >= w.cfg.MinSnapshotInterval) {
+		c.ready, c.describe = w.publishPendingLocked()
+	}
+	w.mu.Unlock()
+	w.describeCommitted(&c)
+	w.emitEvents(c.ready)
+}
+
+// deferEvent 把尚未发布的快照上的事件加入等待队列，返回 false 表示该快照已被发布，调用方应直接发送
+func (w *Watcher) deferEvent(pending *SnapshotNode, ev FileEvent) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.throttle.snap != pending {
+		return false
+	}
+	w.throttle.events = append(w.throttle.events, ev)
+	return true
+}
@@ -0,0 +1,66 @@
+package watcher_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchertest"
+)
+
+// TestMinSnapshotInterval 测试窗口内的变更并入同一个快照，事件延后到快照发布时发送
+func TestMinSnapshotInterval(t *testing.T) {
+	h := watchertest.NewHarness(t, watcher.WithMinSnapshotInterval(time.Second))
+
+	// 窗口打开时的第一个变更立即发布
+	h.Touch("a.txt", "1")
+	h.AdvanceDebounce()
+	first := h.ExpectEvent(t, watchertest.Matcher{Path: "a.txt", Op: watcher.OpCreate})
+	if first.NewSnap == nil || h.W.GetCurrentSnapshot() != first.NewSnap {
+		t.Fatalf("first change should publish immediately: %v", first)
+	}
+
+	h.Touch("b.txt", "1")
+	h.AdvanceDebounce()
+	h.Touch("a.txt", "2")
+	h.AdvanceDebounce()
+	h.ExpectNoEvent(t)
+	if cur := h.W.GetCurrentSnapshot(); cur != first.NewSnap {
+		t.Fatalf("snapshot published inside the window: %v", cur)
+	}
+
+	h.Clock.Advance(time.Second)
+	var evs []watcher.FileEvent
+	deadline := time.Now().Add(5 * time.Second)
+	for len(evs) < 2 && time.Now().Before(deadline) {
+		evs = append(evs, h.Events()...)
+		time.Sleep(time.Millisecond)
+	}
+	if len(evs) != 2 {
+		t.Fatalf("got %d deferred events; want 2", len(evs))
+	}
+	snap := evs[0].NewSnap
+	if snap == nil || evs[1].NewSnap != snap || snap.ParentIDs[0] != first.NewSnap.ID {
+		t.Fatalf("deferred events should point at one new child snapshot: %v / %v", evs[0], evs[1])
+	}
+	if !strings.Contains(snap.Description, "coalesced 2 changes") {
+		t.Errorf("description = %q", snap.Description)
+	}
+	for _, ev := range evs {
+		if ev.FilePath == h.Path("a.txt") && (ev.OldMeta == nil || ev.OldMeta.Hash != watchertest.SHA256("1")) {
+			t.Errorf("OldMeta of the coalesced write should be the previous version: %v", ev.OldMeta)
+		}
+	}
+	h.ExpectFile(t, "a.txt", watchertest.SHA256("2"))
+	h.ExpectFile(t, "b.txt", watchertest.SHA256("1"))
+	if st := h.W.Stats(); st.ChangesCoalesced != 1 || st.SnapshotsCreated != 2 {
+		t.Errorf("coalesced=%d created=%d; want 1/2", st.ChangesCoalesced, st.SnapshotsCreated)
+	}
+
+	// Stop 时立即发布窗口内的变更
+	h.Touch("c.txt", "1")
+	h.AdvanceDebounce()
+	_ = h.W.Close()
+	h.ExpectFile(t, "c.txt", watchertest.SHA256("1"))
+}
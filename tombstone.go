@@ -0,0 +1,113 @@
+package watcher
+
+import "sync/atomic"
+
+// 删除标记(墓碑)
+//
+// ConfigWatcher.TombstoneSnapshots 大于0时，applyChangesLocked 把被删除的条目(含子树)以 Deleted 为 true 的副本写回文件表。
+// 墓碑不登记到目录层级索引(children)，因此不参与目录哈希与 RootHash，重新创建同一路径时按新增处理。
+// 每个墓碑的剩余寿命记录在 tombstones 中，每发布一个快照递减一次(见 expireTombstonesLocked)，
+// 到期时从即将发布的快照中移除。读取当前状态的内部代码通过 live 把墓碑视为不存在
+
+// tombstones 记录当前状态中的墓碑，受 w.mu 保护
+type tombstones struct {
+	left map[string]int // 路径 -> 还要经过的快照数，为0时在下一次发布时清除
+	n    atomic.Int64   // len(left)，供 Stats 无锁读取
+}
+
+// IncludeDeleted 让查询包含墓碑(见 WithTombstones)：FilesUnder、FilesMatching 返回墓碑，
+// GetFiles 把墓碑作为找到的条目返回，DiffSnapshots 中被删除条目的 New 为其墓碑(可读取 DeletedAt)
+func IncludeDeleted() QueryOption {
+	return func(o *queryOptions) {
+		o.deleted = true
+	}
+}
+
+// tombstoning 报告是否以墓碑代替删除
+func (w *Watcher) tombstoning() bool {
+	return w.cfg.TombstoneSnapshots > 0 && !w.cfg.DisableSnapshots
+}
+
+// live 返回未被删除的条目：m 为nil或墓碑时返回nil
+func live(m *FileMetadata) *FileMetadata {
+	if m == nil || m.Deleted {
+		return nil
+	}
+	return m
+}
+
+// FileCount 返回快照中的条目数，不含墓碑
+func (sn *SnapshotNode) FileCount() int {
+	sn.deadOnce.Do(func() {
+		for _, m := range sn.Files {
+			if m.Deleted {
+				sn.dead++
+			}
+		}
+	})
+	return len(sn.Files) - sn.dead
+}
+
+// collectTreeLocked 把 files 中 path 及其子树(按层级索引)的条目记入 out，调用方需持有 w.mu 写锁
+func (w *Watcher) collectTreeLocked(files map[string]*FileMetadata, path string, out map[string]*FileMetadata) {
+	if m := live(files[path]); m != nil {
+		out[path] = m
+	}
+	for child := range w.children[path] {
+		w.collectTreeLocked(files, child, out)
+	}
+}
+
+// buryLocked 把 removed 中没有被重新创建的条目作为墓碑写回 files，调用方需持有 w.mu 写锁
+func (w *Watcher) buryLocked(files map[string]*FileMetadata, removed map[string]*FileMetadata) {
+	if len(removed) == 0 {
+		return
+	}
+	t := &w.tombs
+	if t.left == nil {
+		t.left = make(map[string]int)
+	}
+	now := w.now()
+	for p, m := range removed {
+		if _, ok := files[p]; ok {
+			continue
+		}
+		c := *m
+		c.Deleted = true
+		c.DeletedAt = now
+		c.rewritten = false
+		files[p] = &c
+		// 删除所在的快照发布时也会递减一次
+		t.left[p] = w.cfg.TombstoneSnapshots + 1
+	}
+	t.n.Store(int64(len(t.left)))
+}
+
+// reviveLocked 在 path 被重新创建时清除其墓碑记录，调用方需持有 w.mu 写锁
+func (w *Watcher) reviveLocked(path string) {
+	t := &w.tombs
+	if _, ok := t.left[path]; ok {
+		delete(t.left, path)
+		t.n.Store(int64(len(t.left)))
+	}
+}
+
+// expireTombstonesLocked 在发布 sn 前调用：递减各墓碑的剩余寿命，到期的从 sn.Files 中移除
+// 调用方需持有 w.mu 写锁
+func (w *Watcher) expireTombstonesLocked(sn *SnapshotNode) {
+	t := &w.tombs
+	if len(t.left) == 0 {
+		return
+	}
+	for p, n := range t.left {
+		if n > 0 {
+			t.left[p] = n - 1
+			continue
+		}
+		if m := sn.Files[p]; m != nil && m.Deleted {
+			delete(sn.Files, p)
+		}
+		delete(t.left, p)
+	}
+	t.n.Store(int64(len(t.left)))
+}
@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestTombstoneLifecycle 测试删除的子树以墓碑保留：查询默认忽略、IncludeDeleted 时返回、不影响 RootHash，
+// 经过 TombstoneSnapshots 个后续快照后被清除
+func TestTombstoneLifecycle(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithDisableEventChan(), WithTombstones(2))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	sub := w.keyOf(filepath.Join(root, "sub"))
+	a, b := sub+"/a.txt", sub+"/b.txt"
+	_ = os.Mkdir(sub, 0755)
+	for _, p := range []string{a, b} {
+		_ = os.WriteFile(p, []byte(p), 0644)
+		w.handleFileChange(p, fsnotify.Create)
+	}
+	before := w.GetCurrentSnapshot()
+
+	_ = os.RemoveAll(sub)
+	w.handleFileChange(sub, fsnotify.Remove)
+	cur := w.GetCurrentSnapshot()
+	for _, p := range []string{sub, a, b} {
+		m := cur.Files[p]
+		if m == nil || !m.Deleted || m.DeletedAt.IsZero() {
+			t.Fatalf("Files[%q] = %+v; want a tombstone", p, m)
+		}
+	}
+	if n := cur.FileCount(); n != 1 {
+		t.Errorf("FileCount = %d; want 1 (the root)", n)
+	}
+	if n := w.Stats().Tombstones; n != 3 {
+		t.Errorf("Stats().Tombstones = %d; want 3", n)
+	}
+	if cur.Lookup(a) != nil {
+		t.Error("Lookup returned a tombstone")
+	}
+	if files, _ := w.FilesUnder(cur.ID, sub); len(files) != 0 {
+		t.Errorf("FilesUnder = %d entries; want none", len(files))
+	}
+	if files, _ := w.FilesUnder(cur.ID, sub, IncludeDeleted()); len(files) != 3 {
+		t.Errorf("FilesUnder(IncludeDeleted) = %d entries; want 3", len(files))
+	}
+	if found, missing, _ := w.GetFiles(cur.ID, []string{a}); len(found) != 0 || len(missing) != 1 {
+		t.Errorf("GetFiles = %v, missing %v; want a.txt missing", found, missing)
+	}
+	if found, _, _ := w.GetFiles(cur.ID, []string{a}, IncludeDeleted()); found[a] == nil || !found[a].Deleted {
+		t.Errorf("GetFiles(IncludeDeleted) = %v; want the tombstone", found)
+	}
+
+	d, err := w.DiffSnapshots(before.ID, cur.ID)
+	if err != nil || len(d.Removed) != 3 || len(d.Added) != 0 || len(d.Modified) != 0 || d.Removed[0].New != nil {
+		t.Fatalf("diff = %+v, %v; want 3 removals", d, err)
+	}
+	if d, _ := w.DiffSnapshots(before.ID, cur.ID, IncludeDeleted()); d.Removed[0].New == nil || !d.Removed[0].New.Deleted {
+		t.Errorf("diff(IncludeDeleted) removal New = %+v; want the tombstone", d.Removed[0].New)
+	}
+	if h := w.FileHistory(a); len(h) != 2 || h[0].Meta != nil {
+		t.Errorf("FileHistory = %+v; want the deletion first", h)
+	}
+
+	// 墓碑不参与目录哈希：RootHash 与只含正常条目的文件表重新计算的结果一致
+	liveFiles := make(map[string]*FileMetadata)
+	for p, m := range cur.Files {
+		if !m.Deleted {
+			liveFiles[p] = m
+		}
+	}
+	if got := recomputeRootHash(liveFiles, w.roots); cur.RootHash != got || cur.RootHash == before.RootHash {
+		t.Errorf("RootHash = %s; want %s (computed without tombstones)", cur.RootHash, got)
+	}
+
+	// 之后的 2 个快照中仍保留，第 3 个快照中清除
+	c := w.keyOf(filepath.Join(root, "c.txt"))
+	for i := 1; i <= 3; i++ {
+		_ = os.WriteFile(c, []byte{byte(i)}, 0644)
+		w.handleFileChange(c, fsnotify.Write)
+		_, kept := w.GetCurrentSnapshot().Files[a]
+		if want := i <= 2; kept != want {
+			t.Fatalf("after %d later snapshots tombstone kept = %v; want %v", i, kept, want)
+		}
+	}
+	if n := w.Stats().Tombstones; n != 0 {
+		t.Errorf("Stats().Tombstones = %d after expiry; want 0", n)
+	}
+}
+
+// TestTombstoneRecreate 测试重新创建被删除的路径时墓碑被替换为正常条目
+func TestTombstoneRecreate(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithFS(osFS{}, replaySource{}), WithDisableEventChan(), WithTombstones(5))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	sub := w.keyOf(filepath.Join(root, "sub"))
+	file := sub + "/a.txt"
+
+	_ = os.Mkdir(sub, 0755)
+	_ = os.WriteFile(file, []byte("one"), 0644)
+	w.handleFileChange(file, fsnotify.Create)
+	first := w.GetCurrentSnapshot()
+	_ = os.RemoveAll(sub)
+	w.handleFileChange(sub, fsnotify.Remove)
+	_ = os.Mkdir(sub, 0755)
+	_ = os.WriteFile(file, []byte("one"), 0644)
+	w.handleFileChange(file, fsnotify.Create)
+
+	cur := w.GetCurrentSnapshot()
+	for _, p := range []string{sub, file} {
+		if m := cur.Files[p]; m == nil || m.Deleted {
+			t.Fatalf("Files[%q] = %+v; want a live entry", p, m)
+		}
+	}
+	if n := w.Stats().Tombstones; n != 0 {
+		t.Errorf("Stats().Tombstones = %d; want 0", n)
+	}
+	if cur.Files[sub].Hash != first.Files[sub].Hash {
+		t.Error("recreated directory hash differs from the original")
+	}
+}
@@ -0,0 +1,24 @@
+package watcher
+
+import "time"
+
+// BatchTracer 是批次处理的追踪钩子，通过 ConfigWatcher.Tracer 注入
+//
+// 核心包不依赖任何追踪库；OpenTelemetry 实现见子包 watcherotel
+// 未配置时(nil)事件处理路径上只有一次 nil 判断，没有额外开销
+type BatchTracer interface {
+	// StartBatch 在一个 flush 批次开始处理时调用，paths 为本批次的全部路径
+	StartBatch(paths []string) BatchSpan
+}
+
+// BatchSpan 表示一个正在处理的 flush 批次
+//
+// 批次内的路径由多个worker并发处理，实现必须是并发安全的
+type BatchSpan interface {
+	// FileHashed 在单个文件计算哈希完成后调用(包括失败的情况)
+	FileHashed(path string, start time.Time, d time.Duration, err error)
+	// SnapshotCreated 在批次内某个路径的变更提交为快照后调用
+	SnapshotCreated(path, snapshotID string)
+	// End 在批次内全部路径处理完成后调用一次
+	End()
+}
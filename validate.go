@@ -0,0 +1,323 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// IssueKind 表示 ValidateStore 发现的问题类型
+type IssueKind int
+
+const (
+	IssueDanglingParent   IssueKind = iota // ParentIDs 指向不存在的快照
+	IssueMissingHead                       // HEAD 指向的快照不存在
+	IssueTimeOrder                         // 快照的 CreatedAt 早于其父快照
+	IssueDuplicateID                       // Store 中以某个ID保存的记录实际是另一个ID(重复或被改名)
+	IssueHashMismatch                      // RootHash 与按文件表重新计算的结果不一致
+	IssueUnreadable                        // Store 中的记录无法读取(损坏或格式不支持)
+	IssueBrokenDeltaChain                  // 增量记录回溯到关键帧的链条断裂(DirStoreDeltas)，Ref 为出问题的记录
+)
+
+// String 返回问题类型的可读名称
+func (k IssueKind) String() string {
+	switch k {
+	case IssueDanglingParent:
+		return "DanglingParent"
+	case IssueMissingHead:
+		return "MissingHead"
+	case IssueTimeOrder:
+		return "TimeOrder"
+	case IssueDuplicateID:
+		return "DuplicateID"
+	case IssueHashMismatch:
+		return "HashMismatch"
+	case IssueUnreadable:
+		return "Unreadable"
+	case IssueBrokenDeltaChain:
+		return "BrokenDeltaChain"
+	}
+	return fmt.Sprintf("IssueKind(%d)", int(k))
+}
+
+// ValidationIssue 是 ValidateStore 发现的一个问题，实现 error 接口
+//
+// SnapshotID：出问题的快照；Ref：相关的另一个ID(缺失的父快照、HEAD、记录中的实际ID)；
+// Detail：供阅读的补充说明(时间、哈希、底层错误)
+type ValidationIssue struct {
+	Kind       IssueKind
+	SnapshotID string
+	Ref        string
+	Detail     string
+}
+
+// Error 实现 error 接口
+func (i ValidationIssue) Error() string {
+	msg := fmt.Sprintf("snapshot store: %s: snapshot %s", i.Kind, i.SnapshotID)
+	if i.Ref != "" {
+		msg += " (" + i.Ref + ")"
+	}
+	if i.Detail != "" {
+		msg += ": " + i.Detail
+	}
+	return msg
+}
+
+// HeadStore 是可选接口：记录 HEAD 的 SnapshotStore(如 DirStore)在 Close 时保存 HEAD，ValidateStore 据此检查
+type HeadStore interface {
+	SetHead(id string) error
+	Head() (string, error) // 未记录过 HEAD 时返回空串
+}
+
+// ValidateStore 检查内存中的快照与 ConfigWatcher.Store 中持久化的快照是否构成一致的历史
+//
+// 检查项：每个 ParentIDs 指向存在的快照；HEAD(当前快照，以及 HeadStore 记录的 HEAD)存在；
+// 沿父链 CreatedAt 不倒退；Store 中没有重复或与记录不符的ID；RootHash(非空时)与按文件表重新计算的结果一致。
+// RootHash 按本 Watcher 的监控根重新计算，用不同监控根写入的 Store 会报告 IssueHashMismatch
+// 会逐个读取 Store 中的全部快照(不经过也不填充 LRU 缓存)；未配置 Store 时只检查内存中的快照
+// 问题按快照ID排序；读取 Store 列表失败时返回只含一个 IssueUnreadable 的结果
+func (w *Watcher) ValidateStore() []ValidationIssue {
+	if w.cfg.DisableSnapshots {
+		return nil
+	}
+	nodes, issues := w.collectForValidation()
+
+	head := w.head.Load().ID
+	if nodes[head] == nil {
+		issues = append(issues, ValidationIssue{Kind: IssueMissingHead, SnapshotID: head, Ref: "HEAD", Detail: "current snapshot is not indexed"})
+	}
+	if hs, ok := w.cfg.Store.(HeadStore); ok {
+		if id, err := hs.Head(); err != nil {
+			issues = append(issues, ValidationIssue{Kind: IssueUnreadable, SnapshotID: "HEAD", Detail: err.Error()})
+		} else if id != "" && nodes[id] == nil {
+			issues = append(issues, ValidationIssue{Kind: IssueMissingHead, SnapshotID: id, Ref: "stored HEAD", Detail: "stored HEAD points at a missing snapshot"})
+		}
+	}
+
+	for id, sn := range nodes {
+		for _, pid := range sn.ParentIDs {
+			parent := nodes[pid]
+			if parent == nil {
+				issues = append(issues, ValidationIssue{Kind: IssueDanglingParent, SnapshotID: id, Ref: pid, Detail: "parent snapshot does not exist"})
+				continue
+			}
+			if sn.CreatedAt.Before(parent.CreatedAt) {
+				issues = append(issues, ValidationIssue{Kind: IssueTimeOrder, SnapshotID: id, Ref: pid,
+					Detail: fmt.Sprintf("created at %s, before its parent (%s)", sn.CreatedAt.Format(time.RFC3339Nano), parent.CreatedAt.Format(time.RFC3339Nano))})
+			}
+		}
+		if sn.RootHash != "" {
+			if got := recomputeRootHash(sn.Files, w.roots); got != sn.RootHash {
+				issues = append(issues, ValidationIssue{Kind: IssueHashMismatch, SnapshotID: id, Detail: fmt.Sprintf("recorded root hash %s, recomputed %s", sn.RootHash, got)})
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].SnapshotID != issues[j].SnapshotID {
+			return issues[i].SnapshotID < issues[j].SnapshotID
+		}
+		return issues[i].Kind < issues[j].Kind
+	})
+	return issues
+}
+
+// collectForValidation 汇总内存中的完整快照与 Store 中的全部快照(内存中的优先)，并报告 Store 中读不出或ID不符的记录
+func (w *Watcher) collectForValidation() (map[string]*SnapshotNode, []ValidationIssue) {
+	nodes := make(map[string]*SnapshotNode)
+	stubs := make(map[string]*SnapshotNode)
+	for _, sn := range w.snapshots.all() {
+		if sn.spilled {
+			stubs[sn.ID] = sn
+			continue
+		}
+		nodes[sn.ID] = sn
+	}
+	if w.cfg.Store == nil {
+		return nodes, nil
+	}
+
+	var issues []ValidationIssue
+	ids, err := w.cfg.Store.List()
+	if err != nil {
+		return nodes, []ValidationIssue{{Kind: IssueUnreadable, SnapshotID: "*", Detail: err.Error()}}
+	}
+	for _, id := range ids {
+		if nodes[id] != nil {
+			continue
+		}
+		sn, err := w.cfg.Store.Get(id)
+		var chainErr *DeltaChainError
+		if errors.As(err, &chainErr) {
+			issues = append(issues, ValidationIssue{Kind: IssueBrokenDeltaChain, SnapshotID: id, Ref: chainErr.Broken(), Detail: err.Error()})
+			continue
+		}
+		if err != nil {
+			issues = append(issues, ValidationIssue{Kind: IssueUnreadable, SnapshotID: id, Detail: err.Error()})
+			continue
+		}
+		if sn.ID != id {
+			issues = append(issues, ValidationIssue{Kind: IssueDuplicateID, SnapshotID: id, Ref: sn.ID, Detail: "record is stored under a different ID"})
+			continue
+		}
+		nodes[id] = sn
+	}
+	// 已换出但 Store 中读不到的快照仍按占位节点参与父链检查，避免同一个缺失被重复报告为悬空父节点
+	for id, stub := range stubs {
+		if nodes[id] == nil {
+			nodes[id] = stub
+		}
+	}
+	return nodes, issues
+}
+
+// recomputeRootHash 按文件表自底向上重新计算目录哈希，再按 roots 汇总出 RootHash，不修改 files
+func recomputeRootHash(files map[string]*FileMetadata, roots []string) string {
+	return recomputeRootHashWith(files, roots, appendHashEntry)
+}
+
+// recomputeRootHashWith 同 recomputeRootHash，条目按 enc 编码
+func recomputeRootHashWith(files map[string]*FileMetadata, roots []string, enc hashEntryFunc) string {
+	children := make(map[string]map[string]struct{})
+	var dirs []string
+	for p, m := range files {
+		if m.Deleted {
+			continue // 墓碑不参与目录哈希
+		}
+		if parent := dirOf(p); parent != p {
+			if live(files[parent]) != nil {
+				if children[parent] == nil {
+					children[parent] = make(map[string]struct{})
+				}
+				children[parent][p] = struct{}{}
+			}
+		}
+		if m.IsDirectory {
+			dirs = append(dirs, p)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+
+	// 在副本上计算，子目录先于父目录
+	work := make(map[string]*FileMetadata, len(files))
+	for p, m := range files {
+		work[p] = m
+	}
+	for _, d := range dirs {
+		m := *work[d]
+		m.Hash = dirHashWith(work, children[d], enc)
+		work[d] = &m
+	}
+
+	return rootHashWith(work, roots, enc)
+}
+
+// RepairStore 执行 ValidateStore 并自动修复其中可以安全修复的问题，返回已修复的问题
+//
+// 目前只修复 IssueDanglingParent：把缺失的父快照替换为初始快照(最早创建的无父快照)，
+// 内存中的快照以新节点替换(同 SetSnapshotDescription)，Store 中的快照重新写入；其余问题需要人工处理，
+// 可再次调用 ValidateStore 查看。写入 Store 失败时返回已修复的部分与该错误，Close 之后返回 ErrStopped
+func (w *Watcher) RepairStore() ([]ValidationIssue, error) {
+	if w.cfg.DisableSnapshots {
+		return nil, nil
+	}
+	if err := w.beginMutation(); err != nil {
+		return nil, err
+	}
+	defer w.endMutation()
+	issues := w.ValidateStore()
+	nodes, _ := w.collectForValidation()
+	initial := initialSnapshotID(nodes)
+
+	var fixed []ValidationIssue
+	for _, is := range issues {
+		if is.Kind != IssueDanglingParent || initial == "" || is.SnapshotID == initial {
+			continue
+		}
+		if err := w.reparent(is.SnapshotID, is.Ref, initial); err != nil {
+			return fixed, err
+		}
+		fixed = append(fixed, is)
+	}
+	return fixed, nil
+}
+
+// initialSnapshotID 返回最早创建的无父快照，没有时返回空串
+func initialSnapshotID(nodes map[string]*SnapshotNode) string {
+	var best *SnapshotNode
+	for _, sn := range nodes {
+		if len(sn.ParentIDs) > 0 || sn.spilled {
+			continue
+		}
+		if best == nil || sn.CreatedAt.Before(best.CreatedAt) || (sn.CreatedAt.Equal(best.CreatedAt) && sn.ID < best.ID) {
+			best = sn
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.ID
+}
+
+// reparent 把快照 id 的父节点 from 替换为 to(已是父节点时直接删除 from)
+func (w *Watcher) reparent(id, from, to string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	old := w.snapshots.get(id)
+	full := old
+	if old == nil || old.spilled {
+		var err error
+		if full, err = w.cfg.Store.Get(id); err != nil {
+			return fmt.Errorf("failed to repair snapshot %s: %w", id, err)
+		}
+		full = w.importSnapshot(full)
+	}
+	parents := make([]string, 0, len(full.ParentIDs))
+	for _, pid := range full.ParentIDs {
+		if pid == from {
+			pid = to
+		}
+		if !slices.Contains(parents, pid) {
+			parents = append(parents, pid)
+		}
+	}
+	// 父快照已改变，ChangedPaths 不再对应新的父快照，不保留
+	sn := &SnapshotNode{
+		ID:          full.ID,
+		Instance:    full.Instance,
+		ParentIDs:   parents,
+		CreatedAt:   full.CreatedAt,
+		Description: full.Description,
+		Files:       full.Files,
+		RootHash:    full.RootHash,
+		Config:      full.Config,
+	}
+
+	if w.cfg.Store != nil && (old == nil || old.spilled || w.storedLocked(id)) {
+		if err := w.cfg.Store.Put(sn); err != nil {
+			return fmt.Errorf("failed to repair snapshot %s: %w", id, err)
+		}
+	}
+	switch {
+	case old == nil:
+		// 只存在于 Store 中
+	case old.spilled:
+		w.snapshots.put(&SnapshotNode{ID: sn.ID, Instance: sn.Instance, ParentIDs: sn.ParentIDs, CreatedAt: sn.CreatedAt, spilled: true})
+		w.spill.cache.add(sn)
+	default:
+		w.snapshots.put(sn)
+		if w.head.Load() == old {
+			w.head.Store(sn)
+		}
+	}
+	return nil
+}
+
+// storedLocked 报告快照 id 在 Store 中是否已有副本
+func (w *Watcher) storedLocked(id string) bool {
+	_, err := w.cfg.Store.Get(id)
+	return err == nil
+}
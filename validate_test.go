@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// issueKinds 按 SnapshotID 汇总问题类型
+func issueKinds(issues []ValidationIssue) map[string][]IssueKind {
+	out := make(map[string][]IssueKind)
+	for _, is := range issues {
+		out[is.SnapshotID] = append(out[is.SnapshotID], is.Kind)
+	}
+	return out
+}
+
+// TestValidateStore 测试一致的历史没有问题，手工破坏的 Store 报告对应类型的问题，RepairStore 修复悬空父节点
+func TestValidateStore(t *testing.T) {
+	root := t.TempDir()
+	dir := t.TempDir()
+	store, err := NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newSpillWatcher(t, root, store, 2)
+	w.commitSnapshot("root", map[string]*FileMetadata{root: {Path: root, IsDirectory: true}}, "")
+	ids := commitVersions(w, filepath.Join(root, "a.txt"), 4)
+	w.spillOld()
+	if w.GetCurrentSnapshot().RootHash == "" {
+		t.Fatal("test snapshots should carry a root hash")
+	}
+	if issues := w.ValidateStore(); len(issues) != 0 {
+		t.Fatalf("consistent history reported issues: %v", issues)
+	}
+
+	// 手工破坏 Store
+	base := w.GetSnapshotByID(ids[0])
+	_ = store.Put(&SnapshotNode{ID: "snap-orphan", ParentIDs: []string{"snap-missing"}, CreatedAt: base.CreatedAt.Add(time.Hour), Files: map[string]*FileMetadata{}})
+	_ = store.Put(&SnapshotNode{ID: "snap-early", ParentIDs: []string{ids[0]}, CreatedAt: base.CreatedAt.Add(-time.Hour), Files: map[string]*FileMetadata{}})
+	_ = store.Put(&SnapshotNode{ID: "snap-tampered", ParentIDs: base.ParentIDs, CreatedAt: base.CreatedAt, Files: base.Files, RootHash: "0000"})
+	data, _ := os.ReadFile(filepath.Join(dir, ids[0]+dirStoreExt))
+	_ = os.WriteFile(filepath.Join(dir, "snap-copy"+dirStoreExt), data, 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "snap-garbage"+dirStoreExt), []byte("not gzip"), 0o644)
+	_ = store.ForInstance(w.InstanceID()).(HeadStore).SetHead("snap-gone")
+
+	got := issueKinds(w.ValidateStore())
+	want := map[string]IssueKind{
+		"snap-orphan":   IssueDanglingParent,
+		"snap-early":    IssueTimeOrder,
+		"snap-tampered": IssueHashMismatch,
+		"snap-copy":     IssueDuplicateID,
+		"snap-garbage":  IssueUnreadable,
+		"snap-gone":     IssueMissingHead,
+	}
+	for id, kind := range want {
+		if len(got[id]) != 1 || got[id][0] != kind {
+			t.Errorf("issues for %s = %v; want [%s]", id, got[id], kind)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("unexpected issues: %v", got)
+	}
+
+	fixed, err := w.RepairStore()
+	if err != nil || len(fixed) != 1 || fixed[0].SnapshotID != "snap-orphan" {
+		t.Fatalf("RepairStore = %v, %v", fixed, err)
+	}
+	initial := w.ListAllSnapshots()
+	var initialID string
+	for _, sn := range initial {
+		if len(sn.ParentIDs) == 0 {
+			initialID = sn.ID
+		}
+	}
+	if sn, _ := store.Get("snap-orphan"); sn == nil || len(sn.ParentIDs) != 1 || sn.ParentIDs[0] != initialID {
+		t.Errorf("orphan should be re-parented to %s: %+v", initialID, sn)
+	}
+	if kinds := issueKinds(w.ValidateStore())["snap-orphan"]; len(kinds) != 0 {
+		t.Errorf("orphan still reported after repair: %v", kinds)
+	}
+}
+
+// TestRepairInMemory 测试内存中的悬空父节点被替换为新节点
+func TestRepairInMemory(t *testing.T) {
+	w := newTestWatcher(t, t.TempDir())
+	initial := w.GetCurrentSnapshot()
+	w.mu.Lock()
+	orphan := &SnapshotNode{ID: "snap-orphan", ParentIDs: []string{"snap-missing", initial.ID}, CreatedAt: initial.CreatedAt.Add(time.Second), Files: map[string]*FileMetadata{}}
+	w.snapshots.put(orphan)
+	w.mu.Unlock()
+
+	if kinds := issueKinds(w.ValidateStore())["snap-orphan"]; len(kinds) != 1 || kinds[0] != IssueDanglingParent {
+		t.Fatalf("issues = %v", kinds)
+	}
+	if fixed, err := w.RepairStore(); err != nil || len(fixed) != 1 {
+		t.Fatalf("RepairStore = %v, %v", fixed, err)
+	}
+	if sn := w.GetSnapshotByID("snap-orphan"); len(sn.ParentIDs) != 1 || sn.ParentIDs[0] != initial.ID {
+		t.Errorf("duplicate parent should collapse into the initial snapshot: %v", sn.ParentIDs)
+	}
+}
+
+// TestValidateStoreOnStart 测试 Start 时把发现的问题发送到 ErrorChan
+func TestValidateStoreOnStart(t *testing.T) {
+	store, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = store.Put(&SnapshotNode{ID: "snap-orphan", ParentIDs: []string{"snap-missing"}, Files: map[string]*FileMetadata{}})
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{t.TempDir()}, Store: store, ValidateStoreOnStart: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	select {
+	case err := <-w.ErrorChan:
+		var issue ValidationIssue
+		if !errors.As(err, &issue) || issue.Kind != IssueDanglingParent || issue.Ref != "snap-missing" {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no validation issue reported")
+	}
+}
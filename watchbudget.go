@@ -0,0 +1,128 @@
+package watcher
+
+import (
+	"fmt"
+	"sync"
+)
+
+// watchBudget 记录已注册监控的目录与因 MaxWatchedDirs 而跳过的目录
+//
+// 每个注册的目录占用一个内核监控(inotify watch)；目录被删除或移走时从中释放，预算可被新目录复用
+type watchBudget struct {
+	mu      sync.Mutex
+	dirs    map[string]struct{}
+	skipped map[string]struct{}
+}
+
+// reserve 为目录 p 预留预算，已注册过的目录直接返回 true
+func (b *watchBudget) reserve(p string, limit int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.dirs[p]; ok {
+		return true
+	}
+	if limit > 0 && len(b.dirs) >= limit {
+		if b.skipped == nil {
+			b.skipped = make(map[string]struct{})
+		}
+		b.skipped[p] = struct{}{}
+		return false
+	}
+	if b.dirs == nil {
+		b.dirs = make(map[string]struct{})
+	}
+	b.dirs[p] = struct{}{}
+	delete(b.skipped, p)
+	return true
+}
+
+// unreserve 撤销 reserve(注册失败时)
+func (b *watchBudget) unreserve(p string) {
+	b.mu.Lock()
+	delete(b.dirs, p)
+	b.mu.Unlock()
+}
+
+// release 释放 p 及其下的全部目录，返回其中已注册的目录；p 不是已记录的目录时直接返回
+func (b *watchBudget) release(p string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, watched := b.dirs[p]
+	_, skipped := b.skipped[p]
+	if !watched && !skipped {
+		return nil
+	}
+	var out []string
+	for d := range b.dirs {
+		if withinRoot(d, p) {
+			delete(b.dirs, d)
+			out = append(out, d)
+		}
+	}
+	for d := range b.skipped {
+		if withinRoot(d, p) {
+			delete(b.skipped, d)
+		}
+	}
+	return out
+}
+
+// counts 返回已注册与已跳过的目录数
+func (b *watchBudget) counts() (watched, skipped int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.dirs), len(b.skipped)
+}
+
+// RootCoverage 是 WatchCoverage 中单个监控根的监控情况
+type RootCoverage struct {
+	Root    string
+	Watched int // 已注册监控的目录数
+	Skipped int // 因 MaxWatchedDirs 未注册监控的目录数(其下的变更不会被感知)
+	Failed  int // 因系统资源耗尽注册失败、等待重试的目录数
+}
+
+// WatchCoverage 按监控根(顺序同 Roots())返回已注册、被跳过与注册失败等待重试的目录数，用于定位占用监控预算最多的监控根
+//
+// 并发安全
+func (w *Watcher) WatchCoverage() []RootCoverage {
+	out := make([]RootCoverage, len(w.roots))
+	idx := make(map[string]int, len(w.roots))
+	for i, r := range w.roots {
+		out[i].Root = r
+		idx[r] = i
+	}
+	for _, d := range w.watchRetry.list() {
+		if i, ok := idx[w.rootOf(d)]; ok {
+			out[i].Failed++
+		}
+	}
+	b := &w.watches
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for d := range b.dirs {
+		if i, ok := idx[w.rootOf(d)]; ok {
+			out[i].Watched++
+		}
+	}
+	for d := range b.skipped {
+		if i, ok := idx[w.rootOf(d)]; ok {
+			out[i].Skipped++
+		}
+	}
+	return out
+}
+
+// releaseWatches 在目录 p 被删除或移走后释放其(及子目录)占用的监控预算，并移除仍残留的监控
+func (w *Watcher) releaseWatches(p string) {
+	w.forgetWatch(p)
+	for _, d := range w.watches.release(p) {
+		// 删除的目录内核已自动移除监控，Remove 会返回错误，忽略即可
+		_ = w.fsWatcher.Remove(d)
+	}
+}
+
+// errWatchBudget 返回目录因超出 MaxWatchedDirs 未注册监控的错误
+func (w *Watcher) errWatchBudget() error {
+	return fmt.Errorf("%w: limit of %d directories reached", ErrWatchBudget, w.cfg.MaxWatchedDirs)
+}
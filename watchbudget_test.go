@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMaxWatchedDirs 测试达到上限后的目录被跳过并报告 ErrWatchBudget，释放目录后预算可被复用
+func TestMaxWatchedDirs(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	for _, d := range []string{filepath.Join(a, "x"), filepath.Join(a, "y"), filepath.Join(a, "z"), filepath.Join(b, "x")} {
+		_ = os.Mkdir(d, 0755)
+	}
+	w, err := NewWatcherWithOptions([]string{a, b}, WithMaxWatchedDirs(4))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.fsWatcher.Close() })
+	if err := w.registerWatches(); err != nil {
+		t.Fatalf("registerWatches failed: %v", err)
+	}
+	<-w.ErrorChan // PartialWatchError
+
+	st := w.Stats()
+	if st.WatchedDirs != 4 || st.WatchesSkipped != 2 || st.WatchedDirsLimit != 4 || len(w.fsWatcher.WatchList()) != 4 {
+		t.Fatalf("watched=%d skipped=%d limit=%d; want 4/2/4", st.WatchedDirs, st.WatchesSkipped, st.WatchedDirsLimit)
+	}
+	werrs := w.WatchErrors()
+	if len(werrs) != 2 || !errors.Is(&werrs[0], ErrWatchBudget) {
+		t.Errorf("WatchErrors = %v; want 2 budget errors", werrs)
+	}
+	total := RootCoverage{}
+	for _, c := range w.WatchCoverage() {
+		total.Watched += c.Watched
+		total.Skipped += c.Skipped
+	}
+	if total.Watched != 4 || total.Skipped != 2 {
+		t.Errorf("WatchCoverage totals = %+v", total)
+	}
+
+	var watched, skipped string
+	for d := range w.watches.dirs {
+		if !w.isRoot(d) {
+			watched = d
+		}
+	}
+	for d := range w.watches.skipped {
+		skipped = d
+	}
+	w.releaseWatches(watched)
+	if err := w.addWatch(skipped); err != nil {
+		t.Fatalf("released budget should be reusable: %v", err)
+	}
+	if st := w.Stats(); st.WatchedDirs != 4 || st.WatchesSkipped != 1 {
+		t.Errorf("after reuse: watched=%d skipped=%d; want 4/1", st.WatchedDirs, st.WatchesSkipped)
+	}
+}
@@ -1,14 +1,19 @@
 package watcher
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"log/slog"
+	"maps"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -16,17 +21,42 @@ import (
 
 // SnapshotNode 表示某一次快照(版本)的节点，形成一个DAG
 //
-// ID 是此版本的唯一标识，如 "snap-1234567890"
+// ID 是此版本的唯一标识，如 "snap-<实例ID>-1234567890"，实例ID 同时记录在 Instance 中
 // ParentIDs 表示它可能有多个父版本（支持多分支/合并）
 // CreatedAt 表示创建时间
 // Description 表示对于本次快照的描述
-// Files 存储该快照下每个文件的元信息
+// Files 存储该快照下每个文件的元信息，未变化的条目在快照之间共享，调用方不应修改
+// RootHash 是所有监控根目录哈希的汇总，两个快照 RootHash 相同即内容相同
+// Config 记录创建时生效的忽略规则、大小上限等配置及其指纹，用于解释快照中缺少的文件或空的哈希
+// 配置了 Store 时，较旧的快照在 ListAllSnapshots 中以占位节点出现(见 Spilled)
 type SnapshotNode struct {
-	ID          string                   // 唯一ID (如 v1234567890)
+	ID          string                   // 唯一ID (如 snap-3f9a0c1d-1700000000000000000)
+	Instance    string                   // 创建该快照的 Watcher 的实例ID(ConfigWatcher.InstanceID)
 	ParentIDs   []string                 // 父版本(可能不止一个, 支持合并/多分支场景)
 	CreatedAt   time.Time                // 创建时间
 	Description string                   // 描述(可为空)
-	Files       map[string]*FileMetadata // 当前快照下的文件映射
+	Files       map[string]*FileMetadata // 当前快照下的文件映射(TombstoneSnapshots 时含墓碑，见 FileMetadata.Deleted)
+	RootHash    string                   // 监控根的Merkle哈希汇总(无文件时为空)
+	Config      *SnapshotConfig          // 创建时的有效配置(可为nil)，在快照之间共享，见 ConfigAt
+
+	// 产生该快照的变更路径(有序，含补齐的上级目录，不含仅因子节点变化而重新计算哈希的上级目录)；对账快照为有差异的路径，
+	// 超过 ConfigWatcher.MaxChangedPaths 时截断并设置 Truncated；初始快照为nil
+	ChangedPaths []string
+	Truncated    bool
+
+	spilled bool // 已换出到 Store 的占位节点，只有 ID/Instance/ParentIDs/CreatedAt
+
+	// 按需构建的目录层级索引与有序路径，快照发布后不可变，可安全缓存
+	idxOnce   sync.Once
+	idx       *snapIndex
+	pathsOnce sync.Once
+	paths     []string
+	deadOnce  sync.Once
+	dead      int // 墓碑数
+	trieOnce  sync.Once
+	trie      atomic.Pointer[trieNode] // 前缀树，见 pathTrie
+	sizeOnce  sync.Once
+	sizes     *sizeStats // 文件大小汇总，见 SizeReport
 }
 
 // FileMetadata 表示单个文件在某个版本/快照中的信息
@@ -34,18 +64,32 @@ type SnapshotNode struct {
 // Path：该文件的完整路径
 // Size：文件大小（单位：字节）
 // ModTime：文件上次修改时间
-// Hash：文件内容哈希(使用SHA-256)
+// Hash：文件内容哈希(使用SHA-256)；目录则为其子节点(名称、哈希、类型)排序后的哈希(Merkle)
 // IsDirectory：是否为目录
 // CreatedAt：记录此FileMetadata的时间
 // LastModified：文件本身的修改时间（和ModTime含义相同，但保留是为了可扩展性）
+// BirthTime：文件创建时间，尽力而为：Linux(statx)、macOS、Windows 上可用，其它平台或文件系统不支持时为零值
+// HashState：Hash 的状态(已计算、因大小/类型跳过、不可读等)，用于区分"空哈希"的不同原因
+// AppendedBytes：命中 AppendOnlyPatterns 且本次变更被判定为"仅在末尾追加"时，记录追加的字节数；否则为0
 type FileMetadata struct {
 	Path         string    // 完整路径
 	Size         int64     // 文件大小
 	ModTime      time.Time // 修改时间
-	Hash         string    // 文件内容哈希(如 SHA-256)
+	Hash         string    // 文件内容哈希(如 SHA-256)，目录为子树哈希
+	HashState    HashState // 哈希状态
 	IsDirectory  bool      // 是否目录
 	CreatedAt    time.Time // 记录此条目时
 	LastModified time.Time // 文件本身的修改时间
+	BirthTime    time.Time // 文件创建时间(不支持时为零值)
+
+	AppendedBytes int64 // 相对上一版本追加的字节数(非追加写时为0)
+
+	Deleted   bool      // 删除标记(墓碑)：条目已被删除，只在 TombstoneSnapshots 大于0时出现
+	DeletedAt time.Time // 删除时间(Deleted 时)
+
+	Inaccessible bool // 目录在运行中变得不可读(如被 chmod 000)，子树中的条目停留在此前的状态，见 AccessDeniedError
+
+	rewritten bool // 本次变更经过追加写检测，旧内容已不是前缀(只用于填充 FileEvent.Truncated，不持久化)
 }
 
 // ConfigWatcher 用于配置 Watcher
@@ -54,223 +98,801 @@ type FileMetadata struct {
 // IgnorePatterns：需要忽略的文件(或目录)通配符，如 "*.tmp" 或 ".git"
 // Debounce：事件合并的时间间隔, 默认 10ms
 // WorkerCount：并发处理文件变更的最大worker数量, 默认 32
+// 其余字段见字段注释与对应的 With* 选项
 type ConfigWatcher struct {
 	WatchPaths     []string      // 要监控的路径
 	IgnorePatterns []string      // 要忽略的文件通配符
 	Debounce       time.Duration // 事件合并的时间间隔, 默认 10ms
 	WorkerCount    int           // 并发处理 Worker 数, 默认 32
+
+	WorkerAutoTune     bool          // 按饱和程度自动调整 worker 上限(默认关闭)
+	WorkerMin          int           // 自动调整的下限, 默认 WorkerCount/4
+	WorkerMax          int           // 自动调整的上限, 默认 WorkerCount×4
+	WorkerTuneInterval time.Duration // 统计利用率与自动调整的间隔, 默认 10s
+
+	MaxPendingPaths int           // 合并表的路径数上限，达到时立即 flush, 默认 100000
+	SettleDelay     time.Duration // 路径的最近一个事件之后安静多久才处理, 默认0(不等待)
+
+	Priority func(path string, size int64) int // 路径优先级(可为nil)，见 SmallFilesFirst
+
+	AppendOnlyPatterns []string // 启用追加写检测的文件通配符(默认不启用)
+	MaxHashSize        int64    // 超过该大小(字节)的文件不计算哈希, 0 表示不限制
+	NoHashPatterns     []string // 只记录元信息、不计算哈希的文件通配符(如 "*.mp4")
+	HashBufferSize     int      // 计算哈希的读缓冲大小(字节), 默认 1MB
+
+	HashErrorPolicy HashErrorPolicy // 计算哈希失败时如何记录该文件, 默认 HashErrorEmptyHash
+
+	CompletionPatterns []string      // 写入完成后才处理的文件通配符(投递目录), 默认不启用
+	CompletionQuiet    time.Duration // 判定写入完成所需的稳定时长, 默认 2s
+
+	FailOnPartialWatch bool          // 任一目录注册监控失败时 Start 直接返回错误
+	MaxWatchedDirs     int           // 注册监控的目录数上限, 0 表示不限
+	WatchRetryInterval time.Duration // 注册失败目录的自动重试间隔(之后按退避翻倍), 默认 5s
+
+	RootPollInterval      time.Duration // 监控根存在性巡检间隔, 默认 1s
+	KeepEntriesOnRootLoss bool          // 监控根消失时保留快照中其下的条目
+
+	RejectOverlappingRoots bool // 监控路径重叠时 NewWatcher 返回错误而不是合并
+
+	ScanOnStart  bool                                           // Start 时全量扫描并提交基线快照
+	ScanEvents   bool                                           // 初始扫描为每个条目发送 OpScan 事件
+	ScanProgress func(scanned, total int64, currentPath string) // 初始扫描进度回调(可为nil)
+
+	Tracer BatchTracer // 批次处理追踪钩子(可为nil)，OpenTelemetry 实现见 watcherotel
+
+	HealthThresholds HealthThresholds // Health() 的判定阈值, 零值字段使用默认值
+
+	AuditWriter        io.Writer                                    // 审计日志输出(与 AuditPath 二选一)
+	AuditPath          string                                       // 审计日志文件路径(追加写入)
+	AuditFlushInterval time.Duration                                // 审计日志 flush 间隔, 默认 1s
+	AuditQueueSize     int                                          // 审计日志队列长度, 默认 4096
+	AuditRotateSize    int64                                        // 审计日志超过该大小(字节)时触发轮转, 0 表示不轮转
+	AuditOnRotate      func(size int64) (next io.Writer, err error) // 轮转回调(可为nil)
+
+	Logger *slog.Logger     // 警告日志输出, nil 时打印到标准输出(与早期版本一致)
+	Hasher func() hash.Hash // 文件内容哈希算法, 默认 SHA-256；目录哈希始终使用 SHA-256
+
+	DisableSnapshots    bool // 不创建快照，只维护可变的当前状态并发送事件
+	DisableCurrentState bool // 不维护当前状态(隐含 DisableSnapshots)
+
+	DisableEventChan  bool              // 不创建 EventChan，事件只发给订阅者与审计日志
+	SelfWriteWindow   time.Duration     // MarkSelfWrite 标记的有效时长, 默认 2s
+	ErrorDedupWindow  time.Duration     // 重复错误的去重窗口, 0 表示不去重
+	EventSnapshotMode EventSnapshotMode // 事件携带快照的方式, 默认 EventSnapshotFull(推荐 EventSnapshotIDOnly)
+	ReplayBuffer      int               // 供 SubscribeFrom 回放的最近事件数, 0 表示不保留
+	RecordOpTrace     bool              // 在事件的 OpTrace 中记录合并的底层操作序列
+	MaxOpTrace        int               // 每个事件记录的底层操作数上限, 默认 32
+
+	DescribeSnapshot        func(batch []ChangedPath, parent *SnapshotNode) string // 生成快照描述的回调(可为nil)
+	DescribeSnapshotTimeout time.Duration                                          // 等待 DescribeSnapshot 的时长, 默认 100ms
+
+	Clock Clock // 时间来源, 默认系统时间；测试可注入 watchertest.FakeClock
+
+	MinSnapshotInterval time.Duration // 两次创建快照的最小间隔, 0 表示不限
+
+	ReconcileSummaryThreshold int // 对账差异超过该数量时只发送一个汇总事件, 0 表示不汇总
+
+	MaxChangedPaths int // 快照记录的变更路径(SnapshotNode.ChangedPaths)数上限, 默认 1000
+
+	HistoryLimits []HistoryLimit // 按路径通配符限制保留的版本数(可为nil)
+
+	TombstoneSnapshots int // 删除的条目以墓碑形式保留的后续快照数, 0 表示直接移除
+
+	RescanOnOverflow bool // 内核事件队列溢出后自动对账重扫全部监控根
+
+	HotPathWindow   time.Duration // 按路径统计事件数的滑动窗口(HotPaths), 0 表示不统计
+	HotPathCapacity int           // 最多跟踪的路径数, 默认 10000
+
+	StormMaxEventsPerSec    int           // 每秒事件数上限(事件风暴保护), 0 表示不限
+	StormMaxHashBytesPerSec int64         // 每秒计算哈希的字节数上限, 0 表示不限
+	StormDwell              time.Duration // 超限持续多久后降级, 默认 5s
+	StormRecovery           time.Duration // 低于上限持续多久后恢复, 默认 30s
+	StormMaxDebounce        time.Duration // 降级期间的 flush 间隔, 默认 1s
+	StormNotifyOnly         bool          // 超限时只发送通知，不降级
+
+	IgnoreChmod             bool // 丢弃只有 Chmod 的事件(只改变权限、时间戳、扩展属性等元信息)
+	DisablePlatformDefaults bool // 不应用当前平台的默认配置(PlatformDefaults)
+
+	InstanceID           string        // 实例ID，嵌入生成的快照ID, 默认随机生成
+	Store                SnapshotStore // 快照持久化存储(可为nil)，见 NewDirStore
+	MemorySnapshots      int           // 内存中完整保留的最近快照数, 0 表示不换出
+	ValidateStoreOnStart bool          // Start 时校验 Store 与内存中的快照历史
+
+	NativePaths        bool                     // 快照与事件中的路径使用本平台的分隔符，而不是统一为 '/'
+	PathRewrite        func(raw string) string  // 原始路径 → 逻辑路径(可为nil)
+	PathRewriteInverse func(path string) string // 逻辑路径 → 原始路径，PathRewrite 非nil时必须设置
+
+	Baseline                 *SnapshotNode // 代替空的初始快照的基线(可为nil)，见 BaselineFromManifest
+	BaselineKeepOutsideRoots bool          // 保留 Baseline 中监控根之外的路径而不是报错
+
+	FS          FS          // 文件系统读取, 默认为操作系统文件系统
+	EventSource EventSource // 文件系统事件来源, 默认为 fsnotify
 }
 
 // Watcher 负责监控文件系统变化 + 快照管理
 //
-// mu：对snapshots与current字段的读写上锁
-// fsWatcher：底层使用github.com/fsnotify/fsnotify进行文件系统事件捕捉
+// mu：提交锁，串行化快照提交(head 的推进)，并保护 tags 与目录层级索引；
+// 快照模式下读取 head 与 snapshots 不需要持有它
+// fsWatcher：文件系统事件来源，默认基于github.com/fsnotify/fsnotify(见 EventSource)
+// fs：文件系统读取(stat/open/遍历)，默认为操作系统文件系统(见 FS)
 // stopChan：用于停止所有后台goroutine
-// snapshots：版本ID -> *SnapshotNode 的映射，维护了所有快照
-// current：当前活跃版本(HEAD)
+// snapshots：版本ID -> *SnapshotNode 的索引，维护了所有快照，可无锁读取
+// head：当前活跃版本(HEAD)的原子指针，只在持有 mu 时写入
+// roots, children：监控根与当前快照的目录层级索引，用于增量维护目录哈希
 // aggChan, aggMap, aggMu, aggTicker：用于事件合并（Debounce）
 // workerPool：并发处理文件变更的令牌池
-// EventChan：向外部暴露的"文件变更事件"通道
+// EventChan：向外部暴露的"文件变更事件"通道(DisableEventChan 时为nil)
+// ErrorChan：向外部暴露的错误通道(非阻塞发送，满时丢弃)
 type Watcher struct {
 	mu        sync.RWMutex
 	cfg       ConfigWatcher
-	fsWatcher *fsnotify.Watcher
+	fsWatcher EventSource
+	fs        FS
 
 	stopChan chan struct{}
+	started  atomic.Bool    // Start 已成功返回
+	stopOnce sync.Once      // 保证 Stop/Close 只执行一次
+	stopErr  error          // 第一次 Close 的结果
+	fsAlive  atomic.Bool    // runFsNotify 正在运行
+	bgWG     sync.WaitGroup // 会提交快照/发送事件的后台goroutine，Stop 时等待其退出
+	workerWG sync.WaitGroup // flush 启动的全部goroutine(worker、慢车道、批次耗时统计)，Stop 在关闭通道前等待其退出
+	apiMu    sync.Mutex     // 使 beginMutation 的检查与登记和 shutdown 关闭 stopChan 互斥
+	apiWG    sync.WaitGroup // 进行中的修改状态的公开调用(RehashFile 等)，Stop 在最后写出前等待其结束
+
+	fsWG       sync.WaitGroup // runFsNotify，Stop 关闭事件来源后等待它读完剩余事件
+	describeWG sync.WaitGroup // DescribeSnapshot 回调的goroutine，Stop 有限地等待其结束(见 waitDescribes)
 
-	snapshots map[string]*SnapshotNode
-	current   *SnapshotNode
+	snapshots snapshotTable
+	head      atomic.Pointer[SnapshotNode]
+	tags      map[string]string // 标签 -> 快照ID
+	spill     spillState        // 两级快照存储(cfg.Store)的状态
+	throttle  throttleState     // 快照限速(cfg.MinSnapshotInterval)的状态，受 mu 保护
+	history   historyIndex      // 按路径的版本索引(cfg.HistoryLimits)，受 mu 保护
+	hot       hotTracker        // 按路径的事件计数(cfg.HotPathWindow)
+
+	// 目录层级(用于增量计算目录哈希)
+	roots    []string                       // 清理后的监控根路径
+	children map[string]map[string]struct{} // 当前快照中 目录 -> 直接子路径
+	tombs    tombstones                     // 当前状态中的墓碑(cfg.TombstoneSnapshots)
 
 	// 事件合并(防抖)
-	aggChan   chan fsnotify.Event
-	aggMap    map[string]fsnotify.Op
+	aggChan   chan aggEvent
+	aggMap    map[string]aggEntry
+	aggSpare  map[string]aggEntry // 上一批次清空后留待复用的合并表(可为nil)
 	aggMu     sync.Mutex
-	aggTicker *time.Ticker
+	aggFirst  atomic.Int64 // 合并表中最早的事件进入 aggChan 的时间(UnixNano)，0 表示尚无，用于批次延迟
+	aggTicker Ticker
+	ignore    []globPattern // 预处理过的 cfg.IgnorePatterns
 
 	// 事件处理并发控制
 	workerPool chan struct{}
+	workers    workerState                    // 有效 worker 上限与利用率，见 workers.go
+	lanes      laneState                      // 优先级车道(cfg.Priority)
+	inflight   inflightPaths                  // 已分派、尚未处理完的路径
+	hashRetry  hashRetries                    // 因文件被锁定而等待重试哈希的路径
+	completion completions                    // 等待写入完成检测的文件(CompletionPatterns)
+	self       selfWrites                     // 本进程写入的路径标记(MarkSelfWrite)
+	errDedup   errorDedup                     // 去重窗口内出现过的错误(ErrorDedupWindow)
+	snapCfg    atomic.Pointer[SnapshotConfig] // 新快照记录的有效配置，见 refreshSnapshotConfig
+	excluded   []string                       // 位于监控根之下、自动忽略的自身输出路径(原始路径)，见 ownpaths.go
+	rootExempt map[string]struct{}            // 匹配忽略规则、但不被忽略的监控根(原始路径)，见 rootignore.go
+	storm      stormState                     // 事件风暴保护(cfg.StormMaxEventsPerSec/StormMaxHashBytesPerSec)
+
+	// 初始扫描状态
+	scanning        atomic.Bool // 扫描进行中，周期性flush暂停
+	replayAfterScan atomic.Bool // 下一次flush回放扫描期间积压的事件
+	scan            scanProgress
+	readyChan       chan struct{} // 拥有完整视图(基线已提交)时关闭
+
+	rootLostChan chan string   // runFsNotify -> runRootMonitor：监控根被删除/移走
+	rescanChan   chan struct{} // runFsNotify -> runRootMonitor：事件队列溢出后重扫(RescanOnOverflow)
+
+	counters   watcherCounters // 内部计数器，见 Stats()
+	audit      *auditSink      // 审计日志(未配置时为nil)
+	subs       subscribers     // 事件订阅者与事件序号，见 Subscribe()
+	changeSets changeSetSubs   // 变更集订阅者，见 SubscribeChangeSets()
+	denied     deniedDirs      // 运行中变得不可读的目录，见 access.go
+	watchRetry watchRetrySet   // 因资源耗尽注册失败、等待重试的目录，见 watchretry.go
+	recentErrs errorRing       // 最近的错误，见 RecentErrors()/DumpState()
+
+	clock        Clock // 时间来源(cfg.Clock 或 RealClock())
+	lastSnapNano int64 // 上一个快照ID的时间戳(受 mu 保护)
 
-	// 向外部暴露的事件通道
+	newHash   func() hash.Hash // 文件内容哈希构造函数(cfg.Hasher 或 sha256.New)
+	bufs      *bufferPool      // 读取文件内容的缓冲池(cfg.HashBufferSize)
+	emptyHash string           // 空内容的哈希，零字节文件直接使用
+
+	watchErrs  []*WatchError           // Start 时注册失败的目录(受 mu 保护)
+	watches    watchBudget             // 已注册监控的目录(cfg.MaxWatchedDirs)
+	addWatchFn func(path string) error // 替代 fsWatcher.Add 的注册函数(测试用，默认nil)
+
+	// 向外部暴露的事件通道，cfg.DisableEventChan 时为nil
 	EventChan chan FileEvent
+
+	// 向外部暴露的错误通道
+	ErrorChan chan error
 }
 
 // FileEvent 表示可供外部使用的"文件变更事件"结构
 //
 // FilePath：变更文件的路径
-// Op：操作类型（fsnotify.Create / fsnotify.Write / fsnotify.Remove / fsnotify.Rename 等）
-// NewSnap：触发此变更后产生的新快照（包含了全部文件信息的最新状态）
+// Kind：操作类型（OpCreate / OpWrite / OpRemove / OpRename 等，可能因防抖合并而包含多个）
+// NewSnap：触发此变更后产生的新快照（包含了全部文件信息的最新状态）；EventSnapshotMode 不为 Full 时为nil，
+// 快照ID在 SnapID 中(Summary 时另有 SnapSummary)，SnapshotID() 在各模式下都返回快照ID
+// Seq：事件序号，EventChan 与所有订阅(Subscribe)共用同一计数
+// OldMeta/NewMeta：该路径在父快照与新快照中的元信息
+// Root：该路径所属的监控根(与 Roots() 中的写法相同，按最长前缀匹配)，不属于任何监控根时为 RootUnknown
+// ParentSnapshotID：变更应用时的当前快照(新快照的父快照)；DAG 中的每个快照都由其父快照加上产生它的事件得到，
+// 按 Seq 顺序、沿这两个ID把事件应用到对应父快照的状态上即可重建快照DAG(审计日志与 ReplayEvents 依此还原)
+// SizeDelta/Truncated：由 OldMeta/NewMeta 得到的大小变化，可用于识别日志轮转与截断，见 sizeChange
+//
+// 打印时使用 String()(单行摘要)，JSON 序列化默认不包含 NewSnap.Files，见 MarshalJSON
 type FileEvent struct {
 	FilePath string
-	Op       fsnotify.Op
+	Kind     EventOp
 	NewSnap  *SnapshotNode
+	Seq      uint64        // 事件序号，从1开始单调递增，订阅者可据此判断是否有遗漏
+	OldMeta  *FileMetadata // 变更前(父快照中)的元信息，新增时为nil
+	NewMeta  *FileMetadata // 变更后的元信息，删除时为nil
+	Root     string        // 所属的监控根
+
+	SnapID           string           // 产生的快照ID，只在 NewSnap 按 EventSnapshotMode 省略时填充
+	ParentSnapshotID string           // 变更应用时的当前快照ID(新快照的父快照)，没有快照时为空
+	SnapSummary      *SnapshotSummary // 产生的快照的摘要，只在 EventSnapshotSummary 时填充
+
+	SizeDelta int64 // 新大小减旧大小：新增时为新大小，删除时为负的旧大小，目录总为0
+	Truncated bool  // 文件变小，或变大但旧内容已不是其前缀(AppendOnlyPatterns 检测到重写)
+	Completed bool  // 文件经完成检测(CompletionPatterns)判定写入完成后才发出本事件
+	Entries   int   // OpBaseline 事件中扫描得到的条目数，其它事件为0
+
+	// OpTrace 是合并进本事件的底层操作按到达顺序的序列(合并后的 Kind 看不出先后，如 CREATE|WRITE|REMOVE)，
+	// 只在 RecordOpTrace 时填充，至多 MaxOpTrace 个(超出时保留最近的)；不经合并表的事件(对账、RehashFile 等)为nil
+	OpTrace []OpSample
+
+	// FirstSeen 是本次变更中该路径第一个底层事件到达 Watcher 的时间(合并窗口内的多个事件取最早的)，
+	// Processed 是变更处理完成(快照已提交)的时间，两者之差即管道延迟(Stats().EventLatency)；
+	// 不受 EventChan 积压与快照限速(MinSnapshotInterval)延后发送的影响。
+	// 哈希重试、写入完成检测重新排队的路径以重新排队的时间为 FirstSeen；
+	// 不是由文件系统事件触发的事件(对账、RehashFile、监控根丢失)FirstSeen 与 Processed 相同
+	FirstSeen time.Time
+	Processed time.Time
+
+	// Op 是底层 fsnotify 的原始操作位掩码
+	//
+	// Deprecated: 使用 Kind。对账等非 fsnotify 来源的事件只能近似转换，该字段将在下一个主要版本移除
+	Op fsnotify.Op
 }
 
 // NewWatcher 根据给定配置创建一个新的 Watcher
 //
 // 若 cfg.Debounce <= 0，则默认使用 10ms
 // 若 cfg.WorkerCount <= 0，则默认使用 32
+// 重叠的监控路径(嵌套、重复或经符号链接指向同一目录)默认只保留最外层，
+// 开启 cfg.RejectOverlappingRoots 时则返回错误
+//
+// 等价于 NewWatcherWithOptions(cfg.WatchPaths, ...)，零值字段沿用默认值而不报错
 func NewWatcher(cfg ConfigWatcher) (*Watcher, error) {
-	if cfg.Debounce <= 0 {
-		cfg.Debounce = 10 * time.Millisecond
+	return NewWatcherWithOptions(cfg.WatchPaths, withConfig(cfg))
+}
+
+// newWatcher 按已填充默认值并校验过的配置创建 Watcher
+func newWatcher(cfg ConfigWatcher) (*Watcher, error) {
+	applyPlatformPreset(&cfg, platformPreset)
+	if cfg.InstanceID == "" {
+		cfg.InstanceID = newInstanceID()
+	} else if !validInstanceID(cfg.InstanceID) {
+		return nil, fmt.Errorf("%w: invalid instance id %q", ErrInvalidConfig, cfg.InstanceID)
 	}
-	if cfg.WorkerCount <= 0 {
-		cfg.WorkerCount = 32
+	if (cfg.PathRewrite == nil) != (cfg.PathRewriteInverse == nil) {
+		return nil, fmt.Errorf("%w: PathRewrite and PathRewriteInverse must be set together", ErrInvalidConfig)
 	}
-
-	fsw, err := fsnotify.NewWatcher()
+	if !cfg.HashErrorPolicy.valid() {
+		return nil, fmt.Errorf("%w: invalid hash error policy %v", ErrInvalidConfig, cfg.HashErrorPolicy)
+	}
+	if !cfg.EventSnapshotMode.valid() {
+		return nil, fmt.Errorf("%w: invalid event snapshot mode %v", ErrInvalidConfig, cfg.EventSnapshotMode)
+	}
+	if cfg.WorkerAutoTune {
+		if cfg.WorkerMin <= 0 {
+			cfg.WorkerMin = max(1, cfg.WorkerCount/4)
+		}
+		if cfg.WorkerMax <= 0 {
+			cfg.WorkerMax = cfg.WorkerCount * 4
+		}
+		if cfg.WorkerMin > cfg.WorkerCount || cfg.WorkerCount > cfg.WorkerMax {
+			return nil, fmt.Errorf("%w: WorkerCount %d outside [WorkerMin %d, WorkerMax %d]", ErrInvalidConfig, cfg.WorkerCount, cfg.WorkerMin, cfg.WorkerMax)
+		}
+	}
+	if is, ok := cfg.Store.(InstanceStore); ok {
+		cfg.Store = is.ForInstance(cfg.InstanceID)
+	}
+	if cfg.DisableCurrentState {
+		cfg.DisableSnapshots = true
+	}
+	roots, err := resolveRoots(cfg.WatchPaths, cfg.RejectOverlappingRoots)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create fsnotify watcher: %w", err)
+		return nil, err
+	}
+
+	fsw := cfg.EventSource
+	if fsw == nil {
+		if fsw, err = NewFsnotifySource(); err != nil {
+			if isWatchLimit(err) {
+				return nil, fmt.Errorf("failed to create fsnotify watcher: %w: %w", ErrWatchLimit, err)
+			}
+			return nil, fmt.Errorf("failed to create fsnotify watcher: %w", err)
+		}
 	}
 
 	w := &Watcher{
 		cfg:       cfg,
 		fsWatcher: fsw,
+		fs:        cfg.FS,
 		stopChan:  make(chan struct{}),
 
-		snapshots: make(map[string]*SnapshotNode),
+		tags:     make(map[string]string),
+		children: make(map[string]map[string]struct{}),
 
-		aggChan:   make(chan fsnotify.Event, 100000),
-		aggMap:    make(map[string]fsnotify.Op),
-		aggTicker: time.NewTicker(cfg.Debounce),
+		aggChan: make(chan aggEvent, 100000),
+		aggMap:  make(map[string]aggEntry),
+		ignore:  compileGlobs(cfg.IgnorePatterns),
 
-		workerPool: make(chan struct{}, cfg.WorkerCount),
-		EventChan:  make(chan FileEvent, 20000),
+		workerPool: make(chan struct{}, max(cfg.WorkerCount, cfg.WorkerMax)),
+		workers:    workerState{limit: cfg.WorkerCount, wake: make(chan struct{}, 1)},
+		lanes:      laneState{bulkPool: make(chan struct{}, max(1, cfg.WorkerCount/2))},
+		ErrorChan:  make(chan error, 1000),
+		readyChan:  make(chan struct{}),
+		spill:      spillState{kick: make(chan struct{}, 1)},
+
+		roots:        roots,
+		rootLostChan: make(chan string, 16),
+		rescanChan:   make(chan struct{}, 1),
+	}
+	if w.fs == nil {
+		w.fs = osFS{}
+	}
+	if w.rewriting() {
+		w.fs = rewriteFS{fs: w.fs, w: w}
+		w.fsWatcher = rewriteSource{EventSource: fsw, w: w}
+	}
+	seen := make(map[string]string, len(w.roots))
+	for i, r := range w.roots {
+		p, err := w.rewritePath(r)
+		if err == nil && seen[p] != "" {
+			err = &PathRewriteError{Raw: r, Logical: p, Inverse: seen[p]}
+		}
+		if err != nil {
+			_ = fsw.Close()
+			return nil, fmt.Errorf("%w: watch path: %w", ErrInvalidConfig, err)
+		}
+		seen[p] = r
+		w.roots[i] = p
+	}
+	w.clock = cfg.Clock
+	if w.clock == nil {
+		w.clock = RealClock()
+	}
+	w.aggTicker = w.clock.NewTicker(cfg.Debounce)
+	if !cfg.DisableEventChan {
+		w.EventChan = make(chan FileEvent, 20000)
+	}
+	if cfg.ReplayBuffer > 0 {
+		w.subs.ring.buf = make([]FileEvent, cfg.ReplayBuffer)
+	}
+	w.newHash = cfg.Hasher
+	if w.newHash == nil {
+		w.newHash = sha256.New
+	}
+	w.emptyHash = hex.EncodeToString(w.newHash().Sum(nil))
+	w.bufs = newBufferPool(cfg.HashBufferSize)
+	w.counters.batchLatency = newLatencyHistogram()
+	w.counters.hashLatency = newLatencyHistogram()
+	w.counters.eventLatency = newLatencyHistogram()
+	w.hot.init(&cfg)
+	if err := w.excludeOwnPaths(); err != nil {
+		_ = fsw.Close()
+		return nil, err
+	}
+	w.exemptRoots()
+	var baseline map[string]*FileMetadata
+	if cfg.Baseline != nil && !cfg.DisableCurrentState {
+		if baseline, err = w.baselineChanges(cfg.Baseline); err != nil {
+			_ = fsw.Close()
+			return nil, err
+		}
+	}
+	if w.audit, err = newAuditSink(w); err != nil {
+		_ = fsw.Close()
+		return nil, err
 	}
 
-	// 创建初始快照(空)
+	// 创建初始快照(空，或为 Baseline 的内容)
+	w.refreshSnapshotConfig()
 	initial := &SnapshotNode{
 		ID:          w.newSnapID(),
-		CreatedAt:   time.Now(),
+		Instance:    cfg.InstanceID,
+		CreatedAt:   w.now(),
 		Description: "Initial snapshot",
 		Files:       make(map[string]*FileMetadata),
+		Config:      w.snapshotConfig(),
+	}
+	if baseline != nil {
+		w.mu.Lock()
+		w.applyChangesLocked(initial.Files, baseline)
+		initial.RootHash = w.rootHashLocked(initial.Files)
+		w.mu.Unlock()
+		initial.Description = cfg.Baseline.Description
+		if initial.Description == "" {
+			initial.Description = fmt.Sprintf("Provided baseline (%d entries)", len(baseline))
+		}
 	}
-	w.snapshots[initial.ID] = initial
-	w.current = initial
+	if cfg.DisableSnapshots {
+		initial.Description = liveDescription
+	} else {
+		w.snapshots.put(initial)
+		w.trackResidentLocked(initial.ID)
+	}
+	w.head.Store(initial)
 
 	return w, nil
 }
 
 // Start 启动文件监控
 //
-// 会递归扫描 cfg.WatchPaths 中的所有目录，并将它们加到 fsnotify.Watcher 中
+// 会递归扫描所有监控根(去重后的 cfg.WatchPaths)中的目录(按监控根与一级子目录并行)，并将它们加到 fsnotify.Watcher 中
 // 然后启动2个后台goroutine：
 //  1. runAggregator()：负责事件合并
 //  2. runFsNotify()：读取 fsnotify 事件并投递到合并队列
+//
+// 另有 runRootMonitor() 负责在监控根被删除/替换后等待其重新出现并恢复监控
+// 若 cfg.ScanOnStart 为 true，Start 注册完监控后立即返回，全量扫描在后台进行，
+// 可通过 Ready()/WaitReady() 等待基线快照提交；事件在此期间即可从 EventChan 读取
 func (w *Watcher) Start() error {
 	// 1) 递归添加监控目录
-	for _, path := range w.cfg.WatchPaths {
-		err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.IsDir() && !w.isIgnored(p) {
-				e := w.fsWatcher.Add(p)
-				if e != nil {
-					fmt.Printf("Warning: cannot watch dir %s: %v\n", p, e)
-				}
-			}
-			return nil
-		})
-		if err != nil {
-			return fmt.Errorf("failed to walk watch path %s: %w", path, err)
-		}
+	if err := w.registerWatches(); err != nil {
+		return err
 	}
 
+	// 按有效上限占住 workerPool 中多余的令牌，之后启动的 flush 与初始扫描不会超出上限(见 workers.go)
+	w.reserveWorkers()
+
 	// 2) 启动事件合并goroutine
+	if w.cfg.ScanOnStart {
+		w.scanning.Store(true)
+	}
+	w.bgWG.Add(1)
 	go w.runAggregator()
 
 	// 3) 启动 fsnotify 事件读取goroutine
+	w.fsAlive.Store(true)
+	w.fsWG.Add(1)
 	go w.runFsNotify()
 
+	if w.audit != nil {
+		w.audit.start()
+	}
+
+	// 4) 启动监控根巡检goroutine：处理监控根被删除/替换的情况
+	w.bgWG.Add(1)
+	go w.runRootMonitor()
+
+	w.bgWG.Add(1)
+	go w.runWorkers()
+
+	w.bgWG.Add(1)
+	go w.runWatchRetry()
+
+	if w.spillEnabled() {
+		w.bgWG.Add(1)
+		go w.runSpiller()
+	}
+	if w.cfg.ValidateStoreOnStart {
+		for _, issue := range w.ValidateStore() {
+			w.emitError(issue)
+		}
+	}
+
+	// 5) 可选：后台全量扫描并提交基线快照，扫描期间的事件在基线之后回放
+	if w.cfg.ScanOnStart {
+		w.bgWG.Add(1)
+		go func() {
+			defer w.bgWG.Done()
+			if !w.scanBaseline() {
+				return // 扫描被 Stop 中断
+			}
+			w.replayAfterScan.Store(true)
+			w.scanning.Store(false)
+			close(w.readyChan)
+		}()
+	} else {
+		close(w.readyChan)
+	}
+
+	w.started.Store(true)
 	return nil
 }
 
+// Ready 返回一个在 Watcher 拥有完整视图时关闭的通道
+//
+// 未开启 ScanOnStart 时，Start 注册完监控目录即关闭；
+// 开启时，在基线快照提交后关闭。扫描被 Stop 中断时该通道不会关闭
+func (w *Watcher) Ready() <-chan struct{} {
+	return w.readyChan
+}
+
+// WaitReady 阻塞直到 Ready() 关闭、ctx 结束或 Watcher 被停止
+func (w *Watcher) WaitReady(ctx context.Context) error {
+	select {
+	case <-w.readyChan:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.stopChan:
+		return fmt.Errorf("%w before becoming ready", ErrStopped)
+	}
+}
+
 // Stop 停止监控
 //
 // 关闭 stopChan，停止所有goroutine，关闭底层 fsnotify.Watcher，停止ticker
-// 在退出前flush一次合并队列中的事件，并最后关闭 EventChan
+// 在退出前flush一次合并队列中的事件并等待处理完成，最后关闭 EventChan 与 ErrorChan
+// 停止时的顺序是确定的：事件读取goroutine先处理 fsnotify 已送达(已在 Events 通道中)的全部事件，
+// 随后执行最后一次 flush，处理完成后才关闭各通道，因此 Stop 前已送达的变更都会出现在最终快照中；
+// 内核在此之前尚未送达的事件(如 Stop 前一刻的写入)不会被捕获
+// 注意：若 EventChan 已满且无人消费，Stop 会等待到事件被读取为止；不读取 EventChan 时应开启 cfg.DisableEventChan
+// 可重复调用；需要得知关闭过程中的问题时使用 Close
 func (w *Watcher) Stop() {
+	_ = w.Close()
+}
+
+// Close 停止监控并返回关闭过程中遇到的问题(实现 io.Closer)
+//
+// 行为与 Stop 相同，可重复及并发调用：只有第一次调用执行关闭，之后的调用等待其完成并返回同一个错误
+// 返回的错误由 errors.Join 汇总，可能包括：关闭 fsnotify 失败、初始扫描被中断(基线快照未提交)、审计日志最后一次写出或关闭失败、快照写入 Store 失败
+//
+// Close 返回时保证：
+//   - Watcher 启动的所有goroutine(事件读取、合并、监控根巡检、初始扫描、快照换出、审计写入、worker 与批次统计)均已退出，
+//     反复创建与关闭 Watcher 不会累积goroutine
+//   - fsnotify.Watcher 与 ticker 已关闭
+//   - 审计日志已写出，AuditPath 打开的文件已关闭
+//   - 配置了 Store 时，仍在内存中的快照已写入 Store
+//   - 所有订阅(Subscribe)、EventChan(若已创建)与 ErrorChan 已关闭(已缓冲的事件仍可读出)
+//   - 进行中的 RehashFile、ApplyExternalChanges、BackfillHashes 等修改状态的调用已结束，其结果已写出；
+//     之后再调用这些方法返回 ErrStopped
+//
+// 即使返回错误，上述资源也已释放
+func (w *Watcher) Close() error {
+	w.stopOnce.Do(func() {
+		w.stopErr = w.shutdown()
+	})
+	return w.stopErr
+}
+
+// shutdown 是 Close 的实现，只执行一次
+func (w *Watcher) shutdown() error {
+	var errs []error
+	w.apiMu.Lock()
 	close(w.stopChan)
-	_ = w.fsWatcher.Close()
+	w.apiMu.Unlock()
+	// 之后的修改调用返回 ErrStopped；等待进行中的调用结束，其提交与事件赶在最后的写出与关闭通道之前
+	w.apiWG.Wait()
+	// 等待后台扫描、监控根巡检等goroutine退出，避免其在通道关闭后继续发送或注册监控
+	w.bgWG.Wait()
+	if err := w.fsWatcher.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close fsnotify watcher: %w", err))
+	}
+	// 事件来源关闭前已送达的事件由 runFsNotify 读完，随后 Events 关闭、runFsNotify 退出
+	w.fsWG.Wait()
 	w.aggTicker.Stop()
-	// 退出前 flush 一次
-	w.flushAgg(true)
-	close(w.EventChan)
+	// 合并goroutine退出时可能还有未取走的事件，并入合并map一起处理
+	w.drainAggChan()
+	if w.started.Load() && w.scanning.Load() {
+		errs = append(errs, errors.New("initial scan interrupted: baseline snapshot not committed"))
+	}
+	// 退出前 flush，等待所有已提交的变更处理完毕，避免其在通道关闭后继续发送；
+	// 因仍在处理中而放回合并表的路径在之前的批次完成后再 flush 一次
+	for {
+		w.flushAgg(true)
+		w.workerWG.Wait()
+		if w.aggLen() == 0 {
+			break
+		}
+	}
+	w.flushThrottled(true)
+	w.waitDescribes()
+	if err := w.persistResident(); err != nil {
+		errs = append(errs, err)
+	}
+	w.closeSubscribers()
+	w.closeChangeSets()
+	if w.audit != nil {
+		if err := w.audit.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if w.EventChan != nil {
+		close(w.EventChan)
+	}
+	close(w.ErrorChan)
+	return errors.Join(errs...)
+}
+
+// beginMutation 登记一次会提交快照、发送事件或写入 Store 的公开调用，Watcher 已 Close 时返回 ErrStopped；
+// 返回nil时调用方需在返回前调用 endMutation
+func (w *Watcher) beginMutation() error {
+	w.apiMu.Lock()
+	defer w.apiMu.Unlock()
+	if w.stopped() {
+		return ErrStopped
+	}
+	w.apiWG.Add(1)
+	return nil
+}
+
+// endMutation 结束 beginMutation 登记的调用
+func (w *Watcher) endMutation() {
+	w.apiWG.Done()
+}
+
+// drainAggChan 把合并通道中剩余的事件并入 aggMap，仅在合并goroutine退出后调用
+func (w *Watcher) drainAggChan() {
+	for {
+		select {
+		case ev := <-w.aggChan:
+			w.mergeAgg(ev)
+		default:
+			return
+		}
+	}
 }
 
 // GetCurrentSnapshot 返回当前(最新)快照
 //
-// 并发安全
+// DisableSnapshots 时返回当前状态在调用时刻的副本(不在快照列表中，无父节点)，每次调用复制一次文件表；
+// DisableCurrentState 时返回nil
+// 只需要个别路径或汇总信息时优先使用 CurrentFile/CurrentLen/CurrentRootHash，它们不复制文件表
+// 并发安全；快照模式下不加锁，不会被进行中的提交阻塞
 func (w *Watcher) GetCurrentSnapshot() *SnapshotNode {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
-	return w.current
+	if w.cfg.DisableSnapshots {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		return w.liveSnapshotLocked()
+	}
+	return w.head.Load()
 }
 
 // GetSnapshotByID 根据快照ID获取快照
 //
-// 若找不到则返回nil
-// 并发安全
+// 若找不到则返回nil；DisableSnapshots 时总是返回nil
+// 已换出到 Store 的快照会被透明地读回，读回失败时记录警告(见 RecentErrors)并返回nil
+// 并发安全，不加锁
 func (w *Watcher) GetSnapshotByID(id string) *SnapshotNode {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
-	return w.snapshots[id]
+	return w.snapshotByID(id)
 }
 
 // ListAllSnapshots 列出所有已知快照
 //
-// 并发安全
+// DisableSnapshots 时返回空列表；已换出到 Store 的快照以占位节点返回(Spilled() 为 true，Files 为nil)
+// 并发安全，不加锁
 func (w *Watcher) ListAllSnapshots() []*SnapshotNode {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
-	out := make([]*SnapshotNode, 0, len(w.snapshots))
-	for _, sn := range w.snapshots {
-		out = append(out, sn)
-	}
-	return out
+	return w.snapshots.all()
 }
 
-// runFsNotify 不断读取 fsnotify 的事件并投递到合并队列
+// runFsNotify 不断读取 fsnotify 的事件并投递到合并队列，直到事件来源的 Events 关闭
+//
+// 停止时 shutdown 先关闭事件来源，这里读完其交出的剩余事件(见 EventSource)，使其进入最后一次 flush；
+// Events 为nil(从不产生事件)时在停止时直接退出
 func (w *Watcher) runFsNotify() {
+	defer w.fsWG.Done()
+	w.fsAlive.Store(true)
+	defer w.fsAlive.Store(false)
+	events, errs := w.fsWatcher.Events(), w.fsWatcher.Errors()
+	var stop <-chan struct{}
+	if events == nil {
+		stop = w.stopChan
+	}
 	for {
 		select {
-		case ev := <-w.fsWatcher.Events:
-			if w.isIgnored(ev.Name) {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			w.handleFsEvent(ev.fsnotifyEvent())
+
+		case err, ok := <-errs:
+			if !ok {
+				// Errors 可能先于 Events 关闭，继续读完剩余事件
+				errs = nil
 				continue
 			}
-			// 如果是新建目录，需要额外Add
-			if ev.Op&fsnotify.Create == fsnotify.Create {
-				if fi, e2 := os.Stat(ev.Name); e2 == nil && fi.IsDir() {
-					_ = w.fsWatcher.Add(ev.Name)
-				}
+			if isOverflow(err) {
+				w.handleOverflow(err)
+				continue
+			}
+			if w.emitError(fmt.Errorf("fsnotify: %w", err)) {
+				w.logWarn("fsnotify error", err)
 			}
-			w.queueAgg(ev)
-
-		case err := <-w.fsWatcher.Errors:
-			fmt.Printf("fsnotify error: %v\n", err)
 
-		case <-w.stopChan:
+		case <-stop:
 			return
 		}
 	}
 }
 
+// handleFsEvent 处理一个 fsnotify 事件：过滤、维护监控注册，然后送入合并队列
+func (w *Watcher) handleFsEvent(ev fsnotify.Event) {
+	// 监控长路径时 fsnotify 回报的路径带 `\\?\` 前缀，统一还原，再转换为快照中的形式
+	ev.Name = w.keyOf(fromLongPath(ev.Name))
+	w.counters.eventsReceived.Add(1)
+	if w.ignoredRaw(ev.Name) {
+		w.counters.eventsIgnored.Add(1)
+		return
+	}
+	// 路径改写(PathRewrite)：忽略规则按原始路径匹配之后才改写为逻辑路径
+	if !w.rewriteEvent(&ev) {
+		return
+	}
+	// 平台频繁改写元信息(IgnoreChmod)：只有 Chmod 的事件不是内容变化
+	if w.cfg.IgnoreChmod && ev.Op == fsnotify.Chmod {
+		w.counters.eventsIgnored.Add(1)
+		return
+	}
+	if w.hot.enabled() {
+		w.hot.record(ev.Name, w.now())
+	}
+	// 监控根自身被删除/移走：交给 runRootMonitor 处理，等待其重新出现
+	if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && w.isRoot(ev.Name) {
+		select {
+		case w.rootLostChan <- ev.Name:
+		default:
+			// 通道满时由周期性巡检兜底
+		}
+		return
+	}
+	// 如果是新建目录，需要额外Add，并补上监控注册之前已在其中创建的条目
+	if ev.Op&fsnotify.Create == fsnotify.Create {
+		if fi, e2 := w.fs.Stat(ev.Name); e2 == nil && fi.IsDir() {
+			w.watchNewDir(ev.Name)
+		}
+	}
+	// 目录被删除/移走：释放其占用的监控预算
+	if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		w.releaseWatches(ev.Name)
+	}
+	// 本进程自己的写入(MarkSelfWrite)：监控照常维护，事件不进入合并表
+	if w.suppressSelf(ev.Name) {
+		return
+	}
+	w.queueAgg(ev)
+}
+
 // runAggregator 负责对短时间内的事件进行合并
 func (w *Watcher) runAggregator() {
+	defer w.bgWG.Done()
+	// 合并表达到 limit 时立即 flush；limit 在每次 flush 后按留在表中的路径(仍在处理中而延后的)重新计算，
+	// 避免这些路径让每个新事件都触发一次 flush
+	limit := w.cfg.MaxPendingPaths
 	for {
 		select {
 		case ev := <-w.aggChan:
-			w.aggMu.Lock()
-			op, ok := w.aggMap[ev.Name]
-			if !ok {
-				w.aggMap[ev.Name] = ev.Op
-			} else {
-				w.aggMap[ev.Name] = op | ev.Op
+			if w.mergeAgg(ev) >= limit && !w.scanning.Load() {
+				w.counters.sizeFlushes.Add(1)
+				w.flushAgg(false)
+				limit = w.aggLen() + w.cfg.MaxPendingPaths
 			}
-			w.aggMu.Unlock()
 
-		case <-w.aggTicker.C:
+		case <-w.aggTicker.C():
+			now := w.now()
+			w.checkStorm(now)
+			w.flushErrorDedup(now)
+			w.requeueHashRetries()
+			w.checkCompletions()
+			if w.stormDelaysFlush(now) {
+				continue // 降级期间把 flush 间隔拉长到 StormMaxDebounce
+			}
 			w.flushAgg(false)
+			w.flushThrottled(false)
+			limit = w.aggLen() + w.cfg.MaxPendingPaths
 
 		case <-w.stopChan:
 			return
@@ -280,152 +902,628 @@ func (w *Watcher) runAggregator() {
 
 // flushAgg 将合并map(aggMap)中的事件批量提交给workerPool处理
 // force=false时是周期性flush；force=true时是Stop()阶段最后一次flush
+//
+// 初始扫描期间周期性flush会被跳过，事件留在aggMap中合并，待基线快照提交后再统一回放
 func (w *Watcher) flushAgg(force bool) {
+	if !force && w.scanning.Load() {
+		return
+	}
+	replay := w.replayAfterScan.Swap(false)
+
+	// 与备用表交换而不是复制：空闲期的周期性flush不分配内存
 	w.aggMu.Lock()
-	tmp := make(map[string]fsnotify.Op, len(w.aggMap))
-	for k, v := range w.aggMap {
-		tmp[k] = v
+	pending := w.aggMap
+	w.aggMap = w.aggSpare
+	if w.aggMap == nil {
+		w.aggMap = make(map[string]aggEntry)
+	}
+	w.aggSpare = nil
+	var first int64
+	if len(pending) > 0 {
+		first = w.aggFirst.Swap(0)
 	}
-	w.aggMap = make(map[string]fsnotify.Op)
 	w.aggMu.Unlock()
 
-	for p, op := range tmp {
-		select {
-		case w.workerPool <- struct{}{}:
-			// 提交给worker处理
-			go func(fp string, fop fsnotify.Op) {
-				defer func() { <-w.workerPool }()
-				w.handleFileChange(fp, fop)
-			}(p, op)
-		default:
-			// 如果workerPool已满，可以根据需要阻塞提交或者丢弃
-			w.workerPool <- struct{}{}
-			go func(fp string, fop fsnotify.Op) {
-				defer func() { <-w.workerPool }()
-				w.handleFileChange(fp, fop)
-			}(p, op)
+	w.counters.flushCycles.Add(1)
+	w.counters.lastFlushAt.Store(w.now().UnixNano())
+	if len(pending) == 0 {
+		w.recycleAggMap(pending)
+		return
+	}
+	w.counters.batchesProcessed.Add(1)
+
+	items := make([]aggItem, 0, len(pending))
+	for p, e := range pending {
+		items = append(items, aggItem{p, e})
+	}
+	w.recycleAggMap(pending)
+
+	// SettleDelay：最近一个事件之后还没有安静足够久的路径放回合并表，Stop 时不等待
+	if w.cfg.SettleDelay > 0 && !force {
+		if items = w.settled(items); len(items) == 0 {
+			return
+		}
+	}
+
+	// 之前的批次中仍在处理的路径放回合并表，留到下一次 flush
+	items, busy := w.inflight.claim(items)
+	w.requeueAgg(busy)
+	if len(items) == 0 {
+		return
+	}
+
+	// 配置了 Priority 时拆分为快、慢两条车道，见 lanes.go
+	var bulk []aggItem
+	if w.cfg.Priority != nil {
+		items, bulk = w.splitLanes(items)
+	}
+
+	var span BatchSpan
+	if w.cfg.Tracer != nil {
+		paths := make([]string, 0, len(items)+len(bulk))
+		for _, it := range items {
+			paths = append(paths, it.path)
+		}
+		for _, it := range bulk {
+			paths = append(paths, it.path)
+		}
+		span = w.cfg.Tracer.StartBatch(paths)
+	}
+
+	// 批次内全部路径处理完成后记录批次耗时，从第一个事件进入 aggChan 算起(重试等直接并入合并表的路径从 flush 算起)
+	start := time.Now()
+	if first != 0 && first < start.UnixNano() {
+		start = time.Unix(0, first)
+	}
+	var batch sync.WaitGroup
+	batch.Add(len(items) + len(bulk))
+	if len(bulk) > 0 {
+		w.dispatchBulk(bulk, replay, span, batch.Done)
+	}
+	w.workerWG.Add(1)
+	go func() {
+		defer w.workerWG.Done()
+		batch.Wait()
+		w.counters.batchLatency.observe(time.Since(start))
+		w.counters.batchesCompleted.Add(1)
+		if span != nil {
+			span.End()
+		}
+	}()
+
+	// 每个批次最多启动有效上限(WorkerCount，见 workers.go)个goroutine，各自从 items 中领取路径直到取完，
+	// 而不是每个路径一个goroutine；workerPool 已满时阻塞等待上一批次释放令牌(计入 Stats().WorkerWaits)
+	var next atomic.Int64
+	var waited bool
+	n := min(len(items), w.workerLimit())
+	w.lanes.fastQueued.Add(int64(len(items)))
+	for i := 0; i < n; i++ {
+		if w.acquireWorker() {
+			waited = true
 		}
+		w.workerWG.Add(1)
+		go func() {
+			defer func() {
+				<-w.workerPool
+				w.workerWG.Done()
+			}()
+			for {
+				j := int(next.Add(1)) - 1
+				if j >= len(items) {
+					return
+				}
+				w.lanes.fastQueued.Add(-1)
+				w.processItem(items[j], replay, span)
+				w.inflight.release(items[j].path)
+				batch.Done()
+			}
+		}()
+	}
+	if waited {
+		w.workers.waits.Add(1)
+	}
+}
+
+// aggEvent 是合并通道中的事件，at 为进入 Watcher 的时间(零值表示并入合并表时)
+type aggEvent struct {
+	fsnotify.Event
+	at time.Time
+}
+
+// aggEntry 是合并表中一个路径的状态：合并后的操作、第一个事件到达的时间(FileEvent.FirstSeen)、最近一个事件到达的时间(SettleDelay)
+// 与 RecordOpTrace 时的底层操作序列(FileEvent.OpTrace)
+type aggEntry struct {
+	op    fsnotify.Op
+	first time.Time
+	last  time.Time
+	trace []OpSample
+}
+
+// merge 并入在 at 到达的操作 op，first 取两者中较早的，last 取较晚的
+func (e aggEntry) merge(op fsnotify.Op, at time.Time) aggEntry {
+	return e.join(aggEntry{op: op, first: at, last: at})
+}
+
+// join 并入另一个状态(如放回合并表的路径与期间新到达的事件)
+func (e aggEntry) join(o aggEntry) aggEntry {
+	e.op |= o.op
+	if e.first.IsZero() || (!o.first.IsZero() && o.first.Before(e.first)) {
+		e.first = o.first
+	}
+	if o.last.After(e.last) {
+		e.last = o.last
 	}
+	return e
+}
+
+// aggItem 是一次flush中待处理的路径及其合并后的状态
+type aggItem struct {
+	path string
+	aggEntry
+}
+
+// maxSpareAggMap 是留待复用的合并表的最大条目数；事件风暴后的大表直接丢弃，
+// 以免 clear 后仍长期占用其桶内存
+const maxSpareAggMap = 4096
+
+// recycleAggMap 清空已取走的合并表，留给下一次flush作为新的 aggMap
+func (w *Watcher) recycleAggMap(m map[string]aggEntry) {
+	if len(m) > maxSpareAggMap {
+		return
+	}
+	clear(m)
+	w.aggMu.Lock()
+	w.aggSpare = m
+	w.aggMu.Unlock()
 }
 
-// queueAgg 将事件放入合并通道，若满则阻塞
+// queueAgg 将事件放入合并通道，若满则阻塞；停止过程中直接并入合并表
+//
+// 合并表为空后的第一个事件记录进入时间(批次延迟的起点)，其余事件只多一次原子读
 func (w *Watcher) queueAgg(ev fsnotify.Event) {
-	w.aggChan <- ev
+	if w.aggFirst.Load() == 0 {
+		w.aggFirst.CompareAndSwap(0, time.Now().UnixNano())
+	}
+	ae := aggEvent{ev, w.now()}
+	select {
+	case w.aggChan <- ae:
+	case <-w.stopChan:
+		// 合并goroutine可能已退出：直接并入合并表，由 Close 的最后一次 flush 处理
+		w.mergeAgg(ae)
+		return
+	}
+	observeHighWater(&w.counters.aggHighWater, uint64(len(w.aggChan)))
+}
+
+// mergeAgg 把事件并入合并表，返回合并后表中的路径数
+func (w *Watcher) mergeAgg(ev aggEvent) int {
+	if ev.at.IsZero() {
+		ev.at = w.now()
+	}
+	w.aggMu.Lock()
+	e, ok := w.aggMap[ev.Name]
+	e = e.merge(ev.Op, ev.at)
+	if w.cfg.RecordOpTrace {
+		e.trace = appendTrace(e.trace, OpSample{Op: eventOpFromFsnotify(ev.Op), At: ev.at}, w.cfg.MaxOpTrace)
+	}
+	w.aggMap[ev.Name] = e
+	n := len(w.aggMap)
+	w.aggMu.Unlock()
+	if ok {
+		w.counters.eventsCoalesced.Add(1)
+	}
+	w.counters.eventsAggregated.Add(1)
+	return n
 }
 
 // handleFileChange 进行"更新快照"的逻辑处理
 // 当文件被创建/修改/删除时，都会创建一个新的快照(引用父快照的数据)，并在新快照的 Files 中更新对应文件
+//
+// stat与哈希在锁外完成，随后在 commitSnapshot 中一次性复制父快照、应用变更并发布新快照，
+// 保证快照一旦对外可见就不再被修改
 func (w *Watcher) handleFileChange(path string, op fsnotify.Op) {
-	fileInfo, statErr := os.Stat(path)
+	w.applyChange(aggItem{path, aggEntry{op: op}}, false, nil)
+}
+
+// applyChange 是 handleFileChange 的实现
+//
+// skipUnchanged=true 时，若重新采集的结果与当前快照一致(或删除的路径本就不存在)则不生成快照，
+// 用于回放初始扫描期间积压的事件，避免与基线快照重复计数
+// span 为所属批次的追踪(未配置 Tracer 时为nil)；it.first 为零值(不经合并表直接处理)时以开始处理的时间为 FirstSeen
+func (w *Watcher) applyChange(it aggItem, skipUnchanged bool, span BatchSpan) {
+	path, op, first := it.path, it.op, it.first
+	if first.IsZero() {
+		first = w.now()
+	}
+	if w.checkDenied(path) {
+		return // 位于不可读的目录之下，见 access.go
+	}
+	fileInfo, statErr := w.fs.Stat(path)
 	if statErr != nil && !os.IsNotExist(statErr) {
-		fmt.Printf("Error stating file: %v\n", statErr)
+		if isDenied(statErr) {
+			if dir := w.deniedAncestor(path); dir != "" {
+				w.denyDir(dir, statErr)
+				w.refreshDir(dir)
+				return
+			}
+		}
+		if w.emitError(fmt.Errorf("failed to stat %s: %w", path, statErr)) {
+			w.logWarn("Error stating file", statErr)
+		}
 		return
 	}
 
-	// 复制 currentSnap => newSnap
+	// 目录权限变化：检查内容能否读取，恢复访问时在提交后对账其子树
+	var restored bool
+	if statErr == nil && fileInfo.IsDir() && (op.Has(fsnotify.Chmod) || w.denied.has(path)) {
+		if err := w.probeDir(path); isDenied(err) {
+			w.denyDir(path, err)
+		} else if err == nil {
+			restored = w.denied.remove(path)
+		}
+	}
+
+	var completed bool
+	if statErr == nil && w.awaitsCompletion(path, fileInfo) {
+		var hold bool
+		if hold, completed = w.holdForCompletion(path, fileInfo); hold {
+			return // 仍在写入，等待 checkCompletions 判定完成
+		}
+	}
+
+	prev := w.currentMeta(path)
+
+	var changes map[string]*FileMetadata
+	if os.IsNotExist(statErr) {
+		// 文件已删除 => 从新快照中移除
+		w.clearHashRetry(path)
+		w.denied.remove(path)
+		if w.clearCompletion(path) && prev == nil {
+			return // 写入完成前就被删除，从未发送过事件
+		}
+		// 非删除事件但文件已不存在时(如 rename 的旧路径)，沿用原有逻辑：仍生成快照，但不改动文件表
+		if skipUnchanged && prev == nil {
+			return
+		}
+		changes = make(map[string]*FileMetadata, 1)
+		if op&fsnotify.Remove == fsnotify.Remove {
+			changes[path] = nil
+		}
+	} else if meta := w.buildMeta(path, fileInfo, prev, span); meta == nil {
+		// 哈希失败且策略为 DropEntry：不记录该文件，已有的条目按删除处理
+		if prev == nil {
+			return
+		}
+		op = fsnotify.Remove
+		changes = map[string]*FileMetadata{path: nil}
+	} else {
+		if skipUnchanged && !metaChanged(prev, meta) {
+			return
+		}
+		changes = map[string]*FileMetadata{path: meta}
+		// 补齐快照中缺失的上级目录，使目录哈希能一路传递到监控根
+		for p, m := range w.missingAncestors(path) {
+			changes[p] = m
+		}
+	}
+
+	c := w.commitSnapshot(fmt.Sprintf("Snapshot after %s on %s", op.String(), path), changes, path)
+	if span != nil && c.snap != nil && c.pending == nil {
+		span.SnapshotCreated(path, c.snap.ID)
+	}
+	ev := w.fileEvent(path, op, c)
+	ev.Completed = completed
+	ev.FirstSeen = first
+	ev.OpTrace = it.trace
+	if latency := ev.Processed.Sub(first); latency >= 0 {
+		w.counters.eventLatency.observe(latency)
+	}
+	w.emitCommitted(c, ev)
+	if restored {
+		w.restoreDir(path)
+	}
+}
+
+// buildMeta 根据 stat 结果构造文件元信息，普通文件会计算内容哈希
+//
+// prev 为当前快照中该路径的记录(可为nil)，用于追加写检测与 HashErrorKeepPrevious
+// 目录不在此计算哈希，目录哈希在提交时由子节点推导
+// 哈希失败且 HashErrorPolicy 为 DropEntry 时返回nil，调用方不应记录该文件；span 可为nil
+func (w *Watcher) buildMeta(path string, fileInfo os.FileInfo, prev *FileMetadata, span BatchSpan) *FileMetadata {
+	isDir := fileInfo.IsDir()
+	var res hashResult
+	if !isDir {
+		if res = w.hashFor(path, fileInfo, prev, span); res.drop {
+			return nil
+		}
+	}
+
+	return &FileMetadata{
+		Path:          path,
+		Size:          fileInfo.Size(),
+		ModTime:       fileInfo.ModTime(),
+		Hash:          res.hash,
+		HashState:     res.state,
+		IsDirectory:   isDir,
+		CreatedAt:     w.now(),
+		LastModified:  fileInfo.ModTime(),
+		BirthTime:     w.birthTime(path, fileInfo),
+		AppendedBytes: res.appended,
+		Inaccessible:  isDir && w.denied.has(path),
+		rewritten:     res.rewritten,
+	}
+}
+
+// commitResult 是 commitSnapshot 的结果
+//
+// snap 为新快照，DisableSnapshots 时为nil；old/cur 为 focus 路径在提交前后的元信息
+// 启用 MinSnapshotInterval 时 snap 为nil，pending 为变更并入的待发布快照，
+// ready 为本次提交顺带发布的快照上此前延后的事件，见 throttle.go
+type commitResult struct {
+	snap     *SnapshotNode
+	old, cur *FileMetadata
+	pending  *SnapshotNode
+	ready    []FileEvent
+	describe describeJob // 发布后待生成描述的快照，由 commitSnapshot 在释放 w.mu 后执行(见 describeCommitted)
+}
+
+// commitSnapshot 以当前快照为父节点创建并发布一个新快照
+//
+// changes 中 value 为 nil 表示删除该路径(目录会连同其子树一起删除)，否则表示新增/更新
+// 提交时只对受影响路径上的目录重新计算哈希，并更新快照的 RootHash
+// focus 为要发送事件的路径(可为空)，其提交前后的元信息在同一把锁内读取，随结果返回
+// DisableSnapshots 时不创建快照，changes 直接应用到当前状态上(见 commitLiveLocked)
+func (w *Watcher) commitSnapshot(desc string, changes map[string]*FileMetadata, focus string) commitResult {
 	w.mu.Lock()
-	parentSnap := w.current
+	c := w.commitLocked(desc, changes, focus)
+	w.mu.Unlock()
+	w.describeCommitted(&c)
+	return c
+}
+
+// commitLocked 是 commitSnapshot 的实现，调用方需持有 w.mu 写锁，并在释放后调用 describeCommitted
+func (w *Watcher) commitLocked(desc string, changes map[string]*FileMetadata, focus string) commitResult {
+	if w.cfg.DisableSnapshots {
+		return w.commitLiveLocked(changes, focus)
+	}
+	if w.throttled() {
+		return w.commitThrottledLocked(desc, changes, focus)
+	}
+
+	parentSnap := w.head.Load()
 	newSnap := &SnapshotNode{
 		ID:          w.newSnapID(),
+		Instance:    w.cfg.InstanceID,
 		ParentIDs:   []string{parentSnap.ID},
-		CreatedAt:   time.Now(),
-		Description: fmt.Sprintf("Snapshot after %s on %s", op.String(), path),
-		Files:       make(map[string]*FileMetadata),
+		CreatedAt:   w.now(),
+		Description: desc,
+		// 条目提交后不再被修改(见 rehashDirsLocked)，新快照与父快照共享未变化的条目，只复制文件表
+		Files:  maps.Clone(parentSnap.Files),
+		Config: w.snapshotConfig(),
 	}
-	// 复制父快照的所有文件信息
-	for k, v := range parentSnap.Files {
-		copyMeta := *v
-		newSnap.Files[k] = &copyMeta
+	if newSnap.Files == nil {
+		newSnap.Files = make(map[string]*FileMetadata)
 	}
-	w.snapshots[newSnap.ID] = newSnap
-	w.current = newSnap
-	w.mu.Unlock()
+	w.applyChangesLocked(newSnap.Files, changes)
+	if t := parentSnap.trie.Load(); t != nil {
+		newSnap.trie.Store(deriveTrie(t, changes, newSnap.Files))
+	}
+	setChangedPaths(newSnap, changes, w.cfg.MaxChangedPaths)
+	newSnap.RootHash = w.rootHashLocked(newSnap.Files)
+	w.publishLocked(newSnap)
+	return commitResult{snap: newSnap, old: live(parentSnap.Files[focus]), cur: live(newSnap.Files[focus]), describe: w.describeJobFor(newSnap, parentSnap)}
+}
 
-	// 文件已删除 => 从 newSnap.Files 移除
-	if os.IsNotExist(statErr) && (op&fsnotify.Remove == fsnotify.Remove) {
-		w.mu.Lock()
-		delete(newSnap.Files, path)
-		w.mu.Unlock()
+// publishLocked 登记快照并把 head 推进到它，调用方需持有 w.mu 写锁
+func (w *Watcher) publishLocked(sn *SnapshotNode) {
+	w.expireTombstonesLocked(sn)
+	// 先登记再推进 head：读到新 head 的调用方一定能按 ID 找到它
+	w.snapshots.put(sn)
+	w.head.Store(sn)
+	w.trackResidentLocked(sn.ID)
+	w.counters.snapshotsCreated.Add(1)
+	// 在合并历史之前计算变更集：相对发布时的父快照
+	w.publishChangeSetLocked(sn)
+	w.limitHistoryLocked(sn)
+}
 
-		w.emitFileEvent(path, op, newSnap)
-		return
+// applyChangesLocked 把 changes 应用到 files 上并重新计算受影响目录的哈希，调用方需持有 w.mu 写锁
+//
+// TombstoneSnapshots 时被删除的条目最后作为墓碑写回(见 buryLocked)，不影响目录哈希
+func (w *Watcher) applyChangesLocked(files map[string]*FileMetadata, changes map[string]*FileMetadata) {
+	var removed map[string]*FileMetadata
+	if w.tombstoning() {
+		removed = make(map[string]*FileMetadata)
 	}
-
-	// 若是目录则不计算哈希
-	isDir := false
-	hashVal := ""
-	if fileInfo != nil {
-		isDir = fileInfo.IsDir()
-		if !isDir {
-			h, err := hashFile(path)
-			if err != nil {
-				fmt.Printf("Error hashing file %s: %v\n", path, err)
-				// 这里return还是继续更新均可，但hash失败可能只是临时问题（（
-				// 这里只打印错误，但仍继续更新
-			} else {
-				hashVal = h
+	// 先删除后更新，避免同一批次中"删目录+建子文件"被后执行的删除吞掉
+	dirty := make(map[string]struct{}, len(changes))
+	for p, meta := range changes {
+		if meta == nil {
+			if m, ok := files[p]; ok && m.Deleted {
+				continue // 已经是墓碑
 			}
+			if removed != nil {
+				w.collectTreeLocked(files, p, removed)
+			}
+			w.removeTreeLocked(files, p)
+			dirty[p] = struct{}{}
 		}
 	}
+	for p, meta := range changes {
+		if meta != nil {
+			if live(files[p]) == nil {
+				w.linkChildLocked(p)
+				w.reviveLocked(p)
+			}
+			files[p] = meta
+			dirty[p] = struct{}{}
+		}
+	}
+	w.rehashDirsLocked(files, dirty)
+	w.buryLocked(files, removed)
+}
 
-	// 更新 newSnap 中的该文件信息
-	if fileInfo != nil {
-		meta := &FileMetadata{
-			Path:         path,
-			Size:         fileInfo.Size(),
-			ModTime:      fileInfo.ModTime(),
-			Hash:         hashVal,
-			IsDirectory:  isDir,
-			CreatedAt:    time.Now(),
-			LastModified: fileInfo.ModTime(),
+// emitFileEvent 向外部发送事件，若 EventChan 满则阻塞；DisableEventChan 时只发给订阅者与审计日志
+//
+// c 为该路径变更的提交结果，提供新快照与变更前后的元信息；
+// 先发送 c.ready 中延后的事件，变更并入的快照尚未发布时事件本身也延后(见 MinSnapshotInterval)
+func (w *Watcher) emitFileEvent(path string, op fsnotify.Op, c commitResult) {
+	w.emitCommitted(c, w.fileEvent(path, op, c))
+}
+
+// fileEvent 构造 path 的变更事件，NewSnap 在发送时填充
+func (w *Watcher) fileEvent(path string, op fsnotify.Op, c commitResult) FileEvent {
+	return FileEvent{FilePath: path, Root: w.attributeRoot(path), Kind: eventOpFromFsnotify(op), Op: op, OldMeta: c.old, NewMeta: c.cur, Processed: w.now()}
+}
+
+// emitCommitted 发送同一次提交产生的事件，NewSnap 由 c 填充；先发送 c.ready 中延后的事件，
+// 变更并入的快照尚未发布时这些事件也延后
+func (w *Watcher) emitCommitted(c commitResult, evs ...FileEvent) {
+	w.emitEvents(c.ready)
+	for _, ev := range evs {
+		ev.NewSnap = c.snap
+		if c.pending != nil {
+			if w.deferEvent(c.pending, ev) {
+				continue
+			}
+			ev.NewSnap = c.pending
 		}
+		w.emitEvent(ev)
+	}
+}
 
-		w.mu.Lock()
-		newSnap.Files[path] = meta
-		w.mu.Unlock()
+// emitEvents 依次发送事件
+func (w *Watcher) emitEvents(evs []FileEvent) {
+	for _, ev := range evs {
+		w.emitEvent(ev)
 	}
+}
 
-	w.emitFileEvent(path, op, newSnap)
+// emitEvent 填充父快照ID，按 EventSnapshotMode 替换快照后把事件发给订阅者、审计日志与 EventChan
+func (w *Watcher) emitEvent(ev FileEvent) {
+	ev.SizeDelta, ev.Truncated = sizeChange(ev.OldMeta, ev.NewMeta)
+	if ev.Processed.IsZero() {
+		ev.Processed = w.now()
+	}
+	if ev.FirstSeen.IsZero() {
+		ev.FirstSeen = ev.Processed
+	}
+	if ev.NewSnap != nil && len(ev.NewSnap.ParentIDs) > 0 {
+		ev.ParentSnapshotID = ev.NewSnap.ParentIDs[0]
+	}
+	w.applySnapshotMode(&ev)
+	w.publish(&ev)
+	if w.audit != nil {
+		w.audit.enqueue(w.auditRecord(&ev))
+	}
+	if w.EventChan != nil {
+		w.EventChan <- ev
+		w.counters.eventsEmitted.Add(1)
+	}
+	w.counters.lastEventAt.Store(w.now().UnixNano())
 }
 
-// emitFileEvent 向外部发送事件，若通道满则阻塞
-func (w *Watcher) emitFileEvent(path string, op fsnotify.Op, snap *SnapshotNode) {
-	w.EventChan <- FileEvent{FilePath: path, Op: op, NewSnap: snap}
+// emitError 向外部发送错误，若通道满则丢弃，避免阻塞事件处理；
+// 开启 ErrorDedupWindow 时窗口内重复的错误只计数，返回 false，调用方据此跳过对应的日志
+func (w *Watcher) emitError(err error) bool {
+	if w.cfg.ErrorDedupWindow > 0 {
+		emit, summary := w.dedupError(err, w.now())
+		if summary != nil {
+			w.sendError(summary)
+		}
+		if !emit {
+			return false
+		}
+	}
+	w.sendError(err)
+	return true
 }
 
-// isIgnored 判断路径是否匹配 cfg.IgnorePatterns
+// sendError 记录错误并发送到 ErrorChan，通道满时丢弃
+func (w *Watcher) sendError(err error) {
+	w.recentErrs.add(w.now(), err)
+	select {
+	case w.ErrorChan <- err:
+	default:
+		w.counters.errorsDropped.Add(1)
+	}
+}
+
+// isIgnored 判断路径是否匹配 cfg.IgnorePatterns，或是自动排除的自身输出路径(见 ownpaths.go)
+//
+// 含路径分隔符的模式按完整路径匹配，模式与路径都统一为 "/" 分隔后再比较，
+// 因此在 Linux 上编写的 "/" 风格模式在 Windows 上同样生效；"**" 可跨越多级目录(见 glob.go)
+// 监控根本身总是不被忽略规则排除(见 rootignore.go)
 func (w *Watcher) isIgnored(path string) bool {
-	base := filepath.Base(path)
-	for _, pat := range w.cfg.IgnorePatterns {
-		matched, _ := filepath.Match(pat, base)
-		if matched {
-			// 如果是在子目录中，且模式不包含路径分隔符，则不忽略
-			if filepath.Dir(path) != "." && !strings.Contains(pat, string(os.PathSeparator)) {
-				return false
-			}
-			return true
-		}
+	if w.rewriting() {
+		path = w.keyOf(w.diskPath(path))
 	}
-	return false
+	return w.ignoredRaw(path)
 }
 
-// hashFile 计算文件的SHA-256哈希值
-func hashFile(path string) (string, error) {
-	f, err := os.Open(path)
+// ignoredRaw 同 isIgnored，但 path 是磁盘上的原始路径
+func (w *Watcher) ignoredRaw(path string) bool {
+	if len(w.excluded) > 0 && w.ownExcluded(path) {
+		return true
+	}
+	if _, ok := w.ignoreMatch(path); !ok {
+		return false
+	}
+	// 显式配置的监控根不被忽略规则排除，见 rootignore.go
+	_, root := w.rootExempt[path]
+	return !root
+}
+
+// hasPathSeparator 判断模式中是否含有路径分隔符("/" 或当前平台的分隔符)
+func hasPathSeparator(pat string) bool {
+	return strings.ContainsAny(pat, "/"+string(os.PathSeparator))
+}
+
+// logWarn 输出一条警告日志
+//
+// 配置了 cfg.Logger 时以 Warn 级别输出(错误放在 "error" 属性中)，否则按早期格式打印到标准输出
+func (w *Watcher) logWarn(msg string, err error) {
+	if w.cfg.Logger != nil {
+		w.cfg.Logger.Warn(msg, "error", err)
+		return
+	}
+	fmt.Printf("%s: %v\n", msg, err)
+}
+
+// logInfo 在配置了 cfg.Logger 时以 Info 级别输出一条日志，未配置时不输出
+func (w *Watcher) logInfo(msg string, args ...any) {
+	if w.cfg.Logger != nil {
+		w.cfg.Logger.Info(msg, args...)
+	}
+}
+
+// hashFile 用 newHash 计算文件内容的哈希值
+//
+// buf 为读缓冲(通常取自 w.bufs)，为nil时由 io.CopyBuffer 临时分配
+func hashFile(fsys FS, path string, newHash func() hash.Hash, buf []byte) (string, error) {
+	f, err := fsys.Open(path)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
 
-	h := sha256.New()
-	_, err = io.Copy(h, f)
+	h := newHash()
+	_, err = io.CopyBuffer(h, readerOnly{f}, buf)
 	if err != nil {
 		return "", err
 	}
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// newSnapID 生成新快照ID，使用纳秒时间戳
+// newSnapID 生成新快照ID，由实例ID与纳秒时间戳组成
+//
+// 时间戳不大于上一个ID时(时钟精度不足或注入的 Clock 未推进)顺延1ns，保证ID唯一且递增
+// 调用方需持有 w.mu 写锁(或在 Watcher 发布前调用)
 func (w *Watcher) newSnapID() string {
-	return fmt.Sprintf("snap-%d", time.Now().UnixNano())
+	ns := w.now().UnixNano()
+	if ns <= w.lastSnapNano {
+		ns = w.lastSnapNano + 1
+	}
+	w.lastSnapNano = ns
+	return fmt.Sprintf("%s%s-%d", snapIDPrefix, w.cfg.InstanceID, ns)
 }
@@ -1,9 +1,16 @@
 package watcher
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -20,7 +27,7 @@ func TestHashFile(t *testing.T) {
 	_, _ = tmpFile.WriteString("Hello World!")
 	_ = tmpFile.Sync()
 
-	hashVal, err := hashFile(tmpFile.Name())
+	hashVal, err := hashFile(osFS{}, tmpFile.Name(), sha256.New, nil)
 	if err != nil {
 		t.Fatalf("hashFile failed: %v", err)
 	}
@@ -29,12 +36,73 @@ func TestHashFile(t *testing.T) {
 	}
 }
 
+// fdCountingFS 记录打开与关闭的文件数，读取 failAt 字节后返回错误
+type fdCountingFS struct {
+	FS
+	failAt         int64
+	opened, closed atomic.Int32
+}
+
+func (c *fdCountingFS) Open(name string) (io.ReadCloser, error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	c.opened.Add(1)
+	return &failingFile{f: f, fs: c, left: c.failAt}, nil
+}
+
+type failingFile struct {
+	f    io.ReadCloser
+	fs   *fdCountingFS
+	left int64
+}
+
+func (f *failingFile) Read(p []byte) (int, error) {
+	if f.left <= 0 {
+		return 0, errors.New("read interrupted")
+	}
+	if int64(len(p)) > f.left {
+		p = p[:f.left]
+	}
+	n, err := f.f.Read(p)
+	f.left -= int64(n)
+	return n, err
+}
+
+func (f *failingFile) Close() error {
+	f.fs.closed.Add(1)
+	return f.f.Close()
+}
+
+// TestHashFileClosesOnError 测试哈希中途失败(包括追加写检测的两个阶段)时文件都被关闭
+func TestHashFileClosesOnError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.bin")
+	_ = os.WriteFile(file, make([]byte, 64<<10), 0644)
+	buf := make([]byte, 4096)
+	for _, failAt := range []int64{0, 1000, 40 << 10} {
+		fsys := &fdCountingFS{FS: osFS{}, failAt: failAt}
+		if _, err := hashFile(fsys, file, sha256.New, buf); err == nil {
+			t.Errorf("failAt=%d: hashFile succeeded", failAt)
+		}
+		if _, _, err := hashFileAppend(fsys, file, sha256.New, buf, 32<<10, "x"); err == nil {
+			t.Errorf("failAt=%d: hashFileAppend succeeded", failAt)
+		}
+		if _, err := hashFile(fsys, filepath.Join(filepath.Dir(file), "missing"), sha256.New, buf); err == nil {
+			t.Errorf("hashFile of missing file succeeded")
+		}
+		if o, c := fsys.opened.Load(), fsys.closed.Load(); o != 2 || c != o {
+			t.Errorf("failAt=%d: opened %d, closed %d", failAt, o, c)
+		}
+	}
+}
+
 // TestIsIgnored 测试 isIgnored 函数
 func TestIsIgnored(t *testing.T) {
+	pats := []string{"*.tmp", ".git"}
 	w := Watcher{
-		cfg: ConfigWatcher{
-			IgnorePatterns: []string{"*.tmp", ".git"},
-		},
+		cfg:    ConfigWatcher{IgnorePatterns: pats},
+		ignore: compileGlobs(pats),
 	}
 
 	cases := []struct {
@@ -56,6 +124,31 @@ func TestIsIgnored(t *testing.T) {
 	}
 }
 
+// TestIsIgnoredPathPattern 测试含路径分隔符的模式按完整路径("/" 统一分隔)匹配
+func TestIsIgnoredPathPattern(t *testing.T) {
+	pats := []string{"/data/build/*.o"}
+	w := Watcher{
+		cfg:    ConfigWatcher{IgnorePatterns: pats},
+		ignore: compileGlobs(pats),
+	}
+
+	cases := []struct {
+		path   string
+		ignore bool
+	}{
+		{filepath.FromSlash("/data/build/main.o"), true},
+		{filepath.FromSlash("/data/src/main.o"), false},
+		{filepath.FromSlash("/data/build/main.c"), false},
+	}
+
+	for _, c := range cases {
+		got := w.isIgnored(c.path)
+		if got != c.ignore {
+			t.Errorf("isIgnored(%s) = %v; want %v", c.path, got, c.ignore)
+		}
+	}
+}
+
 // TestWatcherBasic 测试 Watcher 的基本功能
 func TestWatcherBasic(t *testing.T) {
 	testDir, err := ioutil.TempDir("", "watcher-test-")
@@ -124,18 +217,146 @@ func TestWatcherBasic(t *testing.T) {
 	}
 }
 
-// BenchmarkHashFile 基准测试
-func BenchmarkHashFile(b *testing.B) {
-	tmpFile, _ := ioutil.TempFile("", "benchfile-")
-	defer os.Remove(tmpFile.Name())
-	defer tmpFile.Close()
+// TestCloseIdempotent 测试 Close/Stop 可重复调用并返回同一结果
+func TestCloseIdempotent(t *testing.T) {
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{t.TempDir()}})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	var c io.Closer = w
+	if err := c.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+	w.Stop()
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close returned %v", err)
+	}
+	if _, ok := <-w.EventChan; ok {
+		t.Error("EventChan should be closed")
+	}
+}
 
-	data := make([]byte, 1024*1024) // 1MB
-	tmpFile.Write(data)
-	tmpFile.Sync()
+// TestCloseWithoutStart 测试未 Start 时关闭不会阻塞，并释放审计日志文件
+func TestCloseWithoutStart(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{dir}, AuditPath: filepath.Join(dir, "audit.log")})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+	if w.audit.file != nil {
+		t.Error("audit log file not closed")
+	}
+}
+
+// failingWriter 总是写入失败
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+// TestCloseReportsAuditError 测试审计日志最后一次写出失败时 Close 返回错误
+func TestCloseReportsAuditError(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcher(ConfigWatcher{WatchPaths: []string{root}, AuditWriter: failingWriter{}, AuditFlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	go func() {
+		for range w.EventChan {
+		}
+	}()
+	go func() {
+		for range w.ErrorChan {
+		}
+	}()
+	if _, _, err := w.RehashFile(root); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+	waitFor(t, time.Second, func() bool { return w.Stats().AuditWritten > 0 })
+	if err := w.Close(); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("expected audit flush error, got %v", err)
+	}
+}
+
+// TestDisableEventChan 测试不创建 EventChan 时快照与订阅照常工作，关闭不会阻塞
+func TestDisableEventChan(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithDisableEventChan(), WithDebounce(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	if w.EventChan != nil {
+		t.Fatal("EventChan should not be created")
+	}
+	sub := w.Subscribe(1)
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// 订阅缓冲只有1且从不读取，处理流程也不能被阻塞
+	for i := 0; i < 5; i++ {
+		_ = os.WriteFile(filepath.Join(root, fmt.Sprintf("f%d.txt", i)), []byte("x"), 0644)
+	}
+	waitFor(t, 2*time.Second, func() bool { return len(w.GetCurrentSnapshot().Files) == 6 })
+	if ev := <-sub.C; ev.NewSnap == nil {
+		t.Errorf("subscription should receive events: %+v", ev)
+	}
+	if h := w.Health(); h.Status != HealthHealthy {
+		t.Errorf("unexpected health %v: %v", h.Status, h.Reasons)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+}
+
+// BenchmarkHashFile 比较不同读缓冲大小下计算文件哈希的吞吐量
+//
+// buf=32KB 相当于此前 io.Copy 的默认缓冲；1GB 的用例在 -short 下跳过
+func BenchmarkHashFile(b *testing.B) {
+	sizes := []struct {
+		name string
+		n    int64
+	}{{"1MB", 1 << 20}, {"100MB", 100 << 20}, {"1GB", 1 << 30}}
+	for _, size := range sizes {
+		b.Run(size.name, func(b *testing.B) {
+			if size.n >= 1<<30 && testing.Short() {
+				b.Skip("skipping 1GB file in short mode")
+			}
+			path := filepath.Join(b.TempDir(), "bench.dat")
+			f, err := os.Create(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			chunk := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1MB
+			for written := int64(0); written < size.n; written += int64(len(chunk)) {
+				if _, err := f.Write(chunk); err != nil {
+					b.Fatal(err)
+				}
+			}
+			_ = f.Close()
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, _ = hashFile(tmpFile.Name())
+			for _, bufSize := range []int{32 << 10, DefaultHashBufferSize} {
+				b.Run(fmt.Sprintf("buf=%dKB", bufSize>>10), func(b *testing.B) {
+					pool := newBufferPool(bufSize)
+					b.SetBytes(size.n)
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						buf := pool.get()
+						if _, err := hashFile(osFS{}, path, sha256.New, *buf); err != nil {
+							b.Fatal(err)
+						}
+						pool.put(buf)
+					}
+				})
+			}
+		})
 	}
 }
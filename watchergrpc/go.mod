@@ -0,0 +1,20 @@
+module github.com/shuakami/watcher/watchergrpc
+
+go 1.21
+
+require (
+	github.com/shuakami/watcher v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.64.1
+	google.golang.org/protobuf v1.33.0
+)
+
+require (
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+)
+
+// 本地开发时使用仓库中的 watcher，发布时去掉并依赖已发布的版本
+replace github.com/shuakami/watcher => ../
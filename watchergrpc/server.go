@@ -0,0 +1,304 @@
+// Package watchergrpc 以 gRPC 服务(watcher.v1.WatcherService)暴露 Watcher 的快照与事件流
+//
+// 服务定义见 watcherpb/watcher.proto。使用方式：
+//
+//	s := grpc.NewServer()
+//	watchergrpc.Register(s, w)
+//	_ = s.Serve(lis)
+package watchergrpc
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchergrpc/watcherpb"
+)
+
+// 分页参数
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// Server 实现 watcherpb.WatcherServiceServer
+type Server struct {
+	watcherpb.UnimplementedWatcherServiceServer
+
+	w *watcher.Watcher
+}
+
+// NewServer 为给定的 Watcher 创建服务实现
+func NewServer(w *watcher.Watcher) *Server {
+	return &Server{w: w}
+}
+
+// Register 创建服务实现并注册到 gRPC Server
+func Register(s grpc.ServiceRegistrar, w *watcher.Watcher) *Server {
+	srv := NewServer(w)
+	watcherpb.RegisterWatcherServiceServer(s, srv)
+	return srv
+}
+
+// ListSnapshots 按创建时间分页列出快照，page_token 为下一页的起始偏移
+func (s *Server) ListSnapshots(_ context.Context, req *watcherpb.ListSnapshotsRequest) (*watcherpb.ListSnapshotsResponse, error) {
+	size := int(req.GetPageSize())
+	switch {
+	case size <= 0:
+		size = defaultPageSize
+	case size > maxPageSize:
+		size = maxPageSize
+	}
+	offset := 0
+	if tok := req.GetPageToken(); tok != "" {
+		var err error
+		if offset, err = strconv.Atoi(tok); err != nil || offset < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page token %q", tok)
+		}
+	}
+
+	all := s.w.ListAllSnapshots()
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].CreatedAt.Equal(all[j].CreatedAt) {
+			return all[i].CreatedAt.Before(all[j].CreatedAt)
+		}
+		return all[i].ID < all[j].ID
+	})
+
+	resp := &watcherpb.ListSnapshotsResponse{Total: int32(len(all))}
+	for i := offset; i < len(all) && i < offset+size; i++ {
+		resp.Snapshots = append(resp.Snapshots, toSnapshot(all[i], false))
+	}
+	if offset+size < len(all) {
+		resp.NextPageToken = strconv.Itoa(offset + size)
+	}
+	return resp, nil
+}
+
+// GetSnapshot 返回快照(含文件表)
+func (s *Server) GetSnapshot(_ context.Context, req *watcherpb.GetSnapshotRequest) (*watcherpb.Snapshot, error) {
+	var sn *watcher.SnapshotNode
+	if req.GetId() == "" {
+		sn = s.w.GetCurrentSnapshot()
+	} else {
+		sn = s.w.GetSnapshotByID(req.GetId())
+	}
+	if sn == nil {
+		return nil, status.Errorf(codes.NotFound, "snapshot %s not found", req.GetId())
+	}
+	return toSnapshot(sn, true), nil
+}
+
+// GetFiles 在快照中批量查找路径，snapshot_id 为空时使用当前快照，见 watcher.Watcher.GetFiles
+func (s *Server) GetFiles(_ context.Context, req *watcherpb.GetFilesRequest) (*watcherpb.GetFilesResponse, error) {
+	id := req.GetSnapshotId()
+	if id == "" {
+		cur := s.w.GetCurrentSnapshot()
+		if cur == nil {
+			return nil, status.Error(codes.NotFound, "no current snapshot")
+		}
+		id = cur.ID
+	}
+	found, missing, err := s.w.GetFiles(id, req.GetPaths())
+	if err != nil {
+		if errors.Is(err, watcher.ErrSnapshotNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &watcherpb.GetFilesResponse{
+		SnapshotId: id,
+		Found:      make(map[string]*watcherpb.FileMetadata, len(found)),
+		Missing:    missing,
+	}
+	for p, m := range found {
+		resp.Found[p] = toFileMetadata(m)
+	}
+	return resp, nil
+}
+
+// Diff 比较两个快照，to_id 为空时与当前快照比较，detect_renames 时内容相同的删除+新增文件报告在 renamed 中
+func (s *Server) Diff(_ context.Context, req *watcherpb.DiffRequest) (*watcherpb.DiffResponse, error) {
+	if req.GetFromId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "from_id is required")
+	}
+	to := req.GetToId()
+	if to == "" {
+		cur := s.w.GetCurrentSnapshot()
+		if cur == nil {
+			return nil, status.Error(codes.NotFound, "no current snapshot")
+		}
+		to = cur.ID
+	}
+	var opts []watcher.QueryOption
+	if req.GetDetectRenames() {
+		opts = append(opts, watcher.DetectRenames())
+	}
+	d, err := s.w.DiffSnapshots(req.GetFromId(), to, opts...)
+	if err != nil {
+		if errors.Is(err, watcher.ErrSnapshotNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &watcherpb.DiffResponse{
+		FromId:   d.FromID,
+		ToId:     d.ToID,
+		Added:    toDiffEntries(d.Added),
+		Removed:  toDiffEntries(d.Removed),
+		Modified: toDiffEntries(d.Modified),
+		Renamed:  toDiffEntries(d.Renamed),
+	}, nil
+}
+
+// errOverflow 表示 OVERFLOW_POLICY_CLOSE 下订阅缓冲溢出
+var errOverflow = errors.New("subscription buffer overflowed")
+
+// WatchEvents 通过订阅推送事件，客户端取消或 Watcher 停止时结束
+//
+// 每个流有独立的订阅；缓冲溢出时按请求中的 OverflowPolicy 处理
+func (s *Server) WatchEvents(req *watcherpb.WatchEventsRequest, stream watcherpb.WatcherService_WatchEventsServer) error {
+	sub := s.w.Subscribe(int(req.GetBuffer()))
+	defer sub.Close()
+
+	next := sub.StartSeq + 1
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case ev, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			var missed uint64
+			if ev.Seq > next {
+				missed = ev.Seq - next
+				if req.GetOverflow() == watcherpb.OverflowPolicy_OVERFLOW_POLICY_CLOSE {
+					return status.Errorf(codes.ResourceExhausted, "%v: missed %d events before seq %d", errOverflow, missed, ev.Seq)
+				}
+			}
+			next = ev.Seq + 1
+			if err := stream.Send(&watcherpb.FileEvent{
+				Seq:        ev.Seq,
+				Path:       ev.FilePath,
+				Op:         uint32(ev.Op),
+				OpName:     ev.Op.String(),
+				SnapshotId: ev.SnapshotID(),
+				Missed:     missed,
+				Kind:       uint32(ev.Kind),
+				KindName:   ev.Kind.String(),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toSnapshot 转换快照，withFiles 为 true 时包含按路径排序的文件表(不含墓碑)
+func toSnapshot(sn *watcher.SnapshotNode, withFiles bool) *watcherpb.Snapshot {
+	out := &watcherpb.Snapshot{
+		Id:          sn.ID,
+		ParentIds:   sn.ParentIDs,
+		CreatedAt:   timestamppb.New(sn.CreatedAt),
+		Description: sn.Description,
+		RootHash:    sn.RootHash,
+		FileCount:   int32(sn.FileCount()),
+	}
+	if withFiles {
+		paths := make([]string, 0, len(sn.Files))
+		for p, m := range sn.Files {
+			if !m.Deleted {
+				paths = append(paths, p)
+			}
+		}
+		sort.Strings(paths)
+		out.Files = make([]*watcherpb.FileMetadata, 0, len(paths))
+		for _, p := range paths {
+			out.Files = append(out.Files, toFileMetadata(sn.Files[p]))
+		}
+	}
+	return out
+}
+
+// toFileMetadata 转换文件元信息，nil 转换为 nil
+func toFileMetadata(m *watcher.FileMetadata) *watcherpb.FileMetadata {
+	if m == nil {
+		return nil
+	}
+	return &watcherpb.FileMetadata{
+		Path:          m.Path,
+		Size:          m.Size,
+		ModTime:       timestamppb.New(m.ModTime),
+		Hash:          m.Hash,
+		HashState:     toHashState(m.HashState),
+		IsDirectory:   m.IsDirectory,
+		CreatedAt:     timestamppb.New(m.CreatedAt),
+		BirthTime:     optionalTime(m.BirthTime),
+		AppendedBytes: m.AppendedBytes,
+	}
+}
+
+// toHashState 转换哈希状态，proto 中没有对应值的状态(更新的 watcher 版本新增)转换为 HASH_STATE_UNKNOWN
+func toHashState(s watcher.HashState) watcherpb.HashState {
+	switch s {
+	case watcher.HashStateHashed:
+		return watcherpb.HashState_HASH_STATE_HASHED
+	case watcher.HashStateSkippedSize:
+		return watcherpb.HashState_HASH_STATE_SKIPPED_SIZE
+	case watcher.HashStateSkippedType:
+		return watcherpb.HashState_HASH_STATE_SKIPPED_TYPE
+	case watcher.HashStateUnreadable:
+		return watcherpb.HashState_HASH_STATE_UNREADABLE
+	case watcher.HashStatePending:
+		return watcherpb.HashState_HASH_STATE_PENDING
+	case watcher.HashStateSkippedDegraded:
+		return watcherpb.HashState_HASH_STATE_SKIPPED_DEGRADED
+	case watcher.HashStateStale:
+		return watcherpb.HashState_HASH_STATE_STALE
+	}
+	return watcherpb.HashState_HASH_STATE_UNKNOWN
+}
+
+// toDiffEntries 转换差异条目
+func toDiffEntries(entries []watcher.DiffEntry) []*watcherpb.DiffEntry {
+	out := make([]*watcherpb.DiffEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, &watcherpb.DiffEntry{
+			Path: e.Path,
+			Kind: toDiffKind(e.Kind),
+			Old:  toFileMetadata(e.Old),
+			New:  toFileMetadata(e.New),
+			From: e.From,
+		})
+	}
+	return out
+}
+
+// toDiffKind 转换变化类型
+func toDiffKind(k watcher.DiffKind) watcherpb.DiffKind {
+	switch k {
+	case watcher.DiffRemoved:
+		return watcherpb.DiffKind_DIFF_KIND_REMOVED
+	case watcher.DiffModified:
+		return watcherpb.DiffKind_DIFF_KIND_MODIFIED
+	case watcher.DiffRenamed:
+		return watcherpb.DiffKind_DIFF_KIND_RENAMED
+	}
+	return watcherpb.DiffKind_DIFF_KIND_ADDED
+}
+
+// optionalTime 零值时间转换为 nil
+func optionalTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,243 @@
+package watchergrpc
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchergrpc/watcherpb"
+)
+
+// startServer 启动一个监控临时目录的 Watcher，并在 bufconn 上提供服务
+func startServer(t *testing.T) (*watcher.Watcher, watcherpb.WatcherServiceClient, string) {
+	t.Helper()
+	root := t.TempDir()
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{WatchPaths: []string{root}, Debounce: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	// 消费 EventChan，避免其填满后阻塞事件处理
+	go func() {
+		for range w.EventChan {
+		}
+	}()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	Register(s, w)
+	go func() { _ = s.Serve(lis) }()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		s.Stop()
+		w.Stop()
+	})
+	return w, watcherpb.NewWatcherServiceClient(conn), root
+}
+
+// TestEndToEnd 测试真实文件变更经 WatchEvents 送达，并可通过其它 RPC 查询
+func TestEndToEnd(t *testing.T) {
+	w, client, root := startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchEvents(ctx, &watcherpb.WatchEventsRequest{})
+	if err != nil {
+		t.Fatalf("WatchEvents failed: %v", err)
+	}
+	waitSubscribers(t, w, 1)
+	initial := w.GetCurrentSnapshot().ID
+
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("hello"), 0644)
+
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if ev.GetPath() != file || ev.GetSeq() == 0 || ev.GetSnapshotId() == "" || ev.GetMissed() != 0 ||
+		!watcher.EventOp(ev.GetKind()).IsCreate() || ev.GetKindName() != watcher.EventOp(ev.GetKind()).String() {
+		t.Errorf("unexpected event %v", ev)
+	}
+
+	sn, err := client.GetSnapshot(ctx, &watcherpb.GetSnapshotRequest{Id: ev.GetSnapshotId()})
+	if err != nil {
+		t.Fatalf("GetSnapshot failed: %v", err)
+	}
+	var found *watcherpb.FileMetadata
+	for _, f := range sn.GetFiles() {
+		if f.GetPath() == file {
+			found = f
+		}
+	}
+	if found == nil || found.GetSize() != 5 || found.GetHashState() != watcherpb.HashState_HASH_STATE_HASHED {
+		t.Errorf("snapshot file entry = %v", found)
+	}
+	if _, err := client.GetSnapshot(ctx, &watcherpb.GetSnapshotRequest{Id: "nope"}); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown snapshot: got %v; want NotFound", err)
+	}
+
+	files, err := client.GetFiles(ctx, &watcherpb.GetFilesRequest{Paths: []string{file, "/nope", file}})
+	if err != nil {
+		t.Fatalf("GetFiles failed: %v", err)
+	}
+	if len(files.GetFound()) != 1 || files.GetFound()[file].GetSize() != 5 || len(files.GetMissing()) != 1 || files.GetMissing()[0] != "/nope" {
+		t.Errorf("unexpected GetFiles response %v", files)
+	}
+	if _, err := client.GetFiles(ctx, &watcherpb.GetFilesRequest{SnapshotId: "nope"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetFiles on unknown snapshot: got %v; want NotFound", err)
+	}
+
+	d, err := client.Diff(ctx, &watcherpb.DiffRequest{FromId: initial})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(d.GetAdded()) == 0 || d.GetAdded()[0].GetNew() == nil {
+		t.Errorf("diff should report the added file: %v", d)
+	}
+
+	list, err := client.ListSnapshots(ctx, &watcherpb.ListSnapshotsRequest{PageSize: 1})
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+	if len(list.GetSnapshots()) != 1 || list.GetSnapshots()[0].GetId() != initial || list.GetNextPageToken() == "" {
+		t.Errorf("unexpected first page %v", list)
+	}
+	if len(list.GetSnapshots()[0].GetFiles()) != 0 {
+		t.Error("listed snapshots should not include files")
+	}
+
+	// 客户端取消后订阅应被清理
+	cancel()
+	waitSubscribers(t, w, 0)
+}
+
+// TestOverflowClose 测试 OVERFLOW_POLICY_CLOSE 下溢出时以 RESOURCE_EXHAUSTED 结束流
+func TestOverflowClose(t *testing.T) {
+	w, client, root := startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchEvents(ctx, &watcherpb.WatchEventsRequest{Buffer: 1, Overflow: watcherpb.OverflowPolicy_OVERFLOW_POLICY_CLOSE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitSubscribers(t, w, 1)
+
+	// 在未读取的情况下制造多个事件，缓冲为1必然溢出
+	for i := 0; i < 20; i++ {
+		p := filepath.Join(root, "f"+string(rune('a'+i)))
+		_ = os.WriteFile(p, []byte("x"), 0644)
+		_, _, _ = w.RehashFile(p)
+	}
+	for {
+		_, err := stream.Recv()
+		if err == nil {
+			continue
+		}
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("stream ended with %v; want ResourceExhausted", err)
+		}
+		return
+	}
+}
+
+// waitSubscribers 等待订阅数达到 n
+func waitSubscribers(t *testing.T, w *watcher.Watcher, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for w.Stats().Subscribers != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscribers = %d; want %d", w.Stats().Subscribers, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitFile 等待当前快照中 path 的存在与否(墓碑视为不存在)为 exists，返回该快照ID
+func waitFile(t *testing.T, w *watcher.Watcher, path string, exists bool) string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sn := w.GetCurrentSnapshot()
+		if m := sn.Files[path]; (m != nil && !m.Deleted) == exists {
+			return sn.ID
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s exists = %v; want %v", path, !exists, exists)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestDiffRenames 测试 detect_renames 时内容相同的删除+新增文件以 DIFF_KIND_RENAMED 报告，否则仍为删除+新增
+func TestDiffRenames(t *testing.T) {
+	w, client, root := startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	from, to := filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt")
+	_ = os.WriteFile(from, []byte("hello"), 0644)
+	before := waitFile(t, w, from, true)
+	// 复制后删除：旧路径产生 Remove 事件(rename 的旧路径不会从文件表中移除)
+	_ = os.WriteFile(to, []byte("hello"), 0644)
+	_ = os.Remove(from)
+	waitFile(t, w, to, true)
+	waitFile(t, w, from, false)
+
+	d, err := client.Diff(ctx, &watcherpb.DiffRequest{FromId: before, DetectRenames: true})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if r := d.GetRenamed(); len(r) != 1 || r[0].GetKind() != watcherpb.DiffKind_DIFF_KIND_RENAMED ||
+		r[0].GetPath() != to || r[0].GetFrom() != from || len(d.GetAdded())+len(d.GetRemoved()) != 0 {
+		t.Errorf("diff with renames = %v", d)
+	}
+
+	d, err = client.Diff(ctx, &watcherpb.DiffRequest{FromId: before})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(d.GetRenamed()) != 0 || len(d.GetAdded()) != 1 || len(d.GetRemoved()) != 1 {
+		t.Errorf("diff without renames = %v", d)
+	}
+}
+
+// TestToHashState 测试每个哈希状态都映射到同名的 proto 值，未知的状态映射为 HASH_STATE_UNKNOWN
+func TestToHashState(t *testing.T) {
+	cases := map[watcher.HashState]watcherpb.HashState{
+		watcher.HashStateUnknown:         watcherpb.HashState_HASH_STATE_UNKNOWN,
+		watcher.HashStateHashed:          watcherpb.HashState_HASH_STATE_HASHED,
+		watcher.HashStateSkippedSize:     watcherpb.HashState_HASH_STATE_SKIPPED_SIZE,
+		watcher.HashStateSkippedType:     watcherpb.HashState_HASH_STATE_SKIPPED_TYPE,
+		watcher.HashStateUnreadable:      watcherpb.HashState_HASH_STATE_UNREADABLE,
+		watcher.HashStatePending:         watcherpb.HashState_HASH_STATE_PENDING,
+		watcher.HashStateSkippedDegraded: watcherpb.HashState_HASH_STATE_SKIPPED_DEGRADED,
+		watcher.HashStateStale:           watcherpb.HashState_HASH_STATE_STALE,
+		watcher.HashState(99):            watcherpb.HashState_HASH_STATE_UNKNOWN,
+	}
+	for in, want := range cases {
+		if got := toHashState(in); got != want {
+			t.Errorf("toHashState(%v) = %v; want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+// Package watcherpb 包含 watcher.proto 生成的 protobuf 消息与 gRPC 桩代码
+//
+// 服务实现见上级包 watchergrpc；其它语言的客户端可直接使用 watcher.proto 生成
+package watcherpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative watcher.proto
@@ -0,0 +1,302 @@
+// watcher.proto 定义远程访问快照与订阅事件的 gRPC 服务
+//
+// 重新生成(需要 protoc、protoc-gen-go、protoc-gen-go-grpc)：
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	       --go-grpc_out=. --go-grpc_opt=paths=source_relative watcher.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.3
+// source: watcher.proto
+
+package watcherpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	WatcherService_ListSnapshots_FullMethodName = "/watcher.v1.WatcherService/ListSnapshots"
+	WatcherService_GetSnapshot_FullMethodName   = "/watcher.v1.WatcherService/GetSnapshot"
+	WatcherService_GetFiles_FullMethodName      = "/watcher.v1.WatcherService/GetFiles"
+	WatcherService_Diff_FullMethodName          = "/watcher.v1.WatcherService/Diff"
+	WatcherService_WatchEvents_FullMethodName   = "/watcher.v1.WatcherService/WatchEvents"
+)
+
+// WatcherServiceClient is the client API for WatcherService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WatcherServiceClient interface {
+	// ListSnapshots 按创建时间分页列出快照(不含文件表)
+	ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error)
+	// GetSnapshot 获取快照(含文件表)，id 为空时返回当前快照
+	GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	// GetFiles 在快照中批量查找路径，snapshot_id 为空时使用当前快照
+	GetFiles(ctx context.Context, in *GetFilesRequest, opts ...grpc.CallOption) (*GetFilesResponse, error)
+	// Diff 比较两个快照
+	Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (*DiffResponse, error)
+	// WatchEvents 持续推送文件变更事件，直到客户端取消或 Watcher 停止
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (WatcherService_WatchEventsClient, error)
+}
+
+type watcherServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWatcherServiceClient(cc grpc.ClientConnInterface) WatcherServiceClient {
+	return &watcherServiceClient{cc}
+}
+
+func (c *watcherServiceClient) ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error) {
+	out := new(ListSnapshotsResponse)
+	err := c.cc.Invoke(ctx, WatcherService_ListSnapshots_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *watcherServiceClient) GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	out := new(Snapshot)
+	err := c.cc.Invoke(ctx, WatcherService_GetSnapshot_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *watcherServiceClient) GetFiles(ctx context.Context, in *GetFilesRequest, opts ...grpc.CallOption) (*GetFilesResponse, error) {
+	out := new(GetFilesResponse)
+	err := c.cc.Invoke(ctx, WatcherService_GetFiles_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *watcherServiceClient) Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (*DiffResponse, error) {
+	out := new(DiffResponse)
+	err := c.cc.Invoke(ctx, WatcherService_Diff_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *watcherServiceClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (WatcherService_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WatcherService_ServiceDesc.Streams[0], WatcherService_WatchEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &watcherServiceWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WatcherService_WatchEventsClient interface {
+	Recv() (*FileEvent, error)
+	grpc.ClientStream
+}
+
+type watcherServiceWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *watcherServiceWatchEventsClient) Recv() (*FileEvent, error) {
+	m := new(FileEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WatcherServiceServer is the server API for WatcherService service.
+// All implementations must embed UnimplementedWatcherServiceServer
+// for forward compatibility
+type WatcherServiceServer interface {
+	// ListSnapshots 按创建时间分页列出快照(不含文件表)
+	ListSnapshots(context.Context, *ListSnapshotsRequest) (*ListSnapshotsResponse, error)
+	// GetSnapshot 获取快照(含文件表)，id 为空时返回当前快照
+	GetSnapshot(context.Context, *GetSnapshotRequest) (*Snapshot, error)
+	// GetFiles 在快照中批量查找路径，snapshot_id 为空时使用当前快照
+	GetFiles(context.Context, *GetFilesRequest) (*GetFilesResponse, error)
+	// Diff 比较两个快照
+	Diff(context.Context, *DiffRequest) (*DiffResponse, error)
+	// WatchEvents 持续推送文件变更事件，直到客户端取消或 Watcher 停止
+	WatchEvents(*WatchEventsRequest, WatcherService_WatchEventsServer) error
+	mustEmbedUnimplementedWatcherServiceServer()
+}
+
+// UnimplementedWatcherServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedWatcherServiceServer struct {
+}
+
+func (UnimplementedWatcherServiceServer) ListSnapshots(context.Context, *ListSnapshotsRequest) (*ListSnapshotsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSnapshots not implemented")
+}
+func (UnimplementedWatcherServiceServer) GetSnapshot(context.Context, *GetSnapshotRequest) (*Snapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSnapshot not implemented")
+}
+func (UnimplementedWatcherServiceServer) GetFiles(context.Context, *GetFilesRequest) (*GetFilesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFiles not implemented")
+}
+func (UnimplementedWatcherServiceServer) Diff(context.Context, *DiffRequest) (*DiffResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Diff not implemented")
+}
+func (UnimplementedWatcherServiceServer) WatchEvents(*WatchEventsRequest, WatcherService_WatchEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedWatcherServiceServer) mustEmbedUnimplementedWatcherServiceServer() {}
+
+// UnsafeWatcherServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WatcherServiceServer will
+// result in compilation errors.
+type UnsafeWatcherServiceServer interface {
+	mustEmbedUnimplementedWatcherServiceServer()
+}
+
+func RegisterWatcherServiceServer(s grpc.ServiceRegistrar, srv WatcherServiceServer) {
+	s.RegisterService(&WatcherService_ServiceDesc, srv)
+}
+
+func _WatcherService_ListSnapshots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSnapshotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WatcherServiceServer).ListSnapshots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WatcherService_ListSnapshots_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WatcherServiceServer).ListSnapshots(ctx, req.(*ListSnapshotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WatcherService_GetSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WatcherServiceServer).GetSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WatcherService_GetSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WatcherServiceServer).GetSnapshot(ctx, req.(*GetSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WatcherService_GetFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WatcherServiceServer).GetFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WatcherService_GetFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WatcherServiceServer).GetFiles(ctx, req.(*GetFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WatcherService_Diff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WatcherServiceServer).Diff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WatcherService_Diff_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WatcherServiceServer).Diff(ctx, req.(*DiffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WatcherService_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WatcherServiceServer).WatchEvents(m, &watcherServiceWatchEventsServer{stream})
+}
+
+type WatcherService_WatchEventsServer interface {
+	Send(*FileEvent) error
+	grpc.ServerStream
+}
+
+type watcherServiceWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *watcherServiceWatchEventsServer) Send(m *FileEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// WatcherService_ServiceDesc is the grpc.ServiceDesc for WatcherService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WatcherService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "watcher.v1.WatcherService",
+	HandlerType: (*WatcherServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSnapshots",
+			Handler:    _WatcherService_ListSnapshots_Handler,
+		},
+		{
+			MethodName: "GetSnapshot",
+			Handler:    _WatcherService_GetSnapshot_Handler,
+		},
+		{
+			MethodName: "GetFiles",
+			Handler:    _WatcherService_GetFiles_Handler,
+		},
+		{
+			MethodName: "Diff",
+			Handler:    _WatcherService_Diff_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _WatcherService_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "watcher.proto",
+}
@@ -0,0 +1,139 @@
+package watcherhttp
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/shuakami/watcher"
+)
+
+// diffFlushEvery 是流式输出差异时每写出多少个条目刷新一次
+const diffFlushEvery = 256
+
+// diffStream 以分块传输流式写出 /diff 的响应，JSON 结构与 watcher.SnapshotDiff 相同
+//
+// 差异经 Watcher.StreamDiff 逐条取得，Added、Removed、Modified 各遍历一次快照，内存占用与差异数量无关；
+// 响应头在第一个条目(或第一次遍历结束)时才写出，快照不存在等错误仍能以正常的错误响应返回
+type diffStream struct {
+	rw       http.ResponseWriter
+	r        *http.Request
+	from, to string
+	cache    string // Cache-Control
+	out      io.Writer
+	gz       *gzip.Writer
+	started  bool
+	n        int // 当前列表中已写出的条目数
+	unsynced int // 上次刷新后写出的条目数
+}
+
+// streamDiff 流式输出 from 到 to 的差异(不识别重命名)
+func (h *Handler) streamDiff(rw http.ResponseWriter, r *http.Request, from, to, cache string, opts []watcher.QueryOption) {
+	s := &diffStream{rw: rw, r: r, from: from, to: to, cache: cache}
+	lists := []struct {
+		name string
+		kind watcher.DiffKind
+	}{{"Added", watcher.DiffAdded}, {"Removed", watcher.DiffRemoved}, {"Modified", watcher.DiffModified}}
+	for i, list := range lists {
+		err := h.w.StreamDiff(from, to, func(e watcher.DiffEntry) error {
+			if e.Kind != list.kind {
+				return nil
+			}
+			if err := s.begin(); err != nil {
+				return err
+			}
+			return s.entry(e)
+		}, opts...)
+		if err != nil {
+			if !s.started {
+				h.writeError(rw, r, errorStatus(err), err.Error())
+			}
+			// 已经开始输出：只能中断响应，客户端会看到不完整的 JSON
+			return
+		}
+		if s.begin() != nil || s.closeList(list.name, i == len(lists)-1) != nil {
+			return
+		}
+	}
+	if s.gz != nil {
+		_ = s.gz.Close()
+	}
+}
+
+// begin 在第一次输出前写出响应头与差异的开头
+func (s *diffStream) begin() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	hdr := s.rw.Header()
+	hdr.Set("Content-Type", "application/json")
+	hdr.Set("Cache-Control", s.cache)
+	hdr.Add("Vary", "Accept-Encoding")
+	gzipped := acceptsGzip(s.r)
+	if gzipped {
+		hdr.Set("Content-Encoding", "gzip")
+	}
+	s.rw.WriteHeader(http.StatusOK)
+	switch {
+	case s.r.Method == http.MethodHead:
+		s.out = io.Discard
+	case gzipped:
+		s.gz = gzip.NewWriter(s.rw)
+		s.out = s.gz
+	default:
+		s.out = s.rw
+	}
+	ids, _ := json.Marshal(struct{ FromID, ToID string }{s.from, s.to})
+	_, err := s.out.Write(append(ids[:len(ids)-1], `,"Added":[`...))
+	return err
+}
+
+// entry 写出列表中的一个条目
+func (s *diffStream) entry(e watcher.DiffEntry) error {
+	d := watcher.SnapshotDiff{Modified: []watcher.DiffEntry{e}}
+	data, err := json.Marshal(d.SlashPaths().Modified[0])
+	if err != nil {
+		return err
+	}
+	if s.n > 0 {
+		data = append([]byte{','}, data...)
+	}
+	s.n++
+	if _, err := s.out.Write(data); err != nil {
+		return err
+	}
+	if s.unsynced++; s.unsynced >= diffFlushEvery {
+		s.flush()
+	}
+	return nil
+}
+
+// closeList 结束当前列表并开始下一个，last 时结束整个对象
+func (s *diffStream) closeList(list string, last bool) error {
+	s.n = 0
+	tail := `]`
+	switch {
+	case last:
+		tail += `,"Renamed":null}`
+	case list == "Added":
+		tail += `,"Removed":[`
+	default:
+		tail += `,"Modified":[`
+	}
+	_, err := s.out.Write([]byte(tail))
+	s.flush()
+	return err
+}
+
+// flush 把已写出的内容推送给客户端
+func (s *diffStream) flush() {
+	s.unsynced = 0
+	if s.gz != nil {
+		_ = s.gz.Flush()
+	}
+	if f, ok := s.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,41 @@
+package watcherhttp_test
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watcherhttp"
+)
+
+// Example 展示如何把 Handler 挂载到已有服务的子路径下
+func Example() {
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{WatchPaths: []string{"/srv/data"}})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/watcher/", http.StripPrefix("/debug/watcher", watcherhttp.NewHandler(w, watcherhttp.Options{})))
+	// log.Fatal(http.ListenAndServe(":8080", mux))
+}
+
+// ExampleSSEHandler 展示如何单独挂载事件流，并提供 testdata/events.html 作为演示页面
+func ExampleSSEHandler() {
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{WatchPaths: []string{"/srv/data"}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		log.Fatal(err)
+	}
+	defer w.Stop()
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/watcher/events", watcherhttp.NewSSEHandler(w, watcherhttp.SSEOptions{Heartbeat: 10 * time.Second}))
+	mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+		http.ServeFile(rw, r, "testdata/events.html")
+	})
+	// log.Fatal(http.ListenAndServe(":8080", mux))
+}
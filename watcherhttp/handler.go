@@ -0,0 +1,628 @@
+// Package watcherhttp 提供一个可嵌入的 http.Handler，以 JSON 形式只读地暴露快照、差异与统计
+//
+// 路由(相对于挂载点，挂载到子路径时请配合 http.StripPrefix 使用)：
+//
+//	GET  /snapshots?offset=0&limit=50   快照列表(按创建时间排序，不含文件表)
+//	GET  /snapshots/current             当前快照
+//	GET  /snapshots/{id}                指定快照
+//	POST /snapshots/{id}/files          body {"paths": [...], "include_deleted": false}，批量查找路径(GetFiles，id 可为 current)
+//	GET  /snapshots/{id}/sizes?top=10   文件大小分布、总量与最大的 top 个文件(SizeReport，id 可为 current)
+//	GET  /diff?from={id}&to={id}        两个快照的差异(可附加多个 root={监控根} 过滤，renames=1 时识别移动/重命名，deleted=1 时附带墓碑，
+//	                                    compare=HashOnly|HashAndSize|Full 设置比较方式(见 watcher.CompareBy，默认 Full)；
+//	                                    不识别重命名时以分块传输流式输出，内存占用与差异数量无关)
+//	GET  /drift?tag={tag}               当前快照相对标签的偏离汇总(DriftReport，可附加多个 root={监控根} 过滤)
+//	GET  /sizes?from={id}&to={id}&top=10 两个快照之间的大小变化与变大最多的 top 个文件(SizeGrowth，to 缺省为当前快照)
+//	GET  /history?path={path}           路径在当前分支上的历史
+//	GET  /tags                          全部标签
+//	GET  /stats                         Watcher.Stats()
+//	GET  /events                        Server-Sent Events 实时事件流(见 SSEHandler)
+//
+// Options.EnableMutations 为 true 时额外开放：
+//
+//	PUT    /tags/{tag}                  body {"snapshot_id": "..."}，打标签/移动标签
+//	DELETE /tags/{tag}                  删除标签
+//	PUT    /snapshots/{id}/description  body {"description": "..."}，修改快照描述
+//	DELETE /stats/latency               清空延迟分布(Watcher.ResetLatencyStats)
+//
+// 快照一经发布即不可变，按ID获取的快照带有长期缓存头；较大的响应在客户端支持时使用 gzip 压缩。
+// 响应中的路径总是以 '/' 分隔(watcher.SlashPath)，path 参数两种分隔形式都可以
+package watcherhttp
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shuakami/watcher"
+)
+
+// 默认值
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+
+	// DefaultSizeTop 是大小报告默认列出的文件数，top 最大为 MaxPageSize
+	DefaultSizeTop = 10
+
+	// gzipThreshold 以下的响应不压缩
+	gzipThreshold = 1024
+)
+
+// Options 配置 Handler
+//
+// EnableMutations：开放标签与描述的修改接口(默认只读)
+// PageSize：快照列表的默认分页大小, 默认 50
+// SSE：/events 的配置
+type Options struct {
+	EnableMutations bool
+	PageSize        int
+	SSE             SSEOptions
+}
+
+// Handler 实现 http.Handler
+type Handler struct {
+	w    *watcher.Watcher
+	opts Options
+	sse  *SSEHandler
+}
+
+// NewHandler 为给定的 Watcher 创建 Handler
+func NewHandler(w *watcher.Watcher, opts Options) *Handler {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	return &Handler{w: w, opts: opts, sse: NewSSEHandler(w, opts.SSE)}
+}
+
+// SnapshotSummary 是快照列表中的条目(不含文件表)
+type SnapshotSummary struct {
+	ID          string    `json:"id"`
+	ParentIDs   []string  `json:"parent_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	Description string    `json:"description"`
+	RootHash    string    `json:"root_hash"`
+	FileCount   int       `json:"file_count"`
+	Tags        []string  `json:"tags,omitempty"`
+
+	ChangedPaths []string `json:"changed_paths,omitempty"`
+	Truncated    bool     `json:"changed_paths_truncated,omitempty"`
+
+	ConfigFingerprint string `json:"config_fingerprint,omitempty"` // 创建时有效配置的指纹，完整记录见快照的 Config
+}
+
+// FilesResult 是批量查找路径的结果，见 watcher.Watcher.GetFiles
+type FilesResult struct {
+	SnapshotID string                           `json:"snapshot_id"`
+	Found      map[string]*watcher.FileMetadata `json:"found"`   // 以请求中的路径为键
+	Missing    []string                         `json:"missing"` // 按在请求中首次出现的顺序
+}
+
+// SnapshotPage 是快照列表的一页
+type SnapshotPage struct {
+	Total  int               `json:"total"`
+	Offset int               `json:"offset"`
+	Limit  int               `json:"limit"`
+	Items  []SnapshotSummary `json:"items"`
+}
+
+// errorBody 是错误响应的结构
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// ServeHTTP 实现 http.Handler
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "snapshots":
+		h.get(rw, r, h.listSnapshots)
+	case len(parts) == 2 && parts[0] == "snapshots" && parts[1] == "current":
+		h.get(rw, r, h.currentSnapshot)
+	case len(parts) == 2 && parts[0] == "snapshots":
+		h.get(rw, r, func(rw http.ResponseWriter, r *http.Request) { h.snapshotByID(rw, r, parts[1]) })
+	case len(parts) == 3 && parts[0] == "snapshots" && parts[2] == "files":
+		h.post(rw, r, func(rw http.ResponseWriter, r *http.Request) { h.getFiles(rw, r, parts[1]) })
+	case len(parts) == 3 && parts[0] == "snapshots" && parts[2] == "sizes":
+		h.get(rw, r, func(rw http.ResponseWriter, r *http.Request) { h.sizes(rw, r, parts[1]) })
+	case len(parts) == 3 && parts[0] == "snapshots" && parts[2] == "description":
+		h.mutate(rw, r, []string{http.MethodPut}, func(rw http.ResponseWriter, r *http.Request) { h.describe(rw, r, parts[1]) })
+	case len(parts) == 1 && parts[0] == "diff":
+		h.get(rw, r, h.diff)
+	case len(parts) == 1 && parts[0] == "drift":
+		h.get(rw, r, h.drift)
+	case len(parts) == 1 && parts[0] == "sizes":
+		h.get(rw, r, h.sizeGrowth)
+	case len(parts) == 1 && parts[0] == "history":
+		h.get(rw, r, h.history)
+	case len(parts) == 1 && parts[0] == "tags":
+		h.get(rw, r, func(rw http.ResponseWriter, r *http.Request) { h.writeJSON(rw, r, http.StatusOK, h.w.Tags()) })
+	case len(parts) == 2 && parts[0] == "tags":
+		h.mutate(rw, r, []string{http.MethodPut, http.MethodDelete}, func(rw http.ResponseWriter, r *http.Request) { h.tag(rw, r, parts[1]) })
+	case len(parts) == 1 && parts[0] == "events":
+		h.sse.ServeHTTP(rw, r)
+	case len(parts) == 1 && parts[0] == "stats":
+		h.get(rw, r, func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Cache-Control", "no-store")
+			h.writeJSON(rw, r, http.StatusOK, h.w.Stats())
+		})
+	case len(parts) == 2 && parts[0] == "stats" && parts[1] == "latency":
+		h.mutate(rw, r, []string{http.MethodDelete}, func(rw http.ResponseWriter, r *http.Request) {
+			h.w.ResetLatencyStats()
+			rw.WriteHeader(http.StatusNoContent)
+		})
+	default:
+		h.writeError(rw, r, http.StatusNotFound, "not found")
+	}
+}
+
+// get 只允许 GET/HEAD
+func (h *Handler) get(rw http.ResponseWriter, r *http.Request, fn http.HandlerFunc) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		rw.Header().Set("Allow", "GET, HEAD")
+		h.writeError(rw, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	fn(rw, r)
+}
+
+// post 只允许 POST，用于请求体较大的只读查询
+func (h *Handler) post(rw http.ResponseWriter, r *http.Request, fn http.HandlerFunc) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", "POST")
+		h.writeError(rw, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	fn(rw, r)
+}
+
+// mutate 只在 EnableMutations 时允许给定的方法
+func (h *Handler) mutate(rw http.ResponseWriter, r *http.Request, methods []string, fn http.HandlerFunc) {
+	if !h.opts.EnableMutations {
+		h.writeError(rw, r, http.StatusForbidden, "mutations are disabled")
+		return
+	}
+	for _, m := range methods {
+		if r.Method == m {
+			fn(rw, r)
+			return
+		}
+	}
+	rw.Header().Set("Allow", strings.Join(methods, ", "))
+	h.writeError(rw, r, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// listSnapshots 分页列出快照
+func (h *Handler) listSnapshots(rw http.ResponseWriter, r *http.Request) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		h.writeError(rw, r, http.StatusBadRequest, "invalid offset")
+		return
+	}
+	limit, err := queryInt(r, "limit", h.opts.PageSize)
+	if err != nil || limit <= 0 {
+		h.writeError(rw, r, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	all := h.w.ListAllSnapshots()
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].CreatedAt.Equal(all[j].CreatedAt) {
+			return all[i].CreatedAt.Before(all[j].CreatedAt)
+		}
+		return all[i].ID < all[j].ID
+	})
+	tags := make(map[string][]string)
+	for tag, id := range h.w.Tags() {
+		tags[id] = append(tags[id], tag)
+	}
+
+	page := SnapshotPage{Total: len(all), Offset: offset, Limit: limit, Items: []SnapshotSummary{}}
+	for i := offset; i < len(all) && i < offset+limit; i++ {
+		sn := all[i]
+		sort.Strings(tags[sn.ID])
+		page.Items = append(page.Items, SnapshotSummary{
+			ID:          sn.ID,
+			ParentIDs:   sn.ParentIDs,
+			CreatedAt:   sn.CreatedAt,
+			Description: sn.Description,
+			RootHash:    sn.RootHash,
+			FileCount:   sn.FileCount(),
+			Tags:        tags[sn.ID],
+
+			ChangedPaths: sn.ChangedPaths,
+			Truncated:    sn.Truncated,
+
+			ConfigFingerprint: fingerprint(sn.Config),
+		})
+	}
+	rw.Header().Set("Cache-Control", "no-cache")
+	h.writeJSON(rw, r, http.StatusOK, page)
+}
+
+// currentSnapshot 返回当前快照，HEAD 会移动，因此不缓存
+//
+// Watcher 不维护当前状态(DisableCurrentState)时返回 404
+func (h *Handler) currentSnapshot(rw http.ResponseWriter, r *http.Request) {
+	sn := h.w.GetCurrentSnapshot()
+	if sn == nil {
+		h.writeError(rw, r, http.StatusNotFound, "no current snapshot")
+		return
+	}
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("ETag", etag(sn))
+	h.writeJSON(rw, r, http.StatusOK, sn.SlashPaths())
+}
+
+// snapshotByID 返回指定快照
+//
+// 文件表不可变；描述可通过修改接口变更，因此开启修改时改为按 ETag 协商缓存
+func (h *Handler) snapshotByID(rw http.ResponseWriter, r *http.Request, id string) {
+	sn := h.w.GetSnapshotByID(id)
+	if sn == nil {
+		h.writeError(rw, r, http.StatusNotFound, "snapshot "+id+" not found")
+		return
+	}
+	tag := etag(sn)
+	rw.Header().Set("ETag", tag)
+	if h.opts.EnableMutations {
+		rw.Header().Set("Cache-Control", "no-cache")
+	} else {
+		rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	if r.Header.Get("If-None-Match") == tag {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.writeJSON(rw, r, http.StatusOK, sn.SlashPaths())
+}
+
+// getFiles 在快照中批量查找请求体中的路径，id 为 current 时使用当前快照
+func (h *Handler) getFiles(rw http.ResponseWriter, r *http.Request, id string) {
+	var body struct {
+		Paths          []string `json:"paths"`
+		IncludeDeleted bool     `json:"include_deleted"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.writeError(rw, r, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	if id == "current" {
+		cur := h.w.GetCurrentSnapshot()
+		if cur == nil {
+			h.writeError(rw, r, http.StatusNotFound, "no current snapshot")
+			return
+		}
+		id = cur.ID
+	}
+	var opts []watcher.QueryOption
+	if body.IncludeDeleted {
+		opts = append(opts, watcher.IncludeDeleted())
+	}
+	found, missing, err := h.w.GetFiles(id, body.Paths, opts...)
+	if err != nil {
+		h.writeError(rw, r, errorStatus(err), err.Error())
+		return
+	}
+	for _, m := range found {
+		m.Path = watcher.SlashPath(m.Path)
+	}
+	if missing == nil {
+		missing = []string{}
+	}
+	rw.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(rw, r, http.StatusOK, FilesResult{SnapshotID: id, Found: found, Missing: missing})
+}
+
+// diff 比较两个快照；to 缺省为当前快照，可重复的 root 参数按监控根过滤，renames 非空时识别移动/重命名，deleted 非空时附带墓碑，
+// compare 为比较方式的名称(不区分大小写)，无法识别时返回 400
+//
+// renames 为空时以分块传输流式输出(StreamDiff)，响应没有 Content-Length
+func (h *Handler) diff(rw http.ResponseWriter, r *http.Request) {
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	if from == "" {
+		h.writeError(rw, r, http.StatusBadRequest, "missing from")
+		return
+	}
+	immutable := to != ""
+	if to == "" {
+		cur := h.w.GetCurrentSnapshot()
+		if cur == nil {
+			h.writeError(rw, r, http.StatusNotFound, "no current snapshot")
+			return
+		}
+		to = cur.ID
+	}
+	var opts []watcher.QueryOption
+	if roots := r.URL.Query()["root"]; len(roots) > 0 {
+		opts = append(opts, watcher.InRoots(roots...))
+	}
+	if r.URL.Query().Get("renames") != "" {
+		opts = append(opts, watcher.DetectRenames())
+	}
+	if r.URL.Query().Get("deleted") != "" {
+		opts = append(opts, watcher.IncludeDeleted())
+	}
+	if v := r.URL.Query().Get("compare"); v != "" {
+		mode, ok := parseCompare(v)
+		if !ok {
+			h.writeError(rw, r, http.StatusBadRequest, "unknown compare mode "+strconv.Quote(v))
+			return
+		}
+		opts = append(opts, watcher.CompareBy(mode))
+	}
+	cache := "no-cache"
+	if immutable {
+		cache = "public, max-age=31536000, immutable"
+	}
+	if r.URL.Query().Get("renames") == "" {
+		// 不识别重命名时逐条流式输出，差异再多也不会在内存中汇总(见 diffStream)
+		h.streamDiff(rw, r, from, to, cache, opts)
+		return
+	}
+	d, err := h.w.DiffSnapshots(from, to, opts...)
+	if err != nil {
+		h.writeError(rw, r, errorStatus(err), err.Error())
+		return
+	}
+	rw.Header().Set("Cache-Control", cache)
+	h.writeJSON(rw, r, http.StatusOK, d.SlashPaths())
+}
+
+// parseCompare 按名称(见 watcher.CompareMode.String，不区分大小写)查找比较方式
+func parseCompare(v string) (watcher.CompareMode, bool) {
+	for m := watcher.CompareFull; m <= watcher.CompareHashOnly; m++ {
+		if strings.EqualFold(v, m.String()) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// drift 返回当前快照相对标签的偏离汇总
+func (h *Handler) drift(rw http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		h.writeError(rw, r, http.StatusBadRequest, "missing tag")
+		return
+	}
+	var opts []watcher.QueryOption
+	if roots := r.URL.Query()["root"]; len(roots) > 0 {
+		opts = append(opts, watcher.InRoots(roots...))
+	}
+	rep, err := h.w.DriftFromTag(tag, opts...)
+	if err != nil {
+		h.writeError(rw, r, errorStatus(err), err.Error())
+		return
+	}
+	for i := range rep.Largest {
+		rep.Largest[i].Path = watcher.SlashPath(rep.Largest[i].Path)
+	}
+	rw.Header().Set("Cache-Control", "no-cache")
+	h.writeJSON(rw, r, http.StatusOK, rep)
+}
+
+// sizes 返回快照的大小报告，id 为 current 时使用当前快照(不缓存)
+func (h *Handler) sizes(rw http.ResponseWriter, r *http.Request, id string) {
+	top, ok := parseTop(r)
+	if !ok {
+		h.writeError(rw, r, http.StatusBadRequest, "invalid top")
+		return
+	}
+	cache := "public, max-age=31536000, immutable"
+	if id == "current" {
+		cur := h.w.GetCurrentSnapshot()
+		if cur == nil {
+			h.writeError(rw, r, http.StatusNotFound, "no current snapshot")
+			return
+		}
+		id, cache = cur.ID, "no-cache"
+	}
+	rep, err := h.w.SizeReport(id, top)
+	if err != nil {
+		h.writeError(rw, r, errorStatus(err), err.Error())
+		return
+	}
+	for i := range rep.Largest {
+		rep.Largest[i].Path = watcher.SlashPath(rep.Largest[i].Path)
+	}
+	rw.Header().Set("Cache-Control", cache)
+	h.writeJSON(rw, r, http.StatusOK, rep)
+}
+
+// sizeGrowth 比较两个快照的文件大小；to 缺省为当前快照
+func (h *Handler) sizeGrowth(rw http.ResponseWriter, r *http.Request) {
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	if from == "" {
+		h.writeError(rw, r, http.StatusBadRequest, "missing from")
+		return
+	}
+	top, ok := parseTop(r)
+	if !ok {
+		h.writeError(rw, r, http.StatusBadRequest, "invalid top")
+		return
+	}
+	cache := "public, max-age=31536000, immutable"
+	if to == "" {
+		cur := h.w.GetCurrentSnapshot()
+		if cur == nil {
+			h.writeError(rw, r, http.StatusNotFound, "no current snapshot")
+			return
+		}
+		to, cache = cur.ID, "no-cache"
+	}
+	rep, err := h.w.SizeGrowth(from, to, top)
+	if err != nil {
+		h.writeError(rw, r, errorStatus(err), err.Error())
+		return
+	}
+	for i := range rep.Growth {
+		rep.Growth[i].Path = watcher.SlashPath(rep.Growth[i].Path)
+	}
+	rw.Header().Set("Cache-Control", cache)
+	h.writeJSON(rw, r, http.StatusOK, rep)
+}
+
+// parseTop 解析 top 参数：缺省为 DefaultSizeTop，不能为负数，超过 MaxPageSize 时取 MaxPageSize
+func parseTop(r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("top")
+	if v == "" {
+		return DefaultSizeTop, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return min(n, MaxPageSize), true
+}
+
+// history 返回路径的历史
+func (h *Handler) history(rw http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		h.writeError(rw, r, http.StatusBadRequest, "missing path")
+		return
+	}
+	versions := h.w.FileHistory(path)
+	if versions == nil {
+		versions = []watcher.FileVersion{}
+	}
+	for i, v := range versions {
+		if v.Meta != nil && watcher.SlashPath(v.Meta.Path) != v.Meta.Path {
+			m := *v.Meta
+			m.Path = watcher.SlashPath(m.Path)
+			versions[i].Meta = &m
+		}
+	}
+	rw.Header().Set("Cache-Control", "no-cache")
+	h.writeJSON(rw, r, http.StatusOK, versions)
+}
+
+// tag 打标签(PUT)或删除标签(DELETE)
+func (h *Handler) tag(rw http.ResponseWriter, r *http.Request, tag string) {
+	if r.Method == http.MethodDelete {
+		if !h.w.UntagSnapshot(tag) {
+			h.writeError(rw, r, http.StatusNotFound, "tag "+tag+" not found")
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var body struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.writeError(rw, r, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	if err := h.w.TagSnapshot(tag, body.SnapshotID); err != nil {
+		h.writeError(rw, r, errorStatus(err), err.Error())
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// describe 修改快照描述
+func (h *Handler) describe(rw http.ResponseWriter, r *http.Request, id string) {
+	var body struct {
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.writeError(rw, r, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	if err := h.w.SetSnapshotDescription(id, body.Description); err != nil {
+		h.writeError(rw, r, errorStatus(err), err.Error())
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON 编码响应，较大的响应在客户端支持时使用 gzip
+func (h *Handler) writeJSON(rw http.ResponseWriter, r *http.Request, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Add("Vary", "Accept-Encoding")
+	if len(data) < gzipThreshold || !acceptsGzip(r) {
+		rw.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		rw.WriteHeader(status)
+		if r.Method != http.MethodHead {
+			_, _ = rw.Write(data)
+		}
+		return
+	}
+	rw.Header().Set("Content-Encoding", "gzip")
+	rw.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	gz := gzip.NewWriter(rw)
+	_, _ = gz.Write(data)
+	_ = gz.Close()
+}
+
+// writeError 以 JSON 返回错误
+func (h *Handler) writeError(rw http.ResponseWriter, r *http.Request, status int, msg string) {
+	rw.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(rw, r, status, errorBody{Error: msg})
+}
+
+// errorStatus 把 Watcher 返回的错误映射为 HTTP 状态码
+func errorStatus(err error) int {
+	if errors.Is(err, watcher.ErrSnapshotNotFound) || errors.Is(err, watcher.ErrTagNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, watcher.ErrStopped) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// etag 由快照ID与描述的哈希构成(文件表不可变，只有描述可能被修改)
+func etag(sn *watcher.SnapshotNode) string {
+	sum := sha256.Sum256([]byte(sn.Description))
+	return `"` + sn.ID + "-" + hex.EncodeToString(sum[:6]) + `"`
+}
+
+// acceptsGzip 判断客户端是否接受 gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc = strings.TrimSpace(enc)
+		if enc == "gzip" || strings.HasPrefix(enc, "gzip;") {
+			return true
+		}
+	}
+	return false
+}
+
+// queryInt 读取整数查询参数，缺省时返回 def
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// fingerprint 返回快照配置的指纹，没有记录时返回空串
+func fingerprint(c *watcher.SnapshotConfig) string {
+	if c == nil {
+		return ""
+	}
+	return c.Fingerprint
+}
@@ -0,0 +1,280 @@
+package watcherhttp
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+)
+
+// newTestServer 创建一个包含若干快照的 Watcher 及对应的测试服务
+func newTestServer(t *testing.T, opts Options) (*watcher.Watcher, *httptest.Server, string) {
+	t.Helper()
+	root := t.TempDir()
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{WatchPaths: []string{root}})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	file := filepath.Join(root, "a.txt")
+	for i := 0; i < 3; i++ {
+		_ = os.WriteFile(file, []byte(strings.Repeat("x", i+1)), 0644)
+		if _, _, err := w.RehashFile(file); err != nil {
+			t.Fatalf("RehashFile failed: %v", err)
+		}
+	}
+	srv := httptest.NewServer(NewHandler(w, opts))
+	t.Cleanup(srv.Close)
+	return w, srv, file
+}
+
+// getJSON 发起 GET 请求并解码 JSON 响应
+func getJSON(t *testing.T, url string, v any) *http.Response {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("decoding %s failed: %v", url, err)
+		}
+	}
+	return resp
+}
+
+// TestReadEndpoints 测试只读接口
+func TestReadEndpoints(t *testing.T) {
+	w, srv, file := newTestServer(t, Options{})
+	cur := w.GetCurrentSnapshot()
+
+	var page SnapshotPage
+	getJSON(t, srv.URL+"/snapshots?offset=1&limit=2", &page)
+	if page.Total != 4 || len(page.Items) != 2 || page.Items[0].ParentIDs == nil {
+		t.Errorf("unexpected page %+v", page)
+	}
+	if resp := getJSON(t, srv.URL+"/snapshots?limit=-1", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid limit: status %d", resp.StatusCode)
+	}
+
+	var sn watcher.SnapshotNode
+	resp := getJSON(t, srv.URL+"/snapshots/"+cur.ID, &sn)
+	if sn.ID != cur.ID || len(sn.Files) != len(cur.Files) {
+		t.Errorf("snapshot by id: got %s with %d files", sn.ID, len(sn.Files))
+	}
+	if cc := resp.Header.Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("snapshot by id Cache-Control = %q", cc)
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/snapshots/"+cur.ID, nil)
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNotModified {
+		t.Errorf("conditional GET should return 304, got %v %v", resp, err)
+	}
+	if resp := getJSON(t, srv.URL+"/snapshots/nope", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown snapshot: status %d", resp.StatusCode)
+	}
+
+	resp = getJSON(t, srv.URL+"/snapshots/current", &sn)
+	if sn.ID != cur.ID || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("current snapshot: got %s, Cache-Control %q", sn.ID, resp.Header.Get("Cache-Control"))
+	}
+
+	var d watcher.SnapshotDiff
+	first := w.FileHistory(file)[2].SnapshotID
+	resp = getJSON(t, fmt.Sprintf("%s/diff?from=%s&to=%s", srv.URL, first, cur.ID), &d)
+	if len(d.Modified) != 1 || d.Modified[0].Path != file || d.FromID != first || d.ToID != cur.ID {
+		t.Errorf("unexpected diff %+v", d)
+	}
+	if resp.ContentLength != -1 || resp.Header.Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("diff should be streamed: Content-Length %d, Cache-Control %q", resp.ContentLength, resp.Header.Get("Cache-Control"))
+	}
+	if resp := getJSON(t, fmt.Sprintf("%s/diff?from=nope&to=%s", srv.URL, cur.ID), nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("diff from an unknown snapshot: status %d", resp.StatusCode)
+	}
+
+	_ = w.TagSnapshot("golden", first)
+	var drift watcher.DriftReport
+	getJSON(t, srv.URL+"/drift?tag=golden", &drift)
+	if drift.BaselineID != first || drift.Modified != 1 || drift.NetBytes != 2 {
+		t.Errorf("unexpected drift %+v", drift)
+	}
+	if resp := getJSON(t, srv.URL+"/drift?tag=nope", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown tag: status %d", resp.StatusCode)
+	}
+
+	var h []watcher.FileVersion
+	getJSON(t, srv.URL+"/history?path="+file, &h)
+	if len(h) != 3 {
+		t.Errorf("history: got %d versions; want 3", len(h))
+	}
+
+	var files FilesResult
+	body, _ := json.Marshal(map[string][]string{"paths": {file, "/nope", file}})
+	resp, err := http.Post(srv.URL+"/snapshots/current/files", "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("POST files failed: %v", err)
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&files)
+	resp.Body.Close()
+	if files.SnapshotID != cur.ID || len(files.Found) != 1 || files.Found[file].Size != 3 || len(files.Missing) != 1 {
+		t.Errorf("unexpected files result %+v", files)
+	}
+	if resp := getJSON(t, srv.URL+"/snapshots/current/files", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET files: status %d", resp.StatusCode)
+	}
+
+	var st watcher.WatcherStats
+	getJSON(t, srv.URL+"/stats", &st)
+	if st.SnapshotCount != 4 {
+		t.Errorf("stats.SnapshotCount = %d; want 4", st.SnapshotCount)
+	}
+
+	if resp := getJSON(t, srv.URL+"/nope", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown route: status %d", resp.StatusCode)
+	}
+}
+
+// TestDiffCompare 测试 compare 参数：只有修改时间变化的文件在 HashOnly 下不报告，未知的方式返回 400
+func TestDiffCompare(t *testing.T) {
+	w, srv, file := newTestServer(t, Options{})
+	from := w.GetCurrentSnapshot().ID
+	later := time.Now().Add(time.Hour)
+	_ = os.Chtimes(file, later, later)
+	other := filepath.Join(filepath.Dir(file), "b.txt")
+	_ = os.WriteFile(other, []byte("b"), 0644)
+	for _, p := range []string{file, other} {
+		if _, _, err := w.RehashFile(p); err != nil {
+			t.Fatalf("RehashFile failed: %v", err)
+		}
+	}
+	to := w.GetCurrentSnapshot().ID
+
+	for compare, modified := range map[string]int{"": 1, "full": 1, "HashAndSize": 0, "hashonly": 0} {
+		var d watcher.SnapshotDiff
+		getJSON(t, fmt.Sprintf("%s/diff?from=%s&to=%s&compare=%s", srv.URL, from, to, compare), &d)
+		if len(d.Added) != 1 || len(d.Modified) != modified {
+			t.Errorf("compare=%q: %d added, %d modified; want 1, %d", compare, len(d.Added), len(d.Modified), modified)
+		}
+	}
+	if resp := getJSON(t, fmt.Sprintf("%s/diff?from=%s&compare=mtime", srv.URL, from), nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown compare mode: status %d", resp.StatusCode)
+	}
+}
+
+// TestSizes 测试大小报告与两个快照之间的大小变化，top 非法时返回 400
+func TestSizes(t *testing.T) {
+	w, srv, file := newTestServer(t, Options{})
+	first := w.FileHistory(file)[2].SnapshotID
+
+	var rep watcher.SizeReport
+	resp := getJSON(t, srv.URL+"/snapshots/current/sizes?top=1", &rep)
+	if rep.Files != 1 || rep.TotalBytes != 3 || len(rep.Largest) != 1 || rep.Largest[0].Path != watcher.SlashPath(file) {
+		t.Errorf("unexpected size report %+v", rep)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("current sizes Cache-Control = %q", cc)
+	}
+	if resp := getJSON(t, srv.URL+"/snapshots/nope/sizes", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown snapshot: status %d", resp.StatusCode)
+	}
+
+	var g watcher.SizeGrowthReport
+	getJSON(t, fmt.Sprintf("%s/sizes?from=%s", srv.URL, first), &g)
+	if g.NetBytes != 2 || len(g.Growth) != 1 || g.Growth[0].SizeDelta != 2 {
+		t.Errorf("unexpected size growth %+v", g)
+	}
+	for _, q := range []string{"", "from=" + first + "&top=-1", "from=" + first + "&top=x"} {
+		if resp := getJSON(t, srv.URL+"/sizes?"+q, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("/sizes?%s: status %d; want 400", q, resp.StatusCode)
+		}
+	}
+}
+
+// TestGzip 测试较大的响应在客户端支持时被压缩
+func TestGzip(t *testing.T) {
+	w, srv, _ := newTestServer(t, Options{})
+	_ = w.SetSnapshotDescription(w.GetCurrentSnapshot().ID, strings.Repeat("long description ", 100))
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/snapshots/current", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q; want gzip", resp.Header.Get("Content-Encoding"))
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(gz)
+	var sn watcher.SnapshotNode
+	if err := json.Unmarshal(data, &sn); err != nil || sn.ID == "" {
+		t.Errorf("decoding gzipped body failed: %v", err)
+	}
+}
+
+// TestMutations 测试修改接口默认关闭、开启后可用
+func TestMutations(t *testing.T) {
+	do := func(url, method, body string) int {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	w, srv, _ := newTestServer(t, Options{})
+	id := w.GetCurrentSnapshot().ID
+	if code := do(srv.URL+"/tags/golden", http.MethodPut, `{"snapshot_id":"`+id+`"}`); code != http.StatusForbidden {
+		t.Errorf("mutation with default options: status %d; want 403", code)
+	}
+	if code := do(srv.URL+"/snapshots", http.MethodPost, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /snapshots: status %d; want 405", code)
+	}
+
+	w, srv, _ = newTestServer(t, Options{EnableMutations: true})
+	id = w.GetCurrentSnapshot().ID
+	if code := do(srv.URL+"/tags/golden", http.MethodPut, `{"snapshot_id":"`+id+`"}`); code != http.StatusNoContent {
+		t.Errorf("PUT tag: status %d", code)
+	}
+	if w.SnapshotByTag("golden") == nil {
+		t.Error("tag was not created")
+	}
+	if code := do(srv.URL+"/snapshots/"+id+"/description", http.MethodPut, `{"description":"release"}`); code != http.StatusNoContent {
+		t.Errorf("PUT description: status %d", code)
+	}
+	if d := w.GetSnapshotByID(id).Description; d != "release" {
+		t.Errorf("description = %q; want release", d)
+	}
+	var page SnapshotPage
+	getJSON(t, srv.URL+"/snapshots", &page)
+	if last := page.Items[len(page.Items)-1]; len(last.Tags) != 1 || last.Tags[0] != "golden" {
+		t.Errorf("list should include tags, got %+v", last)
+	}
+	if code := do(srv.URL+"/tags/golden", http.MethodDelete, ""); code != http.StatusNoContent {
+		t.Errorf("DELETE tag: status %d", code)
+	}
+	if code := do(srv.URL+"/tags/golden", http.MethodDelete, ""); code != http.StatusNotFound {
+		t.Errorf("DELETE missing tag: status %d", code)
+	}
+	if code := do(srv.URL+"/stats/latency", http.MethodDelete, ""); code != http.StatusNoContent {
+		t.Errorf("DELETE /stats/latency: status %d", code)
+	}
+	if st := w.Stats(); st.BatchLatency.Count != 0 || st.HashLatency.Count != 0 {
+		t.Errorf("latency not reset: batch %d hash %d", st.BatchLatency.Count, st.HashLatency.Count)
+	}
+}
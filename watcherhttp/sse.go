@@ -0,0 +1,163 @@
+package watcherhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shuakami/watcher"
+)
+
+// DefaultHeartbeat 是 SSE 心跳间隔的默认值
+const DefaultHeartbeat = 15 * time.Second
+
+// SSEOptions 配置 SSE 推送
+//
+// Heartbeat：空闲时发送注释行的间隔，防止代理断开连接, 默认 15s
+// Buffer：每个连接的订阅缓冲大小, 默认 watcher.DefaultSubscriptionBuffer
+type SSEOptions struct {
+	Heartbeat time.Duration
+	Buffer    int
+}
+
+// SSEHandler 以 Server-Sent Events 推送 FileEvent
+//
+// 每个事件一个 data 帧，id 为事件序号(Seq)；浏览器重连时携带的 Last-Event-ID
+// (或查询参数 last_event_id)用于续接：断线期间的事件仍在 Watcher 的回放缓冲(ReplayBuffer)中时先补发它们，
+// 无法补发的区间以一个 "gap" 事件告知客户端，客户端可据此通过快照差异重新同步
+type SSEHandler struct {
+	w    *watcher.Watcher
+	opts SSEOptions
+}
+
+// NewSSEHandler 为给定的 Watcher 创建 SSEHandler
+func NewSSEHandler(w *watcher.Watcher, opts SSEOptions) *SSEHandler {
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = DefaultHeartbeat
+	}
+	return &SSEHandler{w: w, opts: opts}
+}
+
+// EventMessage 是 data 帧中的 JSON 结构
+type EventMessage struct {
+	Seq        uint64 `json:"seq"`
+	Path       string `json:"path"`
+	Op         string `json:"op"`
+	SnapshotID string `json:"snapshot_id"`
+}
+
+// GapMessage 是 gap 事件的 JSON 结构，表示 [From, To] 区间内的事件没有送达
+type GapMessage struct {
+	From       uint64 `json:"from"`
+	To         uint64 `json:"to"`
+	SnapshotID string `json:"snapshot_id"` // 发送 gap 时的当前快照，可作为差异比较的终点
+}
+
+// ServeHTTP 实现 http.Handler
+//
+// 连接断开或 Watcher 停止时返回，订阅随之关闭
+func (h *SSEHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", "GET")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	lastID, hasLastID, err := lastEventID(r)
+	if err != nil {
+		http.Error(rw, "invalid Last-Event-ID", http.StatusBadRequest)
+		return
+	}
+
+	// 能续接时先补发回放缓冲(watcher.ConfigWatcher.ReplayBuffer)中断线期间的事件，否则只订阅新事件并以 gap 告知
+	var sub *watcher.Subscription
+	if hasLastID {
+		sub, _ = h.w.SubscribeFrom(lastID, h.opts.Buffer)
+	}
+	if sub == nil {
+		sub = h.w.Subscribe(h.opts.Buffer)
+	}
+	defer sub.Close()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+
+	next := sub.StartSeq + 1
+	if hasLastID && lastID < sub.StartSeq {
+		next = lastID + 1
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.opts.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(rw, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if ev.Seq > next {
+				// 断线期间或订阅缓冲溢出时遗漏的事件
+				if err := writeFrame(rw, "gap", 0, GapMessage{From: next, To: ev.Seq - 1, SnapshotID: ev.SnapshotID()}); err != nil {
+					return
+				}
+			}
+			next = ev.Seq + 1
+			msg := EventMessage{Seq: ev.Seq, Path: ev.FilePath, Op: ev.Kind.String(), SnapshotID: ev.SnapshotID()}
+			if err := writeFrame(rw, "", ev.Seq, msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeFrame 写出一个 SSE 帧，event 为空时使用默认的 message 类型，id 为0时不写 id 行
+func writeFrame(rw http.ResponseWriter, event string, id uint64, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if event != "" {
+		if _, err := fmt.Fprintf(rw, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	if id != 0 {
+		if _, err := fmt.Fprintf(rw, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(rw, "data: %s\n\n", data)
+	return err
+}
+
+// lastEventID 读取 Last-Event-ID 请求头(或 last_event_id 查询参数)
+func lastEventID(r *http.Request) (uint64, bool, error) {
+	s := r.Header.Get("Last-Event-ID")
+	if s == "" {
+		s = r.URL.Query().Get("last_event_id")
+	}
+	if s == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	return id, err == nil, err
+}
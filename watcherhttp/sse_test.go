@@ -0,0 +1,202 @@
+package watcherhttp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+)
+
+// sseFrame 是解析后的一个 SSE 帧
+type sseFrame struct {
+	event, id, data string
+}
+
+// readFrames 在后台读取 SSE 帧(忽略注释行)
+func readFrames(resp *http.Response) <-chan sseFrame {
+	out := make(chan sseFrame, 16)
+	go func() {
+		defer close(out)
+		sc := bufio.NewScanner(resp.Body)
+		var f sseFrame
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "":
+				if f.data != "" {
+					out <- f
+				}
+				f = sseFrame{}
+			case strings.HasPrefix(line, "event: "):
+				f.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "id: "):
+				f.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				f.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return out
+}
+
+// nextFrame 等待下一个帧
+func nextFrame(t *testing.T, frames <-chan sseFrame) sseFrame {
+	t.Helper()
+	select {
+	case f, ok := <-frames:
+		if !ok {
+			t.Fatal("stream closed")
+		}
+		return f
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for SSE frame")
+	}
+	return sseFrame{}
+}
+
+// openStream 连接事件流并等待订阅建立
+func openStream(t *testing.T, ctx context.Context, w *watcher.Watcher, url, lastID string) *http.Response {
+	t.Helper()
+	before := w.Stats().Subscribers
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for w.Stats().Subscribers == before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return resp
+}
+
+// TestSSEStream 测试事件推送、Last-Event-ID 断点检测与断开后的订阅清理
+func TestSSEStream(t *testing.T) {
+	w, srv, file := newTestServer(t, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	resp := openStream(t, ctx, w, srv.URL+"/events", "")
+	frames := readFrames(resp)
+
+	_ = os.WriteFile(file, []byte("changed"), 0644)
+	if _, _, err := w.RehashFile(file); err != nil {
+		t.Fatal(err)
+	}
+	f := nextFrame(t, frames)
+	var msg EventMessage
+	if err := json.Unmarshal([]byte(f.data), &msg); err != nil {
+		t.Fatalf("invalid data %q: %v", f.data, err)
+	}
+	if msg.Path != file || msg.Op != "WRITE" || msg.SnapshotID != w.GetCurrentSnapshot().ID || f.id != "4" {
+		t.Errorf("unexpected frame id=%s %+v", f.id, msg)
+	}
+
+	cancel()
+	resp.Body.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for w.Stats().Subscribers != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := w.Stats().Subscribers; n != 0 {
+		t.Errorf("subscription leaked after disconnect: %d", n)
+	}
+
+	// 断线期间的事件无法补发，重连后先收到 gap
+	_ = os.WriteFile(filepath.Join(filepath.Dir(file), "b.txt"), []byte("b"), 0644)
+	_, _, _ = w.RehashFile(filepath.Join(filepath.Dir(file), "b.txt"))
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	resp = openStream(t, ctx, w, srv.URL+"/events", "4")
+	defer resp.Body.Close()
+	frames = readFrames(resp)
+	_ = os.WriteFile(file, []byte("again"), 0644)
+	_, _, _ = w.RehashFile(file)
+
+	f = nextFrame(t, frames)
+	var gap GapMessage
+	if err := json.Unmarshal([]byte(f.data), &gap); f.event != "gap" || err != nil || gap.From != 5 || gap.To != 5 {
+		t.Errorf("expected gap 5..5, got event=%q %s", f.event, f.data)
+	}
+	if f = nextFrame(t, frames); f.id != "6" {
+		t.Errorf("expected event 6 after the gap, got id=%q", f.id)
+	}
+}
+
+// TestSSEHeartbeat 测试空闲时发送心跳以及 Watcher 停止时结束流
+func TestSSEHeartbeat(t *testing.T) {
+	root := t.TempDir()
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{WatchPaths: []string{root}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewSSEHandler(w, SSEOptions{Heartbeat: 10 * time.Millisecond}))
+	defer srv.Close()
+
+	resp := openStream(t, context.Background(), w, srv.URL, "")
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	line, err := r.ReadString('\n')
+	if err != nil || line != ": ping\n" {
+		t.Errorf("expected heartbeat, got %q %v", line, err)
+	}
+
+	w.Stop()
+	done := make(chan struct{})
+	go func() {
+		for {
+			if _, err := r.ReadString('\n'); err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("stream should end when the watcher stops")
+	}
+}
+
+// TestSSEReplay 测试配置了 ReplayBuffer 时重连先补发断线期间的事件而不是 gap
+func TestSSEReplay(t *testing.T) {
+	root := t.TempDir()
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{WatchPaths: []string{root}, ReplayBuffer: 16, DisableEventChan: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	srv := httptest.NewServer(NewSSEHandler(w, SSEOptions{}))
+	defer srv.Close()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		p := filepath.Join(root, name)
+		_ = os.WriteFile(p, []byte(name), 0644)
+		if _, _, err := w.RehashFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp := openStream(t, ctx, w, srv.URL, "1")
+	defer resp.Body.Close()
+	frames := readFrames(resp)
+	if f := nextFrame(t, frames); f.event != "" || f.id != "2" {
+		t.Errorf("expected the missed event 2 to be replayed, got event=%q id=%q", f.event, f.id)
+	}
+}
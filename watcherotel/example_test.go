@@ -0,0 +1,31 @@
+package watcherotel_test
+
+import (
+	"context"
+	"log"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watcherotel"
+)
+
+// Example 展示如何把批次追踪导出到 stdout
+func Example() {
+	exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+	if err != nil {
+		log.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{
+		WatchPaths: []string{"/srv/data"},
+		Tracer:     watcherotel.NewTracer(tp.Tracer("watcher"), watcherotel.Options{}),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	_ = w
+}
@@ -0,0 +1,22 @@
+module github.com/shuakami/watcher/watcherotel
+
+go 1.21
+
+require (
+	github.com/shuakami/watcher v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
+
+// 本地开发时使用仓库中的 watcher，发布时去掉并依赖已发布的版本
+replace github.com/shuakami/watcher => ../
@@ -0,0 +1,104 @@
+// Package watcherotel 提供基于 OpenTelemetry 的 watcher.BatchTracer 实现
+//
+// 每个 flush 批次对应一个 span(watcher.batch)，属性中包含批次大小与部分路径采样；
+// 耗时超过阈值的单文件哈希会作为子 span(watcher.hash)记录；
+// 每个提交的快照以 span 事件(snapshot.created)的形式记录快照ID。
+//
+// 使用方式：
+//
+//	cfg.Tracer = watcherotel.NewTracer(otel.Tracer("watcher"), watcherotel.Options{})
+package watcherotel
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/shuakami/watcher"
+)
+
+// 默认值
+const (
+	DefaultHashThreshold   = 10 * time.Millisecond
+	DefaultMaxSampledPaths = 10
+)
+
+// Options 配置 span 的详细程度
+//
+// HashThreshold：单文件哈希耗时超过该值才创建子 span, 默认 10ms；负数表示全部记录
+// MaxSampledPaths：批次 span 上记录的路径数上限, 默认 10
+type Options struct {
+	HashThreshold   time.Duration
+	MaxSampledPaths int
+}
+
+// Tracer 实现 watcher.BatchTracer
+type Tracer struct {
+	tracer trace.Tracer
+	opts   Options
+}
+
+// NewTracer 使用给定的 trace.Tracer 创建 BatchTracer
+func NewTracer(tracer trace.Tracer, opts Options) *Tracer {
+	if opts.HashThreshold == 0 {
+		opts.HashThreshold = DefaultHashThreshold
+	}
+	if opts.MaxSampledPaths <= 0 {
+		opts.MaxSampledPaths = DefaultMaxSampledPaths
+	}
+	return &Tracer{tracer: tracer, opts: opts}
+}
+
+// StartBatch 实现 watcher.BatchTracer
+func (t *Tracer) StartBatch(paths []string) watcher.BatchSpan {
+	sampled := paths
+	if len(sampled) > t.opts.MaxSampledPaths {
+		sampled = sampled[:t.opts.MaxSampledPaths]
+	}
+	ctx, span := t.tracer.Start(context.Background(), "watcher.batch",
+		trace.WithAttributes(
+			attribute.Int("watcher.batch.size", len(paths)),
+			attribute.StringSlice("watcher.batch.paths", sampled),
+		))
+	return &batchSpan{t: t, ctx: ctx, span: span}
+}
+
+// batchSpan 实现 watcher.BatchSpan
+//
+// OpenTelemetry 的 span 本身是并发安全的，这里不需要额外加锁
+type batchSpan struct {
+	t    *Tracer
+	ctx  context.Context
+	span trace.Span
+}
+
+// FileHashed 为耗时超过阈值的哈希创建子 span，时间戳使用实际的开始与结束时间
+func (b *batchSpan) FileHashed(path string, start time.Time, d time.Duration, err error) {
+	if b.t.opts.HashThreshold > 0 && d < b.t.opts.HashThreshold {
+		return
+	}
+	_, span := b.t.tracer.Start(b.ctx, "watcher.hash",
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attribute.String("watcher.path", path)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(start.Add(d)))
+}
+
+// SnapshotCreated 以 span 事件记录新快照ID
+func (b *batchSpan) SnapshotCreated(path, snapshotID string) {
+	b.span.AddEvent("snapshot.created", trace.WithAttributes(
+		attribute.String("watcher.path", path),
+		attribute.String("watcher.snapshot.id", snapshotID),
+	))
+}
+
+// End 结束批次 span
+func (b *batchSpan) End() {
+	b.span.End()
+}
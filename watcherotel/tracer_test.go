@@ -0,0 +1,109 @@
+package watcherotel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/shuakami/watcher"
+)
+
+// TestTracerSpans 测试真实事件流经时生成的批次 span、哈希子 span 与快照事件
+func TestTracerSpans(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	root := t.TempDir()
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{
+		WatchPaths: []string{root},
+		Debounce:   5 * time.Millisecond,
+		Tracer:     NewTracer(tp.Tracer("test"), Options{HashThreshold: -1}),
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer w.Stop()
+
+	_ = os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644)
+	var ev watcher.FileEvent
+	select {
+	case ev = <-w.EventChan:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	var batch, hash sdktrace.ReadOnlySpan
+	deadline := time.Now().Add(2 * time.Second)
+	for batch == nil && time.Now().Before(deadline) {
+		for _, s := range exp.GetSpans().Snapshots() {
+			switch s.Name() {
+			case "watcher.batch":
+				batch = s
+			case "watcher.hash":
+				hash = s
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if batch == nil {
+		t.Fatal("no batch span recorded")
+	}
+	if !hasAttr(batch.Attributes(), "watcher.batch.size") {
+		t.Errorf("batch span missing size attribute: %v", batch.Attributes())
+	}
+	if hash == nil || hash.Parent().SpanID() != batch.SpanContext().SpanID() {
+		t.Errorf("hash span should be a child of the batch span")
+	}
+	var found bool
+	for _, e := range batch.Events() {
+		for _, a := range e.Attributes {
+			if a.Key == "watcher.snapshot.id" && a.Value.AsString() == ev.NewSnap.ID {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("batch span should carry snapshot %s in an event", ev.NewSnap.ID)
+	}
+}
+
+// TestHashThreshold 测试低于阈值的哈希不创建子 span
+func TestHashThreshold(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	tr := NewTracer(tp.Tracer("test"), Options{HashThreshold: time.Second})
+
+	span := tr.StartBatch([]string{"a", "b"})
+	span.FileHashed("a", time.Now(), time.Millisecond, nil)
+	span.FileHashed("b", time.Now(), 2*time.Second, nil)
+	span.End()
+
+	var hashes int
+	for _, s := range exp.GetSpans() {
+		if s.Name == "watcher.hash" {
+			hashes++
+		}
+	}
+	if hashes != 1 {
+		t.Errorf("got %d hash spans; want 1", hashes)
+	}
+}
+
+func hasAttr(attrs []attribute.KeyValue, key attribute.Key) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
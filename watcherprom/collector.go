@@ -0,0 +1,232 @@
+// Package watcherprom 把 watcher.Watcher 的内部统计以 Prometheus 指标的形式导出
+//
+// 该包独立于核心包，只有需要 Prometheus 的使用方才会引入 client_golang 依赖。
+// 指标在每次抓取时从 Watcher.Stats() 读取，不会在事件处理路径上增加任何开销。
+//
+// 同一个 Registry 中注册多个 Watcher 时，请为每个 Collector 设置不同的 ConstLabels
+// (例如 {"root": "/data"})，否则指标会冲突。
+package watcherprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/shuakami/watcher"
+)
+
+// Options 配置指标名称与常量标签
+//
+// Namespace：指标名前缀，默认 "watcher"
+// Subsystem：指标名的第二段(可为空)
+// ConstLabels：附加到所有指标上的常量标签，用于区分多个 Watcher
+type Options struct {
+	Namespace   string
+	Subsystem   string
+	ConstLabels prometheus.Labels
+}
+
+// metricDef 描述一个由 WatcherStats 派生的计数器/仪表
+type metricDef struct {
+	desc  *prometheus.Desc
+	typ   prometheus.ValueType
+	value func(st *watcher.WatcherStats) float64
+}
+
+// Collector 实现 prometheus.Collector
+type Collector struct {
+	w       *watcher.Watcher
+	metrics []metricDef
+
+	batchLatency *prometheus.Desc
+	hashLatency  *prometheus.Desc
+	eventLatency *prometheus.Desc
+}
+
+// NewCollector 为给定的 Watcher 创建 Collector
+func NewCollector(w *watcher.Watcher, opts Options) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "watcher"
+	}
+	newDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, opts.Subsystem, name), help, nil, opts.ConstLabels)
+	}
+	counter := func(name, help string, v func(st *watcher.WatcherStats) uint64) metricDef {
+		return metricDef{
+			desc:  newDesc(name, help),
+			typ:   prometheus.CounterValue,
+			value: func(st *watcher.WatcherStats) float64 { return float64(v(st)) },
+		}
+	}
+	gauge := func(name, help string, v func(st *watcher.WatcherStats) float64) metricDef {
+		return metricDef{desc: newDesc(name, help), typ: prometheus.GaugeValue, value: v}
+	}
+
+	c := &Collector{
+		w: w,
+		metrics: []metricDef{
+			counter("events_received_total", "Events received from fsnotify.",
+				func(st *watcher.WatcherStats) uint64 { return st.EventsReceived }),
+			counter("events_ignored_total", "Events dropped by ignore patterns.",
+				func(st *watcher.WatcherStats) uint64 { return st.EventsIgnored }),
+			counter("events_self_suppressed_total", "Events dropped because the process marked the path as its own write.",
+				func(st *watcher.WatcherStats) uint64 { return st.SelfSuppressed }),
+			counter("path_rewrite_conflicts_total", "Paths skipped because the configured path rewrite could not be reversed.",
+				func(st *watcher.WatcherStats) uint64 { return st.PathRewriteConflicts }),
+			counter("events_aggregated_total", "Events placed into the debounce map.",
+				func(st *watcher.WatcherStats) uint64 { return st.EventsAggregated }),
+			counter("events_coalesced_total", "Events merged into an existing debounce entry.",
+				func(st *watcher.WatcherStats) uint64 { return st.EventsCoalesced }),
+			counter("event_overflows_total", "Kernel event queue overflows reported by fsnotify.",
+				func(st *watcher.WatcherStats) uint64 { return st.EventOverflows }),
+			counter("overflow_rescans_total", "Reconciliation rescans completed after an event queue overflow.",
+				func(st *watcher.WatcherStats) uint64 { return st.OverflowRescans }),
+			counter("degraded_entries_total", "Times event storm protection entered degraded mode.",
+				func(st *watcher.WatcherStats) uint64 { return st.DegradedEntries }),
+			{desc: newDesc("degraded_seconds_total", "Time spent in degraded mode."), typ: prometheus.CounterValue,
+				value: func(st *watcher.WatcherStats) float64 { return st.DegradedTime.Seconds() }},
+			gauge("degraded", "1 while event storm protection has degraded the watcher.",
+				func(st *watcher.WatcherStats) float64 { return boolGauge(st.Degraded) }),
+			counter("flush_cycles_total", "Debounce flush cycles executed.",
+				func(st *watcher.WatcherStats) uint64 { return st.FlushCycles }),
+			counter("size_flushes_total", "Flushes forced because the merge table reached MaxPendingPaths.",
+				func(st *watcher.WatcherStats) uint64 { return st.SizeFlushes }),
+			counter("batches_processed_total", "Non-empty flush batches processed.",
+				func(st *watcher.WatcherStats) uint64 { return st.BatchesProcessed }),
+			counter("batches_completed_total", "Flush batches whose paths were all processed and events emitted.",
+				func(st *watcher.WatcherStats) uint64 { return st.BatchesCompleted }),
+			counter("snapshots_created_total", "Snapshots created.",
+				func(st *watcher.WatcherStats) uint64 { return st.SnapshotsCreated }),
+			counter("snapshot_changes_coalesced_total", "Commits merged into a pending snapshot by MinSnapshotInterval.",
+				func(st *watcher.WatcherStats) uint64 { return st.ChangesCoalesced }),
+			counter("snapshot_describe_fallbacks_total", "Snapshots given the default description because DescribeSnapshot panicked or timed out.",
+				func(st *watcher.WatcherStats) uint64 { return st.DescribeFallbacks }),
+			gauge("snapshot_describe_running", "DescribeSnapshot callbacks still running, including ones no longer waited for.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.DescribeRunning) }),
+			counter("snapshots_squashed_total", "Snapshots removed because a path exceeded its history limit.",
+				func(st *watcher.WatcherStats) uint64 { return st.SnapshotsSquashed }),
+			gauge("tombstones", "Deleted entries kept as tombstones in the current state.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.Tombstones) }),
+			counter("snapshots_spilled_total", "Snapshots moved from memory to the snapshot store.",
+				func(st *watcher.WatcherStats) uint64 { return st.SnapshotsSpilled }),
+			counter("snapshots_hydrated_total", "Snapshots loaded back from the snapshot store.",
+				func(st *watcher.WatcherStats) uint64 { return st.SnapshotsHydrated }),
+			counter("snapshot_hydration_cache_hits_total", "Reads of stored snapshots served from the rehydration cache.",
+				func(st *watcher.WatcherStats) uint64 { return st.HydrationCacheHits }),
+			counter("hash_operations_total", "Files whose content was hashed.",
+				func(st *watcher.WatcherStats) uint64 { return st.HashOps }),
+			counter("hashed_bytes_total", "Bytes read while hashing.",
+				func(st *watcher.WatcherStats) uint64 { return st.BytesHashed }),
+			counter("hash_errors_total", "Hashing failures.",
+				func(st *watcher.WatcherStats) uint64 { return st.HashErrors }),
+			counter("hash_retries_total", "Hash retries scheduled because a file was locked by another process.",
+				func(st *watcher.WatcherStats) uint64 { return st.HashRetries }),
+			gauge("hash_pending", "Files waiting for a hash retry.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.HashPending) }),
+			gauge("completion_pending", "Files in a drop folder waiting for their writes to complete.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.CompletionPending) }),
+			counter("events_emitted_total", "FileEvents sent to EventChan.",
+				func(st *watcher.WatcherStats) uint64 { return st.EventsEmitted }),
+			counter("events_dropped_total", "FileEvents dropped because EventChan could not accept them.",
+				func(st *watcher.WatcherStats) uint64 { return st.EventsDropped }),
+			counter("errors_dropped_total", "Errors dropped because ErrorChan was full.",
+				func(st *watcher.WatcherStats) uint64 { return st.ErrorsDropped }),
+			counter("errors_deduplicated_total", "Repeated errors folded into a summary instead of being sent.",
+				func(st *watcher.WatcherStats) uint64 { return st.ErrorsDeduplicated }),
+			counter("subscriber_events_dropped_total", "FileEvents dropped because a subscription buffer was full.",
+				func(st *watcher.WatcherStats) uint64 { return st.SubscriberDropped }),
+			counter("audit_records_total", "Records written to the audit log.",
+				func(st *watcher.WatcherStats) uint64 { return st.AuditWritten }),
+			counter("audit_records_dropped_total", "Audit records dropped because the audit queue was full.",
+				func(st *watcher.WatcherStats) uint64 { return st.AuditDropped }),
+			gauge("subscribers", "Active event subscriptions.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.Subscribers) }),
+			gauge("replay_buffered_events", "FileEvents held in the replay buffer for SubscribeFrom.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.ReplayBuffered) }),
+			gauge("snapshots", "Snapshots currently held.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.SnapshotCount) }),
+			gauge("snapshots_in_memory", "Snapshots whose file tables are held in memory.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.SnapshotsInMemory) }),
+			gauge("watched_dirs", "Directories registered with fsnotify.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.WatchedDirs) }),
+			gauge("watched_dirs_limit", "Configured MaxWatchedDirs (0 = unlimited).",
+				func(st *watcher.WatcherStats) float64 { return float64(st.WatchedDirsLimit) }),
+			gauge("inaccessible_dirs", "Directories that became unreadable and whose subtrees are frozen.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.InaccessibleDirs) }),
+			gauge("watched_dirs_skipped", "Directories left unwatched because MaxWatchedDirs was reached.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.WatchesSkipped) }),
+			gauge("watched_dirs_failed", "Directories whose watch registration failed on resource exhaustion and awaits a retry.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.WatchesFailed) }),
+			counter("watches_recovered_total", "Directories registered successfully after a failed attempt was retried.",
+				func(st *watcher.WatcherStats) uint64 { return st.WatchesRecovered }),
+			gauge("fast_lane_queued", "Paths dispatched to the fast priority lane and not yet started.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.FastLaneQueued) }),
+			gauge("bulk_lane_queued", "Paths dispatched to the bulk priority lane and not yet started.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.BulkLaneQueued) }),
+			gauge("aggregation_queue_length", "Events waiting in the aggregation channel.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.AggChanLen) }),
+			gauge("aggregation_queue_capacity", "Capacity of the aggregation channel.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.AggChanCap) }),
+			gauge("aggregation_queue_high_water", "Highest observed aggregation channel length.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.AggChanHighWater) }),
+			gauge("event_queue_length", "FileEvents waiting to be consumed from EventChan.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.EventChanLen) }),
+			gauge("event_queue_capacity", "Capacity of EventChan.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.EventChanCap) }),
+			gauge("workers_in_flight", "Workers currently processing changes.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.InFlightWorkers) }),
+			gauge("workers_max", "Effective worker limit.",
+				func(st *watcher.WatcherStats) float64 { return float64(st.WorkerCount) }),
+			gauge("worker_utilization", "Share of worker capacity spent processing changes during the last tuning interval.",
+				func(st *watcher.WatcherStats) float64 { return st.WorkerUtilization }),
+			{desc: newDesc("worker_busy_seconds_total", "Time workers spent processing changes."), typ: prometheus.CounterValue,
+				value: func(st *watcher.WatcherStats) float64 { return st.WorkerBusyTime.Seconds() }},
+			counter("worker_waits_total", "Flushes that had to wait for a free worker.",
+				func(st *watcher.WatcherStats) uint64 { return st.WorkerWaits }),
+			gauge("change_duration_max_seconds", "Longest time spent processing a single change.",
+				func(st *watcher.WatcherStats) float64 { return st.MaxChangeDuration.Seconds() }),
+		},
+		batchLatency: newDesc("batch_duration_seconds", "Time to process one flush batch."),
+		hashLatency:  newDesc("hash_duration_seconds", "Time to hash one file."),
+		eventLatency: newDesc("event_latency_seconds", "Time from the first filesystem event for a path to its change being processed."),
+	}
+	return c
+}
+
+// Describe 实现 prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.metrics {
+		ch <- m.desc
+	}
+	ch <- c.batchLatency
+	ch <- c.hashLatency
+	ch <- c.eventLatency
+}
+
+// Collect 实现 prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	st := c.w.Stats()
+	for _, m := range c.metrics {
+		ch <- prometheus.MustNewConstMetric(m.desc, m.typ, m.value(&st))
+	}
+	ch <- constHistogram(c.batchLatency, st.BatchLatency)
+	ch <- constHistogram(c.hashLatency, st.HashLatency)
+	ch <- constHistogram(c.eventLatency, st.EventLatency)
+}
+
+// constHistogram 把 watcher.HistogramSnapshot 转换为 Prometheus 直方图(累计分桶，单位秒)
+func constHistogram(desc *prometheus.Desc, h watcher.HistogramSnapshot) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.Bounds))
+	var cumulative uint64
+	for i, b := range h.Bounds {
+		cumulative += h.Counts[i]
+		buckets[b.Seconds()] = cumulative
+	}
+	return prometheus.MustNewConstHistogram(desc, h.Count, h.Sum.Seconds(), buckets)
+}
+
+// boolGauge 把布尔值转换为 0/1
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package watcherprom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/shuakami/watcher"
+)
+
+// newWatcher 创建一个监控临时目录的 Watcher(不启动)
+func newWatcher(t *testing.T) *watcher.Watcher {
+	t.Helper()
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{WatchPaths: []string{t.TempDir()}})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	return w
+}
+
+// TestCollectorGather 测试指标导出与多 Watcher 注册
+func TestCollectorGather(t *testing.T) {
+	w1, w2 := newWatcher(t), newWatcher(t)
+	file := filepath.Join(w1.Roots()[0], "a.txt")
+	_ = os.WriteFile(file, []byte("hello"), 0644)
+	if _, _, err := w1.RehashFile(file); err != nil {
+		t.Fatalf("RehashFile failed: %v", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewCollector(w1, Options{ConstLabels: prometheus.Labels{"root": "one"}}))
+	if err := reg.Register(NewCollector(w2, Options{ConstLabels: prometheus.Labels{"root": "two"}})); err != nil {
+		t.Fatalf("registering a second watcher with different labels failed: %v", err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	byName := make(map[string]int)
+	var hashedBytes float64
+	for _, mf := range families {
+		byName[mf.GetName()] = len(mf.GetMetric())
+		if mf.GetName() == "watcher_hashed_bytes_total" {
+			for _, m := range mf.GetMetric() {
+				hashedBytes += m.GetCounter().GetValue()
+			}
+		}
+	}
+	for _, name := range []string{"watcher_snapshots_created_total", "watcher_snapshots", "watcher_hash_duration_seconds"} {
+		if byName[name] != 2 {
+			t.Errorf("%s: got %d series; want 2", name, byName[name])
+		}
+	}
+	if hashedBytes != 5 {
+		t.Errorf("watcher_hashed_bytes_total = %v; want 5", hashedBytes)
+	}
+}
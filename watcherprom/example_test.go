@@ -0,0 +1,29 @@
+package watcherprom_test
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watcherprom"
+)
+
+// Example 展示如何把 Watcher 的指标注册到 Prometheus 并通过 promhttp 暴露
+func Example() {
+	w, err := watcher.NewWatcher(watcher.ConfigWatcher{WatchPaths: []string{"/srv/data"}})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(watcherprom.NewCollector(w, watcherprom.Options{
+		Namespace:   "myapp",
+		ConstLabels: prometheus.Labels{"root": "/srv/data"},
+	}))
+
+	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	// log.Fatal(http.ListenAndServe(":9100", nil))
+}
@@ -0,0 +1,22 @@
+module github.com/shuakami/watcher/watcherprom
+
+go 1.21
+
+require (
+	github.com/prometheus/client_golang v1.19.1
+	github.com/shuakami/watcher v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
+
+// 本地开发时使用仓库中的 watcher，发布时去掉并依赖已发布的版本
+replace github.com/shuakami/watcher => ../
@@ -0,0 +1,173 @@
+// Package watchertest 提供编写 watcher 相关测试的辅助工具
+//
+// FakeClock 实现 watcher.Clock，时间只在调用 Advance/Set 时前进，
+// 使快照ID、CreatedAt 与防抖(Debounce)行为可以确定地测试，而不必依赖真实的等待。
+// MemFS 实现 watcher.FS，其 Source() 实现 watcher.EventSource，文件内容与事件都由测试直接构造，
+// 不需要真实的临时目录。
+// Harness 把两者与 Watcher 组合在一起，以同步的方式驱动事件管线并断言事件与快照。
+// Workload(SteadyWrites、CheckoutStorm、DeepTree)是可复现的合成负载，供基准测试比较配置与版本。
+package watchertest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shuakami/watcher"
+)
+
+// FakeClock 是可手动推进的 watcher.Clock，并发安全
+//
+// 定时器与 Sleep 只在 Advance/Set 推进时间时触发；与 time.Ticker 相同，
+// 定时器通道缓冲为1，一次推进跨越多个周期时多余的 tick 被丢弃
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	tickers []*fakeTicker
+	sleeps  []*fakeSleep
+}
+
+// fakeTicker 是 FakeClock 创建的定时器
+type fakeTicker struct {
+	c      *FakeClock
+	ch     chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+// fakeSleep 是一个等待中的 Sleep 调用
+type fakeSleep struct {
+	until time.Time
+	done  chan struct{}
+}
+
+var _ watcher.Clock = (*FakeClock)(nil)
+
+// NewFakeClock 返回一个从 start 开始的 FakeClock，start 为零值时使用 2000-01-01 UTC
+func NewFakeClock(start time.Time) *FakeClock {
+	if start.IsZero() {
+		start = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now 返回当前的模拟时间
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker 创建一个周期为 d 的定时器，d 必须大于0
+func (c *FakeClock) NewTicker(d time.Duration) watcher.Ticker {
+	if d <= 0 {
+		panic("watchertest: non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{c: c, ch: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Sleep 阻塞直到模拟时间推进了 d，d<=0 时立即返回
+func (c *FakeClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.mu.Lock()
+	s := &fakeSleep{until: c.now.Add(d), done: make(chan struct{})}
+	c.sleeps = append(c.sleeps, s)
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	<-s.done
+}
+
+// Advance 把时间推进 d，并触发期间到期的定时器与 Sleep
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(c.now.Add(d))
+}
+
+// Set 把时间设置为 t，t 早于当前时间时只修改 Now 的返回值，不触发任何定时器
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(t)
+}
+
+// Waiters 返回活跃的定时器与等待中的 Sleep 数
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tickers) + len(c.sleeps)
+}
+
+// BlockUntil 阻塞直到活跃的定时器与等待中的 Sleep 合计至少 n 个
+//
+// Watcher 的部分定时器在后台goroutine中创建，Start 之后先 BlockUntil 再 Advance 可避免 tick 被错过
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.tickers)+len(c.sleeps) < n {
+		c.cond.Wait()
+	}
+}
+
+// setLocked 设置时间并触发到期的定时器与 Sleep，调用方需持有 c.mu
+func (c *FakeClock) setLocked(t time.Time) {
+	if t.Before(c.now) {
+		c.now = t
+		return
+	}
+	c.now = t
+
+	for _, tk := range c.tickers {
+		if tk.next.After(t) {
+			continue
+		}
+		select {
+		case tk.ch <- tk.next:
+		default:
+		}
+		// 跳过被丢弃的周期，下一次在 t 之后的第一个周期点触发
+		missed := t.Sub(tk.next)/tk.period + 1
+		tk.next = tk.next.Add(missed * tk.period)
+	}
+
+	sort.Slice(c.sleeps, func(i, j int) bool { return c.sleeps[i].until.Before(c.sleeps[j].until) })
+	n := 0
+	for _, s := range c.sleeps {
+		if s.until.After(t) {
+			break
+		}
+		close(s.done)
+		n++
+	}
+	c.sleeps = c.sleeps[n:]
+	c.cond.Broadcast()
+}
+
+// C 返回投递 tick 的通道
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+// Stop 停止定时器，不关闭通道
+func (t *fakeTicker) Stop() {
+	c := t.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, tk := range c.tickers {
+		if tk == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			break
+		}
+	}
+	c.cond.Broadcast()
+}
@@ -0,0 +1,70 @@
+package watchertest
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFakeClockTicker 测试定时器只在推进时间时触发，跨越多个周期时只投递一次
+func TestFakeClockTicker(t *testing.T) {
+	c := NewFakeClock(time.Time{})
+	start := c.Now()
+	tk := c.NewTicker(10 * time.Millisecond)
+
+	c.Advance(5 * time.Millisecond)
+	select {
+	case <-tk.C():
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	c.Advance(30 * time.Millisecond)
+	if got := <-tk.C(); !got.Equal(start.Add(10 * time.Millisecond)) {
+		t.Errorf("tick time = %v", got)
+	}
+	select {
+	case <-tk.C():
+		t.Fatal("missed ticks should be dropped")
+	default:
+	}
+
+	c.Advance(5 * time.Millisecond) // 40ms
+	if got := <-tk.C(); !got.Equal(start.Add(40 * time.Millisecond)) {
+		t.Errorf("tick time = %v", got)
+	}
+
+	tk.Stop()
+	if n := c.Waiters(); n != 0 {
+		t.Errorf("Waiters = %d after Stop", n)
+	}
+	c.Advance(time.Second)
+	select {
+	case <-tk.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+// TestFakeClockSleep 测试 Sleep 在时间推进到期后返回
+func TestFakeClockSleep(t *testing.T) {
+	c := NewFakeClock(time.Time{})
+	done := make(chan struct{})
+	go func() {
+		c.Sleep(time.Minute)
+		close(done)
+	}()
+	c.BlockUntil(1)
+
+	c.Advance(30 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("Sleep returned early")
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.Advance(30 * time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Sleep did not return")
+	}
+}
@@ -0,0 +1,32 @@
+package watchertest_test
+
+import (
+	"testing"
+
+	"github.com/shuakami/watcher"
+	"github.com/shuakami/watcher/watchertest"
+)
+
+// 在测试函数中使用 Harness：文件操作、推进防抖周期、断言事件与快照，全程不需要 sleep
+func ExampleHarness() {
+	var t *testing.T // 由 go test 传入的测试参数
+
+	h := watchertest.NewHarness(t, watcher.WithIgnorePatterns("**/*.tmp"))
+
+	h.Touch("config/app.yaml", "port: 8080")
+	h.Touch("config/app.yaml.tmp", "scratch")
+	h.AdvanceDebounce()
+
+	h.ExpectEvent(t, watchertest.Matcher{Path: "config", Op: watcher.OpCreate})
+	h.ExpectEvent(t, watchertest.Matcher{
+		Path: "config/app.yaml",
+		Op:   watcher.OpCreate,
+		Hash: watchertest.SHA256("port: 8080"),
+	})
+	h.ExpectNoEvent(t)
+	h.ExpectAbsent(t, "config/app.yaml.tmp")
+
+	h.Remove("config/app.yaml")
+	h.AdvanceDebounce()
+	h.ExpectEvent(t, watchertest.Matcher{Path: "config/app.yaml", Op: watcher.OpRemove})
+}
@@ -0,0 +1,359 @@
+package watchertest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuakami/watcher"
+)
+
+// harnessTimeout 是 Harness 等待后台goroutine追上进度的真实时间上限，超过即判定测试失败
+const harnessTimeout = 5 * time.Second
+
+// harnessSubscriptionBuffer 是 Harness 内部订阅的缓冲大小，足以容纳单个测试产生的全部事件
+const harnessSubscriptionBuffer = 1 << 16
+
+// Harness 把 Watcher 运行在 FakeClock 与 MemFS 之上，以同步的方式驱动事件管线
+//
+// 文件操作(Touch/Mkdir/Remove/Rename/Chmod)返回时事件已进入合并表；AdvanceDebounce 推进一个防抖周期
+// 并等待该批次全部处理完成，之后 ExpectEvent/ExpectFile 等断言看到的就是确定的结果，测试中不需要 sleep
+//
+// 事件通过内部订阅收集，因此 Harness 总是开启 DisableEventChan；删除或移走监控根本身不受支持
+type Harness struct {
+	W     *watcher.Watcher
+	Clock *FakeClock
+	FS    *MemFS
+	// Root 是唯一的监控根，方法中的相对路径都相对于它
+	Root string
+
+	t        testing.TB
+	src      *memSource
+	sub      *watcher.Subscription
+	debounce time.Duration
+	pending  []watcher.FileEvent // 已收到但尚未被 ExpectEvent 认领的事件
+}
+
+// Matcher 描述 ExpectEvent 期望的事件，零值字段不参与比较
+type Matcher struct {
+	Path string          // 相对 Root 的路径(绝对路径原样使用)
+	Op   watcher.EventOp // 事件的 Kind 必须包含其中全部操作位
+	Hash string          // 变更后的内容哈希；删除事件的 NewMeta 为nil，不应设置
+}
+
+// NewHarness 创建并启动一个 Harness，测试结束时自动停止
+//
+// opts 追加在 Harness 自身的选项(WithClock、WithFS、WithDisableEventChan)之后；
+// 开启 WithScanOnStart 时会等待基线快照提交完成再返回
+func NewHarness(t testing.TB, opts ...watcher.Option) *Harness {
+	t.Helper()
+	clock := NewFakeClock(time.Time{})
+	mfs := NewMemFS(clock.Now)
+	root := filepath.Join(string(filepath.Separator), "watch")
+	if err := mfs.MkdirAll(root); err != nil {
+		t.Fatalf("watchertest: %v", err)
+	}
+	src := mfs.Source()
+
+	// 在副本上应用一遍选项，得到 Watcher 实际使用的防抖周期与需要等待的定时器数量
+	var probe watcher.ConfigWatcher
+	for _, opt := range opts {
+		_ = opt(&probe)
+	}
+	debounce := probe.Debounce
+	if debounce <= 0 {
+		debounce = 10 * time.Millisecond
+	}
+	tickers := 2 // 合并定时器与监控根巡检
+	if probe.AuditWriter != nil || probe.AuditPath != "" {
+		tickers++
+	}
+
+	all := append([]watcher.Option{
+		watcher.WithDebounce(debounce),
+		watcher.WithClock(clock),
+		watcher.WithFS(mfs, src),
+		watcher.WithDisableEventChan(),
+	}, opts...)
+	w, err := watcher.NewWatcherWithOptions([]string{root}, all...)
+	if err != nil {
+		t.Fatalf("watchertest: NewWatcher failed: %v", err)
+	}
+	h := &Harness{
+		W: w, Clock: clock, FS: mfs, Root: root,
+		t: t, src: src.(*memSource), sub: w.Subscribe(harnessSubscriptionBuffer), debounce: debounce,
+	}
+	t.Cleanup(h.Close)
+	if err := w.Start(); err != nil {
+		t.Fatalf("watchertest: Start failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), harnessTimeout)
+	defer cancel()
+	if err := w.WaitReady(ctx); err != nil {
+		t.Fatalf("watchertest: watcher not ready: %v", err)
+	}
+	clock.BlockUntil(tickers)
+	return h
+}
+
+// Close 停止 Watcher 与内部订阅，可重复调用；NewHarness 已通过 t.Cleanup 注册
+func (h *Harness) Close() {
+	h.sub.Close()
+	h.W.Stop()
+}
+
+// Path 把相对 Root 的路径转换为 Watcher 中使用的绝对路径，绝对路径原样返回
+func (h *Harness) Path(rel string) string {
+	if filepath.IsAbs(rel) || strings.HasPrefix(rel, string(filepath.Separator)) {
+		return filepath.Clean(rel)
+	}
+	return filepath.Join(h.Root, filepath.FromSlash(rel))
+}
+
+// Touch 写入文件内容(不存在时创建，缺失的上级目录逐级创建)
+func (h *Harness) Touch(rel, content string) {
+	h.t.Helper()
+	p := h.Path(rel)
+	h.mkdirs(filepath.Dir(p))
+	if err := h.FS.WriteFile(p, []byte(content)); err != nil {
+		h.t.Fatalf("watchertest: Touch %s: %v", rel, err)
+	}
+	h.settle()
+}
+
+// Mkdir 逐级创建目录，每一级都等待 Watcher 注册监控后再创建下一级
+func (h *Harness) Mkdir(rel string) {
+	h.t.Helper()
+	h.mkdirs(h.Path(rel))
+}
+
+// Remove 删除文件或整个目录树
+func (h *Harness) Remove(rel string) {
+	h.t.Helper()
+	if err := h.FS.RemoveAll(h.Path(rel)); err != nil {
+		h.t.Fatalf("watchertest: Remove %s: %v", rel, err)
+	}
+	h.settle()
+}
+
+// Rename 重命名文件或目录
+func (h *Harness) Rename(oldRel, newRel string) {
+	h.t.Helper()
+	if err := h.FS.Rename(h.Path(oldRel), h.Path(newRel)); err != nil {
+		h.t.Fatalf("watchertest: Rename %s -> %s: %v", oldRel, newRel, err)
+	}
+	h.settle()
+}
+
+// Chmod 修改权限位
+func (h *Harness) Chmod(rel string, mode fs.FileMode) {
+	h.t.Helper()
+	if err := h.FS.Chmod(h.Path(rel), mode); err != nil {
+		h.t.Fatalf("watchertest: Chmod %s: %v", rel, err)
+	}
+	h.settle()
+}
+
+// AdvanceDebounce 推进一个防抖周期，并等待这次 flush 的全部路径处理完成、事件投递完毕
+func (h *Harness) AdvanceDebounce() {
+	h.t.Helper()
+	h.settle()
+	before := h.W.Stats().FlushCycles
+	h.Clock.Advance(h.debounce)
+	h.waitUntil("flush", func() bool { return h.W.Stats().FlushCycles > before })
+	h.waitUntil("batch completion", func() bool {
+		st := h.W.Stats()
+		return st.BatchesCompleted == st.BatchesProcessed
+	})
+	h.collect()
+}
+
+// ExpectEvent 断言已收到一个与 m 匹配的事件并返回它
+//
+// 同一批次内事件的顺序不确定，因此在全部未认领的事件中查找；未找到时列出每个候选与 m 的差异
+func (h *Harness) ExpectEvent(t testing.TB, m Matcher) watcher.FileEvent {
+	t.Helper()
+	h.collect()
+	for i, ev := range h.pending {
+		if len(h.mismatch(m, ev)) == 0 {
+			h.pending = append(h.pending[:i], h.pending[i+1:]...)
+			return ev
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "no event matches %s", h.describe(m))
+	if len(h.pending) == 0 {
+		b.WriteString("\nno pending events (missing AdvanceDebounce?)")
+	}
+	for _, ev := range h.pending {
+		fmt.Fprintf(&b, "\n  %s: %s", ev, strings.Join(h.mismatch(m, ev), "; "))
+	}
+	t.Fatal(b.String())
+	return watcher.FileEvent{}
+}
+
+// ExpectNoEvent 断言没有未认领的事件
+func (h *Harness) ExpectNoEvent(t testing.TB) {
+	t.Helper()
+	h.collect()
+	if len(h.pending) == 0 {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "expected no events, got %d:", len(h.pending))
+	for _, ev := range h.pending {
+		fmt.Fprintf(&b, "\n  %s", ev)
+	}
+	t.Fatal(b.String())
+}
+
+// Events 返回并清空全部未认领的事件
+func (h *Harness) Events() []watcher.FileEvent {
+	h.collect()
+	out := h.pending
+	h.pending = nil
+	return out
+}
+
+// ExpectFile 断言当前快照中存在 rel 且其哈希为 hash，hash 为空时只检查存在
+func (h *Harness) ExpectFile(t testing.TB, rel, hash string) *watcher.FileMetadata {
+	t.Helper()
+	snap := h.W.GetCurrentSnapshot()
+	if snap == nil {
+		t.Fatalf("ExpectFile %s: no current snapshot", rel)
+	}
+	meta, ok := snap.Files[h.Path(rel)]
+	if !ok {
+		t.Fatalf("ExpectFile %s: not in snapshot %s (%d files)", rel, snap.ID, len(snap.Files))
+	}
+	if hash != "" && meta.Hash != hash {
+		t.Fatalf("ExpectFile %s: hash mismatch in snapshot %s\n got: %s\nwant: %s", rel, snap.ID, meta.Hash, hash)
+	}
+	return meta
+}
+
+// ExpectAbsent 断言当前快照中不存在 rel
+func (h *Harness) ExpectAbsent(t testing.TB, rel string) {
+	t.Helper()
+	snap := h.W.GetCurrentSnapshot()
+	if snap == nil {
+		return
+	}
+	if meta, ok := snap.Files[h.Path(rel)]; ok {
+		t.Fatalf("ExpectAbsent %s: present in snapshot %s (hash %s, size %d)", rel, snap.ID, meta.Hash, meta.Size)
+	}
+}
+
+// SHA256 返回 content 的十六进制 SHA-256，即默认哈希算法下 FileMetadata.Hash 的值
+func SHA256(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// mkdirs 从 Root 开始逐级创建缺失的目录
+//
+// 新目录的监控由 Watcher 收到 Create 事件后注册，一次性创建多级目录时深层目录的事件会丢失，
+// 因此每创建一级都等待事件被处理
+func (h *Harness) mkdirs(dir string) {
+	h.t.Helper()
+	rel, err := filepath.Rel(h.Root, dir)
+	if err != nil || rel == "." {
+		return
+	}
+	cur := h.Root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		if _, err := h.FS.Stat(cur); err == nil {
+			continue
+		}
+		if err := h.FS.MkdirAll(cur); err != nil {
+			h.t.Fatalf("watchertest: Mkdir %s: %v", cur, err)
+		}
+		h.settle()
+	}
+}
+
+// settle 等待已投递给 Watcher 的文件系统事件全部被读取并进入合并表(或被忽略、作为本进程写入丢弃)
+func (h *Harness) settle() {
+	h.t.Helper()
+	h.waitUntil("events to be aggregated", func() bool {
+		st := h.W.Stats()
+		return st.EventsReceived == h.src.sent.Load() &&
+			st.EventsAggregated+st.EventsIgnored+st.SelfSuppressed >= st.EventsReceived
+	})
+}
+
+// waitUntil 轮询 cond 直到成立，超过 harnessTimeout 则判定测试失败
+func (h *Harness) waitUntil(what string, cond func() bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(harnessTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("watchertest: timed out waiting for %s (stats %+v)", what, h.W.Stats())
+		}
+		time.Sleep(50 * time.Microsecond)
+	}
+}
+
+// collect 把订阅中已到达的事件移入 pending
+func (h *Harness) collect() {
+	for {
+		select {
+		case ev, ok := <-h.sub.C:
+			if !ok {
+				return
+			}
+			h.pending = append(h.pending, ev)
+		default:
+			if n := h.sub.Dropped(); n > 0 {
+				h.t.Fatalf("watchertest: %d events dropped by the harness subscription", n)
+			}
+			return
+		}
+	}
+}
+
+// mismatch 返回 ev 与 m 不一致的字段说明，完全匹配时返回nil
+func (h *Harness) mismatch(m Matcher, ev watcher.FileEvent) []string {
+	var diffs []string
+	if m.Path != "" && ev.FilePath != h.Path(m.Path) {
+		diffs = append(diffs, fmt.Sprintf("path %s != %s", ev.FilePath, h.Path(m.Path)))
+	}
+	if m.Op != 0 && ev.Kind&m.Op != m.Op {
+		diffs = append(diffs, fmt.Sprintf("op %s lacks %s", ev.Kind, m.Op&^ev.Kind))
+	}
+	if m.Hash != "" {
+		got := ""
+		if ev.NewMeta != nil {
+			got = ev.NewMeta.Hash
+		}
+		if got != m.Hash {
+			diffs = append(diffs, fmt.Sprintf("hash %q != %q", got, m.Hash))
+		}
+	}
+	return diffs
+}
+
+// describe 返回 m 的可读形式
+func (h *Harness) describe(m Matcher) string {
+	var parts []string
+	if m.Op != 0 {
+		parts = append(parts, m.Op.String())
+	}
+	if m.Path != "" {
+		parts = append(parts, h.Path(m.Path))
+	}
+	if m.Hash != "" {
+		parts = append(parts, "hash "+m.Hash)
+	}
+	if len(parts) == 0 {
+		return "{any}"
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
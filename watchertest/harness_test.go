@@ -0,0 +1,102 @@
+package watchertest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shuakami/watcher"
+)
+
+// recordingTB 记录失败信息而不终止测试，用于检查断言的报错内容
+type recordingTB struct {
+	testing.TB
+	msg string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Fatal(args ...any) {
+	for _, a := range args {
+		r.msg += a.(string)
+	}
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.msg = format
+}
+
+// TestHarnessLifecycle 测试创建、修改、删除文件的完整流程与快照断言
+func TestHarnessLifecycle(t *testing.T) {
+	h := NewHarness(t)
+
+	writeAt := h.Clock.Now()
+	h.Touch("a.txt", "hello")
+	h.AdvanceDebounce()
+	ev := h.ExpectEvent(t, Matcher{Path: "a.txt", Op: watcher.OpCreate, Hash: SHA256("hello")})
+	if !ev.NewMeta.ModTime.Equal(writeAt) {
+		t.Errorf("ModTime = %v, want fake clock time at write %v", ev.NewMeta.ModTime, writeAt)
+	}
+	h.ExpectNoEvent(t)
+	h.ExpectFile(t, "a.txt", SHA256("hello"))
+
+	h.Touch("a.txt", "bye")
+	h.AdvanceDebounce()
+	h.ExpectEvent(t, Matcher{Path: "a.txt", Op: watcher.OpWrite, Hash: SHA256("bye")})
+
+	h.Remove("a.txt")
+	h.AdvanceDebounce()
+	h.ExpectEvent(t, Matcher{Path: "a.txt", Op: watcher.OpRemove})
+	h.ExpectNoEvent(t)
+	h.ExpectAbsent(t, "a.txt")
+}
+
+// TestHarnessNestedDirs 测试逐级创建的目录都被监控，深层文件的事件不会丢失
+func TestHarnessNestedDirs(t *testing.T) {
+	h := NewHarness(t)
+
+	h.Touch("x/y/z/deep.txt", "d")
+	h.AdvanceDebounce()
+	h.ExpectEvent(t, Matcher{Path: "x/y/z/deep.txt", Op: watcher.OpCreate})
+	for _, dir := range []string{"x", "x/y", "x/y/z"} {
+		h.ExpectEvent(t, Matcher{Path: dir, Op: watcher.OpCreate})
+	}
+	h.ExpectNoEvent(t)
+
+	h.Touch("x/y/z/deep.txt", "e")
+	h.AdvanceDebounce()
+	h.ExpectEvent(t, Matcher{Path: "x/y/z/deep.txt", Op: watcher.OpWrite, Hash: SHA256("e")})
+}
+
+// TestHarnessScanOnStart 测试开启初始扫描时等待基线快照
+func TestHarnessScanOnStart(t *testing.T) {
+	h := NewHarness(t, watcher.WithScanOnStart(nil))
+	if h.W.GetCurrentSnapshot() == nil {
+		t.Fatal("expected a baseline snapshot")
+	}
+	h.Touch("b.txt", "b")
+	h.AdvanceDebounce()
+	h.ExpectEvent(t, Matcher{Path: "b.txt"})
+}
+
+// TestHarnessExpectEventDiff 测试匹配失败时列出每个候选事件的差异
+func TestHarnessExpectEventDiff(t *testing.T) {
+	h := NewHarness(t)
+	h.Touch("a.txt", "hello")
+	h.AdvanceDebounce()
+
+	rec := &recordingTB{TB: t}
+	h.ExpectEvent(rec, Matcher{Path: "a.txt", Op: watcher.OpRemove, Hash: "beef"})
+	for _, want := range []string{"no event matches {REMOVE", "lacks REMOVE", `!= "beef"`} {
+		if !strings.Contains(rec.msg, want) {
+			t.Errorf("failure message missing %q:\n%s", want, rec.msg)
+		}
+	}
+	// 未匹配的事件仍保留
+	h.ExpectEvent(t, Matcher{Path: "a.txt"})
+
+	rec = &recordingTB{TB: t}
+	h.ExpectEvent(rec, Matcher{})
+	if !strings.Contains(rec.msg, "missing AdvanceDebounce") {
+		t.Errorf("expected hint about AdvanceDebounce, got:\n%s", rec.msg)
+	}
+}
@@ -0,0 +1,486 @@
+package watchertest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/shuakami/watcher"
+)
+
+// memEventBuffer 是 MemFS 事件通道的缓冲大小，写满后与 inotify 一样丢弃事件并报告 fsnotify.ErrEventOverflow
+const memEventBuffer = 4096
+
+// MemFS 是内存中的文件系统，实现 watcher.FS；Source() 返回与之配套的 watcher.EventSource
+//
+// 修改方法(MkdirAll、WriteFile、RemoveAll、Rename、Chmod)像 inotify 一样，为已监控目录的直接子项
+// (以及被监控的目录自身)产生事件；Inject/InjectError 可直接注入任意事件或错误
+// 路径按 filepath.Clean 规范化，修改时间取自 NewMemFS 传入的 now
+// 并发安全
+type MemFS struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	nodes   map[string]*memNode
+	sources []*memSource
+}
+
+// memNode 是一个文件或目录
+type memNode struct {
+	data    []byte
+	dir     bool
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+var _ watcher.FS = (*MemFS)(nil)
+
+// NewMemFS 创建一个空的内存文件系统，now 为修改时间的来源(如 FakeClock.Now)，nil 时使用 time.Now
+func NewMemFS(now func() time.Time) *MemFS {
+	if now == nil {
+		now = time.Now
+	}
+	return &MemFS{now: now, nodes: make(map[string]*memNode)}
+}
+
+// Source 创建一个从该文件系统接收事件的 watcher.EventSource
+//
+// 每个 Watcher 需要各自的 Source(Watcher 关闭时会关闭它)；多个 Source 互不影响
+func (m *MemFS) Source() watcher.EventSource {
+	s := &memSource{
+		fs:      m,
+		watched: make(map[string]bool),
+		events:  make(chan watcher.RawEvent, memEventBuffer),
+		errs:    make(chan error, memEventBuffer),
+	}
+	m.mu.Lock()
+	m.sources = append(m.sources, s)
+	m.mu.Unlock()
+	return s
+}
+
+// MkdirAll 创建目录及其缺失的上级目录，每个新建的目录产生一个 Create 事件
+func (m *MemFS) MkdirAll(path string) error {
+	path = filepath.Clean(path)
+	m.mu.Lock()
+	var dirs []string
+	for p := path; ; p = filepath.Dir(p) {
+		if n, ok := m.nodes[p]; ok {
+			if !n.dir {
+				m.mu.Unlock()
+				return &fs.PathError{Op: "mkdir", Path: p, Err: errors.New("not a directory")}
+			}
+			break
+		}
+		dirs = append(dirs, p)
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	var evs []watcher.RawEvent
+	for i := len(dirs) - 1; i >= 0; i-- {
+		m.nodes[dirs[i]] = &memNode{dir: true, mode: fs.ModeDir | 0755, modTime: m.now()}
+		m.touchParentLocked(dirs[i])
+		evs = append(evs, watcher.RawEvent{Name: dirs[i], Op: watcher.OpCreate})
+	}
+	m.mu.Unlock()
+	m.deliver(evs...)
+	return nil
+}
+
+// WriteFile 写入文件内容，上级目录必须已存在
+//
+// 新文件产生 Create(内容非空时再产生 Write)，已有文件产生 Write
+func (m *MemFS) WriteFile(path string, data []byte) error {
+	path = filepath.Clean(path)
+	m.mu.Lock()
+	if p, ok := m.nodes[filepath.Dir(path)]; !ok || !p.dir {
+		m.mu.Unlock()
+		return &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	n, exists := m.nodes[path]
+	if exists && n.dir {
+		m.mu.Unlock()
+		return &fs.PathError{Op: "open", Path: path, Err: errors.New("is a directory")}
+	}
+	var evs []watcher.RawEvent
+	if !exists {
+		n = &memNode{mode: 0644}
+		m.nodes[path] = n
+		m.touchParentLocked(path)
+		evs = append(evs, watcher.RawEvent{Name: path, Op: watcher.OpCreate})
+	}
+	n.data = append([]byte(nil), data...)
+	n.modTime = m.now()
+	if exists || len(data) > 0 {
+		evs = append(evs, watcher.RawEvent{Name: path, Op: watcher.OpWrite})
+	}
+	m.mu.Unlock()
+	m.deliver(evs...)
+	return nil
+}
+
+// RemoveAll 删除路径及其子树，按自底向上的顺序为每个条目产生 Remove 事件；路径不存在时不做任何事
+func (m *MemFS) RemoveAll(path string) error {
+	path = filepath.Clean(path)
+	m.mu.Lock()
+	if _, ok := m.nodes[path]; !ok {
+		m.mu.Unlock()
+		return nil
+	}
+	removed := m.subtreeLocked(path)
+	// 子路径总比父路径长，逆序即自底向上
+	evs := make([]watcher.RawEvent, 0, len(removed))
+	for i := len(removed) - 1; i >= 0; i-- {
+		delete(m.nodes, removed[i])
+		evs = append(evs, watcher.RawEvent{Name: removed[i], Op: watcher.OpRemove})
+	}
+	m.touchParentLocked(path)
+	m.mu.Unlock()
+	m.deliver(evs...)
+	m.unwatch(path)
+	return nil
+}
+
+// Rename 把 oldpath(及其子树)移动到 newpath，产生 oldpath 的 Rename 与 newpath 的 Create 事件
+//
+// 与 inotify 不同，oldpath 子树上的监控直接失效，需要由 Watcher 为 newpath 重新注册
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	m.mu.Lock()
+	if _, ok := m.nodes[oldpath]; !ok {
+		m.mu.Unlock()
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if p, ok := m.nodes[filepath.Dir(newpath)]; !ok || !p.dir {
+		m.mu.Unlock()
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if _, ok := m.nodes[newpath]; ok {
+		for _, p := range m.subtreeLocked(newpath) {
+			delete(m.nodes, p)
+		}
+	}
+	for _, p := range m.subtreeLocked(oldpath) {
+		rel, _ := filepath.Rel(oldpath, p)
+		m.nodes[filepath.Join(newpath, rel)] = m.nodes[p]
+		delete(m.nodes, p)
+	}
+	m.touchParentLocked(oldpath)
+	m.touchParentLocked(newpath)
+	m.mu.Unlock()
+	m.deliver(watcher.RawEvent{Name: oldpath, Op: watcher.OpRename}, watcher.RawEvent{Name: newpath, Op: watcher.OpCreate})
+	m.unwatch(oldpath)
+	return nil
+}
+
+// Chmod 修改权限位，产生 Chmod 事件
+func (m *MemFS) Chmod(path string, mode fs.FileMode) error {
+	path = filepath.Clean(path)
+	m.mu.Lock()
+	n, ok := m.nodes[path]
+	if !ok {
+		m.mu.Unlock()
+		return &fs.PathError{Op: "chmod", Path: path, Err: fs.ErrNotExist}
+	}
+	n.mode = n.mode&fs.ModeType | mode.Perm()
+	m.mu.Unlock()
+	m.deliver(watcher.RawEvent{Name: path, Op: watcher.OpChmod})
+	return nil
+}
+
+// Inject 向所有 Source 直接发送一个事件，不检查监控注册，也不修改文件系统
+func (m *MemFS) Inject(ev watcher.RawEvent) {
+	for _, s := range m.sourceList() {
+		s.send(ev)
+	}
+}
+
+// InjectError 向所有 Source 的错误通道发送 err
+func (m *MemFS) InjectError(err error) {
+	for _, s := range m.sourceList() {
+		s.sendErr(err)
+	}
+}
+
+// Stat 实现 watcher.FS
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memFileInfo{name: filepath.Base(name), n: *n}, nil
+}
+
+// Open 实现 watcher.FS，返回调用时刻内容的副本
+func (m *MemFS) Open(name string) (io.ReadCloser, error) {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.dir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return io.NopCloser(bytes.NewReader(append([]byte(nil), n.data...))), nil
+}
+
+// ReadDir 实现 watcher.FS，按名称排序返回直接子项
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	name = filepath.Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if !n.dir {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: errors.New("not a directory")}
+	}
+	var out []fs.DirEntry
+	for p, c := range m.nodes {
+		if p != name && filepath.Dir(p) == name {
+			out = append(out, fs.FileInfoToDirEntry(memFileInfo{name: filepath.Base(p), n: *c}))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out, nil
+}
+
+// WalkDir 实现 watcher.FS，语义同 filepath.WalkDir
+func (m *MemFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	info, err := m.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = m.walkDir(root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDir 递归遍历 path，与 filepath.walkDir 的处理方式一致
+func (m *MemFS) walkDir(path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	entries, err := m.ReadDir(path)
+	if err != nil {
+		if err = fn(path, d, err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, e := range entries {
+		if err := m.walkDir(filepath.Join(path, e.Name()), e, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// subtreeLocked 返回 path 及其全部子孙路径(按路径排序，父在子前)，调用方需持有 m.mu
+func (m *MemFS) subtreeLocked(path string) []string {
+	var out []string
+	for p := range m.nodes {
+		if p == path || isWithin(p, path) {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// touchParentLocked 更新 path 上级目录的修改时间，调用方需持有 m.mu
+func (m *MemFS) touchParentLocked(path string) {
+	if parent := filepath.Dir(path); parent != path {
+		if n, ok := m.nodes[parent]; ok {
+			n.modTime = m.now()
+		}
+	}
+}
+
+// sourceList 返回当前的 Source 列表
+func (m *MemFS) sourceList() []*memSource {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*memSource(nil), m.sources...)
+}
+
+// deliver 把事件发给监控了其上级目录(或其自身)的 Source
+func (m *MemFS) deliver(evs ...watcher.RawEvent) {
+	for _, s := range m.sourceList() {
+		for _, ev := range evs {
+			if s.watches(ev.Name) {
+				s.send(ev)
+			}
+		}
+	}
+}
+
+// unwatch 让所有 Source 取消 path 子树上的监控(目录已被删除或移走)
+func (m *MemFS) unwatch(path string) {
+	for _, s := range m.sourceList() {
+		s.mu.Lock()
+		for p := range s.watched {
+			if p == path || isWithin(p, path) {
+				delete(s.watched, p)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// isWithin 判断 p 是否位于目录 dir 之下(不含 dir 本身)
+func isWithin(p, dir string) bool {
+	prefix := dir
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	return strings.HasPrefix(p, prefix)
+}
+
+// memFileInfo 实现 fs.FileInfo
+type memFileInfo struct {
+	name string
+	n    memNode
+}
+
+func (fi memFileInfo) Name() string { return fi.name }
+func (fi memFileInfo) Size() int64 {
+	if fi.n.dir {
+		return 0
+	}
+	return int64(len(fi.n.data))
+}
+func (fi memFileInfo) Mode() fs.FileMode  { return fi.n.mode }
+func (fi memFileInfo) ModTime() time.Time { return fi.n.modTime }
+func (fi memFileInfo) IsDir() bool        { return fi.n.dir }
+func (fi memFileInfo) Sys() any           { return nil }
+
+// memSource 是 MemFS 的 watcher.EventSource 实现
+type memSource struct {
+	fs      *MemFS
+	mu      sync.Mutex
+	watched map[string]bool
+	events  chan watcher.RawEvent
+	errs    chan error
+	closed  bool
+	sent    atomic.Uint64 // 已放入 events 的事件数
+}
+
+// Add 注册监控，路径不存在时返回错误
+func (s *memSource) Add(name string) error {
+	name = filepath.Clean(name)
+	if _, err := s.fs.Stat(name); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fsnotify.ErrClosed
+	}
+	s.watched[name] = true
+	return nil
+}
+
+// Remove 取消监控，未注册时返回错误
+func (s *memSource) Remove(name string) error {
+	name = filepath.Clean(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.watched[name] {
+		return fsnotify.ErrNonExistentWatch
+	}
+	delete(s.watched, name)
+	return nil
+}
+
+// WatchList 返回已注册的路径(排序)
+func (s *memSource) WatchList() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.watched))
+	for p := range s.watched {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *memSource) Events() <-chan watcher.RawEvent { return s.events }
+func (s *memSource) Errors() <-chan error            { return s.errs }
+
+// Close 关闭事件与错误通道，可重复调用
+func (s *memSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+		close(s.errs)
+	}
+	return nil
+}
+
+// watches 判断 path 或其上级目录是否已注册监控
+func (s *memSource) watches(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watched[path] || s.watched[filepath.Dir(path)]
+}
+
+// send 非阻塞地发送事件，缓冲已满时报告 fsnotify.ErrEventOverflow
+func (s *memSource) send(ev watcher.RawEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.events <- ev:
+		s.sent.Add(1)
+	default:
+		select {
+		case s.errs <- fsnotify.ErrEventOverflow:
+		default:
+		}
+	}
+}
+
+// sendErr 非阻塞地发送错误
+func (s *memSource) sendErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.errs <- err:
+	default:
+	}
+}
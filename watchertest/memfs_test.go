@@ -0,0 +1,94 @@
+package watchertest
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shuakami/watcher"
+)
+
+// drain 取出通道中已有的事件
+func drain(ch <-chan watcher.RawEvent) []string {
+	var out []string
+	for {
+		select {
+		case ev := <-ch:
+			out = append(out, ev.Op.String()+" "+filepath.ToSlash(ev.Name))
+		default:
+			return out
+		}
+	}
+}
+
+// TestMemFSReadWrite 测试读写、遍历与不存在路径的错误
+func TestMemFSReadWrite(t *testing.T) {
+	m := NewMemFS(nil)
+	root := filepath.FromSlash("/r")
+	_ = m.MkdirAll(filepath.Join(root, "a", "b"))
+	_ = m.WriteFile(filepath.Join(root, "a", "f.txt"), []byte("hi"))
+	_ = m.WriteFile(filepath.Join(root, "z.txt"), nil)
+
+	f, err := m.Open(filepath.Join(root, "a", "f.txt"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if data, _ := io.ReadAll(f); string(data) != "hi" {
+		t.Errorf("content = %q", data)
+	}
+	if _, err := m.Stat(filepath.Join(root, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if err := m.WriteFile(filepath.Join(root, "missing", "x"), nil); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist for missing parent, got %v", err)
+	}
+
+	var walked []string
+	_ = m.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if d.Name() == "b" {
+			return filepath.SkipDir
+		}
+		walked = append(walked, filepath.ToSlash(p))
+		return nil
+	})
+	if got := strings.Join(walked, ","); got != "/r,/r/a,/r/a/f.txt,/r/z.txt" {
+		t.Errorf("walk order = %s", got)
+	}
+}
+
+// TestMemFSEvents 测试只有已监控目录的直接子项产生事件
+func TestMemFSEvents(t *testing.T) {
+	m := NewMemFS(nil)
+	root := filepath.FromSlash("/r")
+	_ = m.MkdirAll(filepath.Join(root, "sub"))
+	src := m.Source()
+	defer src.Close()
+	if err := src.Add(root); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := src.Add(filepath.Join(root, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+
+	_ = m.WriteFile(filepath.Join(root, "a.txt"), []byte("x"))
+	_ = m.WriteFile(filepath.Join(root, "sub", "unwatched.txt"), []byte("x"))
+	_ = m.Chmod(filepath.Join(root, "a.txt"), 0600)
+	_ = m.Rename(filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt"))
+	_ = src.Add(filepath.Join(root, "sub"))
+	_ = m.RemoveAll(filepath.Join(root, "sub"))
+
+	want := []string{
+		"CREATE /r/a.txt", "WRITE /r/a.txt", "CHMOD /r/a.txt",
+		"RENAME /r/a.txt", "CREATE /r/b.txt",
+		"REMOVE /r/sub/unwatched.txt", "REMOVE /r/sub",
+	}
+	if got := drain(src.Events()); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v\nwant %v", got, want)
+	}
+	if list := src.WatchList(); len(list) != 1 || list[0] != root {
+		t.Errorf("removed directory should be unwatched: %v", list)
+	}
+}
@@ -0,0 +1,146 @@
+package watchertest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TreeWriter 是合成负载写入的目标，MemFS 与 OSWriter() 都实现了它
+type TreeWriter interface {
+	MkdirAll(path string) error
+	WriteFile(path string, data []byte) error
+}
+
+var _ TreeWriter = (*MemFS)(nil)
+
+// OSWriter 返回直接写入操作系统文件系统的 TreeWriter
+func OSWriter() TreeWriter {
+	return osWriter{}
+}
+
+// osWriter 是 TreeWriter 的操作系统实现
+type osWriter struct{}
+
+func (osWriter) MkdirAll(path string) error               { return os.MkdirAll(path, 0o755) }
+func (osWriter) WriteFile(path string, data []byte) error { return os.WriteFile(path, data, 0o644) }
+
+// Workload 是一个可复现的合成负载，供基准测试比较不同配置或不同提交的性能
+//
+// Run 在 dir(须已存在)下执行负载，每写完一个文件调用一次 wrote(path, n)，n 为写入的字节数；
+// 同一个 Workload 可以多次运行，每次都应使用新的空目录。文件内容带有递增序号，每次写入都会改变哈希
+type Workload struct {
+	Name string
+	Run  func(tw TreeWriter, dir string, wrote func(path string, n int)) error
+}
+
+// SteadyWrites 先创建 files 个大小为 size 的文件，然后在 d 时间内以每秒 perSecond 次的速率轮流改写它们
+func SteadyWrites(files, size, perSecond int, d time.Duration) Workload {
+	return Workload{
+		Name: fmt.Sprintf("steady-%dfiles-%dps", files, perSecond),
+		Run: func(tw TreeWriter, dir string, wrote func(string, int)) error {
+			g := contentGen{size: size}
+			paths := make([]string, files)
+			for i := range paths {
+				paths[i] = filepath.Join(dir, fmt.Sprintf("f%05d.dat", i))
+				if err := g.write(tw, paths[i], wrote); err != nil {
+					return err
+				}
+			}
+			interval := time.Second / time.Duration(perSecond)
+			start := time.Now()
+			for i := 0; time.Since(start) < d; i++ {
+				if err := g.write(tw, paths[i%files], wrote); err != nil {
+					return err
+				}
+				// 按计划时间而不是上一次写入后的间隔等待，避免写入耗时累积造成速率漂移
+				if wait := time.Until(start.Add(time.Duration(i+1) * interval)); wait > 0 {
+					time.Sleep(wait)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// CheckoutStorm 模拟 git checkout 一类的突发写入：bursts 轮，每轮尽快改写 dirs×filesPerDir 个文件，
+// 轮与轮之间暂停 pause
+func CheckoutStorm(dirs, filesPerDir, size, bursts int, pause time.Duration) Workload {
+	return Workload{
+		Name: fmt.Sprintf("checkout-%dx%d-%dbursts", dirs, filesPerDir, bursts),
+		Run: func(tw TreeWriter, dir string, wrote func(string, int)) error {
+			g := contentGen{size: size}
+			for b := 0; b < bursts; b++ {
+				if b > 0 {
+					time.Sleep(pause)
+				}
+				for d := 0; d < dirs; d++ {
+					sub := filepath.Join(dir, fmt.Sprintf("pkg%03d", d))
+					if err := tw.MkdirAll(sub); err != nil {
+						return err
+					}
+					for f := 0; f < filesPerDir; f++ {
+						if err := g.write(tw, filepath.Join(sub, fmt.Sprintf("src%03d.go", f)), wrote); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// DeepTree 一次性创建深度为 depth、每层 fanout 个子目录的目录树，每个目录下写入 filesPerDir 个文件
+func DeepTree(depth, fanout, filesPerDir, size int) Workload {
+	return Workload{
+		Name: fmt.Sprintf("deeptree-d%d-f%d", depth, fanout),
+		Run: func(tw TreeWriter, dir string, wrote func(string, int)) error {
+			g := contentGen{size: size}
+			var build func(parent string, level int) error
+			build = func(parent string, level int) error {
+				for f := 0; f < filesPerDir; f++ {
+					if err := g.write(tw, filepath.Join(parent, fmt.Sprintf("file%02d.txt", f)), wrote); err != nil {
+						return err
+					}
+				}
+				if level == depth {
+					return nil
+				}
+				for i := 0; i < fanout; i++ {
+					sub := filepath.Join(parent, fmt.Sprintf("d%d", i))
+					if err := tw.MkdirAll(sub); err != nil {
+						return err
+					}
+					if err := build(sub, level+1); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			return build(dir, 0)
+		},
+	}
+}
+
+// contentGen 生成带递增序号的文件内容，保证每次写入的哈希都不同
+type contentGen struct {
+	size int
+	seq  uint64
+}
+
+// write 写入下一份内容并回调 wrote
+func (g *contentGen) write(tw TreeWriter, path string, wrote func(string, int)) error {
+	g.seq++
+	data := make([]byte, max(g.size, 8))
+	binary.LittleEndian.PutUint64(data, g.seq)
+	if err := tw.WriteFile(path, data); err != nil {
+		return err
+	}
+	if wrote != nil {
+		wrote(path, len(data))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package watchertest
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWorkloads 在 MemFS 上运行各负载，检查写入次数与内容各不相同
+func TestWorkloads(t *testing.T) {
+	cases := []struct {
+		wl     Workload
+		writes int // 至少应有的写入次数
+		files  int // 不同文件数
+	}{
+		{SteadyWrites(3, 16, 1000, 20*time.Millisecond), 4, 3},
+		{CheckoutStorm(2, 3, 16, 2, time.Millisecond), 12, 6},
+		{DeepTree(2, 2, 1, 16), 7, 7},
+	}
+	for _, c := range cases {
+		t.Run(c.wl.Name, func(t *testing.T) {
+			m := NewMemFS(nil)
+			dir := filepath.FromSlash("/w")
+			_ = m.MkdirAll(dir)
+			writes := 0
+			seen := make(map[string]bool)
+			contents := make(map[string]bool)
+			err := c.wl.Run(m, dir, func(path string, n int) {
+				writes++
+				seen[path] = true
+				f, err := m.Open(path)
+				if err != nil {
+					t.Fatalf("wrote %s but cannot open it: %v", path, err)
+				}
+				data, _ := io.ReadAll(f)
+				if len(data) != n {
+					t.Errorf("%s: reported %d bytes, file has %d", path, n, len(data))
+				}
+				contents[string(data)] = true
+			})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if writes < c.writes || len(seen) != c.files {
+				t.Errorf("writes = %d (want >= %d), files = %d (want %d)", writes, c.writes, len(seen), c.files)
+			}
+			if len(contents) != writes {
+				t.Errorf("%d distinct contents for %d writes", len(contents), writes)
+			}
+		})
+	}
+}
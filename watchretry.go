@@ -0,0 +1,320 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 注册失败目录的重试
+//
+// 因系统资源耗尽(EMFILE/ENFILE/ENOSPC，见 ErrWatchLimit)注册监控失败的目录记入重试集合，运行期间在 Start、新建目录、
+// 监控根恢复等任何位置的失败都算在内。runWatchRetry 按退避间隔自动重试：第一次在 WatchRetryInterval 之后，
+// 仍有失败时间隔翻倍，最长 watchRetryMaxBackoff；RetryFailedWatches 立即重试并重置退避，适合调大 ulimit 之后调用。
+// 每次重试向 ErrorChan 发送一个 *WatchRetry 通知；注册成功的目录重新注册其下的子目录并对账，补上失败期间错过的变化。
+// 目录被删除、变为忽略或不再是目录时移出集合；重试时因其它原因(如无权限)失败的目录同样移出集合，改为 WatchErrors() 中的永久失败。
+// WatchErrors()、Health 与 WatchCoverage 反映的都是当前仍失败的目录
+
+const (
+	// DefaultWatchRetryInterval 是 ConfigWatcher.WatchRetryInterval 的默认值
+	DefaultWatchRetryInterval = 5 * time.Second
+
+	watchRetryMaxBackoff = 5 * time.Minute // 自动重试的最长间隔(WatchRetryInterval 更大时以其为准)
+)
+
+// WatchRetry 是重试注册失败的目录后发送到 ErrorChan 的通知
+//
+// Recovered 为本次注册成功的目录(有序)，Failures 为仍然失败、之后继续重试的目录，
+// Abandoned 为不再因资源耗尽而失败(如无权限)、不再重试的目录(记入 WatchErrors())；
+// 实现了 Unwrap() []error，仍有因资源耗尽失败的目录时 errors.Is(err, ErrWatchLimit) 成立
+type WatchRetry struct {
+	Recovered []string
+	Failures  []*WatchError
+	Abandoned []*WatchError
+}
+
+// Error 实现 error 接口
+func (e *WatchRetry) Error() string {
+	failed := append(slices.Clip(e.Failures), e.Abandoned...)
+	if len(failed) == 0 {
+		return fmt.Sprintf("watch retry: %d dirs recovered", len(e.Recovered))
+	}
+	msg := fmt.Sprintf("watch retry: %d dirs recovered, %d still failing", len(e.Recovered), len(e.Failures))
+	if len(e.Abandoned) > 0 {
+		msg += fmt.Sprintf(", %d abandoned", len(e.Abandoned))
+	}
+	return fmt.Sprintf("%s (first %s: %v)", msg, failed[0].Path, failed[0].Err)
+}
+
+// Unwrap 返回仍然失败与不再重试的目录
+func (e *WatchRetry) Unwrap() []error {
+	out := make([]error, 0, len(e.Failures)+len(e.Abandoned))
+	for _, f := range e.Failures {
+		out = append(out, f)
+	}
+	for _, f := range e.Abandoned {
+		out = append(out, f)
+	}
+	return out
+}
+
+// watchRetrySet 是等待重试注册监控的目录
+type watchRetrySet struct {
+	mu    sync.Mutex
+	dirs  map[string]error // 目录 -> 最近一次注册失败的错误
+	delay time.Duration    // 当前退避间隔，0 表示下一次按 WatchRetryInterval
+	next  time.Time        // 下一次自动重试的时间，零值表示尚未安排
+	n     atomic.Int64     // len(dirs)
+
+	run       sync.Mutex    // 串行化重试(自动重试与 RetryFailedWatches)
+	recovered atomic.Uint64 // 重试注册成功的目录数
+}
+
+// add 记录注册失败的目录
+func (s *watchRetrySet) add(p string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirs == nil {
+		s.dirs = make(map[string]error)
+	}
+	s.dirs[p] = err
+	s.n.Store(int64(len(s.dirs)))
+}
+
+// remove 移除 p 及其下的目录，返回是否移除了任何目录
+func (s *watchRetrySet) remove(p string) bool {
+	if s.n.Load() == 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := false
+	for d := range s.dirs {
+		if withinRoot(d, p) {
+			delete(s.dirs, d)
+			removed = true
+		}
+	}
+	s.n.Store(int64(len(s.dirs)))
+	return removed
+}
+
+// drop 只移除 p 本身(不含其下的目录)
+func (s *watchRetrySet) drop(p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.dirs, p)
+	s.n.Store(int64(len(s.dirs)))
+}
+
+// list 返回按路径排序的全部目录
+func (s *watchRetrySet) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.dirs))
+	for d := range s.dirs {
+		out = append(out, d)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// errOf 返回目录最近一次注册失败的错误，不在集合中时返回nil
+func (s *watchRetrySet) errOf(p string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dirs[p]
+}
+
+// due 报告自动重试是否到期，第一次调用时从 now 起安排
+func (s *watchRetrySet) due(now time.Time, base time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.dirs) == 0 {
+		s.delay, s.next = 0, time.Time{}
+		return false
+	}
+	if s.next.IsZero() {
+		s.delay = base
+		s.next = now.Add(base)
+	}
+	return !now.Before(s.next)
+}
+
+// backoff 在一次重试之后安排下一次：仍有失败时间隔翻倍，reset 时(手动重试)回到 base
+func (s *watchRetrySet) backoff(now time.Time, base time.Duration, reset bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case len(s.dirs) == 0:
+		s.delay, s.next = 0, time.Time{}
+		return
+	case reset || s.delay == 0:
+		s.delay = base
+	default:
+		s.delay = min(2*s.delay, max(watchRetryMaxBackoff, base))
+	}
+	s.next = now.Add(s.delay)
+}
+
+// isRetryable 判断注册监控的错误是否值得重试：系统资源耗尽，调大上限或释放资源后可能成功
+func isRetryable(err error) bool {
+	return isWatchLimit(err)
+}
+
+// noteWatchResult 按 addWatch 的结果维护重试集合
+func (w *Watcher) noteWatchResult(p string, err error) {
+	switch {
+	case err == nil:
+		if w.watchRetry.n.Load() > 0 {
+			w.watchRetry.remove(p)
+		}
+	case isRetryable(err):
+		w.watchRetry.add(p, err)
+	}
+}
+
+// forgetWatch 在目录被删除或移走后把它及其下的目录移出重试集合与 WatchErrors
+func (w *Watcher) forgetWatch(p string) {
+	if w.watchRetry.remove(p) {
+		w.syncWatchErrs()
+	}
+}
+
+// syncWatchErrs 从 WatchErrors 中去掉已不在重试集合中的可重试失败，不可重试的失败原样保留
+func (w *Watcher) syncWatchErrs() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.watchErrs = slices.DeleteFunc(w.watchErrs, func(f *WatchError) bool {
+		return isRetryable(f.Err) && w.watchRetry.errOf(f.Path) == nil
+	})
+}
+
+// addWatchErrs 把不再重试的失败记入 WatchErrors，替换同一目录原有的记录
+func (w *Watcher) addWatchErrs(failures []*WatchError) {
+	if len(failures) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, f := range failures {
+		w.watchErrs = slices.DeleteFunc(w.watchErrs, func(old *WatchError) bool { return old.Path == f.Path })
+		w.watchErrs = append(w.watchErrs, f)
+	}
+	sort.Slice(w.watchErrs, func(i, j int) bool { return w.watchErrs[i].Path < w.watchErrs[j].Path })
+}
+
+// RetryFailedWatches 立即重试因系统资源耗尽而注册失败的目录，并把自动重试的退避间隔重置为 WatchRetryInterval
+//
+// 返回本次注册成功的目录(有序)；仍有失败时 err 为 *PartialWatchError，其中仍因资源耗尽失败的目录之后继续自动重试，
+// 因其它原因失败的不再重试(见 WatchRetry.Abandoned)。
+// 调大进程的文件描述符或 inotify 上限后调用即可恢复，无需重启；重试结果同样以 *WatchRetry 发送到 ErrorChan。Close 之后返回 ErrStopped
+// 并发安全
+func (w *Watcher) RetryFailedWatches() (recovered []string, err error) {
+	if err := w.beginMutation(); err != nil {
+		return nil, err
+	}
+	defer w.endMutation()
+	r := w.retryWatches(true)
+	if r == nil {
+		return nil, nil
+	}
+	if failed := append(slices.Clip(r.Failures), r.Abandoned...); len(failed) > 0 {
+		err = &PartialWatchError{Failures: failed}
+	}
+	return r.Recovered, err
+}
+
+// runWatchRetry 每隔 WatchRetryInterval 检查一次，退避到期时重试注册失败的目录
+func (w *Watcher) runWatchRetry() {
+	defer w.bgWG.Done()
+	t := w.clock.NewTicker(w.cfg.WatchRetryInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+			if w.watchRetry.due(w.now(), w.cfg.WatchRetryInterval) {
+				w.retryWatches(false)
+			}
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+// retryWatches 重试集合中的全部目录并发送通知，集合为空时返回nil；reset 为 true 时重置退避间隔
+func (w *Watcher) retryWatches(reset bool) *WatchRetry {
+	s := &w.watchRetry
+	s.run.Lock()
+	defer s.run.Unlock()
+	dirs := s.list()
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	r := &WatchRetry{}
+	for _, p := range dirs {
+		// 已删除、被替换为文件或不再需要监控的目录移出集合(stat 的其它错误留给下面的注册)
+		fi, err := w.fs.Stat(p)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && (!fi.IsDir() || w.isIgnored(p))) {
+			s.remove(p)
+			continue
+		}
+		if err := w.addWatch(p); err != nil {
+			if isRetryable(err) {
+				r.Failures = append(r.Failures, &WatchError{Path: p, Err: err})
+			} else {
+				// 不再是资源耗尽(如权限被收回)：重试无益，移出集合
+				s.drop(p)
+				r.Abandoned = append(r.Abandoned, &WatchError{Path: p, Err: err})
+			}
+			continue
+		}
+		r.Recovered = append(r.Recovered, p)
+	}
+	s.recovered.Add(uint64(len(r.Recovered)))
+	s.backoff(w.now(), w.cfg.WatchRetryInterval, reset)
+	w.syncWatchErrs()
+	w.addWatchErrs(r.Abandoned)
+
+	// 失败期间其中新建的子目录没有注册、变化没有事件：补注册并对账
+	for _, p := range topmostPaths(r.Recovered) {
+		w.watchSubtree(p)
+		w.reconcile(p, fmt.Sprintf("Reconcile after watch on %s recovered", p))
+	}
+
+	if len(r.Recovered) == 0 && len(r.Failures) == 0 && len(r.Abandoned) == 0 {
+		return r
+	}
+	if w.emitError(r) {
+		if len(r.Failures) > 0 || len(r.Abandoned) > 0 {
+			w.logWarn("Watch retry", r)
+		} else {
+			w.logInfo("Watches recovered", "dirs", len(r.Recovered), "first", r.Recovered[0])
+		}
+	}
+	return r
+}
+
+// watchSubtree 为 dir 之下(不含 dir)未被忽略的子目录注册监控，超出 MaxWatchedDirs 的目录以 *WatchError 报告
+func (w *Watcher) watchSubtree(dir string) {
+	_ = w.fs.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		p = w.keyOf(p)
+		if err != nil || !d.IsDir() || p == dir {
+			return nil
+		}
+		if w.isIgnored(p) {
+			return filepath.SkipDir
+		}
+		if err := w.addWatch(p); errors.Is(err, ErrWatchBudget) {
+			w.emitError(&WatchError{Path: p, Err: err})
+		}
+		return nil
+	})
+}
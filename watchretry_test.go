@@ -0,0 +1,196 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestRetryFailedWatches 测试因 EMFILE 注册失败的目录在上限恢复后被重新注册：
+// 失败期间新建的子目录补注册、错过的变化经对账补上，WatchErrors 与 WatchCoverage 随之更新
+func TestRetryFailedWatches(t *testing.T) {
+	root := t.TempDir()
+	a := filepath.Join(root, "a")
+	b := filepath.Join(a, "b")
+	_ = os.MkdirAll(b, 0755)
+	_ = os.Mkdir(filepath.Join(root, "c"), 0755)
+
+	w := newTestWatcher(t, root)
+	var limited atomic.Bool
+	limited.Store(true)
+	var mu sync.Mutex
+	watched := make(map[string]bool)
+	w.addWatchFn = func(p string) error {
+		if limited.Load() && withinRoot(p, a) {
+			return syscall.EMFILE
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		watched[p] = true
+		return nil
+	}
+	if err := w.registerWatches(); err != nil {
+		t.Fatalf("registerWatches failed: %v", err)
+	}
+	<-w.ErrorChan // *PartialWatchError
+	if errs := w.WatchErrors(); len(errs) != 2 || w.Stats().WatchesFailed != 2 || w.WatchCoverage()[0].Failed != 2 {
+		t.Fatalf("WatchErrors = %+v, stats %d failed, coverage %+v", errs, w.Stats().WatchesFailed, w.WatchCoverage())
+	}
+
+	// 上限未恢复：仍然失败，退避重置
+	recovered, err := w.RetryFailedWatches()
+	var perr *PartialWatchError
+	if len(recovered) != 0 || !errors.As(err, &perr) || len(perr.Failures) != 2 || !errors.Is(err, syscall.EMFILE) {
+		t.Errorf("RetryFailedWatches = %v, %v; want two failures", recovered, err)
+	}
+	var note *WatchRetry
+	if e := <-w.ErrorChan; !errors.As(e, &note) || len(note.Failures) != 2 || !errors.Is(e, ErrWatchLimit) {
+		t.Errorf("expected a *WatchRetry failure notification, got %v", e)
+	}
+
+	// 失败期间的变化没有事件
+	newFile := filepath.Join(a, "new.txt")
+	newDir := filepath.Join(b, "late")
+	_ = os.WriteFile(newFile, []byte("missed"), 0644)
+	_ = os.Mkdir(newDir, 0755)
+
+	limited.Store(false)
+	recovered, err = w.RetryFailedWatches()
+	if err != nil || !slices.Equal(recovered, []string{a, b}) {
+		t.Errorf("RetryFailedWatches = %v, %v; want [%s %s]", recovered, err, a, b)
+	}
+	if e := <-w.ErrorChan; !errors.As(e, &note) || len(note.Recovered) != 2 || len(note.Failures) != 0 || errors.Is(e, ErrWatchLimit) {
+		t.Errorf("expected a *WatchRetry success notification, got %v", e)
+	}
+	mu.Lock()
+	if !watched[a] || !watched[b] || !watched[newDir] {
+		t.Errorf("watched = %v; want %s, %s and %s", watched, a, b, newDir)
+	}
+	mu.Unlock()
+	if _, ok := w.CurrentFile(newFile); !ok {
+		t.Errorf("%s should be reconciled after the watch recovered", newFile)
+	}
+	st := w.Stats()
+	if errs := w.WatchErrors(); len(errs) != 0 || st.WatchesFailed != 0 || st.WatchesRecovered != 2 || w.WatchCoverage()[0].Failed != 0 {
+		t.Errorf("after recovery: WatchErrors = %+v, stats %d failed/%d recovered, coverage %+v",
+			errs, st.WatchesFailed, st.WatchesRecovered, w.WatchCoverage())
+	}
+
+	// 集合为空时什么也不做
+	if recovered, err := w.RetryFailedWatches(); recovered != nil || err != nil || len(w.ErrorChan) != 0 {
+		t.Errorf("empty retry = %v, %v with %d notifications", recovered, err, len(w.ErrorChan))
+	}
+}
+
+// TestWatchRetryRemoved 测试注册失败的目录被删除后移出重试集合与 WatchErrors
+func TestWatchRetryRemoved(t *testing.T) {
+	root := t.TempDir()
+	gone := filepath.Join(root, "gone")
+	_ = os.MkdirAll(filepath.Join(gone, "sub"), 0755)
+
+	w := newTestWatcher(t, root)
+	w.addWatchFn = func(p string) error {
+		if withinRoot(p, gone) {
+			return syscall.ENFILE
+		}
+		return nil
+	}
+	if err := w.registerWatches(); err != nil {
+		t.Fatalf("registerWatches failed: %v", err)
+	}
+	if n := w.Stats().WatchesFailed; n != 2 {
+		t.Fatalf("WatchesFailed = %d; want 2", n)
+	}
+	_ = os.RemoveAll(gone)
+	w.releaseWatches(gone)
+	if errs := w.WatchErrors(); len(errs) != 0 || w.Stats().WatchesFailed != 0 {
+		t.Errorf("removed dir still tracked: WatchErrors = %+v, %d failed", errs, w.Stats().WatchesFailed)
+	}
+}
+
+// TestWatchRetryAbandoned 测试重试时不再因资源耗尽而失败(如无权限)的目录移出重试集合，改为 WatchErrors 中的永久失败
+func TestWatchRetryAbandoned(t *testing.T) {
+	root := t.TempDir()
+	denied := filepath.Join(root, "denied")
+	_ = os.Mkdir(denied, 0755)
+
+	w := newTestWatcher(t, root)
+	var cause atomic.Value
+	cause.Store(syscall.EMFILE)
+	w.addWatchFn = func(p string) error {
+		if p == denied {
+			return cause.Load().(syscall.Errno)
+		}
+		return nil
+	}
+	if err := w.registerWatches(); err != nil {
+		t.Fatalf("registerWatches failed: %v", err)
+	}
+	<-w.ErrorChan // *PartialWatchError
+
+	cause.Store(syscall.EACCES)
+	recovered, err := w.RetryFailedWatches()
+	if len(recovered) != 0 || !errors.Is(err, syscall.EACCES) {
+		t.Errorf("RetryFailedWatches = %v, %v; want the permission error", recovered, err)
+	}
+	var note *WatchRetry
+	if e := <-w.ErrorChan; !errors.As(e, &note) || len(note.Failures) != 0 || len(note.Abandoned) != 1 || errors.Is(e, ErrWatchLimit) {
+		t.Errorf("expected a *WatchRetry with one abandoned dir, got %v", e)
+	}
+	errs := w.WatchErrors()
+	if len(errs) != 1 || errs[0].Path != denied || !errors.Is(errs[0].Err, syscall.EACCES) {
+		t.Errorf("WatchErrors = %+v; want %s with EACCES", errs, denied)
+	}
+	if n := w.Stats().WatchesFailed; n != 0 {
+		t.Errorf("WatchesFailed = %d; the abandoned dir should leave the retry set", n)
+	}
+	if recovered, err := w.RetryFailedWatches(); recovered != nil || err != nil {
+		t.Errorf("second retry = %v, %v; want nothing to retry", recovered, err)
+	}
+	if errs := w.WatchErrors(); len(errs) != 1 {
+		t.Errorf("WatchErrors after the second retry = %+v; want the permanent failure kept", errs)
+	}
+}
+
+// TestWatchRetryBackoff 测试自动重试的退避：首次在基础间隔之后，仍失败时翻倍直到上限，手动重试后回到基础间隔
+func TestWatchRetryBackoff(t *testing.T) {
+	var s watchRetrySet
+	base := time.Second
+	now := time.Unix(1000, 0)
+	if s.due(now, base) {
+		t.Fatal("empty set should never be due")
+	}
+	s.add("/d", syscall.EMFILE)
+	if s.due(now, base) || !s.due(now.Add(base), base) {
+		t.Fatal("the first retry should be one interval after the failure")
+	}
+	now = now.Add(base)
+	for _, want := range []time.Duration{2 * base, 4 * base, 8 * base} {
+		s.backoff(now, base, false)
+		if s.due(now.Add(want-time.Millisecond), base) || !s.due(now.Add(want), base) {
+			t.Fatalf("next retry should be %v later", want)
+		}
+		now = now.Add(want)
+	}
+	for i := 0; i < 20; i++ {
+		s.backoff(now, base, false)
+	}
+	if s.delay != watchRetryMaxBackoff {
+		t.Errorf("delay = %v; want capped at %v", s.delay, watchRetryMaxBackoff)
+	}
+	s.backoff(now, base, true)
+	if !s.due(now.Add(base), base) {
+		t.Error("a manual retry should reset the backoff")
+	}
+	s.remove("/d")
+	s.backoff(now, base, false)
+	if s.due(now.Add(time.Hour), base) || !s.next.IsZero() {
+		t.Error("schedule should be cleared once the set is empty")
+	}
+}
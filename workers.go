@@ -0,0 +1,194 @@
+package watcher
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// worker 利用率与自动调整
+//
+// 每个变更的处理耗时(真实时间)累计为 worker 忙碌时间，每隔 WorkerTuneInterval 按"忙碌时间 / (上限 × 区间长度)"
+// 计算一次利用率(Stats().WorkerUtilization)；flush 提交路径时令牌已被占满、不得不等待的次数计入 Stats().WorkerWaits。
+// 开启 WorkerAutoTune 时，连续 workerTuneSustain 个区间饱和(利用率不低于 workerSaturated 或发生过等待)则把上限
+// 提高约四分之一(不超过 WorkerMax)，连续同样多个区间空闲(利用率低于 workerIdle 且没有等待)则降低约四分之一(不低于 WorkerMin)。
+//
+// 有效上限通过"预留"实现：workerPool 的容量为可能的最大值，超出有效上限的令牌由 runWorkers 占住，
+// 降低上限时等正在处理的 worker 归还令牌后才生效，不会打断进行中的处理
+
+const (
+	// DefaultWorkerTuneInterval 是 ConfigWatcher.WorkerTuneInterval 的默认值
+	DefaultWorkerTuneInterval = 10 * time.Second
+
+	workerSaturated   = 0.9 // 利用率不低于该值视为饱和
+	workerIdle        = 0.3 // 利用率低于该值视为空闲
+	workerTuneSustain = 3   // 连续多少个区间饱和/空闲才调整
+)
+
+// workerState 是有效 worker 上限与利用率统计
+type workerState struct {
+	mu     sync.Mutex
+	limit  int  // 有效上限
+	manual bool // SetWorkerLimit 设置过上限，自动调整不再生效
+	hot    int  // 连续饱和的区间数
+	cold   int  // 连续空闲的区间数
+
+	lastAt    time.Time
+	lastBusy  int64
+	lastWaits uint64
+
+	reserved atomic.Int64  // runWorkers 占住的令牌数
+	wake     chan struct{} // 上限变化时唤醒 runWorkers
+
+	busy      atomic.Int64  // 累计忙碌时间(纳秒)
+	waits     atomic.Uint64 // 提交时等待令牌的 flush 次数
+	maxChange atomic.Uint64 // 单个变更的最长处理耗时(纳秒)
+	util      atomic.Uint64 // 最近一个区间的利用率(math.Float64bits)
+}
+
+// workerLimit 返回有效 worker 上限
+func (w *Watcher) workerLimit() int {
+	w.workers.mu.Lock()
+	defer w.workers.mu.Unlock()
+	return w.workers.limit
+}
+
+// setWorkerLimitLocked 修改有效上限并唤醒 runWorkers，调用方需持有 w.workers.mu
+func (w *Watcher) setWorkerLimitLocked(n int) {
+	w.workers.limit = n
+	w.workers.hot, w.workers.cold = 0, 0
+	select {
+	case w.workers.wake <- struct{}{}:
+	default:
+	}
+}
+
+// SetWorkerLimit 手动设置并发处理变更的 worker 上限，n 须在 1 到 WorkerCount(开启 WorkerAutoTune 时为 WorkerMax)之间
+//
+// 手动设置总是优先：之后自动调整不再生效。降低上限时正在处理的变更不受影响，处理完成后才按新上限限制
+// 并发安全
+func (w *Watcher) SetWorkerLimit(n int) error {
+	if n < 1 || n > cap(w.workerPool) {
+		return fmt.Errorf("%w: worker limit %d out of range [1, %d]", ErrInvalidConfig, n, cap(w.workerPool))
+	}
+	w.workers.mu.Lock()
+	defer w.workers.mu.Unlock()
+	w.workers.manual = true
+	w.setWorkerLimitLocked(n)
+	return nil
+}
+
+// processItem 处理一个变更并记录 worker 忙碌时间与单个变更的最长耗时
+func (w *Watcher) processItem(it aggItem, replay bool, span BatchSpan) {
+	start := time.Now()
+	w.applyChange(it, replay, span)
+	d := time.Since(start)
+	w.workers.busy.Add(int64(d))
+	observeHighWater(&w.workers.maxChange, uint64(d))
+}
+
+// acquireWorker 为 flush 与初始扫描领取一个 worker 令牌，返回是否因令牌已被占满而等待
+func (w *Watcher) acquireWorker() bool {
+	select {
+	case w.workerPool <- struct{}{}:
+		return false
+	default:
+	}
+	w.workerPool <- struct{}{}
+	return true
+}
+
+// reserveWorkers 按有效上限占住或归还 workerPool 中多余的令牌，不阻塞；返回是否因令牌正被使用而还需占住更多
+//
+// Start 在启动任何会领取令牌的goroutine之前调用一次，之后只由 runWorkers 调用
+func (w *Watcher) reserveWorkers() bool {
+	want := int64(cap(w.workerPool) - w.workerLimit())
+	// 占住的令牌都在通道中，归还(取出)不会阻塞
+	for w.workers.reserved.Load() > want {
+		<-w.workerPool
+		w.workers.reserved.Add(-1)
+	}
+	for w.workers.reserved.Load() < want {
+		select {
+		case w.workerPool <- struct{}{}:
+			w.workers.reserved.Add(1)
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// runWorkers 按有效上限占住或归还 workerPool 中多余的令牌，并每隔 WorkerTuneInterval 统计利用率、自动调整上限
+func (w *Watcher) runWorkers() {
+	defer w.bgWG.Done()
+	t := w.clock.NewTicker(w.cfg.WorkerTuneInterval)
+	defer t.Stop()
+	for {
+		var reserve chan<- struct{}
+		if w.reserveWorkers() {
+			reserve = w.workerPool
+		}
+		select {
+		case reserve <- struct{}{}:
+			w.workers.reserved.Add(1)
+		case <-w.workers.wake:
+		case <-t.C():
+			w.sampleWorkers(time.Now())
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+// sampleWorkers 计算自上次统计以来的利用率，开启 WorkerAutoTune 且未手动设置上限时按持续的饱和/空闲调整上限
+func (w *Watcher) sampleWorkers(now time.Time) {
+	s := &w.workers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	busy, waits := s.busy.Load(), s.waits.Load()
+	elapsed := now.Sub(s.lastAt)
+	if s.lastAt.IsZero() || elapsed <= 0 {
+		s.lastAt, s.lastBusy, s.lastWaits = now, busy, waits
+		return
+	}
+	util := float64(busy-s.lastBusy) / (float64(s.limit) * float64(elapsed))
+	waited := waits > s.lastWaits
+	s.lastAt, s.lastBusy, s.lastWaits = now, busy, waits
+	s.util.Store(math.Float64bits(util))
+	if !w.cfg.WorkerAutoTune || s.manual {
+		return
+	}
+
+	switch {
+	case util >= workerSaturated || waited:
+		s.hot, s.cold = s.hot+1, 0
+	case util < workerIdle:
+		s.hot, s.cold = 0, s.cold+1
+	default:
+		s.hot, s.cold = 0, 0
+	}
+	old, n := s.limit, s.limit
+	step := max(1, old/4)
+	if s.hot >= workerTuneSustain {
+		n = min(old+step, w.cfg.WorkerMax)
+	} else if s.cold >= workerTuneSustain {
+		n = max(old-step, w.cfg.WorkerMin)
+	}
+	if n == old {
+		return
+	}
+	w.setWorkerLimitLocked(n)
+	w.logInfo("Worker limit adjusted", "from", old, "to", n, "utilization", util, "waited", waited)
+}
+
+// workerStats 返回利用率相关的统计：有效上限、正在处理的 worker 数、利用率、累计忙碌时间、等待次数与单个变更的最长耗时
+func (w *Watcher) workerStats() (limit, inFlight int, util float64, busy time.Duration, waits uint64, longest time.Duration) {
+	s := &w.workers
+	limit = w.workerLimit()
+	inFlight = max(0, len(w.workerPool)-int(s.reserved.Load()))
+	util = math.Float64frombits(s.util.Load())
+	return limit, inFlight, util, time.Duration(s.busy.Load()), s.waits.Load(), time.Duration(s.maxChange.Load())
+}
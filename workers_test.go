@@ -0,0 +1,118 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestWorkerStats 测试忙碌时间、单个变更的最长耗时与 flush 等待令牌的计数
+func TestWorkerStats(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithWorkerCount(1), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	file := filepath.Join(root, "a.txt")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+
+	// 唯一的令牌被占用：flush 必须等待
+	w.workerPool <- struct{}{}
+	w.queueAgg(fsnotify.Event{Name: w.keyOf(file), Op: fsnotify.Create})
+	w.mergeAgg(<-w.aggChan)
+	done := make(chan struct{})
+	go func() {
+		w.flushAgg(false)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	<-w.workerPool
+	<-done
+	w.workerWG.Wait()
+
+	st := w.Stats()
+	if st.WorkerWaits != 1 || st.WorkerBusyTime <= 0 || st.MaxChangeDuration <= 0 || st.MaxChangeDuration > st.WorkerBusyTime {
+		t.Errorf("stats: waits %d, busy %v, longest %v", st.WorkerWaits, st.WorkerBusyTime, st.MaxChangeDuration)
+	}
+	if st.WorkerCount != 1 || st.InFlightWorkers != 0 {
+		t.Errorf("WorkerCount %d, InFlightWorkers %d; want 1, 0", st.WorkerCount, st.InFlightWorkers)
+	}
+	w.ResetLatencyStats()
+	if st := w.Stats(); st.MaxChangeDuration != 0 || st.WorkerBusyTime == 0 {
+		t.Errorf("ResetLatencyStats: longest %v, busy %v", st.MaxChangeDuration, st.WorkerBusyTime)
+	}
+}
+
+// TestWorkerAutoTune 测试持续饱和时提高上限、持续空闲时降低，手动设置的上限优先且按预留令牌生效
+func TestWorkerAutoTune(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWatcherWithOptions([]string{root}, WithWorkerCount(4), WithWorkerAutoTune(2, 8), WithDisableEventChan())
+	if err != nil {
+		t.Fatalf("NewWatcherWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	if cap(w.workerPool) != 8 {
+		t.Fatalf("worker pool capacity %d; want WorkerMax", cap(w.workerPool))
+	}
+	const interval = time.Second
+	now := time.Unix(0, 0)
+	sample := func(util float64) {
+		w.workers.busy.Add(int64(util * float64(w.workerLimit()) * float64(interval)))
+		now = now.Add(interval)
+		w.sampleWorkers(now)
+	}
+	w.sampleWorkers(now)
+	for i, want := range []int{4, 4, 5, 5, 5, 6} {
+		sample(1)
+		if got := w.workerLimit(); got != want {
+			t.Fatalf("saturated sample %d: limit %d; want %d", i, got, want)
+		}
+	}
+	if u := w.Stats().WorkerUtilization; u < 0.99 || u > 1.01 {
+		t.Errorf("WorkerUtilization = %v; want 1", u)
+	}
+	sample(0.5) // 既不饱和也不空闲：重新计数
+	for i, want := range []int{6, 6, 5} {
+		sample(0)
+		if got := w.workerLimit(); got != want {
+			t.Fatalf("idle sample %d: limit %d; want %d", i, got, want)
+		}
+	}
+
+	// 手动设置优先，之后不再自动调整；多余的令牌由 runWorkers 占住
+	w.bgWG.Add(1)
+	go w.runWorkers()
+	if err := w.SetWorkerLimit(2); err != nil {
+		t.Fatalf("SetWorkerLimit failed: %v", err)
+	}
+	for i := 0; i < workerTuneSustain; i++ {
+		sample(1)
+	}
+	if got := w.workerLimit(); got != 2 {
+		t.Errorf("limit %d after a manual override; want 2", got)
+	}
+	if !waitFor(t, time.Second, func() bool { return len(w.workerPool) == 6 }) {
+		t.Errorf("%d tokens held; want 6 reserved", len(w.workerPool))
+	}
+	if st := w.Stats(); st.WorkerCount != 2 || st.InFlightWorkers != 0 {
+		t.Errorf("WorkerCount %d, InFlightWorkers %d; want 2, 0", st.WorkerCount, st.InFlightWorkers)
+	}
+	_ = w.SetWorkerLimit(7)
+	if !waitFor(t, time.Second, func() bool { return len(w.workerPool) == 1 }) {
+		t.Errorf("%d tokens held; want 1 reserved", len(w.workerPool))
+	}
+	for _, n := range []int{0, 9} {
+		if err := w.SetWorkerLimit(n); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("SetWorkerLimit(%d) = %v; want ErrInvalidConfig", n, err)
+		}
+	}
+
+	if _, err := NewWatcherWithOptions([]string{root}, WithWorkerCount(4), WithWorkerAutoTune(5, 8)); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("WorkerCount below WorkerMin: %v; want ErrInvalidConfig", err)
+	}
+}